@@ -0,0 +1,162 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"github.com/chzyer/readline"
+	"github.com/spf13/cobra"
+
+	"github.com/michaelbrown/forge/internal/agent"
+	"github.com/michaelbrown/forge/internal/config"
+	"github.com/michaelbrown/forge/internal/llm"
+	"github.com/michaelbrown/forge/internal/tools"
+)
+
+var askTools bool
+
+var askCmd = &cobra.Command{
+	Use:   "ask [question]",
+	Short: "Ask the model a quick question and stream its answer",
+	Long: `Ask the model a question and stream its answer, with no session saved
+and no tool servers started: a single call to the model. Input piped to
+stdin is added to the question, and @path and @url references in it are
+expanded, as with forge run.
+
+With --tools, the agent answers instead, using the configured tool servers,
+or the built-in pack, as it needs to. Actions they ask approval for are
+put to you on the terminal, and refused when stdin isn't one.
+
+Examples:
+  forge ask "what does the -z flag to tar do?"
+  git diff | forge ask "write a commit message for this"
+  forge ask --tools "which process is listening on port 8080?"`,
+	RunE:          runAsk,
+	SilenceUsage:  true,
+	SilenceErrors: true,
+}
+
+func init() {
+	rootCmd.AddCommand(askCmd)
+
+	askCmd.Flags().BoolVar(&askTools, "tools", false, "Let the agent use tools to answer")
+}
+
+func runAsk(cmd *cobra.Command, args []string) error {
+	question, err := runPrompt(args, os.Stdin)
+	if err != nil {
+		return &exitError{exitUsage, err}
+	}
+	cfg, err := config.Load()
+	if err != nil {
+		return &exitError{exitUsage, fmt.Errorf("loading config: %w", err)}
+	}
+	setup, err := resolveAgent(cfg, false)
+	if err != nil {
+		return &exitError{exitUsage, err}
+	}
+
+	// Replies are rendered as markdown when stdout is a terminal.
+	md := newMarkdownStream(os.Stdout)
+	write := func(delta string) {
+		if md != nil {
+			md.Write(delta)
+			return
+		}
+		fmt.Print(delta)
+	}
+	flush := func() {
+		if md != nil {
+			md.Flush()
+		}
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	var answer string
+	if askTools {
+		answer, err = askAgent(ctx, cfg, setup, question, write, flush)
+	} else {
+		var system []string
+		if setup.profile != nil && setup.profile.SystemPrompt != "" {
+			system = append(system, setup.profile.SystemPrompt)
+		}
+		if setup.projectContext != "" {
+			system = append(system, setup.projectContext)
+		}
+		var messages []llm.Message
+		if len(system) > 0 {
+			messages = append(messages, llm.SystemMessage(strings.Join(system, "\n\n")))
+		}
+		messages = append(messages, llm.UserMessage(question))
+		client := llm.NewClient(setup.provider.BaseURL, setup.provider.APIKey, setup.model)
+		var resp *llm.Response
+		if resp, err = client.ChatCompletionStream(ctx, messages, nil, write); err == nil {
+			answer = resp.Message.Content
+		}
+	}
+	flush()
+
+	switch {
+	case err == nil:
+	case ctx.Err() != nil:
+		fmt.Println()
+		return &exitError{exitInterrupted, errors.New("interrupted")}
+	default:
+		fmt.Println()
+		return &exitError{exitFailed, err}
+	}
+	if md != nil || !strings.HasSuffix(answer, "\n") {
+		fmt.Println()
+	}
+	return nil
+}
+
+// askAgent answers question with the agent, using the configured tool
+// servers, which are started without storage: their calls aren't recorded
+// and artifacts aren't kept.
+func askAgent(ctx context.Context, cfg *config.Config, setup *agentSetup, question string, write func(string), flush func()) (string, error) {
+	registry := tools.NewRegistry()
+	defer registry.Close()
+	for name, toolCfg := range cfg.Tools {
+		if err := registry.Register(name, toolCfg); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to start tool server %s: %v\n", name, err)
+		}
+	}
+	registry.SetApprover(askApproval)
+
+	a := setup.newAgent(registry)
+	a.OnTextDelta = write
+	a.OnToolCall = func(name string, args map[string]any) {
+		flush()
+		fmt.Fprintf(os.Stderr, "\n  \033[33m⚡ Tool: %s\033[0m\n", agent.FormatToolCall(name, args))
+	}
+	return a.RunStreaming(ctx, question)
+}
+
+// askApproval puts an action a tool server wants to take to the user, on
+// stderr, reading the answer from stdin if it is a terminal.
+func askApproval(ctx context.Context, server, message string) (bool, error) {
+	fmt.Fprintf(os.Stderr, "\n  \033[33m? %s needs approval:\033[0m\n", server)
+	for _, line := range strings.Split(message, "\n") {
+		fmt.Fprintf(os.Stderr, "  \033[33m│\033[0m %s\n", line)
+	}
+	if !readline.IsTerminal(int(os.Stdin.Fd())) {
+		fmt.Fprintln(os.Stderr, "  refused: stdin isn't a terminal")
+		return false, nil
+	}
+	fmt.Fprint(os.Stderr, "  approve? [y/N] ")
+	answer, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil {
+		return false, nil
+	}
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes", nil
+}
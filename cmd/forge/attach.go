@@ -0,0 +1,437 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"github.com/chzyer/readline"
+
+	"github.com/michaelbrown/forge/internal/config"
+	"github.com/michaelbrown/forge/internal/daemon"
+	"github.com/michaelbrown/forge/internal/mention"
+	"github.com/michaelbrown/forge/internal/storage"
+)
+
+// apiClient talks to a running forge server's API.
+type apiClient struct {
+	name string // the server's URL, as given
+	base string // the URL requests go to; for a Unix socket, a stand-in host
+	http *http.Client
+	auth func(*http.Request)
+}
+
+// newAPIClient returns a client for the server at rawURL, or, if it is
+// empty, the one forge serve --daemon started, or else the one the config
+// says forge serve listens on. It signs in with the config's first
+// server.auth credential that may send messages.
+func newAPIClient(cfg *config.Config, rawURL string) (*apiClient, error) {
+	if rawURL == "" {
+		if st, err := daemon.Read(daemonDir(cfg)); err == nil {
+			rawURL = localURL(st.Addr, st.TLS)
+		} else {
+			rawURL = localURL(serveAddr(cfg), serveTLS(cfg))
+		}
+	}
+	c := &apiClient{name: rawURL, base: strings.TrimSuffix(rawURL, "/"), http: &http.Client{}, auth: func(*http.Request) {}}
+	if path, ok := strings.CutPrefix(rawURL, "unix://"); ok {
+		c.base = "http://forge"
+		c.http.Transport = &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				return (&net.Dialer{}).DialContext(ctx, "unix", path)
+			},
+		}
+	} else if u, err := url.Parse(rawURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("invalid server URL %q: want http://host:port, https://host:port, or unix:///path", rawURL)
+	} else if u.Scheme == "https" && (u.Hostname() == "localhost" || u.Hostname() == "127.0.0.1") {
+		// A certificate is for the server's public name, not localhost.
+		c.http.Transport = &http.Transport{TLSClientConfig: &tls.Config{ServerName: certName(cfg)}}
+	}
+
+	for _, k := range cfg.Server.Auth.APIKeys {
+		if k.Scope != config.ScopeRead {
+			key := k.Key
+			c.auth = func(r *http.Request) { r.Header.Set("Authorization", "Bearer "+key) }
+			return c, nil
+		}
+	}
+	for _, u := range cfg.Server.Auth.Users {
+		if u.Scope != config.ScopeRead {
+			user, password := u.Username, u.Password
+			c.auth = func(r *http.Request) { r.SetBasicAuth(user, password) }
+			return c, nil
+		}
+	}
+	return c, nil
+}
+
+// certName returns the name the server's certificate is for, if server.tls
+// gets one from Let's Encrypt; a certificate file is trusted for whatever
+// name it has.
+func certName(cfg *config.Config) string {
+	if domains := cfg.Server.TLS.Autocert.Domains; len(domains) > 0 {
+		return domains[0]
+	}
+	return ""
+}
+
+// request sends a request with body, if any, as JSON.
+func (c *apiClient) request(ctx context.Context, method, path string, body any) (*http.Response, error) {
+	var r io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		r = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.base+path, r)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	c.auth(req)
+	resp, err := c.http.Do(req)
+	if err != nil {
+		var opErr *net.OpError
+		if errors.As(err, &opErr) && opErr.Op == "dial" {
+			return nil, fmt.Errorf("no forge server at %s; start one with forge serve --daemon", c.name)
+		}
+		return nil, err
+	}
+	if resp.StatusCode >= 300 {
+		defer resp.Body.Close()
+		var apiErr struct {
+			Error string `json:"error"`
+		}
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<16))
+		if json.Unmarshal(data, &apiErr) != nil || apiErr.Error == "" {
+			apiErr.Error = strings.TrimSpace(string(data))
+		}
+		return nil, fmt.Errorf("%s %s: %s (%s)", method, path, apiErr.Error, resp.Status)
+	}
+	return resp, nil
+}
+
+// call sends a request and decodes the JSON reply into out, if it isn't
+// nil.
+func (c *apiClient) call(ctx context.Context, method, path string, body, out any) error {
+	resp, err := c.request(ctx, method, path, body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// serverEvent is an event the server streams while the agent works.
+type serverEvent struct {
+	Type     string         `json:"type"`
+	Content  string         `json:"content"`
+	Name     string         `json:"name"`
+	Args     map[string]any `json:"args"`
+	Position int            `json:"position"`
+}
+
+// send posts a message to the session and passes the events the server
+// streams back to onEvent until the turn ends. Cancelling ctx closes the
+// connection, which interrupts the agent.
+func (c *apiClient) send(ctx context.Context, sessionID, content string, onEvent func(serverEvent)) error {
+	resp, err := c.request(ctx, http.MethodPost, "/api/sessions/"+sessionID+"/messages/stream", map[string]string{"content": content})
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(nil, 16<<20)
+	for scanner.Scan() {
+		data, ok := strings.CutPrefix(scanner.Text(), "data: ")
+		if !ok {
+			continue
+		}
+		var ev serverEvent
+		if err := json.Unmarshal([]byte(data), &ev); err != nil {
+			return fmt.Errorf("reading events: %w", err)
+		}
+		onEvent(ev)
+	}
+	return scanner.Err()
+}
+
+// attachSession returns the session --resume or --continue names, the
+// picker's choice, or a new session, from the server.
+func attachSession(ctx context.Context, c *apiClient, args []string) (*storage.Session, bool, error) {
+	switch {
+	case continueLast && resumeID != "":
+		return nil, false, errors.New("--resume and --continue can't be used together")
+	case resumeID == pickFlag && len(args) > 0:
+		resumeID = args[0]
+	case len(args) > 0:
+		return nil, false, fmt.Errorf("unexpected argument %q; to resume a session, use --resume %s", args[0], args[0])
+	}
+
+	var sess storage.Session
+	switch {
+	case continueLast || resumeID == pickFlag:
+		limit := 1
+		if resumeID == pickFlag {
+			limit = pickerLimit
+		}
+		var sessions []storage.Session
+		if err := c.call(ctx, http.MethodGet, fmt.Sprintf("/api/sessions?limit=%d", limit), nil, &sessions); err != nil {
+			return nil, false, err
+		}
+		if continueLast {
+			if len(sessions) == 0 {
+				return nil, false, errors.New("no sessions to continue")
+			}
+			return &sessions[0], true, nil
+		}
+		if !readline.IsTerminal(int(os.Stdin.Fd())) || !readline.IsTerminal(int(os.Stdout.Fd())) {
+			return nil, false, errors.New("--resume needs a session ID when not run in a terminal")
+		}
+		picked, err := runPicker(ctx, sessions)
+		return picked, true, err
+	case resumeID != "":
+		err := c.call(ctx, http.MethodGet, "/api/sessions/"+url.PathEscape(resumeID), nil, &sess)
+		return &sess, true, err
+	}
+	err := c.call(ctx, http.MethodPost, "/api/sessions", map[string]string{
+		"provider": providerFlag,
+		"model":    modelFlag,
+		"profile":  profileFlag,
+	}, &sess)
+	return &sess, false, err
+}
+
+// runAttached runs forge chat against a running server: the session, its
+// agent, and the tool servers are the server's, shared with the web UI and
+// other clients, and only the conversation is shown here.
+func runAttached(cfg *config.Config, args []string) error {
+	c, err := newAPIClient(cfg, attachURL)
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+	sess, resumed, err := attachSession(ctx, c, args)
+	if errors.Is(err, errNotPicked) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Forge - Interactive Agent Chat (attached to %s)\n", c.name)
+	if sess.Profile != "" {
+		fmt.Printf("Profile: %s\n", sess.Profile)
+	}
+	fmt.Printf("Provider: %s | Model: %s\n", sess.Provider, sess.Model)
+	if resumed {
+		fmt.Printf("Session: %s (resumed)\n", shortID(sess.ID))
+	} else {
+		fmt.Printf("Session: %s\n", shortID(sess.ID))
+	}
+	fmt.Printf("Type /help for commands, /quit to exit\n\n")
+
+	rl, err := readline.NewEx(&readline.Config{
+		Prompt:          "\033[36myou>\033[0m ",
+		HistoryFile:     "/tmp/forge_history",
+		InterruptPrompt: "^C",
+		EOFPrompt:       "exit",
+	})
+	if err != nil {
+		return fmt.Errorf("readline: %w", err)
+	}
+	defer rl.Close()
+
+	markdown := newMarkdownStream(os.Stdout)
+	flush := func() {
+		if markdown != nil {
+			markdown.Flush()
+		}
+	}
+	notifier := newTurnNotifier(cfg, func(warning string) {
+		fmt.Fprintf(os.Stderr, "\n\033[33m%s\033[0m\n", warning)
+	})
+
+	// Ctrl+C stops the reply in progress.
+	var reqCancel context.CancelFunc
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(sigCh)
+	go func() {
+		for range sigCh {
+			if reqCancel != nil {
+				reqCancel()
+			}
+		}
+	}()
+
+	for {
+		input, err := readMessage(rl)
+		if err != nil {
+			if err == readline.ErrInterrupt || err == io.EOF {
+				fmt.Println("\nGoodbye!")
+				return nil
+			}
+			return err
+		}
+		if input == "" {
+			continue
+		}
+		switch {
+		case input == "/editor" || strings.HasPrefix(input, "/editor "):
+			input, err = editMessage(strings.TrimSpace(strings.TrimPrefix(input, "/editor")))
+			if err != nil {
+				fmt.Printf("error: %v\n\n", err)
+				continue
+			}
+			if input == "" {
+				fmt.Println("Empty message, nothing sent.")
+				fmt.Println()
+				continue
+			}
+			fmt.Println(input)
+		case strings.HasPrefix(input, "/") && !strings.Contains(input, "\n"):
+			if attachedCommand(ctx, c, sess, input) {
+				return nil
+			}
+			continue
+		}
+
+		reqCtx, cancel := context.WithCancel(ctx)
+		reqCancel = cancel
+
+		// @path and @url references are read here, where they make sense.
+		message, refs, err := mention.Expand(reqCtx, input, mention.Options{})
+		if err != nil {
+			cancel()
+			reqCancel = nil
+			fmt.Printf("\033[31merror: %s\033[0m\n\n", err)
+			continue
+		}
+		for _, ref := range refs {
+			fmt.Printf("  \033[90m+ %s\033[0m\n", describeReference(ref))
+		}
+
+		fmt.Printf("\n\033[32mforge>\033[0m ")
+		notifier.begin()
+		var reply string
+		var turnErr error
+		err = c.send(reqCtx, sess.ID, message, func(ev serverEvent) {
+			switch ev.Type {
+			case "text_delta":
+				if markdown != nil {
+					markdown.Write(ev.Content)
+				} else {
+					fmt.Print(ev.Content)
+				}
+			case "tool_call":
+				flush()
+				printToolCall(ev.Name, ev.Args)
+			case "tool_output":
+				printToolOutput(ev.Content)
+			case "tool_result":
+				printToolResult(ev.Content)
+			case "queued":
+				fmt.Printf("\033[90m(waiting for %s ahead)\033[0m ", plural(ev.Position, "message"))
+			case "done":
+				reply = ev.Content
+			case "error":
+				turnErr = errors.New(ev.Content)
+			}
+		})
+		interrupted := reqCtx.Err() != nil
+		cancel()
+		reqCancel = nil
+		flush()
+		if err == nil {
+			err = turnErr
+		}
+		switch {
+		case interrupted:
+			fmt.Println("\n(interrupted)")
+			continue
+		case err != nil:
+			fmt.Printf("\n\033[31merror: %s\033[0m\n\n", err)
+		default:
+			fmt.Printf("\n\n")
+		}
+		// The server titles the session from its first message.
+		var latest storage.Session
+		if c.call(ctx, http.MethodGet, "/api/sessions/"+sess.ID, nil, &latest) == nil {
+			*sess = latest
+		}
+		notifier.finished(sess.Title, reply, err)
+	}
+}
+
+// attachedCommand runs a slash command while attached, reporting whether
+// it was /quit.
+func attachedCommand(ctx context.Context, c *apiClient, sess *storage.Session, input string) bool {
+	fields := strings.Fields(input)
+	switch strings.ToLower(fields[0]) {
+	case "/quit", "/exit", "/q":
+		fmt.Println("Goodbye!")
+		return true
+	case "/model":
+		if len(fields) == 1 {
+			fmt.Printf("Provider: %s | Model: %s\n\n", sess.Provider, sess.Model)
+			break
+		}
+		var updated storage.Session
+		if err := c.call(ctx, http.MethodPatch, "/api/sessions/"+sess.ID+"/model", map[string]string{"model": fields[1]}, &updated); err != nil {
+			fmt.Printf("Error: %v\n\n", err)
+			break
+		}
+		*sess = updated
+		fmt.Printf("Switched to %s/%s\n\n", sess.Provider, sess.Model)
+	case "/title":
+		title := strings.TrimSpace(strings.TrimPrefix(input, fields[0]))
+		if title == "" {
+			if sess.Title == "" {
+				fmt.Println("Untitled session")
+			} else {
+				fmt.Printf("Title: %s\n", sess.Title)
+			}
+			fmt.Println()
+			break
+		}
+		var updated storage.Session
+		if err := c.call(ctx, http.MethodPatch, "/api/sessions/"+sess.ID, map[string]string{"title": title}, &updated); err != nil {
+			fmt.Printf("Error: %v\n\n", err)
+			break
+		}
+		*sess = updated
+		fmt.Printf("Renamed session to %q\n\n", title)
+	case "/help":
+		fmt.Println("Commands (attached to a server):")
+		fmt.Println("  /help              - Show this help")
+		fmt.Println("  /model             - Show current provider and model")
+		fmt.Println("  /model <model>     - Switch model, provider, or provider/model")
+		fmt.Println("  /title [title]     - Show or rename the session")
+		fmt.Println("  /editor [text]     - Write a message in $EDITOR, starting from text")
+		fmt.Println("  /quit              - Exit; the session stays on the server")
+		fmt.Println()
+	default:
+		fmt.Printf("Unknown command: %s (/help lists those that work while attached)\n\n", fields[0])
+	}
+	return false
+}
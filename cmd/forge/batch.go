@@ -0,0 +1,304 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/michaelbrown/forge/internal/agent"
+	"github.com/michaelbrown/forge/internal/batch"
+	"github.com/michaelbrown/forge/internal/config"
+	"github.com/michaelbrown/forge/internal/mention"
+	"github.com/michaelbrown/forge/internal/storage"
+	"github.com/michaelbrown/forge/internal/storage/sqlite"
+	"github.com/michaelbrown/forge/internal/tools"
+)
+
+var (
+	batchConcurrency int
+	batchTimeout     time.Duration
+	batchResults     string
+	batchApprove     bool
+	batchDryRun      bool
+	batchVerbose     bool
+)
+
+var batchCmd = &cobra.Command{
+	Use:   "batch tasks.yaml",
+	Short: "Run the agent on each prompt in a task file",
+	Long: `Run the agent on each prompt in a task file, as forge run would, several
+at a time. The prompts are listed in the file, or made by filling in a
+template with each row of a CSV or JSON input, for bulk edits and labeling
+datasets. Each item is saved as a session tagged batch; its answer is
+written to the file the output template names, and every item's outcome
+to the results file, as JSON Lines. With neither, answers are printed.
+
+  prompt: "Label the sentiment of this review as positive, negative, or mixed: {{.text}}"
+  input: reviews.csv
+  output: "labels/{{.id}}.txt"
+  results: results.jsonl
+  concurrency: 4
+  timeout: 2m
+
+Paths in the file are relative to it. Tool servers that ask for approval
+are refused unless --approve is given. The README describes every key.
+
+Exits with status 1 if any item failed, and 130 when interrupted.`,
+	Args:          cobra.ExactArgs(1),
+	RunE:          runBatch,
+	SilenceUsage:  true,
+	SilenceErrors: true,
+}
+
+func init() {
+	rootCmd.AddCommand(batchCmd)
+
+	batchCmd.Flags().IntVarP(&batchConcurrency, "concurrency", "j", 0, "Items to run at once (overrides the file)")
+	batchCmd.Flags().DurationVar(&batchTimeout, "timeout", 0, "Stop each item after this long (overrides the file)")
+	batchCmd.Flags().StringVar(&batchResults, "results", "", "Write each item's outcome to this JSON Lines file (overrides the file)")
+	batchCmd.Flags().BoolVar(&batchApprove, "approve", false, "Approve every action tool servers ask about instead of refusing")
+	batchCmd.Flags().BoolVar(&batchDryRun, "dry-run", false, "Print each item's prompt and output file without running anything")
+	batchCmd.Flags().BoolVarP(&batchVerbose, "verbose", "v", false, "Show tool calls on stderr as they happen")
+}
+
+// batchResult is the outcome of an item, as written to the results file.
+type batchResult struct {
+	N          int            `json:"n"`
+	Name       string         `json:"name,omitempty"`
+	Vars       map[string]any `json:"vars,omitempty"`
+	SessionID  string         `json:"session_id,omitempty"`
+	Answer     string         `json:"answer"`
+	Output     string         `json:"output,omitempty"`
+	Error      string         `json:"error,omitempty"`
+	DurationMs int64          `json:"duration_ms"`
+}
+
+func runBatch(cmd *cobra.Command, args []string) error {
+	file, err := batch.Load(args[0])
+	if err != nil {
+		return &exitError{exitUsage, err}
+	}
+	items, err := file.Items()
+	if err != nil {
+		return &exitError{exitUsage, err}
+	}
+	if len(items) == 0 {
+		return &exitError{exitUsage, fmt.Errorf("%s: the input has no rows", args[0])}
+	}
+	concurrency, timeout, results := file.Concurrency, file.Timeout, file.Path(file.Results)
+	if cmd.Flags().Changed("concurrency") {
+		concurrency = batchConcurrency
+	}
+	if cmd.Flags().Changed("timeout") {
+		timeout = batchTimeout
+	}
+	if batchResults != "" {
+		results = batchResults
+	}
+	concurrency = max(1, min(concurrency, len(items)))
+
+	if batchDryRun {
+		for _, item := range items {
+			fmt.Printf("── %d/%d %s\n", item.N, len(items), itemTitle(item))
+			if item.Output != "" {
+				fmt.Printf("→ %s\n", item.Output)
+			}
+			fmt.Printf("%s\n\n", strings.TrimSpace(item.Prompt))
+		}
+		return nil
+	}
+
+	// The flags win over the file.
+	if profileFlag == "" {
+		profileFlag = file.Profile
+	}
+	if providerFlag == "" {
+		providerFlag = file.Provider
+	}
+	if modelFlag == "" {
+		modelFlag = file.Model
+	}
+	cfg, err := config.Load()
+	if err != nil {
+		return &exitError{exitUsage, fmt.Errorf("loading config: %w", err)}
+	}
+	setup, err := resolveAgent(cfg, false)
+	if err != nil {
+		return &exitError{exitUsage, err}
+	}
+	tags, err := storage.NormalizeTags(append([]string{"batch"}, file.Tags...))
+	if err != nil {
+		return &exitError{exitUsage, err}
+	}
+
+	store, err := sqlite.Open(cfg.Storage.DBPath)
+	if err != nil {
+		return fmt.Errorf("opening storage: %w", err)
+	}
+	defer store.Close()
+
+	var resultsFile io.WriteCloser
+	if results != "" {
+		if resultsFile, err = os.Create(results); err != nil {
+			return &exitError{exitUsage, err}
+		}
+		defer resultsFile.Close()
+	}
+
+	registry := startTools(cfg, store, os.Stderr)
+	defer registry.Close()
+	registry.SetApprover(func(ctx context.Context, server, message string) (bool, error) {
+		if batchApprove {
+			return true, nil
+		}
+		fmt.Fprintf(os.Stderr, "%s asked for approval, refused (use --approve to allow):\n  %s\n", server, strings.ReplaceAll(message, "\n", "\n  "))
+		return false, nil
+	})
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	fmt.Fprintf(os.Stderr, "Running %s with %s, %d at a time\n", plural(len(items), "item"), setup.model, concurrency)
+	var (
+		mu     sync.Mutex // guards the counts, results file, and output
+		failed int
+		done   int
+		wg     sync.WaitGroup
+	)
+	queue := make(chan batch.Item)
+	for range concurrency {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for item := range queue {
+				r := runBatchItem(ctx, file, setup, store, registry, tags, item, timeout)
+
+				mu.Lock()
+				done++
+				if r.Error != "" {
+					failed++
+					fmt.Fprintf(os.Stderr, "[%d/%d] \033[31m✗\033[0m %s: %s\n", done, len(items), itemTitle(item), r.Error)
+				} else {
+					line := fmt.Sprintf("[%d/%d] \033[32m✓\033[0m %s (%s)", done, len(items), itemTitle(item), formatMs(float64(r.DurationMs)))
+					if r.Output != "" {
+						line += " → " + r.Output
+					}
+					fmt.Fprintln(os.Stderr, line)
+				}
+				if resultsFile != nil {
+					data, _ := json.Marshal(r)
+					if _, err := resultsFile.Write(append(data, '\n')); err != nil {
+						fmt.Fprintf(os.Stderr, "warning: writing results: %v\n", err)
+					}
+				} else if item.Output == "" && r.Error == "" {
+					fmt.Printf("── %d %s\n%s\n\n", item.N, itemTitle(item), strings.TrimSpace(r.Answer))
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	for _, item := range items {
+		if ctx.Err() != nil {
+			break
+		}
+		select {
+		case queue <- item:
+		case <-ctx.Done():
+		}
+	}
+	close(queue)
+	wg.Wait()
+
+	switch {
+	case ctx.Err() != nil:
+		return &exitError{exitInterrupted, fmt.Errorf("interrupted after %d of %d items", done, len(items))}
+	case failed > 0:
+		return &exitError{exitFailed, fmt.Errorf("%d of %d items failed", failed, len(items))}
+	}
+	fmt.Fprintf(os.Stderr, "Done: %s\n", plural(len(items), "item"))
+	return nil
+}
+
+// runBatchItem runs the agent on an item, saving it as a session, and
+// writes its answer to the item's output file, if it has one.
+func runBatchItem(ctx context.Context, file *batch.File, setup *agentSetup, store storage.Store, registry *tools.Registry, tags []string, item batch.Item, timeout time.Duration) batchResult {
+	start := time.Now()
+	r := batchResult{N: item.N, Name: item.Name, Vars: item.Vars, Output: item.Output}
+	finish := func(err error) batchResult {
+		if err != nil {
+			r.Error = err.Error()
+			r.Output = ""
+		}
+		r.DurationMs = time.Since(start).Milliseconds()
+		return r
+	}
+
+	prompt, _, err := mention.Expand(ctx, item.Prompt, mention.Options{Dir: file.Dir()})
+	if err != nil {
+		return finish(err)
+	}
+	sess, err := newRunSession(store, setup, prompt, tags)
+	if err != nil {
+		return finish(err)
+	}
+	r.SessionID = sess.ID
+
+	a := setup.newAgent(registry)
+	if batchVerbose {
+		a.OnToolCall = func(name string, args map[string]any) {
+			fmt.Fprintf(os.Stderr, "[%d] tool: %s\n", item.N, agent.FormatToolCall(name, args))
+		}
+	}
+	runCtx := ctx
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		runCtx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	answer, err := a.Run(tools.WithSession(runCtx, sess.ID), prompt)
+	saveRunSession(store, sess, a, err)
+	r.Answer = answer
+	switch {
+	case err == nil:
+	case ctx.Err() != nil:
+		return finish(errors.New("interrupted"))
+	case errors.Is(runCtx.Err(), context.DeadlineExceeded):
+		return finish(fmt.Errorf("timed out after %s", timeout))
+	default:
+		return finish(err)
+	}
+
+	if item.Output != "" {
+		if err := os.MkdirAll(filepath.Dir(item.Output), 0o755); err != nil {
+			return finish(err)
+		}
+		if err := os.WriteFile(item.Output, []byte(strings.TrimSpace(answer)+"\n"), 0o644); err != nil {
+			return finish(err)
+		}
+	}
+	return finish(nil)
+}
+
+// itemTitle names an item in progress lines: by its name, or else by the
+// start of its prompt.
+func itemTitle(item batch.Item) string {
+	if item.Name != "" {
+		return item.Name
+	}
+	title := generateTitle(strings.SplitN(strings.TrimSpace(item.Prompt), "\n", 2)[0])
+	if len(title) > 60 {
+		title = title[:60] + "..."
+	}
+	return title
+}
@@ -3,14 +3,16 @@ package main
 import (
 	"bufio"
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"os"
 	"os/signal"
-	"path/filepath"
+	"slices"
 	"strconv"
 	"strings"
 	"syscall"
+	"time"
 
 	"github.com/chzyer/readline"
 	"github.com/google/uuid"
@@ -19,12 +21,18 @@ import (
 	"github.com/michaelbrown/forge/internal/agent"
 	"github.com/michaelbrown/forge/internal/config"
 	"github.com/michaelbrown/forge/internal/llm"
+	"github.com/michaelbrown/forge/internal/mention"
 	"github.com/michaelbrown/forge/internal/storage"
 	"github.com/michaelbrown/forge/internal/storage/sqlite"
 	"github.com/michaelbrown/forge/internal/tools"
 )
 
-var resumeID string
+var (
+	resumeID   string
+	chatTUI    bool
+	attachFlag bool
+	attachURL  string
+)
 
 var chatCmd = &cobra.Command{
 	Use:   "chat",
@@ -36,20 +44,38 @@ Examples:
   forge chat
   forge chat --provider claude
   forge chat --provider ollama --model qwen3:8b
-  forge chat --resume <session-id>`,
+  forge chat --resume <session-id>
+  forge chat --resume      # pick from recent sessions
+  forge chat --continue    # resume the most recent session
+  forge chat --attach      # use the forge serve --daemon server's agent
+  forge chat --tui`,
 	RunE: runChat,
 }
 
 func init() {
-	chatCmd.Flags().StringVar(&resumeID, "resume", "", "Resume a previous session by ID or prefix")
+	chatCmd.Flags().StringVar(&resumeID, "resume", "", "Resume a previous session by ID or prefix, or pick one from a list")
+	chatCmd.Flags().Lookup("resume").NoOptDefVal = pickFlag
+	chatCmd.Flags().BoolVarP(&continueLast, "continue", "c", false, "Resume the most recent session")
+	chatCmd.Flags().BoolVar(&chatTUI, "tui", false, "Use the full-screen terminal UI, as forge tui does")
+	chatCmd.Flags().BoolVar(&attachFlag, "attach", false, "Chat through a running forge server instead of starting an agent here")
+	chatCmd.Flags().StringVar(&attachURL, "server", "", "URL of the server to attach to (implies --attach; default: the daemon's, or the configured address)")
 	rootCmd.AddCommand(chatCmd)
 }
 
 func runChat(cmd *cobra.Command, args []string) error {
+	if chatTUI {
+		if attachFlag || attachURL != "" {
+			return errors.New("--attach can't be used with --tui")
+		}
+		return runTUI(cmd, args)
+	}
 	cfg, err := config.Load()
 	if err != nil {
 		return fmt.Errorf("loading config: %w", err)
 	}
+	if attachFlag || attachURL != "" {
+		return runAttached(cfg, args)
+	}
 
 	// Open storage
 	store, err := sqlite.Open(cfg.Storage.DBPath)
@@ -58,99 +84,48 @@ func runChat(cmd *cobra.Command, args []string) error {
 	}
 	defer store.Close()
 
-	// Load agent profile if specified
-	var profile *agent.Profile
-	if profileFlag != "" {
-		profilePath := filepath.Join(cfg.Agent.ProfilesDir, profileFlag+".yaml")
-		profile, err = agent.LoadProfile(profilePath)
-		if err != nil {
-			return fmt.Errorf("loading profile: %w", err)
-		}
-	}
-
-	providerName := providerFlag
-	if providerName == "" {
-		if profile != nil && profile.Provider != "" {
-			providerName = profile.Provider
-		} else {
-			providerName = cfg.DefaultProvider
-		}
-	}
-
-	provider, err := cfg.Provider(providerName)
+	setup, err := resolveAgent(cfg, true)
 	if err != nil {
 		return err
 	}
+	profile, providerName, model := setup.profile, setup.providerName, setup.model
 
-	model := modelFlag
-	if model == "" {
-		if profile != nil && profile.Model != "" {
-			model = profile.Model
-		} else if provider.IsOllama() {
-			picked, err := pickOllamaModel(provider, provider.Models["default"])
-			if err == nil {
-				model = picked
-			} else {
-				model = provider.Models["default"]
-			}
-		} else {
-			model = provider.Models["default"]
-		}
+	// Create or resume session
+	ctx := context.Background()
+	sess, err := resumeSession(ctx, store, args)
+	if errors.Is(err, errNotPicked) {
+		return nil
 	}
-
-	maxIter := cfg.Agent.MaxIterations
-	if profile != nil && profile.MaxIter > 0 {
-		maxIter = profile.MaxIter
+	if err != nil {
+		return err
 	}
+	resumed := sess != nil
 
 	fmt.Printf("Forge - Interactive Agent Chat\n")
 	if profile != nil {
 		fmt.Printf("Profile: %s\n", profile.Name)
 	}
 	fmt.Printf("Provider: %s | Model: %s\n", providerName, model)
+	if setup.projectContext != "" {
+		fmt.Printf("Project: %s (FORGE.md loaded)\n", cfg.Project())
+	}
 
 	// Create tool registry from config
-	registry := tools.NewRegistry()
+	registry := startTools(cfg, store, os.Stdout)
 	defer registry.Close()
 
-	for name, toolCfg := range cfg.Tools {
-		if err := registry.Register(name, toolCfg); err != nil {
-			fmt.Printf("Warning: failed to start tool server %s: %v\n", name, err)
-		}
-	}
-
 	if registry.HasTools() {
 		fmt.Printf("Tools: MCP servers loaded\n")
 	} else {
-		fmt.Printf("Tools: builtin shell_exec\n")
+		fmt.Printf("Tools: built-in pack (no MCP servers configured)\n")
 	}
 
-	client := llm.NewClient(provider.BaseURL, provider.APIKey, model)
-	a := agent.New(client, registry, maxIter)
-	a.SetMaxTokens(cfg.Agent.ContextMaxTokens)
-
-	// Create utility LLM if configured
-	if utilityModel, ok := provider.Models["utility"]; ok && utilityModel != "" {
-		utilityClient := llm.NewClient(provider.BaseURL, provider.APIKey, utilityModel)
-		a.SetUtilityLLM(utilityClient)
+	a := setup.newAgent(registry)
+	if utilityModel := setup.provider.Models["utility"]; utilityModel != "" {
 		fmt.Printf("Utility model: %s\n", utilityModel)
 	}
 
-	// Apply profile overrides
-	if profile != nil {
-		a.SetSystemPrompt(profile.SystemPrompt)
-		a.FilterTools(profile.Tools)
-	}
-
-	// Create or resume session
-	ctx := context.Background()
-	var sess *storage.Session
-
-	if resumeID != "" {
-		sess, err = store.GetSession(ctx, resumeID)
-		if err != nil {
-			return fmt.Errorf("loading session: %w", err)
-		}
+	if resumed {
 		messages, err := store.LoadMessages(ctx, sess.ID)
 		if err != nil {
 			return fmt.Errorf("loading messages: %w", err)
@@ -158,7 +133,7 @@ func runChat(cmd *cobra.Command, args []string) error {
 		a.SetHistory(messages)
 		sess.Status = storage.StatusActive
 		store.UpdateSession(ctx, sess)
-		fmt.Printf("Session: %s (resumed)\n", sess.ID[:8])
+		fmt.Printf("Session: %s (resumed)\n", shortID(sess.ID))
 	} else {
 		sess = &storage.Session{
 			ID:       uuid.New().String(),
@@ -170,7 +145,7 @@ func runChat(cmd *cobra.Command, args []string) error {
 		if err := store.CreateSession(ctx, sess); err != nil {
 			return fmt.Errorf("creating session: %w", err)
 		}
-		fmt.Printf("Session: %s\n", sess.ID[:8])
+		fmt.Printf("Session: %s\n", shortID(sess.ID))
 	}
 
 	cs := &chatState{
@@ -180,31 +155,28 @@ func runChat(cmd *cobra.Command, args []string) error {
 		model:        model,
 		sess:         sess,
 		store:        store,
+		registry:     registry,
+		markdown:     newMarkdownStream(os.Stdout),
+		typed:        map[string]string{},
 	}
 
 	fmt.Printf("Type /help for commands, /quit to exit\n\n")
 
-	// Wire up callbacks for display
+	// Wire up callbacks for display. Replies are rendered as markdown a
+	// block at a time, unless /raw turned that off.
 	a.OnTextDelta = func(delta string) {
+		if cs.markdown != nil && !cs.raw {
+			cs.markdown.Write(delta)
+			return
+		}
 		fmt.Print(delta)
 	}
 	a.OnToolCall = func(name string, args map[string]any) {
-		fmt.Printf("\n  \033[33m⚡ Tool: %s\033[0m\n", agent.FormatToolCall(name, args))
-	}
-	a.OnToolResult = func(name string, result string) {
-		lines := strings.Split(strings.TrimSpace(result), "\n")
-		preview := lines
-		if len(preview) > 8 {
-			preview = preview[:8]
-		}
-		for _, line := range preview {
-			fmt.Printf("  \033[90m│ %s\033[0m\n", line)
-		}
-		if len(lines) > 8 {
-			fmt.Printf("  \033[90m│ ... (%d more lines)\033[0m\n", len(lines)-8)
-		}
-		fmt.Println()
+		cs.flushMarkdown()
+		printToolCall(name, args)
 	}
+	a.OnToolOutput = func(name string, output string) { printToolOutput(output) }
+	a.OnToolResult = func(name string, result string) { printToolResult(result) }
 
 	// Set up readline for input with history
 	rl, err := readline.NewEx(&readline.Config{
@@ -218,8 +190,43 @@ func runChat(cmd *cobra.Command, args []string) error {
 	}
 	defer rl.Close()
 
+	notifier := newTurnNotifier(cfg, func(warning string) {
+		fmt.Fprintf(os.Stderr, "\n\033[33m%s\033[0m\n", warning)
+	})
+
+	// Tool servers ask here before actions that need sign-off, such as
+	// shell commands matching a require_approval pattern.
+	registry.SetApprover(func(ctx context.Context, server, message string) (bool, error) {
+		notifier.approval(server, message)
+		fmt.Printf("\n  \033[33m? %s needs approval:\033[0m\n", server)
+		for _, line := range strings.Split(message, "\n") {
+			fmt.Printf("  \033[33m│\033[0m %s\n", line)
+		}
+		prompt := rl.Config.Prompt
+		rl.SetPrompt("  approve? [y/N] ")
+		defer rl.SetPrompt(prompt)
+		answer, err := rl.Readline()
+		if err != nil {
+			return false, nil
+		}
+		answer = strings.ToLower(strings.TrimSpace(answer))
+		return answer == "y" || answer == "yes", nil
+	})
+
+	// Titles the utility model writes in the background are picked up
+	// between turns, so later session updates don't undo them.
+	titles := make(chan string, 1)
+	applyTitle := func() {
+		select {
+		case title := <-titles:
+			sess.Title = title
+		default:
+		}
+	}
+
 	// Mark session completed on exit
 	defer func() {
+		applyTitle()
 		if sess.Status == storage.StatusActive {
 			sess.Status = storage.StatusCompleted
 			store.UpdateSession(ctx, sess)
@@ -238,10 +245,10 @@ func runChat(cmd *cobra.Command, args []string) error {
 		}
 	}()
 
-	firstMessage := resumeID == "" // track if we need to generate a title
+	firstMessage := !resumed // track if we need to generate a title
 
 	for {
-		input, err := rl.Readline()
+		input, err := readMessage(rl)
 		if err != nil {
 			if err == readline.ErrInterrupt || err == io.EOF {
 				fmt.Println("\nGoodbye!")
@@ -250,38 +257,92 @@ func runChat(cmd *cobra.Command, args []string) error {
 			return err
 		}
 
-		input = strings.TrimSpace(input)
+		applyTitle()
+
 		if input == "" {
 			continue
 		}
 
-		// Handle slash commands
-		if strings.HasPrefix(input, "/") {
+		resend := false // a message sent before, its references expanded
+		switch {
+		case input == "/editor" || strings.HasPrefix(input, "/editor "):
+			// /editor writes the message in $EDITOR, starting from any
+			// text after it.
+			input, err = editMessage(strings.TrimSpace(strings.TrimPrefix(input, "/editor")))
+			if err != nil {
+				fmt.Printf("error: %v\n\n", err)
+				continue
+			}
+			if input == "" {
+				fmt.Println("Empty message, nothing sent.")
+				fmt.Println()
+				continue
+			}
+			fmt.Println(input)
+		case input == "/retry" || strings.HasPrefix(input, "/retry "):
+			var ok bool
+			if input, ok = retryLast(strings.Fields(input)[1:], cs); !ok {
+				continue
+			}
+			resend = true
+		case input == "/edit":
+			var ok bool
+			if input, ok = editLast(cs, rl); !ok {
+				continue
+			}
+		case strings.HasPrefix(input, "/") && !strings.Contains(input, "\n"):
+			// Handle slash commands
 			if handleCommand(input, cs) {
 				continue
 			}
 		}
 
+		// Create a per-request context so Ctrl+C only cancels this request
+		reqCtx, cancel := context.WithCancel(tools.WithSession(context.Background(), sess.ID))
+		reqCancel = cancel
+
+		// @path and @url references bring in what they point to.
+		message := input
+		if !resend {
+			var refs []mention.Reference
+			message, refs, err = mention.Expand(reqCtx, input, mention.Options{})
+			if err != nil {
+				cancel()
+				reqCancel = nil
+				fmt.Printf("\033[31merror: %s\033[0m\n\n", err)
+				continue
+			}
+			for _, ref := range refs {
+				fmt.Printf("  \033[90m+ %s\033[0m\n", describeReference(ref))
+			}
+			if message != input {
+				cs.typed[message] = input
+			}
+		}
+
 		// Auto-generate title from first user message
-		if firstMessage {
+		titled := firstMessage && sess.Title == ""
+		if titled {
 			sess.Title = generateTitle(input)
 			store.UpdateSession(ctx, sess)
 			firstMessage = false
 		}
 
-		// Create a per-request context so Ctrl+C only cancels this request
-		reqCtx, cancel := context.WithCancel(context.Background())
-		reqCancel = cancel
-
 		// Run the agent with streaming output
 		fmt.Printf("\n\033[32mforge>\033[0m ")
-		_, err = a.RunStreaming(reqCtx, input)
+		turnStart := time.Now()
+		notifier.begin()
+		reply, err := a.RunStreaming(reqCtx, message)
 		wasInterrupted := reqCtx.Err() != nil
+		if !wasInterrupted {
+			notifier.finished(sess.Title, reply, err)
+		}
 		cancel()
 		reqCancel = nil
+		cs.flushMarkdown()
 
 		// Auto-save after each turn
-		if saveErr := store.SaveMessages(ctx, sess.ID, a.History()); saveErr != nil {
+		if saveErr := storage.SaveHistory(ctx, store, sess.ID, a); saveErr != nil {
 			fmt.Fprintf(os.Stderr, "warning: failed to save session: %v\n", saveErr)
 		}
 
@@ -300,10 +361,47 @@ func runChat(cmd *cobra.Command, args []string) error {
 			continue
 		}
 
-		fmt.Printf("\n\n")
+		if titled {
+			retitle(a, store, sess.ID, sess.Title, titles)
+		}
+
+		if cfg.Chat.Footer {
+			fmt.Println()
+			printTurnFooter(cs, time.Since(turnStart))
+			fmt.Println()
+		} else {
+			fmt.Printf("\n\n")
+		}
 	}
 }
 
+// retitle has the utility model write a title after the first exchange, in
+// the background. It replaces the stopgap cut from the first message unless
+// the title has changed meanwhile, and is sent on titles for the chat loop.
+func retitle(a *agent.Agent, store storage.Store, sessionID, stopgap string, titles chan<- string) {
+	client := a.UtilityLLM()
+	if client == nil {
+		return
+	}
+	messages := slices.Clone(a.History())
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		title, err := agent.GenerateTitle(ctx, client, messages)
+		if err != nil {
+			return
+		}
+		sess, err := store.GetSession(ctx, sessionID)
+		if err != nil || sess.Title != stopgap {
+			return
+		}
+		sess.Title = title
+		if store.UpdateSession(ctx, sess) == nil {
+			titles <- title
+		}
+	}()
+}
+
 func generateTitle(firstMessage string) string {
 	t := strings.TrimSpace(firstMessage)
 	if len(t) > 80 {
@@ -320,6 +418,53 @@ type chatState struct {
 	model        string
 	sess         *storage.Session
 	store        storage.Store
+	registry     *tools.Registry
+	markdown     *markdownStream   // nil when stdout isn't a terminal
+	raw          bool              // print replies as they are, set by /raw
+	typed        map[string]string // messages as typed, by the text their references expanded to, for /edit
+}
+
+func printToolCall(name string, args map[string]any) {
+	fmt.Printf("\n  \033[33m⚡ Tool: %s\033[0m\n", agent.FormatToolCall(name, args))
+}
+
+// printToolOutput prints what a program run with code_run printed as it
+// went; the result follows.
+func printToolOutput(output string) {
+	for _, line := range strings.Split(strings.TrimSuffix(output, "\n"), "\n") {
+		fmt.Printf("  \033[90m┊ %s\033[0m\n", line)
+	}
+}
+
+// printToolResult prints the start of a tool's result.
+func printToolResult(result string) {
+	lines := strings.Split(strings.TrimSpace(result), "\n")
+	preview := lines
+	if len(preview) > 8 {
+		preview = preview[:8]
+	}
+	// shell_exec labels its streams; show stderr in red.
+	color := "90"
+	for _, line := range preview {
+		switch line {
+		case "[stdout]":
+			color = "90"
+		case "[stderr]":
+			color = "31"
+		}
+		fmt.Printf("  \033[%sm│ %s\033[0m\n", color, line)
+	}
+	if len(lines) > 8 {
+		fmt.Printf("  \033[90m│ ... (%d more lines)\033[0m\n", len(lines)-8)
+	}
+	fmt.Println()
+}
+
+// flushMarkdown prints what is left of a reply being rendered.
+func (cs *chatState) flushMarkdown() {
+	if cs.markdown != nil {
+		cs.markdown.Flush()
+	}
 }
 
 func handleCommand(input string, cs *chatState) bool {
@@ -335,8 +480,41 @@ func handleCommand(input string, cs *chatState) bool {
 	case "/history":
 		fmt.Println(cs.agent.HistoryJSON())
 		fmt.Println()
+	case "/undo":
+		message, ok := cs.agent.Undo()
+		if !ok {
+			fmt.Println("Nothing to undo.")
+			fmt.Println()
+			break
+		}
+		if err := storage.SaveHistory(context.Background(), cs.store, cs.sess.ID, cs.agent); err != nil {
+			fmt.Fprintf(os.Stderr, "warning: failed to save session: %v\n", err)
+		}
+		fmt.Printf("Removed your last message and its reply: %s\n\n", preview(message))
+	case "/compact":
+		handleCompactCommand(cs)
+	case "/tokens":
+		handleTokensCommand(cs)
+	case "/context":
+		handleContextCommand(cs)
+	case "/raw":
+		switch {
+		case cs.markdown == nil:
+			fmt.Println("Replies are printed raw: output isn't a terminal.")
+		case cs.raw:
+			cs.raw = false
+			fmt.Println("Rendering replies as markdown.")
+		default:
+			cs.raw = true
+			fmt.Println("Printing replies raw.")
+		}
+		fmt.Println()
 	case "/model":
 		handleModelCommand(fields[1:], cs)
+	case "/tools":
+		handleToolsCommand(fields[1:], cs)
+	case "/title":
+		handleTitleCommand(strings.TrimSpace(strings.TrimPrefix(input, fields[0])), cs)
 	case "/help":
 		fmt.Println("Commands:")
 		fmt.Println("  /help              - Show this help")
@@ -344,8 +522,20 @@ func handleCommand(input string, cs *chatState) bool {
 		fmt.Println("  /model <provider>  - Switch provider (e.g. /model gemini)")
 		fmt.Println("  /model <model>     - Switch model (e.g. /model qwen3:8b)")
 		fmt.Println("  /model <p>/<model> - Switch provider and model (e.g. /model claude/claude-sonnet-4-5-20250929)")
+		fmt.Println("  /title             - Show the session title")
+		fmt.Println("  /title <title>     - Rename the session")
+		fmt.Println("  /tools             - List registered tool servers")
+		fmt.Println("  /tools add <name> <binary> - Start a tool server and save it to config")
+		fmt.Println("  /undo              - Remove your last message and its reply")
+		fmt.Println("  /retry [model]     - Send your last message again, on another model if given")
+		fmt.Println("  /edit              - Revise your last message and send it again")
 		fmt.Println("  /reset             - Clear conversation history")
 		fmt.Println("  /history           - Show raw conversation history (JSON)")
+		fmt.Println("  /compact           - Summarize the conversation so far, keeping your latest exchange")
+		fmt.Println("  /tokens            - Show context usage against the budget")
+		fmt.Println("  /context           - List what is in the context window")
+		fmt.Println("  /raw               - Toggle markdown rendering of replies")
+		fmt.Println("  /editor [text]     - Write a message in $EDITOR, starting from text")
 		fmt.Println("  /quit              - Exit")
 		fmt.Println()
 	default:
@@ -354,33 +544,41 @@ func handleCommand(input string, cs *chatState) bool {
 	return true
 }
 
-func handleModelCommand(args []string, cs *chatState) {
-	// No args: show current model
-	if len(args) == 0 {
-		fmt.Printf("Provider: %s | Model: %s\n\n", cs.providerName, cs.model)
+func handleTitleCommand(title string, cs *chatState) {
+	if title == "" {
+		if cs.sess.Title == "" {
+			fmt.Println("Untitled session")
+		} else {
+			fmt.Printf("Title: %s\n", cs.sess.Title)
+		}
+		fmt.Println()
 		return
 	}
 
-	target := args[0]
-	newProvider := cs.providerName
-	newModel := target
+	cs.sess.Title = title
+	if err := cs.store.UpdateSession(context.Background(), cs.sess); err != nil {
+		fmt.Printf("Error: %v\n\n", err)
+		return
+	}
+	fmt.Printf("Renamed session to %q\n\n", title)
+}
 
-	// Check for provider/model syntax
-	if idx := strings.Index(target, "/"); idx > 0 {
-		newProvider = target[:idx]
-		newModel = target[idx+1:]
-	} else if p, ok := cs.cfg.Providers[target]; ok {
-		// Bare provider name — switch to its default model
-		newProvider = target
-		newModel = p.Models["default"]
+// handleModelCommand shows or switches the model, reporting whether it
+// switched.
+func handleModelCommand(args []string, cs *chatState) bool {
+	// No args: show current model
+	if len(args) == 0 {
+		fmt.Printf("Provider: %s | Model: %s\n\n", cs.providerName, cs.model)
+		return false
 	}
 
-	// Look up provider config
-	providerCfg, err := cs.cfg.Provider(newProvider)
+	// provider/model, a bare provider for its default model, or a model
+	newProvider, newModel, err := cs.cfg.ResolveModel(cs.providerName, args[0])
 	if err != nil {
 		fmt.Printf("Error: %v\n\n", err)
-		return
+		return false
 	}
+	providerCfg, _ := cs.cfg.Provider(newProvider)
 
 	// Create new client and swap
 	newClient := llm.NewClient(providerCfg.BaseURL, providerCfg.APIKey, newModel)
@@ -395,6 +593,113 @@ func handleModelCommand(args []string, cs *chatState) {
 	cs.store.UpdateSession(ctx, cs.sess)
 
 	fmt.Printf("Switched to %s/%s\n\n", newProvider, newModel)
+	return true
+}
+
+// lastUserMessage returns the last message the user sent.
+func lastUserMessage(a *agent.Agent) (string, bool) {
+	history := a.History()
+	for i := len(history) - 1; i > 0; i-- {
+		if history[i].Role == llm.RoleUser {
+			return history[i].Content, true
+		}
+	}
+	return "", false
+}
+
+// preview shortens a message to the start of its first line, leaving out
+// any references it brought in.
+func preview(message string) string {
+	line, _, _ := strings.Cut(message, "\n")
+	return truncate(line, 60)
+}
+
+// retryLast takes back the last exchange for /retry, after switching to
+// the model in args, if any, and returns the message to send again.
+func retryLast(args []string, cs *chatState) (string, bool) {
+	if _, ok := lastUserMessage(cs.agent); !ok {
+		fmt.Println("Nothing to retry.")
+		fmt.Println()
+		return "", false
+	}
+	if len(args) > 0 && !handleModelCommand(args, cs) {
+		return "", false
+	}
+	message, _ := cs.agent.Undo()
+	fmt.Printf("\033[90mRetrying: %s\033[0m\n", preview(message))
+	return message, true
+}
+
+// editLast lets the user revise their last message for /edit, on the
+// input line or, if it spans lines, in $EDITOR, and takes back the last
+// exchange once they have. A message is edited as it was typed when it is
+// known, with its @ references unexpanded.
+func editLast(cs *chatState, rl *readline.Instance) (string, bool) {
+	text, ok := lastUserMessage(cs.agent)
+	if !ok {
+		fmt.Println("Nothing to edit.")
+		fmt.Println()
+		return "", false
+	}
+	if typed, ok := cs.typed[text]; ok {
+		text = typed
+	}
+
+	var edited string
+	var err error
+	if strings.Contains(text, "\n") {
+		edited, err = editMessage(text)
+	} else {
+		edited, err = rl.ReadlineWithDefault(text)
+		edited = strings.TrimSpace(edited)
+	}
+	switch {
+	case err == readline.ErrInterrupt || err == io.EOF:
+		fmt.Println("Edit cancelled.")
+	case err != nil:
+		fmt.Printf("error: %v\n", err)
+	case edited == "":
+		fmt.Println("Empty message, nothing sent.")
+	default:
+		cs.agent.Undo()
+		return edited, true
+	}
+	fmt.Println()
+	return "", false
+}
+
+func handleToolsCommand(args []string, cs *chatState) {
+	// No args: list servers and their tools
+	if len(args) == 0 {
+		names := cs.registry.ServerNames()
+		if len(names) == 0 {
+			fmt.Printf("No tool servers registered (using built-in tool pack)\n\n")
+			return
+		}
+		for _, name := range names {
+			fmt.Printf("  %s: %s\n", name, strings.Join(cs.registry.ServerTools(name), ", "))
+		}
+		fmt.Println()
+		return
+	}
+
+	if strings.ToLower(args[0]) != "add" || len(args) != 3 {
+		fmt.Printf("Usage: /tools add <name> <binary>\n\n")
+		return
+	}
+
+	name, binary := args[1], args[2]
+	toolCfg := tools.ToolServerConfig{Binary: binary, Enabled: true}
+	if err := cs.registry.Register(name, toolCfg); err != nil {
+		fmt.Printf("Error: %v\n\n", err)
+		return
+	}
+	if err := cs.cfg.SaveToolServer(name, toolCfg); err != nil {
+		fmt.Printf("Warning: %s registered but not saved to config: %v\n", name, err)
+	}
+
+	cs.agent.RefreshTools()
+	fmt.Printf("Added %s: %s\n\n", name, strings.Join(cs.registry.ServerTools(name), ", "))
 }
 
 // pickOllamaModel queries Ollama for available models and lets the user choose.
@@ -0,0 +1,260 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"github.com/chzyer/readline"
+	"github.com/spf13/cobra"
+
+	"github.com/michaelbrown/forge/internal/config"
+	"github.com/michaelbrown/forge/internal/llm"
+)
+
+var (
+	commitYes    bool
+	commitDryRun bool
+)
+
+var commitCmd = &cobra.Command{
+	Use:   "commit [hint]",
+	Short: "Write a commit message for the staged changes and commit them",
+	Long: `Write a conventional-commit message for the changes staged with git add,
+using the provider's utility model if it has one and its default model
+otherwise, then show it and ask what to do: commit with it, edit it first
+in $VISUAL or $EDITOR, write another, or quit without committing. Words
+after the command are passed to the model as a hint about the change.
+
+The project's FORGE.md, if there is one, is given to the model too, so
+a project can set out its own commit conventions there.
+
+Examples:
+  forge commit
+  forge commit "fixes the retry loop reported in #42"
+  forge commit --dry-run > msg.txt
+  forge commit --yes`,
+	RunE:          runCommit,
+	SilenceUsage:  true,
+	SilenceErrors: true,
+}
+
+func init() {
+	rootCmd.AddCommand(commitCmd)
+
+	commitCmd.Flags().BoolVarP(&commitYes, "yes", "y", false, "Commit with the message without asking")
+	commitCmd.Flags().BoolVar(&commitDryRun, "dry-run", false, "Print the message without committing")
+}
+
+// commitDiffLimit is the most of the staged diff, in bytes, sent to the
+// model. The stat of every changed file is sent regardless.
+const commitDiffLimit = 40_000
+
+const commitSystemPrompt = `You write git commit messages in the Conventional Commits format.
+
+The first line is type(scope): summary, where type is one of feat, fix,
+docs, style, refactor, perf, test, build, ci, or chore; the scope, which
+may be left out, is the part of the code changed; and the summary is in
+the imperative mood, lowercase, without a trailing period, and at most 72
+characters long in all. For a change that breaks compatibility, put ! before
+the colon.
+
+If the change needs explaining, add a blank line and a body, wrapped at 72
+columns, saying what changed and why, not how. Leave the body out for
+small, self-explanatory changes.
+
+Reply with the commit message alone: no preamble, quotes, or code fences.`
+
+func runCommit(cmd *cobra.Command, args []string) error {
+	if commitYes && commitDryRun {
+		return &exitError{exitUsage, errors.New("--yes and --dry-run can't be used together")}
+	}
+	cfg, err := config.Load()
+	if err != nil {
+		return &exitError{exitUsage, fmt.Errorf("loading config: %w", err)}
+	}
+	setup, err := resolveAgent(cfg, false)
+	if err != nil {
+		return &exitError{exitUsage, err}
+	}
+	model := setup.model
+	if utilityModel := setup.provider.Models["utility"]; utilityModel != "" && modelFlag == "" {
+		model = utilityModel
+	}
+
+	diff, err := git("diff", "--cached", "--no-color", "--no-ext-diff")
+	if err != nil {
+		return &exitError{exitFailed, err}
+	}
+	if strings.TrimSpace(diff) == "" {
+		return &exitError{exitFailed, errors.New("nothing is staged; stage changes with git add first")}
+	}
+	stat, err := git("diff", "--cached", "--no-color", "--stat")
+	if err != nil {
+		return &exitError{exitFailed, err}
+	}
+	messages := commitPrompt(setup.projectContext, stat, diff, strings.Join(args, " "))
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	client := llm.NewClient(setup.provider.BaseURL, setup.provider.APIKey, model)
+	generate := func() (string, error) {
+		fmt.Fprintf(os.Stderr, "Writing a commit message with %s...\n", model)
+		resp, err := client.ChatCompletion(ctx, messages, nil)
+		if err != nil {
+			if ctx.Err() != nil {
+				return "", &exitError{exitInterrupted, errors.New("interrupted")}
+			}
+			return "", &exitError{exitFailed, err}
+		}
+		message := cleanCommitMessage(resp.Message.Content)
+		if message == "" {
+			return "", &exitError{exitFailed, errors.New("the model replied with an empty message")}
+		}
+		return message, nil
+	}
+	message, err := generate()
+	if err != nil {
+		return err
+	}
+
+	if commitDryRun {
+		fmt.Println(message)
+		return nil
+	}
+	if commitYes {
+		return gitCommit(message)
+	}
+	if !readline.IsTerminal(int(os.Stdin.Fd())) {
+		fmt.Println(message)
+		return &exitError{exitUsage, errors.New("not committing: stdin isn't a terminal (use --yes to commit)")}
+	}
+
+	in := bufio.NewReader(os.Stdin)
+	for {
+		printCommitMessage(message)
+		fmt.Print("Commit? [Y]es, [e]dit, [r]ewrite, [n]o: ")
+		answer, err := in.ReadString('\n')
+		if err != nil && answer == "" {
+			// Ctrl-D quits, rather than taking the default.
+			fmt.Println("\nNot committing.")
+			return nil
+		}
+		switch strings.ToLower(strings.TrimSpace(answer)) {
+		case "", "y", "yes":
+			return gitCommit(message)
+		case "e", "edit":
+			edited, err := editMessage(message + "\n")
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "error: %v\n", err)
+				continue
+			}
+			if edited == "" {
+				fmt.Println("Empty message; not committing.")
+				return nil
+			}
+			message = edited
+		case "r", "rewrite":
+			if message, err = generate(); err != nil {
+				return err
+			}
+		case "n", "no", "q", "quit":
+			fmt.Println("Not committing.")
+			return nil
+		}
+	}
+}
+
+// commitPrompt returns the messages asking the model for a commit message
+// for diff, of which stat is the summary, with the project's context and
+// the user's hint, if any.
+func commitPrompt(projectContext, stat, diff, hint string) []llm.Message {
+	system := commitSystemPrompt
+	if projectContext != "" {
+		system += "\n\nThe project's own notes, which may set out its commit conventions:\n\n" + projectContext
+	}
+	var b strings.Builder
+	if hint != "" {
+		fmt.Fprintf(&b, "About this change: %s\n\n", hint)
+	}
+	fmt.Fprintf(&b, "Files changed:\n%s\n", stat)
+	if len(diff) > commitDiffLimit {
+		diff = diff[:commitDiffLimit] + "\n[diff truncated]\n"
+	}
+	fmt.Fprintf(&b, "Staged diff:\n%s", diff)
+	return []llm.Message{llm.SystemMessage(system), llm.UserMessage(b.String())}
+}
+
+// cleanCommitMessage strips what models wrap a message in despite being
+// told not to: a "Here is the commit message:" line, code fences, and
+// quotes.
+func cleanCommitMessage(s string) string {
+	s = strings.TrimSpace(s)
+	if first, rest, ok := strings.Cut(s, "\n"); ok && strings.HasSuffix(strings.TrimSpace(first), ":") {
+		if lower := strings.ToLower(first); strings.HasPrefix(lower, "here is") || strings.HasPrefix(lower, "here's") {
+			s = strings.TrimSpace(rest)
+		}
+	}
+	if strings.HasPrefix(s, "```") && strings.HasSuffix(s, "```") && len(s) >= 6 {
+		s = strings.TrimSuffix(s, "```")
+		// Drop the opening fence along with any language after it.
+		if i := strings.Index(s, "\n"); i >= 0 {
+			s = s[i+1:]
+		} else {
+			s = ""
+		}
+		s = strings.TrimSpace(s)
+	}
+	if len(s) >= 2 && (s[0] == '"' && s[len(s)-1] == '"' || s[0] == '\'' && s[len(s)-1] == '\'') {
+		s = strings.TrimSpace(s[1 : len(s)-1])
+	}
+	return s
+}
+
+// printCommitMessage shows a proposed commit message, set off by a bar.
+func printCommitMessage(message string) {
+	fmt.Println()
+	for _, line := range strings.Split(message, "\n") {
+		fmt.Printf("  \033[36m│\033[0m %s\n", line)
+	}
+	fmt.Println()
+}
+
+// git runs git with args in the current directory and returns its output.
+// Errors carry what git wrote to stderr.
+func git(args ...string) (string, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command("git", args...)
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("git %s: %s", args[0], msg)
+		}
+		return "", fmt.Errorf("git %s: %w", args[0], err)
+	}
+	return stdout.String(), nil
+}
+
+// gitCommit commits the staged changes with message, passing git's output
+// through.
+func gitCommit(message string) error {
+	cmd := exec.Command("git", "commit", "-F", "-")
+	cmd.Stdin = strings.NewReader(message + "\n")
+	cmd.Stdout, cmd.Stderr = os.Stdout, os.Stderr
+	if err := cmd.Run(); err != nil {
+		var exit *exec.ExitError
+		if errors.As(err, &exit) {
+			return &exitError{code: exitFailed}
+		}
+		return &exitError{exitFailed, fmt.Errorf("running git commit: %w", err)}
+	}
+	return nil
+}
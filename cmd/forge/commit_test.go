@@ -0,0 +1,107 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/michaelbrown/forge/internal/llm"
+)
+
+func TestCleanCommitMessage(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"plain", "feat: add x", "feat: add x"},
+		{"whitespace", "\n  fix(cli): handle y  \n\n", "fix(cli): handle y"},
+		{"with body", "feat: add x\n\nBecause y.", "feat: add x\n\nBecause y."},
+		{"fenced", "```\nfeat: add x\n```", "feat: add x"},
+		{"fenced with language", "```text\nfeat: add x\n\nBody.\n```", "feat: add x\n\nBody."},
+		{"fence on one line", "``````", ""},
+		{"double quotes", `"fix: handle y"`, "fix: handle y"},
+		{"single quotes", "'fix: handle y'", "fix: handle y"},
+		{"here is", "Here is the commit message:\n\nfeat: add x", "feat: add x"},
+		{"here's, fenced", "Here's a commit message for these changes:\n```\nfeat: add x\n```", "feat: add x"},
+		{"here is, quoted", "here is the message:\n\"fix: handle y\"", "fix: handle y"},
+		{"here is without a colon", "Here is feat: add x\nmore", "Here is feat: add x\nmore"},
+		{"colon in the subject", "docs: note this:\n\nBody.", "docs: note this:\n\nBody."},
+		{"empty", "  \n", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := cleanCommitMessage(tt.in); got != tt.want {
+				t.Errorf("cleanCommitMessage(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCommitPrompt(t *testing.T) {
+	long := strings.Repeat("+x\n", commitDiffLimit)
+	tests := []struct {
+		name                 string
+		projectContext, hint string
+		diff                 string
+		want, notWant        []string // in the user message
+		wantSystem           []string
+	}{
+		{
+			name:       "small diff",
+			diff:       "+x\n",
+			want:       []string{"Files changed:\n a.go | 1 +\n", "Staged diff:\n+x\n"},
+			notWant:    []string{"About this change", "[diff truncated]"},
+			wantSystem: []string{"Conventional Commits"},
+		},
+		{
+			name:    "empty diff",
+			want:    []string{"Files changed:\n a.go | 1 +\n", "Staged diff:\n"},
+			notWant: []string{"[diff truncated]"},
+		},
+		{
+			name: "truncated diff",
+			diff: long,
+			want: []string{"Staged diff:\n" + long[:commitDiffLimit] + "\n[diff truncated]\n"},
+		},
+		{
+			name:           "hint and project context",
+			projectContext: "Use the ticket number as the scope.",
+			hint:           "fixes the login bug",
+			diff:           "+x\n",
+			want:           []string{"About this change: fixes the login bug\n\n"},
+			wantSystem:     []string{"The project's own notes", "Use the ticket number as the scope."},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			messages := commitPrompt(tt.projectContext, " a.go | 1 +\n", tt.diff, tt.hint)
+			if len(messages) != 2 || messages[0].Role != llm.RoleSystem || messages[1].Role != llm.RoleUser {
+				t.Fatalf("messages = %+v, want a system and a user message", messages)
+			}
+			system, user := messages[0].Content, messages[1].Content
+			for _, want := range tt.want {
+				if !strings.Contains(user, want) {
+					t.Errorf("user message missing %q", want)
+				}
+			}
+			for _, notWant := range tt.notWant {
+				if strings.Contains(user, notWant) {
+					t.Errorf("user message has %q", notWant)
+				}
+			}
+			for _, want := range tt.wantSystem {
+				if !strings.Contains(system, want) {
+					t.Errorf("system message missing %q", want)
+				}
+			}
+			if tt.projectContext == "" && strings.Contains(system, "project's own notes") {
+				t.Error("system message mentions project notes without any")
+			}
+			if len(user) > commitDiffLimit+1000 {
+				t.Errorf("user message is %d bytes", len(user))
+			}
+		})
+	}
+}
@@ -0,0 +1,483 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+	"gopkg.in/yaml.v3"
+
+	"github.com/michaelbrown/forge/internal/config"
+	"github.com/michaelbrown/forge/internal/keyring"
+	"github.com/michaelbrown/forge/internal/llm"
+)
+
+var (
+	configInitPath string
+	configForce    bool
+	configOffline  bool
+	configRedact   bool
+)
+
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Create, check, and show forge's configuration",
+}
+
+var configInitCmd = &cobra.Command{
+	Use:   "init",
+	Short: "Write a new forge.yaml, asking which providers to use",
+	Long: `Write a new config file, ~/.forge/forge.yaml unless --path is given,
+after looking for providers: Ollama at localhost:11434, or $OLLAMA_HOST, and
+hosted providers whose API key variables are set. You choose which to use and
+their default models. Keys found in the environment are written as ${VAR}
+references, so the file doesn't hold them.
+
+When stdin isn't a terminal, every question takes its default.`,
+	Args:         cobra.NoArgs,
+	RunE:         runConfigInit,
+	SilenceUsage: true,
+}
+
+var configValidateCmd = &cobra.Command{
+	Use:   "validate",
+	Short: "Check the config for mistakes and unreachable providers",
+	Long: `Check the config forge would load, and the project's .forge/forge.yaml,
+for keys it doesn't know, which are often misspellings, for settings that keep it from working, such as a
+default provider that isn't configured or an API key variable that isn't
+set, and for tool server binaries that don't exist. Then check that each
+provider answers and accepts its API key, unless --offline is given.
+
+Exits with status 1 if there are errors; warnings alone don't fail.`,
+	Args:          cobra.NoArgs,
+	RunE:          runConfigValidate,
+	SilenceUsage:  true,
+	SilenceErrors: true,
+}
+
+var configShowCmd = &cobra.Command{
+	Use:   "show",
+	Short: "Print the config as forge uses it",
+	Long: `Print the config forge would load as YAML, with the project's
+.forge/forge.yaml laid over it, FORGE_* variables applied, defaults filled
+in, and ${VAR} references expanded. Empty settings are left out. Use
+--redact-secrets to hide API keys, passwords, and tokens, as before
+sharing the output.`,
+	Args:         cobra.NoArgs,
+	RunE:         runConfigShow,
+	SilenceUsage: true,
+}
+
+var configSetKeyCmd = &cobra.Command{
+	Use:   "set-key <provider>",
+	Short: "Keep a provider's API key in the system keyring",
+	Long: `Store a provider's API key in the system keyring, the login keychain on
+macOS or the Secret Service (GNOME Keyring, KWallet) on Linux, and set the
+provider's api_key in forge.yaml to "keyring", so forge reads it from there
+rather than from the file or an environment variable.
+
+The key is asked for without echoing it, or read from stdin when stdin isn't
+a terminal.`,
+	Example: `  forge config set-key claude
+  pass show anthropic | forge config set-key claude`,
+	Args:         cobra.ExactArgs(1),
+	RunE:         runConfigSetKey,
+	SilenceUsage: true,
+}
+
+var configTrustCmd = &cobra.Command{
+	Use:   "trust",
+	Short: "Let the project's .forge/forge.yaml set anything",
+	Long: `Trust the .forge/forge.yaml of the project forge is run in, as it is now.
+Until a project is trusted, its forge.yaml may only set models and agent and
+chat settings; one that adds tool servers, changes a provider's base_url or
+api_key, or sets server, storage, or log settings isn't loaded. Read the file
+first: trusting it lets it run programs and send your API keys where it says.
+
+Trust is recorded in ~/.forge/trusted_projects.yaml with the file's hash, so
+once the file changes it needs trusting again.`,
+	Args:         cobra.NoArgs,
+	RunE:         runConfigTrust,
+	SilenceUsage: true,
+}
+
+func init() {
+	rootCmd.AddCommand(configCmd)
+	configCmd.AddCommand(configInitCmd, configValidateCmd, configShowCmd, configSetKeyCmd, configTrustCmd)
+
+	configInitCmd.Flags().StringVar(&configInitPath, "path", "", "Where to write the config (default ~/.forge/forge.yaml)")
+	configInitCmd.Flags().BoolVar(&configForce, "force", false, "Replace the file if it exists")
+	configValidateCmd.Flags().BoolVar(&configOffline, "offline", false, "Skip checking that providers answer")
+	configShowCmd.Flags().BoolVar(&configRedact, "redact-secrets", false, "Hide API keys, passwords, and tokens")
+}
+
+// hostedProviders are the providers forge config init offers besides
+// Ollama, each found by the variable holding its API key.
+var hostedProviders = []struct {
+	name, label, baseURL, keyEnv, model string
+}{
+	{"claude", "Anthropic Claude", "https://api.anthropic.com/v1/", "ANTHROPIC_API_KEY", "claude-sonnet-4-5-20250929"},
+	{"gemini", "Google Gemini", "https://generativelanguage.googleapis.com/v1beta/openai/", "GEMINI_API_KEY", "gemini-2.5-flash"},
+	{"openai", "OpenAI", "https://api.openai.com/v1/", "OPENAI_API_KEY", "gpt-4.1"},
+}
+
+// providerProbeTimeout bounds each check that a provider answers.
+const providerProbeTimeout = 5 * time.Second
+
+func runConfigInit(cmd *cobra.Command, args []string) error {
+	path := configInitPath
+	if path == "" {
+		path = config.DefaultPath()
+	}
+	if _, err := os.Stat(path); err == nil && !configForce {
+		return fmt.Errorf("%s already exists (use --force to replace it)", path)
+	}
+	in := bufio.NewReader(os.Stdin)
+	var chosen []config.NewProvider
+
+	fmt.Println("Looking for providers...")
+	ollamaURL := ollamaBaseURL()
+	ctx, cancel := context.WithTimeout(context.Background(), providerProbeTimeout)
+	models, err := llm.NewClient(ollamaURL, "ollama", "").ListModels(ctx)
+	cancel()
+	if err != nil {
+		fmt.Printf("  - Ollama isn't running at %s\n", ollamaURL)
+	} else {
+		fmt.Printf("  ✓ Ollama at %s has %d models\n", ollamaURL, len(models))
+	}
+	if promptYesNo(in, "Use Ollama?", err == nil && len(models) > 0) {
+		model := "qwen3:14b"
+		if len(models) > 0 {
+			for i, m := range models {
+				fmt.Printf("    %d) %s\n", i+1, m.Name)
+			}
+			answer := promptLine(in, "  Default model (number or name)", "1")
+			model = answer
+			if n, err := strconv.Atoi(answer); err == nil && n >= 1 && n <= len(models) {
+				model = models[n-1].Name
+			}
+		} else {
+			model = promptLine(in, "  Default model", model)
+		}
+		chosen = append(chosen, config.NewProvider{Name: "ollama", BaseURL: ollamaURL, APIKey: "ollama", Model: model})
+	}
+
+	for _, p := range hostedProviders {
+		key := os.Getenv(p.keyEnv)
+		switch {
+		case key == "":
+			fmt.Printf("  - %s: $%s isn't set\n", p.label, p.keyEnv)
+		default:
+			ctx, cancel := context.WithTimeout(context.Background(), providerProbeTimeout)
+			err := llm.NewClient(p.baseURL, key, "").Ping(ctx)
+			cancel()
+			if err != nil {
+				fmt.Printf("  ! %s: $%s is set, but: %s\n", p.label, p.keyEnv, describeProviderError(err))
+			} else {
+				fmt.Printf("  ✓ %s: $%s is set and works\n", p.label, p.keyEnv)
+			}
+		}
+		if !promptYesNo(in, "Use "+p.label+"?", key != "") {
+			continue
+		}
+		apiKey := "${" + p.keyEnv + "}"
+		if key == "" {
+			if entered := promptLine(in, "  API key (blank to read $"+p.keyEnv+" when forge runs)", ""); entered != "" {
+				apiKey = entered
+			}
+		}
+		model := promptLine(in, "  Default model", p.model)
+		chosen = append(chosen, config.NewProvider{Name: p.name, BaseURL: p.baseURL, APIKey: apiKey, Model: model})
+	}
+
+	if len(chosen) == 0 {
+		return errors.New("no providers chosen, so no config written")
+	}
+	defaultProvider := chosen[0].Name
+	if len(chosen) > 1 {
+		var names []string
+		for _, p := range chosen {
+			names = append(names, p.Name)
+		}
+		for {
+			defaultProvider = promptLine(in, "Default provider ("+strings.Join(names, ", ")+")", names[0])
+			if slices.Contains(names, defaultProvider) {
+				break
+			}
+		}
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	// The file may hold API keys typed in above.
+	if err := os.WriteFile(path, config.NewFile(chosen, defaultProvider), 0o600); err != nil {
+		return fmt.Errorf("writing config: %w", err)
+	}
+	fmt.Printf("\nWrote %s. Run forge config validate after editing it.\n", path)
+	return nil
+}
+
+// ollamaBaseURL returns the OpenAI-compatible endpoint of the local Ollama,
+// or of the one $OLLAMA_HOST names.
+func ollamaBaseURL() string {
+	host := os.Getenv("OLLAMA_HOST")
+	if host == "" {
+		host = "localhost:11434"
+	}
+	if !strings.Contains(host, "://") {
+		host = "http://" + host
+	}
+	return strings.TrimRight(host, "/") + "/v1/"
+}
+
+// promptLine asks question and returns the answer, or def when the answer
+// is blank or stdin has ended.
+func promptLine(in *bufio.Reader, question, def string) string {
+	if def != "" {
+		fmt.Printf("%s [%s]: ", question, def)
+	} else {
+		fmt.Printf("%s: ", question)
+	}
+	answer, err := in.ReadString('\n')
+	if err == io.EOF && answer == "" {
+		fmt.Println()
+	}
+	if answer = strings.TrimSpace(answer); answer == "" {
+		return def
+	}
+	return answer
+}
+
+// promptYesNo asks a yes-or-no question, with def as the answer to a blank
+// line.
+func promptYesNo(in *bufio.Reader, question string, def bool) bool {
+	hint := "y/N"
+	if def {
+		hint = "Y/n"
+	}
+	for {
+		switch strings.ToLower(promptLine(in, question+" ["+hint+"]", "")) {
+		case "":
+			return def
+		case "y", "yes":
+			return true
+		case "n", "no":
+			return false
+		}
+	}
+}
+
+// describeProviderError says why a provider check failed, in terms of what
+// to fix.
+func describeProviderError(err error) string {
+	var llmErr *llm.LLMError
+	if errors.As(err, &llmErr) {
+		switch llmErr.Kind {
+		case llm.ErrKindAuth:
+			return "the API key was refused"
+		case llm.ErrKindConnRefused, llm.ErrKindTimeout:
+			return "no answer (is it running, and is base_url right?)"
+		case llm.ErrKindModelNotFound:
+			return "no models endpoint there (is base_url right?)"
+		}
+	}
+	var dnsErr *net.DNSError
+	switch {
+	case errors.As(err, &dnsErr):
+		return fmt.Sprintf("can't look up %s (is base_url right?)", dnsErr.Name)
+	case errors.Is(err, context.DeadlineExceeded):
+		return "no answer (is it running, and is base_url right?)"
+	}
+	return err.Error()
+}
+
+func runConfigValidate(cmd *cobra.Command, args []string) error {
+	cfg, err := config.Load()
+	if err != nil {
+		fmt.Printf("  ✗ %v\n", err)
+		return &exitError{code: exitFailed}
+	}
+	var problems []config.Problem
+	for _, path := range cfg.Files() {
+		fmt.Printf("Checking %s\n", path)
+		unknown, err := config.UnknownKeys(path)
+		if err != nil {
+			return &exitError{exitFailed, err}
+		}
+		problems = append(problems, unknown...)
+	}
+	if env := cfg.EnvOverrides(); len(env) > 0 {
+		fmt.Printf("Overridden by %s\n", strings.Join(env, ", "))
+	}
+	problems = append(problems, cfg.Check()...)
+
+	var errs, warnings int
+	for _, p := range problems {
+		if p.Warning {
+			warnings++
+			fmt.Printf("  ! %s\n", p)
+		} else {
+			errs++
+			fmt.Printf("  ✗ %s\n", p)
+		}
+	}
+
+	if !configOffline {
+		for _, r := range probeProviders(cfg, problems) {
+			if r.err != nil {
+				errs++
+				fmt.Printf("  ✗ providers.%s: %s\n", r.name, describeProviderError(r.err))
+			} else {
+				fmt.Printf("  ✓ providers.%s answers\n", r.name)
+			}
+		}
+	}
+
+	fmt.Println()
+	switch {
+	case errs > 0:
+		fmt.Printf("%s, %s.\n", plural(errs, "error"), plural(warnings, "warning"))
+		return &exitError{code: exitFailed}
+	case warnings > 0:
+		fmt.Printf("No errors, %s.\n", plural(warnings, "warning"))
+	default:
+		fmt.Println("No problems found.")
+	}
+	return nil
+}
+
+// providerProbe is whether a provider answered.
+type providerProbe struct {
+	name string
+	err  error
+}
+
+// probeProviders checks at once that each provider answers and accepts its
+// API key, skipping those already found to have a bad base_url or missing
+// key, and returns the results in name order.
+func probeProviders(cfg *config.Config, problems []config.Problem) []providerProbe {
+	var names []string
+	for name := range cfg.Providers {
+		skip := slices.ContainsFunc(problems, func(p config.Problem) bool {
+			return p.Key == "providers."+name+".base_url" || p.Key == "providers."+name+".api_key"
+		})
+		if !skip {
+			names = append(names, name)
+		}
+	}
+	slices.Sort(names)
+
+	results := make([]providerProbe, len(names))
+	var wg sync.WaitGroup
+	for i, name := range names {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			p := cfg.Providers[name]
+			ctx, cancel := context.WithTimeout(context.Background(), providerProbeTimeout)
+			defer cancel()
+			results[i] = providerProbe{name: name, err: llm.NewClient(p.BaseURL, p.APIKey, "").Ping(ctx)}
+		}()
+	}
+	wg.Wait()
+	return results
+}
+
+// plural renders a count of things, as in "1 error" or "2 warnings".
+func plural(n int, thing string) string {
+	if n == 1 {
+		return "1 " + thing
+	}
+	return fmt.Sprintf("%d %ss", n, thing)
+}
+
+func runConfigShow(cmd *cobra.Command, args []string) error {
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("loading config: %w", err)
+	}
+	for _, path := range cfg.Files() {
+		fmt.Printf("# %s\n", path)
+	}
+	for _, name := range cfg.EnvOverrides() {
+		fmt.Printf("# $%s\n", name)
+	}
+	enc := yaml.NewEncoder(os.Stdout)
+	enc.SetIndent(2)
+	defer enc.Close()
+	return enc.Encode(cfg.Settings(configRedact))
+}
+
+func runConfigSetKey(cmd *cobra.Command, args []string) error {
+	name := args[0]
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("loading config: %w", err)
+	}
+	if _, ok := cfg.Providers[name]; !ok {
+		return fmt.Errorf("provider %q isn't configured (want one of %s)", name, strings.Join(sortedNames(cfg.Providers), ", "))
+	}
+
+	var key string
+	if term.IsTerminal(int(os.Stdin.Fd())) {
+		fmt.Printf("API key for %s: ", name)
+		data, err := term.ReadPassword(int(os.Stdin.Fd()))
+		fmt.Println()
+		if err != nil {
+			return fmt.Errorf("reading the key: %w", err)
+		}
+		key = string(data)
+	} else {
+		data, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			return fmt.Errorf("reading the key: %w", err)
+		}
+		key = string(data)
+	}
+	if key = strings.TrimSpace(key); key == "" {
+		return errors.New("no key given")
+	}
+
+	ctx, cancel := context.WithTimeout(cmd.Context(), time.Minute) // the keyring may ask to be unlocked
+	defer cancel()
+	if err := keyring.Set(ctx, name, key); err != nil {
+		return fmt.Errorf("storing the key: %w", err)
+	}
+	fmt.Printf("Stored the API key for %s in the system keyring.\n", name)
+
+	if cfg.Path() == "" {
+		fmt.Printf("Set providers.%s.api_key to %s to use it.\n", name, config.KeyringKey)
+		return nil
+	}
+	if err := cfg.UseKeyring(name); err != nil {
+		return err
+	}
+	fmt.Printf("Set providers.%s.api_key to %s in %s.\n", name, config.KeyringKey, cfg.Path())
+	return nil
+}
+
+func runConfigTrust(cmd *cobra.Command, args []string) error {
+	project := config.FindProject(".")
+	if project == "" {
+		return errors.New("not in a project: no .forge directory or FORGE.md here or above")
+	}
+	path, err := config.TrustProject(project)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Trusted %s as it is now.\n", path)
+	return nil
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/michaelbrown/forge/internal/agent"
+	"github.com/michaelbrown/forge/internal/llm"
+	"github.com/michaelbrown/forge/internal/storage"
+)
+
+// handleCompactCommand summarizes the conversation so far, for /compact.
+func handleCompactCommand(cs *chatState) {
+	before := cs.agent.ContextUsage().Total()
+	fmt.Println("\033[90mSummarizing the conversation...\033[0m")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+	compacted, err := cs.agent.Compact(ctx)
+	switch {
+	case err != nil:
+		fmt.Printf("Error: %v\n\n", err)
+		return
+	case !compacted:
+		fmt.Println("Nothing to compact before your latest message.")
+		fmt.Println()
+		return
+	}
+	if err := storage.SaveHistory(ctx, cs.store, cs.sess.ID, cs.agent); err != nil {
+		fmt.Fprintf(os.Stderr, "warning: failed to save session: %v\n", err)
+	}
+	after := cs.agent.ContextUsage().Total()
+	fmt.Printf("Compacted %s tokens to %s, keeping your latest exchange.\n\n",
+		formatCount(int64(before)), formatCount(int64(after)))
+}
+
+// handleTokensCommand shows the estimated context usage against the budget,
+// by kind of message, for /tokens.
+func handleTokensCommand(cs *chatState) {
+	u := cs.agent.ContextUsage()
+	fmt.Printf("Context: ~%s of %s tokens (%d%%)\n",
+		formatCount(int64(u.Total())), formatCount(int64(u.Budget)), u.Total()*100/max(u.Budget, 1))
+	for _, row := range []struct {
+		label  string
+		tokens int
+	}{
+		{"system prompt", u.System},
+		{"summary", u.Summary},
+		{"your messages", u.User},
+		{"replies", u.Assistant},
+		{"tool results", u.ToolResults},
+	} {
+		if row.tokens > 0 {
+			fmt.Printf("  %-14s %6s\n", row.label, formatCount(int64(row.tokens)))
+		}
+	}
+	fmt.Println("Past the budget, older messages are summarized before the next message is sent.")
+	fmt.Println()
+}
+
+// printTurnFooter prints a dim line summing up the turn that just ended,
+// which took elapsed: the tokens sent and received over all its model
+// calls, as the provider reported them, the tool calls, the time, the
+// estimated cost, and how full the context is. Figures the provider or
+// pricing list doesn't give are left out.
+func printTurnFooter(cs *chatState, elapsed time.Duration) {
+	var in, out, calls int
+	var cost float64
+	priced := true
+	for _, m := range cs.agent.LastTurn() {
+		calls += len(m.ToolCalls)
+		if m.Role != llm.RoleAssistant || m.Meta == nil {
+			continue
+		}
+		in += m.Meta.PromptTokens
+		out += m.Meta.CompletionTokens
+		model := m.Meta.Model
+		if model == "" {
+			model = cs.model
+		}
+		c, ok := cs.cfg.EstimateCost(model, int64(m.Meta.PromptTokens), int64(m.Meta.CompletionTokens))
+		cost += c
+		priced = priced && ok
+	}
+
+	var parts []string
+	if in+out > 0 {
+		parts = append(parts, formatCount(int64(in))+" in", formatCount(int64(out))+" out")
+	}
+	if calls > 0 {
+		parts = append(parts, plural(calls, "tool call"))
+	}
+	parts = append(parts, formatMs(float64(elapsed.Milliseconds())))
+	if in+out > 0 && priced {
+		if cost < 0.01 {
+			parts = append(parts, fmt.Sprintf("~$%.4f", cost))
+		} else {
+			parts = append(parts, fmt.Sprintf("~$%.2f", cost))
+		}
+	}
+	u := cs.agent.ContextUsage()
+	parts = append(parts, fmt.Sprintf("%d%% of context", u.Total()*100/max(u.Budget, 1)))
+	fmt.Printf("\033[90m  %s\033[0m\n", strings.Join(parts, " · "))
+}
+
+// handleContextCommand lists what is in the context window for /context:
+// the system prompt, which is always kept, the summary of compacted
+// messages, and the recent messages kept as they are.
+func handleContextCommand(cs *chatState) {
+	history := cs.agent.History()
+	fmt.Printf("Pinned\n  %-10s %6s  %s\n", "system", formatCount(int64(llm.EstimateTokens(history[0]))), preview(history[0].Content))
+
+	recent := history[1:]
+	if len(recent) > 0 && agent.IsSummary(recent[0]) {
+		fmt.Printf("Summary\n  %-10s %6s  %s\n", "summary", formatCount(int64(llm.EstimateTokens(recent[0]))), preview(agent.SummaryText(recent[0])))
+		recent = recent[1:]
+	}
+
+	if len(recent) == 0 {
+		fmt.Println("Recent\n  (no messages yet)")
+		fmt.Println()
+		return
+	}
+	fmt.Printf("Recent (%d messages)\n", len(recent))
+	for _, m := range recent {
+		label, text := string(m.Role), m.Content
+		switch {
+		case m.Role == llm.RoleUser:
+			label = "you"
+		case m.Role == llm.RoleTool:
+			label = "tool"
+		case len(m.ToolCalls) > 0:
+			label = "tool call"
+			var calls []string
+			for _, tc := range m.ToolCalls {
+				calls = append(calls, agent.FormatToolCall(tc.Name, tc.Args))
+			}
+			text = strings.Join(calls, ", ")
+		case m.Role == llm.RoleAssistant:
+			label = "forge"
+		}
+		fmt.Printf("  %-10s %6s  %s\n", label, formatCount(int64(llm.EstimateTokens(m))), preview(text))
+	}
+	fmt.Println()
+}
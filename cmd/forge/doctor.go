@@ -0,0 +1,351 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/michaelbrown/forge/internal/config"
+	"github.com/michaelbrown/forge/internal/llm"
+	"github.com/michaelbrown/forge/internal/sandbox"
+	"github.com/michaelbrown/forge/internal/storage"
+	"github.com/michaelbrown/forge/internal/storage/sqlite"
+	"github.com/michaelbrown/forge/internal/tools"
+)
+
+var doctorCmd = &cobra.Command{
+	Use:   "doctor",
+	Short: "Check that forge's providers, models, tools, and database work",
+	Long: `Check everything forge needs: the config, that each provider answers and
+accepts its API key, that the models configured for Ollama providers are
+pulled, that each enabled tool server starts and answers, that Docker is
+there for code-runner, and that the database is sound. Each check passes
+(✓), warns (!), or fails (✗); skipped checks are marked -.
+
+Exits with status 1 if any check fails; warnings alone don't fail.`,
+	Args:          cobra.NoArgs,
+	RunE:          runDoctor,
+	SilenceUsage:  true,
+	SilenceErrors: true,
+}
+
+func init() {
+	rootCmd.AddCommand(doctorCmd)
+}
+
+// toolStartTimeout bounds how long forge doctor waits for a tool server to
+// start and answer initialize.
+const toolStartTimeout = 15 * time.Second
+
+// doctorReport prints the results of forge doctor's checks and counts the
+// failures and warnings among them.
+type doctorReport struct {
+	failures, warnings int
+}
+
+func (r *doctorReport) section(title string) {
+	fmt.Printf("\n%s\n", title)
+}
+
+func (r *doctorReport) pass(format string, args ...any) {
+	fmt.Printf("  ✓ %s\n", fmt.Sprintf(format, args...))
+}
+
+func (r *doctorReport) warn(format string, args ...any) {
+	r.warnings++
+	fmt.Printf("  ! %s\n", fmt.Sprintf(format, args...))
+}
+
+func (r *doctorReport) fail(format string, args ...any) {
+	r.failures++
+	fmt.Printf("  ✗ %s\n", fmt.Sprintf(format, args...))
+}
+
+func (r *doctorReport) skip(format string, args ...any) {
+	fmt.Printf("  - %s\n", fmt.Sprintf(format, args...))
+}
+
+func (r *doctorReport) problem(p config.Problem) {
+	if p.Warning {
+		r.warn("%s", p)
+	} else {
+		r.fail("%s", p)
+	}
+}
+
+func runDoctor(cmd *cobra.Command, args []string) error {
+	r := &doctorReport{}
+	r.section("Config")
+	cfg, err := config.Load()
+	if err != nil {
+		r.fail("%v", err)
+		return &exitError{code: exitFailed}
+	}
+	var problems []config.Problem
+	for _, path := range cfg.Files() {
+		r.pass("loaded %s", path)
+		unknown, err := config.UnknownKeys(path)
+		if err != nil {
+			r.fail("%v", err)
+		}
+		problems = append(problems, unknown...)
+	}
+	if env := cfg.EnvOverrides(); len(env) > 0 {
+		r.pass("overridden by %s", strings.Join(env, ", "))
+	}
+	if project := cfg.Project(); project != "" {
+		switch text, err := cfg.ProjectContext(); {
+		case err != nil:
+			r.fail("%v", err)
+		case text != "":
+			r.pass("project %s, with FORGE.md", project)
+		default:
+			r.pass("project %s", project)
+		}
+	}
+	problems = append(problems, cfg.Check()...)
+	// Problems with providers and tool servers are reported in their own
+	// sections, with the rest of what is known about them.
+	for _, p := range problems {
+		if !strings.HasPrefix(p.Key, "providers.") && !strings.HasPrefix(p.Key, "tools.") {
+			r.problem(p)
+		}
+	}
+
+	r.section("Providers")
+	answered := doctorProviders(r, cfg, problems)
+
+	r.section("Models")
+	doctorModels(r, cfg, answered)
+
+	r.section("Tools")
+	doctorTools(r, cfg, problems)
+
+	r.section("Docker")
+	doctorDocker(r, cfg)
+
+	r.section("Database")
+	doctorDatabase(r, cfg.Storage.DBPath)
+
+	fmt.Println()
+	switch {
+	case r.failures > 0:
+		fmt.Printf("%s, %s.\n", plural(r.failures, "failure"), plural(r.warnings, "warning"))
+		return &exitError{code: exitFailed}
+	case r.warnings > 0:
+		fmt.Printf("No failures, %s.\n", plural(r.warnings, "warning"))
+	default:
+		fmt.Println("Everything works.")
+	}
+	return nil
+}
+
+// doctorProviders reports config problems with each provider, and checks
+// that the others answer and accept their API keys. It returns the names
+// of those that did.
+func doctorProviders(r *doctorReport, cfg *config.Config, problems []config.Problem) map[string]bool {
+	if len(cfg.Providers) == 0 {
+		r.skip("none configured")
+		return nil
+	}
+	probes := map[string]error{}
+	for _, p := range probeProviders(cfg, problems) {
+		probes[p.name] = p.err
+	}
+	answered := map[string]bool{}
+	for _, name := range sortedNames(cfg.Providers) {
+		for _, p := range problems {
+			if strings.HasPrefix(p.Key, "providers."+name+".") {
+				r.problem(p)
+			}
+		}
+		err, probed := probes[name]
+		switch {
+		case !probed:
+			r.skip("%s: not checked, as it is misconfigured", name)
+		case err != nil:
+			r.fail("%s: %s", name, describeProviderError(err))
+		default:
+			answered[name] = true
+			r.pass("%s answers at %s", name, cfg.Providers[name].BaseURL)
+		}
+	}
+	return answered
+}
+
+// doctorModels checks that the models configured for each Ollama provider
+// that answered are pulled. Hosted providers' models aren't checked, as
+// their model lists don't say which models a key may use.
+func doctorModels(r *doctorReport, cfg *config.Config, answered map[string]bool) {
+	checked := false
+	for _, name := range sortedNames(cfg.Providers) {
+		p := cfg.Providers[name]
+		if !p.IsOllama() || !answered[name] || len(p.Models) == 0 {
+			continue
+		}
+		checked = true
+		ctx, cancel := context.WithTimeout(context.Background(), providerProbeTimeout)
+		models, err := llm.NewClient(p.BaseURL, p.APIKey, "").ListModels(ctx)
+		cancel()
+		if err != nil {
+			r.fail("%s: listing models: %s", name, describeProviderError(err))
+			continue
+		}
+		pulled := map[string]bool{}
+		for _, m := range models {
+			pulled[m.Name] = true
+		}
+		for _, role := range sortedNames(p.Models) {
+			model := p.Models[role]
+			if model == "" {
+				continue
+			}
+			// Ollama lists a model pulled without a tag as name:latest.
+			if pulled[model] || (!strings.Contains(model, ":") && pulled[model+":latest"]) {
+				r.pass("%s: %s (%s) is pulled", name, model, role)
+			} else {
+				r.fail("%s: %s (%s) isn't pulled (run ollama pull %s)", name, model, role, model)
+			}
+		}
+	}
+	if !checked {
+		r.skip("no Ollama providers to check")
+	}
+}
+
+// toolStart is how a tool server fared when started.
+type toolStart struct {
+	tools []string
+	err   error
+}
+
+// doctorTools starts each enabled tool server without config problems, all
+// at once, and reports whether it answered initialize and which tools it
+// has.
+func doctorTools(r *doctorReport, cfg *config.Config, problems []config.Problem) {
+	if len(cfg.Tools) == 0 {
+		r.skip("none configured; the agent uses its built-in pack")
+		return
+	}
+	names := sortedNames(cfg.Tools)
+	starts := make([]chan toolStart, len(names))
+	for i, name := range names {
+		toolCfg := cfg.Tools[name]
+		misconfigured := slices.ContainsFunc(problems, func(p config.Problem) bool {
+			return p.Key == "tools."+name || strings.HasPrefix(p.Key, "tools."+name+".")
+		})
+		if !toolCfg.Enabled || misconfigured {
+			continue
+		}
+		// Buffered, so a server that starts after the timeout doesn't
+		// leave its goroutine blocked.
+		starts[i] = make(chan toolStart, 1)
+		go func() {
+			registry := tools.NewRegistry()
+			defer registry.Close()
+			err := registry.Register(name, toolCfg)
+			starts[i] <- toolStart{tools: registry.ServerTools(name), err: err}
+		}()
+	}
+
+	timeout := time.After(toolStartTimeout)
+	for i, name := range names {
+		for _, p := range problems {
+			if p.Key == "tools."+name || strings.HasPrefix(p.Key, "tools."+name+".") {
+				r.problem(p)
+			}
+		}
+		switch {
+		case !cfg.Tools[name].Enabled:
+			r.skip("%s: disabled", name)
+			continue
+		case starts[i] == nil:
+			r.skip("%s: not started, as it is misconfigured", name)
+			continue
+		}
+		select {
+		case s := <-starts[i]:
+			switch {
+			case s.err != nil:
+				r.fail("%s: %v", name, s.err)
+			case len(s.tools) == 0:
+				r.warn("%s: started, but has no tools", name)
+			default:
+				r.pass("%s: %s", name, plural(len(s.tools), "tool"))
+			}
+		case <-timeout:
+			r.fail("%s: didn't answer initialize within %s", name, toolStartTimeout)
+		}
+	}
+}
+
+// doctorDocker checks for Docker when code-runner is enabled. Without it,
+// code-runner still works, running code as plain processes.
+func doctorDocker(r *doctorReport, cfg *config.Config) {
+	var runners []string
+	for _, name := range sortedNames(cfg.Tools) {
+		t := cfg.Tools[name]
+		if t.Enabled && (name == "code-runner" || strings.Contains(filepath.Base(t.Binary), "code-runner")) {
+			runners = append(runners, name)
+		}
+	}
+	if len(runners) == 0 {
+		r.skip("not needed: code-runner isn't enabled")
+		return
+	}
+	if sandbox.DockerAvailable(context.Background()) {
+		r.pass("running; %s runs code in containers", strings.Join(runners, ", "))
+	} else {
+		r.warn("not running or not installed; %s runs code as plain processes, without a container's isolation", strings.Join(runners, ", "))
+	}
+}
+
+// doctorDatabase checks that the database opens, is up to date with
+// forge's schema, and passes SQLite's integrity check. A database that
+// doesn't exist yet is left uncreated.
+func doctorDatabase(r *doctorReport, path string) {
+	info, err := os.Stat(path)
+	if errors.Is(err, os.ErrNotExist) {
+		r.skip("%s doesn't exist yet; forge creates it when first used", path)
+		return
+	}
+	if err != nil {
+		r.fail("%v", err)
+		return
+	}
+	store, err := sqlite.Open(path)
+	if err != nil {
+		r.fail("%s: %v", path, err)
+		return
+	}
+	defer store.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	if err := store.Check(ctx); err != nil {
+		r.fail("%s: %v", path, err)
+		return
+	}
+	sessions, err := store.CountSessions(ctx, storage.SessionListOptions{All: true})
+	if err != nil {
+		r.fail("%s: %v", path, err)
+		return
+	}
+	r.pass("%s: %s, %s", path, plural(sessions, "session"), formatSize(info.Size()))
+}
+
+func sortedNames[V any](m map[string]V) []string {
+	names := make([]string, 0, len(m))
+	for name := range m {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
@@ -0,0 +1,275 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/chzyer/readline"
+	"github.com/spf13/cobra"
+
+	"github.com/michaelbrown/forge/internal/config"
+	"github.com/michaelbrown/forge/internal/llm"
+	"github.com/michaelbrown/forge/internal/tools"
+)
+
+var howTimeout time.Duration
+
+var howCmd = &cobra.Command{
+	Use:   "how <task>",
+	Short: "Suggest a shell command for a task, and run it if you say so",
+	Long: `Ask the model for a shell command that does a task, and print it with an
+explanation. On a terminal, forge then asks whether to run it, edit it
+first, or leave it; nothing is run without a yes.
+
+Commands run through shell_exec, as the agent's do: with the shell-exec
+tool server, and its command policy, if it is configured, and the built-in
+pack's otherwise. Output is shown when the command finishes.
+
+When stdout isn't a terminal, the command alone is printed, and never run,
+so it can be captured.
+
+Examples:
+  forge how "resize all pngs here to 50%"
+  forge how "find files over 100MB under my home directory"
+  cmd=$(forge how "list listening TCP ports")`,
+	Args:          cobra.MinimumNArgs(1),
+	RunE:          runHow,
+	SilenceUsage:  true,
+	SilenceErrors: true,
+}
+
+func init() {
+	rootCmd.AddCommand(howCmd)
+
+	howCmd.Flags().DurationVar(&howTimeout, "timeout", 10*time.Minute, "Stop the command after this long (at most 30m)")
+}
+
+// howSuggestion is the command the model suggests, as it is asked to reply.
+type howSuggestion struct {
+	Command     string `json:"command"`
+	Explanation string `json:"explanation"`
+	Dangerous   bool   `json:"dangerous"`
+}
+
+const howSystemPrompt = `You suggest shell commands. Given a task, reply with one command for %s,
+run with sh -c in %s, that does it. Prefer standard tools that are likely
+to be installed, and say in the explanation which ones aren't.
+
+Reply with a JSON object alone, with no code fences or text around it:
+{"command": "the command", "explanation": "one or two sentences on what it does and what it changes", "dangerous": false}
+
+Set dangerous to true if the command deletes or overwrites files, changes
+system settings, or can't be undone. If the task can't be done with a
+shell command, reply with an empty command and say why in the explanation.`
+
+func runHow(cmd *cobra.Command, args []string) error {
+	task := strings.TrimSpace(strings.Join(args, " "))
+	cfg, err := config.Load()
+	if err != nil {
+		return &exitError{exitUsage, fmt.Errorf("loading config: %w", err)}
+	}
+	setup, err := resolveAgent(cfg, false)
+	if err != nil {
+		return &exitError{exitUsage, err}
+	}
+	dir, err := os.Getwd()
+	if err != nil {
+		return &exitError{exitFailed, err}
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	messages := []llm.Message{
+		llm.SystemMessage(fmt.Sprintf(howSystemPrompt, runtime.GOOS, dir)),
+		llm.UserMessage(task),
+	}
+	client := llm.NewClient(setup.provider.BaseURL, setup.provider.APIKey, setup.model)
+	resp, err := client.ChatCompletion(ctx, messages, nil)
+	if err != nil {
+		if ctx.Err() != nil {
+			return &exitError{exitInterrupted, errors.New("interrupted")}
+		}
+		return &exitError{exitFailed, err}
+	}
+	s, err := parseSuggestion(resp.Message.Content)
+	if err != nil {
+		return &exitError{exitFailed, fmt.Errorf("the model's reply isn't in the expected form: %w", err)}
+	}
+	if s.Command == "" {
+		return &exitError{exitFailed, fmt.Errorf("no command suggested: %s", s.Explanation)}
+	}
+
+	interactive := readline.IsTerminal(int(os.Stdin.Fd())) && readline.IsTerminal(int(os.Stdout.Fd()))
+	if !interactive {
+		fmt.Println(s.Command)
+		if s.Explanation != "" {
+			fmt.Fprintln(os.Stderr, s.Explanation)
+		}
+		return nil
+	}
+
+	// One readline for every prompt: each instance reads stdin until it
+	// is closed, so a second would lose keys to the first.
+	rl, err := readline.New("")
+	if err != nil {
+		return &exitError{exitFailed, err}
+	}
+	defer rl.Close()
+	command := s.Command
+	for {
+		fmt.Printf("\n  \033[1m$ %s\033[0m\n", command)
+		if command == s.Command && s.Explanation != "" {
+			fmt.Printf("  %s\n", s.Explanation)
+		}
+		if command == s.Command && s.Dangerous {
+			fmt.Printf("  \033[31m! This may delete or overwrite things, or can't be undone.\033[0m\n")
+		}
+		// There is no default: running a command takes an explicit yes.
+		rl.SetPrompt("Run it? [y]es, [e]dit, [N]o: ")
+		answer, err := rl.Readline()
+		if err != nil {
+			fmt.Println("Not running it.")
+			return nil
+		}
+		switch strings.ToLower(strings.TrimSpace(answer)) {
+		case "y", "yes":
+			return runHowCommand(ctx, cfg, command, dir, func(ctx context.Context, server, message string) (bool, error) {
+				fmt.Printf("\n  \033[33m? %s needs approval:\033[0m\n", server)
+				for _, line := range strings.Split(message, "\n") {
+					fmt.Printf("  \033[33m│\033[0m %s\n", line)
+				}
+				rl.SetPrompt("  approve? [y/N] ")
+				answer, err := rl.Readline()
+				if err != nil {
+					return false, nil
+				}
+				answer = strings.ToLower(strings.TrimSpace(answer))
+				return answer == "y" || answer == "yes", nil
+			})
+		case "e", "edit":
+			edited, err := editCommand(rl, command)
+			if err != nil || strings.TrimSpace(edited) == "" {
+				fmt.Println("Not running it.")
+				return nil
+			}
+			command = strings.TrimSpace(edited)
+		case "n", "no", "q", "quit", "":
+			fmt.Println("Not running it.")
+			return nil
+		}
+	}
+}
+
+// editCommand lets the user edit command on its own line.
+func editCommand(rl *readline.Instance, command string) (string, error) {
+	rl.SetPrompt("$ ")
+	return rl.ReadlineWithDefault(command)
+}
+
+// runHowCommand runs command with shell_exec, from the shell-exec tool
+// server if one is configured, so that its policy applies, or from the
+// built-in pack. Approvals the policy asks for go to approve.
+func runHowCommand(ctx context.Context, cfg *config.Config, command, dir string, approve tools.Approver) error {
+	registry := tools.NewRegistry()
+	defer registry.Close()
+	registry.SetApprover(approve)
+	if name := shellExecServer(cfg); name != "" {
+		if err := registry.Register(name, cfg.Tools[name]); err != nil {
+			return &exitError{exitFailed, fmt.Errorf("starting %s: %w", name, err)}
+		}
+	} else {
+		registry.Add("builtin", tools.NewBuiltinServer())
+	}
+
+	timeout := min(howTimeout, 30*time.Minute)
+	ctx, cancel := context.WithTimeout(ctx, timeout+10*time.Second)
+	defer cancel()
+	result, err := registry.CallTool(ctx, "shell_exec", map[string]any{
+		"command":         command,
+		"workdir":         dir,
+		"timeout_seconds": int(timeout.Seconds()),
+	})
+	if err != nil {
+		if ctx.Err() != nil && !errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return &exitError{exitInterrupted, errors.New("interrupted")}
+		}
+		return &exitError{exitFailed, err}
+	}
+	if msg, ok := strings.CutPrefix(result, "error: "); ok {
+		return &exitError{exitFailed, errors.New(strings.TrimPrefix(msg, "error: "))}
+	}
+
+	code, status, stdout, stderr := parseShellResult(result)
+	fmt.Print(stdout)
+	fmt.Fprint(os.Stderr, stderr)
+	if code != 0 {
+		if status != "" {
+			return &exitError{exitFailed, fmt.Errorf("exit code %d (%s)", code, status)}
+		}
+		return &exitError{exitFailed, fmt.Errorf("exit code %d", code)}
+	}
+	return nil
+}
+
+// shellExecServer returns the name of the enabled shell-exec tool server,
+// or "" if there isn't one.
+func shellExecServer(cfg *config.Config) string {
+	for _, name := range sortedNames(cfg.Tools) {
+		t := cfg.Tools[name]
+		if t.Enabled && (name == "shell-exec" || strings.Contains(filepath.Base(t.Binary), "shell-exec")) {
+			return name
+		}
+	}
+	return ""
+}
+
+// parseShellResult splits a shell_exec result into the exit code, the
+// status after it, if any, and the [stdout] and [stderr] sections.
+func parseShellResult(result string) (code int, status, stdout, stderr string) {
+	first, rest, _ := strings.Cut(result, "\n")
+	first = strings.TrimPrefix(first, "exit_code: ")
+	num, status, _ := strings.Cut(first, " ")
+	code, err := strconv.Atoi(num)
+	if err != nil {
+		code = -1
+	}
+	status = strings.Trim(status, "()")
+	if after, ok := strings.CutPrefix(rest, "[stderr]\n"); ok {
+		stderr = after
+	} else {
+		stdout, stderr, _ = strings.Cut(strings.TrimPrefix(rest, "[stdout]\n"), "\n[stderr]\n")
+	}
+	if stdout != "" && !strings.HasSuffix(stdout, "\n") {
+		stdout += "\n"
+	}
+	if stderr != "" && !strings.HasSuffix(stderr, "\n") {
+		stderr += "\n"
+	}
+	return code, status, stdout, stderr
+}
+
+// parseSuggestion reads the model's reply, tolerating text or code fences
+// around the JSON object.
+func parseSuggestion(reply string) (*howSuggestion, error) {
+	start, end := strings.Index(reply, "{"), strings.LastIndex(reply, "}")
+	if start < 0 || end < start {
+		return nil, errors.New("no JSON object")
+	}
+	var s howSuggestion
+	if err := json.Unmarshal([]byte(reply[start:end+1]), &s); err != nil {
+		return nil, err
+	}
+	s.Command = strings.TrimSpace(s.Command)
+	return &s, nil
+}
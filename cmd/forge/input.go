@@ -0,0 +1,120 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/chzyer/readline"
+
+	"github.com/michaelbrown/forge/internal/mention"
+)
+
+// continuePrompt asks for the next line of a message spanning several.
+const continuePrompt = "\033[36m...>\033[0m "
+
+// readMessage reads the next message from rl: one line, lines joined by a
+// trailing backslash, or every line between """ and """, which is the way
+// to paste code. A line is trimmed; a message of several lines only loses
+// its blank lines at either end, keeping indentation. Ctrl+C or Ctrl+D
+// partway through a message drops it and returns "".
+func readMessage(rl *readline.Instance) (string, error) {
+	line, err := rl.Readline()
+	if err != nil {
+		return "", err
+	}
+	prompt := rl.Config.Prompt
+	defer rl.SetPrompt(prompt)
+	rl.SetPrompt(continuePrompt)
+
+	if rest, ok := strings.CutPrefix(strings.TrimSpace(line), `"""`); ok {
+		if text, ok := strings.CutSuffix(rest, `"""`); ok {
+			return strings.TrimSpace(text), nil
+		}
+		var lines []string
+		if rest != "" {
+			lines = append(lines, rest)
+		}
+		for {
+			next, err := rl.Readline()
+			if err != nil {
+				return "", nil
+			}
+			if text, ok := strings.CutSuffix(strings.TrimRight(next, " \t"), `"""`); ok {
+				return trimLines(append(lines, text)), nil
+			}
+			lines = append(lines, next)
+		}
+	}
+
+	var lines []string
+	for strings.HasSuffix(line, `\`) {
+		lines = append(lines, strings.TrimSuffix(line, `\`))
+		if line, err = rl.Readline(); err != nil {
+			return "", nil
+		}
+	}
+	return trimLines(append(lines, line)), nil
+}
+
+// trimLines joins lines into a message, trimmed as readMessage describes.
+func trimLines(lines []string) string {
+	if len(lines) == 1 {
+		return strings.TrimSpace(lines[0])
+	}
+	for len(lines) > 0 && strings.TrimSpace(lines[0]) == "" {
+		lines = lines[1:]
+	}
+	for len(lines) > 0 && strings.TrimSpace(lines[len(lines)-1]) == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return strings.Join(lines, "\n")
+}
+
+// editMessage opens $VISUAL or $EDITOR, or vi, on a temp file holding
+// initial, and returns what was saved, trimmed.
+func editMessage(initial string) (string, error) {
+	editor := os.Getenv("VISUAL")
+	if editor == "" {
+		editor = os.Getenv("EDITOR")
+	}
+	if editor == "" {
+		editor = "vi"
+	}
+
+	f, err := os.CreateTemp("", "forge-message-*.md")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(f.Name())
+	_, err = f.WriteString(initial)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return "", err
+	}
+
+	// The editor may carry arguments, as in EDITOR="code --wait".
+	args := strings.Fields(editor)
+	cmd := exec.Command(args[0], append(args[1:], f.Name())...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("running %s: %w", args[0], err)
+	}
+	data, err := os.ReadFile(f.Name())
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+// describeReference says what an @ reference added to a message.
+func describeReference(ref mention.Reference) string {
+	s := fmt.Sprintf("%s (%s)", ref.Name, formatSize(int64(ref.Size)))
+	if ref.Truncated {
+		s += ", truncated"
+	}
+	return s
+}
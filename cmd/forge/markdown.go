@@ -0,0 +1,115 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/charmbracelet/glamour"
+	"github.com/charmbracelet/x/ansi"
+	"github.com/chzyer/readline"
+)
+
+// markdownStream renders a reply's markdown as it streams in, a block at a
+// time: text is held until a blank line outside a code fence ends a block,
+// which is then rendered, code highlighted, and printed. Flush prints what
+// is left at the end of a reply, or before a tool call interrupts it.
+type markdownStream struct {
+	out      io.Writer
+	renderer *glamour.TermRenderer
+
+	line    strings.Builder // the line being streamed
+	block   strings.Builder // complete lines of the block being streamed
+	fence   string          // the open code fence's marker, if any
+	printed bool            // whether a block of this reply was printed
+}
+
+// newMarkdownStream returns a stream rendering to out at the terminal's
+// width, or nil if out isn't a terminal or no renderer can be made, in
+// which case replies are printed as they are.
+func newMarkdownStream(out io.Writer) *markdownStream {
+	if f, ok := out.(*os.File); !ok || !readline.IsTerminal(int(f.Fd())) {
+		return nil
+	}
+	width := readline.GetScreenWidth()
+	if width <= 0 {
+		width = 80
+	}
+	renderer, err := glamour.NewTermRenderer(glamour.WithAutoStyle(), glamour.WithWordWrap(width-4))
+	if err != nil {
+		return nil
+	}
+	return &markdownStream{out: out, renderer: renderer}
+}
+
+// Write adds streamed text, printing each block it completes.
+func (s *markdownStream) Write(delta string) {
+	for delta != "" {
+		text, rest, newline := strings.Cut(delta, "\n")
+		s.line.WriteString(text)
+		delta = rest
+		if !newline {
+			return
+		}
+		line := s.line.String()
+		s.line.Reset()
+		s.block.WriteString(line + "\n")
+
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case s.fence != "":
+			if strings.HasPrefix(trimmed, s.fence) && strings.Trim(trimmed, s.fence[:1]) == "" {
+				s.fence = ""
+			}
+		case strings.HasPrefix(trimmed, "```"), strings.HasPrefix(trimmed, "~~~"):
+			s.fence = trimmed[:3]
+		case trimmed == "":
+			s.render()
+		}
+	}
+}
+
+// Flush prints what is left of the reply and readies the stream for the
+// next one.
+func (s *markdownStream) Flush() {
+	s.block.WriteString(s.line.String())
+	s.line.Reset()
+	s.render()
+	s.fence = ""
+	s.printed = false
+}
+
+// render prints the block streamed so far, if it has any text.
+func (s *markdownStream) render() {
+	block := s.block.String()
+	s.block.Reset()
+	if strings.TrimSpace(block) == "" {
+		return
+	}
+	out, err := s.renderer.Render(block)
+	if err != nil {
+		out = block
+	}
+	if s.printed {
+		fmt.Fprint(s.out, "\n\n")
+	} else {
+		fmt.Fprint(s.out, "\n")
+	}
+	fmt.Fprint(s.out, trimBlankLines(out))
+	s.printed = true
+}
+
+// trimBlankLines drops the blank lines glamour pads a rendering with, which
+// may hold spaces and escape codes.
+func trimBlankLines(s string) string {
+	lines := strings.Split(s, "\n")
+	blank := func(line string) bool { return strings.TrimSpace(ansi.Strip(line)) == "" }
+	for len(lines) > 0 && blank(lines[0]) {
+		lines = lines[1:]
+	}
+	for len(lines) > 0 && blank(lines[len(lines)-1]) {
+		lines = lines[:len(lines)-1]
+	}
+	return strings.Join(lines, "\n")
+}
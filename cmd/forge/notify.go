@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/michaelbrown/forge/internal/config"
+	"github.com/michaelbrown/forge/internal/notify"
+)
+
+// turnNotifier sends a desktop notification when a turn that has run for
+// chat.notify_after_seconds or more ends or stops to ask for approval, so
+// a slow model can be left to work while you do something else. A nil
+// turnNotifier sends nothing.
+type turnNotifier struct {
+	after  time.Duration
+	warn   func(string) // reports the first notification that fails
+	warned atomic.Bool
+
+	mu    sync.Mutex
+	start time.Time // when the running turn began, or zero between turns
+}
+
+// newTurnNotifier returns a turnNotifier, or nil if notifications are off.
+func newTurnNotifier(cfg *config.Config, warn func(string)) *turnNotifier {
+	if cfg.Chat.NotifyAfterSeconds <= 0 {
+		return nil
+	}
+	return &turnNotifier{after: time.Duration(cfg.Chat.NotifyAfterSeconds) * time.Second, warn: warn}
+}
+
+// begin marks the start of a turn.
+func (n *turnNotifier) begin() {
+	if n == nil {
+		return
+	}
+	n.mu.Lock()
+	n.start = time.Now()
+	n.mu.Unlock()
+}
+
+// long reports whether the running turn has gone on long enough to notify
+// about.
+func (n *turnNotifier) long() bool {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return !n.start.IsZero() && time.Since(n.start) >= n.after
+}
+
+// finished notifies that the turn ended with reply, or failed with err,
+// if it ran long. Turns the user stopped aren't reported.
+func (n *turnNotifier) finished(sessionTitle, reply string, err error) {
+	if n == nil {
+		return
+	}
+	long := n.long()
+	n.mu.Lock()
+	n.start = time.Time{}
+	n.mu.Unlock()
+	if !long {
+		return
+	}
+	title := "Forge"
+	if sessionTitle != "" {
+		title += ": " + sessionTitle
+	}
+	body := firstLine(reply)
+	switch {
+	case err != nil:
+		body = "Failed: " + clip(err.Error(), 200)
+	case body == "":
+		body = "Reply ready"
+	}
+	n.send(title, body)
+}
+
+// approval notifies that a tool server is waiting on an approval, if the
+// turn has run long.
+func (n *turnNotifier) approval(server, message string) {
+	if n == nil || !n.long() {
+		return
+	}
+	n.send("Forge needs approval", server+": "+clip(strings.TrimSpace(message), 300))
+}
+
+func (n *turnNotifier) send(title, body string) {
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if err := notify.Send(ctx, title, body); err != nil && n.warned.CompareAndSwap(false, true) {
+			n.warn("warning: desktop notification failed: " + err.Error())
+		}
+	}()
+}
+
+// firstLine returns the first line of text in a markdown reply, without
+// heading or list markers, cut to 120 characters.
+func firstLine(reply string) string {
+	for _, line := range strings.Split(reply, "\n") {
+		line = strings.TrimLeft(strings.TrimSpace(line), "#>*- ")
+		if line != "" && !strings.HasPrefix(line, "```") {
+			return clip(line, 120)
+		}
+	}
+	return ""
+}
+
+// clip cuts s to limit runes, marking the cut.
+func clip(s string, limit int) string {
+	if r := []rune(s); len(r) > limit {
+		return string(r[:limit]) + "…"
+	}
+	return s
+}
@@ -0,0 +1,271 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"unicode"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/x/ansi"
+	"github.com/chzyer/readline"
+
+	"github.com/michaelbrown/forge/internal/storage"
+)
+
+// pickFlag is the value --resume takes when it is given without an ID.
+// Session IDs are hex, so it can't be mistaken for a prefix of one.
+const pickFlag = "pick"
+
+// errNotPicked means the picker was left without choosing a session.
+var errNotPicked = errors.New("no session chosen")
+
+// continueLast is set by --continue.
+var continueLast bool
+
+// resumeSession returns the session --resume or --continue asks for, or
+// nil for a new one. --resume with no ID shows the picker; an ID may also
+// follow it as an argument, as in "--resume 3f2a".
+func resumeSession(ctx context.Context, store storage.Store, args []string) (*storage.Session, error) {
+	switch {
+	case continueLast && resumeID != "":
+		return nil, errors.New("--resume and --continue can't be used together")
+	case continueLast:
+		sessions, err := store.ListSessions(ctx, storage.SessionListOptions{Limit: 1})
+		if err != nil {
+			return nil, fmt.Errorf("listing sessions: %w", err)
+		}
+		if len(sessions) == 0 {
+			return nil, errors.New("no sessions to continue")
+		}
+		return &sessions[0], nil
+	case resumeID == pickFlag && len(args) > 0:
+		resumeID = args[0]
+	case len(args) > 0:
+		return nil, fmt.Errorf("unexpected argument %q; to resume a session, use --resume %s", args[0], args[0])
+	}
+
+	switch resumeID {
+	case "":
+		return nil, nil
+	case pickFlag:
+		if !readline.IsTerminal(int(os.Stdin.Fd())) || !readline.IsTerminal(int(os.Stdout.Fd())) {
+			return nil, errors.New("--resume needs a session ID when not run in a terminal")
+		}
+		return pickSession(ctx, store)
+	}
+	sess, err := store.GetSession(ctx, resumeID)
+	if err != nil {
+		return nil, fmt.Errorf("loading session: %w", err)
+	}
+	return sess, nil
+}
+
+// pickerLimit is how many recent sessions the picker searches.
+const pickerLimit = 200
+
+// pickSession lets the user choose one of the recent sessions, searching
+// them as they type. It returns errNotPicked if they leave without one.
+func pickSession(ctx context.Context, store storage.Store) (*storage.Session, error) {
+	sessions, err := store.ListSessions(ctx, storage.SessionListOptions{Limit: pickerLimit})
+	if err != nil {
+		return nil, fmt.Errorf("listing sessions: %w", err)
+	}
+	return runPicker(ctx, sessions)
+}
+
+// runPicker lets the user choose one of sessions.
+func runPicker(ctx context.Context, sessions []storage.Session) (*storage.Session, error) {
+	if len(sessions) == 0 {
+		return nil, errors.New("no sessions to resume")
+	}
+	m := newSessionPicker(sessions)
+	if _, err := tea.NewProgram(m, tea.WithContext(ctx)).Run(); err != nil {
+		return nil, err
+	}
+	if m.picked == nil {
+		return nil, errNotPicked
+	}
+	return m.picked, nil
+}
+
+// pickerRows is how many sessions the picker shows at once.
+const pickerRows = 10
+
+// sessionPicker is a list of sessions narrowed by a fuzzy search.
+type sessionPicker struct {
+	sessions []storage.Session
+	matches  []storage.Session
+	input    textinput.Model
+	cursor   int
+	first    int // the first match in view
+	width    int
+	picked   *storage.Session
+	done     bool
+}
+
+func newSessionPicker(sessions []storage.Session) *sessionPicker {
+	input := textinput.New()
+	input.Prompt = "Resume: "
+	input.Placeholder = "type to search titles, models, and IDs"
+	input.Focus()
+	return &sessionPicker{sessions: sessions, matches: sessions, input: input, width: 80}
+}
+
+func (m *sessionPicker) Init() tea.Cmd {
+	return textinput.Blink
+}
+
+func (m *sessionPicker) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		m.width = msg.Width
+		return m, nil
+	case tea.KeyMsg:
+		switch msg.String() {
+		case "ctrl+c", "esc":
+			m.done = true
+			return m, tea.Quit
+		case "enter":
+			if len(m.matches) == 0 {
+				return m, nil
+			}
+			m.picked = &m.matches[m.cursor]
+			m.done = true
+			return m, tea.Quit
+		case "up", "ctrl+p", "ctrl+k":
+			m.move(-1)
+			return m, nil
+		case "down", "ctrl+n", "ctrl+j":
+			m.move(1)
+			return m, nil
+		case "pgup":
+			m.move(-pickerRows)
+			return m, nil
+		case "pgdown":
+			m.move(pickerRows)
+			return m, nil
+		}
+	}
+	query := m.input.Value()
+	var cmd tea.Cmd
+	m.input, cmd = m.input.Update(msg)
+	if m.input.Value() != query {
+		m.matches = filterSessions(m.sessions, m.input.Value())
+		m.cursor, m.first = 0, 0
+	}
+	return m, cmd
+}
+
+// move moves the cursor by n matches, scrolling to keep it in view.
+func (m *sessionPicker) move(n int) {
+	m.cursor = max(min(m.cursor+n, len(m.matches)-1), 0)
+	if m.cursor < m.first {
+		m.first = m.cursor
+	}
+	if m.cursor >= m.first+pickerRows {
+		m.first = m.cursor - pickerRows + 1
+	}
+}
+
+func (m *sessionPicker) View() string {
+	// Once the program quits, its last view is left on the screen.
+	if m.done {
+		return ""
+	}
+	lines := []string{m.input.View()}
+	if len(m.matches) == 0 {
+		lines = append(lines, dimStyle.Render("  No matching sessions."))
+	}
+	for i := m.first; i < len(m.matches) && i < m.first+pickerRows; i++ {
+		sess := m.matches[i]
+		title := sess.Title
+		if title == "" {
+			title = "(untitled)"
+		}
+		line := fmt.Sprintf("  %-40s  %-8s  %s", ansi.Truncate(title, 40, "…"), timeAgo(sess.UpdatedAt), sess.Model)
+		line = ansi.Truncate(line, m.width, "…")
+		if i == m.cursor {
+			line = selectedStyle.Render(line)
+		}
+		lines = append(lines, line)
+	}
+	lines = append(lines, dimStyle.Render(fmt.Sprintf("  %d of %d · ↑/↓ select · enter resume · esc cancel", len(m.matches), len(m.sessions))))
+	return strings.Join(lines, "\n")
+}
+
+// filterSessions returns the sessions that match every word of query,
+// best matches first and most recent first among equals.
+func filterSessions(sessions []storage.Session, query string) []storage.Session {
+	words := strings.Fields(strings.ToLower(query))
+	if len(words) == 0 {
+		return sessions
+	}
+	type match struct {
+		sess  storage.Session
+		score int
+	}
+	var matches []match
+	for _, sess := range sessions {
+		text := strings.ToLower(strings.Join([]string{sess.Title, sess.Model, sess.Provider, sess.Profile, shortID(sess.ID)}, " "))
+		total := 0
+		for _, word := range words {
+			score, ok := fuzzyScore(word, text)
+			if !ok {
+				total = -1
+				break
+			}
+			total += score
+		}
+		if total >= 0 {
+			matches = append(matches, match{sess, total})
+		}
+	}
+	slices.SortStableFunc(matches, func(a, b match) int { return b.score - a.score })
+	result := make([]storage.Session, len(matches))
+	for i, m := range matches {
+		result[i] = m.sess
+	}
+	return result
+}
+
+// fuzzyScore reports whether the letters of query appear in text in
+// order, and scores the match higher the more of them run together or
+// start words. Each place the first letter appears is tried, and the
+// best match kept.
+func fuzzyScore(query, text string) (int, bool) {
+	q, t := []rune(query), []rune(text)
+	if len(q) == 0 {
+		return 0, true
+	}
+	best, found := 0, false
+	for start, r := range t {
+		if r != q[0] {
+			continue
+		}
+		score, i, last := 0, 0, start-2
+		for j := start; j < len(t) && i < len(q); j++ {
+			if t[j] != q[i] {
+				continue
+			}
+			score++
+			if j == last+1 {
+				score += 3
+			}
+			if j == 0 || !unicode.IsLetter(t[j-1]) && !unicode.IsDigit(t[j-1]) {
+				score += 2
+			}
+			last = j
+			i++
+		}
+		if i < len(q) {
+			break // later starts have fewer letters left to match
+		}
+		best, found = max(best, score), true
+	}
+	return best, found
+}
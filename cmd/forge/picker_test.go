@@ -0,0 +1,85 @@
+package main
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/michaelbrown/forge/internal/storage"
+)
+
+func TestFuzzyScore(t *testing.T) {
+	tests := []struct {
+		query, text string
+		want        int
+		ok          bool
+	}{
+		{"", "anything", 0, true},
+		{"abc", "abc", 11, true},       // one run from the start of a word
+		{"abc", "a-b-c", 9, true},      // each letter starts a word
+		{"abc", "xaxbxc", 3, true},     // scattered
+		{"ab", "a xab", 5, true},       // the later start runs together
+		{"cb", "abc", 0, false},        // out of order
+		{"abcd", "abc", 0, false},      // runs out of text
+		{"é", "café au lait", 1, true}, // runes, not bytes
+	}
+
+	for _, tt := range tests {
+		got, ok := fuzzyScore(tt.query, tt.text)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("fuzzyScore(%q, %q) = %d, %v; want %d, %v", tt.query, tt.text, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestFilterSessions(t *testing.T) {
+	sessions := []storage.Session{
+		{ID: "1a2b3c4d-0000", Title: "Fix the login bug", Model: "claude-sonnet", Provider: "claude"},
+		{ID: "5e6f7a8b-0000", Title: "Plan the release", Model: "llama3", Provider: "ollama", Profile: "planner"},
+		{ID: "imp1", Title: "Imported notes", Model: "gpt-4o", Provider: "openai"},
+		{ID: "9c0d1e2f-0000", Title: "Logging cleanup", Model: "llama3", Provider: "ollama"},
+	}
+	titles := func(ss []storage.Session) []string {
+		var out []string
+		for _, s := range ss {
+			out = append(out, s.Title)
+		}
+		return out
+	}
+
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{"", []string{"Fix the login bug", "Plan the release", "Imported notes", "Logging cleanup"}},
+		{"   ", []string{"Fix the login bug", "Plan the release", "Imported notes", "Logging cleanup"}},
+		{"login", []string{"Fix the login bug", "Logging cleanup"}},
+		{"LOG", []string{"Fix the login bug", "Logging cleanup"}},
+		{"ogg", []string{"Logging cleanup", "Fix the login bug"}},  // letters together beat scattered ones
+		{"llama", []string{"Plan the release", "Logging cleanup"}}, // equal scores keep their order
+		{"ollama log", []string{"Logging cleanup"}},
+		{"planner", []string{"Plan the release"}},
+		{"5e6f", []string{"Plan the release"}},
+		{"imp1", []string{"Imported notes"}},
+		{"0000", nil}, // only the short ID is searched
+		{"zzz", nil},
+	}
+
+	for _, tt := range tests {
+		if got := titles(filterSessions(sessions, tt.query)); !slices.Equal(got, tt.want) {
+			t.Errorf("filterSessions(%q) = %q, want %q", tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestShortID(t *testing.T) {
+	for id, want := range map[string]string{
+		"1a2b3c4d-5e6f-7a8b": "1a2b3c4d",
+		"1a2b3c4d":           "1a2b3c4d",
+		"imp1":               "imp1",
+		"":                   "",
+	} {
+		if got := shortID(id); got != want {
+			t.Errorf("shortID(%q) = %q, want %q", id, got, want)
+		}
+	}
+}
@@ -0,0 +1,378 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"slices"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/spf13/cobra"
+
+	"github.com/michaelbrown/forge/internal/agent"
+	"github.com/michaelbrown/forge/internal/config"
+	"github.com/michaelbrown/forge/internal/tools"
+)
+
+var (
+	reviewPR    int
+	reviewRange string
+	reviewJSON  bool
+	reviewPost  bool
+)
+
+var reviewCmd = &cobra.Command{
+	Use:   "review",
+	Short: "Review a diff and list what should be fixed, by severity",
+	Long: `Have the reviewer agent read a diff, and the files around it, and report
+findings: a severity, a file and line, what is wrong, and what to do
+instead. By default the diff is the uncommitted changes in the working
+tree, staged or not; --range reviews a range of commits, and --pr a GitHub
+pull request, fetched with the github-ops tool server.
+
+The reviewer may read files, but not change them or run commands. Its
+instructions come from a profile named reviewer, if there is one, or
+--profile; otherwise forge's own are used. Either way the project's
+FORGE.md is added to them.
+
+Examples:
+  forge review
+  forge review --range main..HEAD
+  forge review --pr 42 --post
+  forge review --json | jq '.findings[] | select(.severity == "critical")'`,
+	Args:          cobra.NoArgs,
+	RunE:          runReview,
+	SilenceUsage:  true,
+	SilenceErrors: true,
+}
+
+func init() {
+	rootCmd.AddCommand(reviewCmd)
+
+	reviewCmd.Flags().IntVar(&reviewPR, "pr", 0, "Review this GitHub pull request")
+	reviewCmd.Flags().StringVar(&reviewRange, "range", "", "Review a range of commits, as A..B or A...B")
+	reviewCmd.Flags().BoolVar(&reviewJSON, "json", false, "Print the findings as JSON")
+	reviewCmd.Flags().BoolVar(&reviewPost, "post", false, "Post the findings as a comment on the pull request given by --pr")
+}
+
+// reviewDiffLimit is the most of a diff, in bytes, put in the reviewer's
+// prompt. It can read the files for the rest.
+const reviewDiffLimit = 60_000
+
+// reviewerProfile is the reviewer used when there is no profile named
+// reviewer: it may read and search files, and nothing else.
+var reviewerProfile = &agent.Profile{
+	Name: "reviewer",
+	SystemPrompt: `You are Forge Reviewer, a careful senior engineer reviewing a change.
+Look for bugs, security problems, race conditions, resource leaks, missing
+error handling, and missing tests first; then for unclear code and
+departures from the surrounding code's conventions. Read the files the
+diff touches, and the code that calls them, when the diff alone doesn't
+show whether something is a problem. Don't report what is fine, and don't
+restate what the change does.`,
+	Tools:   []string{"file_read", "file_list", "file_grep", "file_stat", "dir_tree", "grep", "glob"},
+	MaxIter: 15,
+}
+
+// reviewFormat tells the reviewer how to reply, whichever profile it runs
+// with.
+const reviewFormat = `When you have finished reviewing, reply with a JSON object alone, with
+no code fences or text around it, of this form:
+
+{"summary": "one or two sentences on the change and its overall state",
+ "findings": [{"severity": "critical|major|minor|nit",
+               "file": "path as it appears in the diff",
+               "line": 42,
+               "message": "what is wrong and why it matters",
+               "suggestion": "what to do instead"}]}
+
+critical is for bugs that lose data, crash, or open security holes; major
+for other bugs and missing error handling; minor for maintainability; nit
+for style. line is a line of the new version of the file, or 0 if the
+finding isn't about one line. findings is empty if nothing needs fixing.`
+
+// reviewSeverities are the severities of findings, most severe first.
+var reviewSeverities = []string{"critical", "major", "minor", "nit"}
+
+type reviewFinding struct {
+	Severity   string `json:"severity"`
+	File       string `json:"file"`
+	Line       int    `json:"line,omitempty"`
+	Message    string `json:"message"`
+	Suggestion string `json:"suggestion,omitempty"`
+}
+
+type reviewResult struct {
+	Target   string          `json:"target"`
+	Summary  string          `json:"summary"`
+	Findings []reviewFinding `json:"findings"`
+	// Comment is the URL of the comment the findings were posted as.
+	Comment string `json:"comment,omitempty"`
+}
+
+func runReview(cmd *cobra.Command, args []string) error {
+	switch {
+	case reviewPR != 0 && reviewRange != "":
+		return &exitError{exitUsage, errors.New("--pr and --range can't be used together")}
+	case reviewPost && reviewPR == 0:
+		return &exitError{exitUsage, errors.New("--post needs --pr")}
+	case reviewRange != "" && !strings.Contains(reviewRange, ".."):
+		return &exitError{exitUsage, fmt.Errorf("--range must be A..B or A...B, not %q", reviewRange)}
+	}
+	cfg, err := config.Load()
+	if err != nil {
+		return &exitError{exitUsage, fmt.Errorf("loading config: %w", err)}
+	}
+	if profileFlag == "" {
+		if _, err := os.Stat(cfg.ProfilePath("reviewer")); err == nil {
+			profileFlag = "reviewer"
+		}
+	}
+	setup, err := resolveAgent(cfg, false)
+	if err != nil {
+		return &exitError{exitUsage, err}
+	}
+	if setup.profile == nil {
+		setup.profile = reviewerProfile
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	registry := tools.NewRegistry()
+	defer registry.Close()
+	for name, toolCfg := range cfg.Tools {
+		if err := registry.Register(name, toolCfg); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to start tool server %s: %v\n", name, err)
+		}
+	}
+
+	var target, diff, about string
+	switch {
+	case reviewPR != 0:
+		target = fmt.Sprintf("pull request #%d", reviewPR)
+		if about, err = callGitHub(ctx, registry, "github_view_pr", map[string]any{"number": reviewPR}); err == nil {
+			diff, err = callGitHub(ctx, registry, "github_pr_diff", map[string]any{"number": reviewPR})
+		}
+	case reviewRange != "":
+		target = "commits " + reviewRange
+		diff, err = git("diff", "--no-color", "--no-ext-diff", reviewRange)
+	default:
+		target = "the working tree"
+		diff, err = git("diff", "--no-color", "--no-ext-diff", "HEAD")
+	}
+	if err != nil {
+		return &exitError{exitFailed, err}
+	}
+	if strings.TrimSpace(diff) == "" {
+		return &exitError{exitFailed, errors.New("nothing to review: the diff is empty")}
+	}
+
+	a := setup.newAgent(registry)
+	a.AppendSystemPrompt(reviewFormat)
+	a.OnToolCall = func(name string, args map[string]any) {
+		fmt.Fprintf(os.Stderr, "  \033[33m⚡ Tool: %s\033[0m\n", agent.FormatToolCall(name, args))
+	}
+	fmt.Fprintf(os.Stderr, "Reviewing %s with %s...\n", target, setup.model)
+
+	result, err := runReviewer(ctx, a, reviewPrompt(target, about, diff))
+	if err != nil {
+		if ctx.Err() != nil {
+			return &exitError{exitInterrupted, errors.New("interrupted")}
+		}
+		return &exitError{exitFailed, err}
+	}
+	result.Target = target
+	return finishReview(ctx, registry, result)
+}
+
+// runReviewer has the agent review the diff in prompt and returns its findings.
+// A reviewer that doesn't reply in the expected form is asked once more.
+func runReviewer(ctx context.Context, a *agent.Agent, prompt string) (*reviewResult, error) {
+	reply, err := a.Run(ctx, prompt)
+	if err != nil {
+		return nil, err
+	}
+	result, err := parseReview(reply)
+	if err == nil {
+		return result, nil
+	}
+	if reply, err = a.Run(ctx, "That wasn't the JSON object asked for. Reply with the JSON object alone."); err != nil {
+		return nil, err
+	}
+	if result, err = parseReview(reply); err != nil {
+		fmt.Fprintln(os.Stderr, reply)
+		return nil, fmt.Errorf("the reviewer's reply isn't in the expected form: %w", err)
+	}
+	return result, nil
+}
+
+// finishReview posts the findings, if asked to, and prints them.
+func finishReview(ctx context.Context, registry *tools.Registry, result *reviewResult) error {
+	if reviewPost {
+		out, err := callGitHub(ctx, registry, "github_comment", map[string]any{
+			"number": reviewPR,
+			"body":   reviewMarkdown(result),
+		})
+		if err != nil {
+			return &exitError{exitFailed, fmt.Errorf("posting the review: %w", err)}
+		}
+		var comment struct {
+			URL string `json:"url"`
+		}
+		json.Unmarshal([]byte(out), &comment)
+		result.Comment = comment.URL
+	}
+
+	if reviewJSON {
+		return printJSON(result)
+	}
+	if result.Summary != "" {
+		fmt.Printf("\n%s\n", result.Summary)
+	}
+	counts := map[string]int{}
+	for _, f := range result.Findings {
+		counts[f.Severity]++
+		fmt.Printf("\n%s %s\n", severityLabel(f.Severity), f.location())
+		fmt.Printf("  %s\n", f.Message)
+		if f.Suggestion != "" {
+			fmt.Printf("  \033[36m→ %s\033[0m\n", f.Suggestion)
+		}
+	}
+	fmt.Println()
+	if len(result.Findings) == 0 {
+		fmt.Println("No findings.")
+	} else {
+		var parts []string
+		for _, severity := range reviewSeverities {
+			if counts[severity] > 0 {
+				parts = append(parts, fmt.Sprintf("%d %s", counts[severity], severity))
+			}
+		}
+		fmt.Printf("%s: %s.\n", plural(len(result.Findings), "finding"), strings.Join(parts, ", "))
+	}
+	if result.Comment != "" {
+		fmt.Printf("Posted: %s\n", result.Comment)
+	}
+	return nil
+}
+
+// reviewPrompt returns the message asking for a review of diff, with what
+// is known about the change it comes from.
+func reviewPrompt(target, about, diff string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Review the changes in %s.\n\n", target)
+	if about != "" {
+		fmt.Fprintf(&b, "About the pull request:\n%s\n\n", about)
+		b.WriteString("Files you read are from the local checkout, which may not be at the pull request's head; trust the diff where they differ.\n\n")
+	}
+	if len(diff) > reviewDiffLimit {
+		diff = diff[:reviewDiffLimit] + "\n[diff truncated; read the files for the rest]\n"
+	}
+	fmt.Fprintf(&b, "Diff:\n%s", diff)
+	return b.String()
+}
+
+// parseReview reads the reviewer's reply, tolerating text or code fences
+// around the JSON object, and sorts the findings by severity, file, and
+// line.
+func parseReview(reply string) (*reviewResult, error) {
+	start, end := strings.Index(reply, "{"), strings.LastIndex(reply, "}")
+	if start < 0 || end < start {
+		return nil, errors.New("no JSON object")
+	}
+	var result reviewResult
+	if err := json.Unmarshal([]byte(reply[start:end+1]), &result); err != nil {
+		return nil, err
+	}
+	if result.Findings == nil {
+		result.Findings = []reviewFinding{}
+	}
+	for i, f := range result.Findings {
+		f.Severity = strings.ToLower(strings.TrimSpace(f.Severity))
+		if !slices.Contains(reviewSeverities, f.Severity) {
+			f.Severity = "minor"
+		}
+		result.Findings[i] = f
+	}
+	slices.SortStableFunc(result.Findings, func(a, b reviewFinding) int {
+		if d := slices.Index(reviewSeverities, a.Severity) - slices.Index(reviewSeverities, b.Severity); d != 0 {
+			return d
+		}
+		if c := strings.Compare(a.File, b.File); c != 0 {
+			return c
+		}
+		return a.Line - b.Line
+	})
+	return &result, nil
+}
+
+// location returns where a finding is, as file:line.
+func (f reviewFinding) location() string {
+	switch {
+	case f.File == "":
+		return "(general)"
+	case f.Line > 0:
+		return f.File + ":" + strconv.Itoa(f.Line)
+	default:
+		return f.File
+	}
+}
+
+// severityLabel returns severity in brackets, colored by how severe it is.
+func severityLabel(severity string) string {
+	color := "90"
+	switch severity {
+	case "critical", "major":
+		color = "31"
+	case "minor":
+		color = "33"
+	}
+	return fmt.Sprintf("\033[%sm[%s]\033[0m", color, severity)
+}
+
+// reviewMarkdown returns the findings as the body of a GitHub comment.
+func reviewMarkdown(result *reviewResult) string {
+	var b strings.Builder
+	b.WriteString("### Forge review\n\n")
+	if result.Summary != "" {
+		fmt.Fprintf(&b, "%s\n\n", result.Summary)
+	}
+	if len(result.Findings) == 0 {
+		b.WriteString("No findings.\n")
+		return b.String()
+	}
+	for _, f := range result.Findings {
+		if f.File != "" {
+			fmt.Fprintf(&b, "- **%s** `%s`: %s\n", f.Severity, f.location(), f.Message)
+		} else {
+			fmt.Fprintf(&b, "- **%s**: %s\n", f.Severity, f.Message)
+		}
+		if f.Suggestion != "" {
+			fmt.Fprintf(&b, "  *Suggestion:* %s\n", f.Suggestion)
+		}
+	}
+	return b.String()
+}
+
+// callGitHub calls a github-ops tool, turning an error result into an
+// error.
+func callGitHub(ctx context.Context, registry *tools.Registry, tool string, args map[string]any) (string, error) {
+	out, err := registry.CallTool(ctx, tool, args)
+	switch {
+	case err != nil && strings.HasPrefix(err.Error(), "unknown tool"):
+		return "", fmt.Errorf("%s isn't available: enable the github-ops tool server", tool)
+	case err != nil:
+		return "", err
+	case strings.HasPrefix(out, "error: "):
+		// Tool servers' own error text starts with "error: " as well.
+		msg := strings.TrimPrefix(strings.TrimPrefix(out, "error: "), "error: ")
+		return "", fmt.Errorf("%s: %s", tool, msg)
+	}
+	return out, nil
+}
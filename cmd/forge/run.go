@@ -0,0 +1,255 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/spf13/cobra"
+
+	"github.com/michaelbrown/forge/internal/agent"
+	"github.com/michaelbrown/forge/internal/config"
+	"github.com/michaelbrown/forge/internal/mention"
+	"github.com/michaelbrown/forge/internal/storage"
+	"github.com/michaelbrown/forge/internal/storage/sqlite"
+	"github.com/michaelbrown/forge/internal/tools"
+)
+
+// Exit codes for forge run, besides 0 for an answer.
+const (
+	exitFailed      = 1   // the agent or its model failed
+	exitUsage       = 2   // no prompt, or bad flags or config
+	exitTimeout     = 124 // --timeout ran out, as with timeout(1)
+	exitInterrupted = 130 // SIGINT or SIGTERM
+)
+
+var (
+	runJSON    bool
+	runTimeout time.Duration
+	runApprove bool
+	runVerbose bool
+)
+
+var runCmd = &cobra.Command{
+	Use:   "run [prompt]",
+	Short: "Run the agent once on a prompt and print its answer",
+	Long: `Run the agent on a prompt without a chat: it uses tools as it needs to,
+prints its final answer to stdout, and exits. Input piped to stdin is added
+to the prompt, or is the prompt when none is given. The run is saved as a
+session, which forge sessions show and forge chat --resume can continue.
+
+Tool servers that ask for approval are refused unless --approve is given.
+
+Exit codes: 0 when the agent answered, 1 when the run failed, 2 for bad
+usage, 124 when --timeout ran out, and 130 when interrupted.
+
+Examples:
+  forge run "summarize README.md"
+  cat err.log | forge run "explain this"
+  forge run --json --timeout 5m "list the open TODOs" | jq .answer`,
+	RunE:          runRun,
+	SilenceUsage:  true,
+	SilenceErrors: true,
+}
+
+func init() {
+	rootCmd.AddCommand(runCmd)
+
+	runCmd.Flags().BoolVar(&runJSON, "json", false, "Print the answer, session, and every tool call as JSON")
+	runCmd.Flags().DurationVar(&runTimeout, "timeout", 0, "Stop the run after this long (e.g. 5m); default is no limit")
+	runCmd.Flags().BoolVar(&runApprove, "approve", false, "Approve every action tool servers ask about instead of refusing")
+	runCmd.Flags().BoolVarP(&runVerbose, "verbose", "v", false, "Show tool calls on stderr as they happen")
+}
+
+// exitError makes forge exit with code after printing err, if any.
+type exitError struct {
+	code int
+	err  error
+}
+
+func (e *exitError) Error() string {
+	if e.err == nil {
+		return fmt.Sprintf("exit status %d", e.code)
+	}
+	return e.err.Error()
+}
+
+func (e *exitError) Unwrap() error { return e.err }
+
+// runOutput is what forge run --json prints.
+type runOutput struct {
+	SessionID string        `json:"session_id"`
+	Answer    string        `json:"answer"`
+	Error     string        `json:"error,omitempty"`
+	ToolCalls []runToolCall `json:"tool_calls"`
+}
+
+type runToolCall struct {
+	Name   string         `json:"name"`
+	Args   map[string]any `json:"args"`
+	Result string         `json:"result"`
+}
+
+func runRun(cmd *cobra.Command, args []string) error {
+	prompt, err := runPrompt(args, os.Stdin)
+	if err != nil {
+		return &exitError{exitUsage, err}
+	}
+	cfg, err := config.Load()
+	if err != nil {
+		return &exitError{exitUsage, fmt.Errorf("loading config: %w", err)}
+	}
+	setup, err := resolveAgent(cfg, false)
+	if err != nil {
+		return &exitError{exitUsage, err}
+	}
+
+	store, err := sqlite.Open(cfg.Storage.DBPath)
+	if err != nil {
+		return fmt.Errorf("opening storage: %w", err)
+	}
+	defer store.Close()
+
+	registry := startTools(cfg, store, os.Stderr)
+	defer registry.Close()
+	registry.SetApprover(func(ctx context.Context, server, message string) (bool, error) {
+		if runApprove {
+			return true, nil
+		}
+		fmt.Fprintf(os.Stderr, "%s asked for approval, refused (use --approve to allow):\n  %s\n", server, strings.ReplaceAll(message, "\n", "\n  "))
+		return false, nil
+	})
+
+	a := setup.newAgent(registry)
+	out := runOutput{ToolCalls: []runToolCall{}}
+	a.OnToolCall = func(name string, args map[string]any) {
+		out.ToolCalls = append(out.ToolCalls, runToolCall{Name: name, Args: args})
+		if runVerbose {
+			fmt.Fprintf(os.Stderr, "tool: %s\n", agent.FormatToolCall(name, args))
+		}
+	}
+	a.OnToolResult = func(name string, result string) {
+		if n := len(out.ToolCalls); n > 0 {
+			out.ToolCalls[n-1].Result = result
+		}
+	}
+
+	sess, err := newRunSession(store, setup, prompt, nil)
+	if err != nil {
+		return err
+	}
+	out.SessionID = sess.ID
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if runTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, runTimeout)
+		defer cancel()
+	}
+	answer, runErr := a.Run(tools.WithSession(ctx, sess.ID), prompt)
+	out.Answer = answer
+	saveRunSession(store, sess, a, runErr)
+
+	code := 0
+	switch {
+	case runErr == nil:
+	case errors.Is(ctx.Err(), context.DeadlineExceeded):
+		code, runErr = exitTimeout, fmt.Errorf("timed out after %s", runTimeout)
+	case ctx.Err() != nil:
+		code, runErr = exitInterrupted, errors.New("interrupted")
+	default:
+		code = exitFailed
+	}
+
+	if runJSON {
+		if runErr != nil {
+			out.Error = runErr.Error()
+		}
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(out); err != nil {
+			return err
+		}
+		if code != 0 {
+			return &exitError{code: code}
+		}
+		return nil
+	}
+	if runErr != nil {
+		return &exitError{code, runErr}
+	}
+	fmt.Println(strings.TrimSpace(answer))
+	return nil
+}
+
+// newRunSession creates the session a run of the agent on prompt, outside
+// a chat, is saved as.
+func newRunSession(store storage.Store, setup *agentSetup, prompt string, tags []string) (*storage.Session, error) {
+	sess := &storage.Session{
+		ID:       uuid.New().String(),
+		Title:    generateTitle(strings.SplitN(prompt, "\n", 2)[0]),
+		Status:   storage.StatusActive,
+		Provider: setup.providerName,
+		Model:    setup.model,
+		Profile:  profileFlag,
+		Tags:     tags,
+	}
+	if err := store.CreateSession(context.Background(), sess); err != nil {
+		return nil, fmt.Errorf("creating session: %w", err)
+	}
+	return sess, nil
+}
+
+// saveRunSession saves the agent's history to sess, which is marked
+// completed, or failed if runErr isn't nil.
+func saveRunSession(store storage.Store, sess *storage.Session, a *agent.Agent, runErr error) {
+	if err := storage.SaveHistory(context.Background(), store, sess.ID, a); err != nil {
+		fmt.Fprintf(os.Stderr, "warning: failed to save session: %v\n", err)
+	}
+	sess.Status = storage.StatusCompleted
+	if runErr != nil {
+		sess.Status = storage.StatusFailed
+	}
+	store.UpdateSession(context.Background(), sess)
+}
+
+// runPrompt builds the prompt from the arguments, with their @path and
+// @url references expanded, and, when it is piped rather than a terminal,
+// stdin.
+func runPrompt(args []string, stdin *os.File) (string, error) {
+	prompt, refs, err := mention.Expand(context.Background(), strings.TrimSpace(strings.Join(args, " ")), mention.Options{})
+	if err != nil {
+		return "", err
+	}
+	if runVerbose {
+		for _, ref := range refs {
+			fmt.Fprintf(os.Stderr, "attached: %s\n", describeReference(ref))
+		}
+	}
+	if info, err := stdin.Stat(); err == nil && info.Mode()&os.ModeCharDevice == 0 {
+		data, err := io.ReadAll(stdin)
+		if err != nil {
+			return "", fmt.Errorf("reading stdin: %w", err)
+		}
+		if input := strings.TrimSpace(string(data)); input != "" {
+			if prompt == "" {
+				prompt = input
+			} else {
+				prompt += "\n\n```\n" + input + "\n```"
+			}
+		}
+	}
+	if prompt == "" {
+		return "", errors.New("no prompt: give one as an argument or pipe it to stdin")
+	}
+	return prompt, nil
+}
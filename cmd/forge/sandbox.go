@@ -0,0 +1,64 @@
+package main
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/michaelbrown/forge/internal/config"
+)
+
+var sandboxServer string
+
+var sandboxCmd = &cobra.Command{
+	Use:   "sandbox",
+	Short: "Manage the code-runner's sandboxes",
+}
+
+var sandboxPullCmd = &cobra.Command{
+	Use:   "pull [language...]",
+	Short: "Pull the Docker images code_run uses",
+	Long: `Pull the images of the given languages, or of every language, so code_run
+doesn't stall on a first run while one downloads. Languages and images are the
+code-runner's, including any set in its env in forge.yaml. Images already
+present are left alone.
+
+Examples:
+  forge sandbox pull
+  forge sandbox pull python go`,
+	RunE: runSandboxPull,
+}
+
+func init() {
+	rootCmd.AddCommand(sandboxCmd)
+	sandboxCmd.AddCommand(sandboxPullCmd)
+
+	sandboxPullCmd.Flags().StringVar(&sandboxServer, "server", "code-runner", "Tool server in forge.yaml that runs code")
+}
+
+// runSandboxPull has the code-runner pull its images, so they match what
+// it runs with its configured env.
+func runSandboxPull(cmd *cobra.Command, args []string) error {
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("loading config: %w", err)
+	}
+	server, ok := cfg.Tools[sandboxServer]
+	if !ok || server.Binary == "" || strings.HasSuffix(server.Binary, ".wasm") {
+		return fmt.Errorf("no tool server %q with a binary in forge.yaml", sandboxServer)
+	}
+
+	pull := exec.CommandContext(cmd.Context(), server.Binary, append([]string{"pull"}, args...)...)
+	pull.Env = server.Environ()
+	pull.Stdout = cmd.OutOrStdout()
+	pull.Stderr = cmd.ErrOrStderr()
+	if err := pull.Run(); err != nil {
+		if _, exited := err.(*exec.ExitError); exited {
+			return fmt.Errorf("pulling images failed")
+		}
+		return fmt.Errorf("running %s: %w", server.Binary, err)
+	}
+	return nil
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/spf13/cobra"
+)
+
+func TestSandboxPull(t *testing.T) {
+	root, _ := filepath.Abs(filepath.Join("..", ".."))
+	bin := filepath.Join(root, "bin", "forge-tool-code-runner")
+	if _, err := os.Stat(bin); err != nil {
+		t.Skipf("%s not found (run make build-tools first)", bin)
+	}
+
+	// A fake docker that logs its arguments, with python's image present
+	// and the rest pulled when asked.
+	dir := t.TempDir()
+	log := filepath.Join(dir, "docker.log")
+	script := `#!/bin/sh
+echo "$*" >> "$FAKE_DOCKER_LOG"
+case "$1" in
+image) [ "$5" = python:3.12-slim ] ;;
+pull) [ "$3" != golang:1.23-alpine ] ;;
+esac
+`
+	if err := os.WriteFile(filepath.Join(dir, "docker"), []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+	t.Setenv("FAKE_DOCKER_LOG", log)
+	t.Setenv("HOME", t.TempDir())
+	work := t.TempDir()
+	t.Chdir(work)
+	config := "tools:\n  code-runner:\n    binary: " + bin + "\n    enabled: true\n    env:\n      FORGE_CODE_POLICY: \"ruby: {image: ruby:3.4-slim}\"\n"
+	if err := os.WriteFile(filepath.Join(work, "forge.yaml"), []byte(config), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	pull := func(args ...string) (string, error) {
+		t.Helper()
+		os.Remove(log)
+		var out bytes.Buffer
+		cmd := &cobra.Command{}
+		cmd.SetContext(context.Background())
+		cmd.SetOut(&out)
+		cmd.SetErr(&out)
+		err := runSandboxPull(cmd, args)
+		return out.String(), err
+	}
+
+	// Present images are left alone; the rest are pulled once each, in
+	// the images the server's env gives.
+	out, err := pull("python", "ruby")
+	if err != nil {
+		t.Fatalf("pull = %v\n%s", err, out)
+	}
+	for _, want := range []string{"python:3.12-slim: present\n", "ruby:3.4-slim: pulling...\n", "ruby:3.4-slim: pulled in "} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output missing %q:\n%s", want, out)
+		}
+	}
+	logged, _ := os.ReadFile(log)
+	if strings.Count(string(logged), "pull --quiet") != 1 || !strings.Contains(string(logged), "pull --quiet ruby:3.4-slim\n") {
+		t.Errorf("docker log:\n%s", logged)
+	}
+
+	// A failed pull is reported and fails the command.
+	out, err = pull("go")
+	if err == nil || !strings.Contains(out, "golang:1.23-alpine: pulling golang:1.23-alpine:") || !strings.Contains(out, "1 of 1 images could not be pulled") {
+		t.Errorf("pull go = %v\n%s", err, out)
+	}
+
+	if _, err := pull("cobol"); err == nil {
+		t.Error("pull cobol succeeded")
+	}
+	sandboxServer = "missing"
+	defer func() { sandboxServer = "code-runner" }()
+	if _, err := pull(); err == nil || !strings.Contains(err.Error(), `no tool server "missing"`) {
+		t.Errorf("pull with no such server = %v", err)
+	}
+}
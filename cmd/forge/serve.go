@@ -1,22 +1,42 @@
 package main
 
 import (
+	"bufio"
 	"context"
+	"errors"
 	"fmt"
-	"log"
+	"io"
+	"log/slog"
+	"net"
 	"os"
 	"os/signal"
+	"path/filepath"
+	"slices"
+	"strings"
 	"syscall"
+	"time"
 
 	"github.com/spf13/cobra"
 
 	"github.com/michaelbrown/forge/internal/config"
+	"github.com/michaelbrown/forge/internal/daemon"
+	"github.com/michaelbrown/forge/internal/logging"
 	"github.com/michaelbrown/forge/internal/server"
+	"github.com/michaelbrown/forge/internal/storage"
 	"github.com/michaelbrown/forge/internal/storage/sqlite"
 	"github.com/michaelbrown/forge/internal/tools"
 )
 
-var portFlag int
+var (
+	portFlag     int
+	listenFlag   string
+	logLevelFlag string
+	daemonFlag   bool
+	daemonized   bool // set in the daemon's own process
+
+	logsLines  int
+	logsFollow bool
+)
 
 var serveCmd = &cobra.Command{
 	Use:   "serve",
@@ -25,14 +45,59 @@ var serveCmd = &cobra.Command{
 
 The web UI is available at the root URL. API endpoints are under /api.
 
+With --daemon, the server runs in the background, logging to forge.log
+beside the database; forge serve status, stop, and logs look after it,
+and forge chat --attach talks to it.
+
 Examples:
   forge serve
-  forge serve --port 9090`,
-	RunE: runServe,
+  forge serve --port 9090
+  forge serve --listen unix:///run/forge/forge.sock
+  forge serve --daemon`,
+	RunE:          runServe,
+	SilenceUsage:  true,
+	SilenceErrors: true,
+}
+
+var serveStatusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Show whether a forge serve --daemon server is running",
+	Long: `Show the process ID, address, and log file of the server forge serve
+--daemon started. Exits with status 1 if none is running.`,
+	Args:          cobra.NoArgs,
+	RunE:          runServeStatus,
+	SilenceUsage:  true,
+	SilenceErrors: true,
+}
+
+var serveStopCmd = &cobra.Command{
+	Use:           "stop",
+	Short:         "Stop the forge serve --daemon server",
+	Args:          cobra.NoArgs,
+	RunE:          runServeStop,
+	SilenceUsage:  true,
+	SilenceErrors: true,
+}
+
+var serveLogsCmd = &cobra.Command{
+	Use:           "logs",
+	Short:         "Show the forge serve --daemon server's log",
+	Args:          cobra.NoArgs,
+	RunE:          runServeLogs,
+	SilenceUsage:  true,
+	SilenceErrors: true,
 }
 
 func init() {
 	serveCmd.Flags().IntVar(&portFlag, "port", 0, "Port to listen on (overrides config)")
+	serveCmd.Flags().StringVar(&listenFlag, "listen", "", "Address or unix:///path socket to listen on (overrides config)")
+	serveCmd.Flags().StringVar(&logLevelFlag, "log-level", "", "Minimum log level: debug, info, warn, or error (overrides config)")
+	serveCmd.Flags().BoolVar(&daemonFlag, "daemon", false, "Run in the background, logging to forge.log")
+	serveCmd.Flags().BoolVar(&daemonized, "daemonized", false, "")
+	serveCmd.Flags().MarkHidden("daemonized")
+	serveLogsCmd.Flags().IntVarP(&logsLines, "lines", "n", 50, "Number of lines to show from the end")
+	serveLogsCmd.Flags().BoolVarP(&logsFollow, "follow", "f", false, "Keep printing lines as they are written")
+	serveCmd.AddCommand(serveStatusCmd, serveStopCmd, serveLogsCmd)
 	rootCmd.AddCommand(serveCmd)
 }
 
@@ -41,6 +106,16 @@ func runServe(cmd *cobra.Command, args []string) error {
 	if err != nil {
 		return fmt.Errorf("loading config: %w", err)
 	}
+	addr := serveAddr(cfg)
+	if daemonFlag {
+		return startDaemon(cfg, addr)
+	}
+
+	logger, err := newLogger(cfg)
+	if err != nil {
+		return fmt.Errorf("configuring logs: %w", err)
+	}
+	slog.SetDefault(logger)
 
 	// Open storage
 	store, err := sqlite.Open(cfg.Storage.DBPath)
@@ -52,27 +127,31 @@ func runServe(cmd *cobra.Command, args []string) error {
 	// Create tool registry
 	registry := tools.NewRegistry()
 	defer registry.Close()
+	recordToolCalls(registry, store)
+	registry.SetArtifactSaver(storage.NewArtifacts(store, cfg.Storage.ArtifactsDir).Saver())
 
 	for name, toolCfg := range cfg.Tools {
 		if err := registry.Register(name, toolCfg); err != nil {
-			log.Printf("Warning: failed to start tool server %s: %v", name, err)
+			logging.For("tools").Warn("failed to start tool server", "server", name, "error", err)
 		}
 	}
 
 	if registry.HasTools() {
-		log.Println("Tools: MCP servers loaded")
+		logging.For("tools").Info("MCP servers loaded")
 	} else {
-		log.Println("Tools: builtin shell_exec")
-	}
-
-	// Determine port
-	port := cfg.Server.Port
-	if portFlag > 0 {
-		port = portFlag
+		logging.For("tools").Info("using the built-in pack (no MCP servers configured)")
 	}
 
 	// Create and start server
 	srv := server.New(cfg, store, registry)
+	srv.OnReload(func(cfg *config.Config) {
+		logger, err := newLogger(cfg)
+		if err != nil {
+			logging.For("server").Warn("keeping the old log settings", "error", err)
+			return
+		}
+		slog.SetDefault(logger)
+	})
 
 	// Graceful shutdown on SIGINT/SIGTERM
 	sigCh := make(chan os.Signal, 1)
@@ -83,5 +162,232 @@ func runServe(cmd *cobra.Command, args []string) error {
 		srv.Shutdown(context.Background())
 	}()
 
-	return srv.Start(port)
+	if daemonized {
+		dir := daemonDir(cfg)
+		err := daemon.Write(dir, daemon.State{
+			PID:     os.Getpid(),
+			Addr:    addr,
+			TLS:     serveTLS(cfg),
+			Log:     daemon.LogPath(dir),
+			Started: time.Now(),
+		})
+		if err != nil {
+			return fmt.Errorf("writing daemon state: %w", err)
+		}
+		defer daemon.Remove(dir, os.Getpid())
+	}
+	return srv.Start(addr)
+}
+
+// serveAddr returns where forge serve listens, as the config and the
+// --listen and --port flags say.
+func serveAddr(cfg *config.Config) string {
+	switch {
+	case listenFlag != "":
+		return listenFlag
+	case portFlag > 0:
+		return fmt.Sprintf(":%d", portFlag)
+	case cfg.Server.Listen != "":
+		return cfg.Server.Listen
+	}
+	return fmt.Sprintf(":%d", cfg.Server.Port)
+}
+
+// serveTLS reports whether server.tls has the server use TLS.
+func serveTLS(cfg *config.Config) bool {
+	return cfg.Server.TLS.CertFile != "" || len(cfg.Server.TLS.Autocert.Domains) > 0
+}
+
+// daemonDir returns the directory the daemon's state and log files go in:
+// the database's, so there is one daemon per database.
+func daemonDir(cfg *config.Config) string {
+	return filepath.Dir(cfg.Storage.DBPath)
+}
+
+// localURL returns the URL of a server listening on addr, as reached from
+// this machine.
+func localURL(addr string, tls bool) string {
+	if strings.HasPrefix(addr, "unix://") {
+		return addr
+	}
+	scheme := "http"
+	if tls {
+		scheme = "https"
+	}
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return scheme + "://" + addr
+	}
+	if host == "" || host == "0.0.0.0" || host == "::" {
+		host = "localhost"
+	}
+	return scheme + "://" + net.JoinHostPort(host, port)
+}
+
+// startDaemon runs this forge serve again in the background and waits
+// until it is listening on addr.
+func startDaemon(cfg *config.Config, addr string) error {
+	dir := daemonDir(cfg)
+	if st, err := daemon.Read(dir); err == nil {
+		return &exitError{exitFailed, fmt.Errorf("a forge server is already running in the background (pid %d) at %s; stop it with forge serve stop", st.PID, localURL(st.Addr, st.TLS))}
+	}
+	exe, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	argv := []string{exe}
+	for _, arg := range os.Args[1:] {
+		if arg != "--daemon" && !strings.HasPrefix(arg, "--daemon=") {
+			argv = append(argv, arg)
+		}
+	}
+	argv = append(argv, "--daemonized")
+
+	// Only what this start adds to the log is shown if it fails.
+	var offset int64
+	if info, err := os.Stat(daemon.LogPath(dir)); err == nil {
+		offset = info.Size()
+	}
+	cmd, err := daemon.Start(dir, argv)
+	if err != nil {
+		return fmt.Errorf("starting the server: %w", err)
+	}
+	exited := make(chan struct{})
+	go func() {
+		cmd.Wait()
+		close(exited)
+	}()
+
+	network, address := "tcp", addr
+	if path, ok := strings.CutPrefix(addr, "unix://"); ok {
+		network, address = "unix", path
+	} else if host, port, err := net.SplitHostPort(addr); err == nil && (host == "" || host == "0.0.0.0" || host == "::") {
+		address = net.JoinHostPort("localhost", port)
+	}
+	for deadline := time.Now().Add(15 * time.Second); time.Now().Before(deadline); {
+		select {
+		case <-exited:
+			out, _ := readLogFrom(daemon.LogPath(dir), offset)
+			return &exitError{exitFailed, fmt.Errorf("the server exited:\n%s", strings.TrimSpace(out))}
+		case <-time.After(100 * time.Millisecond):
+		}
+		if conn, err := net.DialTimeout(network, address, time.Second); err == nil {
+			conn.Close()
+			fmt.Printf("Forge server running in the background (pid %d) at %s\n", cmd.Process.Pid, localURL(addr, serveTLS(cfg)))
+			fmt.Printf("Logs: %s\n", daemon.LogPath(dir))
+			fmt.Println("Stop it with: forge serve stop")
+			return nil
+		}
+	}
+	return &exitError{exitFailed, fmt.Errorf("the server (pid %d) isn't listening on %s after 15s; see %s", cmd.Process.Pid, addr, daemon.LogPath(dir))}
+}
+
+// readLogFrom returns the log file at path from offset on.
+func readLogFrom(path string, offset int64) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		return "", err
+	}
+	data, err := io.ReadAll(f)
+	return string(data), err
+}
+
+func runServeStatus(cmd *cobra.Command, args []string) error {
+	cfg, err := config.Load()
+	if err != nil {
+		return &exitError{exitUsage, fmt.Errorf("loading config: %w", err)}
+	}
+	st, err := daemon.Read(daemonDir(cfg))
+	if errors.Is(err, daemon.ErrNotRunning) {
+		fmt.Println("No forge server is running in the background.")
+		return &exitError{code: exitFailed}
+	}
+	if err != nil {
+		return &exitError{exitFailed, err}
+	}
+	fmt.Printf("Running (pid %d) at %s\n", st.PID, localURL(st.Addr, st.TLS))
+	fmt.Printf("Started: %s (%s)\n", st.Started.Local().Format("2006-01-02 15:04:05"), timeAgo(st.Started))
+	fmt.Printf("Logs: %s\n", st.Log)
+	return nil
+}
+
+func runServeStop(cmd *cobra.Command, args []string) error {
+	cfg, err := config.Load()
+	if err != nil {
+		return &exitError{exitUsage, fmt.Errorf("loading config: %w", err)}
+	}
+	dir := daemonDir(cfg)
+	st, err := daemon.Read(dir)
+	if err != nil {
+		return &exitError{exitFailed, err}
+	}
+	if err := daemon.Stop(st, 15*time.Second); err != nil {
+		return &exitError{exitFailed, fmt.Errorf("stopping pid %d: %w", st.PID, err)}
+	}
+	// A server that had to be killed couldn't remove its state itself.
+	daemon.Remove(dir, st.PID)
+	fmt.Printf("Stopped the forge server (pid %d).\n", st.PID)
+	return nil
+}
+
+func runServeLogs(cmd *cobra.Command, args []string) error {
+	cfg, err := config.Load()
+	if err != nil {
+		return &exitError{exitUsage, fmt.Errorf("loading config: %w", err)}
+	}
+	path := daemon.LogPath(daemonDir(cfg))
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return &exitError{exitFailed, fmt.Errorf("no log at %s; forge serve --daemon writes one", path)}
+	}
+	if err != nil {
+		return &exitError{exitFailed, err}
+	}
+	defer f.Close()
+
+	var lines []string
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(nil, 1<<20)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+		if len(lines) > logsLines {
+			lines = slices.Delete(lines, 0, 1)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return &exitError{exitFailed, err}
+	}
+	for _, line := range lines {
+		fmt.Println(line)
+	}
+	if !logsFollow {
+		return nil
+	}
+
+	// Print what is appended from here on, until interrupted.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	for {
+		if _, err := io.Copy(os.Stdout, f); err != nil {
+			return &exitError{exitFailed, err}
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(500 * time.Millisecond):
+		}
+	}
+}
+
+// newLogger returns a logger set up as cfg and --log-level say.
+func newLogger(cfg *config.Config) (*slog.Logger, error) {
+	level := cfg.Log.Level
+	if logLevelFlag != "" {
+		level = logLevelFlag
+	}
+	return logging.New(os.Stderr, logging.Options{Format: cfg.Log.Format, Level: level, Components: cfg.Log.Components})
 }
@@ -2,24 +2,41 @@ package main
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"io"
 	"os"
+	"slices"
+	"strconv"
 	"strings"
 	"time"
 
+	"github.com/google/uuid"
 	"github.com/spf13/cobra"
 
+	"github.com/michaelbrown/forge/internal/backup"
 	"github.com/michaelbrown/forge/internal/config"
+	"github.com/michaelbrown/forge/internal/llm"
 	"github.com/michaelbrown/forge/internal/storage"
 	"github.com/michaelbrown/forge/internal/storage/sqlite"
 )
 
 var (
-	statusFilter string
-	limitFlag    int
-	exportFormat string
-	exportOutput string
-	forceFlag    bool
+	statusFilter   string
+	tagFilter      string
+	providerFilter string
+	modelFilter    string
+	sinceFilter    string
+	untilFilter    string
+	jsonFlag       bool
+	allFlag        bool
+	verboseFlag    bool
+	artifactOut    string
+	removeTags     bool
+	limitFlag      int
+	exportFormat   string
+	exportOutput   string
+	forceFlag      bool
 )
 
 var sessionsCmd = &cobra.Command{
@@ -31,7 +48,16 @@ var sessionsCmd = &cobra.Command{
 var sessionsListCmd = &cobra.Command{
 	Use:   "list",
 	Short: "List saved sessions",
-	RunE:  runSessionsList,
+	Long: `List saved sessions, most recently active first. --since and --until keep
+the sessions active between them, and take a date (2026-10-01), a time
+(2026-10-01T09:00:00Z), or how long ago (90m, 12h, 7d); an --until date
+includes that day. Use --json for output to script with.
+
+Examples:
+  forge sessions list --since 7d --provider ollama
+  forge sessions list --tag billing --json | jq -r '.[].id'`,
+	Args: cobra.NoArgs,
+	RunE: runSessionsList,
 }
 
 var sessionsShowCmd = &cobra.Command{
@@ -42,12 +68,15 @@ var sessionsShowCmd = &cobra.Command{
 }
 
 var sessionsResumeCmd = &cobra.Command{
-	Use:   "resume <session-id>",
-	Short: "Resume a previous session",
-	Args:  cobra.ExactArgs(1),
+	Use:   "resume [session-id]",
+	Short: "Resume a previous session, or pick one from a list",
+	Args:  cobra.MaximumNArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
-		resumeID = args[0]
-		return runChat(cmd, args)
+		resumeID = pickFlag
+		if len(args) > 0 {
+			resumeID = args[0]
+		}
+		return runChat(cmd, nil)
 	},
 }
 
@@ -58,6 +87,77 @@ var sessionsDeleteCmd = &cobra.Command{
 	RunE:  runSessionsDelete,
 }
 
+var sessionsRevertCmd = &cobra.Command{
+	Use:   "revert <session-id>",
+	Short: "Restore files changed by a session's file tools",
+	Long: `Restore every file the agent wrote or patched in a session to its state
+before the session's first change. Files the session created are removed.`,
+	Args: cobra.ExactArgs(1),
+	RunE: runSessionsRevert,
+}
+
+var sessionsRenameCmd = &cobra.Command{
+	Use:   "rename <session-id> <title>...",
+	Short: "Change a session's title",
+	Args:  cobra.MinimumNArgs(2),
+	RunE:  runSessionsRename,
+}
+
+var sessionsTagCmd = &cobra.Command{
+	Use:   "tag <session-id> <tag>...",
+	Short: "Add tags to a session, or remove them with --remove",
+	Args:  cobra.MinimumNArgs(2),
+	RunE:  runSessionsTag,
+}
+
+var sessionsArchiveCmd = &cobra.Command{
+	Use:   "archive <session-id>...",
+	Short: "Archive sessions, hiding them from the session list",
+	Long: `Archive sessions so they're left out of "forge sessions list" unless --all
+is given. Resuming an archived session and sending a message unarchives it.`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return setSessionsArchived(args, true)
+	},
+}
+
+var sessionsUnarchiveCmd = &cobra.Command{
+	Use:   "unarchive <session-id>...",
+	Short: "Restore archived sessions",
+	Args:  cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return setSessionsArchived(args, false)
+	},
+}
+
+var sessionsImportCmd = &cobra.Command{
+	Use:   "import <file.json>",
+	Short: "Import a session exported with --format json",
+	Long: `Recreate a session from a JSON export, with its messages, tags, and
+timestamps, under a new ID. Use "-" to read the export from stdin.`,
+	Args: cobra.ExactArgs(1),
+	RunE: runSessionsImport,
+}
+
+var sessionsArtifactsCmd = &cobra.Command{
+	Use:   "artifacts <session-id> [name]",
+	Short: "List a session's saved files, or write one out",
+	Long: `List the files saved during a session, such as program outputs that
+tools returned. Give a name to write that file to stdout, or to a file with -o.`,
+	Args: cobra.RangeArgs(1, 2),
+	RunE: runSessionsArtifacts,
+}
+
+var sessionsSearchCmd = &cobra.Command{
+	Use:   "search <query>",
+	Short: "Search session titles and messages",
+	Long: `Find sessions whose title or messages contain every word of the query.
+Words match as prefixes and ignore endings, so "fixing make" finds "fixed the Makefile".
+Archived sessions are searched too. The filters are those of forge sessions list.`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: runSessionsSearch,
+}
+
 var sessionsExportCmd = &cobra.Command{
 	Use:   "export <session-id>",
 	Short: "Export a session as markdown or JSON",
@@ -67,15 +167,31 @@ var sessionsExportCmd = &cobra.Command{
 
 func init() {
 	rootCmd.AddCommand(sessionsCmd)
-	sessionsCmd.AddCommand(sessionsListCmd, sessionsShowCmd, sessionsResumeCmd, sessionsDeleteCmd, sessionsExportCmd)
-
-	sessionsListCmd.Flags().StringVar(&statusFilter, "status", "", "Filter by status (active, completed, failed, running)")
-	sessionsListCmd.Flags().IntVar(&limitFlag, "limit", 20, "Max sessions to show")
+	sessionsCmd.AddCommand(sessionsListCmd, sessionsShowCmd, sessionsResumeCmd, sessionsDeleteCmd, sessionsExportCmd, sessionsRevertCmd, sessionsSearchCmd, sessionsRenameCmd, sessionsTagCmd, sessionsArchiveCmd, sessionsUnarchiveCmd, sessionsImportCmd, sessionsArtifactsCmd)
+
+	for _, cmd := range []*cobra.Command{sessionsListCmd, sessionsSearchCmd} {
+		cmd.Flags().StringVar(&statusFilter, "status", "", "Filter by status (active, completed, failed, running, archived)")
+		cmd.Flags().StringVar(&tagFilter, "tag", "", "Only sessions with this tag")
+		cmd.Flags().StringVar(&providerFilter, "provider", "", "Only sessions with this provider")
+		cmd.Flags().StringVar(&modelFilter, "model", "", "Only sessions with this model")
+		cmd.Flags().StringVar(&sinceFilter, "since", "", "Only sessions active since this date, time, or long ago (e.g. 7d)")
+		cmd.Flags().StringVar(&untilFilter, "until", "", "Only sessions active until this date, time, or long ago")
+		cmd.Flags().BoolVar(&jsonFlag, "json", false, "Print the sessions as JSON")
+		cmd.Flags().IntVar(&limitFlag, "limit", 20, "Max sessions to show")
+	}
+	sessionsListCmd.Flags().BoolVar(&allFlag, "all", false, "Include archived sessions")
 
-	sessionsExportCmd.Flags().StringVar(&exportFormat, "format", "md", "Export format: md or json")
+	sessionsExportCmd.Flags().StringVar(&exportFormat, "format", "md", "Export format: md, json, or html")
 	sessionsExportCmd.Flags().StringVarP(&exportOutput, "output", "o", "", "Output file (default: stdout)")
 
+	sessionsShowCmd.Flags().BoolVarP(&verboseFlag, "verbose", "v", false, "Show when each message was created, by which model, and its latency and token usage")
+
+	sessionsArtifactsCmd.Flags().StringVarP(&artifactOut, "output", "o", "", "Write the artifact to this file (default: stdout)")
+
+	sessionsTagCmd.Flags().BoolVar(&removeTags, "remove", false, "Remove the tags instead of adding them")
+
 	sessionsDeleteCmd.Flags().BoolVar(&forceFlag, "force", false, "Skip confirmation")
+	sessionsRevertCmd.Flags().BoolVar(&forceFlag, "force", false, "Skip confirmation")
 }
 
 func openStore() (storage.Store, error) {
@@ -86,6 +202,25 @@ func openStore() (storage.Store, error) {
 	return sqlite.Open(cfg.Storage.DBPath)
 }
 
+// openArtifacts returns the artifact store for store's sessions.
+func openArtifacts(store storage.Store) (*storage.Artifacts, error) {
+	cfg, err := config.Load()
+	if err != nil {
+		return nil, fmt.Errorf("loading config: %w", err)
+	}
+	return storage.NewArtifacts(store, cfg.Storage.ArtifactsDir), nil
+}
+
+// shortID returns the first eight characters of a session ID, which is
+// how sessions are shown, or all of an ID shorter than that, such as one
+// an imported session brought with it.
+func shortID(id string) string {
+	if len(id) > 8 {
+		return id[:8]
+	}
+	return id
+}
+
 func runSessionsList(cmd *cobra.Command, args []string) error {
 	store, err := openStore()
 	if err != nil {
@@ -93,24 +228,31 @@ func runSessionsList(cmd *cobra.Command, args []string) error {
 	}
 	defer store.Close()
 
-	opts := storage.SessionListOptions{
-		Status: storage.SessionStatus(statusFilter),
-		Limit:  limitFlag,
+	opts, err := sessionFilterFlags()
+	if err != nil {
+		return err
 	}
+	opts.All = allFlag
 
 	sessions, err := store.ListSessions(context.Background(), opts)
 	if err != nil {
 		return err
 	}
 
+	if jsonFlag {
+		if sessions == nil {
+			sessions = []storage.Session{}
+		}
+		return printJSON(sessions)
+	}
 	if len(sessions) == 0 {
 		fmt.Println("No sessions found.")
 		return nil
 	}
 
 	// Header
-	fmt.Printf("%-10s %-12s %-40s %-15s %s\n", "ID", "STATUS", "TITLE", "MODEL", "UPDATED")
-	fmt.Println(strings.Repeat("─", 95))
+	fmt.Printf("%-10s %-12s %-40s %-15s %-16s %s\n", "ID", "STATUS", "TITLE", "MODEL", "UPDATED", "TAGS")
+	fmt.Println(strings.Repeat("─", 115))
 
 	for _, s := range sessions {
 		title := s.Title
@@ -128,13 +270,234 @@ func runSessionsList(cmd *cobra.Command, args []string) error {
 
 		age := timeAgo(s.UpdatedAt)
 
-		fmt.Printf("%-10s %-12s %-40s %-15s %s\n",
-			s.ID[:8], s.Status, title, model, age)
+		fmt.Printf("%-10s %-12s %-40s %-15s %-16s %s\n",
+			shortID(s.ID), s.Status, title, model, age, strings.Join(s.Tags, ", "))
+	}
+
+	return nil
+}
+
+// sessionFilterFlags reads the filter flags of sessions list and search.
+func sessionFilterFlags() (storage.SessionListOptions, error) {
+	opts := storage.SessionListOptions{
+		Status:   storage.SessionStatus(statusFilter),
+		Tag:      tagFilter,
+		Provider: providerFilter,
+		Model:    modelFilter,
+		Limit:    limitFlag,
+	}
+	var err error
+	if opts.Since, err = parseTimeFlag("--since", sinceFilter, false); err != nil {
+		return opts, err
+	}
+	if opts.Until, err = parseTimeFlag("--until", untilFilter, true); err != nil {
+		return opts, err
+	}
+	return opts, nil
+}
+
+// parseTimeFlag reads a time given as a date, taken in the local time zone,
+// an RFC 3339 time, or a duration before now, such as 12h or 7d. A date
+// given for the end of a range stands for the end of that day.
+func parseTimeFlag(flag, value string, end bool) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.ParseInLocation(time.DateOnly, value, time.Local); err == nil {
+		if end {
+			t = t.AddDate(0, 0, 1)
+		}
+		return t, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	if days, ok := strings.CutSuffix(value, "d"); ok {
+		if n, err := strconv.Atoi(days); err == nil && n >= 0 {
+			return time.Now().AddDate(0, 0, -n), nil
+		}
+	}
+	if d, err := time.ParseDuration(value); err == nil && d >= 0 {
+		return time.Now().Add(-d), nil
+	}
+	return time.Time{}, fmt.Errorf("invalid %s %q (want a date such as 2026-10-01, a time such as 2026-10-01T09:00:00Z, or how long ago, such as 12h or 7d)", flag, value)
+}
+
+// printJSON writes v to stdout as indented JSON.
+func printJSON(v any) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(data))
+	return nil
+}
+
+func runSessionsRename(cmd *cobra.Command, args []string) error {
+	title := strings.TrimSpace(strings.Join(args[1:], " "))
+	if title == "" {
+		return fmt.Errorf("title must not be empty")
+	}
+
+	store, err := openStore()
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	ctx := context.Background()
+	sess, err := store.GetSession(ctx, args[0])
+	if err != nil {
+		return err
+	}
+	sess.Title = title
+	if err := store.UpdateSession(ctx, sess); err != nil {
+		return err
+	}
+	fmt.Printf("Renamed session %s to %q\n", shortID(sess.ID), title)
+	return nil
+}
+
+func runSessionsTag(cmd *cobra.Command, args []string) error {
+	store, err := openStore()
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	ctx := context.Background()
+	sess, err := store.GetSession(ctx, args[0])
+	if err != nil {
+		return err
+	}
+
+	changed, err := storage.NormalizeTags(args[1:])
+	if err != nil {
+		return err
+	}
+	tags := sess.Tags
+	if removeTags {
+		tags = slices.DeleteFunc(tags, func(t string) bool { return slices.Contains(changed, t) })
+	} else {
+		tags = append(tags, changed...)
+	}
+	if tags, err = storage.NormalizeTags(tags); err != nil {
+		return err
+	}
+	if err := store.SetSessionTags(ctx, sess.ID, tags); err != nil {
+		return err
+	}
+
+	if len(tags) == 0 {
+		fmt.Printf("Session %s has no tags\n", shortID(sess.ID))
+	} else {
+		fmt.Printf("Session %s tags: %s\n", shortID(sess.ID), strings.Join(tags, ", "))
+	}
+	return nil
+}
+
+// setSessionsArchived archives sessions, or makes archived ones active again.
+func setSessionsArchived(ids []string, archived bool) error {
+	store, err := openStore()
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	ctx := context.Background()
+	for _, id := range ids {
+		sess, err := store.GetSession(ctx, id)
+		if err != nil {
+			return err
+		}
+		switch {
+		case archived && sess.Status == storage.StatusRunning:
+			return fmt.Errorf("session %s is running", shortID(sess.ID))
+		case archived && sess.Status != storage.StatusArchived:
+			sess.Status = storage.StatusArchived
+		case !archived && sess.Status == storage.StatusArchived:
+			sess.Status = storage.StatusActive
+		default:
+			fmt.Printf("Session %s is already %s\n", shortID(sess.ID), sess.Status)
+			continue
+		}
+		if err := store.UpdateSession(ctx, sess); err != nil {
+			return err
+		}
+		if archived {
+			fmt.Printf("Archived session %s\n", shortID(sess.ID))
+		} else {
+			fmt.Printf("Unarchived session %s\n", shortID(sess.ID))
+		}
+	}
+	return nil
+}
+
+func runSessionsSearch(cmd *cobra.Command, args []string) error {
+	store, err := openStore()
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	opts, err := sessionFilterFlags()
+	if err != nil {
+		return err
 	}
+	opts.All = true
 
+	results, err := store.SearchSessions(context.Background(), strings.Join(args, " "), opts)
+	if err != nil {
+		return err
+	}
+	if jsonFlag {
+		if results == nil {
+			results = []storage.SearchResult{}
+		}
+		return printJSON(results)
+	}
+	if len(results) == 0 {
+		fmt.Println("No matching sessions.")
+		return nil
+	}
+
+	for i, r := range results {
+		if i > 0 {
+			fmt.Println()
+		}
+		title := r.Title
+		if title == "" {
+			title = "(untitled)"
+		}
+		fmt.Printf("\033[33m%s\033[0m  %s  \033[90m%s\033[0m\n", shortID(r.Session.ID), boldMatches(title), timeAgo(r.Session.UpdatedAt))
+		for _, snippet := range r.Snippets {
+			fmt.Printf("    %s\n", boldMatches(snippet))
+		}
+	}
 	return nil
 }
 
+// boldMatches turns the **bold** marks around search matches into terminal
+// bold.
+func boldMatches(s string) string {
+	parts := strings.Split(s, "**")
+	var b strings.Builder
+	for i, p := range parts {
+		if i > 0 {
+			if i%2 == 1 {
+				b.WriteString("\033[1m")
+			} else {
+				b.WriteString("\033[0m")
+			}
+		}
+		b.WriteString(p)
+	}
+	if len(parts)%2 == 0 {
+		b.WriteString("\033[0m")
+	}
+	return b.String()
+}
+
 func runSessionsShow(cmd *cobra.Command, args []string) error {
 	store, err := openStore()
 	if err != nil {
@@ -156,6 +519,9 @@ func runSessionsShow(cmd *cobra.Command, args []string) error {
 	if sess.Profile != "" {
 		fmt.Printf("Profile:  %s\n", sess.Profile)
 	}
+	if len(sess.Tags) > 0 {
+		fmt.Printf("Tags:     %s\n", strings.Join(sess.Tags, ", "))
+	}
 	fmt.Printf("Created:  %s\n", sess.CreatedAt.Format(time.RFC3339))
 	fmt.Printf("Updated:  %s\n", sess.UpdatedAt.Format(time.RFC3339))
 
@@ -167,6 +533,7 @@ func runSessionsShow(cmd *cobra.Command, args []string) error {
 	fmt.Printf("\nMessages: %d\n", len(messages))
 	fmt.Println(strings.Repeat("─", 60))
 
+	var prompt, completion int
 	for _, m := range messages {
 		switch m.Role {
 		case "system":
@@ -183,11 +550,35 @@ func runSessionsShow(cmd *cobra.Command, args []string) error {
 		case "tool":
 			fmt.Printf("  \033[90m│ %s\033[0m\n", truncate(m.Content, 100))
 		}
+		if verboseFlag && m.Meta != nil {
+			fmt.Printf("  \033[90m[%s]\033[0m\n", formatMeta(m.Meta))
+			prompt += m.Meta.PromptTokens
+			completion += m.Meta.CompletionTokens
+		}
 	}
 
+	if verboseFlag && prompt+completion > 0 {
+		fmt.Println(strings.Repeat("─", 60))
+		fmt.Printf("Tokens:   %d prompt, %d completion\n", prompt, completion)
+	}
 	return nil
 }
 
+// formatMeta summarizes a message's metadata on one line.
+func formatMeta(meta *llm.MessageMeta) string {
+	parts := []string{meta.CreatedAt.Local().Format("2006-01-02 15:04:05")}
+	if meta.Model != "" {
+		parts = append(parts, meta.Model)
+	}
+	if meta.LatencyMS > 0 {
+		parts = append(parts, (time.Duration(meta.LatencyMS) * time.Millisecond).String())
+	}
+	if meta.PromptTokens+meta.CompletionTokens > 0 {
+		parts = append(parts, fmt.Sprintf("%d→%d tokens", meta.PromptTokens, meta.CompletionTokens))
+	}
+	return strings.Join(parts, " · ")
+}
+
 func runSessionsDelete(cmd *cobra.Command, args []string) error {
 	store, err := openStore()
 	if err != nil {
@@ -206,7 +597,7 @@ func runSessionsDelete(cmd *cobra.Command, args []string) error {
 		if title == "" {
 			title = "(untitled)"
 		}
-		fmt.Printf("Delete session %s - %q? [y/N] ", sess.ID[:8], title)
+		fmt.Printf("Delete session %s - %q? [y/N] ", shortID(sess.ID), title)
 		var confirm string
 		fmt.Scanln(&confirm)
 		if strings.ToLower(confirm) != "y" {
@@ -218,10 +609,71 @@ func runSessionsDelete(cmd *cobra.Command, args []string) error {
 	if err := store.DeleteSession(ctx, sess.ID); err != nil {
 		return err
 	}
-	fmt.Printf("Deleted session %s\n", sess.ID[:8])
+	if artifacts, err := openArtifacts(store); err == nil {
+		if err := artifacts.RemoveSession(sess.ID); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: deleting artifacts: %v\n", err)
+		}
+	}
+	fmt.Printf("Deleted session %s\n", shortID(sess.ID))
+	return nil
+}
+
+func runSessionsRevert(cmd *cobra.Command, args []string) error {
+	store, err := openStore()
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	sess, err := store.GetSession(context.Background(), args[0])
+	if err != nil {
+		return err
+	}
+
+	backups := backup.New(backupDir())
+	entries, err := backups.Entries(sess.ID)
+	if err != nil {
+		return err
+	}
+	if len(entries) == 0 {
+		fmt.Printf("Session %s has no file changes to revert.\n", shortID(sess.ID))
+		return nil
+	}
+
+	if !forceFlag {
+		fmt.Printf("Revert %d file changes from session %s? [y/N] ", len(entries), shortID(sess.ID))
+		var confirm string
+		fmt.Scanln(&confirm)
+		if strings.ToLower(confirm) != "y" {
+			fmt.Println("Cancelled.")
+			return nil
+		}
+	}
+
+	restored, err := backups.Revert(sess.ID)
+	if err != nil {
+		return err
+	}
+	for _, e := range restored {
+		if e.Existed {
+			fmt.Printf("  restored %s\n", e.Path)
+		} else {
+			fmt.Printf("  removed  %s\n", e.Path)
+		}
+	}
+	fmt.Printf("Reverted %d files from session %s\n", len(restored), shortID(sess.ID))
 	return nil
 }
 
+// backupDir returns where file-ops keeps backups, matching the tool server's
+// FORGE_BACKUP_DIR override.
+func backupDir() string {
+	if dir := os.Getenv("FORGE_BACKUP_DIR"); dir != "" {
+		return dir
+	}
+	return backup.DefaultDir()
+}
+
 func runSessionsExport(cmd *cobra.Command, args []string) error {
 	store, err := openStore()
 	if err != nil {
@@ -248,6 +700,8 @@ func runSessionsExport(cmd *cobra.Command, args []string) error {
 			return err
 		}
 		output = string(data)
+	case "html":
+		output = storage.ExportHTML(sess, messages)
 	default:
 		output = storage.ExportMarkdown(sess, messages)
 	}
@@ -260,6 +714,105 @@ func runSessionsExport(cmd *cobra.Command, args []string) error {
 	return nil
 }
 
+func runSessionsArtifacts(cmd *cobra.Command, args []string) error {
+	store, err := openStore()
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	ctx := context.Background()
+	sess, err := store.GetSession(ctx, args[0])
+	if err != nil {
+		return err
+	}
+
+	if len(args) == 2 {
+		artifacts, err := openArtifacts(store)
+		if err != nil {
+			return err
+		}
+		_, path, err := artifacts.Open(ctx, sess.ID, args[1])
+		if err != nil {
+			return err
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		if artifactOut != "" {
+			return os.WriteFile(artifactOut, data, 0o644)
+		}
+		_, err = os.Stdout.Write(data)
+		return err
+	}
+
+	list, err := store.ListArtifacts(ctx, sess.ID)
+	if err != nil {
+		return err
+	}
+	if len(list) == 0 {
+		fmt.Println("No artifacts.")
+		return nil
+	}
+
+	fmt.Printf("%-32s %9s  %-24s %-16s %s\n", "NAME", "SIZE", "TYPE", "TOOL", "CREATED")
+	fmt.Println(strings.Repeat("─", 100))
+	for _, a := range list {
+		fmt.Printf("%-32s %9s  %-24s %-16s %s\n",
+			truncate(a.Name, 29), formatSize(a.Size), truncate(a.MIMEType, 21), truncate(a.Tool, 13), timeAgo(a.CreatedAt))
+	}
+	return nil
+}
+
+// formatSize renders a byte count for display.
+func formatSize(n int64) string {
+	switch {
+	case n >= 1<<20:
+		return fmt.Sprintf("%.1f MB", float64(n)/(1<<20))
+	case n >= 1<<10:
+		return fmt.Sprintf("%.1f KB", float64(n)/(1<<10))
+	default:
+		return fmt.Sprintf("%d B", n)
+	}
+}
+
+func runSessionsImport(cmd *cobra.Command, args []string) error {
+	var data []byte
+	var err error
+	if args[0] == "-" {
+		data, err = io.ReadAll(os.Stdin)
+	} else {
+		data, err = os.ReadFile(args[0])
+	}
+	if err != nil {
+		return err
+	}
+	export, err := storage.ParseExport(data)
+	if err != nil {
+		return err
+	}
+
+	store, err := openStore()
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	sess := export.Session
+	sess.ID = uuid.New().String()
+	if err := store.ImportSession(context.Background(), sess, export.Messages); err != nil {
+		return err
+	}
+
+	title := sess.Title
+	if title == "" {
+		title = "(untitled)"
+	}
+	fmt.Printf("Imported session %s - %q (%d messages)\n", shortID(sess.ID), title, len(export.Messages))
+	return nil
+}
+
 func truncate(s string, maxLen int) string {
 	s = strings.TrimSpace(s)
 	if len(s) > maxLen {
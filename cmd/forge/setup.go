@@ -0,0 +1,112 @@
+package main
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/michaelbrown/forge/internal/agent"
+	"github.com/michaelbrown/forge/internal/config"
+	"github.com/michaelbrown/forge/internal/llm"
+	"github.com/michaelbrown/forge/internal/storage"
+	"github.com/michaelbrown/forge/internal/tools"
+)
+
+// agentSetup is the profile, provider, and model an agent runs with, as
+// chosen by the --profile, --provider, and --model flags and the config.
+type agentSetup struct {
+	cfg          *config.Config
+	profile      *agent.Profile
+	providerName string
+	provider     config.ProviderConfig
+	model        string
+	// projectContext is the text of the project's FORGE.md, added to the
+	// system prompt.
+	projectContext string
+}
+
+// resolveAgent applies the flags over the profile, if one is given, and
+// the profile over cfg. When interactive, an Ollama model that isn't set
+// is picked from a menu on stdin.
+func resolveAgent(cfg *config.Config, interactive bool) (*agentSetup, error) {
+	s := &agentSetup{cfg: cfg}
+	if profileFlag != "" {
+		profile, err := agent.LoadProfile(cfg.ProfilePath(profileFlag))
+		if err != nil {
+			return nil, fmt.Errorf("loading profile: %w", err)
+		}
+		s.profile = profile
+	}
+	projectContext, err := cfg.ProjectContext()
+	if err != nil {
+		return nil, err
+	}
+	s.projectContext = projectContext
+
+	s.providerName = providerFlag
+	if s.providerName == "" {
+		if s.profile != nil && s.profile.Provider != "" {
+			s.providerName = s.profile.Provider
+		} else {
+			s.providerName = cfg.DefaultProvider
+		}
+	}
+	provider, err := cfg.Provider(s.providerName)
+	if err != nil {
+		return nil, err
+	}
+	s.provider = provider
+
+	s.model = modelFlag
+	if s.model == "" {
+		if s.profile != nil && s.profile.Model != "" {
+			s.model = s.profile.Model
+		} else if provider.IsOllama() && interactive {
+			picked, err := pickOllamaModel(provider, provider.Models["default"])
+			if err == nil {
+				s.model = picked
+			} else {
+				s.model = provider.Models["default"]
+			}
+		} else {
+			s.model = provider.Models["default"]
+		}
+	}
+	return s, nil
+}
+
+// startTools starts the configured tool servers, with calls recorded in
+// store and artifacts saved with their sessions. Servers that fail to
+// start are reported on warn and skipped.
+func startTools(cfg *config.Config, store storage.Store, warn io.Writer) *tools.Registry {
+	registry := tools.NewRegistry()
+	recordToolCalls(registry, store)
+	registry.SetArtifactSaver(storage.NewArtifacts(store, cfg.Storage.ArtifactsDir).Saver())
+	for name, toolCfg := range cfg.Tools {
+		if err := registry.Register(name, toolCfg); err != nil {
+			fmt.Fprintf(warn, "Warning: failed to start tool server %s: %v\n", name, err)
+		}
+	}
+	return registry
+}
+
+// newAgent creates an agent using registry's tools, with the utility model,
+// if the provider has one, the profile's prompt and tools, and the
+// project's FORGE.md.
+func (s *agentSetup) newAgent(registry *tools.Registry) *agent.Agent {
+	maxIter := s.cfg.Agent.MaxIterations
+	if s.profile != nil && s.profile.MaxIter > 0 {
+		maxIter = s.profile.MaxIter
+	}
+	client := llm.NewClient(s.provider.BaseURL, s.provider.APIKey, s.model)
+	a := agent.New(client, registry, maxIter)
+	a.SetMaxTokens(s.cfg.Agent.ContextMaxTokens)
+	if utilityModel := s.provider.Models["utility"]; utilityModel != "" {
+		a.SetUtilityLLM(llm.NewClient(s.provider.BaseURL, s.provider.APIKey, utilityModel))
+	}
+	if s.profile != nil {
+		a.SetSystemPrompt(s.profile.SystemPrompt)
+		a.FilterTools(s.profile.Tools)
+	}
+	a.AppendSystemPrompt(s.projectContext)
+	return a
+}
@@ -0,0 +1,242 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/michaelbrown/forge/internal/config"
+	"github.com/michaelbrown/forge/internal/logging"
+	"github.com/michaelbrown/forge/internal/storage"
+	"github.com/michaelbrown/forge/internal/tools"
+)
+
+var (
+	statsSince  time.Duration
+	statsFrom   string
+	statsTo     string
+	statsDays   int
+	statsFormat string
+)
+
+var statsCmd = &cobra.Command{
+	Use:   "stats",
+	Short: "Show usage statistics",
+	Long: `Report how forge has been used: sessions, messages, tool calls, tokens,
+and estimated cost, by day, model, and session. Days are UTC dates. Costs
+are estimated from the pricing list in forge.yaml.
+
+Examples:
+  forge stats
+  forge stats --days 7
+  forge stats --from 2026-10-01 --to 2026-10-31 --format json`,
+	Args: cobra.NoArgs,
+	RunE: runStats,
+}
+
+var statsToolsCmd = &cobra.Command{
+	Use:   "tools",
+	Short: "Show per-tool call counts, error rates, and latency",
+	Long: `Show which tools agents call most, how often they fail, and how long they take.
+
+Examples:
+  forge stats tools
+  forge stats tools --since 24h`,
+	RunE: runStatsTools,
+}
+
+func init() {
+	rootCmd.AddCommand(statsCmd)
+	statsCmd.AddCommand(statsToolsCmd)
+
+	statsCmd.Flags().StringVar(&statsFrom, "from", "", "First day to include (YYYY-MM-DD)")
+	statsCmd.Flags().StringVar(&statsTo, "to", "", "Last day to include (YYYY-MM-DD)")
+	statsCmd.Flags().IntVar(&statsDays, "days", 0, "Only include the last N days, today included")
+	statsCmd.Flags().StringVar(&statsFormat, "format", "text", "Output format: text or json")
+
+	statsToolsCmd.Flags().DurationVar(&statsSince, "since", 0, "Only include calls from this long ago (e.g. 24h); default is all time")
+}
+
+// recordToolCalls persists every tool call the registry makes.
+func recordToolCalls(registry *tools.Registry, store storage.Store) {
+	registry.OnCall(func(rec tools.CallRecord) {
+		if err := store.RecordToolCall(context.Background(), rec); err != nil {
+			logging.For("stats").Warn("failed to record tool call", "tool", rec.Tool, "error", err)
+		}
+	})
+}
+
+func runStats(cmd *cobra.Command, args []string) error {
+	if statsFormat != "text" && statsFormat != "json" {
+		return fmt.Errorf("unknown format %q (want text or json)", statsFormat)
+	}
+	from, to, err := statsRange()
+	if err != nil {
+		return err
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("loading config: %w", err)
+	}
+	store, err := openStore()
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	records, err := store.ListUsage(context.Background(), from, to)
+	if err != nil {
+		return err
+	}
+	report := storage.SummarizeUsage(records, cfg.EstimateCost)
+
+	if statsFormat == "json" {
+		data, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(data))
+		return nil
+	}
+	printUsageReport(report)
+	return nil
+}
+
+// statsRange reads the --from, --to, and --days flags.
+func statsRange() (from, to time.Time, err error) {
+	if statsDays > 0 {
+		if statsFrom != "" {
+			return from, to, fmt.Errorf("give --days or --from, not both")
+		}
+		from = time.Now().UTC().AddDate(0, 0, 1-statsDays)
+	}
+	if statsFrom != "" {
+		if from, err = time.Parse(time.DateOnly, statsFrom); err != nil {
+			return from, to, fmt.Errorf("invalid --from date %q (want YYYY-MM-DD)", statsFrom)
+		}
+	}
+	if statsTo != "" {
+		if to, err = time.Parse(time.DateOnly, statsTo); err != nil {
+			return from, to, fmt.Errorf("invalid --to date %q (want YYYY-MM-DD)", statsTo)
+		}
+	}
+	return from, to, nil
+}
+
+func printUsageReport(r storage.UsageReport) {
+	if len(r.Days) == 0 {
+		fmt.Println("No usage recorded.")
+		return
+	}
+
+	t := r.Totals
+	fmt.Printf("Usage from %s to %s\n\n", r.Days[0].Day, r.Days[len(r.Days)-1].Day)
+	fmt.Printf("Sessions:   %d\n", t.Sessions)
+	fmt.Printf("Messages:   %d\n", t.Messages)
+	fmt.Printf("Tool calls: %d\n", t.ToolCalls)
+	fmt.Printf("Tokens:     %s prompt, %s completion\n", formatCount(t.PromptTokens), formatCount(t.CompletionTokens))
+	fmt.Printf("Cost:       %s\n", formatCost(t))
+
+	fmt.Printf("\n%-32s %8s %9s %11s %11s %10s\n", "MODEL", "SESSIONS", "MESSAGES", "PROMPT", "COMPLETION", "COST")
+	fmt.Println(strings.Repeat("─", 86))
+	for _, m := range r.Models {
+		fmt.Printf("%-32s %8d %9d %11s %11s %10s\n", truncate(m.Model, 29), m.Sessions, m.Messages,
+			formatCount(m.PromptTokens), formatCount(m.CompletionTokens), formatCost(m.UsageTotals))
+	}
+
+	fmt.Printf("\n%-12s %8s %9s %10s %11s %10s\n", "DAY", "SESSIONS", "MESSAGES", "TOOL CALLS", "TOKENS", "COST")
+	fmt.Println(strings.Repeat("─", 66))
+	for _, d := range r.Days {
+		fmt.Printf("%-12s %8d %9d %10d %11s %10s\n", d.Day, d.Sessions, d.Messages, d.ToolCalls,
+			formatCount(d.PromptTokens+d.CompletionTokens), formatCost(d.UsageTotals))
+	}
+
+	const topSessions = 10
+	fmt.Printf("\n%-10s %-36s %9s %10s %11s %10s\n", "SESSION", "TITLE", "MESSAGES", "TOOL CALLS", "TOKENS", "COST")
+	fmt.Println(strings.Repeat("─", 91))
+	for i, s := range r.Sessions {
+		if i == topSessions {
+			fmt.Printf("... and %d more\n", len(r.Sessions)-topSessions)
+			break
+		}
+		title := s.Title
+		if title == "" {
+			title = "(deleted)"
+		}
+		fmt.Printf("%-10s %-36s %9d %10d %11s %10s\n", s.SessionID[:min(8, len(s.SessionID))], truncate(title, 33),
+			s.Messages, s.ToolCalls, formatCount(s.PromptTokens+s.CompletionTokens), formatCost(s.UsageTotals))
+	}
+	if t.UnpricedTokens > 0 {
+		fmt.Printf("\n* %s tokens are from models with no price in forge.yaml's pricing list.\n", formatCount(t.UnpricedTokens))
+	}
+}
+
+// formatCount renders a token count compactly (e.g. "950", "12.3K", "1.2M").
+func formatCount(n int64) string {
+	switch {
+	case n >= 1_000_000:
+		return fmt.Sprintf("%.1fM", float64(n)/1e6)
+	case n >= 1_000:
+		return fmt.Sprintf("%.1fK", float64(n)/1e3)
+	default:
+		return fmt.Sprintf("%d", n)
+	}
+}
+
+// formatCost renders an estimated cost, starred when some tokens have no
+// price.
+func formatCost(t storage.UsageTotals) string {
+	s := fmt.Sprintf("$%.2f", t.Cost)
+	if t.UnpricedTokens > 0 {
+		s += "*"
+	}
+	return s
+}
+
+func runStatsTools(cmd *cobra.Command, args []string) error {
+	store, err := openStore()
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	var since time.Time
+	if statsSince > 0 {
+		since = time.Now().Add(-statsSince)
+	}
+
+	calls, err := store.ListToolCalls(context.Background(), since)
+	if err != nil {
+		return err
+	}
+
+	stats := tools.SummarizeCalls(calls)
+	if len(stats) == 0 {
+		fmt.Println("No tool calls recorded.")
+		return nil
+	}
+
+	fmt.Printf("%-24s %-16s %7s %7s %7s %9s %9s %9s\n", "TOOL", "SERVER", "CALLS", "ERRORS", "ERR%", "P50", "P90", "P99")
+	fmt.Println(strings.Repeat("─", 95))
+
+	for _, st := range stats {
+		fmt.Printf("%-24s %-16s %7d %7d %6.1f%% %9s %9s %9s\n",
+			truncate(st.Tool, 21), truncate(st.Server, 13), st.Calls, st.Errors, st.ErrorRate*100,
+			formatMs(st.P50Ms), formatMs(st.P90Ms), formatMs(st.P99Ms))
+	}
+
+	return nil
+}
+
+// formatMs renders a millisecond latency compactly (e.g. "850ms", "2.4s").
+func formatMs(ms float64) string {
+	if ms >= 1000 {
+		return fmt.Sprintf("%.1fs", ms/1000)
+	}
+	return fmt.Sprintf("%.0fms", ms)
+}
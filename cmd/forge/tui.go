@@ -0,0 +1,869 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/bubbles/textarea"
+	"github.com/charmbracelet/bubbles/viewport"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/ansi"
+	"github.com/google/uuid"
+	"github.com/spf13/cobra"
+
+	"github.com/michaelbrown/forge/internal/agent"
+	"github.com/michaelbrown/forge/internal/config"
+	"github.com/michaelbrown/forge/internal/llm"
+	"github.com/michaelbrown/forge/internal/mention"
+	"github.com/michaelbrown/forge/internal/storage"
+	"github.com/michaelbrown/forge/internal/storage/sqlite"
+	"github.com/michaelbrown/forge/internal/tools"
+)
+
+var tuiCmd = &cobra.Command{
+	Use:   "tui",
+	Short: "Start a chat session in a full-screen terminal UI",
+	Long: `Chat with a Forge agent in a full-screen terminal UI, with scrollback,
+a status bar showing the model, tokens, and estimated cost, tool calls
+that fold open and shut, and a switcher for earlier sessions.
+"forge chat --tui" does the same.
+
+Keys:
+  enter            send (alt+enter or ctrl+j for a new line)
+  pgup/pgdown      scroll
+  tab/shift+tab    select a tool call
+  ctrl+o           expand or collapse the selected tool call
+  ctrl+s           switch to another session
+  ctrl+n           start a new session
+  esc              stop the reply in progress
+  ctrl+c           stop the reply in progress, or quit
+
+Examples:
+  forge tui
+  forge tui --resume <session-id>
+  forge tui --continue
+  forge chat --tui --provider claude`,
+	RunE: runTUI,
+}
+
+func init() {
+	tuiCmd.Flags().StringVar(&resumeID, "resume", "", "Resume a previous session by ID or prefix, or pick one from a list")
+	tuiCmd.Flags().Lookup("resume").NoOptDefVal = pickFlag
+	tuiCmd.Flags().BoolVarP(&continueLast, "continue", "c", false, "Resume the most recent session")
+	rootCmd.AddCommand(tuiCmd)
+}
+
+func runTUI(cmd *cobra.Command, args []string) error {
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("loading config: %w", err)
+	}
+	store, err := sqlite.Open(cfg.Storage.DBPath)
+	if err != nil {
+		return fmt.Errorf("opening storage: %w", err)
+	}
+	defer store.Close()
+
+	setup, err := resolveAgent(cfg, true)
+	if err != nil {
+		return err
+	}
+	sess, err := resumeSession(context.Background(), store, args)
+	if errors.Is(err, errNotPicked) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var warnings bytes.Buffer
+	registry := startTools(cfg, store, &warnings)
+	defer registry.Close()
+
+	m := newTUIModel(setup, registry, store)
+	p := tea.NewProgram(m, tea.WithAltScreen())
+	m.program = p
+	m.notifier = newTurnNotifier(cfg, func(warning string) { p.Send(noticeMsg(warning)) })
+
+	// Tool servers ask before actions that need sign-off; the question
+	// replaces the input until it is answered.
+	registry.SetApprover(func(ctx context.Context, server, message string) (bool, error) {
+		m.notifier.approval(server, message)
+		reply := make(chan bool, 1)
+		p.Send(approvalMsg{server: server, message: message, reply: reply})
+		select {
+		case ok := <-reply:
+			return ok, nil
+		case <-ctx.Done():
+			return false, ctx.Err()
+		}
+	})
+
+	if sess != nil {
+		if err := m.load(sess); err != nil {
+			return err
+		}
+	} else if err := m.newSession(); err != nil {
+		return err
+	}
+	for _, line := range strings.Split(strings.TrimSpace(warnings.String()), "\n") {
+		if line != "" {
+			m.add(&tuiEntry{kind: entryError, text: line})
+		}
+	}
+
+	_, err = p.Run()
+	m.finish()
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Session: %s\n", shortID(m.sess.ID))
+	return nil
+}
+
+type entryKind int
+
+const (
+	entryUser entryKind = iota
+	entryAssistant
+	entryTool
+	entryNotice
+	entryError
+)
+
+// tuiEntry is one block of the scrollback: a message, a tool call, or a
+// notice.
+type tuiEntry struct {
+	kind entryKind
+	text string
+
+	// Tool calls only.
+	call     string // as FormatToolCall shows it
+	output   string // reported while it runs
+	result   string
+	done     bool
+	expanded bool
+}
+
+// Messages sent from the goroutine running a turn.
+type (
+	textDeltaMsg  string
+	toolCallMsg   string
+	toolOutputMsg string
+	toolResultMsg string
+	noticeMsg     string
+	turnDoneMsg   struct {
+		reply       string
+		err         error
+		interrupted bool
+		titled      bool // the session got its title from this turn's message
+	}
+	titleMsg struct {
+		sessionID string
+		title     string
+	}
+	approvalMsg struct {
+		server  string
+		message string
+		reply   chan<- bool
+	}
+)
+
+// tuiModel is the state of the TUI. Its methods run on the program's
+// goroutine, apart from the turn itself, which only reaches the model
+// through messages.
+type tuiModel struct {
+	setup    *agentSetup
+	registry *tools.Registry
+	store    storage.Store
+	program  *tea.Program
+
+	agent *agent.Agent
+	sess  *storage.Session
+	usage storage.UsageTotals
+
+	viewport viewport.Model
+	input    textarea.Model
+	width    int
+	ready    bool
+
+	entries  []*tuiEntry
+	offsets  []int // first line of each entry in the scrollback
+	selected int   // index of the selected tool call in entries, or -1
+
+	busy     bool
+	cancel   context.CancelFunc
+	done     chan struct{} // closed when the running turn returns
+	approval *approvalMsg
+	switcher *sessionSwitcher
+	notifier *turnNotifier
+}
+
+// sessionSwitcher lists recent sessions to switch to.
+type sessionSwitcher struct {
+	sessions []storage.Session
+	cursor   int
+}
+
+var (
+	userStyle     = lipgloss.NewStyle().Foreground(lipgloss.Color("6"))
+	forgeStyle    = lipgloss.NewStyle().Foreground(lipgloss.Color("2"))
+	toolStyle     = lipgloss.NewStyle().Foreground(lipgloss.Color("3"))
+	dimStyle      = lipgloss.NewStyle().Foreground(lipgloss.Color("8"))
+	errorStyle    = lipgloss.NewStyle().Foreground(lipgloss.Color("1"))
+	selectedStyle = lipgloss.NewStyle().Reverse(true)
+	statusStyle   = lipgloss.NewStyle().Reverse(true)
+)
+
+// inputHeight is how many lines the input box takes.
+const inputHeight = 3
+
+func newTUIModel(setup *agentSetup, registry *tools.Registry, store storage.Store) *tuiModel {
+	input := textarea.New()
+	input.Placeholder = "Message Forge"
+	input.ShowLineNumbers = false
+	input.Prompt = "┃ "
+	input.CharLimit = 0
+	input.SetHeight(inputHeight)
+	input.KeyMap.InsertNewline.SetKeys("alt+enter", "ctrl+j")
+	input.FocusedStyle.CursorLine = lipgloss.NewStyle()
+	input.Focus()
+
+	return &tuiModel{
+		setup:    setup,
+		registry: registry,
+		store:    store,
+		input:    input,
+		selected: -1,
+	}
+}
+
+// startAgent gives the TUI a fresh agent, its callbacks sending what
+// happens during a turn to the program.
+func (m *tuiModel) startAgent() {
+	a := m.setup.newAgent(m.registry)
+	p := m.program
+	a.OnTextDelta = func(delta string) { p.Send(textDeltaMsg(delta)) }
+	a.OnToolCall = func(name string, args map[string]any) {
+		p.Send(toolCallMsg(agent.FormatToolCall(name, args)))
+	}
+	a.OnToolOutput = func(name, output string) { p.Send(toolOutputMsg(output)) }
+	a.OnToolResult = func(name, result string) { p.Send(toolResultMsg(result)) }
+	m.agent = a
+}
+
+// newSession closes the current session and starts a new, empty one.
+func (m *tuiModel) newSession() error {
+	sess := &storage.Session{
+		ID:       uuid.New().String(),
+		Status:   storage.StatusActive,
+		Provider: m.setup.providerName,
+		Model:    m.setup.model,
+		Profile:  profileFlag,
+	}
+	if err := m.store.CreateSession(context.Background(), sess); err != nil {
+		return fmt.Errorf("creating session: %w", err)
+	}
+	m.closeSession()
+	m.startAgent()
+	m.sess = sess
+	m.entries, m.selected = nil, -1
+	m.usage = storage.UsageTotals{}
+	m.add(&tuiEntry{kind: entryNotice, text: fmt.Sprintf("New session %s. Type /help for commands.", shortID(sess.ID))})
+	return nil
+}
+
+// load closes the current session and resumes sess, showing its history.
+func (m *tuiModel) load(sess *storage.Session) error {
+	ctx := context.Background()
+	messages, err := m.store.LoadMessages(ctx, sess.ID)
+	if err != nil {
+		return fmt.Errorf("loading messages: %w", err)
+	}
+	m.closeSession()
+	m.startAgent()
+	m.agent.SetHistory(messages)
+	sess.Status = storage.StatusActive
+	m.store.UpdateSession(ctx, sess)
+	m.sess = sess
+	m.entries, m.selected = historyEntries(messages), -1
+	m.usage = historyUsage(m.setup.cfg, messages)
+	m.add(&tuiEntry{kind: entryNotice, text: fmt.Sprintf("Resumed session %s. Type /help for commands.", shortID(sess.ID))})
+	return nil
+}
+
+// closeSession marks the current session completed, as leaving chat does.
+func (m *tuiModel) closeSession() {
+	if m.sess != nil && m.sess.Status == storage.StatusActive {
+		m.sess.Status = storage.StatusCompleted
+		m.store.UpdateSession(context.Background(), m.sess)
+	}
+}
+
+// finish stops a turn still running when the program exits, saves it,
+// and closes the session.
+func (m *tuiModel) finish() {
+	if m.busy {
+		m.cancel()
+		<-m.done
+		m.busy = false
+		if err := m.save(); err != nil {
+			fmt.Fprintf(os.Stderr, "warning: failed to save session: %v\n", err)
+		}
+	}
+	m.closeSession()
+}
+
+// save stores the messages of the last turn and updates the usage totals.
+func (m *tuiModel) save() error {
+	m.usage = historyUsage(m.setup.cfg, m.agent.History())
+	return storage.SaveHistory(context.Background(), m.store, m.sess.ID, m.agent)
+}
+
+// historyEntries rebuilds the scrollback of a saved conversation, with its
+// tool calls collapsed.
+func historyEntries(messages []llm.Message) []*tuiEntry {
+	var entries []*tuiEntry
+	calls := map[string]*tuiEntry{}
+	for _, msg := range messages {
+		switch msg.Role {
+		case llm.RoleUser:
+			entries = append(entries, &tuiEntry{kind: entryUser, text: msg.Content})
+		case llm.RoleAssistant:
+			if strings.TrimSpace(msg.Content) != "" {
+				entries = append(entries, &tuiEntry{kind: entryAssistant, text: msg.Content})
+			}
+			for _, tc := range msg.ToolCalls {
+				e := &tuiEntry{kind: entryTool, call: agent.FormatToolCall(tc.Name, tc.Args), done: true}
+				calls[tc.ID] = e
+				entries = append(entries, e)
+			}
+		case llm.RoleTool:
+			if e := calls[msg.ToolCallID]; e != nil {
+				e.result = msg.Content
+			}
+		}
+	}
+	return entries
+}
+
+// historyUsage totals the tokens the replies in messages used, pricing them
+// from the config.
+func historyUsage(cfg *config.Config, messages []llm.Message) storage.UsageTotals {
+	var t storage.UsageTotals
+	for _, msg := range messages {
+		if msg.Role != llm.RoleAssistant || msg.Meta == nil {
+			continue
+		}
+		prompt, completion := int64(msg.Meta.PromptTokens), int64(msg.Meta.CompletionTokens)
+		t.PromptTokens += prompt
+		t.CompletionTokens += completion
+		if cost, ok := cfg.EstimateCost(msg.Meta.Model, prompt, completion); ok {
+			t.Cost += cost
+		} else {
+			t.UnpricedTokens += prompt + completion
+		}
+	}
+	return t
+}
+
+func (m *tuiModel) add(e *tuiEntry) {
+	m.entries = append(m.entries, e)
+}
+
+// runningTool returns the tool call in progress, if any.
+func (m *tuiModel) runningTool() *tuiEntry {
+	for i := len(m.entries) - 1; i >= 0; i-- {
+		if e := m.entries[i]; e.kind == entryTool && !e.done {
+			return e
+		}
+	}
+	return nil
+}
+
+func (m *tuiModel) Init() tea.Cmd {
+	return textarea.Blink
+}
+
+func (m *tuiModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	var cmd tea.Cmd
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		m.layout(msg.Width, msg.Height)
+	case tea.KeyMsg:
+		cmd = m.handleKey(msg)
+	case textDeltaMsg:
+		if n := len(m.entries); n > 0 && m.entries[n-1].kind == entryAssistant {
+			m.entries[n-1].text += string(msg)
+		} else {
+			m.add(&tuiEntry{kind: entryAssistant, text: string(msg)})
+		}
+	case toolCallMsg:
+		m.add(&tuiEntry{kind: entryTool, call: string(msg)})
+	case toolOutputMsg:
+		if e := m.runningTool(); e != nil {
+			e.output += string(msg)
+		}
+	case toolResultMsg:
+		if e := m.runningTool(); e != nil {
+			e.result, e.done = string(msg), true
+		}
+	case noticeMsg:
+		m.add(&tuiEntry{kind: entryNotice, text: string(msg)})
+	case approvalMsg:
+		m.approval = &msg
+		m.add(&tuiEntry{kind: entryNotice, text: fmt.Sprintf("%s needs approval:\n%s", msg.server, msg.message)})
+	case turnDoneMsg:
+		cmd = m.turnDone(msg)
+	case titleMsg:
+		if msg.sessionID == m.sess.ID {
+			m.sess.Title = msg.title
+		}
+	default:
+		m.input, cmd = m.input.Update(msg)
+	}
+	m.refresh()
+	return m, cmd
+}
+
+func (m *tuiModel) handleKey(msg tea.KeyMsg) tea.Cmd {
+	if m.approval != nil {
+		switch msg.String() {
+		case "y", "Y":
+			m.answer(true)
+		case "n", "N", "enter", "esc":
+			m.answer(false)
+		case "ctrl+c":
+			m.cancel()
+		}
+		return nil
+	}
+	if m.switcher != nil {
+		return m.switcherKey(msg)
+	}
+
+	switch msg.String() {
+	case "ctrl+c":
+		if m.busy {
+			m.cancel()
+			return nil
+		}
+		return tea.Quit
+	case "esc":
+		if m.busy {
+			m.cancel()
+		}
+		m.selected = -1
+	case "ctrl+s":
+		m.openSwitcher()
+	case "ctrl+n":
+		if m.busy {
+			return nil
+		}
+		if err := m.newSession(); err != nil {
+			m.add(&tuiEntry{kind: entryError, text: "error: " + err.Error()})
+		}
+	case "tab":
+		m.selectTool(1)
+	case "shift+tab":
+		m.selectTool(-1)
+	case "ctrl+o":
+		m.toggleTool()
+	case "pgup":
+		m.viewport.PageUp()
+	case "pgdown":
+		m.viewport.PageDown()
+	case "enter":
+		if m.busy {
+			return nil
+		}
+		text := strings.TrimSpace(m.input.Value())
+		if text == "" {
+			return nil
+		}
+		m.input.Reset()
+		if strings.HasPrefix(text, "/") {
+			return m.command(text)
+		}
+		return m.send(text)
+	default:
+		var cmd tea.Cmd
+		m.input, cmd = m.input.Update(msg)
+		return cmd
+	}
+	return nil
+}
+
+// answer replies to the pending approval.
+func (m *tuiModel) answer(ok bool) {
+	m.approval.reply <- ok
+	m.approval = nil
+	if ok {
+		m.add(&tuiEntry{kind: entryNotice, text: "Approved."})
+	} else {
+		m.add(&tuiEntry{kind: entryNotice, text: "Denied."})
+	}
+}
+
+// send starts a turn on text, once its @ references are expanded. The
+// agent runs in the command's goroutine.
+func (m *tuiModel) send(text string) tea.Cmd {
+	m.add(&tuiEntry{kind: entryUser, text: text})
+	m.viewport.GotoBottom()
+
+	titled := m.sess.Title == ""
+	if titled {
+		m.sess.Title = generateTitle(text)
+		m.store.UpdateSession(context.Background(), m.sess)
+	}
+
+	ctx, cancel := context.WithCancel(tools.WithSession(context.Background(), m.sess.ID))
+	m.busy, m.cancel, m.done = true, cancel, make(chan struct{})
+	m.notifier.begin()
+	a, done, p := m.agent, m.done, m.program
+	return func() tea.Msg {
+		defer close(done)
+		message, refs, err := mention.Expand(ctx, text, mention.Options{})
+		if err != nil {
+			cancel()
+			return turnDoneMsg{err: err}
+		}
+		for _, ref := range refs {
+			p.Send(noticeMsg("+ " + describeReference(ref)))
+		}
+		reply, err := a.RunStreaming(ctx, message)
+		interrupted := ctx.Err() != nil
+		cancel()
+		return turnDoneMsg{reply: reply, err: err, interrupted: interrupted, titled: titled}
+	}
+}
+
+func (m *tuiModel) turnDone(msg turnDoneMsg) tea.Cmd {
+	m.busy, m.cancel, m.approval = false, nil, nil
+	if err := m.save(); err != nil {
+		m.add(&tuiEntry{kind: entryError, text: fmt.Sprintf("warning: failed to save session: %v", err)})
+	}
+	// Calls the turn never finished, if it was stopped, are over too.
+	for _, e := range m.entries {
+		if e.kind == entryTool {
+			e.done = true
+		}
+	}
+	if !msg.interrupted {
+		m.notifier.finished(m.sess.Title, msg.reply, msg.err)
+	}
+	switch {
+	case msg.interrupted:
+		m.add(&tuiEntry{kind: entryNotice, text: "(interrupted)"})
+	case msg.err != nil:
+		text := "error: " + msg.err.Error()
+		if llm.IsFallbackEligible(msg.err) {
+			if opts := m.setup.cfg.FallbackProviders(m.setup.providerName); len(opts) > 0 {
+				text += fmt.Sprintf("\nhint: try forge tui --provider %s --model %s", opts[0].Provider, opts[0].Model)
+			}
+		}
+		m.add(&tuiEntry{kind: entryError, text: text})
+	case msg.titled && m.agent.UtilityLLM() != nil:
+		titles := make(chan string, 1)
+		retitle(m.agent, m.store, m.sess.ID, m.sess.Title, titles)
+		id := m.sess.ID
+		return func() tea.Msg {
+			select {
+			case title := <-titles:
+				return titleMsg{sessionID: id, title: title}
+			case <-time.After(time.Minute):
+				return nil
+			}
+		}
+	}
+	return nil
+}
+
+// command runs a slash command.
+func (m *tuiModel) command(input string) tea.Cmd {
+	fields := strings.Fields(input)
+	switch strings.ToLower(fields[0]) {
+	case "/quit", "/exit", "/q":
+		return tea.Quit
+	case "/new":
+		if err := m.newSession(); err != nil {
+			m.add(&tuiEntry{kind: entryError, text: "error: " + err.Error()})
+		}
+	case "/sessions":
+		m.openSwitcher()
+	case "/title":
+		if len(fields) == 1 {
+			title := m.sess.Title
+			if title == "" {
+				title = "Untitled session"
+			}
+			m.add(&tuiEntry{kind: entryNotice, text: title})
+			return nil
+		}
+		m.sess.Title = strings.TrimSpace(strings.TrimPrefix(input, fields[0]))
+		if err := m.store.UpdateSession(context.Background(), m.sess); err != nil {
+			m.add(&tuiEntry{kind: entryError, text: "error: " + err.Error()})
+		}
+	case "/help":
+		m.add(&tuiEntry{kind: entryNotice, text: strings.Join([]string{
+			"Commands:",
+			"  /new             - Start a new session (ctrl+n)",
+			"  /sessions        - Switch to another session (ctrl+s)",
+			"  /title           - Show the session title",
+			"  /title <title>   - Rename the session",
+			"  /quit            - Exit",
+		}, "\n")})
+	default:
+		m.add(&tuiEntry{kind: entryError, text: fmt.Sprintf("unknown command %s (try /help)", fields[0])})
+	}
+	return nil
+}
+
+// selectTool moves the selection to the next (dir 1) or previous (-1) tool
+// call, starting from the latest when none is selected, and scrolls to it.
+func (m *tuiModel) selectTool(dir int) {
+	i := m.selected
+	if i < 0 {
+		i, dir = len(m.entries), -1
+	}
+	for i += dir; i >= 0 && i < len(m.entries); i += dir {
+		if m.entries[i].kind == entryTool {
+			m.selected = i
+			m.refresh()
+			if off := m.offsets[i]; off < m.viewport.YOffset || off >= m.viewport.YOffset+m.viewport.Height {
+				m.viewport.SetYOffset(off)
+			}
+			return
+		}
+	}
+}
+
+// toggleTool expands or collapses the selected tool call, or the latest
+// one if none is selected.
+func (m *tuiModel) toggleTool() {
+	if m.selected < 0 {
+		m.selectTool(-1)
+	}
+	if m.selected >= 0 {
+		e := m.entries[m.selected]
+		e.expanded = !e.expanded
+	}
+}
+
+func (m *tuiModel) openSwitcher() {
+	if m.busy {
+		return
+	}
+	sessions, err := m.store.ListSessions(context.Background(), storage.SessionListOptions{Limit: 50})
+	if err != nil {
+		m.add(&tuiEntry{kind: entryError, text: "error: " + err.Error()})
+		return
+	}
+	m.switcher = &sessionSwitcher{sessions: sessions}
+}
+
+func (m *tuiModel) switcherKey(msg tea.KeyMsg) tea.Cmd {
+	s := m.switcher
+	switch msg.String() {
+	case "ctrl+c":
+		return tea.Quit
+	case "esc", "ctrl+s":
+		m.switcher = nil
+	case "up", "k":
+		s.cursor = max(s.cursor-1, 0)
+	case "down", "j":
+		s.cursor = min(s.cursor+1, len(s.sessions)-1)
+	case "enter":
+		m.switcher = nil
+		if len(s.sessions) == 0 {
+			return nil
+		}
+		sess := s.sessions[s.cursor]
+		if sess.ID == m.sess.ID {
+			return nil
+		}
+		if err := m.load(&sess); err != nil {
+			m.add(&tuiEntry{kind: entryError, text: "error: " + err.Error()})
+		}
+		m.viewport.GotoBottom()
+	}
+	return nil
+}
+
+func (m *tuiModel) layout(width, height int) {
+	m.width = width
+	m.input.SetWidth(width)
+	m.viewport.Width = width
+	m.viewport.Height = max(height-inputHeight-2, 1) // status bar and help line
+	m.ready = true
+}
+
+// refresh re-renders the scrollback, following new output if the view was
+// at the bottom.
+func (m *tuiModel) refresh() {
+	if !m.ready {
+		return
+	}
+	follow := m.viewport.AtBottom()
+	var b strings.Builder
+	m.offsets = m.offsets[:0]
+	line := 0
+	for i, e := range m.entries {
+		if i > 0 {
+			b.WriteString("\n\n")
+			line += 2
+		}
+		m.offsets = append(m.offsets, line)
+		view := e.view(m.width, i == m.selected)
+		b.WriteString(view)
+		line += strings.Count(view, "\n") + 1
+	}
+	m.viewport.SetContent(b.String())
+	if follow {
+		m.viewport.GotoBottom()
+	}
+}
+
+// view renders the entry to fit width.
+func (e *tuiEntry) view(width int, selected bool) string {
+	wrap := lipgloss.NewStyle().Width(width)
+	switch e.kind {
+	case entryUser:
+		return wrap.Render(userStyle.Render("you>") + " " + strings.TrimSpace(e.text))
+	case entryAssistant:
+		return wrap.Render(forgeStyle.Render("forge>") + " " + strings.TrimSpace(e.text))
+	case entryNotice:
+		return dimStyle.Render(wrap.Render(e.text))
+	case entryError:
+		return errorStyle.Render(wrap.Render(e.text))
+	}
+
+	mark := "▸"
+	if e.expanded {
+		mark = "▾"
+	}
+	state := "…"
+	if e.done {
+		state = "✓"
+		if strings.HasPrefix(e.result, "error:") {
+			state = "✗"
+		}
+	}
+	body := e.result
+	if !e.done {
+		body = e.output
+	}
+	var lines []string
+	if body = strings.TrimRight(body, "\n"); body != "" {
+		lines = strings.Split(lipgloss.NewStyle().Width(max(width-4, 1)).Render(body), "\n")
+	}
+
+	header := fmt.Sprintf("%s ⚡ %s %s", mark, e.call, state)
+	if e.done && !e.expanded && len(lines) > 0 {
+		header += fmt.Sprintf(" (%d lines)", len(lines))
+	}
+	header = ansi.Truncate(header, width, "…")
+	if selected {
+		header = selectedStyle.Render(header)
+	} else {
+		header = toolStyle.Render(header)
+	}
+
+	// Collapsed, a finished call shows only its header and a running one
+	// the tail of its output.
+	switch {
+	case e.expanded:
+	case e.done:
+		lines = nil
+	case len(lines) > 3:
+		lines = lines[len(lines)-3:]
+	}
+	var b strings.Builder
+	b.WriteString(header)
+	for _, line := range lines {
+		b.WriteString("\n" + dimStyle.Render("  │ "+line))
+	}
+	return b.String()
+}
+
+func (m *tuiModel) View() string {
+	if !m.ready {
+		return ""
+	}
+	main := m.viewport.View()
+	if m.switcher != nil {
+		main = m.switcher.view(m.width, m.viewport.Height, m.sess.ID)
+	}
+	bottom := m.input.View()
+	if m.approval != nil {
+		bottom = lipgloss.NewStyle().Height(inputHeight).Render(
+			toolStyle.Render(ansi.Truncate(fmt.Sprintf("? %s needs approval (see above). Approve? [y/N]", m.approval.server), m.width, "…")))
+	}
+	return lipgloss.JoinVertical(lipgloss.Left, main, m.statusView(), bottom, m.helpView())
+}
+
+// statusView is the status bar: the model, session, tokens, cost, and
+// what the agent is doing.
+func (m *tuiModel) statusView() string {
+	title := m.sess.Title
+	if title == "" {
+		title = "untitled"
+	}
+	parts := []string{
+		m.setup.providerName + "/" + m.setup.model,
+		shortID(m.sess.ID) + " " + title,
+		formatCount(m.usage.PromptTokens+m.usage.CompletionTokens) + " tokens",
+	}
+	if len(m.setup.cfg.Pricing) > 0 {
+		parts = append(parts, formatCost(m.usage))
+	}
+	switch {
+	case m.approval != nil:
+		parts = append(parts, "waiting for approval")
+	case m.busy:
+		parts = append(parts, "working…")
+	}
+	return statusStyle.Width(m.width).Render(ansi.Truncate(" "+strings.Join(parts, " · "), m.width, "…"))
+}
+
+func (m *tuiModel) helpView() string {
+	help := "enter send · alt+enter newline · tab select tool · ctrl+o expand · ctrl+s sessions · ctrl+n new · ctrl+c quit"
+	switch {
+	case m.switcher != nil:
+		help = "↑/↓ choose · enter open · esc close"
+	case m.busy:
+		help = "esc stop · pgup/pgdown scroll · tab select tool · ctrl+o expand"
+	}
+	return dimStyle.Render(ansi.Truncate(help, m.width, "…"))
+}
+
+// view renders the switcher in height lines, keeping the cursor in sight.
+func (s *sessionSwitcher) view(width, height int, current string) string {
+	lines := []string{"Sessions"}
+	if len(s.sessions) == 0 {
+		lines = append(lines, dimStyle.Render("No saved sessions."))
+	}
+	first := max(s.cursor-(height-2), 0)
+	for i := first; i < len(s.sessions) && len(lines) < height; i++ {
+		sess := s.sessions[i]
+		title := sess.Title
+		if title == "" {
+			title = "(untitled)"
+		}
+		mark := "  "
+		if sess.ID == current {
+			mark = "* "
+		}
+		line := ansi.Truncate(fmt.Sprintf("%s%s  %-8s  %s", mark, shortID(sess.ID), timeAgo(sess.UpdatedAt), title), width, "…")
+		if i == s.cursor {
+			line = selectedStyle.Render(line)
+		}
+		lines = append(lines, line)
+	}
+	return lipgloss.NewStyle().Height(height).Render(strings.Join(lines, "\n"))
+}
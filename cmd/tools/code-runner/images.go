@@ -0,0 +1,111 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/michaelbrown/forge/internal/sandbox"
+)
+
+// imagesFor returns the images of the named languages, or of every
+// language when names is empty or "all".
+func imagesFor(names []string) ([]string, error) {
+	if len(names) == 0 || len(names) == 1 && names[0] == "all" {
+		return languageImages(), nil
+	}
+	var images []string
+	for _, name := range names {
+		lang, ok := lookupLanguage(name)
+		if !ok {
+			return nil, fmt.Errorf("unknown language %q (supported: %s)", name, strings.Join(languageNames(), ", "))
+		}
+		if !slices.Contains(images, lang.Image) {
+			images = append(images, lang.Image)
+		}
+	}
+	sort.Strings(images)
+	return images, nil
+}
+
+// runPull pulls the images of the named languages, or of all of them, and
+// reports each on stdout. It is what "forge-tool-code-runner pull" does,
+// for "forge sandbox pull".
+func runPull(names []string) error {
+	switch {
+	case languagesErr != nil:
+		return languagesErr
+	case policyErr != nil:
+		return policyErr
+	case native:
+		return errors.New("Docker isn't available, so there are no images to pull")
+	}
+	images, err := imagesFor(names)
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+	failed := 0
+	for _, image := range images {
+		if sandbox.ImagePresent(ctx, image) {
+			fmt.Printf("%s: present\n", image)
+			continue
+		}
+		fmt.Printf("%s: pulling...\n", image)
+		start := time.Now()
+		if err := sandbox.PullImage(ctx, image); err != nil {
+			fmt.Printf("%s: %v\n", image, err)
+			failed++
+			continue
+		}
+		fmt.Printf("%s: pulled in %s\n", image, time.Since(start).Round(time.Second))
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d images could not be pulled", failed, len(images))
+	}
+	return nil
+}
+
+// prePull pulls the images FORGE_CODE_PREPULL names in the background
+// when the server starts, so first runs don't wait for them, and warms
+// their pools if pooling is on. Failures are only logged; runs pull what
+// is still missing.
+func prePull(images []string) {
+	ctx := context.Background()
+	for _, image := range images {
+		if !sandbox.ImagePresent(ctx, image) {
+			if err := sandbox.PullImage(ctx, image); err != nil {
+				fmt.Fprintf(os.Stderr, "pre-pull: %v\n", err)
+				continue
+			}
+		}
+		for _, lang := range languages {
+			if lang.Image != image {
+				continue
+			}
+			if p, ok := newSandbox(lang).(*sandbox.Pool); ok {
+				p.Warm(image, nil)
+			}
+		}
+	}
+}
+
+// pullImage pulls image if it isn't present yet, telling the client while
+// it does, since a first pull can take minutes. It returns a note for the
+// result, or "" if there was nothing to pull.
+func pullImage(ctx context.Context, image string, progress *progress) (string, error) {
+	if native || sandbox.ImagePresent(ctx, image) {
+		return "", nil
+	}
+	progress.note(fmt.Sprintf("image %s not present, pulling...\n", image))
+	start := time.Now()
+	if err := sandbox.PullImage(ctx, image); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("(pulled image %s first, in %s)", image, time.Since(start).Round(time.Second)), nil
+}
@@ -0,0 +1,201 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// language describes how to run a program: the image to run it in, the
+// file name the code is written to in /workspace, and the command, run
+// from /workspace. Compiled languages build into /tmp because /workspace
+// is read-only. When code_run is given an entrypoint, it replaces the file
+// name wherever it appears in the command.
+//
+// Install, if set, is a shell command that installs the packages given as
+// its arguments ("$@") into $DEPS, and Env holds the variables (which may
+// use $DEPS) that let the program find them.
+type language struct {
+	Image   string   `yaml:"image"`
+	File    string   `yaml:"file"`
+	Command []string `yaml:"command"`
+	Aliases []string `yaml:"aliases"`
+	Install string   `yaml:"install"`
+	Env     []string `yaml:"env"`
+
+	name string // the name it is configured under
+}
+
+// nodePackages installs npm packages for javascript and typescript.
+const nodePackages = `npm install --silent --no-audit --no-fund --prefix "$DEPS/node" "$@"`
+
+var builtinLanguages = map[string]language{
+	"python": {
+		Image:   "python:3.12-slim",
+		File:    "main.py",
+		Command: []string{"python", "main.py"},
+		Aliases: []string{"py", "python3"},
+		Install: `pip install --quiet --disable-pip-version-check --root-user-action=ignore --target "$DEPS/python" "$@"`,
+		Env:     []string{"PYTHONPATH=$DEPS/python"},
+	},
+	"javascript": {
+		Image:   "node:22-slim",
+		File:    "main.js",
+		Command: []string{"node", "main.js"},
+		Aliases: []string{"js", "node"},
+		Install: nodePackages,
+		Env:     []string{"NODE_PATH=$DEPS/node/node_modules"},
+	},
+	"typescript": {
+		Image:   "node:22-slim",
+		File:    "main.ts",
+		Command: []string{"node", "--experimental-transform-types", "--disable-warning=ExperimentalWarning", "main.ts"},
+		Aliases: []string{"ts"},
+		Install: nodePackages,
+		Env:     []string{"NODE_PATH=$DEPS/node/node_modules"},
+	},
+	"go": {
+		// The program is built as a module so it can import packages, which
+		// come from the module cache in $DEPS acting as an offline proxy.
+		Image:   "golang:1.23-alpine",
+		File:    "main.go",
+		Command: []string{"sh", "-c", `t=${TMPDIR:-/tmp}; mkdir -p "$t/app" && cp -r . "$t/app" && cd "$t/app" && { [ -f go.mod ] || go mod init main >/dev/null 2>&1; } && go mod tidy >/dev/null 2>"$t/tidy.log" || { cat "$t/tidy.log" >&2; exit 1; }; go run "./$(dirname main.go)"`},
+		Aliases: []string{"golang"},
+		Install: `export GOMODCACHE="$DEPS/gomod" && mkdir -p /tmp/deps && cd /tmp/deps && go mod init deps >/dev/null 2>&1 && go get "$@"`,
+		Env:     []string{"GOPROXY=file://$DEPS/gomod/cache/download", "GOSUMDB=off", "GOMODCACHE=/tmp/gomod"},
+	},
+	"ruby": {
+		Image:   "ruby:3.3-slim",
+		File:    "main.rb",
+		Command: []string{"ruby", "main.rb"},
+		Aliases: []string{"rb"},
+		Install: `gem install --silent --no-document --install-dir "$DEPS/gems" "$@"`,
+		Env:     []string{"GEM_PATH=$DEPS/gems"},
+	},
+	"rust": {
+		Image:   "rust:1.83-slim",
+		File:    "main.rs",
+		Command: []string{"sh", "-c", `t=${TMPDIR:-/tmp}; rustc -o "$t/main" main.rs && "$t/main"`},
+		Aliases: []string{"rs"},
+	},
+	"java": {
+		// Single-file source launch: the first class declared is run.
+		Image:   "eclipse-temurin:21-jdk",
+		File:    "Main.java",
+		Command: []string{"java", "Main.java"},
+	},
+	"c": {
+		Image:   "gcc:14",
+		File:    "main.c",
+		Command: []string{"sh", "-c", `t=${TMPDIR:-/tmp}; gcc -O2 -o "$t/main" $(find . -name '*.c') -lm && "$t/main"`},
+	},
+	"cpp": {
+		Image:   "gcc:14",
+		File:    "main.cpp",
+		Command: []string{"sh", "-c", `t=${TMPDIR:-/tmp}; g++ -O2 -std=c++20 -o "$t/main" $(find . -name '*.cpp' -o -name '*.cc') && "$t/main"`},
+		Aliases: []string{"c++", "cxx"},
+	},
+	"bash": {
+		Image:   "bash:5.2",
+		File:    "main.sh",
+		Command: []string{"bash", "main.sh"},
+		Aliases: []string{"sh", "shell"},
+	},
+}
+
+// languages holds the built-in languages plus any from FORGE_CODE_LANGUAGES,
+// and aliases maps alternate names to them. If the setting can't be parsed,
+// languagesErr is set and code_run reports it.
+var (
+	languages    map[string]language
+	aliases      map[string]string
+	languagesErr error
+)
+
+// loadLanguages merges extra languages, given as YAML (or JSON) keyed by
+// name, over the built-ins. An entry with a built-in's name replaces it,
+// which is how to pin a different image version.
+func loadLanguages(extra string) (map[string]language, map[string]string, error) {
+	langs := make(map[string]language, len(builtinLanguages))
+	for name, l := range builtinLanguages {
+		langs[name] = l
+	}
+	if strings.TrimSpace(extra) != "" {
+		var custom map[string]language
+		if err := yaml.Unmarshal([]byte(extra), &custom); err != nil {
+			return nil, nil, fmt.Errorf("FORGE_CODE_LANGUAGES: %w", err)
+		}
+		for name, l := range custom {
+			switch {
+			case l.Image == "":
+				return nil, nil, fmt.Errorf("FORGE_CODE_LANGUAGES: %s: image is required", name)
+			case l.File == "" || strings.ContainsAny(l.File, `/\`):
+				return nil, nil, fmt.Errorf("FORGE_CODE_LANGUAGES: %s: file must be a plain file name", name)
+			case len(l.Command) == 0:
+				return nil, nil, fmt.Errorf("FORGE_CODE_LANGUAGES: %s: command is required", name)
+			}
+			langs[strings.ToLower(name)] = l
+		}
+	}
+
+	alias := make(map[string]string)
+	for name, l := range langs {
+		l.name = name
+		langs[name] = l
+		for _, a := range l.Aliases {
+			if _, taken := langs[a]; !taken {
+				alias[strings.ToLower(a)] = name
+			}
+		}
+	}
+	return langs, alias, nil
+}
+
+// lookupLanguage finds a language by name or alias.
+func lookupLanguage(name string) (language, bool) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if canonical, ok := aliases[name]; ok {
+		name = canonical
+	}
+	l, ok := languages[name]
+	return l, ok
+}
+
+func languageNames() []string {
+	names := make([]string, 0, len(languages))
+	for name := range languages {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// languageImages returns every configured image, for the sandbox allowlist.
+func languageImages() []string {
+	var images []string
+	for _, l := range languages {
+		if !slices.Contains(images, l.Image) {
+			images = append(images, l.Image)
+		}
+	}
+	sort.Strings(images)
+	return images
+}
+
+// commandFor returns lang's command with its file name replaced by
+// entrypoint.
+func commandFor(lang language, entrypoint string) []string {
+	if entrypoint == lang.File {
+		return lang.Command
+	}
+	re := regexp.MustCompile(`(^|[^\w.])` + regexp.QuoteMeta(lang.File) + `($|[^\w.])`)
+	cmd := make([]string, len(lang.Command))
+	for i, arg := range lang.Command {
+		cmd[i] = re.ReplaceAllString(arg, "${1}"+strings.ReplaceAll(entrypoint, "$", "$$")+"${2}")
+	}
+	return cmd
+}
@@ -3,104 +3,288 @@ package main
 import (
 	"context"
 	"fmt"
+	"os"
+	"path"
+	"regexp"
 	"strings"
+	"time"
 
 	"github.com/mark3labs/mcp-go/mcp"
 	"github.com/mark3labs/mcp-go/server"
 	"github.com/michaelbrown/forge/internal/sandbox"
 )
 
-var languageConfig = map[string]struct {
-	image   string
-	command func(string) []string
-}{
-	"python": {
-		image:   "python:3.12-slim",
-		command: func(_ string) []string { return []string{"python", "/workspace/code"} },
-	},
-	"javascript": {
-		image:   "node:22-slim",
-		command: func(_ string) []string { return []string{"node", "/workspace/code"} },
-	},
-	"go": {
-		image:   "golang:1.23-alpine",
-		command: func(_ string) []string { return []string{"go", "run", "/workspace/code"} },
-	},
-	"ruby": {
-		image:   "ruby:3.3-slim",
-		command: func(_ string) []string { return []string{"ruby", "/workspace/code"} },
-	},
-}
-
 func main() {
-	s := server.NewMCPServer("forge-code-runner", "0.1.0")
-
-	// Build language list for description
-	var langs []string
-	for lang := range languageConfig {
-		langs = append(langs, lang)
+	languages, aliases, languagesErr = loadLanguages(os.Getenv("FORGE_CODE_LANGUAGES"))
+	if languagesErr != nil {
+		// Describe the built-ins; code_run reports the error.
+		languages, aliases, _ = loadLanguages("")
+	}
+	setupSandboxes()
+	// "pull [language...]" pulls images instead of serving.
+	if len(os.Args) > 1 && os.Args[1] == "pull" {
+		if err := runPull(os.Args[2:]); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+	if len(prePullImages) > 0 {
+		go prePull(prePullImages)
+	}
+	langs := strings.Join(languageNames(), ", ")
+	runsIn := "in a Docker sandbox"
+	if native {
+		runsIn = "as a restricted local process (Docker isn't available, so packages and sandbox_start can't be used)"
 	}
+	if maxOutput := sandboxPolicy().MaxOutput; maxOutput != "" {
+		runsIn += fmt.Sprintf(". Files the program writes to $OUTPUT_DIR (/output in a container), up to %s in all, are saved with the session, e.g. charts or reports", maxOutput)
+	}
+
+	s := server.NewMCPServer("forge-code-runner", "0.1.0")
 
 	s.AddTool(mcp.Tool{
 		Name:        "code_run",
-		Description: fmt.Sprintf("Execute code in a Docker sandbox. Supported languages: %s.", strings.Join(langs, ", ")),
+		Description: fmt.Sprintf("Execute code %s. Supported languages: %s.", runsIn, langs),
 		InputSchema: mcp.ToolInputSchema{
 			Type: "object",
 			Properties: map[string]any{
 				"language": map[string]any{
 					"type":        "string",
-					"description": "Programming language (python, javascript, go, ruby)",
+					"description": fmt.Sprintf("Programming language (%s)", langs),
 				},
 				"code": map[string]any{
 					"type":        "string",
-					"description": "Source code to execute",
+					"description": "Source code to execute. Optional when 'files' is given; it is then saved as the entrypoint.",
+				},
+				"files": map[string]any{
+					"type":                 "object",
+					"additionalProperties": map[string]any{"type": "string"},
+					"description":          "Project files to place in /workspace, as relative path -> content, for programs with modules, tests, or fixtures, e.g. {\"main.py\": \"...\", \"util/helpers.py\": \"...\", \"data.csv\": \"...\"}",
+				},
+				"entrypoint": map[string]any{
+					"type":        "string",
+					"description": "File in 'files' to run (default: the language's main file, such as main.py or main.go, or the only file given)",
 				},
 				"stdin": map[string]any{
 					"type":        "string",
 					"description": "Standard input to provide to the program (optional)",
 				},
+				"packages": map[string]any{
+					"type":        "array",
+					"items":       map[string]any{"type": "string"},
+					"description": packagesDescription,
+				},
+				"sandbox_id": map[string]any{
+					"type":        "string",
+					"description": "Run in a sandbox started with sandbox_start instead of a fresh container (optional); same as sandbox_exec",
+				},
+				"save_files": map[string]any{
+					"type":        "array",
+					"items":       map[string]any{"type": "string"},
+					"description": "Only with sandbox_id: " + saveFilesDescription,
+				},
 			},
-			Required: []string{"language", "code"},
+			Required: []string{"language"},
 		},
 	}, handleCodeRun)
 
-	if err := server.ServeStdio(s); err != nil {
+	s.AddTool(mcp.Tool{
+		Name:        "sandbox_start",
+		Description: "Start a sandbox container that stays up between runs and return its sandbox_id. Files and installed packages persist across sandbox_exec calls, and each run skips container startup, which makes iterating on a program much faster. Stop it with sandbox_stop when done.",
+		InputSchema: mcp.ToolInputSchema{
+			Type: "object",
+			Properties: map[string]any{
+				"language": map[string]any{
+					"type":        "string",
+					"description": fmt.Sprintf("Programming language of the sandbox (%s)", langs),
+				},
+				"files": map[string]any{
+					"type":                 "object",
+					"additionalProperties": map[string]any{"type": "string"},
+					"description":          "Files to place in /workspace to start with, as relative path -> content (optional)",
+				},
+				"packages": map[string]any{
+					"type":        "array",
+					"items":       map[string]any{"type": "string"},
+					"description": packagesDescription,
+				},
+			},
+			Required: []string{"language"},
+		},
+	}, handleSandboxStart)
+
+	s.AddTool(mcp.Tool{
+		Name:        "sandbox_exec",
+		Description: "Run code or a shell command in a sandbox started with sandbox_start. Given files are written over the ones already in /workspace; everything else stays. Each run is a new process, so variables in memory don't carry over, but files the program writes do. A run that times out stops the sandbox.",
+		InputSchema: mcp.ToolInputSchema{
+			Type: "object",
+			Properties: map[string]any{
+				"sandbox_id": map[string]any{
+					"type":        "string",
+					"description": "Sandbox to run in",
+				},
+				"code": map[string]any{
+					"type":        "string",
+					"description": "Source code to save as the entrypoint and run (optional)",
+				},
+				"files": map[string]any{
+					"type":                 "object",
+					"additionalProperties": map[string]any{"type": "string"},
+					"description":          "Files to add or replace in /workspace, as relative path -> content (optional)",
+				},
+				"entrypoint": map[string]any{
+					"type":        "string",
+					"description": "File in /workspace to run (default: the language's main file, or the only file given)",
+				},
+				"command": map[string]any{
+					"type":        "string",
+					"description": "Shell command to run in /workspace instead of the entrypoint, e.g. 'pytest -q' or 'ls -R' (optional)",
+				},
+				"stdin": map[string]any{
+					"type":        "string",
+					"description": "Standard input to provide to the program (optional)",
+				},
+				"packages": map[string]any{
+					"type":        "array",
+					"items":       map[string]any{"type": "string"},
+					"description": "More dependencies to install into the sandbox before running (optional). Given alone, they are installed without running anything.",
+				},
+				"timeout_seconds": map[string]any{
+					"type":        "integer",
+					"description": fmt.Sprintf("Stop the sandbox if the run takes longer than this (default: %d, max: %d)", int(defaultExecTimeout.Seconds()), int(maxExecTimeout.Seconds())),
+				},
+				"save_files": map[string]any{
+					"type":        "array",
+					"items":       map[string]any{"type": "string"},
+					"description": saveFilesDescription,
+				},
+			},
+			Required: []string{"sandbox_id"},
+		},
+	}, handleSandboxExec)
+
+	s.AddTool(mcp.Tool{
+		Name:        "sandbox_stop",
+		Description: "Stop a sandbox started with sandbox_start and delete its files.",
+		InputSchema: mcp.ToolInputSchema{
+			Type: "object",
+			Properties: map[string]any{
+				"sandbox_id": map[string]any{
+					"type":        "string",
+					"description": "Sandbox to stop",
+				},
+			},
+			Required: []string{"sandbox_id"},
+		},
+	}, handleSandboxStop)
+
+	err := server.ServeStdio(s)
+	// Sandbox containers run detached and would otherwise outlive the
+	// server.
+	stopSandboxes()
+	if err != nil {
 		fmt.Printf("server error: %v\n", err)
 	}
 }
 
+const saveFilesDescription = "Files in /workspace to save with the session after the run, such as charts, reports, or other outputs the user may want to download, e.g. ['plot.png', 'out/report.csv'] (optional)"
+
+const packagesDescription = "Dependencies to install before running (optional): pip packages for python, npm packages for javascript/typescript, gems for ruby, module paths for go. Version specifiers such as 'requests==2.32.3' or 'lodash@4' are allowed. Installs are cached, so repeating a set is fast."
+
 func handleCodeRun(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 	args, _ := request.Params.Arguments.(map[string]any)
 	if args == nil {
 		return errResult("error: invalid arguments"), nil
 	}
+	if id, _ := args["sandbox_id"].(string); id != "" {
+		return handleSandboxExec(ctx, request)
+	}
+	if len(stringList(args["save_files"])) > 0 {
+		return errResult("error: 'save_files' needs a sandbox; write the files to $OUTPUT_DIR instead, or start one with sandbox_start and pass its sandbox_id"), nil
+	}
 
 	language, _ := args["language"].(string)
 	code, _ := args["code"].(string)
 	stdin, _ := args["stdin"].(string)
 
-	if language == "" || code == "" {
-		return errResult("error: 'language' and 'code' are required"), nil
+	files, err := fileMap(args["files"])
+	if err != nil {
+		return errResult(fmt.Sprintf("error: %v", err)), nil
+	}
+	if language == "" || code == "" && len(files) == 0 {
+		return errResult("error: 'language' and 'code' or 'files' are required"), nil
 	}
 
-	langCfg, ok := languageConfig[language]
+	if languagesErr != nil {
+		return errResult(fmt.Sprintf("error: %v", languagesErr)), nil
+	}
+	if policyErr != nil {
+		return errResult(fmt.Sprintf("error: %v", policyErr)), nil
+	}
+	lang, ok := lookupLanguage(language)
 	if !ok {
-		return errResult(fmt.Sprintf("error: unsupported language %q", language)), nil
+		return errResult(fmt.Sprintf("error: unsupported language %q (supported: %s)", language, strings.Join(languageNames(), ", "))), nil
+	}
+
+	policy := policyFor(lang.name)
+	sb := newSandbox(lang)
+
+	entrypoint, err := pickEntrypoint(lang, args, code, files)
+	if err != nil {
+		return errResult(fmt.Sprintf("error: %v", err)), nil
 	}
 
-	policy := sandbox.DefaultPolicy()
-	sb := sandbox.NewDockerSandbox(policy)
+	opts := sandbox.ExecOpts{
+		Image:    lang.Image,
+		Command:  commandFor(lang, entrypoint),
+		Code:     code,
+		Filename: entrypoint,
+		Files:    files,
+		Stdin:    stdin,
+	}
+	packages := stringList(args["packages"])
+	if len(packages) > 0 {
+		if err := checkPackages(lang, packages); err != nil {
+			return errResult(fmt.Sprintf("error: %s: %v", language, err)), nil
+		}
+		if native {
+			return errResult("error: packages need Docker, which isn't available"), nil
+		}
+	}
 
-	result, err := sb.Exec(ctx, sandbox.ExecOpts{
-		Image:   langCfg.image,
-		Command: langCfg.command(language),
-		Code:    code,
-		Stdin:   stdin,
-	})
+	progress := startProgress(ctx, request)
+	defer progress.stop()
+	pulled, err := pullImage(ctx, lang.Image, progress)
 	if err != nil {
 		return errResult(fmt.Sprintf("error: %v", err)), nil
 	}
+	if len(packages) > 0 {
+		if err := installPackages(ctx, policy, lang, packages); err != nil {
+			return errResult(fmt.Sprintf("error: %s: %v", language, err)), nil
+		}
+		opts.Env, opts.Mounts = depsRun(lang)
+	}
 
+	opts.OnOutput = progress.output()
+	result, err := sb.Exec(ctx, opts)
+	if err != nil {
+		return errResult(fmt.Sprintf("error: %v", err)), nil
+	}
+	res := runResult(result, policy)
+	if pulled != "" {
+		text := res.Content[0].(mcp.TextContent)
+		text.Text = pulled + "\n" + text.Text
+		res.Content[0] = text
+	}
+	return res, nil
+}
+
+// runResult reports a program's output, then why it stopped if it
+// failed, and how long it ran and how much memory it used, followed by the
+// files it left in /output, going by the limits in policy. A nonzero exit
+// is an error.
+func runResult(result *sandbox.ExecResult, policy sandbox.Policy) *mcp.CallToolResult {
 	var output strings.Builder
 	if result.Stdout != "" {
 		output.WriteString(result.Stdout)
@@ -111,19 +295,116 @@ func handleCodeRun(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallT
 		}
 		output.WriteString("STDERR:\n" + result.Stderr)
 	}
-	if result.ExitCode != 0 {
-		output.WriteString(fmt.Sprintf("\nexit code: %d", result.ExitCode))
-	}
 
 	text := output.String()
 	if len(text) > 4000 {
 		text = text[:4000] + "\n... (output truncated)"
 	}
 
+	switch {
+	case result.TimedOut:
+		text += fmt.Sprintf("\ntimed out after %s; the program was killed", policy.MaxTimeout)
+	case result.OOMKilled:
+		text += fmt.Sprintf("\nout of memory: killed for going over the %s limit (exit code %d)", policy.MaxMemory, result.ExitCode)
+	case result.ExitCode != 0:
+		text += fmt.Sprintf("\nexit code: %d", result.ExitCode)
+	}
+	stats := "ran for " + result.Duration.Round(10*time.Millisecond).String()
+	if result.MaxMemory > 0 {
+		stats += fmt.Sprintf(", peak memory %.1f MB", float64(result.MaxMemory)/(1<<20))
+	}
+	text = strings.TrimPrefix(text+"\n("+stats+")", "\n")
+	if len(result.OutputsDropped) > 0 {
+		text += fmt.Sprintf("\nnot saved, over the %s output limit: %s", policy.MaxOutput, strings.Join(result.OutputsDropped, ", "))
+	}
+
+	res := &mcp.CallToolResult{
+		Content: []mcp.Content{mcp.TextContent{Type: "text", Text: text}},
+		IsError: result.ExitCode != 0 || result.OOMKilled,
+	}
+	for _, f := range result.Outputs {
+		res.Content = append(res.Content, embedFile(f.Name, f.Data))
+	}
+	return res
+}
+
+const (
+	maxFiles      = 200
+	maxFilesBytes = 5 << 20
+)
+
+// fileMap reads the files argument.
+func fileMap(v any) (map[string]string, error) {
+	raw, _ := v.(map[string]any)
+	if len(raw) > maxFiles {
+		return nil, fmt.Errorf("too many files (%d, max %d)", len(raw), maxFiles)
+	}
+	files := make(map[string]string, len(raw))
+	size := 0
+	for name, content := range raw {
+		s, ok := content.(string)
+		if !ok {
+			return nil, fmt.Errorf("content of file %q must be a string", name)
+		}
+		if size += len(s); size > maxFilesBytes {
+			return nil, fmt.Errorf("files are larger than %d MB in total", maxFilesBytes>>20)
+		}
+		files[path.Clean(name)] = s
+	}
+	return files, nil
+}
+
+// entrypointName keeps the entrypoint safe to put in a shell command.
+var entrypointName = regexp.MustCompile(`^[A-Za-z0-9_][A-Za-z0-9._/-]*$`)
+
+// pickEntrypoint decides which file to run. Inline code is saved as the
+// entrypoint; otherwise it must be one of the files.
+func pickEntrypoint(lang language, args map[string]any, code string, files map[string]string) (string, error) {
+	entrypoint, _ := args["entrypoint"].(string)
+	if entrypoint != "" {
+		entrypoint = path.Clean(entrypoint)
+	}
+	_, hasMain := files[lang.File]
+	switch {
+	case entrypoint == "" && (code != "" || hasMain):
+		entrypoint = lang.File
+	case entrypoint == "" && len(files) == 1:
+		for name := range files {
+			entrypoint = name
+		}
+	case entrypoint == "":
+		return "", fmt.Errorf("'entrypoint' is required: files has no %s", lang.File)
+	}
+	if !entrypointName.MatchString(entrypoint) || strings.Contains(entrypoint, "..") {
+		return "", fmt.Errorf("invalid entrypoint %q", entrypoint)
+	}
+	_, inFiles := files[entrypoint]
+	switch {
+	case code != "" && inFiles:
+		return "", fmt.Errorf("'code' would overwrite %s from 'files'", entrypoint)
+	case code == "" && !inFiles:
+		return "", fmt.Errorf("entrypoint %s is not in 'files'", entrypoint)
+	}
+	return entrypoint, nil
+}
+
+// stringList converts a JSON array argument to strings, skipping anything
+// else.
+func stringList(v any) []string {
+	items, _ := v.([]any)
+	var out []string
+	for _, item := range items {
+		if s, ok := item.(string); ok && s != "" {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+func textResult(text string) *mcp.CallToolResult {
 	return &mcp.CallToolResult{
 		Content: []mcp.Content{mcp.TextContent{Type: "text", Text: text}},
-		IsError: result.ExitCode != 0,
-	}, nil
+	}
 }
 
 func errResult(text string) *mcp.CallToolResult {
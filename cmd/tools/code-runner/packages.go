@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/michaelbrown/forge/internal/sandbox"
+)
+
+// Packages are installed into a named Docker volume, in a directory per
+// image, with the network enabled only for the install. A marker file
+// records each package set installed, so repeat runs skip the install. The
+// run then mounts the volume read-only.
+
+const (
+	depsMount      = "/deps"
+	installTimeout = 5 * time.Minute
+)
+
+// packageName rejects anything that could be read as an installer option.
+var packageName = regexp.MustCompile(`^[A-Za-z0-9@][A-Za-z0-9._@/:=<>!~+,\[\]-]*$`)
+
+func depsVolume() string {
+	if v := os.Getenv("FORGE_CODE_DEPS_VOLUME"); v != "" {
+		return v
+	}
+	return "forge-code-deps"
+}
+
+// depsDir is where packages for image live inside the volume.
+func depsDir(image string) string {
+	key := strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '.' || r == '-' {
+			return r
+		}
+		return '_'
+	}, image)
+	return depsMount + "/" + key
+}
+
+// checkPackages reports whether packages can be installed for lang, going
+// by the language and the names alone.
+func checkPackages(lang language, packages []string) error {
+	if lang.Install == "" {
+		return fmt.Errorf("packages are not supported for this language")
+	}
+	for _, p := range packages {
+		if !packageName.MatchString(p) {
+			return fmt.Errorf("invalid package name %q", p)
+		}
+	}
+	return nil
+}
+
+// installPackages installs packages for lang, unless that set is already
+// installed. depsRun gives what a run needs to use them.
+func installPackages(ctx context.Context, policy sandbox.Policy, lang language, packages []string) error {
+	if err := checkPackages(lang, packages); err != nil {
+		return err
+	}
+
+	sorted := slices.Sorted(slices.Values(packages))
+	sum := sha256.Sum256([]byte(strings.Join(sorted, "\n")))
+	marker := "$DEPS/.installed-" + hex.EncodeToString(sum[:8])
+	script := fmt.Sprintf(`[ -f "%[1]s" ] && exit 0; mkdir -p "$DEPS" && { %[2]s; } && touch "%[1]s"`, marker, lang.Install)
+
+	dir := depsDir(lang.Image)
+	// Installers can take a while and fill /tmp with downloads.
+	installPolicy := policy
+	installPolicy.Network = true
+	installPolicy.MaxTimeout = installTimeout
+	installPolicy.MaxDisk = ""
+	installPolicy.MaxOutput = ""
+	ctx, cancel := context.WithTimeout(ctx, installTimeout)
+	defer cancel()
+	result, err := sandbox.NewDockerSandbox(installPolicy).Exec(ctx, sandbox.ExecOpts{
+		Image:   lang.Image,
+		Command: append([]string{"sh", "-c", script, "sh"}, packages...),
+		Env:     []string{"DEPS=" + dir},
+		Mounts:  []sandbox.Mount{{Source: depsVolume(), Target: depsMount}},
+	})
+	if err != nil {
+		return fmt.Errorf("installing packages: %w", err)
+	}
+	if result.TimedOut {
+		return fmt.Errorf("installing packages timed out after %s", installTimeout)
+	}
+	if result.ExitCode != 0 {
+		output := strings.TrimSpace(result.Stderr + "\n" + result.Stdout)
+		if len(output) > 2000 {
+			output = output[len(output)-2000:]
+		}
+		return fmt.Errorf("installing packages failed (exit code %d):\n%s", result.ExitCode, output)
+	}
+	return nil
+}
+
+// depsRun returns the environment and read-only volume mount that let a
+// program run with lang find installed packages.
+func depsRun(lang language) ([]string, []sandbox.Mount) {
+	dir := depsDir(lang.Image)
+	env := make([]string, len(lang.Env))
+	for i, e := range lang.Env {
+		env[i] = strings.ReplaceAll(e, "$DEPS", dir)
+	}
+	return env, []sandbox.Mount{{Source: depsVolume(), Target: depsMount, ReadOnly: true}}
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/michaelbrown/forge/internal/sandbox"
+)
+
+// policyOverride changes the sandbox policy for one language's runs, such
+// as more memory for a compiler or the network for Go's module downloads.
+// Unset fields keep the default.
+type policyOverride struct {
+	Image   string        `yaml:"image"`
+	Memory  string        `yaml:"memory"`
+	Timeout time.Duration `yaml:"timeout"`
+	Network *bool         `yaml:"network"`
+}
+
+// overrides holds the policy overrides from FORGE_CODE_POLICY, by
+// language name.
+var overrides map[string]policyOverride
+
+// loadOverrides reads overrides given as YAML (or JSON) keyed by language
+// name or alias, and sets the images they give on languages, which must be
+// loaded first.
+func loadOverrides(setting string) (map[string]policyOverride, error) {
+	if strings.TrimSpace(setting) == "" {
+		return nil, nil
+	}
+	var raw map[string]policyOverride
+	if err := yaml.Unmarshal([]byte(setting), &raw); err != nil {
+		return nil, fmt.Errorf("FORGE_CODE_POLICY: %w", err)
+	}
+	out := make(map[string]policyOverride, len(raw))
+	for name, o := range raw {
+		lang, ok := lookupLanguage(name)
+		if !ok {
+			return nil, fmt.Errorf("FORGE_CODE_POLICY: unknown language %q", name)
+		}
+		if o.Timeout < 0 {
+			return nil, fmt.Errorf("FORGE_CODE_POLICY: %s: invalid timeout %s", name, o.Timeout)
+		}
+		if o.Image != "" {
+			lang.Image = o.Image
+			languages[lang.name] = lang
+		}
+		out[lang.name] = o
+	}
+	return out, nil
+}
+
+// policyFor returns the sandbox policy for runs of the named language:
+// the default one with the language's overrides.
+func policyFor(name string) sandbox.Policy {
+	policy := sandboxPolicy()
+	o := overrides[name]
+	if o.Memory != "" {
+		policy.MaxMemory = o.Memory
+	}
+	if o.Timeout > 0 {
+		policy.MaxTimeout = o.Timeout
+	}
+	if o.Network != nil {
+		policy.Network = *o.Network
+	}
+	return policy
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/michaelbrown/forge/internal/sandbox"
+)
+
+const (
+	// progressInterval is how often output is sent while a program runs.
+	progressInterval = 250 * time.Millisecond
+	// maxProgressBytes bounds how much output is sent as progress; the
+	// result has it all anyway.
+	maxProgressBytes = 64 << 10
+)
+
+// progress sends a program's output to the client while it runs, as MCP
+// progress notifications with the output as their message, when the
+// client asked for progress on the call.
+type progress struct {
+	ctx   context.Context
+	srv   *server.MCPServer
+	token mcp.ProgressToken
+
+	mu      sync.Mutex
+	pending strings.Builder
+	count   int // notifications sent
+	sent    int // bytes sent or pending
+	done    chan struct{}
+	stopped chan struct{}
+}
+
+// startProgress starts sending output for request, or returns nil if the
+// client didn't ask for progress.
+func startProgress(ctx context.Context, request mcp.CallToolRequest) *progress {
+	srv := server.ServerFromContext(ctx)
+	if srv == nil || request.Params.Meta == nil || request.Params.Meta.ProgressToken == nil {
+		return nil
+	}
+	p := &progress{
+		ctx:     ctx,
+		srv:     srv,
+		token:   request.Params.Meta.ProgressToken,
+		done:    make(chan struct{}),
+		stopped: make(chan struct{}),
+	}
+	go p.run()
+	return p
+}
+
+// output returns what to pass as the sandbox's OnOutput: nil without
+// progress.
+func (p *progress) output() sandbox.OutputFunc {
+	if p == nil {
+		return nil
+	}
+	return func(stream, chunk string) {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		if p.sent >= maxProgressBytes {
+			return
+		}
+		if p.sent+len(chunk) > maxProgressBytes {
+			chunk = chunk[:maxProgressBytes-p.sent] + "\n... (more output when it finishes)\n"
+		}
+		p.sent += len(chunk)
+		p.pending.WriteString(chunk)
+	}
+}
+
+// note sends text to the client along with the output, such as what the
+// tool is doing before the program starts.
+func (p *progress) note(text string) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.pending.WriteString(text)
+}
+
+// stop sends what output is left and stops.
+func (p *progress) stop() {
+	if p == nil {
+		return
+	}
+	close(p.done)
+	<-p.stopped
+}
+
+func (p *progress) run() {
+	defer close(p.stopped)
+	ticker := time.NewTicker(progressInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-p.done:
+			p.flush()
+			return
+		case <-ticker.C:
+			p.flush()
+		}
+	}
+}
+
+// flush sends the output that came since the last notification.
+func (p *progress) flush() {
+	p.mu.Lock()
+	text := p.pending.String()
+	p.pending.Reset()
+	if text != "" {
+		p.count++
+	}
+	count := p.count
+	p.mu.Unlock()
+	if text == "" {
+		return
+	}
+	// A client too slow to keep up loses output; the result still has it.
+	p.srv.SendNotificationToClient(p.ctx, "notifications/progress", map[string]any{
+		"progressToken": p.token,
+		"progress":      count,
+		"message":       text,
+	})
+}
@@ -0,0 +1,420 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/michaelbrown/forge/internal/sandbox"
+)
+
+const (
+	// maxSandboxes bounds how many containers one server keeps alive.
+	maxSandboxes = 4
+
+	// maxPoolSize bounds FORGE_CODE_POOL_SIZE.
+	maxPoolSize     = 8
+	defaultPoolIdle = 10 * time.Minute
+
+	defaultExecTimeout = 60 * time.Second
+	maxExecTimeout     = 10 * time.Minute
+
+	// maxSavedFiles and maxSavedFileBytes bound what one run can save
+	// with save_files.
+	maxSavedFiles     = 10
+	maxSavedFileBytes = 10 << 20
+)
+
+// sandboxSession is a container kept running between calls, so files and
+// packages stay in place and each run skips container startup. Runs in a
+// session take turns.
+type sandboxSession struct {
+	sync.Mutex
+	id        string
+	language  string
+	lang      language
+	container *sandbox.Container
+}
+
+var sandboxes = struct {
+	sync.Mutex
+	m    map[string]*sandboxSession
+	next int
+}{m: make(map[string]*sandboxSession)}
+
+// stopSandboxes removes every sandbox container, and every pooled one,
+// which would otherwise outlive the server.
+func stopSandboxes() {
+	pools.Lock()
+	for _, p := range pools.m {
+		p.Close()
+	}
+	pools.Unlock()
+	sandboxes.Lock()
+	defer sandboxes.Unlock()
+	for id, s := range sandboxes.m {
+		s.container.Stop()
+		delete(sandboxes.m, id)
+	}
+}
+
+// native is set when Docker isn't available, and code runs as plain
+// processes instead. Packages and long-lived sandboxes need Docker. With
+// FORGE_CODE_RUNTIME set, nothing runs without Docker unless
+// FORGE_CODE_NATIVE=1 says plain processes will do.
+var native bool
+
+// pools keep containers ready for code_run, when FORGE_CODE_POOL_SIZE is
+// set. Containers run under their language's policy, so languages with
+// overrides get a pool of their own, under their name; the rest share the
+// one under "".
+var pools = struct {
+	sync.Mutex
+	opts sandbox.PoolOptions
+	m    map[string]*sandbox.Pool
+}{m: make(map[string]*sandbox.Pool)}
+
+// policyErr is set when the sandbox settings are invalid, and nothing
+// runs.
+var policyErr error
+
+// prePullImages are the images to pull when the server starts, from
+// FORGE_CODE_PREPULL.
+var prePullImages []string
+
+// setupSandboxes reads and checks the sandbox settings and whether Docker
+// is available.
+func setupSandboxes() {
+	var err error
+	if overrides, err = loadOverrides(os.Getenv("FORGE_CODE_POLICY")); err != nil {
+		policyErr = err
+		return
+	}
+	if err := sandboxPolicy().Validate(); err != nil {
+		policyErr = fmt.Errorf("FORGE_CODE_RUNTIME: %w", err)
+		return
+	}
+	for name := range overrides {
+		if err := policyFor(name).Validate(); err != nil {
+			policyErr = fmt.Errorf("FORGE_CODE_POLICY: %s: %w", name, err)
+			return
+		}
+	}
+	native = !sandbox.DockerAvailable(context.Background())
+	if runtime := os.Getenv("FORGE_CODE_RUNTIME"); native && runtime != "" && os.Getenv("FORGE_CODE_NATIVE") != "1" {
+		policyErr = fmt.Errorf("FORGE_CODE_RUNTIME is %s, but Docker isn't available; set FORGE_CODE_NATIVE=1 to run code as plain processes instead", runtime)
+		return
+	}
+	if pools.opts, err = poolOptions(); err != nil {
+		policyErr = err
+		return
+	}
+	if v := os.Getenv("FORGE_CODE_PREPULL"); v != "" && !native {
+		names := strings.FieldsFunc(v, func(r rune) bool { return r == ',' || r == ' ' })
+		if prePullImages, err = imagesFor(names); err != nil {
+			policyErr = fmt.Errorf("FORGE_CODE_PREPULL: %w", err)
+		}
+	}
+}
+
+// poolOptions reads FORGE_CODE_POOL_SIZE, the containers to keep ready
+// per image (none by default), and FORGE_CODE_POOL_IDLE, how long they
+// are kept unused.
+func poolOptions() (sandbox.PoolOptions, error) {
+	opts := sandbox.PoolOptions{IdleTTL: defaultPoolIdle}
+	if v := os.Getenv("FORGE_CODE_POOL_SIZE"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 || n > maxPoolSize {
+			return opts, fmt.Errorf("FORGE_CODE_POOL_SIZE: must be a number from 0 to %d", maxPoolSize)
+		}
+		opts.Size = n
+	}
+	if v := os.Getenv("FORGE_CODE_POOL_IDLE"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d < 0 {
+			return opts, fmt.Errorf("FORGE_CODE_POOL_IDLE: invalid duration %q", v)
+		}
+		opts.IdleTTL = d
+	}
+	return opts, nil
+}
+
+// newSandbox returns what one-off runs of lang execute in.
+func newSandbox(lang language) sandbox.Sandbox {
+	policy := policyFor(lang.name)
+	switch {
+	case native:
+		return sandbox.NewProcessSandbox(policy)
+	case pools.opts.Size > 0:
+		key := ""
+		if _, ok := overrides[lang.name]; ok {
+			key = lang.name
+		}
+		pools.Lock()
+		defer pools.Unlock()
+		p := pools.m[key]
+		if p == nil {
+			p = sandbox.NewPool(policy, pools.opts)
+			pools.m[key] = p
+		}
+		return p
+	}
+	return sandbox.NewDockerSandbox(policy)
+}
+
+func sandboxPolicy() sandbox.Policy {
+	policy := sandbox.DefaultPolicy()
+	policy.Images = languageImages()
+	policy.Runtime = os.Getenv("FORGE_CODE_RUNTIME")
+	return policy
+}
+
+func handleSandboxStart(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	args, _ := request.Params.Arguments.(map[string]any)
+	if args == nil {
+		return errResult("error: invalid arguments"), nil
+	}
+	language, _ := args["language"].(string)
+	if language == "" {
+		return errResult("error: 'language' is required"), nil
+	}
+	if languagesErr != nil {
+		return errResult(fmt.Sprintf("error: %v", languagesErr)), nil
+	}
+	if policyErr != nil {
+		return errResult(fmt.Sprintf("error: %v", policyErr)), nil
+	}
+	lang, ok := lookupLanguage(language)
+	if !ok {
+		return errResult(fmt.Sprintf("error: unsupported language %q (supported: %s)", language, strings.Join(languageNames(), ", "))), nil
+	}
+	files, err := fileMap(args["files"])
+	if err != nil {
+		return errResult(fmt.Sprintf("error: %v", err)), nil
+	}
+	packages := stringList(args["packages"])
+	if native {
+		return errResult("error: sandboxes need Docker, which isn't available; code_run can still run code without one"), nil
+	}
+
+	sandboxes.Lock()
+	full := len(sandboxes.m) >= maxSandboxes
+	sandboxes.Unlock()
+	if full {
+		return errResult(fmt.Sprintf("error: too many sandboxes (max %d); stop one with sandbox_stop", maxSandboxes)), nil
+	}
+
+	progress := startProgress(ctx, request)
+	defer progress.stop()
+	pulled, err := pullImage(ctx, lang.Image, progress)
+	if err != nil {
+		return errResult(fmt.Sprintf("error: %v", err)), nil
+	}
+	policy := policyFor(lang.name)
+	if len(packages) > 0 {
+		if err := installPackages(ctx, policy, lang, packages); err != nil {
+			return errResult(fmt.Sprintf("error: %s: %v", language, err)), nil
+		}
+	}
+	// The deps volume is mounted even without packages, so ones installed
+	// later with sandbox_exec show up in the running container.
+	var opts sandbox.StartOpts
+	if lang.Install != "" {
+		opts.Env, opts.Mounts = depsRun(lang)
+	}
+	opts.Image = lang.Image
+	c, err := sandbox.NewDockerSandbox(policy).Start(ctx, opts)
+	if err != nil {
+		return errResult(fmt.Sprintf("error: %v", err)), nil
+	}
+	if err := c.WriteFiles(ctx, files); err != nil {
+		c.Stop()
+		return errResult(fmt.Sprintf("error: %v", err)), nil
+	}
+
+	sandboxes.Lock()
+	defer sandboxes.Unlock()
+	sandboxes.next++
+	s := &sandboxSession{
+		id:        fmt.Sprintf("sb%d", sandboxes.next),
+		language:  language,
+		lang:      lang,
+		container: c,
+	}
+	sandboxes.m[s.id] = s
+	return textResult(strings.TrimPrefix(fmt.Sprintf("%s\nsandbox_id: %s\nlanguage: %s\nimage: %s", pulled, s.id, language, lang.Image), "\n")), nil
+}
+
+func handleSandboxExec(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	args, _ := request.Params.Arguments.(map[string]any)
+	if args == nil {
+		return errResult("error: invalid arguments"), nil
+	}
+	id, _ := args["sandbox_id"].(string)
+	code, _ := args["code"].(string)
+	command, _ := args["command"].(string)
+	stdin, _ := args["stdin"].(string)
+	packages := stringList(args["packages"])
+	saveFiles := stringList(args["save_files"])
+	if len(saveFiles) > maxSavedFiles {
+		return errResult(fmt.Sprintf("error: too many files to save (%d, max %d)", len(saveFiles), maxSavedFiles)), nil
+	}
+	files, err := fileMap(args["files"])
+	if err != nil {
+		return errResult(fmt.Sprintf("error: %v", err)), nil
+	}
+
+	sandboxes.Lock()
+	s := sandboxes.m[id]
+	sandboxes.Unlock()
+	if s == nil {
+		return errResult(fmt.Sprintf("error: no sandbox %q (start one with sandbox_start)", id)), nil
+	}
+	s.Lock()
+	defer s.Unlock()
+
+	if lang, ok := args["language"].(string); ok && lang != "" {
+		if l, ok := lookupLanguage(lang); !ok || l.Image != s.lang.Image {
+			return errResult(fmt.Sprintf("error: sandbox %s runs %s, not %s", id, s.language, lang)), nil
+		}
+	}
+
+	var run []string
+	if command == "" {
+		entrypoint, err := sandboxEntrypoint(s.lang, args, code, files)
+		if err != nil {
+			return errResult(fmt.Sprintf("error: %v", err)), nil
+		}
+		if code != "" {
+			files[entrypoint] = code
+		}
+		run = commandFor(s.lang, entrypoint)
+	} else {
+		if code != "" {
+			return errResult("error: give either 'code' or 'command', not both"), nil
+		}
+		run = []string{"sh", "-c", command}
+	}
+
+	if len(packages) > 0 {
+		if err := installPackages(ctx, policyFor(s.lang.name), s.lang, packages); err != nil {
+			return errResult(fmt.Sprintf("error: %s: %v", s.language, err)), nil
+		}
+	}
+	if err := s.container.WriteFiles(ctx, files); err != nil {
+		return errResult(fmt.Sprintf("error: %v", err)), nil
+	}
+	// Packages alone just install.
+	if len(packages) > 0 && code == "" && command == "" && len(files) == 0 {
+		return textResult(fmt.Sprintf("installed: %s", strings.Join(packages, ", "))), nil
+	}
+
+	timeout := defaultExecTimeout
+	if v, ok := args["timeout_seconds"].(float64); ok && v > 0 {
+		timeout = min(time.Duration(v)*time.Second, maxExecTimeout)
+	}
+	runCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	progress := startProgress(ctx, request)
+	result, err := s.container.Exec(runCtx, run, stdin, progress.output())
+	progress.stop()
+	if err != nil {
+		if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) {
+			// The program is still running in the container, so the
+			// sandbox goes with it.
+			sandboxes.Lock()
+			delete(sandboxes.m, id)
+			sandboxes.Unlock()
+			s.container.Stop()
+			if errors.Is(err, context.DeadlineExceeded) {
+				return errResult(fmt.Sprintf("error: timed out after %s; sandbox %s stopped", timeout, id)), nil
+			}
+			return errResult(fmt.Sprintf("error: canceled; sandbox %s stopped", id)), nil
+		}
+		return errResult(fmt.Sprintf("error: %v", err)), nil
+	}
+	res := runResult(result, policyFor(s.lang.name))
+	attachFiles(ctx, s.container, saveFiles, res)
+	return res, nil
+}
+
+// attachFiles adds files from the sandbox's /workspace to res as embedded
+// resources, which forge saves as session artifacts. Files that can't be
+// read are reported in the text instead.
+func attachFiles(ctx context.Context, c *sandbox.Container, names []string, res *mcp.CallToolResult) {
+	for _, name := range names {
+		data, err := c.ReadFile(ctx, name, maxSavedFileBytes)
+		if err == nil && len(data) == 0 {
+			err = errors.New("file is empty")
+		}
+		if err != nil {
+			res.Content = append(res.Content, mcp.TextContent{Type: "text", Text: fmt.Sprintf("not saved: %s: %v", name, err)})
+			continue
+		}
+		res.Content = append(res.Content, embedFile(name, data))
+	}
+}
+
+// embedFile returns a file as an embedded resource, which forge saves as a
+// session artifact.
+func embedFile(name string, data []byte) mcp.EmbeddedResource {
+	return mcp.EmbeddedResource{
+		Type: "resource",
+		Resource: mcp.BlobResourceContents{
+			URI:  "artifact:///" + path.Clean(name),
+			Blob: base64.StdEncoding.EncodeToString(data),
+		},
+	}
+}
+
+func handleSandboxStop(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	args, _ := request.Params.Arguments.(map[string]any)
+	if args == nil {
+		return errResult("error: invalid arguments"), nil
+	}
+	id, _ := args["sandbox_id"].(string)
+
+	sandboxes.Lock()
+	s := sandboxes.m[id]
+	delete(sandboxes.m, id)
+	sandboxes.Unlock()
+	if s == nil {
+		return errResult(fmt.Sprintf("error: no sandbox %q", id)), nil
+	}
+	if err := s.container.Stop(); err != nil {
+		return errResult(fmt.Sprintf("error: stopping sandbox %s: %v", id, err)), nil
+	}
+	return textResult("stopped sandbox " + id), nil
+}
+
+// sandboxEntrypoint decides which file to run in a sandbox. Unlike
+// code_run, the file may be left from an earlier call, so it needn't be in
+// files.
+func sandboxEntrypoint(lang language, args map[string]any, code string, files map[string]string) (string, error) {
+	entrypoint, _ := args["entrypoint"].(string)
+	if entrypoint != "" {
+		entrypoint = path.Clean(entrypoint)
+	} else {
+		entrypoint = lang.File
+		if _, hasMain := files[lang.File]; !hasMain && code == "" && len(files) == 1 {
+			for name := range files {
+				entrypoint = name
+			}
+		}
+	}
+	if !entrypointName.MatchString(entrypoint) || strings.Contains(entrypoint, "..") {
+		return "", fmt.Errorf("invalid entrypoint %q", entrypoint)
+	}
+	if _, inFiles := files[entrypoint]; code != "" && inFiles {
+		return "", fmt.Errorf("'code' would overwrite %s from 'files'", entrypoint)
+	}
+	return entrypoint, nil
+}
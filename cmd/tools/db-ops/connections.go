@@ -0,0 +1,159 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/go-sql-driver/mysql"
+	_ "github.com/lib/pq"
+	"gopkg.in/yaml.v3"
+	_ "modernc.org/sqlite"
+)
+
+// connection is a database named in FORGE_DB_CONNECTIONS. Connections are
+// read-only unless Writable is set.
+type connection struct {
+	Driver      string `yaml:"driver"`
+	DSN         string `yaml:"dsn"`
+	Writable    bool   `yaml:"writable"`
+	Description string `yaml:"description"`
+}
+
+// driverNames maps the accepted driver spellings to database/sql drivers.
+var driverNames = map[string]string{
+	"sqlite":     "sqlite",
+	"sqlite3":    "sqlite",
+	"postgres":   "postgres",
+	"postgresql": "postgres",
+	"mysql":      "mysql",
+	"mariadb":    "mysql",
+}
+
+// connections holds the configured databases. If FORGE_DB_CONNECTIONS can't
+// be parsed, connectionsErr is set and every tool reports it.
+var (
+	connections    map[string]connection
+	connectionsErr error
+)
+
+// envRef matches ${NAME} references, which DSNs may use for credentials.
+var envRef = regexp.MustCompile(`\$\{[A-Za-z_][A-Za-z0-9_]*\}`)
+
+// loadConnections parses connections given as YAML (or JSON) keyed by name.
+// ${NAME} in a DSN is replaced with that environment variable.
+func loadConnections(config string) (map[string]connection, error) {
+	conns := make(map[string]connection)
+	if strings.TrimSpace(config) == "" {
+		return conns, nil
+	}
+	if err := yaml.Unmarshal([]byte(config), &conns); err != nil {
+		return nil, fmt.Errorf("FORGE_DB_CONNECTIONS: %w", err)
+	}
+	for name, c := range conns {
+		driver, ok := driverNames[strings.ToLower(c.Driver)]
+		switch {
+		case !ok:
+			return nil, fmt.Errorf("FORGE_DB_CONNECTIONS: %s: unsupported driver %q (use sqlite, postgres, or mysql)", name, c.Driver)
+		case c.DSN == "":
+			return nil, fmt.Errorf("FORGE_DB_CONNECTIONS: %s: dsn is required", name)
+		}
+		c.Driver = driver
+		c.DSN = envRef.ReplaceAllStringFunc(c.DSN, func(ref string) string {
+			return os.Getenv(ref[2 : len(ref)-1])
+		})
+		conns[name] = c
+	}
+	return conns, nil
+}
+
+func connectionNames() []string {
+	names := make([]string, 0, len(connections))
+	for name := range connections {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// pools keeps one sql.DB per connection, opened on first use.
+var pools = struct {
+	sync.Mutex
+	m map[string]*sql.DB
+}{m: make(map[string]*sql.DB)}
+
+// openConnection returns the named connection and its pool.
+func openConnection(name string) (connection, *sql.DB, error) {
+	if connectionsErr != nil {
+		return connection{}, nil, connectionsErr
+	}
+	c, ok := connections[name]
+	if !ok {
+		if len(connections) == 0 {
+			return connection{}, nil, fmt.Errorf("no database connections are configured (set FORGE_DB_CONNECTIONS)")
+		}
+		return connection{}, nil, fmt.Errorf("unknown connection %q (configured: %s)", name, strings.Join(connectionNames(), ", "))
+	}
+
+	pools.Lock()
+	defer pools.Unlock()
+	if db := pools.m[name]; db != nil {
+		return c, db, nil
+	}
+	dsn := c.DSN
+	if c.Driver == "sqlite" && !c.Writable {
+		// Opening a missing file would create an empty database.
+		if path := sqlitePath(dsn); path != "" {
+			if _, err := os.Stat(path); err != nil {
+				return connection{}, nil, fmt.Errorf("%s: %w", name, err)
+			}
+		}
+		// SQLite ignores read-only transactions, so refuse writes on the
+		// connection itself.
+		sep := "?"
+		if strings.Contains(dsn, "?") {
+			sep = "&"
+		}
+		dsn += sep + "_pragma=query_only(1)"
+	}
+	if c.Driver == "mysql" {
+		// One statement per call keeps a read-only transaction from being
+		// committed partway through a query.
+		cfg, err := mysql.ParseDSN(dsn)
+		if err != nil {
+			return connection{}, nil, fmt.Errorf("%s: %w", name, err)
+		}
+		cfg.MultiStatements = false
+		dsn = cfg.FormatDSN()
+	}
+	db, err := sql.Open(c.Driver, dsn)
+	if err != nil {
+		return connection{}, nil, fmt.Errorf("%s: %w", name, err)
+	}
+	db.SetMaxOpenConns(4)
+	pools.m[name] = db
+	return c, db, nil
+}
+
+// sqlitePath returns the file a SQLite DSN opens, or "" for in-memory
+// databases.
+func sqlitePath(dsn string) string {
+	path, _, _ := strings.Cut(strings.TrimPrefix(dsn, "file:"), "?")
+	if path == "" || path == ":memory:" {
+		return ""
+	}
+	return path
+}
+
+func closePools() {
+	pools.Lock()
+	defer pools.Unlock()
+	for name, db := range pools.m {
+		db.Close()
+		delete(pools.m, name)
+	}
+}
@@ -0,0 +1,581 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+const (
+	defaultMaxRows = 100
+	maxMaxRows     = 1000
+
+	defaultTimeout = 30 * time.Second
+	maxTimeout     = 5 * time.Minute
+
+	// maxCellLen and maxOutputLen keep wide values and large results from
+	// flooding the context.
+	maxCellLen   = 200
+	maxOutputLen = 40_000
+)
+
+func main() {
+	connections, connectionsErr = loadConnections(os.Getenv("FORGE_DB_CONNECTIONS"))
+	if connectionsErr != nil {
+		fmt.Fprintf(os.Stderr, "forge-db-ops: %v\n", connectionsErr)
+	}
+
+	s := server.NewMCPServer("forge-db-ops", "0.1.0")
+
+	s.AddTool(mcp.Tool{
+		Name:        "db_connections",
+		Description: "List the configured database connections with their driver, whether they are read-only, and a description.",
+		InputSchema: mcp.ToolInputSchema{
+			Type:       "object",
+			Properties: map[string]any{},
+		},
+	}, handleConnections)
+
+	s.AddTool(mcp.Tool{
+		Name:        "db_schema",
+		Description: "Describe a database's schema. Without 'table', lists every table and view with its columns; with 'table', also shows its indexes and foreign keys.",
+		InputSchema: mcp.ToolInputSchema{
+			Type: "object",
+			Properties: map[string]any{
+				"connection": map[string]any{
+					"type":        "string",
+					"description": "Connection name from db_connections",
+				},
+				"table": map[string]any{
+					"type":        "string",
+					"description": "Table or view to describe (optional; Postgres tables outside the current schema as schema.table)",
+				},
+			},
+			Required: []string{"connection"},
+		},
+	}, handleSchema)
+
+	s.AddTool(mcp.Tool{
+		Name:        "db_query",
+		Description: "Run one SQL statement and return the rows as a Markdown table. Read-only connections accept SELECT, WITH, VALUES, SHOW, DESCRIBE, EXPLAIN, and PRAGMA, and run them in a read-only transaction; writable connections also accept INSERT, UPDATE, DELETE, and DDL, and report rows affected.",
+		InputSchema: mcp.ToolInputSchema{
+			Type: "object",
+			Properties: map[string]any{
+				"connection": map[string]any{
+					"type":        "string",
+					"description": "Connection name from db_connections",
+				},
+				"sql": map[string]any{
+					"type":        "string",
+					"description": "The statement to run",
+				},
+				"params": map[string]any{
+					"type":        "array",
+					"description": "Values for placeholders in 'sql': $1, $2 for Postgres; ? for SQLite and MySQL",
+				},
+				"max_rows": map[string]any{
+					"type":        "integer",
+					"description": fmt.Sprintf("Maximum rows to return (default %d, max %d)", defaultMaxRows, maxMaxRows),
+				},
+				"timeout_seconds": map[string]any{
+					"type":        "integer",
+					"description": fmt.Sprintf("Cancel the statement after this many seconds (default %d, max %d)", int(defaultTimeout.Seconds()), int(maxTimeout.Seconds())),
+				},
+			},
+			Required: []string{"connection", "sql"},
+		},
+	}, handleQuery)
+
+	s.AddTool(mcp.Tool{
+		Name:        "db_explain",
+		Description: "Show the query plan for a SQL statement without running it. With analyze=true, the statement is run (read statements only) and the plan includes actual timings, on Postgres and MySQL.",
+		InputSchema: mcp.ToolInputSchema{
+			Type: "object",
+			Properties: map[string]any{
+				"connection": map[string]any{
+					"type":        "string",
+					"description": "Connection name from db_connections",
+				},
+				"sql": map[string]any{
+					"type":        "string",
+					"description": "The statement to explain",
+				},
+				"params": map[string]any{
+					"type":        "array",
+					"description": "Values for placeholders in 'sql'",
+				},
+				"analyze": map[string]any{
+					"type":        "boolean",
+					"description": "Run the statement and report actual row counts and timings (default false)",
+				},
+			},
+			Required: []string{"connection", "sql"},
+		},
+	}, handleExplain)
+
+	err := server.ServeStdio(s)
+	closePools()
+	if err != nil {
+		fmt.Printf("server error: %v\n", err)
+	}
+}
+
+func getArgs(request mcp.CallToolRequest) map[string]any {
+	args, _ := request.Params.Arguments.(map[string]any)
+	return args
+}
+
+func textResult(text string) *mcp.CallToolResult {
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{mcp.TextContent{Type: "text", Text: text}},
+	}
+}
+
+func errResult(text string) *mcp.CallToolResult {
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{mcp.TextContent{Type: "text", Text: text}},
+		IsError: true,
+	}
+}
+
+func handleConnections(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	if connectionsErr != nil {
+		return errResult(fmt.Sprintf("error: %v", connectionsErr)), nil
+	}
+	if len(connections) == 0 {
+		return textResult("No database connections are configured (set FORGE_DB_CONNECTIONS)."), nil
+	}
+	var b strings.Builder
+	for _, name := range connectionNames() {
+		c := connections[name]
+		mode := "read-only"
+		if c.Writable {
+			mode = "writable"
+		}
+		fmt.Fprintf(&b, "%s (%s, %s)", name, c.Driver, mode)
+		if c.Description != "" {
+			b.WriteString(": " + c.Description)
+		}
+		b.WriteString("\n")
+	}
+	return textResult(b.String()), nil
+}
+
+func handleSchema(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	args := getArgs(request)
+	name, _ := args["connection"].(string)
+	c, db, err := openConnection(name)
+	if err != nil {
+		return errResult(fmt.Sprintf("error: %v", err)), nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+
+	schema, err := currentSchema(ctx, db, c.Driver)
+	if err != nil {
+		return errResult(fmt.Sprintf("error: %s: %v", name, err)), nil
+	}
+	tables, err := listTables(ctx, db, c.Driver)
+	if err != nil {
+		return errResult(fmt.Sprintf("error: %s: %v", name, err)), nil
+	}
+	displayName := func(t table) string {
+		if t.Schema != "" && t.Schema != schema {
+			return t.Schema + "." + t.Name
+		}
+		return t.Name
+	}
+
+	want, _ := args["table"].(string)
+	if want == "" {
+		cols, err := listColumns(ctx, db, c.Driver, nil)
+		if err != nil {
+			return errResult(fmt.Sprintf("error: %s: %v", name, err)), nil
+		}
+		byTable := make(map[table][]string)
+		for _, col := range cols {
+			key := table{Schema: col.Table.Schema, Name: col.Table.Name}
+			byTable[key] = append(byTable[key], col.String())
+		}
+		var b strings.Builder
+		fmt.Fprintf(&b, "Connection: %s (%s)\nTables: %d\n", name, c.Driver, len(tables))
+		for _, t := range tables {
+			fmt.Fprintf(&b, "\n%s (%s)\n", displayName(t), t.Kind)
+			for _, col := range byTable[table{Schema: t.Schema, Name: t.Name}] {
+				b.WriteString("  " + col + "\n")
+			}
+		}
+		return textResult(limitOutput(b.String())), nil
+	}
+
+	var found *table
+	for i, t := range tables {
+		if want == displayName(t) || want == t.Schema+"."+t.Name {
+			found = &tables[i]
+			break
+		}
+	}
+	if found == nil {
+		return errResult(fmt.Sprintf("error: %s: no table or view %q", name, want)), nil
+	}
+	cols, err := listColumns(ctx, db, c.Driver, found)
+	if err != nil {
+		return errResult(fmt.Sprintf("error: %s: %v", name, err)), nil
+	}
+	indexes, err := listIndexes(ctx, db, c.Driver, *found)
+	if err != nil {
+		return errResult(fmt.Sprintf("error: %s: %v", name, err)), nil
+	}
+	fks, err := listForeignKeys(ctx, db, c.Driver, *found)
+	if err != nil {
+		return errResult(fmt.Sprintf("error: %s: %v", name, err)), nil
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "Table: %s (%s)\n\nColumns:\n", displayName(*found), found.Kind)
+	for _, col := range cols {
+		b.WriteString("  " + col.String() + "\n")
+	}
+	for _, section := range []struct {
+		title string
+		lines []string
+	}{{"Indexes", indexes}, {"Foreign keys", fks}} {
+		if len(section.lines) > 0 {
+			b.WriteString("\n" + section.title + ":\n")
+			for _, line := range section.lines {
+				b.WriteString("  " + line + "\n")
+			}
+		}
+	}
+	return textResult(b.String()), nil
+}
+
+func handleQuery(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	args := getArgs(request)
+	name, _ := args["connection"].(string)
+	query, _ := args["sql"].(string)
+	maxRows := defaultMaxRows
+	if v, ok := args["max_rows"].(float64); ok && v > 0 {
+		maxRows = min(int(v), maxMaxRows)
+	}
+	timeout := defaultTimeout
+	if v, ok := args["timeout_seconds"].(float64); ok && v > 0 {
+		timeout = min(time.Duration(v)*time.Second, maxTimeout)
+	}
+
+	c, db, err := openConnection(name)
+	if err != nil {
+		return errResult(fmt.Sprintf("error: %v", err)), nil
+	}
+	stmt, err := singleStatement(query, c.Driver)
+	if err != nil {
+		return errResult(fmt.Sprintf("error: %v", err)), nil
+	}
+	if !c.Writable && !isRead(stmt) {
+		return errResult(fmt.Sprintf("error: connection %s is read-only; %s statements are not allowed", name, keywordOrUnknown(stmt))), nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	text, err := runQuery(ctx, c, db, stmt, params(args["params"]), maxRows)
+	if err != nil {
+		if errors.Is(err, context.DeadlineExceeded) {
+			return errResult(fmt.Sprintf("error: %s: timed out after %s", name, timeout)), nil
+		}
+		return errResult(fmt.Sprintf("error: %s: %v", name, err)), nil
+	}
+	return textResult(text), nil
+}
+
+func handleExplain(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	args := getArgs(request)
+	name, _ := args["connection"].(string)
+	query, _ := args["sql"].(string)
+	analyze, _ := args["analyze"].(bool)
+
+	c, db, err := openConnection(name)
+	if err != nil {
+		return errResult(fmt.Sprintf("error: %v", err)), nil
+	}
+	stmt, err := singleStatement(query, c.Driver)
+	if err != nil {
+		return errResult(fmt.Sprintf("error: %v", err)), nil
+	}
+	if analyze && !isRead(stmt) {
+		return errResult("error: analyze runs the statement, so it only works with read statements"), nil
+	}
+
+	var prefix string
+	switch {
+	case c.Driver == "sqlite":
+		if analyze {
+			return errResult("error: SQLite has no EXPLAIN ANALYZE; use analyze=false"), nil
+		}
+		prefix = "EXPLAIN QUERY PLAN "
+	case analyze && c.Driver == "postgres":
+		prefix = "EXPLAIN (ANALYZE, BUFFERS) "
+	case analyze:
+		prefix = "EXPLAIN ANALYZE "
+	default:
+		prefix = "EXPLAIN "
+	}
+
+	// Without analyze nothing runs, so even writes are explained read-only.
+	read := c
+	read.Writable = false
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+	rows, err := queryRows(ctx, read, db, prefix+stmt, params(args["params"]))
+	if err != nil {
+		return errResult(fmt.Sprintf("error: %s: %v", name, err)), nil
+	}
+	defer rows.close()
+
+	cols, records, _, err := rows.read(maxMaxRows)
+	if err != nil {
+		return errResult(fmt.Sprintf("error: %s: %v", name, err)), nil
+	}
+	switch {
+	case c.Driver == "sqlite":
+		// Rows are id, parent, notused, detail; indent by nesting.
+		depth := map[string]int{"0": -1}
+		var b strings.Builder
+		for _, r := range records {
+			d := depth[r[1]] + 1
+			depth[r[0]] = d
+			b.WriteString(strings.Repeat("  ", d) + r[3] + "\n")
+		}
+		return textResult(b.String()), nil
+	case len(cols) == 1:
+		// Postgres, and MySQL with ANALYZE, return the plan as lines of text.
+		var b strings.Builder
+		for _, r := range records {
+			b.WriteString(r[0] + "\n")
+		}
+		return textResult(limitOutput(b.String())), nil
+	default:
+		return textResult(markdownTable(cols, records)), nil
+	}
+}
+
+// singleStatement returns the one statement in query.
+func singleStatement(query, driver string) (string, error) {
+	stmts := splitStatements(query, driver)
+	switch len(stmts) {
+	case 0:
+		return "", fmt.Errorf("'sql' is required")
+	case 1:
+		return stmts[0], nil
+	default:
+		return "", fmt.Errorf("run one statement per call (got %d)", len(stmts))
+	}
+}
+
+func keywordOrUnknown(stmt string) string {
+	if k := keyword(stmt); k != "" {
+		return k
+	}
+	return "unrecognized"
+}
+
+// params converts the params argument to driver values. JSON numbers
+// arrive as floats; whole ones are passed as integers so they compare and
+// store as integers.
+func params(v any) []any {
+	items, _ := v.([]any)
+	for i, item := range items {
+		if f, ok := item.(float64); ok && f == math.Trunc(f) && math.Abs(f) < 1<<53 {
+			items[i] = int64(f)
+		}
+	}
+	return items
+}
+
+// runQuery runs stmt in a transaction: read-only unless the connection is
+// writable, and committed only for a write on a writable connection.
+func runQuery(ctx context.Context, c connection, db *sql.DB, stmt string, args []any, maxRows int) (string, error) {
+	if !returnsRows(stmt) {
+		tx, err := db.BeginTx(ctx, nil)
+		if err != nil {
+			return "", err
+		}
+		defer tx.Rollback()
+		result, err := tx.ExecContext(ctx, stmt, args...)
+		if err != nil {
+			return "", err
+		}
+		if err := tx.Commit(); err != nil {
+			return "", err
+		}
+		if n, err := result.RowsAffected(); err == nil {
+			return fmt.Sprintf("OK: %d rows affected", n), nil
+		}
+		return "OK", nil
+	}
+
+	rows, err := queryRows(ctx, c, db, stmt, args)
+	if err != nil {
+		return "", err
+	}
+	defer rows.close()
+	cols, records, more, err := rows.read(maxRows)
+	if err != nil {
+		return "", err
+	}
+	if c.Writable && !isRead(stmt) {
+		// A write with RETURNING.
+		if err := rows.commit(); err != nil {
+			return "", err
+		}
+	}
+
+	text := markdownTable(cols, records)
+	switch {
+	case more:
+		text += fmt.Sprintf("\n(showing the first %d rows; add a LIMIT or raise max_rows to see more)\n", len(records))
+	case len(records) == 1:
+		text += "\n(1 row)\n"
+	default:
+		text += fmt.Sprintf("\n(%d rows)\n", len(records))
+	}
+	return limitOutput(text), nil
+}
+
+// rowSet is a result set and the transaction it was read in.
+type rowSet struct {
+	tx   *sql.Tx
+	stmt *sql.Stmt
+	rows *sql.Rows
+}
+
+// queryRows runs stmt in a transaction that is read-only unless c is
+// writable. Postgres statements are prepared first, because lib/pq runs a
+// query without parameters as a simple query, which may hold several
+// statements, and a COMMIT among them would end the read-only transaction.
+// The MySQL connection is opened without multi-statement support, and
+// SQLite only runs the first statement.
+func queryRows(ctx context.Context, c connection, db *sql.DB, stmt string, args []any) (*rowSet, error) {
+	tx, err := db.BeginTx(ctx, &sql.TxOptions{ReadOnly: !c.Writable})
+	if err != nil {
+		return nil, err
+	}
+	rs := &rowSet{tx: tx}
+	if c.Driver == "postgres" {
+		if rs.stmt, err = tx.PrepareContext(ctx, stmt); err == nil {
+			rs.rows, err = rs.stmt.QueryContext(ctx, args...)
+		}
+	} else {
+		rs.rows, err = tx.QueryContext(ctx, stmt, args...)
+	}
+	if err != nil {
+		rs.close()
+		return nil, err
+	}
+	return rs, nil
+}
+
+// read returns the column names and up to max rows, formatted as text, and
+// whether there were more.
+func (rs *rowSet) read(max int) ([]string, [][]string, bool, error) {
+	cols, err := rs.rows.Columns()
+	if err != nil {
+		return nil, nil, false, err
+	}
+	var records [][]string
+	values := make([]any, len(cols))
+	ptrs := make([]any, len(cols))
+	for i := range values {
+		ptrs[i] = &values[i]
+	}
+	for rs.rows.Next() {
+		if len(records) == max {
+			return cols, records, true, nil
+		}
+		if err := rs.rows.Scan(ptrs...); err != nil {
+			return nil, nil, false, err
+		}
+		record := make([]string, len(cols))
+		for i, v := range values {
+			record[i] = formatValue(v)
+		}
+		records = append(records, record)
+	}
+	return cols, records, false, rs.rows.Err()
+}
+
+func (rs *rowSet) commit() error {
+	if rs.rows != nil {
+		rs.rows.Close()
+	}
+	return rs.tx.Commit()
+}
+
+func (rs *rowSet) close() {
+	if rs.rows != nil {
+		rs.rows.Close()
+	}
+	if rs.stmt != nil {
+		rs.stmt.Close()
+	}
+	rs.tx.Rollback()
+}
+
+func formatValue(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return "NULL"
+	case []byte:
+		if !utf8.Valid(v) {
+			return fmt.Sprintf("<%d bytes>", len(v))
+		}
+		return string(v)
+	case time.Time:
+		return v.Format(time.RFC3339Nano)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// markdownTable renders a result set, escaping the characters that would
+// break the table and shortening long values.
+func markdownTable(cols []string, records [][]string) string {
+	cell := func(s string) string {
+		s = strings.NewReplacer("|", `\|`, "\r\n", `\n`, "\n", `\n`).Replace(s)
+		if utf8.RuneCountInString(s) > maxCellLen {
+			s = string([]rune(s)[:maxCellLen]) + "…"
+		}
+		return s
+	}
+	var b strings.Builder
+	row := func(values []string) {
+		b.WriteString("|")
+		for _, v := range values {
+			b.WriteString(" " + cell(v) + " |")
+		}
+		b.WriteString("\n")
+	}
+	row(cols)
+	b.WriteString("|" + strings.Repeat(" --- |", len(cols)) + "\n")
+	for _, r := range records {
+		row(r)
+	}
+	return b.String()
+}
+
+// limitOutput cuts text that runs past maxOutputLen at a line boundary.
+func limitOutput(text string) string {
+	if len(text) <= maxOutputLen {
+		return text
+	}
+	cut := strings.LastIndexByte(text[:maxOutputLen], '\n') + 1
+	return text[:cut] + fmt.Sprintf("... (output truncated at %d of %d bytes; ask for fewer rows or columns)\n", cut, len(text))
+}
@@ -0,0 +1,279 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// Schema introspection uses each database's own catalog: sqlite_master and
+// the table-valued pragmas for SQLite, information_schema and pg_catalog
+// for Postgres, and information_schema for MySQL. Postgres tables outside
+// the current schema are named schema.table.
+
+// table is a table or view. Schema is only set for Postgres.
+type table struct {
+	Schema, Name, Kind string
+}
+
+// column is one column of a table.
+type column struct {
+	Table      table
+	Name, Type string
+	NotNull    bool
+	Default    sql.NullString
+	PrimaryKey bool
+}
+
+func (c column) String() string {
+	s := c.Name + " " + c.Type
+	if c.PrimaryKey {
+		s += " PRIMARY KEY"
+	}
+	if c.NotNull && !c.PrimaryKey {
+		s += " NOT NULL"
+	}
+	if c.Default.Valid {
+		s += " DEFAULT " + c.Default.String
+	}
+	return s
+}
+
+// currentSchema returns the schema unqualified Postgres names resolve to.
+func currentSchema(ctx context.Context, db *sql.DB, driver string) (string, error) {
+	if driver != "postgres" {
+		return "", nil
+	}
+	var schema string
+	err := db.QueryRowContext(ctx, "SELECT current_schema()").Scan(&schema)
+	return schema, err
+}
+
+func listTables(ctx context.Context, db *sql.DB, driver string) ([]table, error) {
+	var query string
+	switch driver {
+	case "sqlite":
+		query = `SELECT '', name, type FROM sqlite_master WHERE type IN ('table', 'view') AND name NOT LIKE 'sqlite_%' ORDER BY name`
+	case "postgres":
+		query = `SELECT table_schema, table_name, CASE table_type WHEN 'VIEW' THEN 'view' ELSE 'table' END
+			FROM information_schema.tables
+			WHERE table_schema NOT IN ('pg_catalog', 'information_schema')
+			ORDER BY table_schema, table_name`
+	case "mysql":
+		query = `SELECT '', table_name, CASE table_type WHEN 'VIEW' THEN 'view' ELSE 'table' END
+			FROM information_schema.tables
+			WHERE table_schema = DATABASE()
+			ORDER BY table_name`
+	}
+	rows, err := db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var tables []table
+	for rows.Next() {
+		var t table
+		if err := rows.Scan(&t.Schema, &t.Name, &t.Kind); err != nil {
+			return nil, err
+		}
+		tables = append(tables, t)
+	}
+	return tables, rows.Err()
+}
+
+// listColumns returns the columns of t, or of every table when t is nil.
+func listColumns(ctx context.Context, db *sql.DB, driver string, t *table) ([]column, error) {
+	if driver == "sqlite" {
+		tables := []table{}
+		if t != nil {
+			tables = append(tables, *t)
+		} else {
+			var err error
+			if tables, err = listTables(ctx, db, driver); err != nil {
+				return nil, err
+			}
+		}
+		var cols []column
+		for _, t := range tables {
+			c, err := queryColumns(ctx, db, t, `SELECT '', ?, name, type, "notnull", dflt_value, pk > 0 FROM pragma_table_info(?) ORDER BY cid`, t.Name, t.Name)
+			if err != nil {
+				return nil, err
+			}
+			cols = append(cols, c...)
+		}
+		return cols, nil
+	}
+
+	var query string
+	var args []any
+	switch driver {
+	case "postgres":
+		query = `SELECT c.table_schema, c.table_name, c.column_name,
+				CASE WHEN c.data_type IN ('USER-DEFINED', 'ARRAY') THEN c.udt_name ELSE c.data_type END,
+				c.is_nullable = 'NO', c.column_default,
+				EXISTS (
+					SELECT 1 FROM information_schema.table_constraints tc
+					JOIN information_schema.key_column_usage k
+						ON k.constraint_name = tc.constraint_name AND k.table_schema = tc.table_schema AND k.table_name = tc.table_name
+					WHERE tc.constraint_type = 'PRIMARY KEY' AND tc.table_schema = c.table_schema
+						AND tc.table_name = c.table_name AND k.column_name = c.column_name)
+			FROM information_schema.columns c
+			WHERE c.table_schema NOT IN ('pg_catalog', 'information_schema')`
+		if t != nil {
+			query += ` AND c.table_schema = $1 AND c.table_name = $2`
+			args = []any{t.Schema, t.Name}
+		}
+		query += ` ORDER BY c.table_schema, c.table_name, c.ordinal_position`
+	case "mysql":
+		query = `SELECT '', table_name, column_name, column_type, is_nullable = 'NO', column_default, column_key = 'PRI'
+			FROM information_schema.columns
+			WHERE table_schema = DATABASE()`
+		if t != nil {
+			query += ` AND table_name = ?`
+			args = []any{t.Name}
+		}
+		query += ` ORDER BY table_name, ordinal_position`
+	}
+	var zero table
+	if t != nil {
+		zero = *t
+	}
+	return queryColumns(ctx, db, zero, query, args...)
+}
+
+func queryColumns(ctx context.Context, db *sql.DB, t table, query string, args ...any) ([]column, error) {
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var cols []column
+	for rows.Next() {
+		c := column{Table: t}
+		if err := rows.Scan(&c.Table.Schema, &c.Table.Name, &c.Name, &c.Type, &c.NotNull, &c.Default, &c.PrimaryKey); err != nil {
+			return nil, err
+		}
+		cols = append(cols, c)
+	}
+	return cols, rows.Err()
+}
+
+// listIndexes describes t's indexes, one per line.
+func listIndexes(ctx context.Context, db *sql.DB, driver string, t table) ([]string, error) {
+	var query string
+	var args []any
+	switch driver {
+	case "sqlite":
+		query = `SELECT il.name, il."unique", group_concat(ii.name, ', ')
+			FROM pragma_index_list(?) il, pragma_index_info(il.name) ii
+			GROUP BY il.name ORDER BY il.name`
+		args = []any{t.Name}
+	case "postgres":
+		// indexdef already says everything; the other columns keep the
+		// scan below uniform.
+		query = `SELECT indexdef, false, '' FROM pg_indexes WHERE schemaname = $1 AND tablename = $2 ORDER BY indexname`
+		args = []any{t.Schema, t.Name}
+	case "mysql":
+		query = `SELECT index_name, non_unique = 0, GROUP_CONCAT(column_name ORDER BY seq_in_index SEPARATOR ', ')
+			FROM information_schema.statistics
+			WHERE table_schema = DATABASE() AND table_name = ?
+			GROUP BY index_name, non_unique ORDER BY index_name`
+		args = []any{t.Name}
+	}
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var indexes []string
+	for rows.Next() {
+		var name, cols string
+		var unique bool
+		if err := rows.Scan(&name, &unique, &cols); err != nil {
+			return nil, err
+		}
+		line := name
+		if unique {
+			line += " UNIQUE"
+		}
+		if cols != "" {
+			line += " (" + cols + ")"
+		}
+		indexes = append(indexes, line)
+	}
+	return indexes, rows.Err()
+}
+
+// listForeignKeys describes t's foreign keys, one per line.
+func listForeignKeys(ctx context.Context, db *sql.DB, driver string, t table) ([]string, error) {
+	var query string
+	var args []any
+	switch driver {
+	case "sqlite":
+		query = `SELECT id, "from", "table", coalesce("to", '') FROM pragma_foreign_key_list(?) ORDER BY id, seq`
+		args = []any{t.Name}
+	case "postgres":
+		query = `SELECT c.conname, pg_get_constraintdef(c.oid), '', ''
+			FROM pg_constraint c
+			JOIN pg_class r ON r.oid = c.conrelid
+			JOIN pg_namespace n ON n.oid = r.relnamespace
+			WHERE c.contype = 'f' AND n.nspname = $1 AND r.relname = $2
+			ORDER BY c.conname`
+		args = []any{t.Schema, t.Name}
+	case "mysql":
+		query = `SELECT constraint_name, column_name, referenced_table_name, referenced_column_name
+			FROM information_schema.key_column_usage
+			WHERE table_schema = DATABASE() AND table_name = ? AND referenced_table_name IS NOT NULL
+			ORDER BY constraint_name, ordinal_position`
+		args = []any{t.Name}
+	}
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	// Multi-column keys come back a row per column.
+	type key struct {
+		from, to []string
+		table    string
+	}
+	var ids []string
+	keys := make(map[string]*key)
+	for rows.Next() {
+		var id, from, refTable, to string
+		if err := rows.Scan(&id, &from, &refTable, &to); err != nil {
+			return nil, err
+		}
+		if driver == "postgres" {
+			ids = append(ids, from)
+			continue
+		}
+		k := keys[id]
+		if k == nil {
+			k = &key{table: refTable}
+			keys[id] = k
+			ids = append(ids, id)
+		}
+		k.from = append(k.from, from)
+		k.to = append(k.to, to)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if driver == "postgres" {
+		return ids, nil
+	}
+	fks := make([]string, len(ids))
+	for i, id := range ids {
+		k := keys[id]
+		fks[i] = fmt.Sprintf("FOREIGN KEY (%s) REFERENCES %s", strings.Join(k.from, ", "), k.table)
+		// SQLite leaves the referenced columns out when they are the
+		// primary key.
+		if to := strings.Join(k.to, ", "); strings.Trim(to, ", ") != "" {
+			fks[i] += "(" + to + ")"
+		}
+	}
+	return fks, nil
+}
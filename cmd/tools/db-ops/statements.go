@@ -0,0 +1,113 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+)
+
+// readKeywords are the statements allowed on read-only connections. They
+// still run in a read-only transaction (or, for SQLite, on a query_only
+// connection), which catches writes hidden inside them, such as a
+// data-modifying CTE in a WITH.
+var readKeywords = map[string]bool{
+	"SELECT":   true,
+	"WITH":     true,
+	"VALUES":   true,
+	"TABLE":    true,
+	"SHOW":     true,
+	"DESCRIBE": true,
+	"DESC":     true,
+	"EXPLAIN":  true,
+	"PRAGMA":   true,
+}
+
+// splitStatements splits sql at semicolons that aren't inside quotes or
+// comments, dropping empty ones. It follows driver's dialect: MySQL's
+// backticks, # comments, and backslash escapes, and Postgres's
+// dollar-quoted strings. This only catches mistakes; running one statement
+// at a time is enforced by the drivers (see runQuery).
+func splitStatements(sql, driver string) []string {
+	mysql := driver == "mysql"
+	var stmts []string
+	start := 0
+	for i := 0; i < len(sql); i++ {
+		switch c := sql[i]; {
+		case c == '\'' || c == '"' || c == '`' && mysql:
+			// A doubled quote is an escaped quote and just reopens the string.
+			for i++; i < len(sql) && sql[i] != c; i++ {
+				if sql[i] == '\\' && mysql {
+					i++
+				}
+			}
+		case c == '-' && strings.HasPrefix(sql[i:], "--"), c == '#' && mysql:
+			end := strings.IndexByte(sql[i:], '\n')
+			if end < 0 {
+				i = len(sql)
+			} else {
+				i += end
+			}
+		case c == '/' && strings.HasPrefix(sql[i:], "/*"):
+			end := strings.Index(sql[i+2:], "*/")
+			if end < 0 {
+				i = len(sql)
+			} else {
+				i += end + 3
+			}
+		case c == '$' && driver == "postgres":
+			if tag := dollarTag.FindString(sql[i:]); tag != "" {
+				end := strings.Index(sql[i+len(tag):], tag)
+				if end < 0 {
+					i = len(sql)
+				} else {
+					i += len(tag) + end + len(tag) - 1
+				}
+			}
+		case c == ';':
+			stmts = appendStatement(stmts, sql[start:i])
+			start = i + 1
+		}
+	}
+	if start < len(sql) {
+		stmts = appendStatement(stmts, sql[start:])
+	}
+	return stmts
+}
+
+// appendStatement adds stmt unless it is only whitespace and comments.
+func appendStatement(stmts []string, stmt string) []string {
+	stmt = strings.TrimSpace(stmt)
+	if len(leadingComments.FindString(stmt)) == len(stmt) {
+		return stmts
+	}
+	return append(stmts, stmt)
+}
+
+var dollarTag = regexp.MustCompile(`^\$[A-Za-z_]*\$`)
+
+// leadingComments matches whitespace, comments, and opening parentheses
+// before a statement's first keyword. A # comment can only hide the keyword
+// from this check, which then fails safe.
+var leadingComments = regexp.MustCompile(`^(?:\s+|--[^\n]*|/\*(?s:.*?)\*/|\()*`)
+
+// keyword returns the first keyword of stmt, upper-cased.
+func keyword(stmt string) string {
+	stmt = stmt[len(leadingComments.FindString(stmt)):]
+	end := strings.IndexFunc(stmt, func(r rune) bool {
+		return !(r >= 'A' && r <= 'Z' || r >= 'a' && r <= 'z' || r == '_')
+	})
+	if end >= 0 {
+		stmt = stmt[:end]
+	}
+	return strings.ToUpper(stmt)
+}
+
+func isRead(stmt string) bool {
+	return readKeywords[keyword(stmt)]
+}
+
+var returning = regexp.MustCompile(`(?i)\bRETURNING\b`)
+
+// returnsRows reports whether a statement produces a result set.
+func returnsRows(stmt string) bool {
+	return isRead(stmt) || returning.MatchString(stmt)
+}
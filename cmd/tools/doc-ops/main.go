@@ -0,0 +1,245 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+
+	"github.com/michaelbrown/forge/internal/document"
+)
+
+const (
+	defaultMaxLength = 20_000
+	maxMaxLength     = 200_000
+
+	// maxDocBytes caps the size of a document, downloaded or local.
+	maxDocBytes = 50 << 20
+)
+
+var httpClient = &http.Client{Timeout: 60 * time.Second}
+
+// workspaceRoot, when set from FORGE_WORKSPACE_ROOT, is where relative
+// paths are taken from and the boundary local files must stay in.
+var workspaceRoot string
+
+func main() {
+	if root := os.Getenv("FORGE_WORKSPACE_ROOT"); root != "" {
+		real, err := filepath.EvalSymlinks(root)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "forge-doc-ops: workspace root %s: %v\n", root, err)
+			os.Exit(1)
+		}
+		workspaceRoot, _ = filepath.Abs(real)
+	}
+
+	s := server.NewMCPServer("forge-doc-ops", "0.1.0")
+
+	s.AddTool(mcp.Tool{
+		Name:        "doc_extract",
+		Description: "Extract the text of a PDF, DOCX, or HTML document from a URL or local file. Pages are marked with '--- Page N ---' lines; use 'pages' to read part of a long document. HTML is reduced to its main content as Markdown.",
+		InputSchema: mcp.ToolInputSchema{
+			Type: "object",
+			Properties: map[string]any{
+				"source": map[string]any{
+					"type":        "string",
+					"description": "http(s) URL or file path of the document",
+				},
+				"pages": map[string]any{
+					"type":        "string",
+					"description": "Pages to extract, e.g. '3', '2-5', '10-', or '1,4-6' (default: all)",
+				},
+				"max_length": map[string]any{
+					"type":        "integer",
+					"description": fmt.Sprintf("Maximum characters to return (default %d, max %d)", defaultMaxLength, maxMaxLength),
+				},
+			},
+			Required: []string{"source"},
+		},
+	}, handleDocExtract)
+
+	if err := server.ServeStdio(s); err != nil {
+		fmt.Printf("server error: %v\n", err)
+	}
+}
+
+func getArgs(request mcp.CallToolRequest) map[string]any {
+	args, _ := request.Params.Arguments.(map[string]any)
+	return args
+}
+
+func textResult(text string) *mcp.CallToolResult {
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{mcp.TextContent{Type: "text", Text: text}},
+	}
+}
+
+func errResult(text string) *mcp.CallToolResult {
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{mcp.TextContent{Type: "text", Text: text}},
+		IsError: true,
+	}
+}
+
+func handleDocExtract(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	args := getArgs(request)
+	source, _ := args["source"].(string)
+	if source == "" {
+		return errResult("error: 'source' is required"), nil
+	}
+	maxLength := defaultMaxLength
+	if v, ok := args["max_length"].(float64); ok && v > 0 {
+		maxLength = min(int(v), maxMaxLength)
+	}
+
+	var (
+		data        []byte
+		contentType string
+		err         error
+	)
+	if strings.HasPrefix(source, "http://") || strings.HasPrefix(source, "https://") {
+		data, contentType, err = download(ctx, source)
+	} else {
+		data, err = readLocal(source)
+	}
+	if err != nil {
+		return errResult(fmt.Sprintf("error: %v", err)), nil
+	}
+
+	doc, err := document.Extract(data, source, contentType)
+	if err != nil {
+		return errResult(fmt.Sprintf("error: %s: %v", source, err)), nil
+	}
+
+	total := len(doc.Pages)
+	pages := make([]int, total)
+	for i := range pages {
+		pages[i] = i + 1
+	}
+	if spec, _ := args["pages"].(string); spec != "" {
+		if pages, err = document.ParsePages(spec, total); err != nil {
+			return errResult(fmt.Sprintf("error: %v", err)), nil
+		}
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "Document: %s\nFormat: %s\n", source, doc.Format)
+	if doc.Title != "" {
+		fmt.Fprintf(&b, "Title: %s\n", doc.Title)
+	}
+	paged := doc.Format == document.PDF || total > 1
+	if paged {
+		fmt.Fprintf(&b, "Pages: %s of %d\n", describePages(pages), total)
+	}
+	header := b.String()
+
+	b.Reset()
+	for _, p := range pages {
+		if paged {
+			fmt.Fprintf(&b, "\n--- Page %d ---\n", p)
+		} else {
+			b.WriteString("\n")
+		}
+		if text := strings.TrimRight(doc.Pages[p-1], "\n"); text != "" {
+			b.WriteString(text + "\n")
+		} else if paged {
+			b.WriteString("(no text on this page)\n")
+		}
+	}
+	text := b.String()
+	if n := utf8.RuneCountInString(text); n > maxLength {
+		text = truncate(text, maxLength) + fmt.Sprintf("\n... (truncated at %d of %d characters", maxLength, n)
+		if paged {
+			text += "; request fewer pages to see the rest"
+		}
+		text += ")\n"
+	}
+	return textResult(header + text), nil
+}
+
+func download(ctx context.Context, url string) ([]byte, string, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	req.Header.Set("User-Agent", "Forge/0.1")
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 400 {
+		return nil, "", fmt.Errorf("GET %s returned %s", url, resp.Status)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxDocBytes+1))
+	if err != nil {
+		return nil, "", fmt.Errorf("reading %s: %w", url, err)
+	}
+	if len(data) > maxDocBytes {
+		return nil, "", fmt.Errorf("%s is larger than %d MB", url, maxDocBytes>>20)
+	}
+	return data, resp.Header.Get("Content-Type"), nil
+}
+
+// readLocal reads a file, keeping it inside the workspace root.
+func readLocal(path string) ([]byte, error) {
+	if workspaceRoot != "" {
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(workspaceRoot, path)
+		}
+		real, err := filepath.EvalSymlinks(path)
+		if err != nil {
+			return nil, err
+		}
+		rel, err := filepath.Rel(workspaceRoot, real)
+		if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return nil, fmt.Errorf("%s is outside the workspace root %s", path, workspaceRoot)
+		}
+		path = real
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if info.Size() > maxDocBytes {
+		return nil, fmt.Errorf("%s is larger than %d MB", path, maxDocBytes>>20)
+	}
+	return os.ReadFile(path)
+}
+
+// describePages summarizes page numbers as ranges, e.g. "1-3, 7".
+func describePages(pages []int) string {
+	var parts []string
+	for i := 0; i < len(pages); {
+		j := i
+		for j+1 < len(pages) && pages[j+1] == pages[j]+1 {
+			j++
+		}
+		if j == i {
+			parts = append(parts, fmt.Sprint(pages[i]))
+		} else {
+			parts = append(parts, fmt.Sprintf("%d-%d", pages[i], pages[j]))
+		}
+		i = j + 1
+	}
+	return strings.Join(parts, ", ")
+}
+
+// truncate returns the first n characters of s.
+func truncate(s string, n int) string {
+	for i := range s {
+		if n == 0 {
+			return s[:i]
+		}
+		n--
+	}
+	return s
+}
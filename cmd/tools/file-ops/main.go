@@ -1,23 +1,47 @@
 package main
 
 import (
+	"bufio"
+	"bytes"
 	"context"
+	"crypto/sha256"
+	"errors"
 	"fmt"
+	"io"
 	"os"
+	"path"
 	"path/filepath"
+	"regexp"
+	"sort"
 	"strconv"
 	"strings"
+	"time"
+	"unicode/utf8"
 
 	"github.com/mark3labs/mcp-go/mcp"
 	"github.com/mark3labs/mcp-go/server"
+
+	"github.com/michaelbrown/forge/internal/backup"
 )
 
 func main() {
+	if err := setWorkspaceRoot(os.Getenv("FORGE_WORKSPACE_ROOT")); err != nil {
+		// Refuse to start unconfined rather than silently ignoring the jail.
+		fmt.Fprintf(os.Stderr, "forge-file-ops: %v\n", err)
+		os.Exit(1)
+	}
+
+	backupDir := os.Getenv("FORGE_BACKUP_DIR")
+	if backupDir == "" {
+		backupDir = backup.DefaultDir()
+	}
+	backups = backup.New(backupDir)
+
 	s := server.NewMCPServer("forge-file-ops", "0.1.0")
 
 	s.AddTool(mcp.Tool{
 		Name:        "file_read",
-		Description: "Read the contents of a file. Optionally specify a line range.",
+		Description: "Read a file. The result starts with a header giving total_lines and the lines shown. Large files are returned a page at a time (default 2000 lines or 64 KiB); the header gives the offset to pass for the next page.",
 		InputSchema: mcp.ToolInputSchema{
 			Type: "object",
 			Properties: map[string]any{
@@ -25,9 +49,21 @@ func main() {
 					"type":        "string",
 					"description": "Path to the file to read",
 				},
+				"offset": map[string]any{
+					"type":        "integer",
+					"description": "First line to read (1-based, default 1)",
+				},
+				"limit": map[string]any{
+					"type":        "integer",
+					"description": "Maximum number of lines to return (default 2000)",
+				},
+				"max_bytes": map[string]any{
+					"type":        "integer",
+					"description": "Maximum bytes of content to return (default 65536)",
+				},
 				"start_line": map[string]any{
 					"type":        "integer",
-					"description": "First line to read (1-based, optional)",
+					"description": "Alias for offset",
 				},
 				"end_line": map[string]any{
 					"type":        "integer",
@@ -59,7 +95,7 @@ func main() {
 
 	s.AddTool(mcp.Tool{
 		Name:        "file_patch",
-		Description: "Replace the first occurrence of a search string with a replacement string in a file.",
+		Description: "Replace text in a file. By default replaces the first literal occurrence of search. Set replace_all to replace every occurrence, regex to treat search as a regular expression ($1 expands groups in replace), or pass edits to apply several search/replace pairs in one call. Edits are atomic: if any search is not found, the file is left unchanged.",
 		InputSchema: mcp.ToolInputSchema{
 			Type: "object",
 			Properties: map[string]any{
@@ -69,14 +105,36 @@ func main() {
 				},
 				"search": map[string]any{
 					"type":        "string",
-					"description": "The exact text to search for",
+					"description": "The text (or regex) to search for",
 				},
 				"replace": map[string]any{
 					"type":        "string",
 					"description": "The text to replace it with",
 				},
+				"replace_all": map[string]any{
+					"type":        "boolean",
+					"description": "Replace every occurrence instead of only the first",
+				},
+				"regex": map[string]any{
+					"type":        "boolean",
+					"description": "Treat search as a regular expression (Go RE2 syntax)",
+				},
+				"edits": map[string]any{
+					"type":        "array",
+					"description": "Multiple edits applied in order, instead of search/replace",
+					"items": map[string]any{
+						"type": "object",
+						"properties": map[string]any{
+							"search":      map[string]any{"type": "string"},
+							"replace":     map[string]any{"type": "string"},
+							"replace_all": map[string]any{"type": "boolean"},
+							"regex":       map[string]any{"type": "boolean"},
+						},
+						"required": []string{"search", "replace"},
+					},
+				},
 			},
-			Required: []string{"path", "search", "replace"},
+			Required: []string{"path"},
 		},
 	}, handleFilePatch)
 
@@ -99,6 +157,184 @@ func main() {
 		},
 	}, handleFileList)
 
+	s.AddTool(mcp.Tool{
+		Name:        "file_grep",
+		Description: "Search file contents recursively with a regular expression. Returns matching lines as path:line: text. Skips hidden directories, node_modules, and binary files.",
+		InputSchema: mcp.ToolInputSchema{
+			Type: "object",
+			Properties: map[string]any{
+				"pattern": map[string]any{
+					"type":        "string",
+					"description": "Regular expression to search for (Go RE2 syntax)",
+				},
+				"path": map[string]any{
+					"type":        "string",
+					"description": "File or directory to search (default: current directory)",
+				},
+				"include": map[string]any{
+					"type":        "string",
+					"description": "Comma-separated globs; only matching files are searched (e.g. '*.go,*.md')",
+				},
+				"exclude": map[string]any{
+					"type":        "string",
+					"description": "Comma-separated globs for files or directories to skip (e.g. '*_test.go,vendor')",
+				},
+				"context_lines": map[string]any{
+					"type":        "integer",
+					"description": "Lines of context to show before and after each match (default 0)",
+				},
+				"ignore_case": map[string]any{
+					"type":        "boolean",
+					"description": "Match case-insensitively",
+				},
+				"max_results": map[string]any{
+					"type":        "integer",
+					"description": "Stop after this many matching lines (default 100)",
+				},
+			},
+			Required: []string{"pattern"},
+		},
+	}, handleFileGrep)
+
+	s.AddTool(mcp.Tool{
+		Name:        "dir_tree",
+		Description: "Show an indented tree of a directory. Honors .gitignore files and skips .git. Use this to get oriented in a project instead of many file_list calls.",
+		InputSchema: mcp.ToolInputSchema{
+			Type: "object",
+			Properties: map[string]any{
+				"path": map[string]any{
+					"type":        "string",
+					"description": "Directory to show (default: current directory)",
+				},
+				"max_depth": map[string]any{
+					"type":        "integer",
+					"description": "How many levels to descend (default 3)",
+				},
+				"max_entries": map[string]any{
+					"type":        "integer",
+					"description": "Stop after this many entries (default 200)",
+				},
+				"show_hidden": map[string]any{
+					"type":        "boolean",
+					"description": "Include dotfiles and dot-directories (default false)",
+				},
+			},
+		},
+	}, handleDirTree)
+
+	s.AddTool(mcp.Tool{
+		Name:        "file_move",
+		Description: "Move or rename a file or directory. Parent directories of the destination are created as needed.",
+		InputSchema: mcp.ToolInputSchema{
+			Type: "object",
+			Properties: map[string]any{
+				"source": map[string]any{
+					"type":        "string",
+					"description": "Existing file or directory",
+				},
+				"destination": map[string]any{
+					"type":        "string",
+					"description": "New path",
+				},
+				"overwrite": map[string]any{
+					"type":        "boolean",
+					"description": "Replace the destination if it already exists (default false)",
+				},
+			},
+			Required: []string{"source", "destination"},
+		},
+	}, handleFileMove)
+
+	s.AddTool(mcp.Tool{
+		Name:        "file_copy",
+		Description: "Copy a file, or a directory when recursive is true. File permissions are preserved.",
+		InputSchema: mcp.ToolInputSchema{
+			Type: "object",
+			Properties: map[string]any{
+				"source": map[string]any{
+					"type":        "string",
+					"description": "File or directory to copy",
+				},
+				"destination": map[string]any{
+					"type":        "string",
+					"description": "Path of the copy",
+				},
+				"overwrite": map[string]any{
+					"type":        "boolean",
+					"description": "Replace the destination if it already exists (default false)",
+				},
+				"recursive": map[string]any{
+					"type":        "boolean",
+					"description": "Required to copy a directory",
+				},
+			},
+			Required: []string{"source", "destination"},
+		},
+	}, handleFileCopy)
+
+	s.AddTool(mcp.Tool{
+		Name:        "file_delete",
+		Description: "Delete a file or empty directory. Set recursive to delete a directory and everything in it.",
+		InputSchema: mcp.ToolInputSchema{
+			Type: "object",
+			Properties: map[string]any{
+				"path": map[string]any{
+					"type":        "string",
+					"description": "File or directory to delete",
+				},
+				"recursive": map[string]any{
+					"type":        "boolean",
+					"description": "Delete a non-empty directory and its contents",
+				},
+			},
+			Required: []string{"path"},
+		},
+	}, handleFileDelete)
+
+	s.AddTool(mcp.Tool{
+		Name:        "mkdir",
+		Description: "Create a directory, including any missing parents. Succeeds if it already exists.",
+		InputSchema: mcp.ToolInputSchema{
+			Type: "object",
+			Properties: map[string]any{
+				"path": map[string]any{
+					"type":        "string",
+					"description": "Directory to create",
+				},
+			},
+			Required: []string{"path"},
+		},
+	}, handleMkdir)
+
+	s.AddTool(mcp.Tool{
+		Name:        "file_stat",
+		Description: "Show a file's size, modification time, mode, line count, encoding (or whether it is binary), and sha256. Use it to check a file before reading it or to verify a write.",
+		InputSchema: mcp.ToolInputSchema{
+			Type: "object",
+			Properties: map[string]any{
+				"path": map[string]any{
+					"type":        "string",
+					"description": "Path to the file or directory",
+				},
+			},
+			Required: []string{"path"},
+		},
+	}, handleFileStat)
+
+	s.AddTool(mcp.Tool{
+		Name:        "file_undo",
+		Description: "Undo the most recent file_write or file_patch made in this session, restoring the previous contents (or removing a file that was newly created). Pass path to undo the latest change to that file only.",
+		InputSchema: mcp.ToolInputSchema{
+			Type: "object",
+			Properties: map[string]any{
+				"path": map[string]any{
+					"type":        "string",
+					"description": "Only undo the latest change to this file (optional)",
+				},
+			},
+		},
+	}, handleFileUndo)
+
 	if err := server.ServeStdio(s); err != nil {
 		fmt.Printf("server error: %v\n", err)
 	}
@@ -122,56 +358,234 @@ func errResult(text string) *mcp.CallToolResult {
 	}
 }
 
+// workspaceRoot, when set, confines every path the tools touch to one
+// directory tree. It holds the absolute, symlink-resolved FORGE_WORKSPACE_ROOT.
+var workspaceRoot string
+
+func setWorkspaceRoot(dir string) error {
+	if dir == "" {
+		workspaceRoot = ""
+		return nil
+	}
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return fmt.Errorf("workspace root %s: %w", dir, err)
+	}
+	real, err := filepath.EvalSymlinks(abs)
+	if err != nil {
+		return fmt.Errorf("workspace root %s: %w", dir, err)
+	}
+	workspaceRoot = real
+	return nil
+}
+
+// resolvePath checks p against the workspace root. Relative paths are taken
+// from the root, and anything that escapes it — through ".." or a symlink —
+// is rejected. Without a root, p is returned unchanged.
+func resolvePath(p string) (string, error) {
+	if workspaceRoot == "" {
+		return p, nil
+	}
+	if !filepath.IsAbs(p) {
+		p = filepath.Join(workspaceRoot, p)
+	}
+	p = filepath.Clean(p)
+
+	real, err := evalExisting(p)
+	if err != nil {
+		return "", err
+	}
+	if !within(real, workspaceRoot) {
+		return "", fmt.Errorf("%s is outside the workspace root %s", p, workspaceRoot)
+	}
+	return p, nil
+}
+
+// evalExisting resolves symlinks in the longest existing prefix of an
+// absolute, clean path, so paths that don't exist yet can still be checked.
+func evalExisting(p string) (string, error) {
+	var rest []string
+	for {
+		real, err := filepath.EvalSymlinks(p)
+		if err == nil {
+			return filepath.Join(append([]string{real}, rest...)...), nil
+		}
+		if !os.IsNotExist(err) {
+			return "", err
+		}
+		parent := filepath.Dir(p)
+		if parent == p {
+			return "", err
+		}
+		rest = append([]string{filepath.Base(p)}, rest...)
+		p = parent
+	}
+}
+
+// resolveArg reads a path argument and checks it against the workspace root.
+// An empty argument becomes def; the returned message is non-empty on error.
+func resolveArg(args map[string]any, key, def string) (string, string) {
+	p, _ := args[key].(string)
+	if p == "" {
+		p = def
+	}
+	if p == "" {
+		return "", ""
+	}
+	resolved, err := resolvePath(p)
+	if err != nil {
+		return "", fmt.Sprintf("error: %v", err)
+	}
+	return resolved, ""
+}
+
+// backups snapshots files before file_write and file_patch change them.
+var backups *backup.Store
+
+// sessionID returns the forge session a call belongs to, from the request's
+// _meta (see tools.SessionMetaKey). Calls without one share a default journal.
+func sessionID(request mcp.CallToolRequest) string {
+	if meta := request.Params.Meta; meta != nil {
+		if id, ok := meta.AdditionalFields["forge/session"].(string); ok && id != "" {
+			return id
+		}
+	}
+	return "default"
+}
+
+func handleFileUndo(_ context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	args := getArgs(request)
+	path, msg := resolveArg(args, "path", "")
+	if msg != "" {
+		return errResult(msg), nil
+	}
+
+	e, err := backups.Undo(sessionID(request), path)
+	if errors.Is(err, backup.ErrNothingToUndo) {
+		if path != "" {
+			return errResult(fmt.Sprintf("error: no changes to %s to undo in this session", path)), nil
+		}
+		return errResult("error: no file changes to undo in this session"), nil
+	}
+	if err != nil {
+		return errResult(fmt.Sprintf("error undoing: %v", err)), nil
+	}
+
+	if !e.Existed {
+		return textResult(fmt.Sprintf("undid %s: removed %s (it did not exist before)", e.Tool, e.Path)), nil
+	}
+	return textResult(fmt.Sprintf("undid %s: restored %s", e.Tool, e.Path)), nil
+}
+
+const (
+	defaultReadLines = 2000
+	defaultReadBytes = 64 << 10
+)
+
 func handleFileRead(_ context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 	args := getArgs(request)
-	path, _ := args["path"].(string)
+	path, msg := resolveArg(args, "path", "")
+	if msg != "" {
+		return errResult(msg), nil
+	}
 	if path == "" {
 		return errResult("error: 'path' is required"), nil
 	}
 
-	data, err := os.ReadFile(path)
+	// Resolve the requested window: offset/start_line through end_line,
+	// capped by limit lines and max_bytes.
+	start, ok := toInt(args["offset"])
+	if !ok {
+		start, ok = toInt(args["start_line"])
+	}
+	if !ok || start < 1 {
+		start = 1
+	}
+	limit, ok := toInt(args["limit"])
+	if !ok || limit <= 0 {
+		limit = defaultReadLines
+	}
+	end := start + limit - 1
+	endLine, hasEnd := toInt(args["end_line"])
+	if hasEnd {
+		if endLine < start {
+			return errResult("error: start_line > end_line"), nil
+		}
+		end = min(end, endLine)
+	}
+	maxBytes, ok := toInt(args["max_bytes"])
+	if !ok || maxBytes <= 0 {
+		maxBytes = defaultReadBytes
+	}
+
+	f, err := os.Open(path)
 	if err != nil {
 		return errResult(fmt.Sprintf("error reading file: %v", err)), nil
 	}
+	defer f.Close()
 
-	content := string(data)
-
-	// Handle optional line range
-	startLine, hasStart := toInt(args["start_line"])
-	endLine, hasEnd := toInt(args["end_line"])
-
-	if hasStart || hasEnd {
-		lines := strings.Split(content, "\n")
-		if !hasStart {
-			startLine = 1
-		}
-		if !hasEnd {
-			endLine = len(lines)
-		}
-		// Clamp to valid range
-		if startLine < 1 {
-			startLine = 1
+	// Stream the file so total_lines is exact without holding it all in memory.
+	var lines []string
+	size, total := 0, 0
+	reader := bufio.NewReader(f)
+	for {
+		line, err := reader.ReadString('\n')
+		if line != "" {
+			total++
+			if total >= start && total <= end {
+				text := strings.TrimSuffix(line, "\n")
+				if len(text) > maxBytes {
+					text = text[:maxBytes] + " …[line truncated]"
+				}
+				if size+len(text) > maxBytes && len(lines) > 0 {
+					end = total - 1 // page is full
+				} else {
+					lines = append(lines, text)
+					size += len(text) + 1
+				}
+			}
 		}
-		if endLine > len(lines) {
-			endLine = len(lines)
+		if err == io.EOF {
+			break
 		}
-		if startLine > endLine {
-			return errResult("error: start_line > end_line"), nil
+		if err != nil {
+			return errResult(fmt.Sprintf("error reading file: %v", err)), nil
 		}
-		content = strings.Join(lines[startLine-1:endLine], "\n")
 	}
 
-	return textResult(content), nil
+	if total == 0 {
+		return textResult("[total_lines: 0]\n"), nil
+	}
+	if start > total {
+		return errResult(fmt.Sprintf("error: offset %d is past the end of the file (total_lines: %d)", start, total)), nil
+	}
+
+	last := start + len(lines) - 1
+	header := fmt.Sprintf("[total_lines: %d, showing %d-%d", total, start, last)
+	// Point at the next page unless the caller's own end_line was reached.
+	if last < total && !(hasEnd && last >= endLine) {
+		header += fmt.Sprintf("; continue with offset=%d", last+1)
+	}
+	header += "]"
+
+	return textResult(header + "\n" + strings.Join(lines, "\n")), nil
 }
 
 func handleFileWrite(_ context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 	args := getArgs(request)
-	path, _ := args["path"].(string)
+	path, msg := resolveArg(args, "path", "")
+	if msg != "" {
+		return errResult(msg), nil
+	}
 	content, _ := args["content"].(string)
 	if path == "" {
 		return errResult("error: 'path' is required"), nil
 	}
 
+	if err := backups.Snapshot(sessionID(request), path, "file_write"); err != nil {
+		return errResult(fmt.Sprintf("error backing up file: %v", err)), nil
+	}
+
 	// Create parent directories if needed
 	if dir := filepath.Dir(path); dir != "." {
 		if err := os.MkdirAll(dir, 0o755); err != nil {
@@ -186,13 +600,40 @@ func handleFileWrite(_ context.Context, request mcp.CallToolRequest) (*mcp.CallT
 	return textResult(fmt.Sprintf("wrote %d bytes to %s", len(content), path)), nil
 }
 
+// patchEdit is one search/replace operation for file_patch.
+type patchEdit struct {
+	search     string
+	replace    string
+	replaceAll bool
+	regex      bool
+}
+
 func handleFilePatch(_ context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 	args := getArgs(request)
-	path, _ := args["path"].(string)
-	search, _ := args["search"].(string)
-	replace, _ := args["replace"].(string)
-	if path == "" || search == "" {
-		return errResult("error: 'path' and 'search' are required"), nil
+	path, msg := resolveArg(args, "path", "")
+	if msg != "" {
+		return errResult(msg), nil
+	}
+	if path == "" {
+		return errResult("error: 'path' is required"), nil
+	}
+
+	var edits []patchEdit
+	if raw, ok := args["edits"].([]any); ok && len(raw) > 0 {
+		for i, item := range raw {
+			m, _ := item.(map[string]any)
+			e := parsePatchEdit(m)
+			if e.search == "" {
+				return errResult(fmt.Sprintf("error: edit %d: 'search' is required", i+1)), nil
+			}
+			edits = append(edits, e)
+		}
+	} else {
+		e := parsePatchEdit(args)
+		if e.search == "" {
+			return errResult("error: 'path' and 'search' (or 'edits') are required"), nil
+		}
+		edits = append(edits, e)
 	}
 
 	data, err := os.ReadFile(path)
@@ -200,33 +641,106 @@ func handleFilePatch(_ context.Context, request mcp.CallToolRequest) (*mcp.CallT
 		return errResult(fmt.Sprintf("error reading file: %v", err)), nil
 	}
 
+	// Apply every edit in memory first so a failure leaves the file untouched.
 	content := string(data)
-	if !strings.Contains(content, search) {
-		return errResult("error: search string not found in file"), nil
+	total := 0
+	for i, e := range edits {
+		var n int
+		content, n, err = applyPatchEdit(content, e)
+		if err != nil {
+			return errResult(fmt.Sprintf("error: %s", editLabel(i, len(edits), err.Error()))), nil
+		}
+		if n == 0 {
+			return errResult(fmt.Sprintf("error: %s", editLabel(i, len(edits), "search string not found in file"))), nil
+		}
+		total += n
 	}
 
-	newContent := strings.Replace(content, search, replace, 1)
-	if err := os.WriteFile(path, []byte(newContent), 0o644); err != nil {
+	if err := backups.Snapshot(sessionID(request), path, "file_patch"); err != nil {
+		return errResult(fmt.Sprintf("error backing up file: %v", err)), nil
+	}
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
 		return errResult(fmt.Sprintf("error writing file: %v", err)), nil
 	}
 
-	return textResult(fmt.Sprintf("patched %s", path)), nil
+	return textResult(fmt.Sprintf("patched %s (%d replacements)", path, total)), nil
+}
+
+func parsePatchEdit(m map[string]any) patchEdit {
+	var e patchEdit
+	e.search, _ = m["search"].(string)
+	e.replace, _ = m["replace"].(string)
+	e.replaceAll, _ = m["replace_all"].(bool)
+	e.regex, _ = m["regex"].(bool)
+	return e
+}
+
+// applyPatchEdit returns content with e applied and the number of replacements.
+func applyPatchEdit(content string, e patchEdit) (string, int, error) {
+	if !e.regex {
+		n := strings.Count(content, e.search)
+		if n == 0 {
+			return content, 0, nil
+		}
+		if !e.replaceAll {
+			return strings.Replace(content, e.search, e.replace, 1), 1, nil
+		}
+		return strings.ReplaceAll(content, e.search, e.replace), n, nil
+	}
+
+	re, err := regexp.Compile(e.search)
+	if err != nil {
+		return content, 0, fmt.Errorf("invalid regex: %v", err)
+	}
+	limit := 1
+	if e.replaceAll {
+		limit = -1
+	}
+	locs := re.FindAllStringSubmatchIndex(content, limit)
+	if len(locs) == 0 {
+		return content, 0, nil
+	}
+
+	var b strings.Builder
+	last := 0
+	for _, loc := range locs {
+		b.WriteString(content[last:loc[0]])
+		b.Write(re.ExpandString(nil, e.replace, content, loc))
+		last = loc[1]
+	}
+	b.WriteString(content[last:])
+	return b.String(), len(locs), nil
+}
+
+// editLabel prefixes msg with the edit number when a batch was given.
+func editLabel(i, n int, msg string) string {
+	if n == 1 {
+		return msg
+	}
+	return fmt.Sprintf("edit %d: %s (no changes written)", i+1, msg)
 }
 
 func handleFileList(_ context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 	args := getArgs(request)
-	path, _ := args["path"].(string)
-	pattern, _ := args["pattern"].(string)
-	if path == "" {
-		path = "."
+	path, msg := resolveArg(args, "path", ".")
+	if msg != "" {
+		return errResult(msg), nil
 	}
+	pattern, _ := args["pattern"].(string)
 
 	if pattern != "" {
 		matches, err := filepath.Glob(filepath.Join(path, pattern))
 		if err != nil {
 			return errResult(fmt.Sprintf("error: %v", err)), nil
 		}
-		return textResult(strings.Join(matches, "\n")), nil
+		// The pattern itself may contain ".." or match symlinks out of the root.
+		var allowed []string
+		for _, m := range matches {
+			if _, err := resolvePath(m); err == nil {
+				allowed = append(allowed, m)
+			}
+		}
+		return textResult(strings.Join(allowed, "\n")), nil
 	}
 
 	entries, err := os.ReadDir(path)
@@ -246,6 +760,692 @@ func handleFileList(_ context.Context, request mcp.CallToolRequest) (*mcp.CallTo
 	return textResult(strings.Join(lines, "\n")), nil
 }
 
+const (
+	defaultGrepResults = 100
+	maxGrepFileSize    = 1 << 20 // skip files larger than 1 MiB
+)
+
+func handleFileGrep(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	args := getArgs(request)
+	pattern, _ := args["pattern"].(string)
+	if pattern == "" {
+		return errResult("error: 'pattern' is required"), nil
+	}
+	root, msg := resolveArg(args, "path", ".")
+	if msg != "" {
+		return errResult(msg), nil
+	}
+	if ic, _ := args["ignore_case"].(bool); ic {
+		pattern = "(?i)" + pattern
+	}
+
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return errResult(fmt.Sprintf("error: invalid pattern: %v", err)), nil
+	}
+
+	include := splitGlobs(args["include"])
+	exclude := splitGlobs(args["exclude"])
+	contextLines, _ := toInt(args["context_lines"])
+	if contextLines < 0 {
+		contextLines = 0
+	}
+	maxResults, ok := toInt(args["max_results"])
+	if !ok || maxResults <= 0 {
+		maxResults = defaultGrepResults
+	}
+
+	var out []string
+	matches := 0
+	limited := false
+
+	err = filepath.WalkDir(root, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return nil // unreadable entries are skipped, not fatal
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		name := d.Name()
+		if d.IsDir() {
+			if path != root && (strings.HasPrefix(name, ".") || name == "node_modules" || matchAny(exclude, name)) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if matchAny(exclude, name) || (len(include) > 0 && !matchAny(include, name)) {
+			return nil
+		}
+		if d.Type()&os.ModeSymlink != 0 {
+			if _, err := resolvePath(path); err != nil {
+				return nil // symlink points outside the workspace
+			}
+		}
+
+		lines, ok := readTextLines(path)
+		if !ok {
+			return nil
+		}
+
+		lastPrinted := -1
+		for i, line := range lines {
+			if !re.MatchString(line) {
+				continue
+			}
+			if matches >= maxResults {
+				limited = true
+				return filepath.SkipAll
+			}
+			matches++
+
+			start := max(i-contextLines, lastPrinted+1)
+			if contextLines > 0 && lastPrinted >= 0 && start > lastPrinted+1 {
+				out = append(out, "--")
+			}
+			end := min(i+contextLines, len(lines)-1)
+			for j := start; j <= end; j++ {
+				sep := "-"
+				if re.MatchString(lines[j]) {
+					sep = ":"
+				}
+				out = append(out, fmt.Sprintf("%s%s%d%s %s", path, sep, j+1, sep, lines[j]))
+			}
+			lastPrinted = end
+		}
+		return nil
+	})
+	if err != nil && err != filepath.SkipAll {
+		return errResult(fmt.Sprintf("error searching: %v", err)), nil
+	}
+
+	if matches == 0 {
+		return textResult("no matches"), nil
+	}
+	if limited {
+		out = append(out, fmt.Sprintf("(stopped after %d matches; narrow the pattern or raise max_results)", maxResults))
+	}
+	return textResult(strings.Join(out, "\n")), nil
+}
+
+// readTextLines returns a file's lines, or false for large or binary files.
+func readTextLines(path string) ([]string, bool) {
+	info, err := os.Stat(path)
+	if err != nil || info.Size() > maxGrepFileSize {
+		return nil, false
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, false
+	}
+	if bytes.IndexByte(data[:min(len(data), sniffLen)], 0) >= 0 {
+		return nil, false
+	}
+	return strings.Split(strings.TrimSuffix(string(data), "\n"), "\n"), true
+}
+
+// splitGlobs parses a comma-separated glob list.
+func splitGlobs(v any) []string {
+	s, _ := v.(string)
+	var globs []string
+	for _, g := range strings.Split(s, ",") {
+		if g = strings.TrimSpace(g); g != "" {
+			globs = append(globs, g)
+		}
+	}
+	return globs
+}
+
+// matchAny reports whether name matches any of the globs.
+func matchAny(globs []string, name string) bool {
+	for _, g := range globs {
+		if ok, _ := filepath.Match(g, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func handleFileMove(_ context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	args := getArgs(request)
+	src, msg := resolveArg(args, "source", "")
+	if msg != "" {
+		return errResult(msg), nil
+	}
+	dst, msg := resolveArg(args, "destination", "")
+	if msg != "" {
+		return errResult(msg), nil
+	}
+	overwrite, _ := args["overwrite"].(bool)
+	if src == "" || dst == "" {
+		return errResult("error: 'source' and 'destination' are required"), nil
+	}
+
+	if _, err := os.Lstat(src); err != nil {
+		return errResult(fmt.Sprintf("error: %v", err)), nil
+	}
+	if within(dst, src) {
+		return errResult(fmt.Sprintf("error: destination %s is inside source %s", dst, src)), nil
+	}
+	if msg := prepareDestination(dst, overwrite); msg != "" {
+		return errResult(msg), nil
+	}
+
+	if err := os.Rename(src, dst); err != nil {
+		// Rename fails across filesystems; fall back to copy and delete.
+		if err := copyPath(src, dst); err != nil {
+			return errResult(fmt.Sprintf("error moving: %v", err)), nil
+		}
+		if err := os.RemoveAll(src); err != nil {
+			return errResult(fmt.Sprintf("error removing source after copy: %v", err)), nil
+		}
+	}
+
+	return textResult(fmt.Sprintf("moved %s to %s", src, dst)), nil
+}
+
+func handleFileCopy(_ context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	args := getArgs(request)
+	src, msg := resolveArg(args, "source", "")
+	if msg != "" {
+		return errResult(msg), nil
+	}
+	dst, msg := resolveArg(args, "destination", "")
+	if msg != "" {
+		return errResult(msg), nil
+	}
+	overwrite, _ := args["overwrite"].(bool)
+	recursive, _ := args["recursive"].(bool)
+	if src == "" || dst == "" {
+		return errResult("error: 'source' and 'destination' are required"), nil
+	}
+
+	info, err := os.Stat(src)
+	if err != nil {
+		return errResult(fmt.Sprintf("error: %v", err)), nil
+	}
+	if info.IsDir() && !recursive {
+		return errResult(fmt.Sprintf("error: %s is a directory; set recursive to copy it", src)), nil
+	}
+	if within(dst, src) {
+		return errResult(fmt.Sprintf("error: destination %s is inside source %s", dst, src)), nil
+	}
+	if msg := prepareDestination(dst, overwrite); msg != "" {
+		return errResult(msg), nil
+	}
+
+	if err := copyPath(src, dst); err != nil {
+		return errResult(fmt.Sprintf("error copying: %v", err)), nil
+	}
+
+	return textResult(fmt.Sprintf("copied %s to %s", src, dst)), nil
+}
+
+func handleFileDelete(_ context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	args := getArgs(request)
+	path, msg := resolveArg(args, "path", "")
+	if msg != "" {
+		return errResult(msg), nil
+	}
+	recursive, _ := args["recursive"].(bool)
+	if path == "" {
+		return errResult("error: 'path' is required"), nil
+	}
+
+	clean := filepath.Clean(path)
+	if clean == "/" || clean == "." || (workspaceRoot != "" && clean == workspaceRoot) {
+		return errResult(fmt.Sprintf("error: refusing to delete %s", path)), nil
+	}
+
+	info, err := os.Lstat(path)
+	if err != nil {
+		return errResult(fmt.Sprintf("error: %v", err)), nil
+	}
+
+	if info.IsDir() && recursive {
+		err = os.RemoveAll(path)
+	} else {
+		err = os.Remove(path)
+	}
+	if err != nil {
+		if info.IsDir() && !recursive {
+			return errResult(fmt.Sprintf("error: %s is not empty; set recursive to delete it", path)), nil
+		}
+		return errResult(fmt.Sprintf("error deleting: %v", err)), nil
+	}
+
+	return textResult(fmt.Sprintf("deleted %s", path)), nil
+}
+
+func handleMkdir(_ context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	args := getArgs(request)
+	path, msg := resolveArg(args, "path", "")
+	if msg != "" {
+		return errResult(msg), nil
+	}
+	if path == "" {
+		return errResult("error: 'path' is required"), nil
+	}
+
+	if err := os.MkdirAll(path, 0o755); err != nil {
+		return errResult(fmt.Sprintf("error creating directory: %v", err)), nil
+	}
+
+	return textResult(fmt.Sprintf("created %s", path)), nil
+}
+
+// sniffLen is how much of a file is inspected to detect its encoding.
+const sniffLen = 8000
+
+func handleFileStat(_ context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	args := getArgs(request)
+	path, msg := resolveArg(args, "path", "")
+	if msg != "" {
+		return errResult(msg), nil
+	}
+	if path == "" {
+		return errResult("error: 'path' is required"), nil
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return errResult(fmt.Sprintf("error: %v", err)), nil
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "path: %s\n", path)
+	if info.IsDir() {
+		entries, err := os.ReadDir(path)
+		if err != nil {
+			return errResult(fmt.Sprintf("error listing directory: %v", err)), nil
+		}
+		fmt.Fprintf(&b, "type: directory\n")
+		fmt.Fprintf(&b, "entries: %d\n", len(entries))
+		fmt.Fprintf(&b, "modified: %s\n", info.ModTime().UTC().Format(time.RFC3339))
+		fmt.Fprintf(&b, "mode: %s", info.Mode())
+		return textResult(b.String()), nil
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return errResult(fmt.Sprintf("error reading file: %v", err)), nil
+	}
+	defer f.Close()
+
+	// One pass computes the hash, counts lines, and keeps the head for sniffing.
+	hash := sha256.New()
+	counter := &lineCounter{}
+	if _, err := io.Copy(io.MultiWriter(hash, counter), f); err != nil {
+		return errResult(fmt.Sprintf("error reading file: %v", err)), nil
+	}
+	encoding := detectEncoding(counter.head, counter.size > int64(len(counter.head)))
+
+	fmt.Fprintf(&b, "type: file\n")
+	fmt.Fprintf(&b, "size: %d bytes\n", counter.size)
+	fmt.Fprintf(&b, "modified: %s\n", info.ModTime().UTC().Format(time.RFC3339))
+	fmt.Fprintf(&b, "mode: %s\n", info.Mode())
+	fmt.Fprintf(&b, "encoding: %s\n", encoding)
+	if encoding != "binary" {
+		fmt.Fprintf(&b, "lines: %d\n", counter.lines())
+	}
+	fmt.Fprintf(&b, "sha256: %x", hash.Sum(nil))
+	return textResult(b.String()), nil
+}
+
+// lineCounter counts bytes and newlines written to it and keeps the first
+// sniffLen bytes.
+type lineCounter struct {
+	size     int64
+	newlines int
+	last     byte
+	head     []byte
+}
+
+func (c *lineCounter) Write(p []byte) (int, error) {
+	if room := sniffLen - len(c.head); room > 0 {
+		c.head = append(c.head, p[:min(room, len(p))]...)
+	}
+	c.size += int64(len(p))
+	c.newlines += bytes.Count(p, []byte{'\n'})
+	if len(p) > 0 {
+		c.last = p[len(p)-1]
+	}
+	return len(p), nil
+}
+
+// lines counts a final line without a trailing newline, like file_read does.
+func (c *lineCounter) lines() int {
+	if c.size > 0 && c.last != '\n' {
+		return c.newlines + 1
+	}
+	return c.newlines
+}
+
+// detectEncoding classifies a file from its first bytes. truncated reports
+// whether head is only a prefix, in which case it may end mid-character.
+func detectEncoding(head []byte, truncated bool) string {
+	switch {
+	case bytes.HasPrefix(head, []byte{0xEF, 0xBB, 0xBF}):
+		return "utf-8 (bom)"
+	case bytes.HasPrefix(head, []byte{0xFF, 0xFE}):
+		return "utf-16le"
+	case bytes.HasPrefix(head, []byte{0xFE, 0xFF}):
+		return "utf-16be"
+	case bytes.IndexByte(head, 0) >= 0:
+		return "binary"
+	}
+
+	ascii := true
+	for _, c := range head {
+		if c >= utf8.RuneSelf {
+			ascii = false
+			break
+		}
+	}
+	if ascii {
+		return "ascii"
+	}
+	for trim := 0; trim < utf8.UTFMax; trim++ {
+		if utf8.Valid(head[:len(head)-trim]) {
+			return "utf-8"
+		}
+		if !truncated || trim >= len(head) {
+			break
+		}
+	}
+	return "unknown (not utf-8)"
+}
+
+// prepareDestination clears dst (if overwrite) and creates its parent
+// directory. It returns an error message, or "" on success.
+func prepareDestination(dst string, overwrite bool) string {
+	if _, err := os.Lstat(dst); err == nil {
+		if !overwrite {
+			return fmt.Sprintf("error: %s already exists; set overwrite to replace it", dst)
+		}
+		if err := os.RemoveAll(dst); err != nil {
+			return fmt.Sprintf("error removing existing destination: %v", err)
+		}
+	}
+	if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+		return fmt.Sprintf("error creating directories: %v", err)
+	}
+	return ""
+}
+
+// within reports whether p is dir itself or somewhere beneath it.
+func within(p, dir string) bool {
+	absP, err1 := filepath.Abs(p)
+	absDir, err2 := filepath.Abs(dir)
+	if err1 != nil || err2 != nil {
+		return false
+	}
+	rel, err := filepath.Rel(absDir, absP)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// copyPath copies a file, symlink, or directory tree from src to dst.
+func copyPath(src, dst string) error {
+	info, err := os.Lstat(src)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case info.Mode()&os.ModeSymlink != 0:
+		target, err := os.Readlink(src)
+		if err != nil {
+			return err
+		}
+		return os.Symlink(target, dst)
+	case info.IsDir():
+		if err := os.MkdirAll(dst, info.Mode().Perm()); err != nil {
+			return err
+		}
+		entries, err := os.ReadDir(src)
+		if err != nil {
+			return err
+		}
+		for _, e := range entries {
+			if err := copyPath(filepath.Join(src, e.Name()), filepath.Join(dst, e.Name())); err != nil {
+				return err
+			}
+		}
+		return nil
+	default:
+		in, err := os.Open(src)
+		if err != nil {
+			return err
+		}
+		defer in.Close()
+		out, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, info.Mode().Perm())
+		if err != nil {
+			return err
+		}
+		if _, err := io.Copy(out, in); err != nil {
+			out.Close()
+			return err
+		}
+		return out.Close()
+	}
+}
+
+const (
+	defaultTreeDepth   = 3
+	defaultTreeEntries = 200
+)
+
+// treeWalker renders a directory tree while tracking .gitignore rules and
+// the entry budget.
+type treeWalker struct {
+	root       string
+	maxDepth   int
+	maxEntries int
+	showHidden bool
+
+	out     []string
+	entries int
+	dirs    int
+	files   int
+	limited bool
+}
+
+func handleDirTree(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	args := getArgs(request)
+	root, msg := resolveArg(args, "path", ".")
+	if msg != "" {
+		return errResult(msg), nil
+	}
+
+	info, err := os.Stat(root)
+	if err != nil {
+		return errResult(fmt.Sprintf("error: %v", err)), nil
+	}
+	if !info.IsDir() {
+		return errResult(fmt.Sprintf("error: %s is not a directory", root)), nil
+	}
+
+	w := &treeWalker{root: root, maxDepth: defaultTreeDepth, maxEntries: defaultTreeEntries}
+	if n, ok := toInt(args["max_depth"]); ok && n > 0 {
+		w.maxDepth = n
+	}
+	if n, ok := toInt(args["max_entries"]); ok && n > 0 {
+		w.maxEntries = n
+	}
+	w.showHidden, _ = args["show_hidden"].(bool)
+
+	w.out = append(w.out, filepath.Clean(root)+"/")
+	if err := w.walk(ctx, root, "", "", 1, nil); err != nil {
+		return errResult(fmt.Sprintf("error: %v", err)), nil
+	}
+
+	summary := fmt.Sprintf("\n%d directories, %d files", w.dirs, w.files)
+	if w.limited {
+		summary += fmt.Sprintf(" (stopped after %d entries; raise max_entries or narrow the path)", w.maxEntries)
+	}
+	return textResult(strings.Join(w.out, "\n") + summary), nil
+}
+
+// walk appends the children of dir. rel is dir's slash-separated path from the
+// root, prefix is the indentation drawn so far, and rules are the inherited
+// ignore rules.
+func (w *treeWalker) walk(ctx context.Context, dir, rel, prefix string, depth int, rules []ignoreRule) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	rules = append(rules, loadGitignore(dir, rel)...)
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		w.out = append(w.out, prefix+"└── [unreadable: "+err.Error()+"]")
+		return nil
+	}
+
+	var visible []os.DirEntry
+	for _, e := range entries {
+		name := e.Name()
+		if name == ".git" || (!w.showHidden && strings.HasPrefix(name, ".")) {
+			continue
+		}
+		if ignored(rules, path.Join(rel, name), e.IsDir()) {
+			continue
+		}
+		visible = append(visible, e)
+	}
+	// Directories first, then files, each alphabetically.
+	sort.SliceStable(visible, func(i, j int) bool {
+		if visible[i].IsDir() != visible[j].IsDir() {
+			return visible[i].IsDir()
+		}
+		return visible[i].Name() < visible[j].Name()
+	})
+
+	for i, e := range visible {
+		if w.entries >= w.maxEntries {
+			w.limited = true
+			w.out = append(w.out, fmt.Sprintf("%s└── … %d more", prefix, len(visible)-i))
+			return nil
+		}
+		w.entries++
+
+		last := i == len(visible)-1
+		branch, indent := "├── ", "│   "
+		if last {
+			branch, indent = "└── ", "    "
+		}
+
+		name := e.Name()
+		if !e.IsDir() {
+			w.files++
+			w.out = append(w.out, prefix+branch+name)
+			continue
+		}
+
+		w.dirs++
+		w.out = append(w.out, prefix+branch+name+"/")
+		if depth < w.maxDepth {
+			if err := w.walk(ctx, filepath.Join(dir, name), path.Join(rel, name), prefix+indent, depth+1, rules); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// ignoreRule is one line of a .gitignore file.
+type ignoreRule struct {
+	base     string // directory of the .gitignore, relative to the tree root
+	pattern  string
+	negate   bool
+	dirOnly  bool
+	anchored bool // pattern contains a slash, so it matches from base
+}
+
+// loadGitignore parses dir/.gitignore. Missing files yield no rules.
+func loadGitignore(dir, rel string) []ignoreRule {
+	data, err := os.ReadFile(filepath.Join(dir, ".gitignore"))
+	if err != nil {
+		return nil
+	}
+
+	var rules []ignoreRule
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimRight(line, " \r")
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		r := ignoreRule{base: rel}
+		if strings.HasPrefix(line, "!") {
+			r.negate = true
+			line = line[1:]
+		}
+		if strings.HasSuffix(line, "/") {
+			r.dirOnly = true
+			line = strings.TrimSuffix(line, "/")
+		}
+		if strings.Contains(line, "/") {
+			r.anchored = true
+			line = strings.TrimPrefix(line, "/")
+		}
+		if line == "" {
+			continue
+		}
+		r.pattern = line
+		rules = append(rules, r)
+	}
+	return rules
+}
+
+// ignored applies rules in order; the last matching rule wins, as in git.
+func ignored(rules []ignoreRule, rel string, isDir bool) bool {
+	result := false
+	for _, r := range rules {
+		if r.dirOnly && !isDir {
+			continue
+		}
+		p := rel
+		if r.base != "" {
+			if !strings.HasPrefix(rel, r.base+"/") {
+				continue
+			}
+			p = strings.TrimPrefix(rel, r.base+"/")
+		}
+		var match bool
+		if r.anchored {
+			match = matchPath(strings.Split(r.pattern, "/"), strings.Split(p, "/"))
+		} else {
+			match, _ = path.Match(r.pattern, path.Base(p))
+		}
+		if match {
+			result = !r.negate
+		}
+	}
+	return result
+}
+
+// matchPath matches slash-separated segments where "**" spans any number of
+// directories.
+func matchPath(pattern, parts []string) bool {
+	if len(pattern) == 0 {
+		return len(parts) == 0
+	}
+	if pattern[0] == "**" {
+		for i := 0; i <= len(parts); i++ {
+			if matchPath(pattern[1:], parts[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+	if len(parts) == 0 {
+		return false
+	}
+	if ok, _ := path.Match(pattern[0], parts[0]); !ok {
+		return false
+	}
+	return matchPath(pattern[1:], parts[1:])
+}
+
 func toInt(v any) (int, bool) {
 	switch n := v.(type) {
 	case float64:
@@ -0,0 +1,822 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// workspaceRoot, when set from FORGE_WORKSPACE_ROOT, is the default
+// repository directory and the boundary every "dir" argument must stay in.
+var workspaceRoot string
+
+func main() {
+	if root := os.Getenv("FORGE_WORKSPACE_ROOT"); root != "" {
+		real, err := filepath.EvalSymlinks(root)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "forge-git-ops: workspace root %s: %v\n", root, err)
+			os.Exit(1)
+		}
+		workspaceRoot, _ = filepath.Abs(real)
+	}
+
+	s := server.NewMCPServer("forge-git-ops", "0.1.0")
+
+	dirProp := map[string]any{
+		"type":        "string",
+		"description": "Repository directory (default: the working directory)",
+	}
+
+	s.AddTool(mcp.Tool{
+		Name:        "git_status",
+		Description: "Show the current branch, upstream ahead/behind counts, and changed files as JSON. Each file has index (staged) and worktree (unstaged) status letters: M modified, A added, D deleted, R renamed, ? untracked, U conflicted, . unchanged.",
+		InputSchema: mcp.ToolInputSchema{
+			Type:       "object",
+			Properties: map[string]any{"dir": dirProp},
+		},
+	}, handleStatus)
+
+	s.AddTool(mcp.Tool{
+		Name:        "git_diff",
+		Description: "Show changes as a unified diff: unstaged changes by default, staged changes with staged=true, or changes since a ref.",
+		InputSchema: mcp.ToolInputSchema{
+			Type: "object",
+			Properties: map[string]any{
+				"dir": dirProp,
+				"staged": map[string]any{
+					"type":        "boolean",
+					"description": "Show staged changes instead of unstaged ones",
+				},
+				"ref": map[string]any{
+					"type":        "string",
+					"description": "Compare the working tree (or index, if staged) against this commit or branch",
+				},
+				"paths": map[string]any{
+					"type":        "array",
+					"items":       map[string]any{"type": "string"},
+					"description": "Limit the diff to these paths",
+				},
+				"stat": map[string]any{
+					"type":        "boolean",
+					"description": "Only show a per-file summary of changed lines",
+				},
+				"max_bytes": map[string]any{
+					"type":        "integer",
+					"description": "Truncate output after this many bytes (default: 65536)",
+				},
+			},
+		},
+	}, handleDiff)
+
+	s.AddTool(mcp.Tool{
+		Name:        "git_log",
+		Description: "List recent commits as JSON (sha, author, date, subject).",
+		InputSchema: mcp.ToolInputSchema{
+			Type: "object",
+			Properties: map[string]any{
+				"dir": dirProp,
+				"ref": map[string]any{
+					"type":        "string",
+					"description": "Branch, tag, or range such as main..feature (default: HEAD)",
+				},
+				"path": map[string]any{
+					"type":        "string",
+					"description": "Only commits touching this path",
+				},
+				"author": map[string]any{
+					"type":        "string",
+					"description": "Only commits whose author matches this pattern",
+				},
+				"grep": map[string]any{
+					"type":        "string",
+					"description": "Only commits whose message matches this pattern",
+				},
+				"limit": map[string]any{
+					"type":        "integer",
+					"description": "Maximum number of commits (default: 20)",
+				},
+			},
+		},
+	}, handleLog)
+
+	s.AddTool(mcp.Tool{
+		Name:        "git_blame",
+		Description: "Show who last changed each line of a file, as JSON.",
+		InputSchema: mcp.ToolInputSchema{
+			Type: "object",
+			Properties: map[string]any{
+				"dir": dirProp,
+				"path": map[string]any{
+					"type":        "string",
+					"description": "File to blame",
+				},
+				"start_line": map[string]any{
+					"type":        "integer",
+					"description": "First line (1-based, default: 1)",
+				},
+				"end_line": map[string]any{
+					"type":        "integer",
+					"description": "Last line (default: start_line + 99)",
+				},
+				"ref": map[string]any{
+					"type":        "string",
+					"description": "Blame the file as of this commit (default: working tree)",
+				},
+			},
+			Required: []string{"path"},
+		},
+	}, handleBlame)
+
+	s.AddTool(mcp.Tool{
+		Name:        "git_show",
+		Description: "Show a commit's message and patch, or with path, a file's contents at that commit.",
+		InputSchema: mcp.ToolInputSchema{
+			Type: "object",
+			Properties: map[string]any{
+				"dir": dirProp,
+				"ref": map[string]any{
+					"type":        "string",
+					"description": "Commit, branch, or tag (default: HEAD)",
+				},
+				"path": map[string]any{
+					"type":        "string",
+					"description": "Show this file as of ref instead of the commit",
+				},
+				"max_bytes": map[string]any{
+					"type":        "integer",
+					"description": "Truncate output after this many bytes (default: 65536)",
+				},
+			},
+		},
+	}, handleShow)
+
+	s.AddTool(mcp.Tool{
+		Name:        "git_branch",
+		Description: "List branches (as JSON), create a branch, or switch to one.",
+		InputSchema: mcp.ToolInputSchema{
+			Type: "object",
+			Properties: map[string]any{
+				"dir": dirProp,
+				"action": map[string]any{
+					"type":        "string",
+					"description": "list, create, or switch (default: list)",
+				},
+				"name": map[string]any{
+					"type":        "string",
+					"description": "Branch to create or switch to",
+				},
+				"start_point": map[string]any{
+					"type":        "string",
+					"description": "Commit to start a new branch from (default: HEAD)",
+				},
+				"switch": map[string]any{
+					"type":        "boolean",
+					"description": "With create, also switch to the new branch",
+				},
+			},
+		},
+	}, handleBranch)
+
+	s.AddTool(mcp.Tool{
+		Name:        "git_add",
+		Description: "Stage files for the next commit.",
+		InputSchema: mcp.ToolInputSchema{
+			Type: "object",
+			Properties: map[string]any{
+				"dir": dirProp,
+				"paths": map[string]any{
+					"type":        "array",
+					"items":       map[string]any{"type": "string"},
+					"description": `Paths to stage ("." for everything)`,
+				},
+			},
+			Required: []string{"paths"},
+		},
+	}, handleAdd)
+
+	s.AddTool(mcp.Tool{
+		Name:        "git_commit",
+		Description: "Commit staged changes with a message. It never amends, bypasses hooks, or pushes.",
+		InputSchema: mcp.ToolInputSchema{
+			Type: "object",
+			Properties: map[string]any{
+				"dir": dirProp,
+				"message": map[string]any{
+					"type":        "string",
+					"description": "Commit message",
+				},
+				"all": map[string]any{
+					"type":        "boolean",
+					"description": "Also stage modified and deleted tracked files first (like git commit -a)",
+				},
+			},
+			Required: []string{"message"},
+		},
+	}, handleCommit)
+
+	s.AddTool(mcp.Tool{
+		Name:        "git_stash",
+		Description: "Save uncommitted changes to the stash and restore them later.",
+		InputSchema: mcp.ToolInputSchema{
+			Type: "object",
+			Properties: map[string]any{
+				"dir": dirProp,
+				"action": map[string]any{
+					"type":        "string",
+					"description": "push, pop, apply, list, or show (default: push)",
+				},
+				"message": map[string]any{
+					"type":        "string",
+					"description": "Description for push",
+				},
+				"include_untracked": map[string]any{
+					"type":        "boolean",
+					"description": "With push, also stash untracked files",
+				},
+				"index": map[string]any{
+					"type":        "integer",
+					"description": "Stash entry for pop, apply, or show (default: 0, the latest)",
+				},
+			},
+		},
+	}, handleStash)
+
+	if err := server.ServeStdio(s); err != nil {
+		fmt.Printf("server error: %v\n", err)
+	}
+}
+
+func getArgs(request mcp.CallToolRequest) map[string]any {
+	args, _ := request.Params.Arguments.(map[string]any)
+	if args == nil {
+		args = make(map[string]any)
+	}
+	return args
+}
+
+func textResult(text string) *mcp.CallToolResult {
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{mcp.TextContent{Type: "text", Text: text}},
+	}
+}
+
+func errResult(text string) *mcp.CallToolResult {
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{mcp.TextContent{Type: "text", Text: text}},
+		IsError: true,
+	}
+}
+
+func jsonResult(v any) *mcp.CallToolResult {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return errResult(fmt.Sprintf("error encoding result: %v", err))
+	}
+	return textResult(string(data))
+}
+
+// repoDir resolves the "dir" argument, keeping it inside the workspace root.
+func repoDir(args map[string]any) (string, error) {
+	dir, _ := args["dir"].(string)
+	if workspaceRoot == "" {
+		if dir == "" {
+			dir = "."
+		}
+		return dir, nil
+	}
+
+	if !filepath.IsAbs(dir) {
+		dir = filepath.Join(workspaceRoot, dir)
+	}
+	real, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		return "", err
+	}
+	rel, err := filepath.Rel(workspaceRoot, real)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("%s is outside the workspace root %s", dir, workspaceRoot)
+	}
+	return real, nil
+}
+
+// git runs git in dir with prompts, pagers, and editors disabled.
+func git(ctx context.Context, dir string, args ...string) (string, error) {
+	cmd := exec.CommandContext(ctx, "git", append([]string{"-C", dir, "--no-pager"}, args...)...)
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0", "GIT_EDITOR=true", "GIT_OPTIONAL_LOCKS=0")
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		msg := strings.TrimSpace(stderr.String())
+		if msg == "" {
+			msg = strings.TrimSpace(stdout.String())
+		}
+		if msg == "" {
+			msg = err.Error()
+		}
+		return "", errors.New(msg)
+	}
+	return stdout.String(), nil
+}
+
+// safeArg rejects values git would parse as options, since refs and patterns
+// come from the model.
+func safeArg(name, v string) error {
+	if strings.HasPrefix(v, "-") {
+		return fmt.Errorf("%s must not start with '-'", name)
+	}
+	if strings.ContainsAny(v, "\x00\n") {
+		return fmt.Errorf("%s contains invalid characters", name)
+	}
+	return nil
+}
+
+// stringArgs reads optional string arguments, checking each with safeArg.
+func stringArgs(args map[string]any, keys ...string) (map[string]string, error) {
+	out := make(map[string]string, len(keys))
+	for _, k := range keys {
+		v, _ := args[k].(string)
+		if v == "" {
+			continue
+		}
+		if err := safeArg(k, v); err != nil {
+			return nil, err
+		}
+		out[k] = v
+	}
+	return out, nil
+}
+
+// pathsArg reads a list of paths, accepting an array or a single string.
+func pathsArg(args map[string]any) []string {
+	var paths []string
+	switch v := args["paths"].(type) {
+	case []any:
+		for _, p := range v {
+			if s, ok := p.(string); ok && s != "" {
+				paths = append(paths, s)
+			}
+		}
+	case string:
+		if v != "" {
+			paths = append(paths, v)
+		}
+	}
+	return paths
+}
+
+const defaultMaxBytes = 64 << 10
+
+func truncate(out string, args map[string]any) string {
+	max := defaultMaxBytes
+	if n, ok := args["max_bytes"].(float64); ok && n > 0 {
+		max = int(n)
+	}
+	if len(out) <= max {
+		return out
+	}
+	return fmt.Sprintf("%s\n[output truncated: showing %d of %d bytes]", out[:max], max, len(out))
+}
+
+type fileStatus struct {
+	Path     string `json:"path"`
+	OrigPath string `json:"orig_path,omitempty"`
+	Index    string `json:"index"`
+	Worktree string `json:"worktree"`
+}
+
+type repoStatus struct {
+	Branch   string       `json:"branch"`
+	Commit   string       `json:"commit,omitempty"`
+	Upstream string       `json:"upstream,omitempty"`
+	Ahead    int          `json:"ahead"`
+	Behind   int          `json:"behind"`
+	Clean    bool         `json:"clean"`
+	Files    []fileStatus `json:"files"`
+}
+
+func handleStatus(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	dir, err := repoDir(getArgs(request))
+	if err != nil {
+		return errResult(fmt.Sprintf("error: %v", err)), nil
+	}
+	out, err := git(ctx, dir, "status", "--porcelain=v2", "--branch", "-z")
+	if err != nil {
+		return errResult(fmt.Sprintf("error: %v", err)), nil
+	}
+	st := parseStatus(out)
+	return jsonResult(st), nil
+}
+
+// parseStatus parses `git status --porcelain=v2 --branch -z`.
+func parseStatus(out string) repoStatus {
+	st := repoStatus{Files: []fileStatus{}}
+	records := strings.Split(strings.TrimSuffix(out, "\x00"), "\x00")
+	for i := 0; i < len(records); i++ {
+		rec := records[i]
+		switch {
+		case strings.HasPrefix(rec, "# branch.oid "):
+			if oid := strings.TrimPrefix(rec, "# branch.oid "); oid != "(initial)" {
+				st.Commit = oid
+			}
+		case strings.HasPrefix(rec, "# branch.head "):
+			st.Branch = strings.TrimPrefix(rec, "# branch.head ")
+		case strings.HasPrefix(rec, "# branch.upstream "):
+			st.Upstream = strings.TrimPrefix(rec, "# branch.upstream ")
+		case strings.HasPrefix(rec, "# branch.ab "):
+			fmt.Sscanf(strings.TrimPrefix(rec, "# branch.ab "), "+%d -%d", &st.Ahead, &st.Behind)
+		case strings.HasPrefix(rec, "1 "):
+			// 1 XY sub mH mI mW hH hI path
+			if f := strings.SplitN(rec, " ", 9); len(f) == 9 {
+				st.Files = append(st.Files, fileStatus{Path: f[8], Index: f[1][:1], Worktree: f[1][1:]})
+			}
+		case strings.HasPrefix(rec, "2 "):
+			// 2 XY sub mH mI mW hH hI Xscore path, then origPath as the next record
+			if f := strings.SplitN(rec, " ", 10); len(f) == 10 {
+				fs := fileStatus{Path: f[9], Index: f[1][:1], Worktree: f[1][1:]}
+				if i+1 < len(records) {
+					i++
+					fs.OrigPath = records[i]
+				}
+				st.Files = append(st.Files, fs)
+			}
+		case strings.HasPrefix(rec, "u "):
+			// u XY sub m1 m2 m3 mW h1 h2 h3 path
+			if f := strings.SplitN(rec, " ", 11); len(f) == 11 {
+				st.Files = append(st.Files, fileStatus{Path: f[10], Index: "U", Worktree: "U"})
+			}
+		case strings.HasPrefix(rec, "? "):
+			st.Files = append(st.Files, fileStatus{Path: rec[2:], Index: "?", Worktree: "?"})
+		}
+	}
+	st.Clean = len(st.Files) == 0
+	return st
+}
+
+func handleDiff(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	args := getArgs(request)
+	dir, err := repoDir(args)
+	if err != nil {
+		return errResult(fmt.Sprintf("error: %v", err)), nil
+	}
+	opts, err := stringArgs(args, "ref")
+	if err != nil {
+		return errResult(fmt.Sprintf("error: %v", err)), nil
+	}
+
+	gitArgs := []string{"diff", "--no-color", "--no-ext-diff"}
+	if staged, _ := args["staged"].(bool); staged {
+		gitArgs = append(gitArgs, "--cached")
+	}
+	if stat, _ := args["stat"].(bool); stat {
+		gitArgs = append(gitArgs, "--stat")
+	}
+	if ref := opts["ref"]; ref != "" {
+		gitArgs = append(gitArgs, ref)
+	}
+	gitArgs = append(append(gitArgs, "--"), pathsArg(args)...)
+
+	out, err := git(ctx, dir, gitArgs...)
+	if err != nil {
+		return errResult(fmt.Sprintf("error: %v", err)), nil
+	}
+	if out == "" {
+		return textResult("No changes."), nil
+	}
+	return textResult(truncate(out, args)), nil
+}
+
+type commit struct {
+	SHA     string    `json:"sha"`
+	Author  string    `json:"author"`
+	Email   string    `json:"email"`
+	Date    time.Time `json:"date"`
+	Subject string    `json:"subject"`
+}
+
+func handleLog(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	args := getArgs(request)
+	dir, err := repoDir(args)
+	if err != nil {
+		return errResult(fmt.Sprintf("error: %v", err)), nil
+	}
+	opts, err := stringArgs(args, "ref", "path", "author", "grep")
+	if err != nil {
+		return errResult(fmt.Sprintf("error: %v", err)), nil
+	}
+	limit := 20
+	if n, ok := args["limit"].(float64); ok && n > 0 {
+		limit = int(n)
+	}
+
+	// Unit and record separators keep subjects with any punctuation intact.
+	gitArgs := []string{"log", "--no-color", "-n", strconv.Itoa(limit), "--format=%H%x1f%an%x1f%ae%x1f%aI%x1f%s%x1e"}
+	if a := opts["author"]; a != "" {
+		gitArgs = append(gitArgs, "--author="+a)
+	}
+	if g := opts["grep"]; g != "" {
+		gitArgs = append(gitArgs, "--grep="+g)
+	}
+	if ref := opts["ref"]; ref != "" {
+		gitArgs = append(gitArgs, ref)
+	}
+	gitArgs = append(gitArgs, "--")
+	if p := opts["path"]; p != "" {
+		gitArgs = append(gitArgs, p)
+	}
+
+	out, err := git(ctx, dir, gitArgs...)
+	if err != nil {
+		return errResult(fmt.Sprintf("error: %v", err)), nil
+	}
+
+	commits := []commit{}
+	for _, rec := range strings.Split(out, "\x1e") {
+		f := strings.Split(strings.TrimSpace(rec), "\x1f")
+		if len(f) != 5 {
+			continue
+		}
+		date, _ := time.Parse(time.RFC3339, f[3])
+		commits = append(commits, commit{SHA: f[0], Author: f[1], Email: f[2], Date: date, Subject: f[4]})
+	}
+	return jsonResult(commits), nil
+}
+
+type blameLine struct {
+	Line   int       `json:"line"`
+	SHA    string    `json:"sha"`
+	Author string    `json:"author"`
+	Date   time.Time `json:"date"`
+	Text   string    `json:"text"`
+}
+
+func handleBlame(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	args := getArgs(request)
+	dir, err := repoDir(args)
+	if err != nil {
+		return errResult(fmt.Sprintf("error: %v", err)), nil
+	}
+	opts, err := stringArgs(args, "path", "ref")
+	if err != nil {
+		return errResult(fmt.Sprintf("error: %v", err)), nil
+	}
+	if opts["path"] == "" {
+		return errResult("error: 'path' is required"), nil
+	}
+
+	start := 1
+	if n, ok := args["start_line"].(float64); ok && n > 0 {
+		start = int(n)
+	}
+	end := start + 99
+	if n, ok := args["end_line"].(float64); ok && int(n) >= start {
+		end = int(n)
+	}
+
+	gitArgs := []string{"blame", "--line-porcelain", "-L", fmt.Sprintf("%d,%d", start, end)}
+	if ref := opts["ref"]; ref != "" {
+		gitArgs = append(gitArgs, ref)
+	}
+	gitArgs = append(gitArgs, "--", opts["path"])
+
+	out, err := git(ctx, dir, gitArgs...)
+	if err != nil {
+		return errResult(fmt.Sprintf("error: %v", err)), nil
+	}
+	return jsonResult(parseBlame(out)), nil
+}
+
+// parseBlame parses `git blame --line-porcelain`, where every line has a
+// full header followed by the content prefixed with a tab.
+func parseBlame(out string) []blameLine {
+	lines := []blameLine{}
+	var cur blameLine
+	for _, l := range strings.Split(out, "\n") {
+		switch {
+		case strings.HasPrefix(l, "\t"):
+			cur.Text = l[1:]
+			lines = append(lines, cur)
+			cur = blameLine{}
+		case strings.HasPrefix(l, "author "):
+			cur.Author = strings.TrimPrefix(l, "author ")
+		case strings.HasPrefix(l, "author-time "):
+			if sec, err := strconv.ParseInt(strings.TrimPrefix(l, "author-time "), 10, 64); err == nil {
+				cur.Date = time.Unix(sec, 0).UTC()
+			}
+		case cur.SHA == "" && len(l) > 40 && l[40] == ' ':
+			// <sha> <orig-line> <final-line> [<group-size>]
+			f := strings.Fields(l)
+			cur.SHA = f[0][:12]
+			if len(f) >= 3 {
+				cur.Line, _ = strconv.Atoi(f[2])
+			}
+		}
+	}
+	return lines
+}
+
+func handleShow(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	args := getArgs(request)
+	dir, err := repoDir(args)
+	if err != nil {
+		return errResult(fmt.Sprintf("error: %v", err)), nil
+	}
+	opts, err := stringArgs(args, "ref", "path")
+	if err != nil {
+		return errResult(fmt.Sprintf("error: %v", err)), nil
+	}
+	ref := opts["ref"]
+	if ref == "" {
+		ref = "HEAD"
+	}
+
+	var out string
+	if p := opts["path"]; p != "" {
+		out, err = git(ctx, dir, "show", ref+":"+filepath.ToSlash(p))
+	} else {
+		out, err = git(ctx, dir, "show", "--no-color", "--no-ext-diff", "--stat", "--patch", ref, "--")
+	}
+	if err != nil {
+		return errResult(fmt.Sprintf("error: %v", err)), nil
+	}
+	return textResult(truncate(out, args)), nil
+}
+
+type branch struct {
+	Name     string `json:"name"`
+	Current  bool   `json:"current"`
+	Commit   string `json:"commit"`
+	Upstream string `json:"upstream,omitempty"`
+	Subject  string `json:"subject"`
+}
+
+func handleBranch(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	args := getArgs(request)
+	dir, err := repoDir(args)
+	if err != nil {
+		return errResult(fmt.Sprintf("error: %v", err)), nil
+	}
+	opts, err := stringArgs(args, "name", "start_point")
+	if err != nil {
+		return errResult(fmt.Sprintf("error: %v", err)), nil
+	}
+	name := opts["name"]
+
+	action, _ := args["action"].(string)
+	switch action {
+	case "", "list":
+		out, err := git(ctx, dir, "for-each-ref", "--format=%(HEAD)%1f%(refname:short)%1f%(objectname:short)%1f%(upstream:short)%1f%(contents:subject)", "refs/heads")
+		if err != nil {
+			return errResult(fmt.Sprintf("error: %v", err)), nil
+		}
+		branches := []branch{}
+		for _, line := range strings.Split(strings.TrimSpace(out), "\n") {
+			f := strings.Split(line, "\x1f")
+			if len(f) != 5 {
+				continue
+			}
+			branches = append(branches, branch{Current: f[0] == "*", Name: f[1], Commit: f[2], Upstream: f[3], Subject: f[4]})
+		}
+		return jsonResult(branches), nil
+
+	case "create", "switch":
+		if name == "" {
+			return errResult("error: 'name' is required"), nil
+		}
+		if _, err := git(ctx, dir, "check-ref-format", "--branch", name); err != nil {
+			return errResult(fmt.Sprintf("error: invalid branch name %q", name)), nil
+		}
+
+		var gitArgs []string
+		doSwitch, _ := args["switch"].(bool)
+		switch {
+		case action == "switch":
+			gitArgs = []string{"switch", name}
+		case doSwitch:
+			gitArgs = []string{"switch", "-c", name}
+		default:
+			gitArgs = []string{"branch", name}
+		}
+		if sp := opts["start_point"]; sp != "" && action == "create" {
+			gitArgs = append(gitArgs, sp)
+		}
+		if _, err := git(ctx, dir, gitArgs...); err != nil {
+			return errResult(fmt.Sprintf("error: %v", err)), nil
+		}
+
+		switch {
+		case action == "switch":
+			return textResult(fmt.Sprintf("switched to %s", name)), nil
+		case doSwitch:
+			return textResult(fmt.Sprintf("created and switched to %s", name)), nil
+		}
+		return textResult(fmt.Sprintf("created %s", name)), nil
+
+	default:
+		return errResult(fmt.Sprintf("error: unknown action %q (use list, create, or switch)", action)), nil
+	}
+}
+
+func handleAdd(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	args := getArgs(request)
+	dir, err := repoDir(args)
+	if err != nil {
+		return errResult(fmt.Sprintf("error: %v", err)), nil
+	}
+	paths := pathsArg(args)
+	if len(paths) == 0 {
+		return errResult("error: 'paths' is required"), nil
+	}
+
+	if _, err := git(ctx, dir, append([]string{"add", "--"}, paths...)...); err != nil {
+		return errResult(fmt.Sprintf("error: %v", err)), nil
+	}
+	staged, err := git(ctx, dir, "diff", "--cached", "--name-only")
+	if err != nil {
+		return errResult(fmt.Sprintf("error: %v", err)), nil
+	}
+	return textResult("staged:\n" + strings.TrimSpace(staged)), nil
+}
+
+func handleCommit(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	args := getArgs(request)
+	dir, err := repoDir(args)
+	if err != nil {
+		return errResult(fmt.Sprintf("error: %v", err)), nil
+	}
+	message, _ := args["message"].(string)
+	if strings.TrimSpace(message) == "" {
+		return errResult("error: 'message' is required"), nil
+	}
+
+	// -F - reads the message from stdin so it can't be mistaken for an option.
+	gitArgs := []string{"-C", dir, "--no-pager", "commit", "-F", "-"}
+	if all, _ := args["all"].(bool); all {
+		gitArgs = append(gitArgs, "--all")
+	}
+	cmd := exec.CommandContext(ctx, "git", gitArgs...)
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0", "GIT_EDITOR=true")
+	cmd.Stdin = strings.NewReader(message)
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return errResult(fmt.Sprintf("error: %s", strings.TrimSpace(string(out)))), nil
+	}
+	return textResult(strings.TrimSpace(string(out))), nil
+}
+
+func handleStash(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	args := getArgs(request)
+	dir, err := repoDir(args)
+	if err != nil {
+		return errResult(fmt.Sprintf("error: %v", err)), nil
+	}
+	entry := "stash@{0}"
+	if n, ok := args["index"].(float64); ok && n >= 0 {
+		entry = fmt.Sprintf("stash@{%d}", int(n))
+	}
+
+	var gitArgs []string
+	action, _ := args["action"].(string)
+	switch action {
+	case "", "push":
+		gitArgs = []string{"stash", "push"}
+		if u, _ := args["include_untracked"].(bool); u {
+			gitArgs = append(gitArgs, "--include-untracked")
+		}
+		if msg, _ := args["message"].(string); msg != "" {
+			gitArgs = append(gitArgs, "--message", msg)
+		}
+	case "pop", "apply":
+		gitArgs = []string{"stash", action, entry}
+	case "show":
+		gitArgs = []string{"stash", "show", "--stat", "--patch", "--no-color", entry}
+	case "list":
+		gitArgs = []string{"stash", "list"}
+	default:
+		return errResult(fmt.Sprintf("error: unknown action %q (use push, pop, apply, list, or show)", action)), nil
+	}
+
+	out, err := git(ctx, dir, gitArgs...)
+	if err != nil {
+		return errResult(fmt.Sprintf("error: %v", err)), nil
+	}
+	out = strings.TrimSpace(out)
+	if out == "" {
+		if action == "list" {
+			return textResult("No stash entries."), nil
+		}
+		out = "ok"
+	}
+	return textResult(truncate(out, args)), nil
+}
@@ -1,21 +1,43 @@
 package main
 
 import (
+	"bufio"
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"net/http"
+	"net/url"
+	"os"
 	"os/exec"
+	"regexp"
 	"strings"
+	"time"
 
+	"github.com/google/go-github/v74/github"
 	"github.com/mark3labs/mcp-go/mcp"
 	"github.com/mark3labs/mcp-go/server"
+
+	"github.com/michaelbrown/forge/internal/codehost"
 )
 
+// gh is the GitHub API client, authenticated with GITHUB_TOKEN (or GH_TOKEN)
+// when set. GITHUB_API_URL points it at GitHub Enterprise.
+var gh *github.Client
+
 func main() {
+	client, err := newClient(os.Getenv("GITHUB_API_URL"), githubToken())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "forge-github-ops: %v\n", err)
+		os.Exit(1)
+	}
+	gh = client
+
 	s := server.NewMCPServer("forge-github-ops", "0.1.0")
 
 	s.AddTool(mcp.Tool{
 		Name:        "github_list_prs",
-		Description: "List pull requests for a GitHub repository.",
+		Description: "List pull requests for a GitHub repository. Returns a JSON array.",
 		InputSchema: mcp.ToolInputSchema{
 			Type: "object",
 			Properties: map[string]any{
@@ -37,7 +59,7 @@ func main() {
 
 	s.AddTool(mcp.Tool{
 		Name:        "github_list_issues",
-		Description: "List issues for a GitHub repository.",
+		Description: "List issues (excluding pull requests) for a GitHub repository. Returns a JSON array.",
 		InputSchema: mcp.ToolInputSchema{
 			Type: "object",
 			Properties: map[string]any{
@@ -59,7 +81,7 @@ func main() {
 
 	s.AddTool(mcp.Tool{
 		Name:        "github_view_pr",
-		Description: "View details of a specific pull request.",
+		Description: "View details of a specific pull request as JSON, including its body, branches, and diff stats.",
 		InputSchema: mcp.ToolInputSchema{
 			Type: "object",
 			Properties: map[string]any{
@@ -76,9 +98,32 @@ func main() {
 		},
 	}, handleViewPR)
 
+	s.AddTool(mcp.Tool{
+		Name:        "github_pr_diff",
+		Description: "Get the unified diff of a pull request against its base branch, as plain text.",
+		InputSchema: mcp.ToolInputSchema{
+			Type: "object",
+			Properties: map[string]any{
+				"repo": map[string]any{
+					"type":        "string",
+					"description": "Repository in owner/repo format (optional)",
+				},
+				"number": map[string]any{
+					"type":        "integer",
+					"description": "PR number",
+				},
+				"max_bytes": map[string]any{
+					"type":        "integer",
+					"description": "Truncate the diff past this many bytes (default: 100000)",
+				},
+			},
+			Required: []string{"number"},
+		},
+	}, handlePRDiff)
+
 	s.AddTool(mcp.Tool{
 		Name:        "github_repo_info",
-		Description: "Get information about a GitHub repository.",
+		Description: "Get information about a GitHub repository as JSON.",
 		InputSchema: mcp.ToolInputSchema{
 			Type: "object",
 			Properties: map[string]any{
@@ -90,6 +135,167 @@ func main() {
 		},
 	}, handleRepoInfo)
 
+	s.AddTool(mcp.Tool{
+		Name:        "github_checks",
+		Description: "Show CI check runs and commit statuses for a branch, commit, or pull request, with an overall success/failure/pending verdict. Returns JSON.",
+		InputSchema: mcp.ToolInputSchema{
+			Type: "object",
+			Properties: map[string]any{
+				"repo": map[string]any{
+					"type":        "string",
+					"description": "Repository in owner/repo format (optional, uses current repo if omitted)",
+				},
+				"ref": map[string]any{
+					"type":        "string",
+					"description": "Branch, tag, or commit SHA (default: the default branch)",
+				},
+				"number": map[string]any{
+					"type":        "integer",
+					"description": "PR number; checks its head commit instead of ref",
+				},
+			},
+		},
+	}, handleChecks)
+
+	s.AddTool(mcp.Tool{
+		Name:        "github_workflow_runs",
+		Description: "List recent GitHub Actions workflow runs, or with run_id show one run's jobs and failed steps, optionally with the tail of each failed job's log. Returns JSON.",
+		InputSchema: mcp.ToolInputSchema{
+			Type: "object",
+			Properties: map[string]any{
+				"repo": map[string]any{
+					"type":        "string",
+					"description": "Repository in owner/repo format (optional, uses current repo if omitted)",
+				},
+				"branch": map[string]any{
+					"type":        "string",
+					"description": "Only runs for this branch",
+				},
+				"status": map[string]any{
+					"type":        "string",
+					"description": "Filter by status or conclusion, e.g. failure, success, in_progress",
+				},
+				"limit": map[string]any{
+					"type":        "integer",
+					"description": "Maximum number of runs to list (default: 10)",
+				},
+				"run_id": map[string]any{
+					"type":        "integer",
+					"description": "Show this run's jobs instead of listing runs",
+				},
+				"logs": map[string]any{
+					"type":        "boolean",
+					"description": "With run_id, include the end of each failed job's log",
+				},
+				"log_lines": map[string]any{
+					"type":        "integer",
+					"description": "Log lines to include per failed job (default: 50)",
+				},
+			},
+		},
+	}, handleWorkflowRuns)
+
+	s.AddTool(mcp.Tool{
+		Name:        "github_create_issue",
+		Description: "Create an issue in a GitHub repository. Returns the new issue's number and URL as JSON.",
+		InputSchema: mcp.ToolInputSchema{
+			Type: "object",
+			Properties: map[string]any{
+				"repo": map[string]any{
+					"type":        "string",
+					"description": "Repository in owner/repo format (optional, uses current repo if omitted)",
+				},
+				"title": map[string]any{
+					"type":        "string",
+					"description": "Issue title",
+				},
+				"body": map[string]any{
+					"type":        "string",
+					"description": "Issue body in Markdown",
+				},
+				"labels": map[string]any{
+					"type":        "array",
+					"items":       map[string]any{"type": "string"},
+					"description": "Labels to apply",
+				},
+				"assignees": map[string]any{
+					"type":        "array",
+					"items":       map[string]any{"type": "string"},
+					"description": "Logins to assign",
+				},
+			},
+			Required: []string{"title"},
+		},
+	}, handleCreateIssue)
+
+	s.AddTool(mcp.Tool{
+		Name:        "github_comment",
+		Description: "Comment on an issue or pull request. Returns the comment URL as JSON.",
+		InputSchema: mcp.ToolInputSchema{
+			Type: "object",
+			Properties: map[string]any{
+				"repo": map[string]any{
+					"type":        "string",
+					"description": "Repository in owner/repo format (optional, uses current repo if omitted)",
+				},
+				"number": map[string]any{
+					"type":        "integer",
+					"description": "Issue or PR number",
+				},
+				"body": map[string]any{
+					"type":        "string",
+					"description": "Comment text in Markdown",
+				},
+			},
+			Required: []string{"number", "body"},
+		},
+	}, handleComment)
+
+	s.AddTool(mcp.Tool{
+		Name:        "github_create_pr",
+		Description: "Open a pull request from a pushed branch. Returns the new PR's number and URL as JSON.",
+		InputSchema: mcp.ToolInputSchema{
+			Type: "object",
+			Properties: map[string]any{
+				"repo": map[string]any{
+					"type":        "string",
+					"description": "Repository in owner/repo format (optional, uses current repo if omitted)",
+				},
+				"title": map[string]any{
+					"type":        "string",
+					"description": "PR title",
+				},
+				"body": map[string]any{
+					"type":        "string",
+					"description": "PR description in Markdown",
+				},
+				"head": map[string]any{
+					"type":        "string",
+					"description": "Branch with the changes (use owner:branch for a fork)",
+				},
+				"base": map[string]any{
+					"type":        "string",
+					"description": "Branch to merge into (default: the repository's default branch)",
+				},
+				"draft": map[string]any{
+					"type":        "boolean",
+					"description": "Open as a draft PR",
+				},
+				"labels": map[string]any{
+					"type":        "array",
+					"items":       map[string]any{"type": "string"},
+					"description": "Labels to apply",
+				},
+				"reviewers": map[string]any{
+					"type":        "array",
+					"items":       map[string]any{"type": "string"},
+					"description": "Logins to request review from (org/team-slug for teams)",
+				},
+			},
+			Required: []string{"title", "head"},
+		},
+	}, handleCreatePR)
+
 	if err := server.ServeStdio(s); err != nil {
 		fmt.Printf("server error: %v\n", err)
 	}
@@ -116,96 +322,823 @@ func errResult(text string) *mcp.CallToolResult {
 	}
 }
 
-func runGH(ctx context.Context, args ...string) (string, error) {
-	cmd := exec.CommandContext(ctx, "gh", args...)
-	out, err := cmd.CombinedOutput()
+func jsonResult(v any) *mcp.CallToolResult {
+	data, err := json.MarshalIndent(v, "", "  ")
 	if err != nil {
-		return "", fmt.Errorf("%s\n%s", err, string(out))
+		return errResult(fmt.Sprintf("error encoding result: %v", err))
+	}
+	return textResult(string(data))
+}
+
+func githubToken() string {
+	if token := os.Getenv("GITHUB_TOKEN"); token != "" {
+		return token
+	}
+	return os.Getenv("GH_TOKEN")
+}
+
+// newClient builds an API client for baseURL (api.github.com if empty).
+func newClient(baseURL, token string) (*github.Client, error) {
+	client := github.NewClient(&http.Client{Timeout: 30 * time.Second})
+	if token != "" {
+		client = client.WithAuthToken(token)
+	}
+	if baseURL != "" {
+		u, err := url.Parse(strings.TrimSuffix(baseURL, "/") + "/")
+		if err != nil {
+			return nil, fmt.Errorf("invalid GITHUB_API_URL: %w", err)
+		}
+		client.BaseURL = u
+	}
+	return client, nil
+}
+
+// apiError formats a GitHub API error, with a hint when auth is the likely cause.
+func apiError(err error) *mcp.CallToolResult {
+	var ghErr *github.ErrorResponse
+	if errors.As(err, &ghErr) && ghErr.Response != nil {
+		msg := fmt.Sprintf("error: GitHub API returned %d: %s", ghErr.Response.StatusCode, ghErr.Message)
+		switch ghErr.Response.StatusCode {
+		case http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound:
+			if githubToken() == "" {
+				msg += " (GITHUB_TOKEN is not set; private repositories and higher rate limits need a token)"
+			}
+		}
+		return errResult(msg)
+	}
+	var rateErr *github.RateLimitError
+	if errors.As(err, &rateErr) {
+		return errResult(fmt.Sprintf("error: GitHub rate limit exceeded, resets at %s", rateErr.Rate.Reset.Format(time.RFC3339)))
+	}
+	return errResult(fmt.Sprintf("error: %v", err))
+}
+
+// githubHost is the web host whose remotes belong to the configured API.
+func githubHost() string {
+	if gh.BaseURL.Host == "api.github.com" {
+		return "github.com"
 	}
-	return strings.TrimSpace(string(out)), nil
+	return gh.BaseURL.Hostname()
 }
 
-func repoFlag(args map[string]any) []string {
+// repoArg returns the owner and name from the "repo" argument, falling back
+// to GITHUB_REPOSITORY and then the origin remote of the working directory.
+func repoArg(ctx context.Context, args map[string]any) (string, string, error) {
 	repo, _ := args["repo"].(string)
-	if repo != "" {
-		return []string{"-R", repo}
+	if repo == "" {
+		repo = os.Getenv("GITHUB_REPOSITORY")
+	}
+	if repo == "" {
+		out, err := exec.CommandContext(ctx, "git", "remote", "get-url", "origin").Output()
+		if err != nil {
+			return "", "", fmt.Errorf("'repo' is required (no git origin remote found)")
+		}
+		host, path, ok := codehost.ParseRemote(string(out))
+		if !ok || host != githubHost() {
+			return "", "", fmt.Errorf("'repo' is required (origin %q is not a GitHub remote)", strings.TrimSpace(string(out)))
+		}
+		repo = path
+	}
+
+	owner, name, ok := strings.Cut(repo, "/")
+	if !ok || owner == "" || name == "" || strings.Contains(name, "/") {
+		return "", "", fmt.Errorf("repo must be in owner/repo format, got %q", repo)
+	}
+	return owner, name, nil
+}
+
+func limitArg(args map[string]any) int {
+	if l, ok := args["limit"].(float64); ok && l > 0 {
+		return int(l)
+	}
+	return 10
+}
+
+type user struct {
+	Login string `json:"login"`
+}
+
+func toUser(u *github.User) *user {
+	if u == nil {
+		return nil
+	}
+	return &user{Login: u.GetLogin()}
+}
+
+func labelNames(labels []*github.Label) []string {
+	names := make([]string, 0, len(labels))
+	for _, l := range labels {
+		names = append(names, l.GetName())
+	}
+	return names
+}
+
+type prSummary struct {
+	Number    int        `json:"number"`
+	Title     string     `json:"title"`
+	State     string     `json:"state"`
+	Draft     bool       `json:"draft"`
+	Author    *user      `json:"author"`
+	Head      string     `json:"head"`
+	Base      string     `json:"base"`
+	Labels    []string   `json:"labels"`
+	URL       string     `json:"url"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+	MergedAt  *time.Time `json:"merged_at,omitempty"`
+}
+
+// prState reports "merged" for merged PRs, which the API lists as closed.
+func prState(pr *github.PullRequest) string {
+	if pr.MergedAt != nil {
+		return "merged"
+	}
+	return pr.GetState()
+}
+
+func toPRSummary(pr *github.PullRequest) prSummary {
+	s := prSummary{
+		Number:    pr.GetNumber(),
+		Title:     pr.GetTitle(),
+		State:     prState(pr),
+		Draft:     pr.GetDraft(),
+		Author:    toUser(pr.User),
+		Head:      pr.GetHead().GetRef(),
+		Base:      pr.GetBase().GetRef(),
+		Labels:    labelNames(pr.Labels),
+		URL:       pr.GetHTMLURL(),
+		CreatedAt: pr.GetCreatedAt().Time,
+		UpdatedAt: pr.GetUpdatedAt().Time,
+	}
+	if pr.MergedAt != nil {
+		s.MergedAt = &pr.MergedAt.Time
 	}
-	return nil
+	return s
 }
 
 func handleListPRs(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 	args := getArgs(request)
+	owner, name, err := repoArg(ctx, args)
+	if err != nil {
+		return errResult(fmt.Sprintf("error: %v", err)), nil
+	}
 	state, _ := args["state"].(string)
 	if state == "" {
 		state = "open"
 	}
-	limit := "10"
-	if l, ok := args["limit"].(float64); ok {
-		limit = fmt.Sprintf("%d", int(l))
-	}
+	limit := limitArg(args)
 
-	ghArgs := []string{"pr", "list", "--state", state, "--limit", limit}
-	ghArgs = append(ghArgs, repoFlag(args)...)
+	// The API has no merged filter; list closed PRs and keep the merged ones.
+	apiState := state
+	switch state {
+	case "merged":
+		apiState = "closed"
+	case "open", "closed", "all":
+	default:
+		return errResult(fmt.Sprintf("error: unknown state %q (use open, closed, merged, or all)", state)), nil
+	}
 
-	out, err := runGH(ctx, ghArgs...)
-	if err != nil {
-		return errResult(fmt.Sprintf("error: %v", err)), nil
+	opts := &github.PullRequestListOptions{
+		State:       apiState,
+		ListOptions: github.ListOptions{PerPage: min(limit, 100)},
 	}
-	if out == "" {
-		return textResult("No pull requests found."), nil
+	prs := []prSummary{}
+	for len(prs) < limit {
+		page, resp, err := gh.PullRequests.List(ctx, owner, name, opts)
+		if err != nil {
+			return apiError(err), nil
+		}
+		for _, pr := range page {
+			if state == "merged" && pr.MergedAt == nil {
+				continue
+			}
+			if len(prs) < limit {
+				prs = append(prs, toPRSummary(pr))
+			}
+		}
+		if resp.NextPage == 0 {
+			break
+		}
+		opts.ListOptions.Page = resp.NextPage
 	}
-	return textResult(out), nil
+	return jsonResult(prs), nil
+}
+
+type issueSummary struct {
+	Number    int       `json:"number"`
+	Title     string    `json:"title"`
+	State     string    `json:"state"`
+	Author    *user     `json:"author"`
+	Labels    []string  `json:"labels"`
+	Assignees []string  `json:"assignees"`
+	Comments  int       `json:"comments"`
+	URL       string    `json:"url"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
 }
 
 func handleListIssues(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 	args := getArgs(request)
+	owner, name, err := repoArg(ctx, args)
+	if err != nil {
+		return errResult(fmt.Sprintf("error: %v", err)), nil
+	}
 	state, _ := args["state"].(string)
 	if state == "" {
 		state = "open"
 	}
-	limit := "10"
-	if l, ok := args["limit"].(float64); ok {
-		limit = fmt.Sprintf("%d", int(l))
+	limit := limitArg(args)
+
+	opts := &github.IssueListByRepoOptions{
+		State:       state,
+		ListOptions: github.ListOptions{PerPage: min(limit, 100)},
+	}
+	issues := []issueSummary{}
+	for len(issues) < limit {
+		page, resp, err := gh.Issues.ListByRepo(ctx, owner, name, opts)
+		if err != nil {
+			return apiError(err), nil
+		}
+		for _, is := range page {
+			// The issues endpoint also returns pull requests.
+			if is.IsPullRequest() || len(issues) >= limit {
+				continue
+			}
+			assignees := make([]string, 0, len(is.Assignees))
+			for _, a := range is.Assignees {
+				assignees = append(assignees, a.GetLogin())
+			}
+			issues = append(issues, issueSummary{
+				Number:    is.GetNumber(),
+				Title:     is.GetTitle(),
+				State:     is.GetState(),
+				Author:    toUser(is.User),
+				Labels:    labelNames(is.Labels),
+				Assignees: assignees,
+				Comments:  is.GetComments(),
+				URL:       is.GetHTMLURL(),
+				CreatedAt: is.GetCreatedAt().Time,
+				UpdatedAt: is.GetUpdatedAt().Time,
+			})
+		}
+		if resp.NextPage == 0 {
+			break
+		}
+		opts.ListOptions.Page = resp.NextPage
 	}
+	return jsonResult(issues), nil
+}
 
-	ghArgs := []string{"issue", "list", "--state", state, "--limit", limit}
-	ghArgs = append(ghArgs, repoFlag(args)...)
+type prDetail struct {
+	prSummary
+	Body         string   `json:"body"`
+	Mergeable    *bool    `json:"mergeable,omitempty"`
+	Commits      int      `json:"commits"`
+	Additions    int      `json:"additions"`
+	Deletions    int      `json:"deletions"`
+	ChangedFiles int      `json:"changed_files"`
+	Reviewers    []string `json:"requested_reviewers"`
+}
 
-	out, err := runGH(ctx, ghArgs...)
+func handleViewPR(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	args := getArgs(request)
+	number, ok := args["number"].(float64)
+	if !ok {
+		return errResult("error: 'number' is required"), nil
+	}
+	owner, name, err := repoArg(ctx, args)
 	if err != nil {
 		return errResult(fmt.Sprintf("error: %v", err)), nil
 	}
-	if out == "" {
-		return textResult("No issues found."), nil
+
+	pr, _, err := gh.PullRequests.Get(ctx, owner, name, int(number))
+	if err != nil {
+		return apiError(err), nil
 	}
-	return textResult(out), nil
+
+	reviewers := make([]string, 0, len(pr.RequestedReviewers))
+	for _, r := range pr.RequestedReviewers {
+		reviewers = append(reviewers, r.GetLogin())
+	}
+	return jsonResult(prDetail{
+		prSummary:    toPRSummary(pr),
+		Body:         pr.GetBody(),
+		Mergeable:    pr.Mergeable,
+		Commits:      pr.GetCommits(),
+		Additions:    pr.GetAdditions(),
+		Deletions:    pr.GetDeletions(),
+		ChangedFiles: pr.GetChangedFiles(),
+		Reviewers:    reviewers,
+	}), nil
 }
 
-func handleViewPR(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+const defaultDiffBytes = 100_000
+
+func handlePRDiff(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 	args := getArgs(request)
 	number, ok := args["number"].(float64)
 	if !ok {
 		return errResult("error: 'number' is required"), nil
 	}
+	owner, name, err := repoArg(ctx, args)
+	if err != nil {
+		return errResult(fmt.Sprintf("error: %v", err)), nil
+	}
+
+	diff, _, err := gh.PullRequests.GetRaw(ctx, owner, name, int(number), github.RawOptions{Type: github.Diff})
+	if err != nil {
+		return apiError(err), nil
+	}
+	maxBytes := defaultDiffBytes
+	if m, ok := args["max_bytes"].(float64); ok && m > 0 {
+		maxBytes = int(m)
+	}
+	if len(diff) > maxBytes {
+		diff = fmt.Sprintf("%s\n[diff truncated at %d of %d bytes]\n", diff[:maxBytes], maxBytes, len(diff))
+	}
+	return textResult(diff), nil
+}
 
-	ghArgs := []string{"pr", "view", fmt.Sprintf("%d", int(number))}
-	ghArgs = append(ghArgs, repoFlag(args)...)
+type repoInfo struct {
+	FullName      string    `json:"full_name"`
+	Description   string    `json:"description"`
+	Private       bool      `json:"private"`
+	Fork          bool      `json:"fork"`
+	Archived      bool      `json:"archived"`
+	DefaultBranch string    `json:"default_branch"`
+	Language      string    `json:"language"`
+	Topics        []string  `json:"topics"`
+	Stars         int       `json:"stars"`
+	Forks         int       `json:"forks"`
+	OpenIssues    int       `json:"open_issues"`
+	License       string    `json:"license,omitempty"`
+	URL           string    `json:"url"`
+	PushedAt      time.Time `json:"pushed_at"`
+}
 
-	out, err := runGH(ctx, ghArgs...)
+func handleRepoInfo(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	args := getArgs(request)
+	owner, name, err := repoArg(ctx, args)
 	if err != nil {
 		return errResult(fmt.Sprintf("error: %v", err)), nil
 	}
-	return textResult(out), nil
+
+	repo, _, err := gh.Repositories.Get(ctx, owner, name)
+	if err != nil {
+		return apiError(err), nil
+	}
+
+	topics := repo.Topics
+	if topics == nil {
+		topics = []string{}
+	}
+	return jsonResult(repoInfo{
+		FullName:      repo.GetFullName(),
+		Description:   repo.GetDescription(),
+		Private:       repo.GetPrivate(),
+		Fork:          repo.GetFork(),
+		Archived:      repo.GetArchived(),
+		DefaultBranch: repo.GetDefaultBranch(),
+		Language:      repo.GetLanguage(),
+		Topics:        topics,
+		Stars:         repo.GetStargazersCount(),
+		Forks:         repo.GetForksCount(),
+		OpenIssues:    repo.GetOpenIssuesCount(),
+		License:       repo.GetLicense().GetSPDXID(),
+		URL:           repo.GetHTMLURL(),
+		PushedAt:      repo.GetPushedAt().Time,
+	}), nil
 }
 
-func handleRepoInfo(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+type checkRun struct {
+	Name       string     `json:"name"`
+	Status     string     `json:"status"`
+	Conclusion string     `json:"conclusion,omitempty"`
+	Summary    string     `json:"summary,omitempty"`
+	URL        string     `json:"url"`
+	StartedAt  *time.Time `json:"started_at,omitempty"`
+	FinishedAt *time.Time `json:"completed_at,omitempty"`
+}
+
+type commitStatus struct {
+	Context     string `json:"context"`
+	State       string `json:"state"`
+	Description string `json:"description,omitempty"`
+	URL         string `json:"url,omitempty"`
+}
+
+type checksResult struct {
+	Ref       string         `json:"ref"`
+	SHA       string         `json:"sha"`
+	Overall   string         `json:"overall"`
+	CheckRuns []checkRun     `json:"check_runs"`
+	Statuses  []commitStatus `json:"statuses"`
+}
+
+// failedConclusions are check run conclusions that make a ref red.
+var failedConclusions = map[string]bool{
+	"failure": true, "timed_out": true, "cancelled": true, "action_required": true, "startup_failure": true,
+}
+
+func handleChecks(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 	args := getArgs(request)
-	ghArgs := []string{"repo", "view"}
-	ghArgs = append(ghArgs, repoFlag(args)...)
+	owner, name, err := repoArg(ctx, args)
+	if err != nil {
+		return errResult(fmt.Sprintf("error: %v", err)), nil
+	}
+
+	ref, _ := args["ref"].(string)
+	if number, ok := args["number"].(float64); ok {
+		pr, _, err := gh.PullRequests.Get(ctx, owner, name, int(number))
+		if err != nil {
+			return apiError(err), nil
+		}
+		ref = pr.GetHead().GetSHA()
+	}
+	if ref == "" {
+		repo, _, err := gh.Repositories.Get(ctx, owner, name)
+		if err != nil {
+			return apiError(err), nil
+		}
+		ref = repo.GetDefaultBranch()
+	}
+
+	out := checksResult{Ref: ref, CheckRuns: []checkRun{}, Statuses: []commitStatus{}}
+	failed, pending := false, false
+
+	runs, _, err := gh.Checks.ListCheckRunsForRef(ctx, owner, name, ref, &github.ListCheckRunsOptions{
+		ListOptions: github.ListOptions{PerPage: 100},
+	})
+	if err != nil {
+		return apiError(err), nil
+	}
+	for _, cr := range runs.CheckRuns {
+		out.SHA = cr.GetHeadSHA()
+		run := checkRun{
+			Name:       cr.GetName(),
+			Status:     cr.GetStatus(),
+			Conclusion: cr.GetConclusion(),
+			Summary:    cr.GetOutput().GetTitle(),
+			URL:        cr.GetHTMLURL(),
+		}
+		if cr.StartedAt != nil {
+			run.StartedAt = &cr.StartedAt.Time
+		}
+		if cr.CompletedAt != nil {
+			run.FinishedAt = &cr.CompletedAt.Time
+		}
+		out.CheckRuns = append(out.CheckRuns, run)
+
+		switch {
+		case run.Status != "completed":
+			pending = true
+		case failedConclusions[run.Conclusion]:
+			failed = true
+		}
+	}
+
+	combined, _, err := gh.Repositories.GetCombinedStatus(ctx, owner, name, ref, &github.ListOptions{PerPage: 100})
+	if err != nil {
+		return apiError(err), nil
+	}
+	if sha := combined.GetSHA(); sha != "" {
+		out.SHA = sha
+	}
+	for _, st := range combined.Statuses {
+		out.Statuses = append(out.Statuses, commitStatus{
+			Context:     st.GetContext(),
+			State:       st.GetState(),
+			Description: st.GetDescription(),
+			URL:         st.GetTargetURL(),
+		})
+		switch st.GetState() {
+		case "failure", "error":
+			failed = true
+		case "pending":
+			pending = true
+		}
+	}
+
+	switch {
+	case failed:
+		out.Overall = "failure"
+	case pending:
+		out.Overall = "pending"
+	case len(out.CheckRuns) == 0 && len(out.Statuses) == 0:
+		out.Overall = "none"
+	default:
+		out.Overall = "success"
+	}
+	return jsonResult(out), nil
+}
+
+type workflowRun struct {
+	ID         int64     `json:"id"`
+	Workflow   string    `json:"workflow"`
+	RunNumber  int       `json:"run_number"`
+	Event      string    `json:"event"`
+	Branch     string    `json:"branch"`
+	SHA        string    `json:"sha"`
+	Status     string    `json:"status"`
+	Conclusion string    `json:"conclusion,omitempty"`
+	URL        string    `json:"url"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+func toWorkflowRun(r *github.WorkflowRun) workflowRun {
+	return workflowRun{
+		ID:         r.GetID(),
+		Workflow:   r.GetName(),
+		RunNumber:  r.GetRunNumber(),
+		Event:      r.GetEvent(),
+		Branch:     r.GetHeadBranch(),
+		SHA:        r.GetHeadSHA(),
+		Status:     r.GetStatus(),
+		Conclusion: r.GetConclusion(),
+		URL:        r.GetHTMLURL(),
+		CreatedAt:  r.GetCreatedAt().Time,
+		UpdatedAt:  r.GetUpdatedAt().Time,
+	}
+}
+
+type workflowJob struct {
+	ID          int64    `json:"id"`
+	Name        string   `json:"name"`
+	Status      string   `json:"status"`
+	Conclusion  string   `json:"conclusion,omitempty"`
+	FailedSteps []string `json:"failed_steps,omitempty"`
+	URL         string   `json:"url"`
+	Log         string   `json:"log,omitempty"`
+	LogError    string   `json:"log_error,omitempty"`
+}
+
+type workflowRunDetail struct {
+	workflowRun
+	Jobs []workflowJob `json:"jobs"`
+}
+
+const defaultLogLines = 50
 
-	out, err := runGH(ctx, ghArgs...)
+func handleWorkflowRuns(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	args := getArgs(request)
+	owner, name, err := repoArg(ctx, args)
 	if err != nil {
 		return errResult(fmt.Sprintf("error: %v", err)), nil
 	}
-	return textResult(out), nil
+
+	if runID, ok := args["run_id"].(float64); ok {
+		return workflowRunDetails(ctx, owner, name, int64(runID), args), nil
+	}
+
+	branch, _ := args["branch"].(string)
+	status, _ := args["status"].(string)
+	limit := limitArg(args)
+	runs, _, err := gh.Actions.ListRepositoryWorkflowRuns(ctx, owner, name, &github.ListWorkflowRunsOptions{
+		Branch:      branch,
+		Status:      status,
+		ListOptions: github.ListOptions{PerPage: min(limit, 100)},
+	})
+	if err != nil {
+		return apiError(err), nil
+	}
+
+	out := []workflowRun{}
+	for _, r := range runs.WorkflowRuns {
+		if len(out) == limit {
+			break
+		}
+		out = append(out, toWorkflowRun(r))
+	}
+	return jsonResult(out), nil
+}
+
+func workflowRunDetails(ctx context.Context, owner, name string, runID int64, args map[string]any) *mcp.CallToolResult {
+	run, _, err := gh.Actions.GetWorkflowRunByID(ctx, owner, name, runID)
+	if err != nil {
+		return apiError(err)
+	}
+	jobs, _, err := gh.Actions.ListWorkflowJobs(ctx, owner, name, runID, &github.ListWorkflowJobsOptions{
+		ListOptions: github.ListOptions{PerPage: 100},
+	})
+	if err != nil {
+		return apiError(err)
+	}
+
+	withLogs, _ := args["logs"].(bool)
+	logLines := defaultLogLines
+	if n, ok := args["log_lines"].(float64); ok && n > 0 {
+		logLines = int(n)
+	}
+
+	out := workflowRunDetail{workflowRun: toWorkflowRun(run), Jobs: []workflowJob{}}
+	for _, j := range jobs.Jobs {
+		job := workflowJob{
+			ID:         j.GetID(),
+			Name:       j.GetName(),
+			Status:     j.GetStatus(),
+			Conclusion: j.GetConclusion(),
+			URL:        j.GetHTMLURL(),
+		}
+		for _, step := range j.Steps {
+			if failedConclusions[step.GetConclusion()] {
+				job.FailedSteps = append(job.FailedSteps, step.GetName())
+			}
+		}
+		if withLogs && failedConclusions[job.Conclusion] {
+			if job.Log, err = jobLogTail(ctx, owner, name, job.ID, logLines); err != nil {
+				job.LogError = err.Error()
+			}
+		}
+		out.Jobs = append(out.Jobs, job)
+	}
+	return jsonResult(out)
+}
+
+// logTimestamp matches the timestamp Actions prefixes to every log line.
+var logTimestamp = regexp.MustCompile(`^\d{4}-\d{2}-\d{2}T[\d:.]+Z `)
+
+// logHTTPClient downloads job logs from the pre-signed URL the API redirects
+// to, which must not receive the API token.
+var logHTTPClient = &http.Client{Timeout: 60 * time.Second}
+
+// jobLogTail returns the last n lines of a job's log without timestamps.
+func jobLogTail(ctx context.Context, owner, name string, jobID int64, n int) (string, error) {
+	logURL, _, err := gh.Actions.GetWorkflowJobLogs(ctx, owner, name, jobID, 1)
+	if err != nil {
+		return "", fmt.Errorf("fetching log URL: %w", err)
+	}
+	logURL = gh.BaseURL.ResolveReference(logURL)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, logURL.String(), nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := logHTTPClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("downloading log: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("downloading log: HTTP %d", resp.StatusCode)
+	}
+
+	// Keep a ring of the last n lines so large logs aren't held in memory.
+	tail := make([]string, 0, n)
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), 1<<20)
+	for scanner.Scan() {
+		line := logTimestamp.ReplaceAllString(strings.TrimRight(scanner.Text(), "\r"), "")
+		if len(tail) == n {
+			tail = append(tail[1:], line)
+		} else {
+			tail = append(tail, line)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", fmt.Errorf("reading log: %w", err)
+	}
+	return strings.Join(tail, "\n"), nil
+}
+
+// stringsArg reads a list argument, accepting a JSON array or a
+// comma-separated string.
+func stringsArg(args map[string]any, key string) []string {
+	var out []string
+	switch v := args[key].(type) {
+	case []any:
+		for _, item := range v {
+			if s, ok := item.(string); ok && strings.TrimSpace(s) != "" {
+				out = append(out, strings.TrimSpace(s))
+			}
+		}
+	case string:
+		for _, s := range strings.Split(v, ",") {
+			if s = strings.TrimSpace(s); s != "" {
+				out = append(out, s)
+			}
+		}
+	}
+	return out
+}
+
+type created struct {
+	Number int    `json:"number,omitempty"`
+	ID     int64  `json:"id,omitempty"`
+	URL    string `json:"url"`
+}
+
+func handleCreateIssue(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	args := getArgs(request)
+	title, _ := args["title"].(string)
+	if title == "" {
+		return errResult("error: 'title' is required"), nil
+	}
+	owner, name, err := repoArg(ctx, args)
+	if err != nil {
+		return errResult(fmt.Sprintf("error: %v", err)), nil
+	}
+	body, _ := args["body"].(string)
+
+	req := &github.IssueRequest{Title: &title, Body: &body}
+	if labels := stringsArg(args, "labels"); len(labels) > 0 {
+		req.Labels = &labels
+	}
+	if assignees := stringsArg(args, "assignees"); len(assignees) > 0 {
+		req.Assignees = &assignees
+	}
+
+	issue, _, err := gh.Issues.Create(ctx, owner, name, req)
+	if err != nil {
+		return apiError(err), nil
+	}
+	return jsonResult(created{Number: issue.GetNumber(), URL: issue.GetHTMLURL()}), nil
+}
+
+func handleComment(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	args := getArgs(request)
+	number, ok := args["number"].(float64)
+	if !ok {
+		return errResult("error: 'number' is required"), nil
+	}
+	body, _ := args["body"].(string)
+	if strings.TrimSpace(body) == "" {
+		return errResult("error: 'body' is required"), nil
+	}
+	owner, name, err := repoArg(ctx, args)
+	if err != nil {
+		return errResult(fmt.Sprintf("error: %v", err)), nil
+	}
+
+	// PR conversation comments go through the issues API too.
+	comment, _, err := gh.Issues.CreateComment(ctx, owner, name, int(number), &github.IssueComment{Body: &body})
+	if err != nil {
+		return apiError(err), nil
+	}
+	return jsonResult(created{ID: comment.GetID(), URL: comment.GetHTMLURL()}), nil
+}
+
+type createdPR struct {
+	created
+	Warnings []string `json:"warnings,omitempty"`
+}
+
+func handleCreatePR(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	args := getArgs(request)
+	title, _ := args["title"].(string)
+	if title == "" {
+		return errResult("error: 'title' is required"), nil
+	}
+	head, _ := args["head"].(string)
+	if head == "" {
+		return errResult("error: 'head' is required"), nil
+	}
+	owner, name, err := repoArg(ctx, args)
+	if err != nil {
+		return errResult(fmt.Sprintf("error: %v", err)), nil
+	}
+	body, _ := args["body"].(string)
+	draft, _ := args["draft"].(bool)
+
+	base, _ := args["base"].(string)
+	if base == "" {
+		repo, _, err := gh.Repositories.Get(ctx, owner, name)
+		if err != nil {
+			return apiError(err), nil
+		}
+		base = repo.GetDefaultBranch()
+	}
+
+	pr, _, err := gh.PullRequests.Create(ctx, owner, name, &github.NewPullRequest{
+		Title: &title,
+		Body:  &body,
+		Head:  &head,
+		Base:  &base,
+		Draft: &draft,
+	})
+	if err != nil {
+		return apiError(err), nil
+	}
+
+	// The PR exists at this point, so report label and reviewer failures
+	// alongside it rather than failing the whole call.
+	out := createdPR{created: created{Number: pr.GetNumber(), URL: pr.GetHTMLURL()}}
+	if labels := stringsArg(args, "labels"); len(labels) > 0 {
+		if _, _, err := gh.Issues.AddLabelsToIssue(ctx, owner, name, pr.GetNumber(), labels); err != nil {
+			out.Warnings = append(out.Warnings, fmt.Sprintf("adding labels: %v", err))
+		}
+	}
+	if reviewers := stringsArg(args, "reviewers"); len(reviewers) > 0 {
+		var req github.ReviewersRequest
+		for _, r := range reviewers {
+			if _, team, ok := strings.Cut(r, "/"); ok {
+				req.TeamReviewers = append(req.TeamReviewers, team)
+			} else {
+				req.Reviewers = append(req.Reviewers, r)
+			}
+		}
+		if _, _, err := gh.PullRequests.RequestReviewers(ctx, owner, name, pr.GetNumber(), req); err != nil {
+			out.Warnings = append(out.Warnings, fmt.Sprintf("requesting reviewers: %v", err))
+		}
+	}
+	return jsonResult(out), nil
 }
@@ -0,0 +1,401 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+
+	"github.com/michaelbrown/forge/internal/codehost"
+)
+
+var (
+	host     codehost.Client
+	hostName string // hostname used to match the git origin remote
+	hostKind string // "GitLab" or "Gitea", for messages
+)
+
+// configure selects Gitea when GITEA_URL is set and GitLab otherwise.
+func configure() error {
+	if giteaURL := os.Getenv("GITEA_URL"); giteaURL != "" {
+		c, err := codehost.NewGitea(giteaURL, os.Getenv("GITEA_TOKEN"))
+		if err != nil {
+			return fmt.Errorf("GITEA_URL: %w", err)
+		}
+		host, hostKind = c, "Gitea"
+		return setHostName(giteaURL)
+	}
+
+	gitlabURL := os.Getenv("GITLAB_URL")
+	if gitlabURL == "" {
+		gitlabURL = "https://gitlab.com"
+	}
+	c, err := codehost.NewGitLab(gitlabURL, os.Getenv("GITLAB_TOKEN"))
+	if err != nil {
+		return fmt.Errorf("GITLAB_URL: %w", err)
+	}
+	host, hostKind = c, "GitLab"
+	return setHostName(gitlabURL)
+}
+
+func setHostName(baseURL string) error {
+	u, err := url.Parse(baseURL)
+	if err != nil {
+		return err
+	}
+	hostName = u.Hostname()
+	return nil
+}
+
+func main() {
+	if err := configure(); err != nil {
+		fmt.Fprintf(os.Stderr, "forge-gitlab-ops: %v\n", err)
+		os.Exit(1)
+	}
+
+	s := server.NewMCPServer("forge-gitlab-ops", "0.1.0")
+
+	repoProp := map[string]any{
+		"type":        "string",
+		"description": "Project path, e.g. group/project (optional, uses current repo if omitted)",
+	}
+
+	s.AddTool(mcp.Tool{
+		Name:        "gitlab_list_issues",
+		Description: "List issues in a GitLab or Gitea project. Returns a JSON array.",
+		InputSchema: mcp.ToolInputSchema{
+			Type: "object",
+			Properties: map[string]any{
+				"repo": repoProp,
+				"state": map[string]any{
+					"type":        "string",
+					"description": "Filter by state: open, closed, all (default: open)",
+				},
+				"limit": map[string]any{
+					"type":        "integer",
+					"description": "Maximum number of issues to return (default: 10)",
+				},
+			},
+		},
+	}, handleListIssues)
+
+	s.AddTool(mcp.Tool{
+		Name:        "gitlab_view_issue",
+		Description: "View an issue, including its description, as JSON.",
+		InputSchema: mcp.ToolInputSchema{
+			Type: "object",
+			Properties: map[string]any{
+				"repo": repoProp,
+				"number": map[string]any{
+					"type":        "integer",
+					"description": "Issue number",
+				},
+			},
+			Required: []string{"number"},
+		},
+	}, handleViewIssue)
+
+	s.AddTool(mcp.Tool{
+		Name:        "gitlab_list_mrs",
+		Description: "List merge requests (pull requests on Gitea). Returns a JSON array.",
+		InputSchema: mcp.ToolInputSchema{
+			Type: "object",
+			Properties: map[string]any{
+				"repo": repoProp,
+				"state": map[string]any{
+					"type":        "string",
+					"description": "Filter by state: open, closed, merged, all (default: open)",
+				},
+				"limit": map[string]any{
+					"type":        "integer",
+					"description": "Maximum number of merge requests to return (default: 10)",
+				},
+			},
+		},
+	}, handleListMRs)
+
+	s.AddTool(mcp.Tool{
+		Name:        "gitlab_view_mr",
+		Description: "View a merge request, including its description and branches, as JSON.",
+		InputSchema: mcp.ToolInputSchema{
+			Type: "object",
+			Properties: map[string]any{
+				"repo": repoProp,
+				"number": map[string]any{
+					"type":        "integer",
+					"description": "Merge request number",
+				},
+			},
+			Required: []string{"number"},
+		},
+	}, handleViewMR)
+
+	s.AddTool(mcp.Tool{
+		Name:        "gitlab_mr_diff",
+		Description: "Show a merge request's changes as a unified diff.",
+		InputSchema: mcp.ToolInputSchema{
+			Type: "object",
+			Properties: map[string]any{
+				"repo": repoProp,
+				"number": map[string]any{
+					"type":        "integer",
+					"description": "Merge request number",
+				},
+				"max_bytes": map[string]any{
+					"type":        "integer",
+					"description": "Truncate the diff after this many bytes (default: 65536)",
+				},
+			},
+			Required: []string{"number"},
+		},
+	}, handleMRDiff)
+
+	s.AddTool(mcp.Tool{
+		Name:        "gitlab_comment",
+		Description: "Comment on an issue or merge request. Returns the comment ID as JSON.",
+		InputSchema: mcp.ToolInputSchema{
+			Type: "object",
+			Properties: map[string]any{
+				"repo": repoProp,
+				"number": map[string]any{
+					"type":        "integer",
+					"description": "Issue or merge request number",
+				},
+				"target": map[string]any{
+					"type":        "string",
+					"description": "What number refers to: issue or mr (default: issue)",
+				},
+				"body": map[string]any{
+					"type":        "string",
+					"description": "Comment text in Markdown",
+				},
+			},
+			Required: []string{"number", "body"},
+		},
+	}, handleComment)
+
+	if err := server.ServeStdio(s); err != nil {
+		fmt.Printf("server error: %v\n", err)
+	}
+}
+
+func getArgs(request mcp.CallToolRequest) map[string]any {
+	args, _ := request.Params.Arguments.(map[string]any)
+	if args == nil {
+		args = make(map[string]any)
+	}
+	return args
+}
+
+func textResult(text string) *mcp.CallToolResult {
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{mcp.TextContent{Type: "text", Text: text}},
+	}
+}
+
+func errResult(text string) *mcp.CallToolResult {
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{mcp.TextContent{Type: "text", Text: text}},
+		IsError: true,
+	}
+}
+
+func jsonResult(v any) *mcp.CallToolResult {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return errResult(fmt.Sprintf("error encoding result: %v", err))
+	}
+	return textResult(string(data))
+}
+
+// apiError formats a host API error, with a hint when auth is the likely cause.
+func apiError(err error) *mcp.CallToolResult {
+	var apiErr *codehost.APIError
+	if errors.As(err, &apiErr) {
+		msg := fmt.Sprintf("error: %s %v", hostKind, apiErr)
+		if apiErr.Status == http.StatusUnauthorized || apiErr.Status == http.StatusNotFound {
+			msg += fmt.Sprintf(" (check %s_TOKEN has access to the project)", strings.ToUpper(hostKind))
+		}
+		return errResult(msg)
+	}
+	return errResult(fmt.Sprintf("error: %v", err))
+}
+
+// repoArg returns the project path from the "repo" argument, falling back to
+// GITLAB_PROJECT and then the origin remote if it points at the configured host.
+func repoArg(ctx context.Context, args map[string]any) (string, error) {
+	if repo, _ := args["repo"].(string); repo != "" {
+		return strings.Trim(repo, "/"), nil
+	}
+	if repo := os.Getenv("GITLAB_PROJECT"); repo != "" {
+		return repo, nil
+	}
+
+	out, err := exec.CommandContext(ctx, "git", "remote", "get-url", "origin").Output()
+	if err != nil {
+		return "", fmt.Errorf("'repo' is required (no git origin remote found)")
+	}
+	remoteHost, path, ok := codehost.ParseRemote(string(out))
+	if !ok || remoteHost != hostName {
+		return "", fmt.Errorf("'repo' is required (origin %q is not on %s)", strings.TrimSpace(string(out)), hostName)
+	}
+	return path, nil
+}
+
+func numberArg(args map[string]any) (int, bool) {
+	n, ok := args["number"].(float64)
+	return int(n), ok
+}
+
+// listArgs reads the state and limit arguments shared by the list tools.
+func listArgs(args map[string]any) (string, int) {
+	state, _ := args["state"].(string)
+	if state == "" {
+		state = codehost.StateOpen
+	}
+	limit := 10
+	if l, ok := args["limit"].(float64); ok && l > 0 {
+		limit = int(l)
+	}
+	return state, limit
+}
+
+func handleListIssues(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	args := getArgs(request)
+	repo, err := repoArg(ctx, args)
+	if err != nil {
+		return errResult(fmt.Sprintf("error: %v", err)), nil
+	}
+	state, limit := listArgs(args)
+	if !codehost.ValidState(state) || state == codehost.StateMerged {
+		return errResult(fmt.Sprintf("error: unknown state %q (use open, closed, or all)", state)), nil
+	}
+
+	issues, err := host.ListIssues(ctx, repo, state, limit)
+	if err != nil {
+		return apiError(err), nil
+	}
+	return jsonResult(issues), nil
+}
+
+func handleViewIssue(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	args := getArgs(request)
+	number, ok := numberArg(args)
+	if !ok {
+		return errResult("error: 'number' is required"), nil
+	}
+	repo, err := repoArg(ctx, args)
+	if err != nil {
+		return errResult(fmt.Sprintf("error: %v", err)), nil
+	}
+
+	issue, err := host.GetIssue(ctx, repo, number)
+	if err != nil {
+		return apiError(err), nil
+	}
+	return jsonResult(issue), nil
+}
+
+func handleListMRs(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	args := getArgs(request)
+	repo, err := repoArg(ctx, args)
+	if err != nil {
+		return errResult(fmt.Sprintf("error: %v", err)), nil
+	}
+	state, limit := listArgs(args)
+	if !codehost.ValidState(state) {
+		return errResult(fmt.Sprintf("error: unknown state %q (use open, closed, merged, or all)", state)), nil
+	}
+
+	mrs, err := host.ListMergeRequests(ctx, repo, state, limit)
+	if err != nil {
+		return apiError(err), nil
+	}
+	return jsonResult(mrs), nil
+}
+
+func handleViewMR(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	args := getArgs(request)
+	number, ok := numberArg(args)
+	if !ok {
+		return errResult("error: 'number' is required"), nil
+	}
+	repo, err := repoArg(ctx, args)
+	if err != nil {
+		return errResult(fmt.Sprintf("error: %v", err)), nil
+	}
+
+	mr, err := host.GetMergeRequest(ctx, repo, number)
+	if err != nil {
+		return apiError(err), nil
+	}
+	return jsonResult(mr), nil
+}
+
+const defaultDiffBytes = 64 << 10
+
+func handleMRDiff(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	args := getArgs(request)
+	number, ok := numberArg(args)
+	if !ok {
+		return errResult("error: 'number' is required"), nil
+	}
+	repo, err := repoArg(ctx, args)
+	if err != nil {
+		return errResult(fmt.Sprintf("error: %v", err)), nil
+	}
+	maxBytes := defaultDiffBytes
+	if n, ok := args["max_bytes"].(float64); ok && n > 0 {
+		maxBytes = int(n)
+	}
+
+	diff, err := host.MergeRequestDiff(ctx, repo, number)
+	if err != nil {
+		return apiError(err), nil
+	}
+	if diff == "" {
+		return textResult("No changes."), nil
+	}
+	if len(diff) > maxBytes {
+		diff = fmt.Sprintf("%s\n[diff truncated: showing %d of %d bytes]", diff[:maxBytes], maxBytes, len(diff))
+	}
+	return textResult(diff), nil
+}
+
+func handleComment(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	args := getArgs(request)
+	number, ok := numberArg(args)
+	if !ok {
+		return errResult("error: 'number' is required"), nil
+	}
+	body, _ := args["body"].(string)
+	if strings.TrimSpace(body) == "" {
+		return errResult("error: 'body' is required"), nil
+	}
+	repo, err := repoArg(ctx, args)
+	if err != nil {
+		return errResult(fmt.Sprintf("error: %v", err)), nil
+	}
+
+	var comment *codehost.Comment
+	switch target, _ := args["target"].(string); target {
+	case "", "issue":
+		comment, err = host.CommentOnIssue(ctx, repo, number, body)
+	case "mr", "merge_request", "pr":
+		comment, err = host.CommentOnMergeRequest(ctx, repo, number, body)
+	default:
+		return errResult(fmt.Sprintf("error: unknown target %q (use issue or mr)", target)), nil
+	}
+	if err != nil {
+		return apiError(err), nil
+	}
+	return jsonResult(comment), nil
+}
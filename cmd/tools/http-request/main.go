@@ -0,0 +1,410 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+const (
+	defaultMaxLength = 20_000
+	maxMaxLength     = 200_000
+
+	defaultTimeout = 30 * time.Second
+	maxTimeout     = 5 * time.Minute
+
+	// maxResponseBytes caps how much of a response is read, and
+	// maxRequestBytes how much may be sent.
+	maxResponseBytes = 10 << 20
+	maxRequestBytes  = 5 << 20
+)
+
+var methods = []string{"GET", "HEAD", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"}
+
+// shownHeaders are the response headers included without include_headers.
+var shownHeaders = []string{"Content-Type", "Location", "Retry-After", "Link", "Etag"}
+
+func main() {
+	profiles, profilesErr = loadProfiles(os.Getenv("FORGE_HTTP_PROFILES"))
+	if profilesErr != nil {
+		fmt.Fprintf(os.Stderr, "forge-http-request: %v\n", profilesErr)
+	}
+
+	s := server.NewMCPServer("forge-http-request", "0.1.0")
+
+	s.AddTool(mcp.Tool{
+		Name:        "http_request",
+		Description: "Send an HTTP request and return the status, key response headers, and body, with JSON pretty-printed. To call an API that needs credentials, pass a 'profile' from http_profiles: its base URL and auth headers are added for you, and 'url' can be a path relative to the base URL.",
+		InputSchema: mcp.ToolInputSchema{
+			Type: "object",
+			Properties: map[string]any{
+				"url": map[string]any{
+					"type":        "string",
+					"description": "Absolute http(s) URL, or with a profile, a path such as /repos/owner/repo",
+				},
+				"method": map[string]any{
+					"type":        "string",
+					"description": fmt.Sprintf("HTTP method: %s (default GET)", strings.Join(methods, ", ")),
+				},
+				"profile": map[string]any{
+					"type":        "string",
+					"description": "Auth profile to use (optional; see http_profiles)",
+				},
+				"headers": map[string]any{
+					"type":                 "object",
+					"additionalProperties": map[string]any{"type": "string"},
+					"description":          "Extra request headers (optional). Headers the profile sets can't be changed.",
+				},
+				"query": map[string]any{
+					"type":                 "object",
+					"additionalProperties": map[string]any{"type": "string"},
+					"description":          "Query parameters to add to the URL (optional)",
+				},
+				"json": map[string]any{
+					"description": "Value to send as a JSON body, with Content-Type application/json (optional)",
+				},
+				"body": map[string]any{
+					"type":        "string",
+					"description": "Raw request body (optional; use 'json' for JSON). Set Content-Type in 'headers'.",
+				},
+				"include_headers": map[string]any{
+					"type":        "boolean",
+					"description": "List every response header, not just the main ones (default false)",
+				},
+				"max_length": map[string]any{
+					"type":        "integer",
+					"description": fmt.Sprintf("Maximum characters of body to return (default %d, max %d)", defaultMaxLength, maxMaxLength),
+				},
+				"timeout_seconds": map[string]any{
+					"type":        "integer",
+					"description": fmt.Sprintf("Give up after this many seconds (default %d, max %d)", int(defaultTimeout.Seconds()), int(maxTimeout.Seconds())),
+				},
+			},
+			Required: []string{"url"},
+		},
+	}, handleRequest)
+
+	s.AddTool(mcp.Tool{
+		Name:        "http_profiles",
+		Description: "List the auth profiles http_request can use, with their base URLs and the headers they set. Header values are not shown.",
+		InputSchema: mcp.ToolInputSchema{
+			Type:       "object",
+			Properties: map[string]any{},
+		},
+	}, handleProfiles)
+
+	if err := server.ServeStdio(s); err != nil {
+		fmt.Printf("server error: %v\n", err)
+	}
+}
+
+func getArgs(request mcp.CallToolRequest) map[string]any {
+	args, _ := request.Params.Arguments.(map[string]any)
+	return args
+}
+
+func textResult(text string) *mcp.CallToolResult {
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{mcp.TextContent{Type: "text", Text: text}},
+	}
+}
+
+func errResult(text string) *mcp.CallToolResult {
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{mcp.TextContent{Type: "text", Text: text}},
+		IsError: true,
+	}
+}
+
+func handleProfiles(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	if profilesErr != nil {
+		return errResult(fmt.Sprintf("error: %v", profilesErr)), nil
+	}
+	if len(profiles) == 0 {
+		return textResult("No profiles are configured (set FORGE_HTTP_PROFILES)."), nil
+	}
+	var b strings.Builder
+	for _, name := range profileNames() {
+		p := profiles[name]
+		fmt.Fprintf(&b, "%s: %s\n", name, p.base)
+		if p.Description != "" {
+			fmt.Fprintf(&b, "  %s\n", p.Description)
+		}
+		if len(p.Headers) > 0 {
+			fmt.Fprintf(&b, "  headers: %s\n", strings.Join(slices.Sorted(maps.Keys(p.Headers)), ", "))
+		}
+		if len(p.Query) > 0 {
+			fmt.Fprintf(&b, "  query: %s\n", strings.Join(slices.Sorted(maps.Keys(p.Query)), ", "))
+		}
+	}
+	return textResult(b.String()), nil
+}
+
+func handleRequest(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	args := getArgs(request)
+	if args == nil {
+		return errResult("error: invalid arguments"), nil
+	}
+	maxLength := defaultMaxLength
+	if v, ok := args["max_length"].(float64); ok && v > 0 {
+		maxLength = min(int(v), maxMaxLength)
+	}
+	timeout := defaultTimeout
+	if v, ok := args["timeout_seconds"].(float64); ok && v > 0 {
+		timeout = min(time.Duration(v)*time.Second, maxTimeout)
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	req, prof, err := newRequest(ctx, args)
+	if err != nil {
+		return errResult(fmt.Sprintf("error: %v", redact(err.Error()))), nil
+	}
+
+	client := &http.Client{
+		CheckRedirect: func(next *http.Request, via []*http.Request) error {
+			if len(via) >= 10 {
+				return errors.New("stopped after 10 redirects")
+			}
+			// The profile's headers would follow the redirect; leave it to
+			// the caller instead.
+			if prof != nil && !prof.covers(next.URL) {
+				return http.ErrUseLastResponse
+			}
+			return nil
+		},
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		if errors.Is(err, context.DeadlineExceeded) {
+			return errResult(fmt.Sprintf("error: %s %s timed out after %s", req.Method, req.URL.Redacted(), timeout)), nil
+		}
+		return errResult(fmt.Sprintf("error: %v", redact(err.Error()))), nil
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseBytes))
+	if err != nil {
+		return errResult(fmt.Sprintf("error reading body: %v", err)), nil
+	}
+
+	includeAll, _ := args["include_headers"].(bool)
+	text := redact(formatResponse(resp, body, includeAll, maxLength))
+	if resp.StatusCode >= 400 {
+		return errResult(text), nil
+	}
+	return textResult(text), nil
+}
+
+// newRequest builds the request from the arguments, applying the profile
+// if one is named.
+func newRequest(ctx context.Context, args map[string]any) (*http.Request, *profile, error) {
+	rawURL, _ := args["url"].(string)
+	if rawURL == "" {
+		return nil, nil, fmt.Errorf("'url' is required")
+	}
+	method, _ := args["method"].(string)
+	method = strings.ToUpper(method)
+	if method == "" {
+		method = http.MethodGet
+	}
+	if !slices.Contains(methods, method) {
+		return nil, nil, fmt.Errorf("unsupported method %q (want one of %s)", method, strings.Join(methods, ", "))
+	}
+
+	var prof *profile
+	var u *url.URL
+	var err error
+	if name, _ := args["profile"].(string); name != "" {
+		if profilesErr != nil {
+			return nil, nil, profilesErr
+		}
+		if prof = profiles[name]; prof == nil {
+			if len(profiles) == 0 {
+				return nil, nil, fmt.Errorf("unknown profile %q (none are configured)", name)
+			}
+			return nil, nil, fmt.Errorf("unknown profile %q (configured: %s)", name, strings.Join(profileNames(), ", "))
+		}
+		if u, err = prof.resolve(rawURL); err != nil {
+			return nil, nil, fmt.Errorf("profile %s: %w", name, err)
+		}
+	} else {
+		if u, err = url.Parse(rawURL); err != nil {
+			return nil, nil, err
+		}
+		if u.Scheme != "http" && u.Scheme != "https" {
+			return nil, nil, fmt.Errorf("url must be an absolute http(s) URL, or a path with a profile")
+		}
+	}
+
+	q := u.Query()
+	if extra, ok := args["query"].(map[string]any); ok {
+		for name, v := range extra {
+			q.Set(name, fmt.Sprint(v))
+		}
+	}
+
+	var body []byte
+	contentType := ""
+	rawBody, hasBody := args["body"].(string)
+	jsonBody, hasJSON := args["json"]
+	switch {
+	case hasBody && hasJSON:
+		return nil, nil, fmt.Errorf("give either 'body' or 'json', not both")
+	case hasJSON:
+		if body, err = json.Marshal(jsonBody); err != nil {
+			return nil, nil, fmt.Errorf("encoding json: %w", err)
+		}
+		contentType = "application/json"
+	case hasBody:
+		body = []byte(rawBody)
+	}
+	if len(body) > maxRequestBytes {
+		return nil, nil, fmt.Errorf("request body is larger than %d MB", maxRequestBytes>>20)
+	}
+	if body != nil && (method == http.MethodGet || method == http.MethodHead) {
+		return nil, nil, fmt.Errorf("a body cannot be sent with %s", method)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, nil, err
+	}
+	if body == nil {
+		req.Body, req.ContentLength = nil, 0
+	}
+	req.Header.Set("User-Agent", "Forge/0.1")
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	if headers, ok := args["headers"].(map[string]any); ok {
+		for name, v := range headers {
+			value, ok := v.(string)
+			if !ok {
+				return nil, nil, fmt.Errorf("header %q must be a string", name)
+			}
+			if prof != nil && hasKeyFold(prof.Headers, name) {
+				return nil, nil, fmt.Errorf("header %q is set by the profile", name)
+			}
+			req.Header.Set(name, value)
+		}
+	}
+
+	if prof != nil {
+		for name, v := range prof.Headers {
+			value, err := expand(v)
+			if err != nil {
+				return nil, nil, fmt.Errorf("profile header %s: %w", name, err)
+			}
+			req.Header.Set(name, value)
+		}
+		for name, v := range prof.Query {
+			value, err := expand(v)
+			if err != nil {
+				return nil, nil, fmt.Errorf("profile query %s: %w", name, err)
+			}
+			q.Set(name, value)
+		}
+	}
+	req.URL.RawQuery = q.Encode()
+	return req, prof, nil
+}
+
+func hasKeyFold(m map[string]string, key string) bool {
+	for k := range m {
+		if strings.EqualFold(k, key) {
+			return true
+		}
+	}
+	return false
+}
+
+// formatResponse renders the status line, headers, and body.
+func formatResponse(resp *http.Response, body []byte, includeAll bool, maxLength int) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "HTTP %s\n", resp.Status)
+	names := shownHeaders
+	if includeAll {
+		names = slices.Sorted(maps.Keys(resp.Header))
+	}
+	for _, name := range names {
+		for _, v := range resp.Header.Values(name) {
+			fmt.Fprintf(&b, "%s: %s\n", name, v)
+		}
+	}
+	if resp.Request.Response != nil {
+		// A redirect was followed.
+		fmt.Fprintf(&b, "URL: %s\n", resp.Request.URL)
+	}
+	if resp.Request.Method == http.MethodHead || len(body) == 0 {
+		return b.String()
+	}
+	b.WriteString("\n")
+
+	contentType := resp.Header.Get("Content-Type")
+	if contentType == "" {
+		contentType = http.DetectContentType(body)
+	}
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	switch {
+	case isJSON(mediaType) || json.Valid(body):
+		var pretty bytes.Buffer
+		if json.Indent(&pretty, body, "", "  ") == nil {
+			body = pretty.Bytes()
+		}
+	case !isText(mediaType) || !utf8.Valid(body):
+		fmt.Fprintf(&b, "(%d bytes of %s not shown)\n", len(body), mediaType)
+		return b.String()
+	}
+
+	text := string(body)
+	if n := utf8.RuneCountInString(text); n > maxLength {
+		text = truncate(text, maxLength) + fmt.Sprintf("\n... (truncated at %d of %d characters)", maxLength, n)
+	}
+	b.WriteString(strings.TrimRight(text, "\n") + "\n")
+	if len(body) == maxResponseBytes {
+		fmt.Fprintf(&b, "... (response cut off after %d MB)\n", maxResponseBytes>>20)
+	}
+	return b.String()
+}
+
+func isJSON(mediaType string) bool {
+	return mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")
+}
+
+func isText(mediaType string) bool {
+	if strings.HasPrefix(mediaType, "text/") || isJSON(mediaType) {
+		return true
+	}
+	switch mediaType {
+	case "application/xml", "application/javascript", "application/x-yaml",
+		"application/yaml", "application/x-ndjson", "application/x-www-form-urlencoded":
+		return true
+	}
+	return strings.HasSuffix(mediaType, "+xml")
+}
+
+// truncate returns the first n characters of s.
+func truncate(s string, n int) string {
+	for i := range s {
+		if n == 0 {
+			return s[:i]
+		}
+		n--
+	}
+	return s
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// profile holds the base URL and credentials for one API, from
+// FORGE_HTTP_PROFILES. Header and query values may reference environment
+// variables as ${NAME}; they are filled in by this server and never shown
+// to the model.
+type profile struct {
+	BaseURL     string            `yaml:"base_url"`
+	Headers     map[string]string `yaml:"headers"`
+	Query       map[string]string `yaml:"query"`
+	Description string            `yaml:"description"`
+
+	base *url.URL
+}
+
+// profiles holds the configured profiles. If FORGE_HTTP_PROFILES can't be
+// parsed, profilesErr is set and http_request reports it.
+var (
+	profiles    map[string]*profile
+	profilesErr error
+)
+
+var envRef = regexp.MustCompile(`\$\{[A-Za-z_][A-Za-z0-9_]*\}`)
+
+// loadProfiles parses profiles given as YAML (or JSON) keyed by name.
+func loadProfiles(config string) (map[string]*profile, error) {
+	profs := make(map[string]*profile)
+	if strings.TrimSpace(config) == "" {
+		return profs, nil
+	}
+	if err := yaml.Unmarshal([]byte(config), &profs); err != nil {
+		return nil, fmt.Errorf("FORGE_HTTP_PROFILES: %w", err)
+	}
+	for name, p := range profs {
+		if p == nil || p.BaseURL == "" {
+			return nil, fmt.Errorf("FORGE_HTTP_PROFILES: %s: base_url is required", name)
+		}
+		u, err := url.Parse(p.BaseURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return nil, fmt.Errorf("FORGE_HTTP_PROFILES: %s: base_url must be an absolute http(s) URL", name)
+		}
+		u.Path = strings.TrimSuffix(u.Path, "/")
+		p.base = u
+	}
+	return profs, nil
+}
+
+func profileNames() []string {
+	names := make([]string, 0, len(profiles))
+	for name := range profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// resolve turns a URL argument into an absolute URL. With a profile, it may
+// be a path relative to the base URL, and must stay under it so the
+// profile's credentials only go where they are meant to.
+func (p *profile) resolve(raw string) (*url.URL, error) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return nil, err
+	}
+	// JoinPath resolves dot segments, as servers do, so a path can't climb
+	// out of the base, and keeps escapes such as %2F intact.
+	if u.IsAbs() {
+		u = u.JoinPath()
+	} else {
+		joined := p.base.JoinPath(u.EscapedPath())
+		joined.RawQuery, joined.Fragment = u.RawQuery, u.Fragment
+		u = joined
+	}
+	if !p.covers(u) {
+		return nil, fmt.Errorf("%s is outside the profile's base_url %s", u.Redacted(), p.base)
+	}
+	return u, nil
+}
+
+// covers reports whether u is at or under the base URL.
+func (p *profile) covers(u *url.URL) bool {
+	if u.Scheme != p.base.Scheme || !strings.EqualFold(u.Host, p.base.Host) {
+		return false
+	}
+	path := u.EscapedPath()
+	base := p.base.EscapedPath()
+	return base == "" || path == base || strings.HasPrefix(path, base+"/")
+}
+
+// expand fills in ${NAME} references from the environment.
+func expand(s string) (string, error) {
+	var err error
+	out := envRef.ReplaceAllStringFunc(s, func(ref string) string {
+		name := ref[2 : len(ref)-1]
+		value, ok := os.LookupEnv(name)
+		if !ok && err == nil {
+			err = fmt.Errorf("${%s} is not set", name)
+		}
+		return value
+	})
+	return out, err
+}
+
+// secrets returns every value the profiles fill in from the environment,
+// longest first, so they can be scrubbed from responses that echo them.
+func secrets() []string {
+	var out []string
+	add := func(s string) {
+		for _, ref := range envRef.FindAllString(s, -1) {
+			// Short values would mangle unrelated text.
+			if v := os.Getenv(ref[2 : len(ref)-1]); len(v) >= 6 && !slices.Contains(out, v) {
+				out = append(out, v)
+			}
+		}
+	}
+	for _, p := range profiles {
+		for _, v := range p.Headers {
+			add(v)
+		}
+		for _, v := range p.Query {
+			add(v)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return len(out[i]) > len(out[j]) })
+	return out
+}
+
+// redact replaces profile secrets in s.
+func redact(s string) string {
+	for _, secret := range secrets() {
+		s = strings.ReplaceAll(s, secret, "[redacted]")
+		if escaped := url.QueryEscape(secret); escaped != secret {
+			s = strings.ReplaceAll(s, escaped, "[redacted]")
+		}
+	}
+	return s
+}
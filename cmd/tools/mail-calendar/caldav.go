@@ -0,0 +1,288 @@
+package main
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// davClient reads events from a CalDAV server (RFC 4791). FORGE_CALDAV_URL
+// may point at a calendar, a calendar home, or the user's principal; the
+// calendars under it are discovered on first use.
+type davClient struct {
+	base     *url.URL
+	user     string
+	password string
+	token    string
+	http     *http.Client
+
+	mu        sync.Mutex
+	calendars []calendar
+}
+
+// calendar is one calendar collection.
+type calendar struct {
+	Name string
+	URL  *url.URL
+}
+
+// multistatus is a WebDAV 207 Multi-Status response body.
+type multistatus struct {
+	Responses []struct {
+		Href     string `xml:"DAV: href"`
+		Propstat []struct {
+			Prop   davProp `xml:"DAV: prop"`
+			Status string  `xml:"DAV: status"`
+		} `xml:"DAV: propstat"`
+	} `xml:"DAV: response"`
+}
+
+type davProp struct {
+	DisplayName  string `xml:"DAV: displayname"`
+	ResourceType struct {
+		Calendar *struct{} `xml:"urn:ietf:params:xml:ns:caldav calendar"`
+	} `xml:"DAV: resourcetype"`
+	Principal struct {
+		Href string `xml:"DAV: href"`
+	} `xml:"DAV: current-user-principal"`
+	HomeSet struct {
+		Href string `xml:"DAV: href"`
+	} `xml:"urn:ietf:params:xml:ns:caldav calendar-home-set"`
+	Components   []component `xml:"urn:ietf:params:xml:ns:caldav supported-calendar-component-set>comp"`
+	CalendarData string      `xml:"urn:ietf:params:xml:ns:caldav calendar-data"`
+}
+
+type component struct {
+	Name string `xml:"name,attr"`
+}
+
+// newDAVClient returns a client for FORGE_CALDAV_URL, or nil if it isn't set.
+func newDAVClient() (*davClient, error) {
+	raw := os.Getenv("FORGE_CALDAV_URL")
+	if raw == "" {
+		return nil, nil
+	}
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("FORGE_CALDAV_URL must be an absolute http(s) URL")
+	}
+	if !strings.HasSuffix(u.Path, "/") {
+		u.Path += "/"
+	}
+	return &davClient{
+		base:     u,
+		user:     os.Getenv("FORGE_CALDAV_USER"),
+		password: os.Getenv("FORGE_CALDAV_PASSWORD"),
+		token:    os.Getenv("FORGE_CALDAV_TOKEN"),
+		http:     &http.Client{Timeout: time.Minute},
+	}, nil
+}
+
+// do sends a PROPFIND or REPORT and parses the multistatus response.
+func (c *davClient) do(ctx context.Context, method string, u *url.URL, depth, body string) (*multistatus, error) {
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), strings.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", `application/xml; charset="utf-8"`)
+	req.Header.Set("Depth", depth)
+	switch {
+	case c.token != "":
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	case c.user != "":
+		req.SetBasicAuth(c.user, c.password)
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 20<<20))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusMultiStatus {
+		return nil, fmt.Errorf("%s %s: %s", method, u.Redacted(), resp.Status)
+	}
+	var ms multistatus
+	if err := xml.Unmarshal(data, &ms); err != nil {
+		return nil, fmt.Errorf("%s %s: %w", method, u.Redacted(), err)
+	}
+	return &ms, nil
+}
+
+// resolve turns an href from a response into an absolute URL.
+func (c *davClient) resolve(href string) (*url.URL, error) {
+	ref, err := url.Parse(strings.TrimSpace(href))
+	if err != nil {
+		return nil, err
+	}
+	return c.base.ResolveReference(ref), nil
+}
+
+const propfindDiscover = `<?xml version="1.0" encoding="utf-8"?>
+<d:propfind xmlns:d="DAV:" xmlns:c="urn:ietf:params:xml:ns:caldav">
+  <d:prop><d:resourcetype/><d:displayname/><d:current-user-principal/><c:calendar-home-set/></d:prop>
+</d:propfind>`
+
+const propfindCalendars = `<?xml version="1.0" encoding="utf-8"?>
+<d:propfind xmlns:d="DAV:" xmlns:c="urn:ietf:params:xml:ns:caldav">
+  <d:prop><d:resourcetype/><d:displayname/><c:supported-calendar-component-set/></d:prop>
+</d:propfind>`
+
+// props merges the properties of the successful propstats in a response.
+func props(ms *multistatus, i int) davProp {
+	var p davProp
+	for _, ps := range ms.Responses[i].Propstat {
+		if ps.Status != "" && !strings.Contains(ps.Status, " 200 ") {
+			continue
+		}
+		if ps.Prop.DisplayName != "" {
+			p.DisplayName = ps.Prop.DisplayName
+		}
+		if ps.Prop.ResourceType.Calendar != nil {
+			p.ResourceType = ps.Prop.ResourceType
+		}
+		if ps.Prop.Principal.Href != "" {
+			p.Principal = ps.Prop.Principal
+		}
+		if ps.Prop.HomeSet.Href != "" {
+			p.HomeSet = ps.Prop.HomeSet
+		}
+		p.Components = append(p.Components, ps.Prop.Components...)
+		p.CalendarData += ps.Prop.CalendarData
+	}
+	return p
+}
+
+// listCalendars returns the event calendars, discovering them the first
+// time.
+func (c *davClient) listCalendars(ctx context.Context) ([]calendar, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.calendars != nil {
+		return c.calendars, nil
+	}
+
+	ms, err := c.do(ctx, "PROPFIND", c.base, "0", propfindDiscover)
+	if err != nil {
+		return nil, err
+	}
+	home := c.base
+	if len(ms.Responses) > 0 {
+		p := props(ms, 0)
+		switch {
+		case p.ResourceType.Calendar != nil:
+			c.calendars = []calendar{{Name: calendarName(p.DisplayName, c.base), URL: c.base}}
+			return c.calendars, nil
+		case p.HomeSet.Href != "":
+			if home, err = c.resolve(p.HomeSet.Href); err != nil {
+				return nil, err
+			}
+		case p.Principal.Href != "":
+			principal, err := c.resolve(p.Principal.Href)
+			if err != nil {
+				return nil, err
+			}
+			pms, err := c.do(ctx, "PROPFIND", principal, "0", propfindDiscover)
+			if err != nil {
+				return nil, err
+			}
+			if len(pms.Responses) > 0 {
+				if href := props(pms, 0).HomeSet.Href; href != "" {
+					if home, err = c.resolve(href); err != nil {
+						return nil, err
+					}
+				}
+			}
+		}
+	}
+
+	ms, err = c.do(ctx, "PROPFIND", home, "1", propfindCalendars)
+	if err != nil {
+		return nil, err
+	}
+	cals := []calendar{}
+	for i, r := range ms.Responses {
+		p := props(ms, i)
+		if p.ResourceType.Calendar == nil {
+			continue
+		}
+		// Calendars that declare their components but not VEVENT hold
+		// only tasks or journals.
+		if len(p.Components) > 0 && !slices.ContainsFunc(p.Components, func(comp component) bool {
+			return strings.EqualFold(comp.Name, "VEVENT")
+		}) {
+			continue
+		}
+		u, err := c.resolve(r.Href)
+		if err != nil {
+			return nil, err
+		}
+		cals = append(cals, calendar{Name: calendarName(p.DisplayName, u), URL: u})
+	}
+	if len(cals) == 0 {
+		return nil, fmt.Errorf("no calendars found at %s", c.base.Redacted())
+	}
+	sort.Slice(cals, func(i, j int) bool { return cals[i].Name < cals[j].Name })
+	c.calendars = cals
+	return cals, nil
+}
+
+// calendarName falls back to the last path segment when a calendar has no
+// display name.
+func calendarName(display string, u *url.URL) string {
+	if display != "" {
+		return display
+	}
+	segs := strings.Split(strings.Trim(u.Path, "/"), "/")
+	name, _ := url.PathUnescape(segs[len(segs)-1])
+	return name
+}
+
+// events returns cal's events that overlap [start, end), with recurring
+// events expanded into occurrences by the server.
+func (c *davClient) events(ctx context.Context, cal calendar, start, end time.Time, loc *time.Location) ([]event, error) {
+	const stamp = "20060102T150405Z"
+	s, e := start.UTC().Format(stamp), end.UTC().Format(stamp)
+	body := fmt.Sprintf(`<?xml version="1.0" encoding="utf-8"?>
+<c:calendar-query xmlns:d="DAV:" xmlns:c="urn:ietf:params:xml:ns:caldav">
+  <d:prop>
+    <c:calendar-data><c:expand start="%s" end="%s"/></c:calendar-data>
+  </d:prop>
+  <c:filter>
+    <c:comp-filter name="VCALENDAR">
+      <c:comp-filter name="VEVENT"><c:time-range start="%s" end="%s"/></c:comp-filter>
+    </c:comp-filter>
+  </c:filter>
+</c:calendar-query>`, s, e, s, e)
+	ms, err := c.do(ctx, "REPORT", cal.URL, "1", body)
+	if err != nil {
+		return nil, err
+	}
+	var events []event
+	for i := range ms.Responses {
+		evs, err := parseICal(props(ms, i).CalendarData, loc)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", ms.Responses[i].Href, err)
+		}
+		for _, ev := range evs {
+			// Servers that ignore expand return the master event; keep
+			// only what overlaps the range.
+			if ev.Start.Before(end) && (ev.End.After(start) || ev.Start.Equal(start)) {
+				ev.Calendar = cal.Name
+				events = append(events, ev)
+			}
+		}
+	}
+	return events, nil
+}
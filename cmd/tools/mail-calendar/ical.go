@@ -0,0 +1,190 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// event is a VEVENT. Recurring events arrive already expanded by the
+// server, one event per occurrence.
+type event struct {
+	Calendar    string
+	UID         string
+	Summary     string
+	Location    string
+	Description string
+	Status      string
+	Start, End  time.Time
+	AllDay      bool
+	// Transparent events don't block time (TRANSP:TRANSPARENT).
+	Transparent bool
+}
+
+// busy reports whether the event takes up time on the calendar.
+func (e event) busy() bool {
+	return !e.Transparent && e.Status != "CANCELLED"
+}
+
+// property is one content line: NAME;PARAM=VALUE:value.
+type property struct {
+	Name   string
+	Params map[string]string
+	Value  string
+}
+
+// parseICal returns the events in an iCalendar object. Times without a
+// zone are read in loc.
+func parseICal(data string, loc *time.Location) ([]event, error) {
+	// Unfold continuation lines, which start with a space or tab.
+	data = strings.ReplaceAll(data, "\r\n", "\n")
+	data = strings.NewReplacer("\n ", "", "\n\t", "").Replace(data)
+
+	var events []event
+	var cur *event
+	var hasEnd bool
+	var duration time.Duration
+	depth := 0 // nesting inside the current VEVENT, such as a VALARM
+	for _, line := range strings.Split(data, "\n") {
+		if line == "" {
+			continue
+		}
+		p, err := parseProperty(line)
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case p.Name == "BEGIN" && cur == nil && p.Value == "VEVENT":
+			cur, hasEnd, duration = &event{}, false, 0
+			continue
+		case p.Name == "BEGIN" && cur != nil:
+			depth++
+			continue
+		case p.Name == "END" && cur != nil && depth > 0:
+			depth--
+			continue
+		case p.Name == "END" && cur != nil && p.Value == "VEVENT":
+			if !hasEnd {
+				switch {
+				case duration != 0:
+					cur.End = cur.Start.Add(duration)
+				case cur.AllDay:
+					cur.End = cur.Start.AddDate(0, 0, 1)
+				default:
+					cur.End = cur.Start
+				}
+			}
+			events = append(events, *cur)
+			cur = nil
+			continue
+		}
+		if cur == nil || depth > 0 {
+			continue
+		}
+
+		switch p.Name {
+		case "UID":
+			cur.UID = p.Value
+		case "SUMMARY":
+			cur.Summary = unescapeText(p.Value)
+		case "LOCATION":
+			cur.Location = unescapeText(p.Value)
+		case "DESCRIPTION":
+			cur.Description = unescapeText(p.Value)
+		case "STATUS":
+			cur.Status = strings.ToUpper(p.Value)
+		case "TRANSP":
+			cur.Transparent = strings.EqualFold(p.Value, "TRANSPARENT")
+		case "DTSTART":
+			if cur.Start, cur.AllDay, err = parseICalTime(p, loc); err != nil {
+				return nil, err
+			}
+		case "DTEND":
+			if cur.End, _, err = parseICalTime(p, loc); err != nil {
+				return nil, err
+			}
+			hasEnd = true
+		case "DURATION":
+			if duration, err = parseDuration(p.Value); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return events, nil
+}
+
+func parseProperty(line string) (property, error) {
+	// The value starts at the first colon outside a quoted parameter.
+	quoted := false
+	colon := -1
+	for i, r := range line {
+		if r == '"' {
+			quoted = !quoted
+		} else if r == ':' && !quoted {
+			colon = i
+			break
+		}
+	}
+	if colon < 0 {
+		return property{}, fmt.Errorf("invalid iCalendar line %q", line)
+	}
+	p := property{Value: line[colon+1:], Params: map[string]string{}}
+	parts := strings.Split(line[:colon], ";")
+	p.Name = strings.ToUpper(parts[0])
+	for _, param := range parts[1:] {
+		if k, v, ok := strings.Cut(param, "="); ok {
+			p.Params[strings.ToUpper(k)] = strings.Trim(v, `"`)
+		}
+	}
+	return p, nil
+}
+
+func unescapeText(s string) string {
+	return strings.NewReplacer(`\n`, "\n", `\N`, "\n", `\,`, ",", `\;`, ";", `\\`, `\`).Replace(s)
+}
+
+// parseICalTime reads a DATE or DATE-TIME value, reporting whether it is a
+// date. UTC times end in Z; others use their TZID, or loc if they have
+// none or it is unknown. Times are returned in loc.
+func parseICalTime(p property, loc *time.Location) (time.Time, bool, error) {
+	v := p.Value
+	if p.Params["VALUE"] == "DATE" || len(v) == 8 {
+		t, err := time.ParseInLocation("20060102", v, loc)
+		return t, true, err
+	}
+	if strings.HasSuffix(v, "Z") {
+		t, err := time.Parse("20060102T150405Z", v)
+		return t.In(loc), false, err
+	}
+	zone := loc
+	if tzid := p.Params["TZID"]; tzid != "" {
+		if l, err := time.LoadLocation(tzid); err == nil {
+			zone = l
+		}
+	}
+	t, err := time.ParseInLocation("20060102T150405", v, zone)
+	return t.In(loc), false, err
+}
+
+var icalDuration = regexp.MustCompile(`^([+-])?P(?:(\d+)W)?(?:(\d+)D)?(?:T(?:(\d+)H)?(?:(\d+)M)?(?:(\d+)S)?)?$`)
+
+// parseDuration reads an RFC 5545 duration such as P1D or PT1H30M.
+func parseDuration(s string) (time.Duration, error) {
+	m := icalDuration.FindStringSubmatch(s)
+	if m == nil {
+		return 0, fmt.Errorf("invalid duration %q", s)
+	}
+	var d time.Duration
+	for i, unit := range []time.Duration{7 * 24 * time.Hour, 24 * time.Hour, time.Hour, time.Minute, time.Second} {
+		if m[i+2] != "" {
+			n, _ := strconv.Atoi(m[i+2])
+			d += time.Duration(n) * unit
+		}
+	}
+	if m[1] == "-" {
+		d = -d
+	}
+	return d, nil
+}
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/emersion/go-imap"
+	"github.com/emersion/go-imap/client"
+	"github.com/emersion/go-message/mail"
+
+	"github.com/michaelbrown/forge/internal/webpage"
+)
+
+// imapConfig is the mailbox to read, from FORGE_IMAP_URL, FORGE_IMAP_USER
+// and FORGE_IMAP_PASSWORD. imaps:// connects over TLS; imap:// upgrades
+// with STARTTLS when the server offers it.
+type imapConfig struct {
+	url      *url.URL
+	user     string
+	password string
+}
+
+func loadIMAPConfig() (*imapConfig, error) {
+	raw := os.Getenv("FORGE_IMAP_URL")
+	if raw == "" {
+		return nil, nil
+	}
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "imap" && u.Scheme != "imaps") || u.Host == "" {
+		return nil, fmt.Errorf("FORGE_IMAP_URL must look like imaps://host[:port]")
+	}
+	if u.Port() == "" {
+		port := "993"
+		if u.Scheme == "imap" {
+			port = "143"
+		}
+		u.Host = net.JoinHostPort(u.Hostname(), port)
+	}
+	return &imapConfig{
+		url:      u,
+		user:     os.Getenv("FORGE_IMAP_USER"),
+		password: os.Getenv("FORGE_IMAP_PASSWORD"),
+	}, nil
+}
+
+// dial connects and logs in. Each tool call uses its own connection.
+func (c *imapConfig) dial() (*client.Client, error) {
+	dialer := &net.Dialer{Timeout: 30 * time.Second}
+	tlsConfig := &tls.Config{ServerName: c.url.Hostname()}
+	var cl *client.Client
+	var err error
+	if c.url.Scheme == "imaps" {
+		cl, err = client.DialWithDialerTLS(dialer, c.url.Host, tlsConfig)
+	} else {
+		cl, err = client.DialWithDialer(dialer, c.url.Host)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("connecting to %s: %w", c.url.Host, err)
+	}
+	cl.Timeout = time.Minute
+	if !cl.IsTLS() {
+		if ok, _ := cl.SupportStartTLS(); ok {
+			if err := cl.StartTLS(tlsConfig); err != nil {
+				cl.Logout()
+				return nil, fmt.Errorf("STARTTLS: %w", err)
+			}
+		}
+	}
+	if err := cl.Login(c.user, c.password); err != nil {
+		cl.Logout()
+		return nil, fmt.Errorf("logging in as %s: %w", c.user, err)
+	}
+	return cl, nil
+}
+
+// summary is one line of a message list.
+type summary struct {
+	UID     uint32
+	Date    time.Time
+	From    string
+	Subject string
+	Unseen  bool
+}
+
+func (s summary) String() string {
+	line := fmt.Sprintf("[uid %d] %s  %s  %s", s.UID, s.Date.Format("2006-01-02 15:04"), s.From, s.Subject)
+	if s.Unseen {
+		line += "  (unread)"
+	}
+	return line
+}
+
+func newSummary(msg *imap.Message, loc *time.Location) summary {
+	s := summary{UID: msg.Uid, Date: msg.InternalDate.In(loc), Unseen: true}
+	if env := msg.Envelope; env != nil {
+		s.Subject = env.Subject
+		if !env.Date.IsZero() {
+			s.Date = env.Date.In(loc)
+		}
+		if len(env.From) > 0 {
+			s.From = formatIMAPAddress(env.From[0])
+		}
+	}
+	if s.Subject == "" {
+		s.Subject = "(no subject)"
+	}
+	for _, f := range msg.Flags {
+		if f == imap.SeenFlag {
+			s.Unseen = false
+		}
+	}
+	return s
+}
+
+func formatIMAPAddress(a *imap.Address) string {
+	if a.PersonalName != "" {
+		return fmt.Sprintf("%s <%s>", a.PersonalName, a.Address())
+	}
+	return a.Address()
+}
+
+func formatAddresses(h mail.Header, key string) string {
+	addrs, err := h.AddressList(key)
+	if err != nil || len(addrs) == 0 {
+		return h.Get(key)
+	}
+	parts := make([]string, len(addrs))
+	for i, a := range addrs {
+		parts[i] = a.String()
+		if a.Name == "" {
+			parts[i] = a.Address
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+// message is a fetched message, reduced to what a reader needs.
+type message struct {
+	Header      mail.Header
+	Text        string
+	Attachments []string
+}
+
+// readMessage parses a raw message, preferring its text/plain body and
+// falling back to HTML converted to Markdown.
+func readMessage(r io.Reader) (*message, error) {
+	mr, err := mail.CreateReader(r)
+	if err != nil {
+		return nil, err
+	}
+	msg := &message{Header: mr.Header}
+	var html string
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		switch h := part.Header.(type) {
+		case *mail.InlineHeader:
+			ct, _, _ := h.ContentType()
+			body, err := io.ReadAll(io.LimitReader(part.Body, 5<<20))
+			if err != nil {
+				return nil, err
+			}
+			switch {
+			case ct == "text/plain" && msg.Text == "":
+				msg.Text = string(body)
+			case ct == "text/html" && html == "":
+				html = string(body)
+			case !strings.HasPrefix(ct, "text/"):
+				msg.Attachments = append(msg.Attachments, fmt.Sprintf("inline %s (%s)", ct, formatSize(len(body))))
+			}
+		case *mail.AttachmentHeader:
+			name, _ := h.Filename()
+			ct, _, _ := h.ContentType()
+			n, _ := io.Copy(io.Discard, part.Body)
+			if name == "" {
+				name = "unnamed"
+			}
+			msg.Attachments = append(msg.Attachments, fmt.Sprintf("%s (%s, %s)", name, ct, formatSize(int(n))))
+		}
+	}
+	if msg.Text == "" && html != "" {
+		page, err := webpage.Extract(strings.NewReader(html), webpage.Options{Full: true})
+		if err != nil {
+			return nil, err
+		}
+		msg.Text = page.Markdown
+	}
+	return msg, nil
+}
+
+func formatSize(n int) string {
+	switch {
+	case n >= 1<<20:
+		return fmt.Sprintf("%.1f MB", float64(n)/(1<<20))
+	case n >= 1<<10:
+		return fmt.Sprintf("%d KB", n>>10)
+	}
+	return fmt.Sprintf("%d bytes", n)
+}
@@ -0,0 +1,761 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/emersion/go-imap"
+	"github.com/emersion/go-imap/client"
+	"github.com/emersion/go-message/charset"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+const (
+	defaultLimit = 20
+	maxLimit     = 100
+
+	defaultMaxLength = 20_000
+	maxMaxLength     = 100_000
+
+	// maxEvents caps the events listed by calendar_events.
+	maxEvents = 200
+)
+
+// Each source is configured from the environment. A missing or invalid
+// configuration is reported when one of its tools is called.
+var (
+	dav    *davClient
+	davErr error
+
+	mailbox    *imapConfig
+	mailboxErr error
+
+	// loc is the timezone for input and output times, from FORGE_TIMEZONE.
+	loc = time.Local
+)
+
+func main() {
+	if tz := os.Getenv("FORGE_TIMEZONE"); tz != "" {
+		l, err := time.LoadLocation(tz)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "forge-mail-calendar: FORGE_TIMEZONE: %v\n", err)
+		} else {
+			loc = l
+		}
+	}
+	dav, davErr = newDAVClient()
+	mailbox, mailboxErr = loadIMAPConfig()
+	imap.CharsetReader = charset.Reader
+
+	s := server.NewMCPServer("forge-mail-calendar", "0.1.0")
+
+	s.AddTool(mcp.Tool{
+		Name:        "calendar_events",
+		Description: "List calendar events in a time range, with recurring events expanded. Times are shown in the configured timezone.",
+		InputSchema: mcp.ToolInputSchema{
+			Type: "object",
+			Properties: map[string]any{
+				"start": map[string]any{
+					"type":        "string",
+					"description": timeDescription("Start of the range (default now)"),
+				},
+				"end": map[string]any{
+					"type":        "string",
+					"description": timeDescription("End of the range (default 7 days after start)"),
+				},
+				"calendar": map[string]any{
+					"type":        "string",
+					"description": "Only list events from the calendar with this name (optional; default all calendars)",
+				},
+				"query": map[string]any{
+					"type":        "string",
+					"description": "Only list events whose title, location, or description contains this text (optional)",
+				},
+			},
+		},
+	}, handleEvents)
+
+	s.AddTool(mcp.Tool{
+		Name:        "calendar_freebusy",
+		Description: "Show busy times and free slots in a time range, across all calendars. Use it to find a time for a meeting.",
+		InputSchema: mcp.ToolInputSchema{
+			Type: "object",
+			Properties: map[string]any{
+				"start": map[string]any{
+					"type":        "string",
+					"description": timeDescription("Start of the range (default now)"),
+				},
+				"end": map[string]any{
+					"type":        "string",
+					"description": timeDescription("End of the range (default 7 days after start)"),
+				},
+				"duration_minutes": map[string]any{
+					"type":        "integer",
+					"description": "Only list free slots at least this long (default 30)",
+				},
+				"working_hours": map[string]any{
+					"type":        "string",
+					"description": "Only look for free slots between these times of day, such as 09:00-17:00 (optional)",
+				},
+				"weekdays_only": map[string]any{
+					"type":        "boolean",
+					"description": "Skip Saturdays and Sundays when looking for free slots (default false)",
+				},
+			},
+		},
+	}, handleFreeBusy)
+
+	s.AddTool(mcp.Tool{
+		Name:        "mail_mailboxes",
+		Description: "List the mail folders with their message and unread counts.",
+		InputSchema: mcp.ToolInputSchema{
+			Type:       "object",
+			Properties: map[string]any{},
+		},
+	}, handleMailboxes)
+
+	s.AddTool(mcp.Tool{
+		Name:        "mail_search",
+		Description: "Search a mail folder and list matching messages, newest first, with their uid, date, sender, and subject. Read one with mail_read. Messages are not marked as read.",
+		InputSchema: mcp.ToolInputSchema{
+			Type: "object",
+			Properties: map[string]any{
+				"query": map[string]any{
+					"type":        "string",
+					"description": "Text to find anywhere in the message, headers included (optional)",
+				},
+				"from": map[string]any{
+					"type":        "string",
+					"description": "Text to find in the sender (optional)",
+				},
+				"to": map[string]any{
+					"type":        "string",
+					"description": "Text to find in the recipients (optional)",
+				},
+				"subject": map[string]any{
+					"type":        "string",
+					"description": "Text to find in the subject (optional)",
+				},
+				"since": map[string]any{
+					"type":        "string",
+					"description": "Only messages received on or after this date, YYYY-MM-DD (optional)",
+				},
+				"before": map[string]any{
+					"type":        "string",
+					"description": "Only messages received before this date, YYYY-MM-DD (optional)",
+				},
+				"unread": map[string]any{
+					"type":        "boolean",
+					"description": "Only unread messages (default false)",
+				},
+				"mailbox": map[string]any{
+					"type":        "string",
+					"description": "Folder to search (default INBOX; see mail_mailboxes)",
+				},
+				"limit": map[string]any{
+					"type":        "integer",
+					"description": fmt.Sprintf("Maximum messages to list (default %d, max %d)", defaultLimit, maxLimit),
+				},
+			},
+		},
+	}, handleMailSearch)
+
+	s.AddTool(mcp.Tool{
+		Name:        "mail_read",
+		Description: "Read a message by uid: its headers, text body (HTML is converted to Markdown), and a list of attachments. The message is not marked as read.",
+		InputSchema: mcp.ToolInputSchema{
+			Type: "object",
+			Properties: map[string]any{
+				"uid": map[string]any{
+					"type":        "integer",
+					"description": "Message uid, from mail_search",
+				},
+				"mailbox": map[string]any{
+					"type":        "string",
+					"description": "Folder the message is in (default INBOX)",
+				},
+				"max_length": map[string]any{
+					"type":        "integer",
+					"description": fmt.Sprintf("Maximum characters of body to return (default %d, max %d)", defaultMaxLength, maxMaxLength),
+				},
+			},
+			Required: []string{"uid"},
+		},
+	}, handleMailRead)
+
+	if err := server.ServeStdio(s); err != nil {
+		fmt.Printf("server error: %v\n", err)
+	}
+}
+
+func getArgs(request mcp.CallToolRequest) map[string]any {
+	args, _ := request.Params.Arguments.(map[string]any)
+	return args
+}
+
+func textResult(text string) *mcp.CallToolResult {
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{mcp.TextContent{Type: "text", Text: text}},
+	}
+}
+
+func errResult(text string) *mcp.CallToolResult {
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{mcp.TextContent{Type: "text", Text: text}},
+		IsError: true,
+	}
+}
+
+func timeDescription(what string) string {
+	return what + ": YYYY-MM-DD, YYYY-MM-DDTHH:MM, an RFC 3339 time, now, today, or tomorrow. Times without an offset are in " + loc.String() + "."
+}
+
+// parseTime reads a time argument, returning def if it is empty.
+func parseTime(s string, def time.Time) (time.Time, error) {
+	now := time.Now().In(loc)
+	midnight := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc)
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "":
+		return def, nil
+	case "now":
+		return now, nil
+	case "today":
+		return midnight, nil
+	case "tomorrow":
+		return midnight.AddDate(0, 0, 1), nil
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t.In(loc), nil
+	}
+	for _, layout := range []string{"2006-01-02T15:04:05", "2006-01-02T15:04", "2006-01-02 15:04", "2006-01-02"} {
+		if t, err := time.ParseInLocation(layout, s, loc); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid time %q (use YYYY-MM-DD, YYYY-MM-DDTHH:MM, or RFC 3339)", s)
+}
+
+// timeRange reads the start and end arguments.
+func timeRange(args map[string]any) (time.Time, time.Time, error) {
+	startArg, _ := args["start"].(string)
+	start, err := parseTime(startArg, time.Now().In(loc))
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	endArg, _ := args["end"].(string)
+	end, err := parseTime(endArg, start.AddDate(0, 0, 7))
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	if !end.After(start) {
+		return time.Time{}, time.Time{}, fmt.Errorf("end must be after start")
+	}
+	return start, end, nil
+}
+
+// fetchEvents returns the events in [start, end) from every calendar, or
+// from the one named, sorted by start time.
+func fetchEvents(ctx context.Context, start, end time.Time, only string) ([]event, error) {
+	if davErr != nil {
+		return nil, davErr
+	}
+	if dav == nil {
+		return nil, fmt.Errorf("no calendar is configured (set FORGE_CALDAV_URL)")
+	}
+	cals, err := dav.listCalendars(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if only != "" {
+		i := slices.IndexFunc(cals, func(c calendar) bool { return strings.EqualFold(c.Name, only) })
+		if i < 0 {
+			names := make([]string, len(cals))
+			for i, c := range cals {
+				names[i] = c.Name
+			}
+			return nil, fmt.Errorf("no calendar named %q (calendars: %s)", only, strings.Join(names, ", "))
+		}
+		cals = cals[i : i+1]
+	}
+	var events []event
+	for _, cal := range cals {
+		evs, err := dav.events(ctx, cal, start, end, loc)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", cal.Name, err)
+		}
+		events = append(events, evs...)
+	}
+	sort.SliceStable(events, func(i, j int) bool { return events[i].Start.Before(events[j].Start) })
+	return events, nil
+}
+
+const dayFormat = "Mon 2006-01-02"
+
+// formatSpan renders a time range compactly, leaving out the end date when
+// it is the same day.
+func formatSpan(start, end time.Time, allDay bool) string {
+	if allDay {
+		last := end.AddDate(0, 0, -1)
+		if !last.After(start) {
+			return start.Format(dayFormat) + " (all day)"
+		}
+		return start.Format(dayFormat) + " – " + last.Format(dayFormat) + " (all day)"
+	}
+	s := start.Format(dayFormat + " 15:04")
+	if end.Equal(start) {
+		return s
+	}
+	if start.Format(time.DateOnly) == end.Format(time.DateOnly) {
+		return s + "–" + end.Format("15:04")
+	}
+	return s + " – " + end.Format(dayFormat+" 15:04")
+}
+
+func handleEvents(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	args := getArgs(request)
+	start, end, err := timeRange(args)
+	if err != nil {
+		return errResult(fmt.Sprintf("error: %v", err)), nil
+	}
+	only, _ := args["calendar"].(string)
+	events, err := fetchEvents(ctx, start, end, only)
+	if err != nil {
+		return errResult(fmt.Sprintf("error: %v", err)), nil
+	}
+	if q, _ := args["query"].(string); q != "" {
+		q = strings.ToLower(q)
+		events = slices.DeleteFunc(events, func(e event) bool {
+			return !strings.Contains(strings.ToLower(e.Summary+"\n"+e.Location+"\n"+e.Description), q)
+		})
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "Events from %s to %s (%s):\n", start.Format(dayFormat+" 15:04"), end.Format(dayFormat+" 15:04"), loc)
+	if len(events) == 0 {
+		b.WriteString("\nNo events.\n")
+		return textResult(b.String()), nil
+	}
+	for i, e := range events {
+		if i == maxEvents {
+			fmt.Fprintf(&b, "\n... and %d more events; narrow the range to see them.\n", len(events)-maxEvents)
+			break
+		}
+		summary := e.Summary
+		if summary == "" {
+			summary = "(no title)"
+		}
+		fmt.Fprintf(&b, "\n%s  %s [%s]", formatSpan(e.Start, e.End, e.AllDay), summary, e.Calendar)
+		if e.Status == "CANCELLED" || e.Status == "TENTATIVE" {
+			fmt.Fprintf(&b, " (%s)", strings.ToLower(e.Status))
+		}
+		b.WriteString("\n")
+		if e.Location != "" {
+			fmt.Fprintf(&b, "  Location: %s\n", e.Location)
+		}
+		if desc := strings.TrimSpace(e.Description); desc != "" {
+			if utf8.RuneCountInString(desc) > 300 {
+				desc = truncate(desc, 300) + "..."
+			}
+			fmt.Fprintf(&b, "  %s\n", strings.ReplaceAll(desc, "\n", "\n  "))
+		}
+	}
+	return textResult(b.String()), nil
+}
+
+// interval is a span of time, [Start, End).
+type interval struct {
+	Start, End time.Time
+}
+
+// busyIntervals merges the busy events into non-overlapping intervals
+// clipped to [start, end).
+func busyIntervals(events []event, start, end time.Time) []interval {
+	var spans []interval
+	for _, e := range events {
+		if !e.busy() || !e.End.After(start) || !e.Start.Before(end) {
+			continue
+		}
+		spans = append(spans, interval{maxTime(e.Start, start), minTime(e.End, end)})
+	}
+	sort.Slice(spans, func(i, j int) bool { return spans[i].Start.Before(spans[j].Start) })
+	var merged []interval
+	for _, s := range spans {
+		if n := len(merged); n > 0 && !s.Start.After(merged[n-1].End) {
+			merged[n-1].End = maxTime(merged[n-1].End, s.End)
+			continue
+		}
+		merged = append(merged, s)
+	}
+	return merged
+}
+
+// freeSlots returns the gaps between busy intervals inside each window that
+// are at least minLength long.
+func freeSlots(windows, busy []interval, minLength time.Duration) []interval {
+	var free []interval
+	for _, w := range windows {
+		cursor := w.Start
+		for _, b := range busy {
+			if !b.End.After(cursor) || !b.Start.Before(w.End) {
+				continue
+			}
+			if b.Start.Sub(cursor) >= minLength {
+				free = append(free, interval{cursor, b.Start})
+			}
+			cursor = maxTime(cursor, b.End)
+		}
+		if w.End.Sub(cursor) >= minLength {
+			free = append(free, interval{cursor, w.End})
+		}
+	}
+	return free
+}
+
+// windows returns the parts of [start, end) to look for free time in: each
+// day's working hours, or the whole range.
+func windows(start, end time.Time, workingHours string, weekdaysOnly bool) ([]interval, error) {
+	from, to := time.Duration(0), 24*time.Hour
+	if workingHours != "" {
+		a, b, ok := strings.Cut(workingHours, "-")
+		var err error
+		if ok {
+			if from, err = parseClock(a); err == nil {
+				to, err = parseClock(b)
+			}
+		}
+		if !ok || err != nil || to <= from {
+			return nil, fmt.Errorf("invalid working_hours %q (use HH:MM-HH:MM)", workingHours)
+		}
+	} else if !weekdaysOnly {
+		return []interval{{start, end}}, nil
+	}
+	var out []interval
+	day := time.Date(start.Year(), start.Month(), start.Day(), 0, 0, 0, 0, loc)
+	for ; day.Before(end); day = day.AddDate(0, 0, 1) {
+		if weekdaysOnly && (day.Weekday() == time.Saturday || day.Weekday() == time.Sunday) {
+			continue
+		}
+		// Add the clock times to the date rather than the duration, so
+		// daylight saving changes don't shift them.
+		w := interval{
+			time.Date(day.Year(), day.Month(), day.Day(), 0, int(from/time.Minute), 0, 0, loc),
+			time.Date(day.Year(), day.Month(), day.Day(), 0, int(to/time.Minute), 0, 0, loc),
+		}
+		w.Start, w.End = maxTime(w.Start, start), minTime(w.End, end)
+		if w.End.After(w.Start) {
+			out = append(out, w)
+		}
+	}
+	return out, nil
+}
+
+// parseClock reads HH:MM as a time of day.
+func parseClock(s string) (time.Duration, error) {
+	s = strings.TrimSpace(s)
+	if s == "24:00" {
+		return 24 * time.Hour, nil
+	}
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, err
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+func handleFreeBusy(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	args := getArgs(request)
+	start, end, err := timeRange(args)
+	if err != nil {
+		return errResult(fmt.Sprintf("error: %v", err)), nil
+	}
+	minLength := 30 * time.Minute
+	if v, ok := args["duration_minutes"].(float64); ok && v > 0 {
+		minLength = time.Duration(v) * time.Minute
+	}
+	workingHours, _ := args["working_hours"].(string)
+	weekdaysOnly, _ := args["weekdays_only"].(bool)
+	wins, err := windows(start, end, workingHours, weekdaysOnly)
+	if err != nil {
+		return errResult(fmt.Sprintf("error: %v", err)), nil
+	}
+	events, err := fetchEvents(ctx, start, end, "")
+	if err != nil {
+		return errResult(fmt.Sprintf("error: %v", err)), nil
+	}
+	busy := busyIntervals(events, start, end)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "From %s to %s (%s)\n\nBusy:\n", start.Format(dayFormat+" 15:04"), end.Format(dayFormat+" 15:04"), loc)
+	if len(busy) == 0 {
+		b.WriteString("  nothing\n")
+	}
+	for _, s := range busy {
+		fmt.Fprintf(&b, "  %s\n", formatSpan(s.Start, s.End, false))
+	}
+	fmt.Fprintf(&b, "\nFree for at least %s", formatDuration(minLength))
+	if workingHours != "" {
+		fmt.Fprintf(&b, " within %s", workingHours)
+	}
+	if weekdaysOnly {
+		b.WriteString(" on weekdays")
+	}
+	b.WriteString(":\n")
+	free := freeSlots(wins, busy, minLength)
+	if len(free) == 0 {
+		b.WriteString("  nothing\n")
+	}
+	for _, s := range free {
+		fmt.Fprintf(&b, "  %s\n", formatSpan(s.Start, s.End, false))
+	}
+	return textResult(b.String()), nil
+}
+
+func formatDuration(d time.Duration) string {
+	h, m := int(d.Hours()), int(d.Minutes())%60
+	switch {
+	case h == 0:
+		return fmt.Sprintf("%d minutes", m)
+	case m == 0 && h == 1:
+		return "1 hour"
+	case m == 0:
+		return fmt.Sprintf("%d hours", h)
+	}
+	return fmt.Sprintf("%dh%02dm", h, m)
+}
+
+func minTime(a, b time.Time) time.Time {
+	if a.Before(b) {
+		return a
+	}
+	return b
+}
+
+func maxTime(a, b time.Time) time.Time {
+	if a.After(b) {
+		return a
+	}
+	return b
+}
+
+// dialMail connects to the configured IMAP server.
+func dialMail() (*client.Client, error) {
+	if mailboxErr != nil {
+		return nil, mailboxErr
+	}
+	if mailbox == nil {
+		return nil, fmt.Errorf("no mailbox is configured (set FORGE_IMAP_URL)")
+	}
+	return mailbox.dial()
+}
+
+func handleMailboxes(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	c, err := dialMail()
+	if err != nil {
+		return errResult(fmt.Sprintf("error: %v", err)), nil
+	}
+	defer c.Logout()
+
+	ch := make(chan *imap.MailboxInfo, 20)
+	done := make(chan error, 1)
+	go func() { done <- c.List("", "*", ch) }()
+	var names []string
+	for info := range ch {
+		if !slices.Contains(info.Attributes, imap.NoSelectAttr) {
+			names = append(names, info.Name)
+		}
+	}
+	if err := <-done; err != nil {
+		return errResult(fmt.Sprintf("error: %v", err)), nil
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	for _, name := range names {
+		status, err := c.Status(name, []imap.StatusItem{imap.StatusMessages, imap.StatusUnseen})
+		if err != nil {
+			fmt.Fprintf(&b, "%s\n", name)
+			continue
+		}
+		fmt.Fprintf(&b, "%s: %d messages, %d unread\n", name, status.Messages, status.Unseen)
+	}
+	return textResult(b.String()), nil
+}
+
+// searchDate reads a YYYY-MM-DD search argument.
+func searchDate(args map[string]any, key string) (time.Time, error) {
+	s, _ := args[key].(string)
+	if s == "" {
+		return time.Time{}, nil
+	}
+	t, err := time.ParseInLocation("2006-01-02", s, loc)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid %s %q (use YYYY-MM-DD)", key, s)
+	}
+	return t, nil
+}
+
+func handleMailSearch(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	args := getArgs(request)
+	criteria := imap.NewSearchCriteria()
+	if q, _ := args["query"].(string); q != "" {
+		criteria.Text = []string{q}
+	}
+	for _, key := range []string{"from", "to", "subject"} {
+		if v, _ := args[key].(string); v != "" {
+			criteria.Header.Add(key, v)
+		}
+	}
+	var err error
+	if criteria.Since, err = searchDate(args, "since"); err != nil {
+		return errResult(fmt.Sprintf("error: %v", err)), nil
+	}
+	if criteria.Before, err = searchDate(args, "before"); err != nil {
+		return errResult(fmt.Sprintf("error: %v", err)), nil
+	}
+	if unread, _ := args["unread"].(bool); unread {
+		criteria.WithoutFlags = []string{imap.SeenFlag}
+	}
+	limit := defaultLimit
+	if v, ok := args["limit"].(float64); ok && v > 0 {
+		limit = min(int(v), maxLimit)
+	}
+	name, _ := args["mailbox"].(string)
+	if name == "" {
+		name = "INBOX"
+	}
+
+	c, err := dialMail()
+	if err != nil {
+		return errResult(fmt.Sprintf("error: %v", err)), nil
+	}
+	defer c.Logout()
+	// EXAMINE opens the folder read-only.
+	if _, err := c.Select(name, true); err != nil {
+		return errResult(fmt.Sprintf("error: opening %s: %v", name, err)), nil
+	}
+	uids, err := c.UidSearch(criteria)
+	if err != nil {
+		return errResult(fmt.Sprintf("error: searching %s: %v", name, err)), nil
+	}
+	if len(uids) == 0 {
+		return textResult(fmt.Sprintf("No messages in %s match.", name)), nil
+	}
+	// UIDs grow as messages arrive, so the highest are the newest.
+	slices.Sort(uids)
+	total := len(uids)
+	if total > limit {
+		uids = uids[total-limit:]
+	}
+	seqset := new(imap.SeqSet)
+	seqset.AddNum(uids...)
+	ch := make(chan *imap.Message, len(uids))
+	if err := c.UidFetch(seqset, []imap.FetchItem{imap.FetchUid, imap.FetchEnvelope, imap.FetchFlags, imap.FetchInternalDate}, ch); err != nil {
+		return errResult(fmt.Sprintf("error: fetching messages: %v", err)), nil
+	}
+	var list []summary
+	for msg := range ch {
+		list = append(list, newSummary(msg, loc))
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].UID > list[j].UID })
+
+	var b strings.Builder
+	if total > len(list) {
+		fmt.Fprintf(&b, "%s: %d messages match, showing the newest %d\n\n", name, total, len(list))
+	} else {
+		fmt.Fprintf(&b, "%s: %d messages match\n\n", name, total)
+	}
+	for _, s := range list {
+		fmt.Fprintf(&b, "%s\n", s)
+	}
+	return textResult(b.String()), nil
+}
+
+func handleMailRead(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	args := getArgs(request)
+	uid, ok := args["uid"].(float64)
+	if !ok || uid < 1 {
+		return errResult("error: uid is required"), nil
+	}
+	name, _ := args["mailbox"].(string)
+	if name == "" {
+		name = "INBOX"
+	}
+	maxLength := defaultMaxLength
+	if v, ok := args["max_length"].(float64); ok && v > 0 {
+		maxLength = min(int(v), maxMaxLength)
+	}
+
+	c, err := dialMail()
+	if err != nil {
+		return errResult(fmt.Sprintf("error: %v", err)), nil
+	}
+	defer c.Logout()
+	if _, err := c.Select(name, true); err != nil {
+		return errResult(fmt.Sprintf("error: opening %s: %v", name, err)), nil
+	}
+	seqset := new(imap.SeqSet)
+	seqset.AddNum(uint32(uid))
+	// PEEK leaves the \Seen flag alone.
+	section := &imap.BodySectionName{Peek: true}
+	ch := make(chan *imap.Message, 1)
+	if err := c.UidFetch(seqset, []imap.FetchItem{section.FetchItem()}, ch); err != nil {
+		return errResult(fmt.Sprintf("error: fetching message: %v", err)), nil
+	}
+	var raw imap.Literal
+	for msg := range ch {
+		if body := msg.GetBody(section); body != nil {
+			raw = body
+		}
+	}
+	if raw == nil {
+		return errResult(fmt.Sprintf("error: no message with uid %d in %s", int(uid), name)), nil
+	}
+	msg, err := readMessage(raw)
+	if err != nil {
+		return errResult(fmt.Sprintf("error: parsing message: %v", err)), nil
+	}
+
+	var b strings.Builder
+	h := msg.Header
+	for _, key := range []string{"From", "To", "Cc", "Reply-To"} {
+		if v := formatAddresses(h, key); v != "" {
+			fmt.Fprintf(&b, "%s: %s\n", key, v)
+		}
+	}
+	if date, err := h.Date(); err == nil {
+		fmt.Fprintf(&b, "Date: %s\n", date.In(loc).Format(dayFormat+" 15:04 MST"))
+	}
+	subject, _ := h.Subject()
+	fmt.Fprintf(&b, "Subject: %s\n", subject)
+	if len(msg.Attachments) > 0 {
+		fmt.Fprintf(&b, "Attachments: %s\n", strings.Join(msg.Attachments, "; "))
+	}
+	b.WriteString("\n")
+	text := strings.TrimSpace(strings.ReplaceAll(msg.Text, "\r\n", "\n"))
+	if text == "" {
+		text = "(no text body)"
+	}
+	if n := utf8.RuneCountInString(text); n > maxLength {
+		text = truncate(text, maxLength) + fmt.Sprintf("\n... (truncated at %d of %d characters)", maxLength, n)
+	}
+	b.WriteString(text)
+	return textResult(b.String()), nil
+}
+
+// truncate returns the first n characters of s.
+func truncate(s string, n int) string {
+	for i := range s {
+		if n == 0 {
+			return s[:i]
+		}
+		n--
+	}
+	return s
+}
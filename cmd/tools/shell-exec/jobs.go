@@ -0,0 +1,319 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+const (
+	// maxRunningJobs bounds how many background jobs run at once.
+	maxRunningJobs = 16
+	// maxJobs bounds how many jobs, running or finished, are remembered.
+	maxJobs = 64
+	// maxJobOutput is how much of each stream a job keeps, from the end.
+	maxJobOutput = 64 << 10
+	// defaultTailLines is how many lines shell_job_output returns by default.
+	defaultTailLines = 50
+)
+
+// tailBuffer is an io.Writer that keeps only the last maxJobOutput bytes.
+type tailBuffer struct {
+	mu  sync.Mutex
+	buf []byte
+}
+
+func (t *tailBuffer) Write(p []byte) (int, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.buf = append(t.buf, p...)
+	if over := len(t.buf) - maxJobOutput; over > 0 {
+		t.buf = append(t.buf[:0], t.buf[over:]...)
+	}
+	return len(p), nil
+}
+
+// tail returns the last n lines written.
+func (t *tailBuffer) tail(n int) string {
+	t.mu.Lock()
+	s := string(t.buf)
+	t.mu.Unlock()
+
+	trimmed := strings.TrimSuffix(s, "\n")
+	if trimmed == "" {
+		return ""
+	}
+	lines := strings.Split(trimmed, "\n")
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	return strings.Join(lines, "\n")
+}
+
+// job is a command started with shell_exec background=true.
+type job struct {
+	id      string
+	seq     int
+	command string
+	cmd     *exec.Cmd
+	cancel  context.CancelFunc
+	started time.Time
+	stdout  tailBuffer
+	stderr  tailBuffer
+	killed  atomic.Bool
+	done    chan struct{} // closed once the fields below are set
+
+	exitCode int
+	status   string
+	ended    time.Time
+}
+
+func (j *job) finished() bool {
+	select {
+	case <-j.done:
+		return true
+	default:
+		return false
+	}
+}
+
+func (j *job) runtime() time.Duration {
+	end := time.Now()
+	if j.finished() {
+		end = j.ended
+	}
+	return end.Sub(j.started).Round(time.Second)
+}
+
+// exitLine renders a finished job's exit code like formatResult does.
+func (j *job) exitLine() string {
+	line := fmt.Sprintf("exit_code: %d", j.exitCode)
+	if j.status != "" {
+		line += " (" + j.status + ")"
+	}
+	return line
+}
+
+var jobs = struct {
+	sync.Mutex
+	m    map[string]*job
+	next int
+}{m: make(map[string]*job)}
+
+func lookupJob(id string) *job {
+	jobs.Lock()
+	defer jobs.Unlock()
+	return jobs.m[id]
+}
+
+// startJob runs argv in the background. It only times out if the caller
+// passed timeout_seconds.
+func startJob(command string, argv []string, args map[string]any) (*mcp.CallToolResult, error) {
+	jobs.Lock()
+	defer jobs.Unlock()
+
+	running := 0
+	var oldest *job
+	for _, j := range jobs.m {
+		if !j.finished() {
+			running++
+		} else if oldest == nil || j.seq < oldest.seq {
+			oldest = j
+		}
+	}
+	if running >= maxRunningJobs {
+		return errResult(fmt.Sprintf("error: too many background jobs running (max %d); stop one with shell_job_kill", maxRunningJobs)), nil
+	}
+	if len(jobs.m) >= maxJobs && oldest != nil {
+		delete(jobs.m, oldest.id)
+	}
+
+	var ctx context.Context
+	var cancel context.CancelFunc
+	if _, ok := args["timeout_seconds"]; ok {
+		ctx, cancel = context.WithTimeout(context.Background(), timeoutArg(args))
+	} else {
+		ctx, cancel = context.WithCancel(context.Background())
+	}
+	cmd, err := newCommand(ctx, argv, args)
+	if err != nil {
+		cancel()
+		return errResult("error: " + err.Error()), nil
+	}
+
+	jobs.next++
+	j := &job{
+		id:      fmt.Sprintf("j%d", jobs.next),
+		seq:     jobs.next,
+		command: command,
+		cmd:     cmd,
+		cancel:  cancel,
+		done:    make(chan struct{}),
+	}
+	cmd.Stdout = &j.stdout
+	cmd.Stderr = &j.stderr
+	if err := cmd.Start(); err != nil {
+		cancel()
+		return errResult("error: starting command: " + err.Error()), nil
+	}
+	j.started = time.Now()
+	jobs.m[j.id] = j
+
+	go func() {
+		err := cmd.Wait()
+		var exitErr *exec.ExitError
+		switch {
+		case j.killed.Load():
+			j.exitCode, j.status = -1, "killed"
+		case errors.Is(ctx.Err(), context.DeadlineExceeded):
+			j.exitCode, j.status = -1, fmt.Sprintf("timed out after %s (process killed)", timeoutArg(args))
+		case errors.As(err, &exitErr):
+			j.exitCode = exitErr.ExitCode()
+			if j.exitCode < 0 {
+				j.status = exitErr.String()
+			}
+		case err != nil:
+			j.exitCode, j.status = -1, err.Error()
+		}
+		j.ended = time.Now()
+		cancel()
+		close(j.done)
+	}()
+
+	return textResult(fmt.Sprintf("job_id: %s\npid: %d\nstarted in the background; check on it with shell_job_status or shell_job_output",
+		j.id, cmd.Process.Pid)), nil
+}
+
+// kill stops the job and waits briefly for it to be reaped.
+func (j *job) kill() {
+	if j.finished() {
+		return
+	}
+	j.killed.Store(true)
+	j.cancel()
+	select {
+	case <-j.done:
+	case <-time.After(5 * time.Second):
+	}
+}
+
+// killJobs stops every running job.
+func killJobs() {
+	jobs.Lock()
+	all := make([]*job, 0, len(jobs.m))
+	for _, j := range jobs.m {
+		all = append(all, j)
+	}
+	jobs.Unlock()
+	for _, j := range all {
+		j.kill()
+	}
+}
+
+func handleJobStatus(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	args := getArgs(request) // job_id is optional
+	id, _ := args["job_id"].(string)
+
+	if id == "" {
+		jobs.Lock()
+		all := make([]*job, 0, len(jobs.m))
+		for _, j := range jobs.m {
+			all = append(all, j)
+		}
+		jobs.Unlock()
+		if len(all) == 0 {
+			return textResult("no background jobs"), nil
+		}
+		sort.Slice(all, func(a, b int) bool { return all[a].seq < all[b].seq })
+
+		var b strings.Builder
+		for _, j := range all {
+			state := "running"
+			if j.finished() {
+				state = j.exitLine()
+			}
+			fmt.Fprintf(&b, "%s\t%s\t%s\t%s\n", j.id, state, j.runtime(), j.command)
+		}
+		return textResult(strings.TrimSuffix(b.String(), "\n")), nil
+	}
+
+	j := lookupJob(id)
+	if j == nil {
+		return errResult(fmt.Sprintf("error: no job %q", id)), nil
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "job_id: %s\ncommand: %s\npid: %d\n", j.id, j.command, j.cmd.Process.Pid)
+	if j.finished() {
+		fmt.Fprintf(&b, "state: exited\n%s\n", j.exitLine())
+	} else {
+		b.WriteString("state: running\n")
+	}
+	fmt.Fprintf(&b, "started: %s\nruntime: %s", j.started.Format(time.RFC3339), j.runtime())
+	return textResult(b.String()), nil
+}
+
+func handleJobOutput(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	args := getArgs(request)
+	if args == nil {
+		return errResult("error: invalid arguments"), nil
+	}
+	id, _ := args["job_id"].(string)
+	j := lookupJob(id)
+	if j == nil {
+		return errResult(fmt.Sprintf("error: no job %q", id)), nil
+	}
+	lines := defaultTailLines
+	if n, ok := args["lines"].(float64); ok && n > 0 {
+		lines = int(n)
+	}
+
+	// Check before reading so a job that exits in between isn't reported as
+	// running with its final output missing.
+	finished := j.finished()
+	stdout, stderr := j.stdout.tail(lines), j.stderr.tail(lines)
+
+	var b strings.Builder
+	if finished {
+		b.WriteString(j.exitLine() + "\n")
+	} else {
+		b.WriteString("state: running\n")
+	}
+	if stdout == "" && stderr == "" {
+		b.WriteString("(no output)")
+	}
+	writeStreams(&b, tailTruncate(stdout), tailTruncate(stderr))
+	return textResult(strings.TrimSuffix(b.String(), "\n")), nil
+}
+
+// tailTruncate caps s at maxStreamLen, keeping the end.
+func tailTruncate(s string) string {
+	if len(s) > maxStreamLen {
+		return "... (earlier output truncated)\n" + s[len(s)-maxStreamLen:]
+	}
+	return s
+}
+
+func handleJobKill(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	args := getArgs(request)
+	if args == nil {
+		return errResult("error: invalid arguments"), nil
+	}
+	id, _ := args["job_id"].(string)
+	j := lookupJob(id)
+	if j == nil {
+		return errResult(fmt.Sprintf("error: no job %q", id)), nil
+	}
+	if j.finished() {
+		return textResult(fmt.Sprintf("job %s already exited (%s)", j.id, j.exitLine())), nil
+	}
+	j.kill()
+	return textResult("killed job " + j.id), nil
+}
@@ -1,20 +1,41 @@
 package main
 
 import (
+	"bytes"
 	"context"
+	"errors"
 	"fmt"
+	"os"
 	"os/exec"
+	"sort"
+	"strings"
+	"time"
 
 	"github.com/mark3labs/mcp-go/mcp"
 	"github.com/mark3labs/mcp-go/server"
 )
 
+const (
+	defaultTimeout = 120 * time.Second
+	maxTimeout     = 30 * time.Minute
+
+	// maxStreamLen caps each of stdout and stderr in the result.
+	maxStreamLen = 4000
+)
+
 func main() {
-	s := server.NewMCPServer("forge-shell-exec", "0.1.0")
+	if path := os.Getenv("FORGE_SHELL_POLICY"); path != "" {
+		shellPolicy, policyErr = loadPolicy(path)
+		if policyErr != nil {
+			fmt.Fprintf(os.Stderr, "shell policy: %v\n", policyErr)
+		}
+	}
+
+	s := server.NewMCPServer("forge-shell-exec", "0.1.0", server.WithElicitation())
 
 	s.AddTool(mcp.Tool{
 		Name:        "shell_exec",
-		Description: "Execute a shell command and return the combined stdout and stderr output. Use this to run system commands, check files, install packages, etc.",
+		Description: "Execute a shell command. The result starts with an exit_code line, followed by [stdout] and [stderr] sections (omitted when empty). Use this to run system commands, check files, install packages, etc. Commands are killed after timeout_seconds (default 120).",
 		InputSchema: mcp.ToolInputSchema{
 			Type: "object",
 			Properties: map[string]any{
@@ -26,51 +47,322 @@ func main() {
 					"type":        "string",
 					"description": "Working directory for the command (optional)",
 				},
+				"timeout_seconds": map[string]any{
+					"type":        "integer",
+					"description": "Kill the command after this many seconds (default: 120, max: 1800)",
+				},
+				"env": map[string]any{
+					"type":                 "object",
+					"additionalProperties": map[string]any{"type": "string"},
+					"description":          "Extra environment variables for the command",
+				},
+				"shell": map[string]any{
+					"type":        "string",
+					"description": "Shell to run the command with: sh, bash, or pwsh (default: sh)",
+				},
+				"background": map[string]any{
+					"type":        "boolean",
+					"description": "Start the command as a background job and return its job_id right away, for dev servers and watchers. Background jobs only time out if timeout_seconds is given.",
+				},
 			},
 			Required: []string{"command"},
 		},
 	}, handleShellExec)
 
+	s.AddTool(mcp.Tool{
+		Name:        "shell_job_status",
+		Description: "Show the state of a background job started with shell_exec background=true: running or exited, runtime, and exit code. Without job_id, lists every job.",
+		InputSchema: mcp.ToolInputSchema{
+			Type: "object",
+			Properties: map[string]any{
+				"job_id": map[string]any{
+					"type":        "string",
+					"description": "Job to inspect (optional)",
+				},
+			},
+		},
+	}, handleJobStatus)
+
+	s.AddTool(mcp.Tool{
+		Name:        "shell_job_output",
+		Description: "Return the last lines a background job wrote, as [stdout] and [stderr] sections.",
+		InputSchema: mcp.ToolInputSchema{
+			Type: "object",
+			Properties: map[string]any{
+				"job_id": map[string]any{
+					"type":        "string",
+					"description": "Job to read",
+				},
+				"lines": map[string]any{
+					"type":        "integer",
+					"description": "Number of lines to return from the end of each stream (default: 50)",
+				},
+			},
+			Required: []string{"job_id"},
+		},
+	}, handleJobOutput)
+
+	s.AddTool(mcp.Tool{
+		Name:        "shell_job_kill",
+		Description: "Kill a background job and every process it started.",
+		InputSchema: mcp.ToolInputSchema{
+			Type: "object",
+			Properties: map[string]any{
+				"job_id": map[string]any{
+					"type":        "string",
+					"description": "Job to kill",
+				},
+			},
+			Required: []string{"job_id"},
+		},
+	}, handleJobKill)
+
+	s.AddTool(mcp.Tool{
+		Name:        "shell_session_start",
+		Description: "Start a persistent shell session and return its session_id. Commands run with shell_session_exec share the session's working directory and environment, so cd, export, and venv activation carry over between calls.",
+		InputSchema: mcp.ToolInputSchema{
+			Type: "object",
+			Properties: map[string]any{
+				"workdir": map[string]any{
+					"type":        "string",
+					"description": "Initial working directory (optional)",
+				},
+				"env": map[string]any{
+					"type":                 "object",
+					"additionalProperties": map[string]any{"type": "string"},
+					"description":          "Extra environment variables for the session",
+				},
+				"shell": map[string]any{
+					"type":        "string",
+					"description": "Shell to run: sh or bash (default: sh)",
+				},
+			},
+		},
+	}, handleSessionStart)
+
+	s.AddTool(mcp.Tool{
+		Name:        "shell_session_exec",
+		Description: "Run a command in a shell session started with shell_session_start. The result has the same exit_code, [stdout], and [stderr] layout as shell_exec. A command that times out or exits the shell ends the session.",
+		InputSchema: mcp.ToolInputSchema{
+			Type: "object",
+			Properties: map[string]any{
+				"session_id": map[string]any{
+					"type":        "string",
+					"description": "Session to run the command in",
+				},
+				"command": map[string]any{
+					"type":        "string",
+					"description": "The shell command to execute",
+				},
+				"timeout_seconds": map[string]any{
+					"type":        "integer",
+					"description": "Kill the session if the command runs longer than this (default: 120, max: 1800)",
+				},
+			},
+			Required: []string{"session_id", "command"},
+		},
+	}, handleSessionExec)
+
+	s.AddTool(mcp.Tool{
+		Name:        "shell_session_close",
+		Description: "Close a shell session and kill anything still running in it.",
+		InputSchema: mcp.ToolInputSchema{
+			Type: "object",
+			Properties: map[string]any{
+				"session_id": map[string]any{
+					"type":        "string",
+					"description": "Session to close",
+				},
+			},
+			Required: []string{"session_id"},
+		},
+	}, handleSessionClose)
+
 	if err := server.ServeStdio(s); err != nil {
 		fmt.Printf("server error: %v\n", err)
 	}
+	// Background jobs run in their own process groups and would otherwise
+	// outlive the server.
+	killJobs()
+}
+
+// shellArgs returns the argv that runs command under the named shell.
+func shellArgs(shell, command string) ([]string, error) {
+	switch shell {
+	case "", "sh":
+		return []string{"sh", "-c", command}, nil
+	case "bash":
+		return []string{"bash", "-c", command}, nil
+	case "pwsh", "powershell":
+		return []string{"pwsh", "-NoProfile", "-NonInteractive", "-Command", command}, nil
+	default:
+		return nil, fmt.Errorf("unknown shell %q (use sh, bash, or pwsh)", shell)
+	}
+}
+
+// timeoutArg reads timeout_seconds, applying the default and the cap.
+func timeoutArg(args map[string]any) time.Duration {
+	if secs, ok := args["timeout_seconds"].(float64); ok && secs > 0 {
+		return min(time.Duration(secs*float64(time.Second)), maxTimeout)
+	}
+	return defaultTimeout
+}
+
+// commandEnv returns the server's environment with extra variables applied
+// in a stable order.
+func commandEnv(extra map[string]any) ([]string, error) {
+	env := os.Environ()
+	keys := make([]string, 0, len(extra))
+	for k := range extra {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		v, ok := extra[k].(string)
+		if !ok {
+			return nil, fmt.Errorf("env value for %s must be a string", k)
+		}
+		env = append(env, k+"="+v)
+	}
+	return env, nil
+}
+
+// newCommand prepares argv with the workdir and env arguments applied.
+// Canceling ctx kills the command and everything it started.
+func newCommand(ctx context.Context, argv []string, args map[string]any) (*exec.Cmd, error) {
+	cmd := exec.CommandContext(ctx, argv[0], argv[1:]...)
+	if workdir, ok := args["workdir"].(string); ok && workdir != "" {
+		cmd.Dir = workdir
+	}
+	if extra, ok := args["env"].(map[string]any); ok && len(extra) > 0 {
+		var err error
+		if cmd.Env, err = commandEnv(extra); err != nil {
+			return nil, err
+		}
+	}
+	// Kill the whole process group so children like `sleep` in a pipeline
+	// don't outlive the shell, and stop waiting on pipes they hold open.
+	setProcessGroup(cmd)
+	cmd.Cancel = func() error { return killProcessGroup(cmd) }
+	cmd.WaitDelay = 2 * time.Second
+	return cmd, nil
 }
 
 func handleShellExec(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
-	args, _ := request.Params.Arguments.(map[string]any)
+	args := getArgs(request)
 	if args == nil {
-		return &mcp.CallToolResult{
-			Content: []mcp.Content{mcp.TextContent{Type: "text", Text: "error: invalid arguments"}},
-			IsError: true,
-		}, nil
+		return errResult("error: invalid arguments"), nil
 	}
 
 	command, ok := args["command"].(string)
 	if !ok {
-		return &mcp.CallToolResult{
-			Content: []mcp.Content{mcp.TextContent{Type: "text", Text: "error: 'command' argument must be a string"}},
-			IsError: true,
-		}, nil
+		return errResult("error: 'command' argument must be a string"), nil
+	}
+
+	shell, _ := args["shell"].(string)
+	argv, err := shellArgs(shell, command)
+	if err != nil {
+		return errResult("error: " + err.Error()), nil
 	}
 
-	cmd := exec.CommandContext(ctx, "sh", "-c", command)
+	if err := authorize(ctx, command); err != nil {
+		return errResult("error: " + err.Error()), nil
+	}
 
-	if workdir, ok := args["workdir"].(string); ok && workdir != "" {
-		cmd.Dir = workdir
+	if background, _ := args["background"].(bool); background {
+		return startJob(command, argv, args)
 	}
 
-	output, err := cmd.CombinedOutput()
-	result := string(output)
+	timeout := timeoutArg(args)
+	runCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	cmd, err := newCommand(runCtx, argv, args)
 	if err != nil {
-		result += "\nexit error: " + err.Error()
+		return errResult("error: " + err.Error()), nil
 	}
 
-	const maxLen = 4000
-	if len(result) > maxLen {
-		result = result[:maxLen] + "\n... (output truncated)"
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	err = cmd.Run()
+
+	exitCode := 0
+	var status string
+	timedOut := errors.Is(runCtx.Err(), context.DeadlineExceeded) && ctx.Err() == nil
+	var exitErr *exec.ExitError
+	switch {
+	case timedOut:
+		exitCode = -1
+		status = fmt.Sprintf("timed out after %s (process killed)", timeout)
+	case errors.As(err, &exitErr):
+		exitCode = exitErr.ExitCode()
+		if exitCode < 0 {
+			status = exitErr.String()
+		}
+	case err != nil:
+		// The command never started (bad workdir, missing shell).
+		exitCode = -1
+		status = err.Error()
 	}
 
+	return textResult(formatResult(exitCode, status, stdout.String(), stderr.String())), nil
+}
+
+// formatResult renders the exit code and labeled output streams, e.g.
+//
+//	exit_code: 1
+//	[stdout]
+//	...
+//	[stderr]
+//	...
+//
+// status, if set, follows the exit code in parentheses.
+func formatResult(exitCode int, status, stdout, stderr string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "exit_code: %d", exitCode)
+	if status != "" {
+		fmt.Fprintf(&b, " (%s)", status)
+	}
+	b.WriteByte('\n')
+	writeStreams(&b, truncate(stdout), truncate(stderr))
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+// writeStreams appends the [stdout] and [stderr] sections, skipping empty ones.
+func writeStreams(b *strings.Builder, stdout, stderr string) {
+	for _, stream := range []struct{ label, text string }{{"stdout", stdout}, {"stderr", stderr}} {
+		if stream.text == "" {
+			continue
+		}
+		fmt.Fprintf(b, "[%s]\n%s", stream.label, stream.text)
+		if !strings.HasSuffix(stream.text, "\n") {
+			b.WriteByte('\n')
+		}
+	}
+}
+
+func truncate(s string) string {
+	if len(s) > maxStreamLen {
+		return s[:maxStreamLen] + "\n... (output truncated)"
+	}
+	return s
+}
+
+func getArgs(request mcp.CallToolRequest) map[string]any {
+	args, _ := request.Params.Arguments.(map[string]any)
+	return args
+}
+
+func textResult(text string) *mcp.CallToolResult {
 	return &mcp.CallToolResult{
-		Content: []mcp.Content{mcp.TextContent{Type: "text", Text: result}},
-	}, nil
+		Content: []mcp.Content{mcp.TextContent{Type: "text", Text: text}},
+	}
+}
+
+func errResult(text string) *mcp.CallToolResult {
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{mcp.TextContent{Type: "text", Text: text}},
+		IsError: true,
+	}
 }
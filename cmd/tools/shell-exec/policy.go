@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"regexp"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"gopkg.in/yaml.v3"
+)
+
+// policy restricts which commands the server will run. Patterns are Go
+// regular expressions matched anywhere in the command text. Deny wins over
+// allow; a non-empty allow list blocks everything it doesn't match; and
+// require_approval commands run only after the user approves them through
+// the client.
+//
+// Matching raw text is a guardrail against an agent wandering into the
+// wrong command, not a sandbox: a determined command can be obfuscated past
+// any pattern.
+type policy struct {
+	allow   []*regexp.Regexp
+	deny    []*regexp.Regexp
+	approve []*regexp.Regexp
+}
+
+type policyFile struct {
+	Allow           []string `yaml:"allow"`
+	Deny            []string `yaml:"deny"`
+	RequireApproval []string `yaml:"require_approval"`
+}
+
+// shellPolicy is loaded from FORGE_SHELL_POLICY at startup; nil allows
+// every command. If the file can't be loaded, policyErr is set and every
+// command is refused rather than run unchecked.
+var (
+	shellPolicy *policy
+	policyErr   error
+)
+
+func loadPolicy(path string) (*policy, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var f policyFile
+	if err := yaml.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+
+	p := &policy{}
+	for _, list := range []struct {
+		name     string
+		patterns []string
+		dst      *[]*regexp.Regexp
+	}{
+		{"allow", f.Allow, &p.allow},
+		{"deny", f.Deny, &p.deny},
+		{"require_approval", f.RequireApproval, &p.approve},
+	} {
+		for _, pattern := range list.patterns {
+			re, err := regexp.Compile(pattern)
+			if err != nil {
+				return nil, fmt.Errorf("%s: bad %s pattern %q: %w", path, list.name, pattern, err)
+			}
+			*list.dst = append(*list.dst, re)
+		}
+	}
+	return p, nil
+}
+
+func firstMatch(res []*regexp.Regexp, command string) *regexp.Regexp {
+	for _, re := range res {
+		if re.MatchString(command) {
+			return re
+		}
+	}
+	return nil
+}
+
+// authorize checks command against the policy, asking the user through the
+// client when it needs approval. A nil error means the command may run.
+func authorize(ctx context.Context, command string) error {
+	if policyErr != nil {
+		return fmt.Errorf("shell policy: %w", policyErr)
+	}
+	p := shellPolicy
+	if p == nil {
+		return nil
+	}
+	if re := firstMatch(p.deny, command); re != nil {
+		return fmt.Errorf("command blocked by shell policy (matches deny pattern %q)", re)
+	}
+	if len(p.allow) > 0 && firstMatch(p.allow, command) == nil {
+		return fmt.Errorf("command blocked by shell policy (matches no allow pattern)")
+	}
+	re := firstMatch(p.approve, command)
+	if re == nil {
+		return nil
+	}
+
+	srv := server.ServerFromContext(ctx)
+	if srv == nil {
+		return fmt.Errorf("command requires approval (matches %q), but there is no client to ask", re)
+	}
+	result, err := srv.RequestElicitation(ctx, mcp.ElicitationRequest{
+		Params: mcp.ElicitationParams{
+			Message:         fmt.Sprintf("Run this shell command? It matches the approval pattern %q.\n\n%s", re, command),
+			RequestedSchema: map[string]any{"type": "object", "properties": map[string]any{}},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("command requires approval (matches %q), but approval could not be requested: %v", re, err)
+	}
+	if result.Action != mcp.ElicitationResponseActionAccept {
+		return fmt.Errorf("command was not approved by the user")
+	}
+	return nil
+}
@@ -0,0 +1,16 @@
+//go:build !unix
+
+package main
+
+import "os/exec"
+
+// setProcessGroup is a no-op where process groups aren't available.
+func setProcessGroup(cmd *exec.Cmd) {}
+
+// killProcessGroup kills the command itself; its children may survive.
+func killProcessGroup(cmd *exec.Cmd) error {
+	if cmd.Process == nil {
+		return nil
+	}
+	return cmd.Process.Kill()
+}
@@ -0,0 +1,313 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+const (
+	// maxSessions bounds how many shells one server keeps alive.
+	maxSessions = 8
+	// maxSessionOutput caps what is buffered per stream for one command.
+	maxSessionOutput = 1 << 20
+)
+
+// session is a long-lived shell that commands are fed to one at a time, so
+// cwd, variables, and activated environments carry over between calls.
+// After each command the shell prints a marker line on both streams, which
+// tells us where that command's output ends.
+type session struct {
+	id     string
+	shell  string
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	stdout *bufio.Reader
+	stderr *bufio.Reader
+	marker string
+	done   chan struct{} // closed once the shell has exited
+
+	mu sync.Mutex // serializes exec
+}
+
+var sessions = struct {
+	sync.Mutex
+	m    map[string]*session
+	next int
+}{m: make(map[string]*session)}
+
+// sessionArgv returns the argv for a shell that reads commands from stdin.
+func sessionArgv(shell string) ([]string, error) {
+	switch shell {
+	case "", "sh":
+		return []string{"sh"}, nil
+	case "bash":
+		return []string{"bash", "--noprofile", "--norc"}, nil
+	default:
+		return nil, fmt.Errorf("unsupported session shell %q (use sh or bash)", shell)
+	}
+}
+
+func startSession(shell, workdir string, env []string) (*session, error) {
+	argv, err := sessionArgv(shell)
+	if err != nil {
+		return nil, err
+	}
+	marker := make([]byte, 8)
+	if _, err := rand.Read(marker); err != nil {
+		return nil, err
+	}
+
+	cmd := exec.Command(argv[0], argv[1:]...)
+	cmd.Dir = workdir
+	cmd.Env = env
+	setProcessGroup(cmd)
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	// Plain os.Pipes rather than StdoutPipe: Wait closes the latter as soon
+	// as the shell exits, which would race with reading its last output.
+	stdoutR, stdoutW, err := os.Pipe()
+	if err != nil {
+		return nil, err
+	}
+	stderrR, stderrW, err := os.Pipe()
+	if err != nil {
+		stdoutR.Close()
+		stdoutW.Close()
+		return nil, err
+	}
+	cmd.Stdout = stdoutW
+	cmd.Stderr = stderrW
+	err = cmd.Start()
+	stdoutW.Close()
+	stderrW.Close()
+	if err != nil {
+		stdoutR.Close()
+		stderrR.Close()
+		return nil, err
+	}
+
+	s := &session{
+		shell:  argv[0],
+		cmd:    cmd,
+		stdin:  stdin,
+		stdout: bufio.NewReader(stdoutR),
+		stderr: bufio.NewReader(stderrR),
+		marker: "__forge_done_" + hex.EncodeToString(marker),
+		done:   make(chan struct{}),
+	}
+	go func() {
+		cmd.Wait()
+		stdoutR.Close()
+		stderrR.Close()
+		close(s.done)
+	}()
+	return s, nil
+}
+
+// readUntil reads r up to the marker line and returns what came before it,
+// along with the rest of the marker line. ok is false if the stream ended
+// first, i.e. the shell exited.
+func readUntil(r *bufio.Reader, marker string) (out, rest string, ok bool) {
+	var b strings.Builder
+	for {
+		line, err := r.ReadString('\n')
+		if strings.HasPrefix(line, marker) {
+			// The marker is printed after a newline of its own so it always
+			// starts a line; drop that newline from the output.
+			return strings.TrimSuffix(b.String(), "\n"), strings.TrimSpace(line[len(marker):]), true
+		}
+		if b.Len() < maxSessionOutput {
+			b.WriteString(line)
+		}
+		if err != nil {
+			return b.String(), "", false
+		}
+	}
+}
+
+type streamResult struct {
+	out, rest string
+	ok        bool
+}
+
+// exec runs command in the session. If it times out or the shell exits, the
+// session is over and closed reports true.
+func (s *session) exec(ctx context.Context, command string, timeout time.Duration) (exitCode int, status, stdout, stderr string, closed bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	select {
+	case <-s.done:
+		return -1, "shell has exited", "", "", true
+	default:
+	}
+
+	// A syntax error makes a non-interactive sh exit, taking the session's
+	// state with it, so check the command in a throwaway shell first.
+	check := exec.CommandContext(ctx, s.shell, "-n", "-c", command)
+	if out, err := check.CombinedOutput(); err != nil {
+		return 2, "syntax error", "", string(out), false
+	}
+
+	// eval keeps cd and variable assignments in the session's shell, and
+	// stdin is redirected so the command can't eat the script that follows.
+	quoted := "'" + strings.ReplaceAll(command, "'", `'\''`) + "'"
+	script := fmt.Sprintf("eval %s </dev/null\n__forge_rc=$?\nprintf '\\n%s %%d\\n' \"$__forge_rc\"\nprintf '\\n%s\\n' >&2\n",
+		quoted, s.marker, s.marker)
+	if _, err := io.WriteString(s.stdin, script); err != nil {
+		<-s.done
+		return -1, "shell has exited", "", "", true
+	}
+
+	outCh := make(chan streamResult, 1)
+	errCh := make(chan streamResult, 1)
+	go func() {
+		out, rest, ok := readUntil(s.stdout, s.marker)
+		outCh <- streamResult{out, rest, ok}
+	}()
+	go func() {
+		out, rest, ok := readUntil(s.stderr, s.marker)
+		errCh <- streamResult{out, rest, ok}
+	}()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	var o, e *streamResult
+	// abort kills the shell and collects whatever the readers got so far.
+	abort := func(status string) (int, string, string, string, bool) {
+		s.kill()
+		if o == nil {
+			r := <-outCh
+			o = &r
+		}
+		if e == nil {
+			r := <-errCh
+			e = &r
+		}
+		return -1, status, o.out, e.out, true
+	}
+	for o == nil || e == nil {
+		select {
+		case r := <-outCh:
+			o = &r
+		case r := <-errCh:
+			e = &r
+		case <-timer.C:
+			return abort(fmt.Sprintf("timed out after %s; session closed", timeout))
+		case <-ctx.Done():
+			return abort("canceled; session closed")
+		}
+	}
+
+	if !o.ok || !e.ok {
+		// The command ended the shell, e.g. with exit.
+		<-s.done
+		return s.cmd.ProcessState.ExitCode(), "shell exited; session closed", o.out, e.out, true
+	}
+	exitCode, _ = strconv.Atoi(o.rest)
+	return exitCode, "", o.out, e.out, false
+}
+
+// kill stops the shell and anything it started, and waits for it to exit.
+func (s *session) kill() {
+	s.stdin.Close()
+	killProcessGroup(s.cmd)
+	<-s.done
+}
+
+func handleSessionStart(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	args := getArgs(request) // every argument is optional
+	workdir, _ := args["workdir"].(string)
+	env := os.Environ()
+	if extra, ok := args["env"].(map[string]any); ok && len(extra) > 0 {
+		var err error
+		if env, err = commandEnv(extra); err != nil {
+			return errResult("error: " + err.Error()), nil
+		}
+	}
+
+	sessions.Lock()
+	defer sessions.Unlock()
+	if len(sessions.m) >= maxSessions {
+		return errResult(fmt.Sprintf("error: too many open sessions (max %d); close one with shell_session_close", maxSessions)), nil
+	}
+
+	shell, _ := args["shell"].(string)
+	s, err := startSession(shell, workdir, env)
+	if err != nil {
+		return errResult("error: starting shell: " + err.Error()), nil
+	}
+	sessions.next++
+	s.id = fmt.Sprintf("s%d", sessions.next)
+	sessions.m[s.id] = s
+
+	if workdir == "" {
+		workdir, _ = os.Getwd()
+	}
+	return textResult(fmt.Sprintf("session_id: %s\nshell: %s\nworkdir: %s", s.id, s.shell, workdir)), nil
+}
+
+func handleSessionExec(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	args := getArgs(request)
+	if args == nil {
+		return errResult("error: invalid arguments"), nil
+	}
+	id, _ := args["session_id"].(string)
+	command, ok := args["command"].(string)
+	if !ok {
+		return errResult("error: 'command' argument must be a string"), nil
+	}
+
+	sessions.Lock()
+	s := sessions.m[id]
+	sessions.Unlock()
+	if s == nil {
+		return errResult(fmt.Sprintf("error: no session %q (start one with shell_session_start)", id)), nil
+	}
+	if err := authorize(ctx, command); err != nil {
+		return errResult("error: " + err.Error()), nil
+	}
+
+	exitCode, status, stdout, stderr, closed := s.exec(ctx, command, timeoutArg(args))
+	if closed {
+		sessions.Lock()
+		delete(sessions.m, id)
+		sessions.Unlock()
+	}
+	return textResult(formatResult(exitCode, status, stdout, stderr)), nil
+}
+
+func handleSessionClose(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	args := getArgs(request)
+	if args == nil {
+		return errResult("error: invalid arguments"), nil
+	}
+	id, _ := args["session_id"].(string)
+
+	sessions.Lock()
+	s := sessions.m[id]
+	delete(sessions.m, id)
+	sessions.Unlock()
+	if s == nil {
+		return errResult(fmt.Sprintf("error: no session %q", id)), nil
+	}
+
+	// A command still running in the session is cut off.
+	s.kill()
+	return textResult("closed session " + id), nil
+}
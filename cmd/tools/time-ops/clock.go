@@ -0,0 +1,192 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// loadZone resolves a timezone argument: an IANA name such as
+// Europe/Berlin, UTC, "local", or a fixed offset such as +05:30 or UTC-8.
+// Empty means the default zone.
+func loadZone(name string) (*time.Location, error) {
+	name = strings.TrimSpace(name)
+	switch strings.ToLower(name) {
+	case "", "local":
+		return loc, nil
+	case "utc", "z", "gmt":
+		return time.UTC, nil
+	}
+	if m := offsetZone.FindStringSubmatch(name); m != nil {
+		h, _ := strconv.Atoi(m[2])
+		mins, _ := strconv.Atoi(m[3])
+		secs := h*3600 + mins*60
+		if m[1] == "-" {
+			secs = -secs
+		}
+		return time.FixedZone(strings.TrimPrefix(strings.ToUpper(name), "GMT"), secs), nil
+	}
+	l, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, fmt.Errorf("unknown timezone %q (use an IANA name such as America/New_York, or an offset such as +05:30)", name)
+	}
+	return l, nil
+}
+
+var offsetZone = regexp.MustCompile(`(?i)^(?:utc|gmt)?\s*([+-])(\d{1,2})(?::?(\d{2}))?$`)
+
+// parseTime reads a time argument. Times without an offset are in zone.
+// Empty means now.
+func parseTime(s string, zone *time.Location) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	now := time.Now().In(zone)
+	midnight := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, zone)
+	switch strings.ToLower(s) {
+	case "", "now":
+		return now, nil
+	case "today":
+		return midnight, nil
+	case "tomorrow":
+		return midnight.AddDate(0, 0, 1), nil
+	case "yesterday":
+		return midnight.AddDate(0, 0, -1), nil
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	for _, layout := range []string{"2006-01-02T15:04:05", "2006-01-02T15:04", "2006-01-02 15:04:05", "2006-01-02 15:04", "2006-01-02"} {
+		if t, err := time.ParseInLocation(layout, s, zone); err == nil {
+			return t, nil
+		}
+	}
+	// Unix timestamps, in seconds.
+	if n, err := strconv.ParseInt(s, 10, 64); err == nil && len(s) >= 9 {
+		return time.Unix(n, 0).In(zone), nil
+	}
+	return time.Time{}, fmt.Errorf("invalid time %q (use %s)", s, timeHelp)
+}
+
+const timeHelp = "YYYY-MM-DD, YYYY-MM-DDTHH:MM, RFC 3339, a Unix timestamp, now, today, or tomorrow"
+
+// amount is a signed calendar span: years, months, and days move by the
+// calendar, so a month from January 31 is March 3 or 2, and a day across a
+// daylight saving change is 23 or 25 hours; the rest is exact.
+type amount struct {
+	years, months, days int
+	clock               time.Duration
+}
+
+var amountPart = regexp.MustCompile(`(?i)(\d+)\s*(years?|y|months?|mo|weeks?|w|days?|d|hours?|hrs?|h|minutes?|mins?|m|seconds?|secs?|s)`)
+
+// parseAmount reads a span such as 3d, 1h30m, 2 weeks, or -1mo.
+func parseAmount(s string) (amount, error) {
+	var a amount
+	s = strings.TrimSpace(s)
+	sign := 1
+	if rest, ok := strings.CutPrefix(s, "-"); ok {
+		sign, s = -1, rest
+	} else {
+		s = strings.TrimPrefix(s, "+")
+	}
+	matches := amountPart.FindAllStringSubmatchIndex(s, -1)
+	// Everything but separators must be part of a match.
+	leftover := amountPart.ReplaceAllString(s, "")
+	if len(matches) == 0 || strings.Trim(leftover, " ,and") != "" {
+		return a, fmt.Errorf("invalid amount %q (use units such as 2y, 3mo, 1w, 4d, 5h, 30m, 10s)", s)
+	}
+	for _, m := range matches {
+		n, _ := strconv.Atoi(s[m[2]:m[3]])
+		n *= sign
+		switch unit := strings.ToLower(s[m[4]:m[5]]); {
+		case unit == "y" || strings.HasPrefix(unit, "year"):
+			a.years += n
+		case unit == "mo" || strings.HasPrefix(unit, "month"):
+			a.months += n
+		case unit == "w" || strings.HasPrefix(unit, "week"):
+			a.days += 7 * n
+		case unit == "d" || strings.HasPrefix(unit, "day"):
+			a.days += n
+		case strings.HasPrefix(unit, "h"):
+			a.clock += time.Duration(n) * time.Hour
+		case unit == "m" || strings.HasPrefix(unit, "min"):
+			a.clock += time.Duration(n) * time.Minute
+		default:
+			a.clock += time.Duration(n) * time.Second
+		}
+	}
+	return a, nil
+}
+
+func (a amount) addTo(t time.Time) time.Time {
+	return t.AddDate(a.years, a.months, a.days).Add(a.clock)
+}
+
+// addBusinessDays moves t by n weekdays, skipping Saturdays and Sundays.
+func addBusinessDays(t time.Time, n int) time.Time {
+	step := 1
+	if n < 0 {
+		step, n = -1, -n
+	}
+	for n > 0 {
+		t = t.AddDate(0, 0, step)
+		if !isWeekend(t) {
+			n--
+		}
+	}
+	return t
+}
+
+func isWeekend(t time.Time) bool {
+	return t.Weekday() == time.Saturday || t.Weekday() == time.Sunday
+}
+
+// businessDaysBetween counts the weekdays after from's date, up to and
+// including to's date.
+func businessDaysBetween(from, to time.Time) int {
+	sign := 1
+	if to.Before(from) {
+		from, to, sign = to, from, -1
+	}
+	a := time.Date(from.Year(), from.Month(), from.Day(), 12, 0, 0, 0, time.UTC)
+	b := time.Date(to.Year(), to.Month(), to.Day(), 12, 0, 0, 0, time.UTC)
+	n := 0
+	for d := a.AddDate(0, 0, 1); !d.After(b); d = d.AddDate(0, 0, 1) {
+		if !isWeekend(d) {
+			n++
+		}
+	}
+	return sign * n
+}
+
+// formatTime renders t with its weekday, zone abbreviation, and UTC offset,
+// so the model doesn't have to work them out.
+func formatTime(t time.Time) string {
+	return t.Format("Mon 2006-01-02 15:04:05 MST") + " (UTC" + t.Format("-07:00") + ")"
+}
+
+// formatSpan renders a duration as days, hours, minutes, and seconds.
+func formatSpan(d time.Duration) string {
+	if d < 0 {
+		d = -d
+	}
+	if d < time.Second {
+		return "0 seconds"
+	}
+	var parts []string
+	for _, u := range []struct {
+		size time.Duration
+		name string
+	}{{24 * time.Hour, "day"}, {time.Hour, "hour"}, {time.Minute, "minute"}, {time.Second, "second"}} {
+		if n := int64(d / u.size); n > 0 {
+			part := fmt.Sprintf("%d %s", n, u.name)
+			if n != 1 {
+				part += "s"
+			}
+			parts = append(parts, part)
+			d -= time.Duration(n) * u.size
+		}
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,355 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+	_ "time/tzdata"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// loc is the default timezone, from FORGE_TIMEZONE.
+var loc = time.Local
+
+func main() {
+	if tz := os.Getenv("FORGE_TIMEZONE"); tz != "" {
+		l, err := time.LoadLocation(tz)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "forge-time-ops: FORGE_TIMEZONE: %v\n", err)
+		} else {
+			loc = l
+		}
+	}
+	defer closeStore()
+
+	s := server.NewMCPServer("forge-time-ops", "0.1.0")
+
+	timezone := map[string]any{
+		"type":        "string",
+		"description": fmt.Sprintf("Timezone for times without an offset, and for the result: an IANA name such as Europe/Berlin, or an offset such as +05:30 (default %s)", loc),
+	}
+
+	s.AddTool(mcp.Tool{
+		Name:        "time_now",
+		Description: "Get the current date and time, with the weekday, week number, and UTC offset. Use it instead of guessing today's date.",
+		InputSchema: mcp.ToolInputSchema{
+			Type: "object",
+			Properties: map[string]any{
+				"timezones": map[string]any{
+					"type":        "array",
+					"items":       map[string]any{"type": "string"},
+					"description": fmt.Sprintf("Timezones to show the time in (default %s)", loc),
+				},
+			},
+		},
+	}, handleNow)
+
+	s.AddTool(mcp.Tool{
+		Name:        "time_convert",
+		Description: "Convert a time from one timezone to others.",
+		InputSchema: mcp.ToolInputSchema{
+			Type: "object",
+			Properties: map[string]any{
+				"time": map[string]any{
+					"type":        "string",
+					"description": "Time to convert: " + timeHelp,
+				},
+				"from": map[string]any{
+					"type":        "string",
+					"description": fmt.Sprintf("Timezone the time is in, if it has no offset (default %s)", loc),
+				},
+				"to": map[string]any{
+					"type":        "array",
+					"items":       map[string]any{"type": "string"},
+					"description": "Timezones to convert to",
+				},
+			},
+			Required: []string{"time", "to"},
+		},
+	}, handleConvert)
+
+	s.AddTool(mcp.Tool{
+		Name:        "time_add",
+		Description: "Add an amount of time or a number of business days to a date, such as 3 weeks from today or 10 business days before a deadline.",
+		InputSchema: mcp.ToolInputSchema{
+			Type: "object",
+			Properties: map[string]any{
+				"time": map[string]any{
+					"type":        "string",
+					"description": "Starting time (default now): " + timeHelp,
+				},
+				"amount": map[string]any{
+					"type":        "string",
+					"description": "Amount to add, such as 3d, 1h30m, 2w, 1mo, or 1y; prefix with - to subtract. Months and years follow the calendar.",
+				},
+				"business_days": map[string]any{
+					"type":        "integer",
+					"description": "Weekdays to add, skipping Saturdays and Sundays (negative to go back). Public holidays are not skipped.",
+				},
+				"timezone": timezone,
+			},
+		},
+	}, handleAdd)
+
+	s.AddTool(mcp.Tool{
+		Name:        "time_diff",
+		Description: "Work out how far apart two times are, in days, hours, and minutes, and in business days.",
+		InputSchema: mcp.ToolInputSchema{
+			Type: "object",
+			Properties: map[string]any{
+				"start": map[string]any{
+					"type":        "string",
+					"description": "Earlier time: " + timeHelp,
+				},
+				"end": map[string]any{
+					"type":        "string",
+					"description": "Later time (default now)",
+				},
+				"timezone": timezone,
+			},
+			Required: []string{"start"},
+		},
+	}, handleDiff)
+
+	s.AddTool(mcp.Tool{
+		Name:        "task_schedule",
+		Description: "Schedule a follow-up: at the given time, the forge server sends the prompt to this session as a new message, and the agent carries it out. Use it to check back on something later, such as a deploy or a reply. Write the prompt so it makes sense on its own. Tasks only run while forge serve is running.",
+		InputSchema: mcp.ToolInputSchema{
+			Type: "object",
+			Properties: map[string]any{
+				"prompt": map[string]any{
+					"type":        "string",
+					"description": "What to do when the task runs",
+				},
+				"at": map[string]any{
+					"type":        "string",
+					"description": "When to run: " + timeHelp,
+				},
+				"in": map[string]any{
+					"type":        "string",
+					"description": "Or how long from now to run, such as 30m, 2h, or 1d",
+				},
+				"title": map[string]any{
+					"type":        "string",
+					"description": "Short name for the task (default the start of the prompt)",
+				},
+				"timezone": timezone,
+			},
+			Required: []string{"prompt"},
+		},
+	}, handleSchedule)
+
+	s.AddTool(mcp.Tool{
+		Name:        "task_list",
+		Description: "List scheduled tasks in this session, soonest first, with their status and, once run, their result.",
+		InputSchema: mcp.ToolInputSchema{
+			Type: "object",
+			Properties: map[string]any{
+				"status": map[string]any{
+					"type":        "string",
+					"description": "Only tasks with this status: pending, running, done, failed, or cancelled (default all)",
+				},
+				"all_sessions": map[string]any{
+					"type":        "boolean",
+					"description": "List tasks from every session, not just this one (default false)",
+				},
+			},
+		},
+	}, handleList)
+
+	s.AddTool(mcp.Tool{
+		Name:        "task_cancel",
+		Description: "Cancel a scheduled task that hasn't run yet.",
+		InputSchema: mcp.ToolInputSchema{
+			Type: "object",
+			Properties: map[string]any{
+				"id": map[string]any{
+					"type":        "integer",
+					"description": "Task ID, from task_schedule or task_list",
+				},
+			},
+			Required: []string{"id"},
+		},
+	}, handleCancel)
+
+	if err := server.ServeStdio(s); err != nil {
+		fmt.Printf("server error: %v\n", err)
+	}
+}
+
+func getArgs(request mcp.CallToolRequest) map[string]any {
+	args, _ := request.Params.Arguments.(map[string]any)
+	return args
+}
+
+func textResult(text string) *mcp.CallToolResult {
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{mcp.TextContent{Type: "text", Text: text}},
+	}
+}
+
+func errResult(text string) *mcp.CallToolResult {
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{mcp.TextContent{Type: "text", Text: text}},
+		IsError: true,
+	}
+}
+
+// zones reads a list of timezones, given as an array or a comma-separated
+// string.
+func zones(v any) ([]*time.Location, error) {
+	var names []string
+	switch v := v.(type) {
+	case string:
+		names = strings.Split(v, ",")
+	case []any:
+		for _, n := range v {
+			if s, ok := n.(string); ok {
+				names = append(names, s)
+			}
+		}
+	}
+	var out []*time.Location
+	for _, name := range names {
+		if strings.TrimSpace(name) == "" {
+			continue
+		}
+		l, err := loadZone(name)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, l)
+	}
+	return out, nil
+}
+
+func handleNow(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	zs, err := zones(getArgs(request)["timezones"])
+	if err != nil {
+		return errResult(fmt.Sprintf("error: %v", err)), nil
+	}
+	if len(zs) == 0 {
+		zs = []*time.Location{loc}
+	}
+	now := time.Now()
+	var b strings.Builder
+	for _, z := range zs {
+		t := now.In(z)
+		year, week := t.ISOWeek()
+		fmt.Fprintf(&b, "%s: %s\n", z, formatTime(t))
+		fmt.Fprintf(&b, "  %s, ISO week %d-W%02d, day %d of the year\n", t.Format(time.RFC3339), year, week, t.YearDay())
+	}
+	fmt.Fprintf(&b, "Unix time: %d\n", now.Unix())
+	return textResult(b.String()), nil
+}
+
+func handleConvert(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	args := getArgs(request)
+	from, err := loadZone(stringArg(args, "from"))
+	if err != nil {
+		return errResult(fmt.Sprintf("error: %v", err)), nil
+	}
+	t, err := parseTime(stringArg(args, "time"), from)
+	if err != nil {
+		return errResult(fmt.Sprintf("error: %v", err)), nil
+	}
+	to, err := zones(args["to"])
+	if err != nil {
+		return errResult(fmt.Sprintf("error: %v", err)), nil
+	}
+	if len(to) == 0 {
+		return errResult("error: to must name at least one timezone"), nil
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s: %s\n", t.Location(), formatTime(t))
+	for _, z := range to {
+		c := t.In(z)
+		fmt.Fprintf(&b, "%s: %s", z, formatTime(c))
+		if days := dayOffset(t, c); days != 0 {
+			fmt.Fprintf(&b, " [%+d day]", days)
+		}
+		b.WriteString("\n")
+	}
+	return textResult(b.String()), nil
+}
+
+// dayOffset reports how many calendar days ahead b's date is of a's.
+func dayOffset(a, b time.Time) int {
+	da := time.Date(a.Year(), a.Month(), a.Day(), 0, 0, 0, 0, time.UTC)
+	db := time.Date(b.Year(), b.Month(), b.Day(), 0, 0, 0, 0, time.UTC)
+	return int(db.Sub(da).Hours() / 24)
+}
+
+func handleAdd(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	args := getArgs(request)
+	zone, err := loadZone(stringArg(args, "timezone"))
+	if err != nil {
+		return errResult(fmt.Sprintf("error: %v", err)), nil
+	}
+	start, err := parseTime(stringArg(args, "time"), zone)
+	if err != nil {
+		return errResult(fmt.Sprintf("error: %v", err)), nil
+	}
+	start = start.In(zone)
+	amountArg := stringArg(args, "amount")
+	businessDays, hasBusinessDays := args["business_days"].(float64)
+	if amountArg == "" && !hasBusinessDays {
+		return errResult("error: give an amount, business_days, or both"), nil
+	}
+
+	t := start
+	if amountArg != "" {
+		a, err := parseAmount(amountArg)
+		if err != nil {
+			return errResult(fmt.Sprintf("error: %v", err)), nil
+		}
+		t = a.addTo(t)
+	}
+	if hasBusinessDays {
+		t = addBusinessDays(t, int(businessDays))
+	}
+	return textResult(fmt.Sprintf("%s\n  %s\nStarting from %s", formatTime(t), t.Format(time.RFC3339), formatTime(start))), nil
+}
+
+func handleDiff(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	args := getArgs(request)
+	zone, err := loadZone(stringArg(args, "timezone"))
+	if err != nil {
+		return errResult(fmt.Sprintf("error: %v", err)), nil
+	}
+	startArg := stringArg(args, "start")
+	if startArg == "" {
+		return errResult("error: start is required"), nil
+	}
+	start, err := parseTime(startArg, zone)
+	if err != nil {
+		return errResult(fmt.Sprintf("error: %v", err)), nil
+	}
+	end, err := parseTime(stringArg(args, "end"), zone)
+	if err != nil {
+		return errResult(fmt.Sprintf("error: %v", err)), nil
+	}
+	start, end = start.In(zone), end.In(zone)
+
+	d := end.Sub(start)
+	relation := "after"
+	if d < 0 {
+		relation = "before"
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "End is %s %s start.\n", formatSpan(d), relation)
+	fmt.Fprintf(&b, "Total: %.2f hours, %d minutes\n", d.Hours(), int64(d.Minutes()))
+	fmt.Fprintf(&b, "Calendar days: %d, business days: %d\n", dayOffset(start, end), businessDaysBetween(start, end))
+	fmt.Fprintf(&b, "Start: %s\nEnd: %s\n", formatTime(start), formatTime(end))
+	return textResult(b.String()), nil
+}
+
+func stringArg(args map[string]any, key string) string {
+	s, _ := args[key].(string)
+	return s
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+
+	"github.com/michaelbrown/forge/internal/storage"
+	"github.com/michaelbrown/forge/internal/storage/sqlite"
+)
+
+var (
+	storeOnce sync.Once
+	store     *sqlite.SQLiteStore
+	storeErr  error
+)
+
+// openStore opens the forge database on first use, so the time tools work
+// even when it can't be opened. FORGE_DB_PATH must match the server's
+// storage.db_path for scheduled tasks to run.
+func openStore() (*sqlite.SQLiteStore, error) {
+	storeOnce.Do(func() {
+		path := os.Getenv("FORGE_DB_PATH")
+		if path == "" {
+			path = filepath.Join(os.Getenv("HOME"), ".forge", "forge.db")
+		}
+		store, storeErr = sqlite.Open(path)
+	})
+	return store, storeErr
+}
+
+func closeStore() {
+	if store != nil {
+		store.Close()
+	}
+}
+
+// sessionID returns the forge session a call belongs to, from the request's
+// _meta (see tools.SessionMetaKey). It is empty outside a session.
+func sessionID(request mcp.CallToolRequest) string {
+	if meta := request.Params.Meta; meta != nil {
+		if id, ok := meta.AdditionalFields["forge/session"].(string); ok {
+			return id
+		}
+	}
+	return ""
+}
+
+func handleSchedule(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	args := getArgs(request)
+	prompt := strings.TrimSpace(stringArg(args, "prompt"))
+	if prompt == "" {
+		return errResult("error: prompt is required"), nil
+	}
+	zone, err := loadZone(stringArg(args, "timezone"))
+	if err != nil {
+		return errResult(fmt.Sprintf("error: %v", err)), nil
+	}
+
+	at, in := stringArg(args, "at"), stringArg(args, "in")
+	var runAt time.Time
+	switch {
+	case at != "" && in != "":
+		return errResult("error: give either at or in, not both"), nil
+	case at != "":
+		if runAt, err = parseTime(at, zone); err != nil {
+			return errResult(fmt.Sprintf("error: %v", err)), nil
+		}
+	case in != "":
+		a, err := parseAmount(in)
+		if err != nil {
+			return errResult(fmt.Sprintf("error: %v", err)), nil
+		}
+		runAt = a.addTo(time.Now().In(zone))
+	default:
+		return errResult("error: give a time with at or a delay with in"), nil
+	}
+	if !runAt.After(time.Now()) {
+		return errResult(fmt.Sprintf("error: %s is in the past", formatTime(runAt.In(zone)))), nil
+	}
+
+	title := strings.TrimSpace(stringArg(args, "title"))
+	if title == "" {
+		title = defaultTitle(prompt)
+	}
+
+	st, err := openStore()
+	if err != nil {
+		return errResult(fmt.Sprintf("error opening task store: %v", err)), nil
+	}
+	task := &storage.Task{SessionID: sessionID(request), Title: title, Prompt: prompt, RunAt: runAt}
+	if err := st.CreateTask(ctx, task); err != nil {
+		return errResult(fmt.Sprintf("error scheduling task: %v", err)), nil
+	}
+
+	where := "in this session"
+	if task.SessionID == "" {
+		where = "in a new session"
+	}
+	return textResult(fmt.Sprintf("Scheduled task %d %q for %s (in %s), %s.\nIt runs only while forge serve is running; cancel it with task_cancel.",
+		task.ID, title, formatTime(runAt.In(zone)), formatSpan(time.Until(runAt).Round(time.Minute)), where)), nil
+}
+
+// defaultTitle shortens a prompt's first line to a task title.
+func defaultTitle(prompt string) string {
+	title, _, _ := strings.Cut(prompt, "\n")
+	if r := []rune(title); len(r) > 60 {
+		title = strings.TrimSpace(string(r[:60])) + "..."
+	}
+	return title
+}
+
+func handleList(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	args := getArgs(request)
+	opts := storage.TaskListOptions{Status: storage.TaskStatus(stringArg(args, "status")), Limit: 100}
+	switch opts.Status {
+	case "", storage.TaskPending, storage.TaskRunning, storage.TaskDone, storage.TaskFailed, storage.TaskCancelled:
+	default:
+		return errResult(fmt.Sprintf("error: invalid status %q (use pending, running, done, failed, or cancelled)", opts.Status)), nil
+	}
+	allSessions, _ := args["all_sessions"].(bool)
+	if !allSessions {
+		opts.SessionID = sessionID(request)
+		if opts.SessionID == "" {
+			allSessions = true
+		}
+	}
+
+	st, err := openStore()
+	if err != nil {
+		return errResult(fmt.Sprintf("error opening task store: %v", err)), nil
+	}
+	tasks, err := st.ListTasks(ctx, opts)
+	if err != nil {
+		return errResult(fmt.Sprintf("error: %v", err)), nil
+	}
+	if len(tasks) == 0 {
+		return textResult("No scheduled tasks."), nil
+	}
+
+	var b strings.Builder
+	for _, t := range tasks {
+		fmt.Fprintf(&b, "%d. [%s] %s — %s", t.ID, t.Status, t.Title, formatTime(t.RunAt.In(loc)))
+		if allSessions && t.SessionID != "" {
+			fmt.Fprintf(&b, " (session %.8s)", t.SessionID)
+		}
+		b.WriteString("\n")
+		if t.Result != "" {
+			fmt.Fprintf(&b, "   %s\n", truncate(strings.ReplaceAll(t.Result, "\n", " "), 200))
+		}
+	}
+	return textResult(b.String()), nil
+}
+
+func handleCancel(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	id, ok := getArgs(request)["id"].(float64)
+	if !ok {
+		return errResult("error: id is required"), nil
+	}
+	st, err := openStore()
+	if err != nil {
+		return errResult(fmt.Sprintf("error opening task store: %v", err)), nil
+	}
+	if err := st.CancelTask(ctx, int64(id)); err != nil {
+		return errResult(fmt.Sprintf("error: %v", err)), nil
+	}
+	return textResult(fmt.Sprintf("Cancelled task %d.", int64(id))), nil
+}
+
+func truncate(s string, max int) string {
+	if r := []rune(s); len(r) > max {
+		return string(r[:max]) + "..."
+	}
+	return s
+}
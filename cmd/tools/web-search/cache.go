@@ -0,0 +1,151 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Repeated searches are answered from an on-disk cache, and searches that do
+// reach the API are counted against an hourly budget. Both live in a
+// directory shared by every web-search server on the machine, so the
+// budget holds across sessions and restarts. Writes are atomic but not
+// locked; concurrent servers can undercount the budget by a request or two.
+//
+// FORGE_SEARCH_CACHE_DIR sets the directory (default: the user cache dir),
+// FORGE_SEARCH_CACHE_TTL how long results stay fresh (a duration, default
+// 1h; 0 disables the cache), and FORGE_SEARCH_BUDGET the API requests
+// allowed per hour (default 0, unlimited).
+
+const defaultCacheTTL = time.Hour
+
+type searchCache struct {
+	dir    string
+	ttl    time.Duration
+	budget int
+
+	mu sync.Mutex // serializes budget updates within this server
+}
+
+type cacheEntry struct {
+	Query     string    `json:"query"`
+	FetchedAt time.Time `json:"fetched_at"`
+	Result    string    `json:"result"`
+}
+
+func newSearchCache() (*searchCache, error) {
+	c := &searchCache{dir: os.Getenv("FORGE_SEARCH_CACHE_DIR"), ttl: defaultCacheTTL}
+	if c.dir == "" {
+		base, err := os.UserCacheDir()
+		if err != nil {
+			return nil, fmt.Errorf("finding cache dir (set FORGE_SEARCH_CACHE_DIR): %w", err)
+		}
+		c.dir = filepath.Join(base, "forge", "web-search")
+	}
+	if v := os.Getenv("FORGE_SEARCH_CACHE_TTL"); v != "" {
+		ttl, err := time.ParseDuration(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid FORGE_SEARCH_CACHE_TTL: %w", err)
+		}
+		c.ttl = ttl
+	}
+	if v := os.Getenv("FORGE_SEARCH_BUDGET"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("invalid FORGE_SEARCH_BUDGET %q", v)
+		}
+		c.budget = n
+	}
+	return c, nil
+}
+
+// cacheKey normalizes case and spacing so trivially different phrasings of
+// a query share an entry.
+func cacheKey(query string) string {
+	sum := sha256.Sum256([]byte(strings.ToLower(strings.Join(strings.Fields(query), " "))))
+	return hex.EncodeToString(sum[:16])
+}
+
+// get returns a fresh cached result for key.
+func (c *searchCache) get(key string) (cacheEntry, bool) {
+	if c.ttl <= 0 {
+		return cacheEntry{}, false
+	}
+	var e cacheEntry
+	data, err := os.ReadFile(filepath.Join(c.dir, key+".json"))
+	if err != nil || json.Unmarshal(data, &e) != nil {
+		return cacheEntry{}, false
+	}
+	if time.Since(e.FetchedAt) > c.ttl {
+		return cacheEntry{}, false
+	}
+	return e, true
+}
+
+func (c *searchCache) put(key string, e cacheEntry) error {
+	if c.ttl <= 0 {
+		return nil
+	}
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	return writeAtomic(filepath.Join(c.dir, key+".json"), data)
+}
+
+// spend records an API request against the hourly budget. When the budget
+// is used up it records nothing and returns how long until a request frees
+// up.
+func (c *searchCache) spend(now time.Time) (wait time.Duration, err error) {
+	if c.budget == 0 {
+		return 0, nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	path := filepath.Join(c.dir, "budget.json")
+	var times []time.Time
+	if data, err := os.ReadFile(path); err == nil {
+		_ = json.Unmarshal(data, &times)
+	}
+	recent := times[:0]
+	for _, t := range times {
+		if now.Sub(t) < time.Hour {
+			recent = append(recent, t)
+		}
+	}
+	if len(recent) >= c.budget {
+		return recent[len(recent)-c.budget].Add(time.Hour).Sub(now), nil
+	}
+	data, err := json.Marshal(append(recent, now))
+	if err != nil {
+		return 0, err
+	}
+	return 0, writeAtomic(path, data)
+}
+
+func writeAtomic(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,271 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"mime"
+	"net/http"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/mark3labs/mcp-go/mcp"
+
+	"github.com/michaelbrown/forge/internal/webpage"
+)
+
+const (
+	defaultMaxLength = 10_000
+	maxMaxLength     = 100_000
+
+	// maxBodyBytes caps how much of a response is read. Pages are much
+	// larger than their text, so this is well above maxMaxLength.
+	maxBodyBytes = 5 << 20
+)
+
+func handleWebFetch(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	args := getArgs(request)
+	url, _ := args["url"].(string)
+	if url == "" {
+		return errResult("error: 'url' is required"), nil
+	}
+	mode, _ := args["mode"].(string)
+	switch mode {
+	case "":
+		mode = "readable"
+	case "readable", "full", "raw":
+	default:
+		return errResult(fmt.Sprintf("error: unknown mode %q (want readable, full, or raw)", mode)), nil
+	}
+	selector, _ := args["selector"].(string)
+	if selector != "" && mode == "raw" {
+		return errResult("error: 'selector' cannot be used with raw mode"), nil
+	}
+	maxLength := defaultMaxLength
+	if v, ok := args["max_length"].(float64); ok && v > 0 {
+		maxLength = min(int(v), maxMaxLength)
+	}
+	offset := 0
+	if v, ok := args["offset"].(float64); ok && v > 0 {
+		offset = int(v)
+	}
+
+	req, err := newFetchRequest(ctx, url, args)
+	if err != nil {
+		return errResult(fmt.Sprintf("error: %v", err)), nil
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return errResult(fmt.Sprintf("error: %v", err)), nil
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxBodyBytes))
+	if err != nil {
+		return errResult(fmt.Sprintf("error reading body: %v", err)), nil
+	}
+	if resp.StatusCode >= 400 {
+		return errResult(fmt.Sprintf("error: %s %s returned %s\n\n%s", req.Method, url, resp.Status, truncate(string(body), 500))), nil
+	}
+
+	header := ""
+	if resp.StatusCode != http.StatusOK {
+		header = "Status: " + resp.Status + "\n"
+	}
+	if cr := resp.Header.Get("Content-Range"); cr != "" {
+		header += "Content-Range: " + cr + "\n"
+	}
+	if req.Method == http.MethodHead {
+		return textResult(header + "URL: " + resp.Request.URL.String() + "\n\n" + formatHeaders(resp.Header)), nil
+	}
+
+	contentType := resp.Header.Get("Content-Type")
+	if contentType == "" {
+		contentType = http.DetectContentType(body)
+	}
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+
+	var text string
+	switch {
+	case len(body) == 0:
+	case mode != "raw" && isHTML(mediaType):
+		page, err := webpage.Extract(strings.NewReader(string(body)), webpage.Options{
+			BaseURL:  resp.Request.URL,
+			Selector: selector,
+			Full:     mode == "full",
+		})
+		if err != nil {
+			return errResult(fmt.Sprintf("error: %v", err)), nil
+		}
+		if page.Title != "" {
+			header = "Title: " + page.Title + "\n" + header
+		}
+		text = page.Markdown
+	case selector != "":
+		return errResult(fmt.Sprintf("error: 'selector' needs an HTML page, got %s", mediaType)), nil
+	case !isText(mediaType):
+		return errResult(fmt.Sprintf("error: %s is %s, not text", url, mediaType)), nil
+	default:
+		text = string(body)
+	}
+	header += "URL: " + resp.Request.URL.String() + "\n\n"
+
+	total := utf8.RuneCountInString(text)
+	if offset > 0 {
+		if offset >= total {
+			return errResult(fmt.Sprintf("error: offset %d is past the end of the content (%d characters)", offset, total)), nil
+		}
+		text = skip(text, offset)
+	}
+	if end := offset + maxLength; end < total {
+		text = truncate(text, maxLength) + fmt.Sprintf("\n\n... (truncated: showing characters %d-%d of %d; continue with offset=%d)", offset, end, total, end)
+	} else if offset > 0 {
+		text += fmt.Sprintf("\n\n... (end: showing characters %d-%d of %d)", offset, total, total)
+	}
+	if len(body) == maxBodyBytes {
+		text += fmt.Sprintf("\n\n... (response cut off after %d bytes; use byte_range to fetch the rest)", maxBodyBytes)
+	}
+	return textResult(header + text), nil
+}
+
+// fetchMethods are the methods web_fetch accepts.
+var fetchMethods = []string{"GET", "POST", "PUT", "PATCH", "DELETE", "HEAD"}
+
+// newFetchRequest builds the request from the method, headers, body, and
+// byte_range arguments.
+func newFetchRequest(ctx context.Context, url string, args map[string]any) (*http.Request, error) {
+	method, _ := args["method"].(string)
+	method = strings.ToUpper(method)
+	if method == "" {
+		method = http.MethodGet
+	}
+	if !slices.Contains(fetchMethods, method) {
+		return nil, fmt.Errorf("unsupported method %q (want one of %s)", method, strings.Join(fetchMethods, ", "))
+	}
+
+	var body io.Reader
+	bodyText, hasBody := args["body"].(string)
+	if hasBody {
+		if method == http.MethodGet || method == http.MethodHead {
+			return nil, fmt.Errorf("'body' cannot be sent with %s", method)
+		}
+		body = strings.NewReader(bodyText)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, url, body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", "Forge/0.1")
+	if hasBody && json.Valid([]byte(bodyText)) {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	if headers, ok := args["headers"].(map[string]any); ok {
+		for name, v := range headers {
+			value, ok := v.(string)
+			if !ok {
+				return nil, fmt.Errorf("header %q must be a string", name)
+			}
+			value, err := expandEnv(value)
+			if err != nil {
+				return nil, fmt.Errorf("header %q: %w", name, err)
+			}
+			req.Header.Set(name, value)
+		}
+	}
+
+	if r, _ := args["byte_range"].(string); r != "" {
+		if !byteRange.MatchString(r) {
+			return nil, fmt.Errorf("invalid byte_range %q (want start-end or start-)", r)
+		}
+		req.Header.Set("Range", "bytes="+r)
+	}
+	return req, nil
+}
+
+var byteRange = regexp.MustCompile(`^\d+-\d*$`)
+
+// expandEnv replaces ${NAME} references with environment variables. Only
+// variables named in FORGE_FETCH_ENV (comma-separated) may be used, so an
+// agent can't send arbitrary secrets from the server's environment, such as
+// model API keys, to a URL of its choosing.
+func expandEnv(s string) (string, error) {
+	allowed := strings.Split(os.Getenv("FORGE_FETCH_ENV"), ",")
+	for i := range allowed {
+		allowed[i] = strings.TrimSpace(allowed[i])
+	}
+	var err error
+	out := envRef.ReplaceAllStringFunc(s, func(ref string) string {
+		name := ref[2 : len(ref)-1]
+		if !slices.Contains(allowed, name) {
+			if err == nil {
+				err = fmt.Errorf("${%s} is not allowed; list it in FORGE_FETCH_ENV in the web-search server's env", name)
+			}
+			return ""
+		}
+		value, ok := os.LookupEnv(name)
+		if !ok && err == nil {
+			err = fmt.Errorf("${%s} is not set", name)
+		}
+		return value
+	})
+	return out, err
+}
+
+var envRef = regexp.MustCompile(`\$\{[A-Za-z_][A-Za-z0-9_]*\}`)
+
+func formatHeaders(h http.Header) string {
+	names := slices.Sorted(maps.Keys(h))
+	var b strings.Builder
+	for _, name := range names {
+		for _, v := range h[name] {
+			fmt.Fprintf(&b, "%s: %s\n", name, v)
+		}
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+func isHTML(mediaType string) bool {
+	return mediaType == "text/html" || mediaType == "application/xhtml+xml"
+}
+
+func isText(mediaType string) bool {
+	if strings.HasPrefix(mediaType, "text/") {
+		return true
+	}
+	switch mediaType {
+	case "application/json", "application/xml", "application/javascript",
+		"application/x-yaml", "application/yaml", "application/x-ndjson":
+		return true
+	}
+	return strings.HasSuffix(mediaType, "+json") || strings.HasSuffix(mediaType, "+xml")
+}
+
+// skip returns s without its first n characters.
+func skip(s string, n int) string {
+	for i := range s {
+		if n == 0 {
+			return s[i:]
+		}
+		n--
+	}
+	return ""
+}
+
+// truncate returns the first n characters of s.
+func truncate(s string, n int) string {
+	for i := range s {
+		if n == 0 {
+			return s[:i]
+		}
+		n--
+	}
+	return s
+}
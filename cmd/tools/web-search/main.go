@@ -16,7 +16,16 @@ import (
 
 var httpClient = &http.Client{Timeout: 30 * time.Second}
 
+// cache is set up at startup; if its settings are invalid, cacheErr is set
+// and web_search reports it instead of running without a budget.
+var (
+	cache    *searchCache
+	cacheErr error
+)
+
 func main() {
+	cache, cacheErr = newSearchCache()
+
 	s := server.NewMCPServer("forge-web-search", "0.1.0")
 
 	s.AddTool(mcp.Tool{
@@ -36,7 +45,7 @@ func main() {
 
 	s.AddTool(mcp.Tool{
 		Name:        "web_fetch",
-		Description: "Fetch the text content of a URL via HTTP GET.",
+		Description: "Fetch a URL over HTTP. HTML pages are converted to Markdown: by default only the main content is kept, with navigation, scripts, and other page chrome removed; links and headings are preserved. Other text responses, such as JSON from an API, are returned as-is. Supports other methods, headers, and a request body for calling APIs.",
 		InputSchema: mcp.ToolInputSchema{
 			Type: "object",
 			Properties: map[string]any{
@@ -44,6 +53,41 @@ func main() {
 					"type":        "string",
 					"description": "The URL to fetch",
 				},
+				"mode": map[string]any{
+					"type":        "string",
+					"enum":        []string{"readable", "full", "raw"},
+					"description": "readable (default): main content as Markdown; full: the whole page as Markdown; raw: the response body unconverted",
+				},
+				"selector": map[string]any{
+					"type":        "string",
+					"description": "CSS selector limiting the output to matching elements, e.g. 'article', '#content', 'table.data', 'div.post > h2'. Pseudo-classes are not supported.",
+				},
+				"max_length": map[string]any{
+					"type":        "integer",
+					"description": fmt.Sprintf("Maximum characters to return (default %d, max %d)", defaultMaxLength, maxMaxLength),
+				},
+				"offset": map[string]any{
+					"type":        "integer",
+					"description": "Character offset into the converted content to start from, for reading a long page in chunks. A truncated result says which offset to continue from. Each call fetches the URL again.",
+				},
+				"method": map[string]any{
+					"type":        "string",
+					"enum":        []string{"GET", "POST", "PUT", "PATCH", "DELETE", "HEAD"},
+					"description": "HTTP method (default GET)",
+				},
+				"headers": map[string]any{
+					"type":                 "object",
+					"additionalProperties": map[string]any{"type": "string"},
+					"description":          "Request headers. Values may reference environment variables allowed by the server's FORGE_FETCH_ENV setting as ${NAME}, e.g. {\"Authorization\": \"Bearer ${API_TOKEN}\"}.",
+				},
+				"body": map[string]any{
+					"type":        "string",
+					"description": "Request body. Sent as application/json when it is valid JSON and no Content-Type header is given.",
+				},
+				"byte_range": map[string]any{
+					"type":        "string",
+					"description": "Fetch only part of the resource with a Range header, as start-end or start- (bytes, inclusive), e.g. '0-65535'",
+				},
 			},
 			Required: []string{"url"},
 		},
@@ -79,10 +123,25 @@ func handleWebSearch(ctx context.Context, request mcp.CallToolRequest) (*mcp.Cal
 		return errResult("error: 'query' is required"), nil
 	}
 
+	if cacheErr != nil {
+		return errResult(fmt.Sprintf("error: %v", cacheErr)), nil
+	}
+	key := cacheKey(query)
+	if e, ok := cache.get(key); ok {
+		return textResult(fmt.Sprintf("(cached result, %d minutes old)\n\n%s", int(time.Since(e.FetchedAt).Minutes()), e.Result)), nil
+	}
+
 	apiKey := os.Getenv("TAVILY_API_KEY")
 	if apiKey == "" {
 		return errResult("error: TAVILY_API_KEY not set"), nil
 	}
+	wait, err := cache.spend(time.Now())
+	if err != nil {
+		return errResult(fmt.Sprintf("error: recording search budget: %v", err)), nil
+	}
+	if wait > 0 {
+		return errResult(fmt.Sprintf("error: the search budget of %d requests per hour is used up; the next request is available in %s. Cached queries still work.", cache.budget, wait.Round(time.Second))), nil
+	}
 
 	body := map[string]any{
 		"query":          query,
@@ -91,7 +150,11 @@ func handleWebSearch(ctx context.Context, request mcp.CallToolRequest) (*mcp.Cal
 	}
 	bodyJSON, _ := json.Marshal(body)
 
-	req, err := http.NewRequestWithContext(ctx, "POST", "https://api.tavily.com/search", strings.NewReader(string(bodyJSON)))
+	apiURL := os.Getenv("TAVILY_API_URL")
+	if apiURL == "" {
+		apiURL = "https://api.tavily.com"
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", strings.TrimSuffix(apiURL, "/")+"/search", strings.NewReader(string(bodyJSON)))
 	if err != nil {
 		return errResult(fmt.Sprintf("error: %v", err)), nil
 	}
@@ -133,37 +196,8 @@ func handleWebSearch(ctx context.Context, request mcp.CallToolRequest) (*mcp.Cal
 		sb.WriteString(fmt.Sprintf("%d. %s\n   %s\n   %s\n\n", i+1, r.Title, r.URL, r.Content))
 	}
 
-	return textResult(sb.String()), nil
-}
-
-func handleWebFetch(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
-	args := getArgs(request)
-	url, _ := args["url"].(string)
-	if url == "" {
-		return errResult("error: 'url' is required"), nil
-	}
-
-	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
-	if err != nil {
-		return errResult(fmt.Sprintf("error: %v", err)), nil
-	}
-	req.Header.Set("User-Agent", "Forge/0.1")
-
-	resp, err := httpClient.Do(req)
-	if err != nil {
-		return errResult(fmt.Sprintf("error: %v", err)), nil
+	if err := cache.put(key, cacheEntry{Query: query, FetchedAt: time.Now(), Result: sb.String()}); err != nil {
+		fmt.Fprintf(os.Stderr, "forge-web-search: caching result: %v\n", err)
 	}
-	defer resp.Body.Close()
-
-	body, err := io.ReadAll(io.LimitReader(resp.Body, 50_000))
-	if err != nil {
-		return errResult(fmt.Sprintf("error reading body: %v", err)), nil
-	}
-
-	text := string(body)
-	if len(text) > 4000 {
-		text = text[:4000] + "\n... (truncated)"
-	}
-
-	return textResult(text), nil
+	return textResult(sb.String()), nil
 }
@@ -0,0 +1,110 @@
+//go:build wasip1
+
+// Example Forge WASM plugin exposing a single wasm_echo tool.
+//
+// Build it as a WASI reactor:
+//
+//	make build-plugins
+//
+// Then register it in forge.yaml like any other tool server:
+//
+//	tools:
+//	  echo:
+//	    binary: "bin/forge-plugin-echo.wasm"
+//	    enabled: true
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"unsafe"
+)
+
+// buffers keeps host-written allocations alive until the call that uses them returns.
+var buffers = map[uint32][]byte{}
+
+// output holds the most recent response so the host can read it after return.
+var output []byte
+
+var toolDefs = []map[string]any{
+	{
+		"name":        "wasm_echo",
+		"description": "Echo text back, optionally upper-cased. Runs inside a WASM sandbox.",
+		"parameters": map[string]any{
+			"type": "object",
+			"properties": map[string]any{
+				"text": map[string]any{
+					"type":        "string",
+					"description": "Text to echo",
+				},
+				"upper": map[string]any{
+					"type":        "boolean",
+					"description": "Upper-case the text (optional)",
+				},
+			},
+			"required": []string{"text"},
+		},
+	},
+}
+
+func main() {}
+
+//go:wasmexport forge_alloc
+func forgeAlloc(size uint32) uint32 {
+	buf := make([]byte, size+1) // +1 so zero-size allocations get a real address
+	ptr := uint32(uintptr(unsafe.Pointer(&buf[0])))
+	buffers[ptr] = buf
+	return ptr
+}
+
+//go:wasmexport forge_describe
+func forgeDescribe() uint64 {
+	data, _ := json.Marshal(toolDefs)
+	return respond(data)
+}
+
+//go:wasmexport forge_call
+func forgeCall(namePtr, nameLen, argsPtr, argsLen uint32) uint64 {
+	name := string(take(namePtr, nameLen))
+	argsJSON := take(argsPtr, argsLen)
+
+	var args map[string]any
+	if err := json.Unmarshal(argsJSON, &args); err != nil {
+		return result("invalid arguments: "+err.Error(), true)
+	}
+
+	switch name {
+	case "wasm_echo":
+		text, ok := args["text"].(string)
+		if !ok {
+			return result("'text' is required", true)
+		}
+		if upper, _ := args["upper"].(bool); upper {
+			text = strings.ToUpper(text)
+		}
+		return result(text, false)
+	default:
+		return result("unknown tool "+name, true)
+	}
+}
+
+// take returns a host-written buffer and releases it.
+func take(ptr, size uint32) []byte {
+	buf := buffers[ptr]
+	delete(buffers, ptr)
+	return buf[:size]
+}
+
+func result(content string, isError bool) uint64 {
+	data, _ := json.Marshal(map[string]any{"content": content, "is_error": isError})
+	return respond(data)
+}
+
+func respond(data []byte) uint64 {
+	output = data
+	if len(data) == 0 {
+		return 0
+	}
+	ptr := uint64(uintptr(unsafe.Pointer(&output[0])))
+	return ptr<<32 | uint64(len(output))
+}
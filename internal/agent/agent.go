@@ -4,10 +4,11 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"os/exec"
 	"strings"
+	"time"
 
 	"github.com/michaelbrown/forge/internal/llm"
+	"github.com/michaelbrown/forge/internal/logging"
 	"github.com/michaelbrown/forge/internal/tools"
 )
 
@@ -20,13 +21,20 @@ type Agent struct {
 	llm          llm.Client
 	utilityLLM   llm.Client // optional, for summarization/titles
 	registry     *tools.Registry
+	builtins     *tools.BuiltinServer // used when the registry has no tools
 	history      []llm.Message
+	saved        int  // leading history messages already persisted
+	rewritten    bool // history changed before saved since the last save
 	tools        []llm.ToolDef
+	toolFilter   []string // profile tool allowlist, re-applied on refresh
 	maxIter      int
 	maxTokens    int
 	OnToolCall   func(name string, args map[string]any)
 	OnToolResult func(name string, result string)
 	OnTextDelta  func(delta string)
+	// OnToolOutput gets what a tool reports while it runs, such as a
+	// program's output so far, before its result.
+	OnToolOutput func(name string, output string)
 }
 
 const defaultMaxTokens = 6000
@@ -38,24 +46,42 @@ func New(client llm.Client, registry *tools.Registry, maxIterations int) *Agent
 		registry:  registry,
 		maxIter:   maxIterations,
 		maxTokens: defaultMaxTokens,
+		builtins:  tools.NewBuiltinServer(),
 		history: []llm.Message{
 			llm.SystemMessage(defaultSystemPrompt),
 		},
 	}
 
+	a.RefreshTools()
+	return a
+}
+
+// RefreshTools reloads tool definitions from the registry so servers added
+// at runtime become visible. The profile tool filter is re-applied.
+func (a *Agent) RefreshTools() {
 	// Use registry tools if available, otherwise fall back to builtins
-	if registry != nil && registry.HasTools() {
-		a.tools = registry.AllTools()
+	if a.registry != nil && a.registry.HasTools() {
+		a.tools = a.registry.AllTools()
 	} else {
-		a.tools = a.builtinTools()
+		a.tools = a.builtins.ToolDefs()
 	}
-	return a
+	a.applyToolFilter()
 }
 
 // SetSystemPrompt overrides the default system prompt.
 func (a *Agent) SetSystemPrompt(prompt string) {
 	if prompt != "" {
 		a.history[0] = llm.SystemMessage(prompt)
+		a.rewritten = a.rewritten || a.saved > 0
+	}
+}
+
+// AppendSystemPrompt adds text to the end of the system prompt, the default
+// or the one set, as a paragraph of its own.
+func (a *Agent) AppendSystemPrompt(text string) {
+	if text != "" {
+		a.history[0] = llm.SystemMessage(a.history[0].Content + "\n\n" + text)
+		a.rewritten = a.rewritten || a.saved > 0
 	}
 }
 
@@ -64,8 +90,16 @@ func (a *Agent) FilterTools(names []string) {
 	if len(names) == 0 {
 		return
 	}
-	allowed := make(map[string]bool, len(names))
-	for _, n := range names {
+	a.toolFilter = names
+	a.applyToolFilter()
+}
+
+func (a *Agent) applyToolFilter() {
+	if len(a.toolFilter) == 0 {
+		return
+	}
+	allowed := make(map[string]bool, len(a.toolFilter))
+	for _, n := range a.toolFilter {
 		allowed[n] = true
 	}
 	var filtered []llm.ToolDef
@@ -109,6 +143,37 @@ func (a *Agent) compactHistory(ctx context.Context) error {
 		return nil // nothing to compact
 	}
 
+	if err := a.summarizeBefore(ctx, splitIdx); err != nil {
+		// Fallback: simple trim, keep last few messages
+		a.trimHistory(10)
+	}
+	return nil
+}
+
+// Compact summarizes the conversation now, whatever its size, keeping the
+// latest exchange, from the last user message on, as it is. It reports
+// whether there was anything to summarize.
+func (a *Agent) Compact(ctx context.Context) (bool, error) {
+	splitIdx := 0
+	for i := len(a.history) - 1; i > 0; i-- {
+		if a.history[i].Role == llm.RoleUser {
+			splitIdx = i
+			break
+		}
+	}
+	// Only the system prompt, and maybe an earlier summary, come before it.
+	if splitIdx <= 1 || splitIdx == 2 && IsSummary(a.history[1]) {
+		return false, nil
+	}
+	if err := a.summarizeBefore(ctx, splitIdx); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// summarizeBefore replaces the messages between the system prompt and
+// splitIdx with a summary of them.
+func (a *Agent) summarizeBefore(ctx context.Context, splitIdx int) error {
 	// Old messages are indices 1 through splitIdx-1 (skip system prompt at 0)
 	oldMessages := a.history[1:splitIdx]
 	if len(oldMessages) == 0 {
@@ -121,18 +186,17 @@ func (a *Agent) compactHistory(ctx context.Context) error {
 	}
 	summary, err := summarizeMessages(ctx, summarizer, oldMessages)
 	if err != nil {
-		// Fallback: simple trim, keep last few messages
-		a.trimHistory(10)
-		return nil
+		return err
 	}
 
 	// Rebuild history: system prompt + summary + recent messages
-	summaryMsg := llm.SystemMessage("[Prior conversation summary]\n" + summary)
+	summaryMsg := llm.SystemMessage(summaryPrefix + summary)
 	newHistory := make([]llm.Message, 0, 2+len(a.history)-splitIdx)
 	newHistory = append(newHistory, a.history[0]) // system prompt
 	newHistory = append(newHistory, summaryMsg)
 	newHistory = append(newHistory, a.history[splitIdx:]...)
 	a.history = newHistory
+	a.rewritten = true
 
 	return nil
 }
@@ -140,14 +204,20 @@ func (a *Agent) compactHistory(ctx context.Context) error {
 // Run sends a user message and executes the full ReAct loop.
 // Returns the final assistant text response.
 func (a *Agent) Run(ctx context.Context, userMessage string) (string, error) {
+	a.RefreshTools()
 	a.compactHistory(ctx)
-	a.history = append(a.history, llm.UserMessage(userMessage))
+	a.history = append(a.history, stamped(llm.UserMessage(userMessage), time.Now()))
 
 	for i := 0; i < a.maxIter; i++ {
+		start := time.Now()
 		resp, err := a.llm.ChatCompletion(ctx, a.history, a.tools)
+		logLLMCall(ctx, i+1, start, resp, err)
 		if err != nil {
 			return "", fmt.Errorf("llm call (iteration %d): %w", i+1, err)
 		}
+		if resp.Message.Meta == nil {
+			resp.Message = stamped(resp.Message, start)
+		}
 
 		a.history = append(a.history, resp.Message)
 
@@ -162,13 +232,15 @@ func (a *Agent) Run(ctx context.Context, userMessage string) (string, error) {
 				a.OnToolCall(tc.Name, tc.Args)
 			}
 
+			start := time.Now()
 			result := a.executeTool(ctx, tc)
+			msg := stamped(llm.ToolResultMessage(tc.ID, result), start)
 
 			if a.OnToolResult != nil {
 				a.OnToolResult(tc.Name, result)
 			}
 
-			a.history = append(a.history, llm.ToolResultMessage(tc.ID, result))
+			a.history = append(a.history, msg)
 		}
 		// Loop back — LLM will see the tool results and decide next action
 	}
@@ -178,14 +250,20 @@ func (a *Agent) Run(ctx context.Context, userMessage string) (string, error) {
 
 // RunStreaming is like Run but streams text output token-by-token via OnTextDelta.
 func (a *Agent) RunStreaming(ctx context.Context, userMessage string) (string, error) {
+	a.RefreshTools()
 	a.compactHistory(ctx)
-	a.history = append(a.history, llm.UserMessage(userMessage))
+	a.history = append(a.history, stamped(llm.UserMessage(userMessage), time.Now()))
 
 	for i := 0; i < a.maxIter; i++ {
+		start := time.Now()
 		resp, err := a.llm.ChatCompletionStream(ctx, a.history, a.tools, a.OnTextDelta)
+		logLLMCall(ctx, i+1, start, resp, err)
 		if err != nil {
 			return "", fmt.Errorf("llm call (iteration %d): %w", i+1, err)
 		}
+		if resp.Message.Meta == nil {
+			resp.Message = stamped(resp.Message, start)
+		}
 
 		a.history = append(a.history, resp.Message)
 
@@ -198,90 +276,60 @@ func (a *Agent) RunStreaming(ctx context.Context, userMessage string) (string, e
 				a.OnToolCall(tc.Name, tc.Args)
 			}
 
+			start := time.Now()
 			result := a.executeTool(ctx, tc)
+			msg := stamped(llm.ToolResultMessage(tc.ID, result), start)
 
 			if a.OnToolResult != nil {
 				a.OnToolResult(tc.Name, result)
 			}
 
-			a.history = append(a.history, llm.ToolResultMessage(tc.ID, result))
+			a.history = append(a.history, msg)
 		}
 	}
 
 	return "", fmt.Errorf("agent reached max iterations (%d) without a final response", a.maxIter)
 }
 
-// executeTool dispatches a tool call to the registry or builtin handler.
-func (a *Agent) executeTool(ctx context.Context, tc llm.ToolCall) string {
-	// Try registry first
-	if a.registry != nil && a.registry.HasTools() {
-		result, err := a.registry.CallTool(ctx, tc.Name, tc.Args)
-		if err != nil {
-			return fmt.Sprintf("error: %s", err)
-		}
-		return result
-	}
-
-	// Builtin fallback
-	switch tc.Name {
-	case "shell_exec":
-		return a.toolShellExec(ctx, tc.Args)
-	default:
-		return fmt.Sprintf("error: unknown tool %q", tc.Name)
-	}
+// stamped returns m with metadata recording that it was created now, after
+// taking since start.
+func stamped(m llm.Message, start time.Time) llm.Message {
+	now := time.Now()
+	m.Meta = &llm.MessageMeta{CreatedAt: now.UTC(), LatencyMS: now.Sub(start).Milliseconds()}
+	return m
 }
 
-// toolShellExec runs a shell command and returns stdout+stderr.
-func (a *Agent) toolShellExec(ctx context.Context, args map[string]any) string {
-	command, ok := args["command"].(string)
-	if !ok {
-		return "error: 'command' argument must be a string"
+// executeTool dispatches a tool call to the registry or the built-in tool pack.
+func (a *Agent) executeTool(ctx context.Context, tc llm.ToolCall) string {
+	call := a.builtins.CallTool
+	if a.registry != nil && a.registry.HasTools() {
+		call = a.registry.CallTool
 	}
 
-	cmd := exec.CommandContext(ctx, "sh", "-c", command)
-
-	// Set working directory if provided
-	if workdir, ok := args["workdir"].(string); ok && workdir != "" {
-		cmd.Dir = workdir
+	if a.OnToolOutput != nil {
+		onOutput := a.OnToolOutput
+		ctx = tools.WithProgress(ctx, func(output string) { onOutput(tc.Name, output) })
 	}
 
-	output, err := cmd.CombinedOutput()
-	result := string(output)
+	start := time.Now()
+	result, err := call(tools.WithCallID(ctx, tc.ID), tc.Name, tc.Args)
+	logging.For("agent").DebugContext(ctx, "tool call", "session", tools.SessionFromContext(ctx),
+		"tool", tc.Name, "call_id", tc.ID, "duration", time.Since(start), "error", err)
 	if err != nil {
-		result += "\nexit error: " + err.Error()
+		return fmt.Sprintf("error: %s", err)
 	}
-
-	// Truncate very long outputs to keep context window manageable
-	const maxLen = 4000
-	if len(result) > maxLen {
-		result = result[:maxLen] + "\n... (output truncated)"
-	}
-
 	return result
 }
 
-// builtinTools returns the tool definitions for Phase 1 hardcoded tools.
-func (a *Agent) builtinTools() []llm.ToolDef {
-	return []llm.ToolDef{
-		{
-			Name:        "shell_exec",
-			Description: "Execute a shell command and return the combined stdout and stderr output. Use this to run system commands, check files, install packages, etc.",
-			Parameters: map[string]any{
-				"type": "object",
-				"properties": map[string]any{
-					"command": map[string]any{
-						"type":        "string",
-						"description": "The shell command to execute",
-					},
-					"workdir": map[string]any{
-						"type":        "string",
-						"description": "Working directory for the command (optional)",
-					},
-				},
-				"required": []string{"command"},
-			},
-		},
+// logLLMCall logs a call to the LLM, with how long it took and how many
+// tools the reply called.
+func logLLMCall(ctx context.Context, iteration int, start time.Time, resp *llm.Response, err error) {
+	calls := 0
+	if resp != nil {
+		calls = len(resp.Message.ToolCalls)
 	}
+	logging.For("agent").DebugContext(ctx, "llm call", "session", tools.SessionFromContext(ctx),
+		"iteration", iteration, "duration", time.Since(start), "tool_calls", calls, "error", err)
 }
 
 // History returns the current conversation history (for debugging/display).
@@ -304,16 +352,64 @@ func (a *Agent) trimHistory(keepLast int) {
 	system := a.history[0]
 	recent := a.history[len(a.history)-keepLast:]
 	a.history = append([]llm.Message{system}, recent...)
+	a.rewritten = true
 }
 
 // SetHistory replaces the conversation history (used when resuming a session).
+// The messages are taken to be the ones already persisted.
 func (a *Agent) SetHistory(messages []llm.Message) {
 	a.history = messages
+	a.MarkSaved()
+}
+
+// LastTurn returns the messages that followed the latest user message: the
+// model's replies and the results of the tools they called.
+func (a *Agent) LastTurn() []llm.Message {
+	for i := len(a.history) - 1; i > 0; i-- {
+		if a.history[i].Role == llm.RoleUser {
+			return a.history[i+1:]
+		}
+	}
+	return nil
+}
+
+// Undo removes the last user message and everything after it, the reply
+// and any tool calls, and returns that message. ok is false if there is
+// no user message to remove.
+func (a *Agent) Undo() (userMessage string, ok bool) {
+	for i := len(a.history) - 1; i > 0; i-- {
+		if a.history[i].Role != llm.RoleUser {
+			continue
+		}
+		userMessage = a.history[i].Content
+		a.history = a.history[:i]
+		a.rewritten = a.rewritten || i < a.saved
+		return userMessage, true
+	}
+	return "", false
 }
 
 // Reset clears conversation history (keeps system prompt).
 func (a *Agent) Reset() {
 	a.history = a.history[:1]
+	a.rewritten = a.rewritten || a.saved > 1
+}
+
+// UnsavedHistory returns the messages added since the last MarkSaved. If the
+// history was compacted, reset, or otherwise rewritten in the meantime, it
+// returns the whole history with rewrite set, and the stored copy should be
+// replaced rather than appended to.
+func (a *Agent) UnsavedHistory() (messages []llm.Message, rewrite bool) {
+	if a.rewritten || a.saved > len(a.history) {
+		return a.history, true
+	}
+	return a.history[a.saved:], false
+}
+
+// MarkSaved records that the whole history has been persisted.
+func (a *Agent) MarkSaved() {
+	a.saved = len(a.history)
+	a.rewritten = false
 }
 
 // String returns a summary of the agent state.
@@ -0,0 +1,212 @@
+package agent
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/michaelbrown/forge/internal/llm"
+	"github.com/michaelbrown/forge/internal/tools"
+)
+
+func toolNames(defs []llm.ToolDef) []string {
+	names := make([]string, len(defs))
+	for i, d := range defs {
+		names[i] = d.Name
+	}
+	return names
+}
+
+func TestNew_BuiltinToolsWithoutRegistry(t *testing.T) {
+	a := New(nil, tools.NewRegistry(), 5)
+
+	names := strings.Join(toolNames(a.tools), ",")
+	for _, want := range []string{"shell_exec", "file_read", "grep", "glob", "http_fetch"} {
+		if !strings.Contains(names, want) {
+			t.Errorf("expected built-in tool %s, got %s", want, names)
+		}
+	}
+
+	result := a.executeTool(context.Background(), llm.ToolCall{Name: "shell_exec", Args: map[string]any{"command": "echo hi"}})
+	if !strings.Contains(result, "hi") {
+		t.Errorf("shell_exec result = %q", result)
+	}
+}
+
+func TestFilterTools_SurvivesRefresh(t *testing.T) {
+	a := New(nil, nil, 5)
+	a.FilterTools([]string{"file_read", "grep"})
+	a.RefreshTools()
+
+	if got := strings.Join(toolNames(a.tools), ","); got != "file_read,grep" {
+		t.Errorf("tools after refresh = %s, want file_read,grep", got)
+	}
+}
+
+func TestUnsavedHistory(t *testing.T) {
+	a := New(nil, nil, 5)
+	a.history = append(a.history, llm.UserMessage("hi"), llm.AssistantMessage("hello"))
+
+	// A new agent's whole history is unsaved, but needs no rewrite.
+	if msgs, rewrite := a.UnsavedHistory(); len(msgs) != 3 || rewrite {
+		t.Fatalf("new agent: %d messages, rewrite %v", len(msgs), rewrite)
+	}
+	a.MarkSaved()
+	if msgs, rewrite := a.UnsavedHistory(); len(msgs) != 0 || rewrite {
+		t.Fatalf("after save: %d messages, rewrite %v", len(msgs), rewrite)
+	}
+
+	a.history = append(a.history, llm.UserMessage("again"))
+	if msgs, rewrite := a.UnsavedHistory(); len(msgs) != 1 || msgs[0].Content != "again" || rewrite {
+		t.Errorf("after a turn: %+v, rewrite %v", msgs, rewrite)
+	}
+
+	// Changing saved messages means the stored history must be replaced.
+	a.SetSystemPrompt("be brief")
+	if msgs, rewrite := a.UnsavedHistory(); len(msgs) != 4 || !rewrite {
+		t.Errorf("after SetSystemPrompt: %d messages, rewrite %v", len(msgs), rewrite)
+	}
+	a.MarkSaved()
+	a.Reset()
+	if msgs, rewrite := a.UnsavedHistory(); len(msgs) != 1 || !rewrite {
+		t.Errorf("after Reset: %d messages, rewrite %v", len(msgs), rewrite)
+	}
+
+	// A resumed history is already saved.
+	a.SetHistory([]llm.Message{llm.SystemMessage("s"), llm.UserMessage("u")})
+	if msgs, rewrite := a.UnsavedHistory(); len(msgs) != 0 || rewrite {
+		t.Errorf("after SetHistory: %d messages, rewrite %v", len(msgs), rewrite)
+	}
+}
+
+func TestAppendSystemPrompt(t *testing.T) {
+	a := New(nil, nil, 5)
+	a.AppendSystemPrompt("")
+	if a.history[0].Content != defaultSystemPrompt {
+		t.Errorf("appending nothing changed the prompt to %q", a.history[0].Content)
+	}
+	a.SetSystemPrompt("You review code.")
+	a.AppendSystemPrompt("Use tabs.")
+	if got := a.history[0].Content; got != "You review code.\n\nUse tabs." {
+		t.Errorf("system prompt = %q", got)
+	}
+}
+
+func TestLastTurn(t *testing.T) {
+	a := New(nil, nil, 5)
+	if turn := a.LastTurn(); len(turn) != 0 {
+		t.Fatalf("LastTurn with no messages = %v", turn)
+	}
+	a.history = append(a.history,
+		llm.UserMessage("first"), llm.AssistantMessage("one"),
+		llm.UserMessage("second"),
+		llm.Message{Role: llm.RoleAssistant, ToolCalls: []llm.ToolCall{{ID: "c1", Name: "grep"}}},
+		llm.ToolResultMessage("c1", "found"),
+		llm.AssistantMessage("two"))
+	turn := a.LastTurn()
+	if len(turn) != 3 || len(turn[0].ToolCalls) != 1 || turn[2].Content != "two" {
+		t.Errorf("LastTurn = %+v", turn)
+	}
+}
+
+func TestUndo(t *testing.T) {
+	a := New(nil, nil, 5)
+	if _, ok := a.Undo(); ok {
+		t.Fatal("Undo with no messages succeeded")
+	}
+	a.history = append(a.history,
+		llm.UserMessage("first"), llm.AssistantMessage("one"),
+		llm.UserMessage("second"),
+		llm.Message{Role: llm.RoleAssistant, ToolCalls: []llm.ToolCall{{ID: "c1", Name: "grep"}}},
+		llm.ToolResultMessage("c1", "found"),
+		llm.AssistantMessage("two"))
+	a.MarkSaved()
+
+	msg, ok := a.Undo()
+	if !ok || msg != "second" || len(a.history) != 3 {
+		t.Fatalf("Undo = %q, %v, leaving %d messages; want second, true, 3", msg, ok, len(a.history))
+	}
+	// The stored copy still has the removed messages.
+	if msgs, rewrite := a.UnsavedHistory(); len(msgs) != 3 || !rewrite {
+		t.Errorf("after Undo: %d messages, rewrite %v", len(msgs), rewrite)
+	}
+
+	a.MarkSaved()
+	a.history = append(a.history, llm.UserMessage("unsaved"))
+	if msg, _ := a.Undo(); msg != "unsaved" {
+		t.Fatalf("Undo = %q, want unsaved", msg)
+	}
+	if msgs, rewrite := a.UnsavedHistory(); len(msgs) != 0 || rewrite {
+		t.Errorf("undoing an unsaved message: %d messages, rewrite %v", len(msgs), rewrite)
+	}
+}
+
+// scriptedClient replies with each of its responses in turn, keeping the
+// last messages it was sent.
+type scriptedClient struct {
+	replies []llm.Message
+	sent    []llm.Message
+}
+
+func (c *scriptedClient) ChatCompletion(ctx context.Context, messages []llm.Message, tools []llm.ToolDef) (*llm.Response, error) {
+	c.sent = messages
+	reply := c.replies[0]
+	c.replies = c.replies[1:]
+	return &llm.Response{Message: reply}, nil
+}
+
+func (c *scriptedClient) ChatCompletionStream(ctx context.Context, messages []llm.Message, tools []llm.ToolDef, handler llm.StreamHandler) (*llm.Response, error) {
+	return c.ChatCompletion(ctx, messages, tools)
+}
+
+func TestRunRecordsMessageMeta(t *testing.T) {
+	fromProvider := &llm.MessageMeta{Model: "big-model", LatencyMS: 1500, PromptTokens: 120, CompletionTokens: 8}
+	client := &scriptedClient{replies: []llm.Message{
+		{Role: llm.RoleAssistant, ToolCalls: []llm.ToolCall{{ID: "1", Name: "shell_exec", Args: map[string]any{"command": "echo hi"}}}},
+		{Role: llm.RoleAssistant, Content: "It printed hi.", Meta: fromProvider},
+	}}
+	a := New(client, nil, 5)
+
+	before := time.Now().UTC().Add(-time.Second)
+	if _, err := a.Run(context.Background(), "run echo"); err != nil {
+		t.Fatal(err)
+	}
+
+	history := a.History()
+	if len(history) != 5 {
+		t.Fatalf("history has %d messages, want 5", len(history))
+	}
+	if history[0].Meta != nil {
+		t.Errorf("system prompt has meta %+v", history[0].Meta)
+	}
+	for i, m := range history[1:4] {
+		if m.Meta == nil || m.Meta.CreatedAt.Before(before) {
+			t.Errorf("message %d (%s) meta = %+v, want a creation time", i+1, m.Role, m.Meta)
+		}
+	}
+	if history[4].Meta != fromProvider {
+		t.Errorf("reply meta = %+v, want the provider's", history[4].Meta)
+	}
+}
+
+func TestRunPassesToolCallIDs(t *testing.T) {
+	registry := tools.NewRegistry()
+	defer registry.Close()
+	if err := registry.Add("builtin", tools.NewBuiltinServer()); err != nil {
+		t.Fatal(err)
+	}
+	var callIDs []string
+	registry.OnCall(func(rec tools.CallRecord) { callIDs = append(callIDs, rec.CallID) })
+
+	client := &scriptedClient{replies: []llm.Message{
+		{Role: llm.RoleAssistant, ToolCalls: []llm.ToolCall{{ID: "call_7", Name: "shell_exec", Args: map[string]any{"command": "echo hi"}}}},
+		{Role: llm.RoleAssistant, Content: "done"},
+	}}
+	if _, err := New(client, registry, 5).Run(context.Background(), "run echo"); err != nil {
+		t.Fatal(err)
+	}
+	if len(callIDs) != 1 || callIDs[0] != "call_7" {
+		t.Errorf("recorded call IDs = %v, want [call_7]", callIDs)
+	}
+}
@@ -4,25 +4,66 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"strings"
 
 	"github.com/michaelbrown/forge/internal/llm"
 )
 
-// estimateTokens returns an approximate token count for a message.
-// Uses chars/4 heuristic — accurate enough for context management.
-func estimateTokens(m llm.Message) int {
-	tokens := len(m.Content) / 4
-	for _, tc := range m.ToolCalls {
-		tokens += len(tc.Name) / 4
-		if argsJSON, err := json.Marshal(tc.Args); err == nil {
-			tokens += len(argsJSON) / 4
+// summaryPrefix starts the system message that stands in for compacted
+// messages.
+const summaryPrefix = "[Prior conversation summary]\n"
+
+// IsSummary reports whether m is the summary compaction put in place of
+// older messages.
+func IsSummary(m llm.Message) bool {
+	return m.Role == llm.RoleSystem && strings.HasPrefix(m.Content, summaryPrefix)
+}
+
+// SummaryText returns the text of a summary message.
+func SummaryText(m llm.Message) string {
+	return strings.TrimPrefix(m.Content, summaryPrefix)
+}
+
+// ContextUsage is an estimate of the tokens in the context window, by kind
+// of message, and the budget above which older messages are compacted.
+type ContextUsage struct {
+	Budget      int
+	System      int // the system prompt
+	Summary     int // the summary of compacted messages, if any
+	User        int
+	Assistant   int // replies, with their tool calls
+	ToolResults int
+}
+
+// Total returns the estimated tokens in the window.
+func (u ContextUsage) Total() int {
+	return u.System + u.Summary + u.User + u.Assistant + u.ToolResults
+}
+
+// ContextUsage estimates the tokens in the current history.
+func (a *Agent) ContextUsage() ContextUsage {
+	u := ContextUsage{Budget: a.maxTokens}
+	for i, m := range a.history {
+		tokens := estimateTokens(m)
+		switch {
+		case i == 0:
+			u.System += tokens
+		case IsSummary(m):
+			u.Summary += tokens
+		case m.Role == llm.RoleUser:
+			u.User += tokens
+		case m.Role == llm.RoleTool:
+			u.ToolResults += tokens
+		default:
+			u.Assistant += tokens
 		}
 	}
-	// Minimum 1 token per message for role overhead
-	if tokens == 0 {
-		tokens = 1
-	}
-	return tokens
+	return u
+}
+
+// estimateTokens returns an approximate token count for a message.
+func estimateTokens(m llm.Message) int {
+	return llm.EstimateTokens(m)
 }
 
 // estimateHistoryTokens returns approximate total tokens for a message slice.
@@ -263,3 +263,80 @@ func TestCompactHistoryFallbackOnError(t *testing.T) {
 		t.Errorf("expected trimmed history, got same length %d", len(a.history))
 	}
 }
+
+func TestCompact(t *testing.T) {
+	mock := &mockClient{responses: []llm.Response{
+		{Message: llm.AssistantMessage("User said hi twice.")},
+	}}
+	a := &Agent{
+		llm:       mock,
+		maxTokens: 10000, // well under budget: Compact doesn't wait for it
+		history: []llm.Message{
+			llm.SystemMessage("system"),
+			llm.UserMessage("hi"),
+			llm.AssistantMessage("hello"),
+			llm.UserMessage("hi again"),
+			llm.AssistantMessage("hello again"),
+		},
+	}
+	a.MarkSaved()
+
+	compacted, err := a.Compact(context.Background())
+	if err != nil || !compacted {
+		t.Fatalf("Compact() = %v, %v; want true, nil", compacted, err)
+	}
+	if len(a.history) != 4 || !IsSummary(a.history[1]) || SummaryText(a.history[1]) != "User said hi twice." {
+		t.Fatalf("history after Compact = %+v", a.history)
+	}
+	if a.history[2].Content != "hi again" {
+		t.Errorf("latest exchange should be kept, got %q", a.history[2].Content)
+	}
+	if _, rewrite := a.UnsavedHistory(); !rewrite {
+		t.Error("Compact should mark the history rewritten")
+	}
+
+	// Only the summary and the latest exchange are left.
+	if compacted, err := a.Compact(context.Background()); compacted || err != nil {
+		t.Errorf("second Compact() = %v, %v; want false, nil", compacted, err)
+	}
+}
+
+func TestCompactError(t *testing.T) {
+	a := &Agent{
+		llm: &mockClient{},
+		history: []llm.Message{
+			llm.SystemMessage("system"),
+			llm.UserMessage("q1"),
+			llm.AssistantMessage("a1"),
+			llm.UserMessage("q2"),
+		},
+	}
+	if _, err := a.Compact(context.Background()); err == nil {
+		t.Fatal("Compact() should return the summarizer's error")
+	}
+	if len(a.history) != 4 {
+		t.Errorf("history changed on error: %d messages", len(a.history))
+	}
+}
+
+func TestContextUsage(t *testing.T) {
+	a := &Agent{
+		maxTokens: 6000,
+		history: []llm.Message{
+			llm.SystemMessage(strings.Repeat("s", 40)),
+			llm.SystemMessage(summaryPrefix + strings.Repeat("m", 80)),
+			llm.UserMessage(strings.Repeat("u", 20)),
+			{Role: llm.RoleAssistant, ToolCalls: []llm.ToolCall{{ID: "1", Name: "grep"}}},
+			llm.ToolResultMessage("1", strings.Repeat("r", 60)),
+			llm.AssistantMessage(strings.Repeat("a", 12)),
+		},
+	}
+	u := a.ContextUsage()
+	want := ContextUsage{Budget: 6000, System: 10, Summary: 27, User: 5, Assistant: 5, ToolResults: 15}
+	if u != want {
+		t.Errorf("ContextUsage() = %+v, want %+v", u, want)
+	}
+	if u.Total() != 62 {
+		t.Errorf("Total() = %d, want 62", u.Total())
+	}
+}
@@ -3,18 +3,19 @@ package agent
 import (
 	"fmt"
 	"os"
+	"path/filepath"
 
 	"gopkg.in/yaml.v3"
 )
 
 // Profile defines an agent's personality and capabilities.
 type Profile struct {
-	Name         string   `yaml:"name"`
-	Provider     string   `yaml:"provider"`
-	Model        string   `yaml:"model"`
-	SystemPrompt string   `yaml:"system_prompt"`
-	Tools        []string `yaml:"tools"`
-	MaxIter      int      `yaml:"max_iterations"`
+	Name         string   `yaml:"name" json:"name"`
+	Provider     string   `yaml:"provider,omitempty" json:"provider,omitempty"`
+	Model        string   `yaml:"model,omitempty" json:"model,omitempty"`
+	SystemPrompt string   `yaml:"system_prompt,omitempty" json:"system_prompt,omitempty"`
+	Tools        []string `yaml:"tools,omitempty" json:"tools,omitempty"`
+	MaxIter      int      `yaml:"max_iterations,omitempty" json:"max_iterations,omitempty"`
 }
 
 // LoadProfile reads an agent profile from a YAML file.
@@ -31,3 +32,19 @@ func LoadProfile(path string) (*Profile, error) {
 
 	return &p, nil
 }
+
+// SaveProfile writes an agent profile to a YAML file, creating its
+// directory if needed.
+func SaveProfile(path string, p *Profile) error {
+	data, err := yaml.Marshal(p)
+	if err != nil {
+		return fmt.Errorf("encoding profile: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("creating profiles directory: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		return fmt.Errorf("writing profile %s: %w", path, err)
+	}
+	return nil
+}
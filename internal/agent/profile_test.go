@@ -0,0 +1,35 @@
+package agent
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSaveProfile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "profiles", "coder.yaml")
+	want := &Profile{
+		Name:         "coder",
+		Provider:     "ollama",
+		SystemPrompt: "You write Go.",
+		Tools:        []string{"shell_exec", "file_read"},
+		MaxIter:      5,
+	}
+	if err := SaveProfile(path, want); err != nil {
+		t.Fatal(err)
+	}
+
+	data, _ := os.ReadFile(path)
+	if strings.Contains(string(data), "model:") {
+		t.Errorf("empty fields were written:\n%s", data)
+	}
+	got, err := LoadProfile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("loaded %+v, want %+v", got, want)
+	}
+}
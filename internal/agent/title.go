@@ -0,0 +1,83 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/michaelbrown/forge/internal/llm"
+)
+
+const maxTitleLen = 80
+
+// UtilityLLM returns the client set with SetUtilityLLM, or nil.
+func (a *Agent) UtilityLLM() llm.Client {
+	return a.utilityLLM
+}
+
+// GenerateTitle asks client for a short title describing a conversation,
+// from its first user message and the reply to it.
+func GenerateTitle(ctx context.Context, client llm.Client, messages []llm.Message) (string, error) {
+	var question, answer string
+	for _, m := range messages {
+		switch {
+		case m.Role == llm.RoleUser && question == "":
+			question = m.Content
+		case m.Role == llm.RoleAssistant && question != "" && m.Content != "":
+			answer = m.Content
+		}
+		if answer != "" {
+			break
+		}
+	}
+	if question == "" {
+		return "", fmt.Errorf("no user message to title")
+	}
+
+	prompt := []llm.Message{
+		llm.SystemMessage("You write titles for conversations. Reply with a title of at most eight words that says what the conversation is about. " +
+			"Output only the title, without quotes or a trailing period."),
+		llm.UserMessage(fmt.Sprintf("User: %s\n\nAssistant: %s", clip(question, 2000), clip(answer, 2000))),
+	}
+	resp, err := client.ChatCompletion(ctx, prompt, nil)
+	if err != nil {
+		return "", fmt.Errorf("title LLM call: %w", err)
+	}
+	title := cleanTitle(resp.Message.Content)
+	if title == "" {
+		return "", fmt.Errorf("title LLM returned no title")
+	}
+	return title, nil
+}
+
+// cleanTitle takes the first line of a model's reply and strips the
+// decoration models tend to add.
+func cleanTitle(s string) string {
+	lines := strings.Split(s, "\n")
+	s = ""
+	for _, line := range lines {
+		if line = strings.TrimSpace(line); line != "" {
+			s = line
+			break
+		}
+	}
+	const decoration = " \t\"'`*#"
+	s = strings.Trim(s, decoration)
+	if len(s) >= 6 && strings.EqualFold(s[:6], "title:") {
+		s = strings.Trim(s[6:], decoration)
+	}
+	s = strings.TrimSuffix(s, ".")
+	if utf8.RuneCountInString(s) > maxTitleLen {
+		s = string([]rune(s)[:maxTitleLen-3]) + "..."
+	}
+	return s
+}
+
+// clip shortens s to at most n bytes.
+func clip(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n] + "..."
+}
@@ -0,0 +1,50 @@
+package agent
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/michaelbrown/forge/internal/llm"
+)
+
+func TestGenerateTitle(t *testing.T) {
+	client := &scriptedClient{replies: []llm.Message{{Role: llm.RoleAssistant, Content: "\n  Title: \"Fixing the flaky CI cache.\"\nBecause..."}}}
+	messages := []llm.Message{
+		llm.SystemMessage("system"),
+		llm.UserMessage("why does CI keep failing on cache restore?"),
+		{Role: llm.RoleAssistant, ToolCalls: []llm.ToolCall{{ID: "1", Name: "shell_exec"}}},
+		llm.ToolResultMessage("1", "cache miss"),
+		{Role: llm.RoleAssistant, Content: "The cache key includes the date."},
+	}
+
+	title, err := GenerateTitle(context.Background(), client, messages)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if title != "Fixing the flaky CI cache" {
+		t.Errorf("title = %q", title)
+	}
+	prompt := client.sent[len(client.sent)-1].Content
+	if !strings.Contains(prompt, "cache restore?") || !strings.Contains(prompt, "includes the date") || strings.Contains(prompt, "cache miss") {
+		t.Errorf("prompt = %q", prompt)
+	}
+
+	if _, err := GenerateTitle(context.Background(), client, messages[:1]); err == nil {
+		t.Error("expected error without a user message")
+	}
+}
+
+func TestCleanTitle(t *testing.T) {
+	for in, want := range map[string]string{
+		"Deploy notes":              "Deploy notes",
+		"**Debugging Go tests**":    "Debugging Go tests",
+		"title: `Kafka lag`":        "Kafka lag",
+		"  \n\n":                    "",
+		strings.Repeat("long ", 30): strings.Repeat("long ", 15) + "lo...",
+	} {
+		if got := cleanTitle(in); got != want {
+			t.Errorf("cleanTitle(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
@@ -0,0 +1,271 @@
+package backup
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sync"
+	"time"
+)
+
+// ErrNothingToUndo is returned when a session has no recorded changes.
+var ErrNothingToUndo = errors.New("nothing to undo")
+
+// Entry records the state of a file just before a tool changed it.
+type Entry struct {
+	Path    string      `json:"path"`
+	Existed bool        `json:"existed"`
+	Hash    string      `json:"hash,omitempty"`
+	Mode    os.FileMode `json:"mode,omitempty"`
+	Tool    string      `json:"tool,omitempty"`
+	Time    time.Time   `json:"time"`
+}
+
+// Store keeps content-addressed snapshots of files before tools modify them,
+// grouped into per-session journals so an agent's changes can be undone one
+// at a time or reverted as a whole. It is safe for concurrent use within one
+// process.
+//
+// Layout under the store directory:
+//
+//	objects/<sha256>         file contents, shared across sessions
+//	sessions/<session>.jsonl one Entry per modification, oldest first
+type Store struct {
+	dir string
+	mu  sync.Mutex
+}
+
+// DefaultDir returns ~/.forge/backups.
+func DefaultDir() string {
+	home, _ := os.UserHomeDir()
+	return filepath.Join(home, ".forge", "backups")
+}
+
+// New returns a Store rooted at dir. The directory is created on first use.
+func New(dir string) *Store {
+	return &Store{dir: dir}
+}
+
+// Snapshot records the current state of path (including whether it exists)
+// in the session's journal. Call it before modifying the file.
+func (s *Store) Snapshot(session, path, tool string) error {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return err
+	}
+
+	e := Entry{Path: abs, Tool: tool, Time: time.Now().UTC()}
+	info, err := os.Stat(abs)
+	switch {
+	case err == nil:
+		data, err := os.ReadFile(abs)
+		if err != nil {
+			return fmt.Errorf("reading %s for backup: %w", path, err)
+		}
+		e.Existed = true
+		e.Mode = info.Mode().Perm()
+		if e.Hash, err = s.putObject(data); err != nil {
+			return err
+		}
+	case !os.IsNotExist(err):
+		return fmt.Errorf("backing up %s: %w", path, err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entries, err := s.load(session)
+	if err != nil {
+		return err
+	}
+	return s.save(session, append(entries, e))
+}
+
+// Entries returns the session's journal, oldest first.
+func (s *Store) Entries(session string) ([]Entry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.load(session)
+}
+
+// Undo restores the most recent change in the session (limited to path if
+// non-empty) and removes it from the journal.
+func (s *Store) Undo(session, path string) (*Entry, error) {
+	var abs string
+	if path != "" {
+		var err error
+		if abs, err = filepath.Abs(path); err != nil {
+			return nil, err
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entries, err := s.load(session)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := len(entries) - 1; i >= 0; i-- {
+		e := entries[i]
+		if abs != "" && e.Path != abs {
+			continue
+		}
+		if err := s.restore(e); err != nil {
+			return nil, err
+		}
+		entries = append(entries[:i], entries[i+1:]...)
+		if err := s.save(session, entries); err != nil {
+			return nil, err
+		}
+		return &e, nil
+	}
+	return nil, ErrNothingToUndo
+}
+
+// Revert restores every file the session changed to its state before the
+// session's first change, then clears the journal. It returns one entry per
+// restored file.
+func (s *Store) Revert(session string) ([]Entry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entries, err := s.load(session)
+	if err != nil {
+		return nil, err
+	}
+	if len(entries) == 0 {
+		return nil, ErrNothingToUndo
+	}
+
+	// The earliest entry per path holds the original state.
+	seen := make(map[string]bool)
+	var originals []Entry
+	for _, e := range entries {
+		if !seen[e.Path] {
+			seen[e.Path] = true
+			originals = append(originals, e)
+		}
+	}
+
+	for _, e := range originals {
+		if err := s.restore(e); err != nil {
+			return nil, err
+		}
+	}
+	if err := os.Remove(s.journalPath(session)); err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	return originals, nil
+}
+
+// restore puts a file back to the state recorded in e.
+func (s *Store) restore(e Entry) error {
+	if !e.Existed {
+		if err := os.Remove(e.Path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("removing %s: %w", e.Path, err)
+		}
+		return nil
+	}
+
+	data, err := os.ReadFile(filepath.Join(s.dir, "objects", e.Hash))
+	if err != nil {
+		return fmt.Errorf("reading backup of %s: %w", e.Path, err)
+	}
+	if err := os.MkdirAll(filepath.Dir(e.Path), 0o755); err != nil {
+		return err
+	}
+	mode := e.Mode
+	if mode == 0 {
+		mode = 0o644
+	}
+	if err := os.WriteFile(e.Path, data, mode); err != nil {
+		return fmt.Errorf("restoring %s: %w", e.Path, err)
+	}
+	return nil
+}
+
+// putObject stores data under its sha256 and returns the hash.
+func (s *Store) putObject(data []byte) (string, error) {
+	sum := sha256.Sum256(data)
+	hash := hex.EncodeToString(sum[:])
+
+	dir := filepath.Join(s.dir, "objects")
+	path := filepath.Join(dir, hash)
+	if _, err := os.Stat(path); err == nil {
+		return hash, nil
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return "", fmt.Errorf("creating backup dir: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		return "", fmt.Errorf("writing backup: %w", err)
+	}
+	return hash, nil
+}
+
+var unsafeName = regexp.MustCompile(`[^A-Za-z0-9_.-]`)
+
+func (s *Store) journalPath(session string) string {
+	if session == "" {
+		session = "default"
+	}
+	return filepath.Join(s.dir, "sessions", unsafeName.ReplaceAllString(session, "_")+".jsonl")
+}
+
+func (s *Store) load(session string) ([]Entry, error) {
+	f, err := os.Open(s.journalPath(session))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var entries []Entry
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var e Entry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			return nil, fmt.Errorf("parsing backup journal: %w", err)
+		}
+		entries = append(entries, e)
+	}
+	return entries, scanner.Err()
+}
+
+func (s *Store) save(session string, entries []Entry) error {
+	path := s.journalPath(session)
+	if len(entries) == 0 {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return fmt.Errorf("creating backup dir: %w", err)
+	}
+
+	var buf []byte
+	for _, e := range entries {
+		line, err := json.Marshal(e)
+		if err != nil {
+			return err
+		}
+		buf = append(append(buf, line...), '\n')
+	}
+	// Write then rename so a crash never leaves a half-written journal.
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, buf, 0o600); err != nil {
+		return fmt.Errorf("writing backup journal: %w", err)
+	}
+	return os.Rename(tmp, path)
+}
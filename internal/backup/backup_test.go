@@ -0,0 +1,133 @@
+package backup
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func readFile(t *testing.T, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestUndo(t *testing.T) {
+	s := New(t.TempDir())
+	dir := t.TempDir()
+	a := filepath.Join(dir, "a.txt")
+	b := filepath.Join(dir, "b.txt")
+
+	os.WriteFile(a, []byte("v1"), 0o600)
+	s.Snapshot("sess", a, "file_write")
+	os.WriteFile(a, []byte("v2"), 0o600)
+	s.Snapshot("sess", a, "file_patch")
+	os.WriteFile(a, []byte("v3"), 0o600)
+
+	// b did not exist before the session touched it
+	s.Snapshot("sess", b, "file_write")
+	os.WriteFile(b, []byte("new"), 0o644)
+
+	e, err := s.Undo("sess", "")
+	if err != nil {
+		t.Fatalf("Undo: %v", err)
+	}
+	if e.Path != b || e.Existed {
+		t.Errorf("undo entry = %+v", e)
+	}
+	if _, err := os.Stat(b); !os.IsNotExist(err) {
+		t.Error("undoing a create should remove the file")
+	}
+
+	e, err = s.Undo("sess", a)
+	if err != nil || e.Tool != "file_patch" {
+		t.Fatalf("Undo a: %+v, %v", e, err)
+	}
+	if got := readFile(t, a); got != "v2" {
+		t.Errorf("after one undo a = %q, want v2", got)
+	}
+
+	s.Undo("sess", a)
+	if got := readFile(t, a); got != "v1" {
+		t.Errorf("after two undos a = %q, want v1", got)
+	}
+	info, _ := os.Stat(a)
+	if info.Mode().Perm() != 0o600 {
+		t.Errorf("mode = %v, want 0600", info.Mode().Perm())
+	}
+
+	if _, err := s.Undo("sess", ""); !errors.Is(err, ErrNothingToUndo) {
+		t.Errorf("expected ErrNothingToUndo, got %v", err)
+	}
+}
+
+func TestRevert(t *testing.T) {
+	s := New(t.TempDir())
+	dir := t.TempDir()
+	a := filepath.Join(dir, "a.txt")
+	created := filepath.Join(dir, "sub", "created.txt")
+
+	os.WriteFile(a, []byte("original"), 0o644)
+	for _, v := range []string{"one", "two", "three"} {
+		s.Snapshot("sess", a, "file_write")
+		os.WriteFile(a, []byte(v), 0o644)
+	}
+	s.Snapshot("sess", created, "file_write")
+	os.MkdirAll(filepath.Dir(created), 0o755)
+	os.WriteFile(created, []byte("x"), 0o644)
+
+	// Another session's changes are untouched.
+	other := filepath.Join(dir, "other.txt")
+	s.Snapshot("other", other, "file_write")
+	os.WriteFile(other, []byte("keep"), 0o644)
+
+	restored, err := s.Revert("sess")
+	if err != nil {
+		t.Fatalf("Revert: %v", err)
+	}
+	if len(restored) != 2 {
+		t.Errorf("restored %d files, want 2", len(restored))
+	}
+	if got := readFile(t, a); got != "original" {
+		t.Errorf("a = %q, want original", got)
+	}
+	if _, err := os.Stat(created); !os.IsNotExist(err) {
+		t.Error("file created in the session should be removed")
+	}
+	if got := readFile(t, other); got != "keep" {
+		t.Errorf("other session's file = %q", got)
+	}
+
+	if entries, _ := s.Entries("sess"); len(entries) != 0 {
+		t.Errorf("journal not cleared: %+v", entries)
+	}
+	if entries, _ := s.Entries("other"); len(entries) != 1 {
+		t.Errorf("other journal = %+v", entries)
+	}
+	if _, err := s.Revert("sess"); !errors.Is(err, ErrNothingToUndo) {
+		t.Errorf("second revert: %v", err)
+	}
+}
+
+func TestSnapshot_DeduplicatesContent(t *testing.T) {
+	backupDir := t.TempDir()
+	s := New(backupDir)
+	dir := t.TempDir()
+
+	for _, name := range []string{"a.txt", "b.txt"} {
+		p := filepath.Join(dir, name)
+		os.WriteFile(p, []byte("same content"), 0o644)
+		if err := s.Snapshot("sess", p, "file_write"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	objects, _ := os.ReadDir(filepath.Join(backupDir, "objects"))
+	if len(objects) != 1 {
+		t.Errorf("got %d objects, want 1", len(objects))
+	}
+}
@@ -0,0 +1,245 @@
+// Package batch reads the task files forge batch runs: lists of prompts,
+// written out one by one or made from a template over the rows of a CSV or
+// JSON input, each run through the agent on its own.
+package batch
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// File is a task file.
+type File struct {
+	// Profile, Provider, and Model choose the agent, as the flags of the
+	// same names do; the flags win.
+	Profile  string `yaml:"profile"`
+	Provider string `yaml:"provider"`
+	Model    string `yaml:"model"`
+
+	Concurrency int           `yaml:"concurrency"` // items run at once; 0 is 1
+	Timeout     time.Duration `yaml:"timeout"`     // per item; 0 is no limit
+	Tags        []string      `yaml:"tags"`        // added to each item's session
+
+	// Prompt is the template for each row of Input, and for tasks without
+	// a prompt of their own.
+	Prompt string `yaml:"prompt"`
+	// Input is a CSV file, with a header row, or a JSON array or JSON
+	// Lines file of objects. Each row is an item, whose fields are the
+	// template's variables.
+	Input string `yaml:"input"`
+	// Output is the template for the file each item's answer is written
+	// to. Without one, answers are only kept in sessions and Results.
+	Output string `yaml:"output"`
+	// Results is a JSON Lines file to which the outcome of every item is
+	// appended.
+	Results string `yaml:"results"`
+
+	Tasks []Task `yaml:"tasks"`
+
+	dir string
+}
+
+// Task is an item listed in a task file.
+type Task struct {
+	Name   string         `yaml:"name"`
+	Prompt string         `yaml:"prompt"` // a template; "" uses the file's
+	Output string         `yaml:"output"` // a template; "" uses the file's
+	Vars   map[string]any `yaml:"vars"`
+}
+
+// Item is a prompt to run, with its templates filled in.
+type Item struct {
+	N      int // the item's place in the batch, from 1
+	Name   string
+	Prompt string
+	Output string // the file to write the answer to, or ""
+	Vars   map[string]any
+}
+
+// Load reads the task file at path. Unknown keys are errors, so typos
+// don't go unnoticed.
+func Load(path string) (*File, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var f File
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&f); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if f.dir, err = filepath.Abs(filepath.Dir(path)); err != nil {
+		return nil, err
+	}
+	switch {
+	case len(f.Tasks) == 0 && f.Input == "":
+		return nil, fmt.Errorf("%s: no tasks and no input", path)
+	case f.Input != "" && f.Prompt == "":
+		return nil, fmt.Errorf("%s: input needs a prompt to fill in with each row", path)
+	case f.Concurrency < 0:
+		return nil, fmt.Errorf("%s: concurrency must not be negative", path)
+	}
+	for i, t := range f.Tasks {
+		if t.Prompt == "" && f.Prompt == "" {
+			return nil, fmt.Errorf("%s: task %d has no prompt, and there is no file-wide one", path, i+1)
+		}
+	}
+	return &f, nil
+}
+
+// Dir returns the directory the file is in, which relative paths in it
+// are relative to.
+func (f *File) Dir() string {
+	return f.dir
+}
+
+// Path resolves a path given in the file.
+func (f *File) Path(path string) string {
+	if path == "" || filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(f.dir, path)
+}
+
+// Items returns the file's tasks, then a task for each row of its input,
+// with their templates filled in. Templates see the task's vars or the
+// row's fields, and the function n, the item's place in the batch.
+func (f *File) Items() ([]Item, error) {
+	rows, err := f.readInput()
+	if err != nil {
+		return nil, err
+	}
+	tasks := append([]Task(nil), f.Tasks...)
+	for _, row := range rows {
+		tasks = append(tasks, Task{Vars: row})
+	}
+
+	items := make([]Item, 0, len(tasks))
+	writers := map[string]int{}
+	for i, t := range tasks {
+		item := Item{N: i + 1, Name: t.Name, Vars: t.Vars}
+		if item.Vars == nil {
+			item.Vars = map[string]any{}
+		}
+		prompt, output := t.Prompt, t.Output
+		if prompt == "" {
+			prompt = f.Prompt
+		}
+		if output == "" {
+			output = f.Output
+		}
+		if item.Prompt, err = render(prompt, item); err != nil {
+			return nil, fmt.Errorf("item %d: prompt: %w", item.N, err)
+		}
+		if strings.TrimSpace(item.Prompt) == "" {
+			return nil, fmt.Errorf("item %d: the prompt is empty", item.N)
+		}
+		if output != "" {
+			if output, err = render(output, item); err != nil {
+				return nil, fmt.Errorf("item %d: output: %w", item.N, err)
+			}
+			item.Output = f.Path(output)
+			if other, ok := writers[item.Output]; ok {
+				return nil, fmt.Errorf("items %d and %d would both write %s", other, item.N, output)
+			}
+			writers[item.Output] = item.N
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+// render fills in the template text for item. A variable that item
+// doesn't have is an error, rather than "<no value>" in a prompt.
+func render(text string, item Item) (string, error) {
+	tmpl, err := template.New("").
+		Option("missingkey=error").
+		Funcs(template.FuncMap{"n": func() int { return item.N }}).
+		Parse(text)
+	if err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	if err := tmpl.Execute(&b, item.Vars); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+// readInput reads the rows of the input file, if there is one, by its
+// extension: .csv, .json, or .jsonl.
+func (f *File) readInput() ([]map[string]any, error) {
+	if f.Input == "" {
+		return nil, nil
+	}
+	path := f.Path(f.Input)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading input: %w", err)
+	}
+	var rows []map[string]any
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".csv":
+		rows, err = readCSV(data)
+	case ".json":
+		err = json.Unmarshal(data, &rows)
+	case ".jsonl", ".ndjson":
+		rows, err = readJSONLines(data)
+	default:
+		return nil, fmt.Errorf("input %s: unknown format %q; use .csv, .json, or .jsonl", f.Input, ext)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("input %s: %w", f.Input, err)
+	}
+	return rows, nil
+}
+
+func readCSV(data []byte) ([]map[string]any, error) {
+	records, err := csv.NewReader(bytes.NewReader(data)).ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, nil
+	}
+	header := records[0]
+	rows := make([]map[string]any, 0, len(records)-1)
+	for _, record := range records[1:] {
+		row := make(map[string]any, len(header))
+		for i, name := range header {
+			row[strings.TrimSpace(name)] = record[i]
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+func readJSONLines(data []byte) ([]map[string]any, error) {
+	var rows []map[string]any
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(nil, 16<<20)
+	for line := 1; scanner.Scan(); line++ {
+		if strings.TrimSpace(scanner.Text()) == "" {
+			continue
+		}
+		var row map[string]any
+		if err := json.Unmarshal(scanner.Bytes(), &row); err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		rows = append(rows, row)
+	}
+	return rows, scanner.Err()
+}
@@ -0,0 +1,124 @@
+package batch
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeFile(t *testing.T, dir, name, content string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadAndItems(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "reviews.csv", "id,text\n1,Great product\n2,\"Broke, twice\"\n")
+	path := writeFile(t, dir, "tasks.yaml", `
+profile: coder
+concurrency: 3
+timeout: 90s
+prompt: "Label the sentiment of: {{.text}}"
+input: reviews.csv
+output: "out/{{.id}}.txt"
+tasks:
+  - name: readme
+    prompt: "Summarize README.md as item {{n}}"
+    output: readme.md
+  - name: vars
+    vars: {text: from vars, id: v}
+`)
+	f, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.Profile != "coder" || f.Concurrency != 3 || f.Timeout != 90*time.Second {
+		t.Errorf("file = %+v", f)
+	}
+	items, err := f.Items()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Item{
+		{N: 1, Name: "readme", Prompt: "Summarize README.md as item 1", Output: filepath.Join(dir, "readme.md")},
+		{N: 2, Name: "vars", Prompt: "Label the sentiment of: from vars", Output: filepath.Join(dir, "out/v.txt")},
+		{N: 3, Prompt: "Label the sentiment of: Great product", Output: filepath.Join(dir, "out/1.txt")},
+		{N: 4, Prompt: "Label the sentiment of: Broke, twice", Output: filepath.Join(dir, "out/2.txt")},
+	}
+	if len(items) != len(want) {
+		t.Fatalf("got %d items: %+v", len(items), items)
+	}
+	for i, item := range items {
+		w := want[i]
+		if item.N != w.N || item.Name != w.Name || item.Prompt != w.Prompt || item.Output != w.Output {
+			t.Errorf("item %d = %+v, want %+v", i, item, w)
+		}
+	}
+	if items[2].Vars["id"] != "1" {
+		t.Errorf("row vars = %v", items[2].Vars)
+	}
+}
+
+func TestItemsJSONInput(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "rows.json", `[{"name": "a", "n": 1}, {"name": "b", "n": 2}]`)
+	writeFile(t, dir, "rows.jsonl", "{\"name\": \"c\"}\n\n{\"name\": \"d\"}\n")
+	for input, names := range map[string][]string{"rows.json": {"a", "b"}, "rows.jsonl": {"c", "d"}} {
+		path := writeFile(t, dir, "tasks.yaml", "prompt: 'Hello {{.name}}'\ninput: "+input+"\n")
+		f, err := Load(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		items, err := f.Items()
+		if err != nil {
+			t.Fatalf("%s: %v", input, err)
+		}
+		if len(items) != 2 || items[0].Prompt != "Hello "+names[0] || items[1].Prompt != "Hello "+names[1] {
+			t.Errorf("%s: items = %+v", input, items)
+		}
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	dir := t.TempDir()
+	for name, tc := range map[string]struct{ file, want string }{
+		"empty":           {"concurrency: 2\n", "no tasks and no input"},
+		"unknown key":     {"promt: hi\ntasks: [{prompt: x}]\n", "field promt not found"},
+		"input no prompt": {"input: rows.csv\n", "input needs a prompt"},
+		"task no prompt":  {"tasks: [{name: x}]\n", "task 1 has no prompt"},
+		"bad timeout":     {"timeout: soon\ntasks: [{prompt: x}]\n", "soon"},
+	} {
+		path := writeFile(t, dir, "tasks.yaml", tc.file)
+		if _, err := Load(path); err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("%s: err = %v, want %q", name, err, tc.want)
+		}
+	}
+}
+
+func TestItemsErrors(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "rows.csv", "id\n1\n1\n")
+	writeFile(t, dir, "rows.txt", "id\n")
+	for name, tc := range map[string]struct{ file, want string }{
+		"missing var":   {"tasks: [{prompt: '{{.nope}}'}]\n", `map has no entry for key "nope"`},
+		"bad template":  {"tasks: [{prompt: '{{.x'}]\n", "item 1: prompt"},
+		"same output":   {"prompt: x\ninput: rows.csv\noutput: 'out/{{.id}}'\n", "items 1 and 2 would both write out/1"},
+		"no input file": {"prompt: x\ninput: missing.csv\n", "reading input"},
+		"bad format":    {"prompt: x\ninput: rows.txt\n", `unknown format ".txt"`},
+	} {
+		path := writeFile(t, dir, "tasks.yaml", tc.file)
+		f, err := Load(path)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if _, err := f.Items(); err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("%s: err = %v, want %q", name, err, tc.want)
+		}
+	}
+}
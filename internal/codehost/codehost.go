@@ -0,0 +1,220 @@
+// Package codehost is a small client for code hosting services (GitLab,
+// Gitea) used by the forge tool servers. Results are normalized into shared
+// types so tools return the same JSON shape regardless of the host.
+package codehost
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Client reads and comments on issues and merge requests. Repositories are
+// identified by their path on the host, e.g. "group/subgroup/project".
+// Numbers are the per-repository numbers shown in the UI.
+type Client interface {
+	ListIssues(ctx context.Context, repo, state string, limit int) ([]Issue, error)
+	GetIssue(ctx context.Context, repo string, number int) (*Issue, error)
+	ListMergeRequests(ctx context.Context, repo, state string, limit int) ([]MergeRequest, error)
+	GetMergeRequest(ctx context.Context, repo string, number int) (*MergeRequest, error)
+	// MergeRequestDiff returns the merge request's changes as a unified diff.
+	MergeRequestDiff(ctx context.Context, repo string, number int) (string, error)
+	CommentOnIssue(ctx context.Context, repo string, number int, body string) (*Comment, error)
+	CommentOnMergeRequest(ctx context.Context, repo string, number int, body string) (*Comment, error)
+}
+
+// States accepted by the List methods. Merged applies to merge requests only.
+const (
+	StateOpen   = "open"
+	StateClosed = "closed"
+	StateMerged = "merged"
+	StateAll    = "all"
+)
+
+type User struct {
+	Login string `json:"login"`
+}
+
+type Issue struct {
+	Number    int       `json:"number"`
+	Title     string    `json:"title"`
+	State     string    `json:"state"`
+	Body      string    `json:"body,omitempty"`
+	Author    *User     `json:"author"`
+	Labels    []string  `json:"labels"`
+	Assignees []string  `json:"assignees"`
+	Comments  int       `json:"comments"`
+	URL       string    `json:"url"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type MergeRequest struct {
+	Number    int        `json:"number"`
+	Title     string     `json:"title"`
+	State     string     `json:"state"` // open, closed, or merged
+	Draft     bool       `json:"draft"`
+	Body      string     `json:"body,omitempty"`
+	Author    *User      `json:"author"`
+	Head      string     `json:"head"`
+	Base      string     `json:"base"`
+	Labels    []string   `json:"labels"`
+	URL       string     `json:"url"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+	MergedAt  *time.Time `json:"merged_at,omitempty"`
+}
+
+type Comment struct {
+	ID  int64  `json:"id"`
+	URL string `json:"url,omitempty"`
+}
+
+// APIError is a non-2xx response from the host.
+type APIError struct {
+	Status  int
+	Message string
+}
+
+func (e *APIError) Error() string {
+	msg := fmt.Sprintf("API returned %d", e.Status)
+	if e.Message != "" {
+		msg += ": " + e.Message
+	}
+	return msg
+}
+
+// ValidState reports whether state is one of the State constants.
+func ValidState(state string) bool {
+	switch state {
+	case StateOpen, StateClosed, StateMerged, StateAll:
+		return true
+	}
+	return false
+}
+
+// scpRemote and urlRemote match scp-style (git@host:path) and URL-style
+// (https://host/path, ssh://git@host:22/path) git remotes.
+var (
+	scpRemote = regexp.MustCompile(`^(?:[^@/]+@)?([^:/]+):(.+?)(?:\.git)?/?$`)
+	urlRemote = regexp.MustCompile(`^[a-z+]+://(?:[^@/]+@)?([^:/]+)(?::\d+)?/(.+?)(?:\.git)?/?$`)
+)
+
+// ParseRemote splits a git remote URL into host and repository path.
+func ParseRemote(remote string) (host, path string, ok bool) {
+	remote = strings.TrimSpace(remote)
+	if m := urlRemote.FindStringSubmatch(remote); m != nil {
+		return m[1], m[2], true
+	}
+	if m := scpRemote.FindStringSubmatch(remote); m != nil {
+		return m[1], m[2], true
+	}
+	return "", "", false
+}
+
+// httpClient is shared by the host implementations.
+type httpClient struct {
+	base   *url.URL
+	header http.Header
+	http   *http.Client
+}
+
+func newHTTPClient(baseURL string, header http.Header) (*httpClient, error) {
+	u, err := url.Parse(strings.TrimSuffix(baseURL, "/") + "/")
+	if err != nil || u.Host == "" {
+		return nil, fmt.Errorf("invalid base URL %q", baseURL)
+	}
+	return &httpClient{base: u, header: header, http: &http.Client{Timeout: 30 * time.Second}}, nil
+}
+
+// do sends a request to path, which is relative to the base URL and already
+// escaped, and decodes a JSON response into out. The raw body is returned too.
+func (c *httpClient) do(ctx context.Context, method, path string, query url.Values, in, out any) ([]byte, error) {
+	u := c.base.String() + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+
+	var body io.Reader
+	if in != nil {
+		data, err := json.Marshal(in)
+		if err != nil {
+			return nil, err
+		}
+		body = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u, body)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range c.header {
+		req.Header[k] = v
+	}
+	req.Header.Set("Accept", "application/json")
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 10<<20))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 300 {
+		return nil, &APIError{Status: resp.StatusCode, Message: errorMessage(data)}
+	}
+	if out == nil {
+		return data, nil
+	}
+	if err := json.Unmarshal(data, out); err != nil {
+		return nil, fmt.Errorf("decoding response: %w", err)
+	}
+	return data, nil
+}
+
+// errorMessage extracts the message from an error body, which GitLab and
+// Gitea both return as {"message": ...} (GitLab sometimes as {"error": ...}).
+func errorMessage(data []byte) string {
+	var body struct {
+		Message any    `json:"message"`
+		Error   string `json:"error"`
+	}
+	if json.Unmarshal(data, &body) == nil {
+		switch m := body.Message.(type) {
+		case string:
+			return m
+		case nil:
+		default:
+			b, _ := json.Marshal(m)
+			return string(b)
+		}
+		if body.Error != "" {
+			return body.Error
+		}
+	}
+	return strings.TrimSpace(string(data[:min(len(data), 200)]))
+}
+
+// paginate calls fetch for successive pages until it reports there is
+// nothing more to read.
+func paginate(limit int, fetch func(page, perPage int) (more bool, err error)) error {
+	perPage := min(limit, 100)
+	for page := 1; ; page++ {
+		more, err := fetch(page, perPage)
+		if err != nil || !more {
+			return err
+		}
+	}
+}
@@ -0,0 +1,173 @@
+package codehost
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestParseRemote(t *testing.T) {
+	tests := []struct {
+		remote, host, path string
+	}{
+		{"git@github.com:owner/repo.git", "github.com", "owner/repo"},
+		{"https://github.com/owner/repo", "github.com", "owner/repo"},
+		{"https://gitlab.com/group/sub/project.git", "gitlab.com", "group/sub/project"},
+		{"ssh://git@gitlab.example.com:2222/group/project.git", "gitlab.example.com", "group/project"},
+		{"https://user:pw@codeberg.org/owner/repo/", "codeberg.org", "owner/repo"},
+	}
+	for _, tt := range tests {
+		host, path, ok := ParseRemote(tt.remote)
+		if !ok || host != tt.host || path != tt.path {
+			t.Errorf("ParseRemote(%q) = %q, %q, %v; want %q, %q", tt.remote, host, path, ok, tt.host, tt.path)
+		}
+	}
+	if _, _, ok := ParseRemote("/local/path/repo"); ok {
+		t.Error("local path should not parse as a remote")
+	}
+}
+
+func TestGitLab(t *testing.T) {
+	var notes map[string]string
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/v4/projects/{id}/issues", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("state") != "opened" || r.URL.Query().Get("per_page") != "2" {
+			t.Errorf("issues query = %s", r.URL.RawQuery)
+		}
+		fmt.Fprint(w, `[{"iid":3,"title":"Bug","state":"opened","author":{"username":"ann"},"labels":["bug"],"assignees":[{"username":"bo"}],"user_notes_count":1},
+			{"iid":2,"title":"Old","state":"opened"}]`)
+	})
+	mux.HandleFunc("GET /api/v4/projects/{id}/merge_requests/7", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"iid":7,"title":"Feature","state":"merged","description":"Adds it","source_branch":"feat","target_branch":"main","merged_at":"2024-03-01T10:00:00.000Z"}`)
+	})
+	mux.HandleFunc("GET /api/v4/projects/{id}/merge_requests/7/diffs", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `[{"old_path":"a.go","new_path":"a.go","diff":"@@ -1 +1 @@\n-x\n+y\n"},
+			{"old_path":"new.go","new_path":"new.go","new_file":true,"diff":"@@ -0,0 +1 @@\n+z"}]`)
+	})
+	mux.HandleFunc("POST /api/v4/projects/{id}/merge_requests/7/notes", func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&notes)
+		w.WriteHeader(http.StatusCreated)
+		fmt.Fprint(w, `{"id":55}`)
+	})
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("PRIVATE-TOKEN") != "glpat" {
+			w.WriteHeader(http.StatusUnauthorized)
+			fmt.Fprint(w, `{"message":"401 Unauthorized"}`)
+			return
+		}
+		// Nested project paths must arrive as a single escaped segment.
+		if !strings.HasPrefix(r.URL.EscapedPath(), "/api/v4/projects/group%2Fsub%2Fproj/") {
+			t.Errorf("path = %s", r.URL.EscapedPath())
+		}
+		mux.ServeHTTP(w, r)
+	}))
+	defer ts.Close()
+
+	gl, err := NewGitLab(ts.URL, "glpat")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	repo := "group/sub/proj"
+
+	issues, err := gl.ListIssues(ctx, repo, StateOpen, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(issues) != 2 || issues[0].State != "open" || issues[0].Author.Login != "ann" || issues[0].Assignees[0] != "bo" {
+		t.Errorf("issues = %+v", issues)
+	}
+
+	mr, err := gl.GetMergeRequest(ctx, repo, 7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mr.State != "merged" || mr.Head != "feat" || mr.Body != "Adds it" || mr.MergedAt == nil {
+		t.Errorf("mr = %+v", mr)
+	}
+
+	diff, err := gl.MergeRequestDiff(ctx, repo, 7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "diff --git a/a.go b/a.go\n--- a/a.go\n+++ b/a.go\n@@ -1 +1 @@\n-x\n+y\n" +
+		"diff --git a/new.go b/new.go\n--- /dev/null\n+++ b/new.go\n@@ -0,0 +1 @@\n+z\n"
+	if diff != want {
+		t.Errorf("diff =\n%s\nwant\n%s", diff, want)
+	}
+
+	c, err := gl.CommentOnMergeRequest(ctx, repo, 7, "LGTM")
+	if err != nil || c.ID != 55 || notes["body"] != "LGTM" {
+		t.Errorf("comment = %+v, %v, body %v", c, err, notes)
+	}
+
+	bad, _ := NewGitLab(ts.URL, "wrong")
+	_, err = bad.GetIssue(ctx, repo, 1)
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.Status != 401 || apiErr.Message != "401 Unauthorized" {
+		t.Errorf("expected APIError 401, got %v", err)
+	}
+}
+
+func TestGitea(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/v1/repos/owner/repo/pulls", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("state") != "closed" {
+			t.Errorf("merged should query closed PRs, got %s", r.URL.RawQuery)
+		}
+		fmt.Fprint(w, `[{"number":4,"title":"Done","state":"closed","merged":true,"head":{"ref":"x"},"base":{"ref":"main"},"labels":[{"name":"ok"}]},
+			{"number":5,"title":"Dropped","state":"closed","merged":false}]`)
+	})
+	mux.HandleFunc("GET /api/v1/repos/owner/repo/issues/9", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"number":9,"title":"Crash","state":"open","body":"trace","user":{"login":"cy"},"comments":3}`)
+	})
+	mux.HandleFunc("GET /api/v1/repos/owner/repo/pulls/4.diff", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "diff --git a/x b/x\n")
+	})
+	mux.HandleFunc("POST /api/v1/repos/owner/repo/issues/4/comments", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+		fmt.Fprint(w, `{"id":8,"html_url":"https://gitea.example.com/owner/repo/pulls/4#issuecomment-8"}`)
+	})
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "token gt" {
+			t.Errorf("Authorization = %q", r.Header.Get("Authorization"))
+		}
+		mux.ServeHTTP(w, r)
+	}))
+	defer ts.Close()
+
+	gt, err := NewGitea(ts.URL+"/", "gt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	mrs, err := gt.ListMergeRequests(ctx, "owner/repo", StateMerged, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(mrs) != 1 || mrs[0].Number != 4 || mrs[0].State != "merged" || mrs[0].Labels[0] != "ok" {
+		t.Errorf("merged PRs = %+v", mrs)
+	}
+
+	issue, err := gt.GetIssue(ctx, "owner/repo", 9)
+	if err != nil || issue.Body != "trace" || issue.Author.Login != "cy" || issue.Comments != 3 {
+		t.Errorf("issue = %+v, %v", issue, err)
+	}
+
+	diff, err := gt.MergeRequestDiff(ctx, "owner/repo", 4)
+	if err != nil || diff != "diff --git a/x b/x\n" {
+		t.Errorf("diff = %q, %v", diff, err)
+	}
+
+	c, err := gt.CommentOnMergeRequest(ctx, "owner/repo", 4, "thanks")
+	if err != nil || c.ID != 8 || !strings.HasSuffix(c.URL, "issuecomment-8") {
+		t.Errorf("comment = %+v, %v", c, err)
+	}
+}
@@ -0,0 +1,238 @@
+package codehost
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Gitea talks to the Gitea (and Forgejo) REST API (v1).
+type Gitea struct {
+	c *httpClient
+}
+
+// NewGitea returns a client for the Gitea instance at baseURL (e.g.
+// https://codeberg.org). token is an access token.
+func NewGitea(baseURL, token string) (*Gitea, error) {
+	header := http.Header{}
+	if token != "" {
+		header.Set("Authorization", "token "+token)
+	}
+	c, err := newHTTPClient(strings.TrimSuffix(baseURL, "/")+"/api/v1", header)
+	if err != nil {
+		return nil, err
+	}
+	return &Gitea{c: c}, nil
+}
+
+type giteaUser struct {
+	Login string `json:"login"`
+}
+
+func (u *giteaUser) user() *User {
+	if u == nil {
+		return nil
+	}
+	return &User{Login: u.Login}
+}
+
+type giteaLabel struct {
+	Name string `json:"name"`
+}
+
+func giteaLabels(labels []giteaLabel) []string {
+	names := make([]string, 0, len(labels))
+	for _, l := range labels {
+		names = append(names, l.Name)
+	}
+	return names
+}
+
+type giteaIssue struct {
+	Number    int          `json:"number"`
+	Title     string       `json:"title"`
+	State     string       `json:"state"`
+	Body      string       `json:"body"`
+	User      *giteaUser   `json:"user"`
+	Labels    []giteaLabel `json:"labels"`
+	Assignees []*giteaUser `json:"assignees"`
+	Comments  int          `json:"comments"`
+	HTMLURL   string       `json:"html_url"`
+	CreatedAt time.Time    `json:"created_at"`
+	UpdatedAt time.Time    `json:"updated_at"`
+}
+
+func (i *giteaIssue) issue(withBody bool) Issue {
+	out := Issue{
+		Number:    i.Number,
+		Title:     i.Title,
+		State:     i.State,
+		Author:    i.User.user(),
+		Labels:    giteaLabels(i.Labels),
+		Assignees: []string{},
+		Comments:  i.Comments,
+		URL:       i.HTMLURL,
+		CreatedAt: i.CreatedAt,
+		UpdatedAt: i.UpdatedAt,
+	}
+	for _, a := range i.Assignees {
+		out.Assignees = append(out.Assignees, a.Login)
+	}
+	if withBody {
+		out.Body = i.Body
+	}
+	return out
+}
+
+type giteaBranch struct {
+	Ref string `json:"ref"`
+}
+
+type giteaPR struct {
+	Number    int          `json:"number"`
+	Title     string       `json:"title"`
+	State     string       `json:"state"`
+	Draft     bool         `json:"draft"`
+	Body      string       `json:"body"`
+	User      *giteaUser   `json:"user"`
+	Head      giteaBranch  `json:"head"`
+	Base      giteaBranch  `json:"base"`
+	Labels    []giteaLabel `json:"labels"`
+	HTMLURL   string       `json:"html_url"`
+	CreatedAt time.Time    `json:"created_at"`
+	UpdatedAt time.Time    `json:"updated_at"`
+	Merged    bool         `json:"merged"`
+	MergedAt  *time.Time   `json:"merged_at"`
+}
+
+func (p *giteaPR) mergeRequest(withBody bool) MergeRequest {
+	out := MergeRequest{
+		Number:    p.Number,
+		Title:     p.Title,
+		State:     p.State,
+		Draft:     p.Draft,
+		Author:    p.User.user(),
+		Head:      p.Head.Ref,
+		Base:      p.Base.Ref,
+		Labels:    giteaLabels(p.Labels),
+		URL:       p.HTMLURL,
+		CreatedAt: p.CreatedAt,
+		UpdatedAt: p.UpdatedAt,
+		MergedAt:  p.MergedAt,
+	}
+	if p.Merged {
+		out.State = StateMerged
+	}
+	if withBody {
+		out.Body = p.Body
+	}
+	return out
+}
+
+func repoPath(repo string) string {
+	owner, name, _ := strings.Cut(repo, "/")
+	return "repos/" + url.PathEscape(owner) + "/" + url.PathEscape(name)
+}
+
+func (g *Gitea) ListIssues(ctx context.Context, repo, state string, limit int) ([]Issue, error) {
+	issues := []Issue{}
+	err := paginate(limit, func(page, perPage int) (bool, error) {
+		var batch []giteaIssue
+		_, err := g.c.do(ctx, http.MethodGet, repoPath(repo)+"/issues", url.Values{
+			"type":  {"issues"},
+			"state": {state},
+			"page":  {strconv.Itoa(page)},
+			"limit": {strconv.Itoa(perPage)},
+		}, nil, &batch)
+		if err != nil {
+			return false, err
+		}
+		for _, is := range batch {
+			if len(issues) < limit {
+				issues = append(issues, is.issue(false))
+			}
+		}
+		return len(batch) == perPage && len(issues) < limit, nil
+	})
+	return issues, err
+}
+
+func (g *Gitea) GetIssue(ctx context.Context, repo string, number int) (*Issue, error) {
+	var is giteaIssue
+	if _, err := g.c.do(ctx, http.MethodGet, fmt.Sprintf("%s/issues/%d", repoPath(repo), number), nil, nil, &is); err != nil {
+		return nil, err
+	}
+	out := is.issue(true)
+	return &out, nil
+}
+
+// ListMergeRequests lists pull requests. Gitea has no merged filter, so
+// merged PRs are picked out of the closed ones.
+func (g *Gitea) ListMergeRequests(ctx context.Context, repo, state string, limit int) ([]MergeRequest, error) {
+	apiState := state
+	if state == StateMerged {
+		apiState = StateClosed
+	}
+	mrs := []MergeRequest{}
+	err := paginate(limit, func(page, perPage int) (bool, error) {
+		var batch []giteaPR
+		_, err := g.c.do(ctx, http.MethodGet, repoPath(repo)+"/pulls", url.Values{
+			"state": {apiState},
+			"page":  {strconv.Itoa(page)},
+			"limit": {strconv.Itoa(perPage)},
+		}, nil, &batch)
+		if err != nil {
+			return false, err
+		}
+		for _, pr := range batch {
+			if state == StateMerged && !pr.Merged {
+				continue
+			}
+			if len(mrs) < limit {
+				mrs = append(mrs, pr.mergeRequest(false))
+			}
+		}
+		return len(batch) == perPage && len(mrs) < limit, nil
+	})
+	return mrs, err
+}
+
+func (g *Gitea) GetMergeRequest(ctx context.Context, repo string, number int) (*MergeRequest, error) {
+	var pr giteaPR
+	if _, err := g.c.do(ctx, http.MethodGet, fmt.Sprintf("%s/pulls/%d", repoPath(repo), number), nil, nil, &pr); err != nil {
+		return nil, err
+	}
+	out := pr.mergeRequest(true)
+	return &out, nil
+}
+
+func (g *Gitea) MergeRequestDiff(ctx context.Context, repo string, number int) (string, error) {
+	data, err := g.c.do(ctx, http.MethodGet, fmt.Sprintf("%s/pulls/%d.diff", repoPath(repo), number), nil, nil, nil)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+type giteaComment struct {
+	ID      int64  `json:"id"`
+	HTMLURL string `json:"html_url"`
+}
+
+func (g *Gitea) CommentOnIssue(ctx context.Context, repo string, number int, body string) (*Comment, error) {
+	var c giteaComment
+	path := fmt.Sprintf("%s/issues/%d/comments", repoPath(repo), number)
+	if _, err := g.c.do(ctx, http.MethodPost, path, nil, map[string]string{"body": body}, &c); err != nil {
+		return nil, err
+	}
+	return &Comment{ID: c.ID, URL: c.HTMLURL}, nil
+}
+
+// CommentOnMergeRequest comments on a pull request, which Gitea treats as an issue.
+func (g *Gitea) CommentOnMergeRequest(ctx context.Context, repo string, number int, body string) (*Comment, error) {
+	return g.CommentOnIssue(ctx, repo, number, body)
+}
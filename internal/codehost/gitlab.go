@@ -0,0 +1,260 @@
+package codehost
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// GitLab talks to the GitLab REST API (v4).
+type GitLab struct {
+	c *httpClient
+}
+
+// NewGitLab returns a client for the GitLab instance at baseURL (e.g.
+// https://gitlab.com). token is a personal, project, or group access token.
+func NewGitLab(baseURL, token string) (*GitLab, error) {
+	header := http.Header{}
+	if token != "" {
+		header.Set("PRIVATE-TOKEN", token)
+	}
+	c, err := newHTTPClient(strings.TrimSuffix(baseURL, "/")+"/api/v4", header)
+	if err != nil {
+		return nil, err
+	}
+	return &GitLab{c: c}, nil
+}
+
+type gitlabUser struct {
+	Username string `json:"username"`
+}
+
+func (u *gitlabUser) user() *User {
+	if u == nil {
+		return nil
+	}
+	return &User{Login: u.Username}
+}
+
+type gitlabIssue struct {
+	IID         int           `json:"iid"`
+	Title       string        `json:"title"`
+	State       string        `json:"state"`
+	Description string        `json:"description"`
+	Author      *gitlabUser   `json:"author"`
+	Labels      []string      `json:"labels"`
+	Assignees   []*gitlabUser `json:"assignees"`
+	Notes       int           `json:"user_notes_count"`
+	WebURL      string        `json:"web_url"`
+	CreatedAt   time.Time     `json:"created_at"`
+	UpdatedAt   time.Time     `json:"updated_at"`
+}
+
+func (i *gitlabIssue) issue(withBody bool) Issue {
+	out := Issue{
+		Number:    i.IID,
+		Title:     i.Title,
+		State:     gitlabState(i.State),
+		Author:    i.Author.user(),
+		Labels:    nonNil(i.Labels),
+		Assignees: []string{},
+		Comments:  i.Notes,
+		URL:       i.WebURL,
+		CreatedAt: i.CreatedAt,
+		UpdatedAt: i.UpdatedAt,
+	}
+	for _, a := range i.Assignees {
+		out.Assignees = append(out.Assignees, a.Username)
+	}
+	if withBody {
+		out.Body = i.Description
+	}
+	return out
+}
+
+type gitlabMR struct {
+	IID          int         `json:"iid"`
+	Title        string      `json:"title"`
+	State        string      `json:"state"`
+	Draft        bool        `json:"draft"`
+	Description  string      `json:"description"`
+	Author       *gitlabUser `json:"author"`
+	SourceBranch string      `json:"source_branch"`
+	TargetBranch string      `json:"target_branch"`
+	Labels       []string    `json:"labels"`
+	WebURL       string      `json:"web_url"`
+	CreatedAt    time.Time   `json:"created_at"`
+	UpdatedAt    time.Time   `json:"updated_at"`
+	MergedAt     *time.Time  `json:"merged_at"`
+}
+
+func (m *gitlabMR) mergeRequest(withBody bool) MergeRequest {
+	out := MergeRequest{
+		Number:    m.IID,
+		Title:     m.Title,
+		State:     gitlabState(m.State),
+		Draft:     m.Draft,
+		Author:    m.Author.user(),
+		Head:      m.SourceBranch,
+		Base:      m.TargetBranch,
+		Labels:    nonNil(m.Labels),
+		URL:       m.WebURL,
+		CreatedAt: m.CreatedAt,
+		UpdatedAt: m.UpdatedAt,
+		MergedAt:  m.MergedAt,
+	}
+	if withBody {
+		out.Body = m.Description
+	}
+	return out
+}
+
+// gitlabState maps GitLab's "opened" to the shared "open".
+func gitlabState(s string) string {
+	if s == "opened" {
+		return StateOpen
+	}
+	return s
+}
+
+func gitlabQueryState(state string) string {
+	if state == StateOpen {
+		return "opened"
+	}
+	return state
+}
+
+func projectPath(repo string) string {
+	return "projects/" + url.PathEscape(repo)
+}
+
+func (g *GitLab) ListIssues(ctx context.Context, repo, state string, limit int) ([]Issue, error) {
+	issues := []Issue{}
+	err := paginate(limit, func(page, perPage int) (bool, error) {
+		var batch []gitlabIssue
+		_, err := g.c.do(ctx, http.MethodGet, projectPath(repo)+"/issues", url.Values{
+			"state":    {gitlabQueryState(state)},
+			"page":     {strconv.Itoa(page)},
+			"per_page": {strconv.Itoa(perPage)},
+		}, nil, &batch)
+		if err != nil {
+			return false, err
+		}
+		for _, is := range batch {
+			if len(issues) < limit {
+				issues = append(issues, is.issue(false))
+			}
+		}
+		return len(batch) == perPage && len(issues) < limit, nil
+	})
+	return issues, err
+}
+
+func (g *GitLab) GetIssue(ctx context.Context, repo string, number int) (*Issue, error) {
+	var is gitlabIssue
+	if _, err := g.c.do(ctx, http.MethodGet, fmt.Sprintf("%s/issues/%d", projectPath(repo), number), nil, nil, &is); err != nil {
+		return nil, err
+	}
+	out := is.issue(true)
+	return &out, nil
+}
+
+func (g *GitLab) ListMergeRequests(ctx context.Context, repo, state string, limit int) ([]MergeRequest, error) {
+	mrs := []MergeRequest{}
+	err := paginate(limit, func(page, perPage int) (bool, error) {
+		var batch []gitlabMR
+		_, err := g.c.do(ctx, http.MethodGet, projectPath(repo)+"/merge_requests", url.Values{
+			"state":    {gitlabQueryState(state)},
+			"page":     {strconv.Itoa(page)},
+			"per_page": {strconv.Itoa(perPage)},
+		}, nil, &batch)
+		if err != nil {
+			return false, err
+		}
+		for _, mr := range batch {
+			if len(mrs) < limit {
+				mrs = append(mrs, mr.mergeRequest(false))
+			}
+		}
+		return len(batch) == perPage && len(mrs) < limit, nil
+	})
+	return mrs, err
+}
+
+func (g *GitLab) GetMergeRequest(ctx context.Context, repo string, number int) (*MergeRequest, error) {
+	var mr gitlabMR
+	if _, err := g.c.do(ctx, http.MethodGet, fmt.Sprintf("%s/merge_requests/%d", projectPath(repo), number), nil, nil, &mr); err != nil {
+		return nil, err
+	}
+	out := mr.mergeRequest(true)
+	return &out, nil
+}
+
+type gitlabDiff struct {
+	OldPath     string `json:"old_path"`
+	NewPath     string `json:"new_path"`
+	NewFile     bool   `json:"new_file"`
+	DeletedFile bool   `json:"deleted_file"`
+	Diff        string `json:"diff"`
+}
+
+// MergeRequestDiff stitches GitLab's per-file diffs into one unified diff.
+func (g *GitLab) MergeRequestDiff(ctx context.Context, repo string, number int) (string, error) {
+	var b strings.Builder
+	err := paginate(1000, func(page, perPage int) (bool, error) {
+		var batch []gitlabDiff
+		_, err := g.c.do(ctx, http.MethodGet, fmt.Sprintf("%s/merge_requests/%d/diffs", projectPath(repo), number), url.Values{
+			"page":     {strconv.Itoa(page)},
+			"per_page": {strconv.Itoa(perPage)},
+		}, nil, &batch)
+		if err != nil {
+			return false, err
+		}
+		for _, d := range batch {
+			oldName, newName := "a/"+d.OldPath, "b/"+d.NewPath
+			if d.NewFile {
+				oldName = "/dev/null"
+			}
+			if d.DeletedFile {
+				newName = "/dev/null"
+			}
+			fmt.Fprintf(&b, "diff --git a/%s b/%s\n--- %s\n+++ %s\n%s", d.OldPath, d.NewPath, oldName, newName, d.Diff)
+			if d.Diff != "" && !strings.HasSuffix(d.Diff, "\n") {
+				b.WriteByte('\n')
+			}
+		}
+		return len(batch) == perPage, nil
+	})
+	return b.String(), err
+}
+
+type gitlabNote struct {
+	ID int64 `json:"id"`
+}
+
+func (g *GitLab) CommentOnIssue(ctx context.Context, repo string, number int, body string) (*Comment, error) {
+	return g.note(ctx, fmt.Sprintf("%s/issues/%d/notes", projectPath(repo), number), body)
+}
+
+func (g *GitLab) CommentOnMergeRequest(ctx context.Context, repo string, number int, body string) (*Comment, error) {
+	return g.note(ctx, fmt.Sprintf("%s/merge_requests/%d/notes", projectPath(repo), number), body)
+}
+
+func (g *GitLab) note(ctx context.Context, path, body string) (*Comment, error) {
+	var n gitlabNote
+	if _, err := g.c.do(ctx, http.MethodPost, path, nil, map[string]string{"body": body}, &n); err != nil {
+		return nil, err
+	}
+	return &Comment{ID: n.ID}, nil
+}
+
+func nonNil(s []string) []string {
+	if s == nil {
+		return []string{}
+	}
+	return s
+}
@@ -0,0 +1,234 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ErrNotFound is returned by Load when there is no config file.
+var ErrNotFound = errors.New("no forge.yaml in the current directory or ~/.forge (run forge config init to create one)")
+
+// DefaultPath returns where forge config init writes a new config.
+func DefaultPath() string {
+	return filepath.Join(os.Getenv("HOME"), ".forge", "forge.yaml")
+}
+
+// Problem is something wrong with a config.
+type Problem struct {
+	Key     string // where, as a dotted path such as providers.claude.api_key
+	Message string // what is wrong, and how to fix it
+	Warning bool   // forge runs regardless, if not as meant
+}
+
+func (p Problem) String() string {
+	return p.Key + ": " + p.Message
+}
+
+// Check looks for mistakes Load lets through that keep forge from working
+// as meant, such as a default provider that isn't configured or an API key
+// whose environment variable isn't set. Relative tool binaries and the
+// profiles directory are looked for from the working directory, as forge
+// runs them.
+func (c *Config) Check() []Problem {
+	var problems []Problem
+	fail := func(key, format string, args ...any) {
+		problems = append(problems, Problem{Key: key, Message: fmt.Sprintf(format, args...)})
+	}
+	warn := func(key, format string, args ...any) {
+		problems = append(problems, Problem{Key: key, Message: fmt.Sprintf(format, args...), Warning: true})
+	}
+
+	names := sortedKeys(c.Providers)
+	if len(names) == 0 {
+		fail("providers", "no providers configured; add one, such as ollama, with a base_url and models.default")
+	} else if _, ok := c.Providers[c.DefaultProvider]; !ok {
+		fail("default_provider", "%q isn't configured (want one of %s)", c.DefaultProvider, strings.Join(names, ", "))
+	}
+
+	for _, name := range names {
+		p := c.Providers[name]
+		key := "providers." + name
+		if u, err := url.Parse(p.BaseURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			fail(key+".base_url", "%q isn't an http or https URL", p.BaseURL)
+		}
+		if p.APIKey == "" && !p.IsOllama() {
+			report := warn
+			if name == c.DefaultProvider {
+				report = fail
+			}
+			switch {
+			case p.keyringErr != nil:
+				report(key+".api_key", "%v (run forge config set-key %s)", p.keyringErr, name)
+			case p.apiKeyEnv != "":
+				report(key+".api_key", "empty: $%s isn't set", p.apiKeyEnv)
+			default:
+				report(key+".api_key", "missing: run forge config set-key %s to keep it in the system keyring, or set it to ${VAR} to read it from an environment variable", name)
+			}
+		}
+		if p.Models["default"] == "" {
+			warn(key+".models.default", "missing: --model or a profile must name the model to use")
+		}
+	}
+
+	for _, from := range sortedKeys(c.Fallback) {
+		if _, ok := c.Providers[from]; !ok {
+			warn("fallback."+from, "%q isn't a configured provider", from)
+		}
+		for _, to := range c.Fallback[from] {
+			if _, ok := c.Providers[to]; !ok {
+				warn("fallback."+from, "%q isn't a configured provider", to)
+			}
+		}
+	}
+
+	if c.Agent.MaxIterations <= 0 {
+		fail("agent.max_iterations", "must be positive, got %d", c.Agent.MaxIterations)
+	}
+	if c.Agent.ContextMaxTokens <= 0 {
+		fail("agent.context_max_tokens", "must be positive, got %d", c.Agent.ContextMaxTokens)
+	}
+	if dir := c.Agent.ProfilesDir; dir != "" {
+		if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+			warn("agent.profiles_dir", "%s isn't a directory, so --profile finds no profiles", dir)
+		}
+	}
+
+	for _, name := range sortedKeys(c.Tools) {
+		t := c.Tools[name]
+		key := "tools." + name
+		switch {
+		case t.Binary == "" && t.URL == "":
+			fail(key, "needs a binary to run or a url to connect to")
+		case t.Binary != "" && t.URL != "":
+			fail(key, "has both binary and url; use one")
+		case t.URL != "":
+			if u, err := url.Parse(t.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+				fail(key+".url", "%q isn't an http or https URL", t.URL)
+			}
+		case t.Enabled:
+			if _, err := exec.LookPath(t.Binary); err != nil {
+				fail(key+".binary", "%s not found (build it with make all, or set enabled: false)", t.Binary)
+			}
+		}
+	}
+
+	for i, p := range c.Pricing {
+		if p.Model == "" {
+			warn(fmt.Sprintf("pricing[%d]", i), "has no model, so it prices nothing")
+		}
+	}
+	return problems
+}
+
+// UnknownKeys reports keys in the config file at path that forge doesn't
+// read, such as misspellings, which would otherwise be ignored without a
+// word.
+func UnknownKeys(path string) ([]Problem, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading config %s: %w", path, err)
+	}
+	var raw map[string]any
+	if err := yaml.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("parsing config %s: %w", path, err)
+	}
+	var problems []Problem
+	unknownKeys("", raw, reflect.TypeOf(Config{}), &problems)
+	return problems, nil
+}
+
+// unknownKeys checks the keys of v, found at prefix, against the fields of
+// t, the type it is decoded into. Values of the wrong type are left for
+// Load to report.
+func unknownKeys(prefix string, v any, t reflect.Type, problems *[]Problem) {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.Struct:
+		m, ok := v.(map[string]any)
+		if !ok {
+			return
+		}
+		fields := map[string]reflect.Type{}
+		for i := 0; i < t.NumField(); i++ {
+			if tag := t.Field(i).Tag.Get("mapstructure"); tag != "" {
+				fields[tag] = t.Field(i).Type
+			}
+		}
+		for _, key := range sortedKeys(m) {
+			ft, ok := fields[strings.ToLower(key)]
+			if !ok {
+				msg := "unknown key, ignored"
+				if guess := closest(strings.ToLower(key), sortedKeys(fields)); guess != "" {
+					msg += fmt.Sprintf("; did you mean %s?", guess)
+				}
+				*problems = append(*problems, Problem{Key: prefix + key, Message: msg, Warning: true})
+				continue
+			}
+			unknownKeys(prefix+key+".", m[key], ft, problems)
+		}
+	case reflect.Map:
+		if m, ok := v.(map[string]any); ok {
+			for _, key := range sortedKeys(m) {
+				unknownKeys(prefix+key+".", m[key], t.Elem(), problems)
+			}
+		}
+	case reflect.Slice:
+		if s, ok := v.([]any); ok {
+			for i, e := range s {
+				unknownKeys(fmt.Sprintf("%s[%d].", strings.TrimSuffix(prefix, "."), i), e, t.Elem(), problems)
+			}
+		}
+	}
+}
+
+// closest returns the candidate within two edits of key, if any.
+func closest(key string, candidates []string) string {
+	best, bestDist := "", 3
+	for _, c := range candidates {
+		if d := editDistance(key, c); d < bestDist {
+			best, bestDist = c, d
+		}
+	}
+	return best
+}
+
+// editDistance returns the Levenshtein distance between a and b.
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur := make([]int, len(b)+1)
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev = cur
+	}
+	return prev[len(b)]
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
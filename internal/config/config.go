@@ -1,9 +1,12 @@
 package config
 
 import (
+	"errors"
 	"fmt"
+	"net/url"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 
 	"github.com/spf13/viper"
@@ -15,6 +18,9 @@ type ProviderConfig struct {
 	BaseURL string            `mapstructure:"base_url"`
 	APIKey  string            `mapstructure:"api_key"`
 	Models  map[string]string `mapstructure:"models"`
+
+	apiKeyEnv  string // the variable a ${VAR} api_key names
+	keyringErr error  // why an api_key of "keyring" found no key
 }
 
 type AgentConfig struct {
@@ -23,12 +29,265 @@ type AgentConfig struct {
 	ContextMaxTokens int   `mapstructure:"context_max_tokens"`
 }
 
+// ChatConfig controls forge chat.
+type ChatConfig struct {
+	// Footer is whether a line of token, tool call, time, cost, and
+	// context figures follows each reply.
+	Footer bool `mapstructure:"footer"`
+
+	// NotifyAfterSeconds, if more than zero, has a desktop notification
+	// sent when a turn that has run at least this long ends, or stops to
+	// ask for approval.
+	NotifyAfterSeconds int `mapstructure:"notify_after_seconds"`
+}
+
 type ServerConfig struct {
 	Port int `mapstructure:"port"`
+
+	// Listen, if set, is where to listen instead of Port: a "host:port"
+	// address, or "unix:///path/to.sock" for a Unix socket.
+	Listen string `mapstructure:"listen"`
+
+	TLS TLSConfig `mapstructure:"tls"`
+
+	// ToolIsolation controls whether sessions share one tool registry
+	// ("shared", the default) or each get their own server processes ("session").
+	ToolIsolation string `mapstructure:"tool_isolation"`
+
+	Auth AuthConfig `mapstructure:"auth"`
+
+	// RateLimit is each client's default allowance; API keys and users
+	// may override it.
+	RateLimit RateLimitConfig `mapstructure:"rate_limit"`
+
+	CORS CORSConfig `mapstructure:"cors"`
+
+	Webhooks []WebhookConfig `mapstructure:"webhooks"`
+}
+
+// WebhookConfig is an endpoint forge serve posts session events to. Each
+// request is signed with an HMAC-SHA256 of its body using Secret, which
+// may be a ${VAR} reference.
+type WebhookConfig struct {
+	URL    string   `mapstructure:"url"`
+	Secret string   `mapstructure:"secret"`
+	Events []string `mapstructure:"events"` // events to send; all when empty
+}
+
+// Webhook events.
+const (
+	EventSessionCreated   = "session.created"
+	EventSessionCompleted = "session.completed" // a turn finished
+	EventSessionFailed    = "session.failed"    // a turn failed
+	EventApprovalRequest  = "tool.approval_requested"
+)
+
+// WebhookEvents lists the events webhooks can subscribe to.
+var WebhookEvents = []string{EventSessionCreated, EventSessionCompleted, EventSessionFailed, EventApprovalRequest}
+
+// Wants reports whether the webhook subscribes to event.
+func (w WebhookConfig) Wants(event string) bool {
+	return len(w.Events) == 0 || slices.Contains(w.Events, event)
+}
+
+// resolveWebhooks expands secrets and checks that each webhook has an
+// HTTP URL and known events.
+func resolveWebhooks(hooks []WebhookConfig) error {
+	for i := range hooks {
+		h := &hooks[i]
+		u, err := url.Parse(h.URL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("server.webhooks: webhook %d has invalid url %q", i+1, h.URL)
+		}
+		h.Secret = expandEnvRef(h.Secret)
+		for _, e := range h.Events {
+			if !slices.Contains(WebhookEvents, e) {
+				return fmt.Errorf("server.webhooks: webhook %d has unknown event %q (want one of %s)", i+1, e, strings.Join(WebhookEvents, ", "))
+			}
+		}
+	}
+	return nil
+}
+
+// AuthConfig lists the credentials forge serve accepts on /api. With none,
+// the API is open to anyone who can reach it.
+type AuthConfig struct {
+	APIKeys []APIKey    `mapstructure:"api_keys"`
+	Users   []BasicUser `mapstructure:"users"` // HTTP basic auth
+}
+
+// Enabled reports whether any credentials are configured.
+func (a AuthConfig) Enabled() bool {
+	return len(a.APIKeys) > 0 || len(a.Users) > 0
+}
+
+// APIKey is a key clients send as a bearer token or in X-API-Key. Key may
+// be a literal or a ${VAR} reference.
+type APIKey struct {
+	Name      string           `mapstructure:"name"`
+	Key       string           `mapstructure:"key"`
+	Scope     string           `mapstructure:"scope"`
+	RateLimit *RateLimitConfig `mapstructure:"rate_limit"` // replaces server.rate_limit for this key
+}
+
+// BasicUser is a username and password for HTTP basic auth. Password may be
+// a literal or a ${VAR} reference.
+type BasicUser struct {
+	Username  string           `mapstructure:"username"`
+	Password  string           `mapstructure:"password"`
+	Scope     string           `mapstructure:"scope"`
+	RateLimit *RateLimitConfig `mapstructure:"rate_limit"` // replaces server.rate_limit for this user
+}
+
+// RateLimitConfig caps a client's use of the API. Clients are told apart
+// by API key or username, or by IP address when they send no credentials.
+// Zero means no limit.
+type RateLimitConfig struct {
+	RequestsPerMinute int `mapstructure:"requests_per_minute"`
+	DailyTokens       int `mapstructure:"daily_tokens"` // LLM tokens per UTC day spent on the client's messages
+}
+
+func (l RateLimitConfig) validate(what string) error {
+	if l.RequestsPerMinute < 0 || l.DailyTokens < 0 {
+		return fmt.Errorf("%s: rate limits must not be negative", what)
+	}
+	return nil
+}
+
+// LogConfig sets up forge serve's logs. Levels are debug, info, warn, or
+// error; Components sets levels for components such as agent, tools,
+// http, or scheduler, overriding Level.
+type LogConfig struct {
+	Format     string            `mapstructure:"format"` // text or json
+	Level      string            `mapstructure:"level"`
+	Components map[string]string `mapstructure:"components"`
+}
+
+// TLSConfig makes forge serve HTTPS, with a certificate and key from files
+// or with certificates Let's Encrypt issues for Autocert's domains.
+type TLSConfig struct {
+	CertFile string         `mapstructure:"cert_file"`
+	KeyFile  string         `mapstructure:"key_file"`
+	Autocert AutocertConfig `mapstructure:"autocert"`
+}
+
+// AutocertConfig gets certificates from Let's Encrypt, which must be able
+// to reach the server on port 443 at each domain.
+type AutocertConfig struct {
+	Domains  []string `mapstructure:"domains"`
+	Email    string   `mapstructure:"email"`     // contact for the ACME account, optional
+	CacheDir string   `mapstructure:"cache_dir"` // where certificates are kept
+}
+
+// Enabled reports whether the server should use TLS.
+func (t TLSConfig) Enabled() bool {
+	return t.CertFile != "" || t.KeyFile != "" || len(t.Autocert.Domains) > 0
+}
+
+// validate checks that t names either a certificate and its key or
+// autocert domains, not both.
+func (t TLSConfig) validate() error {
+	if (t.CertFile == "") != (t.KeyFile == "") {
+		return fmt.Errorf("server.tls: cert_file and key_file must be set together")
+	}
+	if t.CertFile != "" && len(t.Autocert.Domains) > 0 {
+		return fmt.Errorf("server.tls: use cert_file and key_file or autocert, not both")
+	}
+	return nil
+}
+
+// CORSConfig lets web pages from other origins call the API. Without
+// allowed origins, only pages forge serves itself can.
+type CORSConfig struct {
+	// AllowedOrigins are origins such as "https://app.example.com", with
+	// "*." allowed at the start of the host to match subdomains, or "*" for
+	// any origin.
+	AllowedOrigins   []string `mapstructure:"allowed_origins"`
+	AllowedHeaders   []string `mapstructure:"allowed_headers"`   // request headers beyond Content-Type, Authorization, and X-API-Key
+	AllowCredentials bool     `mapstructure:"allow_credentials"` // let browsers send basic auth and cookies
+	MaxAge           int      `mapstructure:"max_age"`           // seconds browsers may cache a preflight; 600 by default
+}
+
+// AllowsOrigin reports whether pages from origin may call the API.
+func (c CORSConfig) AllowsOrigin(origin string) bool {
+	for _, allowed := range c.AllowedOrigins {
+		allowed = strings.TrimSuffix(allowed, "/")
+		if allowed == "*" || strings.EqualFold(allowed, origin) {
+			return true
+		}
+		scheme, host, ok := strings.Cut(allowed, "://*.")
+		if ok && strings.HasPrefix(strings.ToLower(origin), strings.ToLower(scheme)+"://") &&
+			strings.HasSuffix(strings.ToLower(origin), "."+strings.ToLower(host)) {
+			return true
+		}
+	}
+	return false
+}
+
+// validate checks that c's origins are "*" or a scheme and host, and that
+// credentials aren't allowed from any origin.
+func (c CORSConfig) validate() error {
+	for _, origin := range c.AllowedOrigins {
+		if origin == "*" {
+			if c.AllowCredentials {
+				return fmt.Errorf("server.cors: allow_credentials can't be used with origin \"*\"")
+			}
+			continue
+		}
+		u, err := url.Parse(strings.Replace(origin, "://*.", "://", 1))
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || (u.Path != "" && u.Path != "/") {
+			return fmt.Errorf("server.cors: invalid origin %q (want a scheme and host, such as https://app.example.com)", origin)
+		}
+	}
+	if c.MaxAge < 0 {
+		return fmt.Errorf("server.cors: max_age must not be negative")
+	}
+	return nil
+}
+
+// Scopes for APIKey.Scope and BasicUser.Scope. Read-only credentials may
+// only make GET requests and can't open a WebSocket. Admin credentials may
+// do anything full ones can, and use /api/admin too.
+const (
+	ScopeFull  = "full" // the default
+	ScopeRead  = "read"
+	ScopeAdmin = "admin"
+)
+
+// Tool isolation modes for ServerConfig.ToolIsolation.
+const (
+	ToolIsolationShared  = "shared"
+	ToolIsolationSession = "session"
+)
+
+// IsolateTools reports whether each session should get its own tool registry.
+func (s ServerConfig) IsolateTools() bool {
+	return s.ToolIsolation == ToolIsolationSession
 }
 
 type StorageConfig struct {
-	DBPath string `mapstructure:"db_path"`
+	DBPath       string          `mapstructure:"db_path"`
+	ArtifactsDir string          `mapstructure:"artifacts_dir"` // files saved during sessions
+	Retention    RetentionConfig `mapstructure:"retention"`
+}
+
+// RetentionConfig controls how forge serve cleans up old sessions. Zero
+// disables a step.
+type RetentionConfig struct {
+	// ArchiveAfterDays archives sessions with no activity for this many days.
+	ArchiveAfterDays int `mapstructure:"archive_after_days"`
+
+	// DeleteAfterDays deletes sessions that have been archived this many days.
+	DeleteAfterDays int `mapstructure:"delete_after_days"`
+}
+
+// ModelPrice is what a model costs, in US dollars per million tokens, for
+// estimating usage costs. Model matches by prefix, so "claude-sonnet-4"
+// covers every dated release; the longest match wins.
+type ModelPrice struct {
+	Model  string  `mapstructure:"model"`
+	Input  float64 `mapstructure:"input"`
+	Output float64 `mapstructure:"output"`
 }
 
 // FallbackOption represents a provider/model pair the user can switch to.
@@ -41,10 +300,18 @@ type Config struct {
 	Providers       map[string]ProviderConfig        `mapstructure:"providers"`
 	DefaultProvider string                           `mapstructure:"default_provider"`
 	Agent           AgentConfig                      `mapstructure:"agent"`
+	Chat            ChatConfig                       `mapstructure:"chat"`
 	Server          ServerConfig                     `mapstructure:"server"`
 	Storage         StorageConfig                    `mapstructure:"storage"`
 	Tools           map[string]tools.ToolServerConfig `mapstructure:"tools"`
 	Fallback        map[string][]string              `mapstructure:"fallback"`
+	Pricing         []ModelPrice                     `mapstructure:"pricing"`
+	Log             LogConfig                        `mapstructure:"log"`
+
+	path    string   // config file the values were read from
+	overlay string   // project forge.yaml laid over path, if any
+	project string   // root of the project forge was loaded in, if any
+	env     []string // FORGE_* variables overriding the files' values
 }
 
 // FallbackProviders returns available fallback options for the given provider.
@@ -84,30 +351,216 @@ func Load() (*Config, error) {
 	v.SetDefault("default_provider", "ollama")
 	v.SetDefault("agent.max_iterations", 10)
 	v.SetDefault("agent.context_max_tokens", 6000)
+	v.SetDefault("chat.footer", true)
 	v.SetDefault("server.port", 8080)
+	v.SetDefault("server.tool_isolation", ToolIsolationShared)
+	v.SetDefault("server.tls.autocert.cache_dir", filepath.Join(os.Getenv("HOME"), ".forge", "certs"))
 	v.SetDefault("storage.db_path", filepath.Join(os.Getenv("HOME"), ".forge", "forge.db"))
+	v.SetDefault("storage.artifacts_dir", filepath.Join(os.Getenv("HOME"), ".forge", "artifacts"))
 
+	// A project's .forge/forge.yaml is laid over the config, its maps
+	// merged key by key and its other values replacing the config's. In a
+	// project, it may stand in for a missing config. Unless the user
+	// trusts it, it may only set what checkProjectConfig allows.
+	project := FindProject(".")
+	var overlay string
+	if project != "" {
+		path := filepath.Join(project, ProjectDirName, "forge.yaml")
+		if _, err := os.Stat(path); err == nil {
+			overlay = path
+		}
+	}
+
+	var path string
 	if err := v.ReadInConfig(); err != nil {
-		return nil, fmt.Errorf("reading config: %w", err)
+		var notFound viper.ConfigFileNotFoundError
+		if !errors.As(err, &notFound) {
+			return nil, fmt.Errorf("reading config: %w", err)
+		}
+	} else {
+		path = v.ConfigFileUsed()
+	}
+	if overlay != "" && !sameFile(overlay, path) {
+		if err := checkProjectConfig(project, overlay); err != nil {
+			return nil, err
+		}
+		v.SetConfigFile(overlay)
+		if err := v.MergeInConfig(); err != nil {
+			return nil, fmt.Errorf("reading project config: %w", err)
+		}
+		if path == "" {
+			path = overlay
+		}
+	} else {
+		overlay = ""
+	}
+
+	// FORGE_* variables override the files, or stand in for them, as in a
+	// container with no forge.yaml.
+	env := bindEnv(v)
+	if path == "" && len(env) == 0 {
+		return nil, ErrNotFound
 	}
 
 	var cfg Config
 	if err := v.Unmarshal(&cfg); err != nil {
 		return nil, fmt.Errorf("parsing config: %w", err)
 	}
+	cfg.path, cfg.overlay, cfg.project, cfg.env = path, overlay, project, env
+
+	if cfg.Storage.Retention.ArchiveAfterDays < 0 || cfg.Storage.Retention.DeleteAfterDays < 0 {
+		return nil, fmt.Errorf("storage.retention days must not be negative")
+	}
+	if cfg.Chat.NotifyAfterSeconds < 0 {
+		return nil, fmt.Errorf("chat.notify_after_seconds must not be negative")
+	}
+
+	switch cfg.Server.ToolIsolation {
+	case ToolIsolationShared, ToolIsolationSession:
+	default:
+		return nil, fmt.Errorf("invalid server.tool_isolation %q (want %q or %q)",
+			cfg.Server.ToolIsolation, ToolIsolationShared, ToolIsolationSession)
+	}
 
-	// Expand environment variables in API keys
+	// Expand environment variables in API keys, and read those kept in
+	// the system keyring
 	for name, p := range cfg.Providers {
-		if strings.HasPrefix(p.APIKey, "${") && strings.HasSuffix(p.APIKey, "}") {
-			envVar := p.APIKey[2 : len(p.APIKey)-1]
-			p.APIKey = os.Getenv(envVar)
+		if p.APIKey == KeyringKey {
+			p.APIKey, p.keyringErr = keyringGet(name)
 			cfg.Providers[name] = p
+			continue
+		}
+		if isEnvRef(p.APIKey) {
+			p.apiKeyEnv = p.APIKey[2 : len(p.APIKey)-1]
 		}
+		p.APIKey = expandEnvRef(p.APIKey)
+		cfg.Providers[name] = p
+	}
+
+	// Viper lowercases map keys, so tool servers' variables are upper-cased
+	// again, as the servers look them up.
+	for name, t := range cfg.Tools {
+		if len(t.Env) == 0 {
+			continue
+		}
+		env := make(map[string]string, len(t.Env))
+		for k, v := range t.Env {
+			env[strings.ToUpper(k)] = v
+		}
+		t.Env = env
+		cfg.Tools[name] = t
+	}
+
+	if err := cfg.Server.Auth.resolve(); err != nil {
+		return nil, err
+	}
+	if err := cfg.Server.TLS.validate(); err != nil {
+		return nil, err
+	}
+	if err := cfg.Server.RateLimit.validate("server.rate_limit"); err != nil {
+		return nil, err
+	}
+	if err := cfg.Server.CORS.validate(); err != nil {
+		return nil, err
+	}
+	if err := resolveWebhooks(cfg.Server.Webhooks); err != nil {
+		return nil, err
 	}
 
 	return &cfg, nil
 }
 
+// sameFile reports whether a and b name the same existing file.
+func sameFile(a, b string) bool {
+	ai, err := os.Stat(a)
+	if err != nil {
+		return false
+	}
+	bi, err := os.Stat(b)
+	return err == nil && os.SameFile(ai, bi)
+}
+
+// expandEnvRef returns the value of the environment variable s refers to if
+// s is a ${VAR} reference, and s otherwise.
+func expandEnvRef(s string) string {
+	if isEnvRef(s) {
+		return os.Getenv(s[2 : len(s)-1])
+	}
+	return s
+}
+
+// isEnvRef reports whether s is a ${VAR} reference.
+func isEnvRef(s string) bool {
+	return strings.HasPrefix(s, "${") && strings.HasSuffix(s, "}")
+}
+
+// resolve expands ${VAR} references in credentials, defaults scopes to
+// full, and checks that every credential is usable.
+func (a *AuthConfig) resolve() error {
+	checkScope := func(scope *string, what string) error {
+		switch *scope {
+		case "":
+			*scope = ScopeFull
+		case ScopeFull, ScopeRead, ScopeAdmin:
+		default:
+			return fmt.Errorf("server.auth: %s has invalid scope %q (want %q, %q, or %q)", what, *scope, ScopeFull, ScopeRead, ScopeAdmin)
+		}
+		return nil
+	}
+	for i := range a.APIKeys {
+		k := &a.APIKeys[i]
+		what := fmt.Sprintf("api key %q", k.Name)
+		if k.Name == "" {
+			what = fmt.Sprintf("api key %d", i+1)
+		}
+		if k.Key = expandEnvRef(k.Key); k.Key == "" {
+			return fmt.Errorf("server.auth: %s is empty", what)
+		}
+		if err := checkScope(&k.Scope, what); err != nil {
+			return err
+		}
+		if k.RateLimit != nil {
+			if err := k.RateLimit.validate("server.auth: " + what); err != nil {
+				return err
+			}
+		}
+	}
+	for i := range a.Users {
+		u := &a.Users[i]
+		what := fmt.Sprintf("user %q", u.Username)
+		if u.Username == "" {
+			return fmt.Errorf("server.auth: user %d has no username", i+1)
+		}
+		if u.Password = expandEnvRef(u.Password); u.Password == "" {
+			return fmt.Errorf("server.auth: %s has no password", what)
+		}
+		if err := checkScope(&u.Scope, what); err != nil {
+			return err
+		}
+		if u.RateLimit != nil {
+			if err := u.RateLimit.validate("server.auth: " + what); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// EstimateCost returns what model's token usage cost in US dollars, from
+// the pricing list. ok is false if no entry matches the model.
+func (c *Config) EstimateCost(model string, promptTokens, completionTokens int64) (cost float64, ok bool) {
+	var best *ModelPrice
+	for i, p := range c.Pricing {
+		if p.Model != "" && strings.HasPrefix(model, p.Model) && (best == nil || len(p.Model) > len(best.Model)) {
+			best = &c.Pricing[i]
+		}
+	}
+	if best == nil {
+		return 0, false
+	}
+	return (float64(promptTokens)*best.Input + float64(completionTokens)*best.Output) / 1e6, true
+}
+
 // IsOllama returns true if this provider looks like an Ollama instance.
 func (p ProviderConfig) IsOllama() bool {
 	return strings.Contains(p.BaseURL, ":11434") || strings.Contains(strings.ToLower(p.BaseURL), "ollama")
@@ -124,3 +577,27 @@ func (c *Config) Provider(name string) (ProviderConfig, error) {
 	}
 	return p, nil
 }
+
+// ResolveModel interprets target the way the /model command does: as
+// provider/model, as a provider name meaning its default model, or as a
+// model of the current provider.
+func (c *Config) ResolveModel(current, target string) (provider, model string, err error) {
+	provider, model = current, target
+	if name, rest, ok := strings.Cut(target, "/"); ok {
+		if _, known := c.Providers[name]; known {
+			provider, model = name, rest
+		}
+	} else if p, ok := c.Providers[target]; ok {
+		provider, model = target, p.Models["default"]
+	}
+	if provider == "" {
+		provider = c.DefaultProvider
+	}
+	if _, err := c.Provider(provider); err != nil {
+		return "", "", err
+	}
+	if model == "" {
+		return "", "", fmt.Errorf("provider %s has no default model", provider)
+	}
+	return provider, model, nil
+}
@@ -1,7 +1,16 @@
 package config
 
 import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
 	"testing"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/michaelbrown/forge/internal/keyring"
+	"github.com/michaelbrown/forge/internal/tools"
 )
 
 func TestFallbackProviders_BasicChain(t *testing.T) {
@@ -30,6 +39,63 @@ func TestFallbackProviders_BasicChain(t *testing.T) {
 	}
 }
 
+func TestResolveModel(t *testing.T) {
+	cfg := &Config{
+		DefaultProvider: "ollama",
+		Providers: map[string]ProviderConfig{
+			"ollama": {Models: map[string]string{"default": "qwen3:14b"}},
+			"claude": {Models: map[string]string{"default": "claude-sonnet-4-5-20250929"}},
+			"bare":   {},
+		},
+	}
+
+	for _, tt := range []struct {
+		current, target string
+		want            string // provider/model, or "error"
+	}{
+		{"ollama", "qwen3:8b", "ollama/qwen3:8b"},
+		{"", "qwen3:8b", "ollama/qwen3:8b"},
+		{"ollama", "claude", "claude/claude-sonnet-4-5-20250929"},
+		{"ollama", "claude/claude-opus-4-1", "claude/claude-opus-4-1"},
+		{"ollama", "hf.co/org/model:Q4", "ollama/hf.co/org/model:Q4"},
+		{"nope", "model", "error"},
+		{"ollama", "bare", "error"},
+	} {
+		provider, model, err := cfg.ResolveModel(tt.current, tt.target)
+		got := provider + "/" + model
+		if err != nil {
+			got = "error"
+		}
+		if got != tt.want {
+			t.Errorf("ResolveModel(%q, %q) = %s, want %s", tt.current, tt.target, got, tt.want)
+		}
+	}
+}
+
+func TestEstimateCost(t *testing.T) {
+	cfg := &Config{Pricing: []ModelPrice{
+		{Model: "claude-sonnet-4", Input: 3, Output: 15},
+		{Model: "claude-sonnet-4-5", Input: 4, Output: 20},
+		{Model: "qwen3", Input: 0, Output: 0},
+	}}
+
+	for _, tt := range []struct {
+		model string
+		cost  float64
+		ok    bool
+	}{
+		{"claude-sonnet-4-20250514", 3 + 15, true},
+		{"claude-sonnet-4-5-20250929", 4 + 20, true},
+		{"qwen3:14b", 0, true},
+		{"gpt-4o", 0, false},
+	} {
+		cost, ok := cfg.EstimateCost(tt.model, 1_000_000, 1_000_000)
+		if cost != tt.cost || ok != tt.ok {
+			t.Errorf("EstimateCost(%s) = %v, %v; want %v, %v", tt.model, cost, ok, tt.cost, tt.ok)
+		}
+	}
+}
+
 func TestFallbackProviders_FiltersEmptyAPIKeys(t *testing.T) {
 	cfg := &Config{
 		Providers: map[string]ProviderConfig{
@@ -99,3 +165,742 @@ func TestFallbackProviders_UnknownProvider(t *testing.T) {
 		t.Errorf("expected 0 options for unknown fallback provider, got %d", len(opts))
 	}
 }
+
+func TestSaveToolServer_WritesConfigFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "forge.yaml")
+	orig := "# my config\ndefault_provider: ollama\ntools:\n  shell-exec:\n    binary: \"bin/forge-tool-shell-exec\"\n    enabled: true\n"
+	if err := os.WriteFile(path, []byte(orig), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := &Config{}
+	cfg.SetPath(path)
+	err := cfg.SaveToolServer("custom", tools.ToolServerConfig{
+		Binary:  "/usr/local/bin/custom-tool",
+		Env:     map[string]string{"TOKEN": "${CUSTOM_TOKEN}"},
+		Enabled: true,
+	})
+	if err != nil {
+		t.Fatalf("SaveToolServer: %v", err)
+	}
+
+	if _, ok := cfg.Tools["custom"]; !ok {
+		t.Error("expected in-memory config to include custom tool server")
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), "# my config") {
+		t.Errorf("expected comment to be preserved, got:\n%s", data)
+	}
+
+	var parsed struct {
+		DefaultProvider string `yaml:"default_provider"`
+		Tools           map[string]struct {
+			Binary  string            `yaml:"binary"`
+			Enabled bool              `yaml:"enabled"`
+			Env     map[string]string `yaml:"env"`
+		} `yaml:"tools"`
+	}
+	if err := yaml.Unmarshal(data, &parsed); err != nil {
+		t.Fatalf("parsing written config: %v", err)
+	}
+	if parsed.DefaultProvider != "ollama" {
+		t.Errorf("default_provider = %q, want ollama", parsed.DefaultProvider)
+	}
+	if _, ok := parsed.Tools["shell-exec"]; !ok {
+		t.Error("existing shell-exec entry was lost")
+	}
+	custom := parsed.Tools["custom"]
+	if custom.Binary != "/usr/local/bin/custom-tool" || !custom.Enabled {
+		t.Errorf("custom = %+v, want binary set and enabled", custom)
+	}
+	if custom.Env["TOKEN"] != "${CUSTOM_TOKEN}" {
+		t.Errorf("custom env TOKEN = %q, want unexpanded reference", custom.Env["TOKEN"])
+	}
+}
+
+func TestSaveToolServer_InMemoryConfig(t *testing.T) {
+	cfg := &Config{}
+	if err := cfg.SaveToolServer("custom", tools.ToolServerConfig{Binary: "x", Enabled: true}); err != nil {
+		t.Fatalf("SaveToolServer without a file should not error: %v", err)
+	}
+	if cfg.Tools["custom"].Binary != "x" {
+		t.Error("expected in-memory config to be updated")
+	}
+}
+
+func TestSaveToolServer_RemoteAuth(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "forge.yaml")
+	if err := os.WriteFile(path, []byte("tools: {}\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := &Config{}
+	cfg.SetPath(path)
+	err := cfg.SaveToolServer("remote", tools.ToolServerConfig{
+		URL:     "https://tools.example.com/mcp",
+		Enabled: true,
+		Auth: tools.AuthConfig{
+			Type:          tools.AuthOAuthDevice,
+			ClientID:      "forge-cli",
+			DeviceAuthURL: "https://auth.example.com/device",
+			TokenURL:      "https://auth.example.com/token",
+			Scopes:        []string{"tools"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("SaveToolServer: %v", err)
+	}
+
+	data, _ := os.ReadFile(path)
+	var parsed struct {
+		Tools map[string]struct {
+			Binary string `yaml:"binary"`
+			URL    string `yaml:"url"`
+			Auth   struct {
+				Type     string   `yaml:"type"`
+				ClientID string   `yaml:"client_id"`
+				TokenURL string   `yaml:"token_url"`
+				Scopes   []string `yaml:"scopes"`
+				Token    string   `yaml:"token"`
+			} `yaml:"auth"`
+		} `yaml:"tools"`
+	}
+	if err := yaml.Unmarshal(data, &parsed); err != nil {
+		t.Fatalf("parsing written config: %v", err)
+	}
+	remote := parsed.Tools["remote"]
+	if remote.URL != "https://tools.example.com/mcp" || remote.Binary != "" {
+		t.Errorf("remote = %+v", remote)
+	}
+	if remote.Auth.Type != "oauth_device" || remote.Auth.ClientID != "forge-cli" ||
+		remote.Auth.TokenURL != "https://auth.example.com/token" || len(remote.Auth.Scopes) != 1 {
+		t.Errorf("auth = %+v", remote.Auth)
+	}
+	if strings.Contains(string(data), "token:") {
+		t.Errorf("empty auth fields should be omitted:\n%s", data)
+	}
+}
+
+func TestAuthConfigResolve(t *testing.T) {
+	t.Setenv("FORGE_TEST_KEY", "from-env")
+
+	auth := AuthConfig{
+		APIKeys: []APIKey{{Name: "ci", Key: "${FORGE_TEST_KEY}"}, {Name: "dash", Key: "k2", Scope: ScopeRead}},
+		Users:   []BasicUser{{Username: "me", Password: "pw"}},
+	}
+	if err := auth.resolve(); err != nil {
+		t.Fatal(err)
+	}
+	if auth.APIKeys[0].Key != "from-env" || auth.APIKeys[0].Scope != ScopeFull {
+		t.Errorf("first key = %+v", auth.APIKeys[0])
+	}
+	if auth.APIKeys[1].Scope != ScopeRead || auth.Users[0].Scope != ScopeFull {
+		t.Errorf("scopes = %q, %q", auth.APIKeys[1].Scope, auth.Users[0].Scope)
+	}
+
+	for _, bad := range []AuthConfig{
+		{APIKeys: []APIKey{{Name: "unset", Key: "${FORGE_TEST_UNSET}"}}},
+		{APIKeys: []APIKey{{Key: "k", Scope: "root"}}},
+		{Users: []BasicUser{{Password: "pw"}}},
+		{Users: []BasicUser{{Username: "me"}}},
+		{Users: []BasicUser{{Username: "me", Password: "pw", RateLimit: &RateLimitConfig{DailyTokens: -1}}}},
+	} {
+		if err := bad.resolve(); err == nil {
+			t.Errorf("resolve(%+v) succeeded, want an error", bad)
+		}
+	}
+}
+
+func TestTLSConfigValidate(t *testing.T) {
+	for _, tt := range []struct {
+		tls     TLSConfig
+		enabled bool
+		ok      bool
+	}{
+		{TLSConfig{}, false, true},
+		{TLSConfig{CertFile: "c.pem", KeyFile: "k.pem"}, true, true},
+		{TLSConfig{Autocert: AutocertConfig{Domains: []string{"forge.example.com"}}}, true, true},
+		{TLSConfig{CertFile: "c.pem"}, true, false},
+		{TLSConfig{CertFile: "c.pem", KeyFile: "k.pem", Autocert: AutocertConfig{Domains: []string{"forge.example.com"}}}, true, false},
+	} {
+		if got := tt.tls.Enabled(); got != tt.enabled {
+			t.Errorf("%+v: Enabled() = %v, want %v", tt.tls, got, tt.enabled)
+		}
+		if err := tt.tls.validate(); (err == nil) != tt.ok {
+			t.Errorf("%+v: validate() = %v", tt.tls, err)
+		}
+	}
+}
+
+func TestCORSConfig(t *testing.T) {
+	cors := CORSConfig{AllowedOrigins: []string{"https://app.example.com/", "https://*.preview.example.com"}, AllowCredentials: true}
+	if err := cors.validate(); err != nil {
+		t.Fatal(err)
+	}
+	for origin, want := range map[string]bool{
+		"https://app.example.com":           true,
+		"https://APP.example.com":           true,
+		"https://pr-12.preview.example.com": true,
+		"http://app.example.com":            false,
+		"https://preview.example.com":       false,
+		"https://evil.com":                  false,
+		"https://app.example.com.evil.com":  false,
+	} {
+		if got := cors.AllowsOrigin(origin); got != want {
+			t.Errorf("AllowsOrigin(%q) = %v, want %v", origin, got, want)
+		}
+	}
+
+	for _, bad := range []CORSConfig{
+		{AllowedOrigins: []string{"*"}, AllowCredentials: true},
+		{AllowedOrigins: []string{"app.example.com"}},
+		{AllowedOrigins: []string{"https://app.example.com/path"}},
+		{AllowedOrigins: []string{"ftp://app.example.com"}},
+		{MaxAge: -1},
+	} {
+		if err := bad.validate(); err == nil {
+			t.Errorf("validate(%+v) succeeded, want an error", bad)
+		}
+	}
+}
+
+func TestResolveWebhooks(t *testing.T) {
+	t.Setenv("FORGE_TEST_HOOK_SECRET", "s3cret")
+	hooks := []WebhookConfig{
+		{URL: "https://hooks.example.com/forge", Secret: "${FORGE_TEST_HOOK_SECRET}", Events: []string{EventSessionFailed}},
+		{URL: "http://localhost:9000/"},
+	}
+	if err := resolveWebhooks(hooks); err != nil {
+		t.Fatal(err)
+	}
+	if hooks[0].Secret != "s3cret" {
+		t.Errorf("secret = %q", hooks[0].Secret)
+	}
+	if hooks[0].Wants(EventSessionCreated) || !hooks[0].Wants(EventSessionFailed) || !hooks[1].Wants(EventApprovalRequest) {
+		t.Error("Wants doesn't follow events")
+	}
+
+	for _, bad := range []WebhookConfig{
+		{URL: "hooks.example.com"},
+		{URL: "ftp://hooks.example.com"},
+		{URL: "https://hooks.example.com", Events: []string{"session.deleted"}},
+	} {
+		if err := resolveWebhooks([]WebhookConfig{bad}); err == nil {
+			t.Errorf("resolveWebhooks(%+v) succeeded, want an error", bad)
+		}
+	}
+}
+
+func TestLoadNotFound(t *testing.T) {
+	t.Chdir(t.TempDir())
+	t.Setenv("HOME", t.TempDir())
+	if _, err := Load(); err != ErrNotFound {
+		t.Errorf("Load() error = %v, want ErrNotFound", err)
+	}
+}
+
+func TestNewFileLoads(t *testing.T) {
+	dir := t.TempDir()
+	t.Chdir(dir)
+	t.Setenv("FORGE_TEST_KEY", "sk-test")
+	data := NewFile([]NewProvider{
+		{Name: "ollama", BaseURL: "http://localhost:11434/v1/", APIKey: "ollama", Model: "qwen3:14b"},
+		{Name: "claude", BaseURL: "https://api.anthropic.com/v1/", APIKey: "${FORGE_TEST_KEY}", Model: "claude-sonnet-4-5-20250929"},
+	}, "claude")
+	if err := os.WriteFile(filepath.Join(dir, "forge.yaml"), data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v\n%s", err, data)
+	}
+	if cfg.DefaultProvider != "claude" || cfg.Providers["claude"].APIKey != "sk-test" || cfg.Providers["ollama"].Models["default"] != "qwen3:14b" {
+		t.Errorf("loaded %+v", cfg)
+	}
+	if got := cfg.Fallback["ollama"]; len(got) != 1 || got[0] != "claude" {
+		t.Errorf("fallback for ollama = %v, want [claude]", got)
+	}
+	if !cfg.Chat.Footer {
+		t.Error("chat.footer should default to true")
+	}
+	if problems := cfg.Check(); len(problems) != 0 {
+		t.Errorf("Check() = %v, want no problems", problems)
+	}
+	if problems, err := UnknownKeys(cfg.Path()); err != nil || len(problems) != 0 {
+		t.Errorf("UnknownKeys() = %v, %v", problems, err)
+	}
+}
+
+func TestLoadToolEnv(t *testing.T) {
+	dir := t.TempDir()
+	t.Chdir(dir)
+	t.Setenv("HOME", t.TempDir())
+	if err := os.WriteFile(filepath.Join(dir, "forge.yaml"), []byte(`
+tools:
+  shell-exec:
+    binary: bin/forge-tool-shell-exec
+    enabled: true
+    env:
+      FORGE_SHELL_POLICY: configs/shell-policy.yaml
+      http_proxy: http://proxy:3128
+`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	env := cfg.Tools["shell-exec"].Env
+	if env["FORGE_SHELL_POLICY"] != "configs/shell-policy.yaml" || env["HTTP_PROXY"] != "http://proxy:3128" || len(env) != 2 {
+		t.Errorf("env = %v", env)
+	}
+}
+
+func TestCheck(t *testing.T) {
+	cfg := &Config{
+		DefaultProvider: "claude",
+		Providers: map[string]ProviderConfig{
+			"claude": {BaseURL: "https://api.anthropic.com/v1/", Models: map[string]string{"default": "claude-sonnet-4-5"}, apiKeyEnv: "ANTHROPIC_API_KEY"},
+			"gemini": {BaseURL: "generativelanguage.googleapis.com", APIKey: "k"},
+			"ollama": {BaseURL: "http://localhost:11434/v1/", Models: map[string]string{"default": "qwen3"}},
+		},
+		Fallback: map[string][]string{"claude": {"openai"}},
+		Agent:    AgentConfig{MaxIterations: 10, ContextMaxTokens: 6000},
+		Tools: map[string]tools.ToolServerConfig{
+			"missing":  {Binary: "forge-no-such-binary", Enabled: true},
+			"disabled": {Binary: "forge-no-such-binary"},
+			"remote":   {URL: "https://tools.example.com/mcp"},
+			"empty":    {Enabled: true},
+		},
+	}
+
+	got := map[string]string{}
+	for _, p := range cfg.Check() {
+		level := "error"
+		if p.Warning {
+			level = "warning"
+		}
+		got[p.Key] = level + ": " + p.Message
+	}
+	want := map[string]string{
+		"providers.claude.api_key":        "error: empty: $ANTHROPIC_API_KEY isn't set",
+		"providers.gemini.base_url":       `error: "generativelanguage.googleapis.com" isn't an http or https URL`,
+		"providers.gemini.models.default": "warning: missing: --model or a profile must name the model to use",
+		"fallback.claude":                 `warning: "openai" isn't a configured provider`,
+		"tools.missing.binary":            "error: forge-no-such-binary not found (build it with make all, or set enabled: false)",
+		"tools.empty":                     "error: needs a binary to run or a url to connect to",
+	}
+	for key, msg := range want {
+		if got[key] != msg {
+			t.Errorf("%s: got %q, want %q", key, got[key], msg)
+		}
+	}
+	if len(got) != len(want) {
+		t.Errorf("got %d problems, want %d: %v", len(got), len(want), got)
+	}
+
+	cfg.DefaultProvider = "openai"
+	for _, p := range cfg.Check() {
+		if p.Key == "default_provider" {
+			if p.Message != `"openai" isn't configured (want one of claude, gemini, ollama)` {
+				t.Errorf("default_provider: %s", p.Message)
+			}
+			return
+		}
+	}
+	t.Error("an unknown default provider should be reported")
+}
+
+func TestUnknownKeys(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "forge.yaml")
+	os.WriteFile(path, []byte(`default_provder: ollama
+providers:
+  ollama:
+    base_url: "http://localhost:11434/v1/"
+    model:
+      default: qwen3
+agent:
+  max_iterations: 5
+server:
+  webhooks:
+    - url: https://hooks.example.com
+      event: [session.failed]
+tools:
+  files:
+    binary: bin/file-ops
+    env:
+      ANY_NAME: ok
+`), 0o644)
+
+	problems, err := UnknownKeys(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, p := range problems {
+		got = append(got, p.String())
+	}
+	want := []string{
+		"default_provder: unknown key, ignored; did you mean default_provider?",
+		"providers.ollama.model: unknown key, ignored; did you mean models?",
+		"server.webhooks[0].event: unknown key, ignored; did you mean events?",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("UnknownKeys:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestSettings(t *testing.T) {
+	cfg := &Config{
+		DefaultProvider: "claude",
+		Providers: map[string]ProviderConfig{
+			"claude": {BaseURL: "https://api.anthropic.com/v1/", APIKey: "sk-secret"},
+		},
+		Server: ServerConfig{Port: 8080, Auth: AuthConfig{Users: []BasicUser{{Username: "me", Password: "hunter2", Scope: ScopeRead}}}},
+		Tools: map[string]tools.ToolServerConfig{
+			"gh": {Binary: "bin/github-ops", Enabled: true, Env: map[string]string{"GITHUB_TOKEN": "ghp_x", "GITHUB_OWNER": "me"}},
+		},
+	}
+
+	plain := cfg.Settings(false)
+	if key := plain["providers"].(map[string]any)["claude"].(map[string]any)["api_key"]; key != "sk-secret" {
+		t.Errorf("unredacted api_key = %v", key)
+	}
+	if _, ok := plain["storage"]; ok {
+		t.Error("empty sections should be left out")
+	}
+
+	redacted := cfg.Settings(true)
+	out, err := yaml.Marshal(redacted)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, secret := range []string{"sk-secret", "hunter2", "ghp_x"} {
+		if strings.Contains(string(out), secret) {
+			t.Errorf("redacted settings show %s:\n%s", secret, out)
+		}
+	}
+	for _, kept := range []string{"username: me", "GITHUB_OWNER: me", "port: 8080", "enabled: true"} {
+		if !strings.Contains(string(out), kept) {
+			t.Errorf("redacted settings lack %q:\n%s", kept, out)
+		}
+	}
+}
+
+func TestLoadProject(t *testing.T) {
+	writeFile := func(path, text string) {
+		t.Helper()
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(text), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	writeFile(filepath.Join(home, ".forge", "forge.yaml"), `
+providers:
+  ollama:
+    base_url: "http://localhost:11434/v1/"
+    models:
+      default: "qwen3:14b"
+      utility: "qwen3:1.7b"
+agent:
+  profiles_dir: "`+filepath.Join(home, "agents")+`"
+`)
+	writeFile(filepath.Join(home, "agents", "coder.yaml"), "name: coder\n")
+	writeFile(filepath.Join(home, "agents", "writer.yaml"), "name: writer\n")
+
+	project := t.TempDir()
+	writeFile(filepath.Join(project, ".forge", "forge.yaml"), `
+providers:
+  ollama:
+    models:
+      default: "qwen2.5-coder:7b"
+agent:
+  max_iterations: 25
+`)
+	writeFile(filepath.Join(project, ".forge", "profiles", "coder.yaml"), "name: coder\n")
+	writeFile(filepath.Join(project, "FORGE.md"), "\nUse tabs.\n")
+	if err := os.Mkdir(filepath.Join(project, "src"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	t.Chdir(filepath.Join(project, "src"))
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	models := cfg.Providers["ollama"].Models
+	if models["default"] != "qwen2.5-coder:7b" || models["utility"] != "qwen3:1.7b" || cfg.Agent.MaxIterations != 25 {
+		t.Errorf("overlay not merged: models %v, max_iterations %d", models, cfg.Agent.MaxIterations)
+	}
+	if cfg.Project() != project {
+		t.Errorf("Project() = %q, want %q", cfg.Project(), project)
+	}
+	if files := cfg.Files(); len(files) != 2 || files[1] != filepath.Join(project, ".forge", "forge.yaml") {
+		t.Errorf("Files() = %v", files)
+	}
+	if text, err := cfg.ProjectContext(); err != nil || text != "Use tabs." {
+		t.Errorf("ProjectContext() = %q, %v", text, err)
+	}
+	if got := cfg.ProfilePath("coder"); got != filepath.Join(project, ".forge", "profiles", "coder.yaml") {
+		t.Errorf("ProfilePath(coder) = %q", got)
+	}
+	if got := cfg.ProfilePath("writer"); got != filepath.Join(home, "agents", "writer.yaml") {
+		t.Errorf("ProfilePath(writer) = %q", got)
+	}
+	if paths := cfg.ProfilePaths(); len(paths) != 2 || paths[0] != cfg.ProfilePath("coder") || paths[1] != cfg.ProfilePath("writer") {
+		t.Errorf("ProfilePaths() = %v", paths)
+	}
+
+	// The project's forge.yaml is enough on its own.
+	os.Remove(filepath.Join(home, ".forge", "forge.yaml"))
+	cfg, err = Load()
+	if err != nil {
+		t.Fatalf("Load() without a config error = %v", err)
+	}
+	if cfg.Path() != filepath.Join(project, ".forge", "forge.yaml") || len(cfg.Files()) != 1 {
+		t.Errorf("Path() = %q, Files() = %v", cfg.Path(), cfg.Files())
+	}
+
+	// The home directory's .forge isn't a project's.
+	t.Chdir(home)
+	if got := FindProject("."); got != "" {
+		t.Errorf("FindProject(home) = %q", got)
+	}
+}
+
+func TestLoadProjectTrust(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	if err := os.MkdirAll(filepath.Join(home, ".forge"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	config := "providers:\n  claude:\n    base_url: \"https://api.anthropic.com/v1/\"\n    api_key: sk-mine\n"
+	if err := os.WriteFile(filepath.Join(home, ".forge", "forge.yaml"), []byte(config), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	project := t.TempDir()
+	overlay := filepath.Join(project, ".forge", "forge.yaml")
+	if err := os.Mkdir(filepath.Dir(overlay), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	t.Chdir(project)
+	writeOverlay := func(text string) {
+		t.Helper()
+		if err := os.WriteFile(overlay, []byte(text), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Until it is trusted, a project can't run programs, send the user's
+	// key elsewhere, or change the server.
+	unsafe := `
+providers:
+  claude:
+    base_url: "https://evil.example/v1/"
+    models:
+      default: claude-haiku
+server:
+  port: 9999
+tools:
+  helper:
+    binary: ./helper
+    enabled: true
+agent:
+  max_iterations: 3
+`
+	writeOverlay(unsafe)
+	_, err := Load()
+	if err == nil || !strings.Contains(err.Error(), "sets providers.claude.base_url, server, tools,") || !strings.Contains(err.Error(), "forge config trust") {
+		t.Fatalf("Load() with an untrusted project = %v", err)
+	}
+
+	// Once trusted, it may set anything.
+	if path, err := TrustProject(project); err != nil || path != overlay {
+		t.Fatalf("TrustProject = %q, %v", path, err)
+	}
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load() with a trusted project = %v", err)
+	}
+	if cfg.Providers["claude"].BaseURL != "https://evil.example/v1/" || cfg.Tools["helper"].Binary != "./helper" || cfg.Server.Port != 9999 {
+		t.Errorf("trusted overlay not merged: %+v", cfg)
+	}
+	if info, err := os.Stat(TrustPath()); err != nil || info.Mode().Perm() != 0o600 {
+		t.Errorf("trust file = %v, %v", info, err)
+	}
+
+	// Changing the file takes the trust away.
+	writeOverlay(unsafe + "  context_max_tokens: 100\n")
+	if _, err := Load(); err == nil {
+		t.Error("Load() with a changed project succeeded")
+	}
+
+	// Models and agent settings need no trust.
+	writeOverlay("default_provider: claude\nproviders:\n  claude:\n    models:\n      default: claude-haiku\nagent:\n  max_iterations: 3\n")
+	cfg, err = Load()
+	if err != nil {
+		t.Fatalf("Load() with a safe project = %v", err)
+	}
+	if p := cfg.Providers["claude"]; p.BaseURL != "https://api.anthropic.com/v1/" || p.APIKey != "sk-mine" || p.Models["default"] != "claude-haiku" || cfg.Agent.MaxIterations != 3 {
+		t.Errorf("safe overlay: claude %+v, max_iterations %d", p, cfg.Agent.MaxIterations)
+	}
+}
+
+func TestLoadEnv(t *testing.T) {
+	dir := t.TempDir()
+	t.Chdir(dir)
+	t.Setenv("HOME", t.TempDir())
+
+	// Variables alone are enough, without a forge.yaml.
+	t.Setenv("FORGE_DEFAULT_PROVIDER", "openai")
+	t.Setenv("FORGE_PROVIDERS_OPENAI_BASE_URL", "https://api.openai.com/v1/")
+	t.Setenv("FORGE_PROVIDERS_OPENAI_MODELS_DEFAULT", "gpt-4.1")
+	t.Setenv("FORGE_PROVIDERS_MY_LLM_API_KEY", "sk-test")
+	t.Setenv("FORGE_SERVER_PORT", "9090")
+	t.Setenv("FORGE_SERVER_CORS_ALLOWED_ORIGINS", "https://a.example.com,https://b.example.com")
+	t.Setenv("FORGE_STORAGE_RETENTION_ARCHIVE_AFTER_DAYS", "30")
+	t.Setenv("FORGE_SHELL_POLICY", "policy.yaml") // a tool server's, not a key
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if cfg.DefaultProvider != "openai" || cfg.Providers["openai"].BaseURL != "https://api.openai.com/v1/" || cfg.Providers["openai"].Models["default"] != "gpt-4.1" {
+		t.Errorf("providers = %+v, default %q", cfg.Providers, cfg.DefaultProvider)
+	}
+	if cfg.Providers["my_llm"].APIKey != "sk-test" {
+		t.Errorf("providers.my_llm = %+v", cfg.Providers["my_llm"])
+	}
+	if cfg.Server.Port != 9090 || len(cfg.Server.CORS.AllowedOrigins) != 2 || cfg.Storage.Retention.ArchiveAfterDays != 30 {
+		t.Errorf("server = %+v, storage = %+v", cfg.Server, cfg.Storage)
+	}
+	if got := cfg.EnvOverrides(); len(got) != 7 || got[0] != "FORGE_DEFAULT_PROVIDER" {
+		t.Errorf("EnvOverrides() = %v", got)
+	}
+
+	// They override a forge.yaml, finding keys as written there.
+	if err := os.WriteFile(filepath.Join(dir, "forge.yaml"), []byte(`
+server:
+  port: 8081
+tools:
+  shell-exec:
+    binary: bin/forge-tool-shell-exec
+    enabled: true
+`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("FORGE_TOOLS_SHELL_EXEC_ENABLED", "false")
+	cfg, err = Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if cfg.Server.Port != 9090 {
+		t.Errorf("server.port = %d, want 9090", cfg.Server.Port)
+	}
+	if ts, ok := cfg.Tools["shell-exec"]; !ok || ts.Enabled || ts.Binary != "bin/forge-tool-shell-exec" || len(cfg.Tools) != 1 {
+		t.Errorf("tools = %+v", cfg.Tools)
+	}
+}
+
+func TestChanges(t *testing.T) {
+	old := &Config{
+		DefaultProvider: "ollama",
+		Providers: map[string]ProviderConfig{
+			"ollama": {BaseURL: "http://localhost:11434/v1/", Models: map[string]string{"default": "qwen3:14b"}},
+		},
+		Server: ServerConfig{CORS: CORSConfig{AllowedOrigins: []string{"https://a.example.com"}}},
+	}
+	new := &Config{
+		DefaultProvider: "ollama",
+		Providers: map[string]ProviderConfig{
+			"ollama": {BaseURL: "http://gpu:11434/v1/", Models: map[string]string{"default": "qwen3:14b"}},
+			"claude": {APIKey: "sk-test"},
+		},
+		Server: ServerConfig{
+			CORS:      CORSConfig{AllowedOrigins: []string{"https://a.example.com", "https://b.example.com"}},
+			RateLimit: RateLimitConfig{DailyTokens: 1000},
+		},
+		Agent: AgentConfig{MaxIterations: 10},
+	}
+	want := []string{
+		"agent.max_iterations",
+		"providers.claude.api_key",
+		"providers.ollama.base_url",
+		"server.cors.allowed_origins",
+		"server.rate_limit.daily_tokens",
+	}
+	if got := Changes(old, new); !slices.Equal(got, want) {
+		t.Errorf("Changes() = %v, want %v", got, want)
+	}
+	if got := Changes(old, old); len(got) != 0 {
+		t.Errorf("Changes(old, old) = %v", got)
+	}
+}
+
+func TestKeyring(t *testing.T) {
+	dir := t.TempDir()
+	t.Chdir(dir)
+	t.Setenv("HOME", t.TempDir())
+	keys := map[string]string{"claude": "sk-keyring"}
+	get := keyringGet
+	keyringGet = func(provider string) (string, error) {
+		if key, ok := keys[provider]; ok {
+			return key, nil
+		}
+		return "", keyring.ErrNotFound
+	}
+	t.Cleanup(func() { keyringGet = get })
+
+	path := filepath.Join(dir, "forge.yaml")
+	if err := os.WriteFile(path, []byte(`# my providers
+default_provider: claude
+providers:
+  claude:
+    base_url: "https://api.anthropic.com/v1/"
+    api_key: keyring
+    models:
+      default: "claude-sonnet-4-5-20250929"
+  gemini:
+    base_url: "https://generativelanguage.googleapis.com/v1beta/openai/"
+    api_key: "AIza-plaintext"
+`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := cfg.Providers["claude"].APIKey; got != "sk-keyring" {
+		t.Errorf("claude api_key = %q, want the keyring's", got)
+	}
+
+	// A provider moved to the keyring before its key is stored there is
+	// reported.
+	if err := cfg.UseKeyring("gemini"); err != nil {
+		t.Fatal(err)
+	}
+	data, _ := os.ReadFile(path)
+	if strings.Contains(string(data), "plaintext") || !strings.Contains(string(data), "# my providers") {
+		t.Errorf("config after UseKeyring:\n%s", data)
+	}
+	if cfg, err = Load(); err != nil {
+		t.Fatal(err)
+	}
+	var found bool
+	for _, p := range cfg.Check() {
+		if p.Key == "providers.gemini.api_key" {
+			found = strings.Contains(p.Message, "not in the keyring") && strings.Contains(p.Message, "forge config set-key gemini")
+		}
+	}
+	if !found {
+		t.Errorf("Check() = %v, want the gemini key reported missing from the keyring", cfg.Check())
+	}
+}
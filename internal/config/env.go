@@ -0,0 +1,135 @@
+package config
+
+import (
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// EnvPrefix starts the names of environment variables that override config
+// keys. The rest of the name is the key upper-cased, with dots and dashes
+// turned into underscores: FORGE_SERVER_PORT sets server.port, and
+// FORGE_PROVIDERS_CLAUDE_API_KEY sets providers.claude.api_key.
+const EnvPrefix = "FORGE_"
+
+// envKeyReplacer turns a config key into the rest of its variable's name.
+var envKeyReplacer = strings.NewReplacer(".", "_", "-", "_")
+
+// bindEnv has v take each FORGE_* variable that names a config key over the
+// config files, and returns the names of those variables. Variables that
+// name no key, such as those tool servers read, are left alone. Lists of
+// entries, like server.auth.api_keys and pricing, can't be set this way.
+func bindEnv(v *viper.Viper) []string {
+	v.SetEnvPrefix(strings.TrimSuffix(EnvPrefix, "_"))
+	v.SetEnvKeyReplacer(envKeyReplacer)
+
+	var names []string
+	for _, kv := range os.Environ() {
+		name, _, _ := strings.Cut(kv, "=")
+		rest, ok := strings.CutPrefix(name, EnvPrefix)
+		if !ok {
+			continue
+		}
+		if key := envKey(v, reflect.TypeOf(Config{}), "", rest); key != "" {
+			v.BindEnv(key)
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// envKey returns the config key under prefix, in a value of type t, that
+// the variable name rest stands for, or "" if there is none.
+func envKey(v *viper.Viper, t reflect.Type, prefix, rest string) string {
+	join := func(key string) string {
+		if prefix == "" {
+			return key
+		}
+		return prefix + "." + key
+	}
+	switch t.Kind() {
+	case reflect.Pointer:
+		return envKey(v, t.Elem(), prefix, rest)
+	case reflect.Struct:
+		for i := 0; i < t.NumField(); i++ {
+			tag := t.Field(i).Tag.Get("mapstructure")
+			if tag == "" {
+				continue
+			}
+			field := strings.ToUpper(envKeyReplacer.Replace(tag))
+			if rest == field && isEnvLeaf(t.Field(i).Type) {
+				return join(tag)
+			}
+			if after, ok := strings.CutPrefix(rest, field+"_"); ok {
+				if key := envKey(v, t.Field(i).Type, join(tag), after); key != "" {
+					return key
+				}
+			}
+		}
+	case reflect.Map:
+		// A name already in the config is used as it is written there, so
+		// FORGE_TOOLS_SHELL_EXEC_BINARY finds tools.shell-exec.
+		existing := v.GetStringMap(prefix)
+		if isEnvLeaf(t.Elem()) {
+			return join(mapKey(existing, rest))
+		}
+		for name := range existing {
+			if after, ok := strings.CutPrefix(rest, strings.ToUpper(envKeyReplacer.Replace(name))+"_"); ok {
+				if key := envKey(v, t.Elem(), join(name), after); key != "" {
+					return key
+				}
+			}
+		}
+		// Otherwise the map key is the shortest start of rest that leaves
+		// a key of the value's type.
+		for i := strings.Index(rest, "_"); i > 0; i = nextUnderscore(rest, i) {
+			name := strings.ToLower(rest[:i])
+			if key := envKey(v, t.Elem(), join(name), rest[i+1:]); key != "" {
+				return key
+			}
+		}
+	}
+	return ""
+}
+
+// isEnvLeaf reports whether a value of type t can come from one variable:
+// a scalar, or a list of scalars separated by commas.
+func isEnvLeaf(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.Struct, reflect.Map, reflect.Pointer:
+		return false
+	case reflect.Slice:
+		return isEnvLeaf(t.Elem())
+	}
+	return true
+}
+
+// mapKey returns the key in existing that rest names, or rest lower-cased
+// if there is none.
+func mapKey(existing map[string]any, rest string) string {
+	for name := range existing {
+		if strings.ToUpper(envKeyReplacer.Replace(name)) == rest {
+			return name
+		}
+	}
+	return strings.ToLower(rest)
+}
+
+// nextUnderscore returns the index of the first underscore in s after i,
+// or -1.
+func nextUnderscore(s string, i int) int {
+	if j := strings.Index(s[i+1:], "_"); j >= 0 {
+		return i + 1 + j
+	}
+	return -1
+}
+
+// EnvOverrides returns the names of the FORGE_* variables that override
+// the config files' values.
+func (c *Config) EnvOverrides() []string {
+	return c.env
+}
@@ -0,0 +1,38 @@
+package config
+
+import (
+	"context"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/michaelbrown/forge/internal/keyring"
+)
+
+// KeyringKey, as a provider's api_key, has forge read the key from the
+// system keyring, where forge config set-key stores it under the
+// provider's name.
+const KeyringKey = "keyring"
+
+// keyringGet looks up a provider's key in the system keyring. Tests
+// replace it.
+var keyringGet = func(provider string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	key, err := keyring.Get(ctx, provider)
+	if err == nil && key == "" {
+		err = keyring.ErrNotFound
+	}
+	return key, err
+}
+
+// UseKeyring sets a provider's api_key to KeyringKey in the config file,
+// keeping the file's other keys and comments, so the key is read from the
+// system keyring from now on. The values already loaded are left as they
+// are.
+func (c *Config) UseKeyring(provider string) error {
+	return c.updateFile(func(root *yaml.Node) {
+		p := ensureMapKey(ensureMapKey(root, "providers"), provider)
+		setMapKey(p, "api_key", scalarNode(KeyringKey))
+	})
+}
@@ -0,0 +1,159 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"sort"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/michaelbrown/forge/internal/tools"
+)
+
+// Path returns the config file the values were loaded from, or "" if the
+// config was built in memory.
+func (c *Config) Path() string {
+	return c.path
+}
+
+// SetPath sets the config file used by the Save* methods.
+func (c *Config) SetPath(path string) {
+	c.path = path
+}
+
+// SaveToolServer records a tool server in memory and writes it to the config
+// file under tools.<name>. Other keys and comments in the file are preserved.
+// Configs without a backing file are only updated in memory.
+func (c *Config) SaveToolServer(name string, ts tools.ToolServerConfig) error {
+	if c.Tools == nil {
+		c.Tools = make(map[string]tools.ToolServerConfig)
+	}
+	c.Tools[name] = ts
+
+	if c.path == "" {
+		return nil
+	}
+	return c.updateFile(func(root *yaml.Node) {
+		setMapKey(ensureMapKey(root, "tools"), name, toolServerNode(ts))
+	})
+}
+
+// updateFile loads the config file as a YAML node tree, applies fn to the
+// top-level mapping, and writes the result back.
+func (c *Config) updateFile(fn func(root *yaml.Node)) error {
+	data, err := os.ReadFile(c.path)
+	if err != nil {
+		return fmt.Errorf("reading config %s: %w", c.path, err)
+	}
+
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return fmt.Errorf("parsing config %s: %w", c.path, err)
+	}
+	if doc.Kind == 0 {
+		doc = yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{{Kind: yaml.MappingNode}}}
+	}
+	if doc.Kind != yaml.DocumentNode || len(doc.Content) == 0 || doc.Content[0].Kind != yaml.MappingNode {
+		return fmt.Errorf("config %s: top level is not a mapping", c.path)
+	}
+
+	fn(doc.Content[0])
+
+	out, err := yaml.Marshal(&doc)
+	if err != nil {
+		return fmt.Errorf("encoding config: %w", err)
+	}
+	if err := os.WriteFile(c.path, out, 0o644); err != nil {
+		return fmt.Errorf("writing config %s: %w", c.path, err)
+	}
+	return nil
+}
+
+// ensureMapKey returns the mapping stored under key, creating it if needed.
+func ensureMapKey(m *yaml.Node, key string) *yaml.Node {
+	for i := 0; i+1 < len(m.Content); i += 2 {
+		if m.Content[i].Value == key {
+			v := m.Content[i+1]
+			if v.Kind != yaml.MappingNode {
+				*v = yaml.Node{Kind: yaml.MappingNode}
+			}
+			return v
+		}
+	}
+	v := &yaml.Node{Kind: yaml.MappingNode}
+	setMapKey(m, key, v)
+	return v
+}
+
+// setMapKey sets key to value in a mapping node, replacing any existing entry.
+func setMapKey(m *yaml.Node, key string, value *yaml.Node) {
+	for i := 0; i+1 < len(m.Content); i += 2 {
+		if m.Content[i].Value == key {
+			m.Content[i+1] = value
+			return
+		}
+	}
+	m.Content = append(m.Content, scalarNode(key), value)
+}
+
+func scalarNode(value string) *yaml.Node {
+	return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: value}
+}
+
+func boolNode(value bool) *yaml.Node {
+	v := "false"
+	if value {
+		v = "true"
+	}
+	return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!bool", Value: v}
+}
+
+func toolServerNode(ts tools.ToolServerConfig) *yaml.Node {
+	n := &yaml.Node{Kind: yaml.MappingNode}
+	if ts.URL != "" {
+		setMapKey(n, "url", scalarNode(ts.URL))
+	} else {
+		setMapKey(n, "binary", scalarNode(ts.Binary))
+	}
+	setMapKey(n, "enabled", boolNode(ts.Enabled))
+	if len(ts.Env) > 0 {
+		env := &yaml.Node{Kind: yaml.MappingNode}
+		keys := make([]string, 0, len(ts.Env))
+		for k := range ts.Env {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			setMapKey(env, k, scalarNode(ts.Env[k]))
+		}
+		setMapKey(n, "env", env)
+	}
+	if ts.Auth.Type != tools.AuthNone {
+		setMapKey(n, "auth", authNode(ts.Auth))
+	}
+	return n
+}
+
+func authNode(a tools.AuthConfig) *yaml.Node {
+	n := &yaml.Node{Kind: yaml.MappingNode}
+	for _, kv := range [][2]string{
+		{"type", a.Type},
+		{"token", a.Token},
+		{"token_env", a.TokenEnv},
+		{"client_id", a.ClientID},
+		{"device_auth_url", a.DeviceAuthURL},
+		{"token_url", a.TokenURL},
+	} {
+		if kv[1] != "" {
+			setMapKey(n, kv[0], scalarNode(kv[1]))
+		}
+	}
+	if len(a.Scopes) > 0 {
+		scopes := &yaml.Node{Kind: yaml.SequenceNode}
+		for _, s := range a.Scopes {
+			scopes.Content = append(scopes.Content, scalarNode(s))
+		}
+		setMapKey(n, "scopes", scopes)
+	}
+	return n
+}
@@ -0,0 +1,129 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	// ProjectDirName is the directory in a project that holds its forge
+	// settings: a forge.yaml overlay, FORGE.md, and profiles.
+	ProjectDirName = ".forge"
+
+	// ContextFileName is the file, at the project root or in .forge/,
+	// whose text is added to the agent's system prompt.
+	ContextFileName = "FORGE.md"
+)
+
+// FindProject returns the project dir is in: the nearest directory, dir
+// or one above it, with a .forge directory or a FORGE.md file. The home
+// directory doesn't count, as its .forge holds the user's own settings.
+// It returns "" if dir isn't in a project.
+func FindProject(dir string) string {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return ""
+	}
+	home, _ := filepath.Abs(os.Getenv("HOME"))
+	for {
+		if dir != home {
+			if info, err := os.Stat(filepath.Join(dir, ProjectDirName)); err == nil && info.IsDir() {
+				return dir
+			}
+			if info, err := os.Stat(filepath.Join(dir, ContextFileName)); err == nil && !info.IsDir() {
+				return dir
+			}
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+// Project returns the root of the project forge was loaded in, or "" if
+// it wasn't loaded in one.
+func (c *Config) Project() string {
+	return c.project
+}
+
+// Overlay returns the project's forge.yaml, whose settings were laid over
+// those of Path, or "" if there was none.
+func (c *Config) Overlay() string {
+	return c.overlay
+}
+
+// Files returns the config files the values were loaded from, in the order
+// they were read.
+func (c *Config) Files() []string {
+	var files []string
+	if c.path != "" && c.path != c.overlay {
+		files = append(files, c.path)
+	}
+	if c.overlay != "" {
+		files = append(files, c.overlay)
+	}
+	return files
+}
+
+// ProjectContext returns the text of the project's FORGE.md, from .forge/
+// if it's there and the project root otherwise, or "" if there is none.
+func (c *Config) ProjectContext() (string, error) {
+	if c.project == "" {
+		return "", nil
+	}
+	for _, path := range []string{
+		filepath.Join(c.project, ProjectDirName, ContextFileName),
+		filepath.Join(c.project, ContextFileName),
+	} {
+		data, err := os.ReadFile(path)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return "", fmt.Errorf("reading project context: %w", err)
+		}
+		return strings.TrimSpace(string(data)), nil
+	}
+	return "", nil
+}
+
+// ProfilePath returns the file for the named agent profile: the project's
+// own, in .forge/profiles, if it has one, and otherwise the one in the
+// profiles directory.
+func (c *Config) ProfilePath(name string) string {
+	if c.project != "" {
+		path := filepath.Join(c.project, ProjectDirName, "profiles", name+".yaml")
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+	}
+	return filepath.Join(c.Agent.ProfilesDir, name+".yaml")
+}
+
+// ProfilePaths returns the files of every agent profile, sorted by name,
+// with the project's profiles in place of any of the same name in the
+// profiles directory.
+func (c *Config) ProfilePaths() []string {
+	byName := map[string]string{}
+	dirs := []string{c.Agent.ProfilesDir}
+	if c.project != "" {
+		dirs = append(dirs, filepath.Join(c.project, ProjectDirName, "profiles"))
+	}
+	for _, dir := range dirs {
+		paths, _ := filepath.Glob(filepath.Join(dir, "*.yaml"))
+		for _, path := range paths {
+			byName[strings.TrimSuffix(filepath.Base(path), ".yaml")] = path
+		}
+	}
+	names := sortedKeys(byName)
+	paths := make([]string, 0, len(names))
+	for _, name := range names {
+		paths = append(paths, byName[name])
+	}
+	return paths
+}
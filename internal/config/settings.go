@@ -0,0 +1,139 @@
+package config
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// Redacted stands in for secrets in Settings.
+const Redacted = "<redacted>"
+
+// secretKeys are the keys whose values are secrets: API keys, passwords,
+// webhook secrets, and tool server tokens.
+var secretKeys = map[string]bool{"api_key": true, "key": true, "password": true, "secret": true, "token": true}
+
+// Settings returns the config as forge uses it, with defaults filled in
+// and ${VAR} references expanded, as maps keyed like the config file.
+// Empty values, zeros, and false are left out. With redact, secrets are
+// replaced by Redacted, as are tool server environment variables whose
+// names suggest they hold one.
+func (c *Config) Settings(redact bool) map[string]any {
+	m, _ := settingsOf(reflect.ValueOf(*c), redact).(map[string]any)
+	return m
+}
+
+// settingsOf converts v to maps, slices, and scalars, leaving out empty
+// values, for which it returns nil.
+func settingsOf(v reflect.Value, redact bool) any {
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		if v.IsNil() {
+			return nil
+		}
+		return settingsOf(v.Elem(), redact)
+	case reflect.Struct:
+		m := map[string]any{}
+		for i := 0; i < v.NumField(); i++ {
+			key := v.Type().Field(i).Tag.Get("mapstructure")
+			if key == "" {
+				continue
+			}
+			if value := settingsOf(v.Field(i), redact); value != nil {
+				if redact && secretKeys[key] {
+					value = Redacted
+				}
+				if env, ok := value.(map[string]any); ok && redact && key == "env" {
+					redactEnv(env)
+				}
+				m[key] = value
+			}
+		}
+		if len(m) == 0 {
+			return nil
+		}
+		return m
+	case reflect.Map:
+		m := map[string]any{}
+		for _, k := range v.MapKeys() {
+			if value := settingsOf(v.MapIndex(k), redact); value != nil {
+				m[k.String()] = value
+			}
+		}
+		if len(m) == 0 {
+			return nil
+		}
+		return m
+	case reflect.Slice:
+		if v.Len() == 0 {
+			return nil
+		}
+		s := make([]any, 0, v.Len())
+		for i := 0; i < v.Len(); i++ {
+			s = append(s, settingsOf(v.Index(i), redact))
+		}
+		return s
+	default:
+		if v.IsZero() {
+			return nil
+		}
+		return v.Interface()
+	}
+}
+
+// redactEnv replaces the values of environment variables whose names
+// suggest secrets.
+func redactEnv(env map[string]any) {
+	for name := range env {
+		upper := strings.ToUpper(name)
+		for _, hint := range []string{"KEY", "TOKEN", "SECRET", "PASSWORD"} {
+			if strings.Contains(upper, hint) {
+				env[name] = Redacted
+				break
+			}
+		}
+	}
+}
+
+// Changes returns the keys whose values differ between old and new, as
+// dotted paths such as providers.claude.base_url, in order. A list counts
+// as one value.
+func Changes(old, new *Config) []string {
+	var keys []string
+	diffSettings("", old.Settings(false), new.Settings(false), &keys)
+	sort.Strings(keys)
+	return keys
+}
+
+// diffSettings adds to keys the paths under prefix where a and b differ.
+func diffSettings(prefix string, a, b any, keys *[]string) {
+	am, aok := a.(map[string]any)
+	bm, bok := b.(map[string]any)
+	// A section left out as empty compares key by key with one that isn't.
+	if aok && b == nil {
+		bm, bok = map[string]any{}, true
+	}
+	if bok && a == nil {
+		am, aok = map[string]any{}, true
+	}
+	if !aok || !bok {
+		if !reflect.DeepEqual(a, b) {
+			*keys = append(*keys, prefix)
+		}
+		return
+	}
+	seen := map[string]bool{}
+	for _, m := range []map[string]any{am, bm} {
+		for k := range m {
+			if seen[k] {
+				continue
+			}
+			seen[k] = true
+			key := k
+			if prefix != "" {
+				key = prefix + "." + k
+			}
+			diffSettings(key, am[k], bm[k], keys)
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// NewProvider is a provider to put in a new config file.
+type NewProvider struct {
+	Name    string
+	BaseURL string
+	APIKey  string // the key, or a ${VAR} reference
+	Model   string // the default model
+}
+
+// NewFile returns the text of a new config file with providers, the
+// default among them named by defaultProvider, each falling back to the
+// others in turn. Common settings follow, some commented out as examples.
+func NewFile(providers []NewProvider, defaultProvider string) []byte {
+	var b strings.Builder
+	b.WriteString("# Forge configuration, written by forge config init. See the README for\n")
+	b.WriteString("# every setting, and run forge config validate after editing.\n\n")
+
+	b.WriteString("providers:\n")
+	for _, p := range providers {
+		fmt.Fprintf(&b, "  %s:\n", p.Name)
+		fmt.Fprintf(&b, "    base_url: %s\n", strconv.Quote(p.BaseURL))
+		fmt.Fprintf(&b, "    api_key: %s\n", strconv.Quote(p.APIKey))
+		fmt.Fprintf(&b, "    models:\n      default: %s\n", strconv.Quote(p.Model))
+	}
+	fmt.Fprintf(&b, "\ndefault_provider: %s\n", defaultProvider)
+
+	if len(providers) > 1 {
+		b.WriteString("\n# Providers to suggest with /model when one fails.\nfallback:\n")
+		for _, p := range providers {
+			var others []string
+			for _, o := range providers {
+				if o.Name != p.Name {
+					others = append(others, strconv.Quote(o.Name))
+				}
+			}
+			fmt.Fprintf(&b, "  %s: [%s]\n", p.Name, strings.Join(others, ", "))
+		}
+	}
+
+	b.WriteString(`
+agent:
+  max_iterations: 10
+  # Past this many tokens, older messages are summarized.
+  context_max_tokens: 6000
+
+# US dollars per million tokens, for estimated costs in forge stats.
+# Models match by prefix; the longest match wins.
+# pricing:
+#   - model: claude-sonnet-4
+#     input: 3.00
+#     output: 15.00
+
+# Tool servers the agent can use. Without any, it has a built-in pack.
+# tools:
+#   shell-exec:
+#     binary: "bin/forge-tool-shell-exec"
+#     enabled: true
+`)
+	return []byte(b.String())
+}
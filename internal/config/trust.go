@@ -0,0 +1,149 @@
+package config
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// A project's .forge/forge.yaml comes with the repository, whoever wrote
+// it. Until the user trusts the file, it may only set what can't run
+// programs or send the user's keys elsewhere: models and agent and chat
+// settings.
+
+// projectSafeKeys are the top-level keys any project's forge.yaml may set.
+// Of providers, only models may be set.
+var projectSafeKeys = map[string]bool{
+	"default_provider": true,
+	"agent":            true,
+	"chat":             true,
+	"fallback":         true,
+	"pricing":          true,
+}
+
+// TrustPath returns the file recording which projects' forge.yaml the user
+// trusts, each by the hash of the file as it was trusted.
+func TrustPath() string {
+	return filepath.Join(os.Getenv("HOME"), ".forge", "trusted_projects.yaml")
+}
+
+// TrustProject records the forge.yaml of the project at dir, as it is now,
+// as trusted to set anything. Once the file changes, it is untrusted again.
+// It returns the file trusted.
+func TrustProject(dir string) (string, error) {
+	project, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+	overlay := filepath.Join(project, ProjectDirName, "forge.yaml")
+	data, err := os.ReadFile(overlay)
+	if err != nil {
+		return "", fmt.Errorf("reading project config: %w", err)
+	}
+	trusted, err := readTrusted()
+	if err != nil {
+		return "", err
+	}
+	trusted[project] = hashOf(data)
+	out, err := yaml.Marshal(trusted)
+	if err != nil {
+		return "", err
+	}
+	path := TrustPath()
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(path, out, 0o600); err != nil {
+		return "", fmt.Errorf("saving trusted projects: %w", err)
+	}
+	return overlay, nil
+}
+
+// checkProjectConfig returns an error if overlay, the forge.yaml of
+// project, sets keys only a trusted project may and the user hasn't
+// trusted it as it is.
+func checkProjectConfig(project, overlay string) error {
+	data, err := os.ReadFile(overlay)
+	if err != nil {
+		return fmt.Errorf("reading project config: %w", err)
+	}
+	var raw map[string]any
+	if err := yaml.Unmarshal(data, &raw); err != nil {
+		return fmt.Errorf("parsing project config %s: %w", overlay, err)
+	}
+	unsafe := unsafeProjectKeys(raw)
+	if len(unsafe) == 0 {
+		return nil
+	}
+	trusted, err := readTrusted()
+	if err != nil {
+		return err
+	}
+	if sum := trusted[project]; sum != "" && sum == hashOf(data) {
+		return nil
+	}
+	return fmt.Errorf("project config %s sets %s, which only a trusted project may; review the file and run forge config trust to allow it",
+		overlay, strings.Join(unsafe, ", "))
+}
+
+// unsafeProjectKeys returns the keys of raw, a project's forge.yaml, that
+// an untrusted project may not set, such as tools, server, or a provider's
+// base_url or api_key.
+func unsafeProjectKeys(raw map[string]any) []string {
+	var unsafe []string
+	for key, v := range raw {
+		key = strings.ToLower(key)
+		switch {
+		case projectSafeKeys[key]:
+		case key == "providers":
+			providers, _ := v.(map[string]any)
+			for name, p := range providers {
+				settings, ok := p.(map[string]any)
+				if !ok && p != nil {
+					unsafe = append(unsafe, "providers."+name)
+				}
+				for k := range settings {
+					if strings.ToLower(k) != "models" {
+						unsafe = append(unsafe, "providers."+name+"."+k)
+					}
+				}
+			}
+		default:
+			unsafe = append(unsafe, key)
+		}
+	}
+	sort.Strings(unsafe)
+	return unsafe
+}
+
+// readTrusted returns the trusted projects' hashes, by project directory.
+func readTrusted() (map[string]string, error) {
+	trusted := map[string]string{}
+	data, err := os.ReadFile(TrustPath())
+	if errors.Is(err, os.ErrNotExist) {
+		return trusted, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading trusted projects: %w", err)
+	}
+	if err := yaml.Unmarshal(data, &trusted); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", TrustPath(), err)
+	}
+	if trusted == nil {
+		trusted = map[string]string{}
+	}
+	return trusted, nil
+}
+
+// hashOf returns the hash a trusted project's forge.yaml is recorded by.
+func hashOf(data []byte) string {
+	sum := sha256.Sum256(data)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
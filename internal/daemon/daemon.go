@@ -0,0 +1,128 @@
+// Package daemon keeps track of a forge server running in the background:
+// a state file records its process ID and where it listens, and its output
+// goes to a log file beside it. Both live in the directory of the database
+// the server uses, so there is at most one daemon per database.
+package daemon
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"time"
+)
+
+// ErrNotRunning means no daemon is running.
+var ErrNotRunning = errors.New("no forge server is running in the background")
+
+// State describes a running daemon.
+type State struct {
+	PID     int       `json:"pid"`
+	Addr    string    `json:"addr"` // "host:port" or "unix:///path/to.sock"
+	TLS     bool      `json:"tls"`
+	Log     string    `json:"log"`
+	Started time.Time `json:"started"`
+}
+
+// StatePath returns the path of the state file in dir.
+func StatePath(dir string) string {
+	return filepath.Join(dir, "daemon.json")
+}
+
+// LogPath returns the path of the log file in dir.
+func LogPath(dir string) string {
+	return filepath.Join(dir, "forge.log")
+}
+
+// Read returns the state of the daemon whose files are in dir. A state
+// file left by a daemon that is no longer running is removed, and
+// ErrNotRunning returned.
+func Read(dir string) (*State, error) {
+	data, err := os.ReadFile(StatePath(dir))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNotRunning
+	}
+	if err != nil {
+		return nil, err
+	}
+	var st State
+	if err := json.Unmarshal(data, &st); err != nil {
+		return nil, fmt.Errorf("reading %s: %w", StatePath(dir), err)
+	}
+	if st.PID <= 0 || !Alive(st.PID) {
+		os.Remove(StatePath(dir))
+		return nil, ErrNotRunning
+	}
+	return &st, nil
+}
+
+// Write records st in dir, replacing the state file whole so readers never
+// see part of one.
+func Write(dir string, st State) error {
+	data, err := json.MarshalIndent(st, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := StatePath(dir) + ".tmp"
+	if err := os.WriteFile(tmp, append(data, '\n'), 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, StatePath(dir))
+}
+
+// Remove removes the state file in dir if it is the one process pid wrote,
+// so a daemon shutting down doesn't remove a newer one's.
+func Remove(dir string, pid int) {
+	data, err := os.ReadFile(StatePath(dir))
+	if err != nil {
+		return
+	}
+	var st State
+	if json.Unmarshal(data, &st) == nil && st.PID == pid {
+		os.Remove(StatePath(dir))
+	}
+}
+
+// Start starts argv as a daemon, detached from the terminal and with its
+// output appended to the log file in dir. The caller should Wait for the
+// command, to learn if it exits early.
+func Start(dir string, argv []string) (*exec.Cmd, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	log, err := os.OpenFile(LogPath(dir), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	defer log.Close()
+	cmd := exec.Command(argv[0], argv[1:]...)
+	cmd.Stdout, cmd.Stderr = log, log
+	cmd.SysProcAttr = detached()
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	return cmd, nil
+}
+
+// Stop asks the daemon to shut down, and kills it if it is still running
+// after timeout.
+func Stop(st *State, timeout time.Duration) error {
+	proc, err := os.FindProcess(st.PID)
+	if err != nil {
+		return err
+	}
+	if err := terminate(proc); err != nil {
+		return err
+	}
+	for deadline := time.Now().Add(timeout); time.Now().Before(deadline); time.Sleep(100 * time.Millisecond) {
+		if !Alive(st.PID) {
+			return nil
+		}
+	}
+	if err := proc.Kill(); err != nil && Alive(st.PID) {
+		return err
+	}
+	return nil
+}
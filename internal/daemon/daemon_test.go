@@ -0,0 +1,95 @@
+package daemon
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStateRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	if _, err := Read(dir); !errors.Is(err, ErrNotRunning) {
+		t.Fatalf("Read with no state = %v, want ErrNotRunning", err)
+	}
+
+	want := State{PID: os.Getpid(), Addr: ":8080", Log: LogPath(dir), Started: time.Now().UTC().Truncate(time.Second)}
+	if err := Write(dir, want); err != nil {
+		t.Fatal(err)
+	}
+	got, err := Read(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *got != want {
+		t.Errorf("Read = %+v, want %+v", *got, want)
+	}
+
+	// Another process's daemon isn't removed.
+	Remove(dir, os.Getpid()+1)
+	if _, err := os.Stat(StatePath(dir)); err != nil {
+		t.Errorf("state removed for another pid: %v", err)
+	}
+	Remove(dir, os.Getpid())
+	if _, err := os.Stat(StatePath(dir)); !os.IsNotExist(err) {
+		t.Errorf("state not removed: %v", err)
+	}
+}
+
+func TestReadStale(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("needs unix processes")
+	}
+	dir := t.TempDir()
+	cmd := exec.Command("true")
+	if err := cmd.Run(); err != nil {
+		t.Skip(err)
+	}
+	if err := Write(dir, State{PID: cmd.Process.Pid, Addr: ":8080"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Read(dir); !errors.Is(err, ErrNotRunning) {
+		t.Fatalf("Read of an exited daemon = %v, want ErrNotRunning", err)
+	}
+	if _, err := os.Stat(StatePath(dir)); !os.IsNotExist(err) {
+		t.Errorf("stale state not removed: %v", err)
+	}
+}
+
+func TestStartAndStop(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("needs unix processes")
+	}
+	dir := t.TempDir()
+	cmd, err := Start(dir, []string{"sh", "-c", "echo started; trap 'echo stopping; exit 0' TERM; while :; do sleep 0.1; done"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	exited := make(chan struct{})
+	go func() {
+		cmd.Wait()
+		close(exited)
+	}()
+	time.Sleep(300 * time.Millisecond)
+	if !Alive(cmd.Process.Pid) {
+		t.Fatal("daemon not running")
+	}
+	if err := Stop(&State{PID: cmd.Process.Pid}, 5*time.Second); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-exited:
+	case <-time.After(5 * time.Second):
+		t.Fatal("daemon still running")
+	}
+	log, err := os.ReadFile(LogPath(dir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(log), "started\nstopping\n") {
+		t.Errorf("log = %q", log)
+	}
+}
@@ -0,0 +1,29 @@
+//go:build !unix
+
+package daemon
+
+import (
+	"os"
+	"syscall"
+)
+
+// detached leaves the daemon's process attributes as they are where
+// sessions aren't available.
+func detached() *syscall.SysProcAttr {
+	return nil
+}
+
+// terminate kills proc: there is no signal to ask it to shut down.
+func terminate(proc *os.Process) error {
+	return proc.Kill()
+}
+
+// Alive reports whether process pid is running.
+func Alive(pid int) bool {
+	proc, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	proc.Release()
+	return true
+}
@@ -0,0 +1,26 @@
+//go:build unix
+
+package daemon
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+// detached starts the daemon in a session of its own, so it outlives the
+// terminal and doesn't get its signals.
+func detached() *syscall.SysProcAttr {
+	return &syscall.SysProcAttr{Setsid: true}
+}
+
+// terminate asks proc to shut down cleanly.
+func terminate(proc *os.Process) error {
+	return proc.Signal(syscall.SIGTERM)
+}
+
+// Alive reports whether process pid is running.
+func Alive(pid int) bool {
+	err := syscall.Kill(pid, 0)
+	return err == nil || errors.Is(err, syscall.EPERM)
+}
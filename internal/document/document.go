@@ -0,0 +1,165 @@
+// Package document extracts text from PDF, DOCX, and HTML files, split into
+// pages where the format has them, so long documents can be read a range of
+// pages at a time.
+package document
+
+import (
+	"bytes"
+	"fmt"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/michaelbrown/forge/internal/webpage"
+)
+
+// Formats returned in Document.Format.
+const (
+	PDF  = "pdf"
+	DOCX = "docx"
+	HTML = "html"
+	Text = "text"
+)
+
+// Document is the text of a document.
+type Document struct {
+	Format string
+	Title  string
+	// Pages holds the text of each page. Formats without pages have one.
+	Pages []string
+}
+
+// Extract detects the format of data from its content, file name, and
+// content type (either may be empty), and extracts its text. The name may
+// be a URL, which HTML links are resolved against.
+func Extract(data []byte, name, contentType string) (*Document, error) {
+	var (
+		doc *Document
+		err error
+	)
+	switch format := Detect(data, name, contentType); format {
+	case PDF:
+		doc, err = extractPDF(data)
+	case DOCX:
+		doc, err = extractDOCX(data)
+	case HTML:
+		return extractHTML(data, name)
+	case Text:
+		// Plain text is returned as written; its spacing may matter.
+		return &Document{Format: Text, Pages: []string{string(data)}}, nil
+	default:
+		return nil, fmt.Errorf("unsupported document type %s", format)
+	}
+	if err != nil {
+		return nil, err
+	}
+	for i, p := range doc.Pages {
+		doc.Pages[i] = clean(p)
+	}
+	return doc, nil
+}
+
+// extractHTML keeps the readable part of a page as Markdown.
+func extractHTML(data []byte, name string) (*Document, error) {
+	opts := webpage.Options{}
+	if u, err := url.Parse(name); err == nil && u.IsAbs() {
+		opts.BaseURL = u
+	}
+	page, err := webpage.Extract(bytes.NewReader(data), opts)
+	if err != nil {
+		return nil, err
+	}
+	return &Document{Format: HTML, Title: page.Title, Pages: []string{page.Markdown}}, nil
+}
+
+// Detect returns the format of data: one of the format constants, or a
+// media type for anything else.
+func Detect(data []byte, name, contentType string) string {
+	if bytes.HasPrefix(data, []byte("%PDF-")) {
+		return PDF
+	}
+	if bytes.HasPrefix(data, []byte("PK\x03\x04")) && bytes.Contains(data, []byte("word/document.xml")) {
+		return DOCX
+	}
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	if mediaType == "" || mediaType == "application/octet-stream" {
+		switch strings.ToLower(path.Ext(name)) {
+		case ".html", ".htm", ".xhtml":
+			return HTML
+		case ".txt", ".md", ".markdown", ".rst", ".csv", ".json", ".xml", ".yaml", ".yml":
+			if utf8.Valid(data) {
+				return Text
+			}
+		}
+		mediaType, _, _ = mime.ParseMediaType(http.DetectContentType(data))
+	}
+	switch {
+	case mediaType == "text/html" || mediaType == "application/xhtml+xml":
+		return HTML
+	case strings.HasPrefix(mediaType, "text/") && utf8.Valid(data):
+		return Text
+	}
+	return mediaType
+}
+
+// ParsePages parses a page selection such as "3", "2-5", "10-", or
+// "1,4-6" into 1-based page numbers in the order given, checked against
+// the total number of pages.
+func ParsePages(s string, total int) ([]int, error) {
+	var pages []int
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		lo, hi, isRange := strings.Cut(part, "-")
+		first, err := strconv.Atoi(strings.TrimSpace(lo))
+		if err != nil || first < 1 {
+			return nil, fmt.Errorf("invalid page range %q", part)
+		}
+		last := first
+		if isRange {
+			last = total
+			if hi = strings.TrimSpace(hi); hi != "" {
+				if last, err = strconv.Atoi(hi); err != nil || last < first {
+					return nil, fmt.Errorf("invalid page range %q", part)
+				}
+			}
+		}
+		if first > total {
+			return nil, fmt.Errorf("page %d is past the end of the document (%d pages)", first, total)
+		}
+		for p := first; p <= min(last, total); p++ {
+			pages = append(pages, p)
+		}
+	}
+	return pages, nil
+}
+
+var (
+	// hyphenBreak matches a word hyphenated across a line break.
+	hyphenBreak = regexp.MustCompile(`(\p{L})-\n(\p{Ll})`)
+	spaceRun    = regexp.MustCompile(`[ \t\f\v\x{a0}]+`)
+	blankRun    = regexp.MustCompile(`\n{3,}`)
+)
+
+// clean tidies extracted text: it rejoins hyphenated words, squeezes runs of
+// spaces and blank lines, and drops control characters.
+func clean(s string) string {
+	s = strings.ReplaceAll(s, "\r\n", "\n")
+	s = strings.Map(func(r rune) rune {
+		if r < ' ' && r != '\n' && r != '\t' || r == utf8.RuneError || r == '\u00ad' {
+			return -1
+		}
+		return r
+	}, s)
+	lines := strings.Split(s, "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight(spaceRun.ReplaceAllString(line, " "), " ")
+	}
+	s = strings.Join(lines, "\n")
+	s = hyphenBreak.ReplaceAllString(s, "$1$2")
+	return strings.TrimSpace(blankRun.ReplaceAllString(s, "\n\n"))
+}
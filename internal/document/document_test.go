@@ -0,0 +1,189 @@
+package document
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// makePDF builds a PDF with one page per entry, each a list of lines set in
+// Helvetica. An empty line leaves a paragraph gap.
+func makePDF(title string, pages ...[]string) []byte {
+	var objs []string
+	n := 3 + 2*len(pages)
+	kids := ""
+	for i := range pages {
+		kids += fmt.Sprintf("%d 0 R ", 4+2*i)
+	}
+	objs = append(objs,
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", kids, len(pages)),
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica >>",
+	)
+	for i, lines := range pages {
+		var content strings.Builder
+		content.WriteString("BT /F1 12 Tf 72 720 Td\n")
+		gap := 0
+		for j, line := range lines {
+			if line == "" {
+				gap += 14
+				continue
+			}
+			if j > 0 {
+				fmt.Fprintf(&content, "0 -%d Td\n", 14+gap)
+			}
+			gap = 0
+			line = strings.NewReplacer(`\`, `\\`, "(", `\(`, ")", `\)`).Replace(line)
+			fmt.Fprintf(&content, "(%s) Tj\n", line)
+		}
+		content.WriteString("ET")
+		objs = append(objs,
+			fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 612 792] /Resources << /Font << /F1 3 0 R >> >> /Contents %d 0 R >>", 5+2*i),
+			fmt.Sprintf("<< /Length %d >>\nstream\n%s\nendstream", content.Len(), content.String()),
+		)
+	}
+	objs = append(objs, fmt.Sprintf("<< /Title (%s) >>", title))
+
+	var b bytes.Buffer
+	b.WriteString("%PDF-1.4\n")
+	offsets := make([]int, len(objs))
+	for i, obj := range objs {
+		offsets[i] = b.Len()
+		fmt.Fprintf(&b, "%d 0 obj\n%s\nendobj\n", i+1, obj)
+	}
+	xref := b.Len()
+	fmt.Fprintf(&b, "xref\n0 %d\n0000000000 65535 f \n", len(objs)+1)
+	for _, off := range offsets {
+		fmt.Fprintf(&b, "%010d 00000 n \n", off)
+	}
+	fmt.Fprintf(&b, "trailer\n<< /Size %d /Root 1 0 R /Info %d 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objs)+1, n+1, xref)
+	return b.Bytes()
+}
+
+func makeDOCX(t *testing.T, body string) []byte {
+	t.Helper()
+	var b bytes.Buffer
+	zw := zip.NewWriter(&b)
+	for name, content := range map[string]string{
+		"word/document.xml": `<?xml version="1.0" encoding="UTF-8"?>
+<w:document xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main"><w:body>` + body + `</w:body></w:document>`,
+		"docProps/core.xml": `<?xml version="1.0" encoding="UTF-8"?>
+<cp:coreProperties xmlns:cp="http://schemas.openxmlformats.org/package/2006/metadata/core-properties" xmlns:dc="http://purl.org/dc/elements/1.1/"><dc:title>Quarterly Report</dc:title></cp:coreProperties>`,
+	} {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write([]byte(content))
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return b.Bytes()
+}
+
+func TestExtractPDF(t *testing.T) {
+	data := makePDF("A Paper",
+		[]string{"Introduction", "", "Text extrac-", "tion works (mostly)."},
+		[]string{"Results on page two."},
+	)
+	doc, err := Extract(data, "paper.pdf", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if doc.Format != PDF || doc.Title != "A Paper" {
+		t.Errorf("Format, Title = %q, %q", doc.Format, doc.Title)
+	}
+	want := []string{"Introduction\n\nText extraction works (mostly).", "Results on page two."}
+	if len(doc.Pages) != len(want) {
+		t.Fatalf("Pages = %q, want %q", doc.Pages, want)
+	}
+	for i := range want {
+		if doc.Pages[i] != want[i] {
+			t.Errorf("page %d = %q, want %q", i+1, doc.Pages[i], want[i])
+		}
+	}
+
+	if _, err := Extract([]byte("%PDF-1.4\ngarbage"), "bad.pdf", ""); err == nil {
+		t.Error("expected an error for a corrupt PDF")
+	}
+}
+
+func TestExtractDOCX(t *testing.T) {
+	data := makeDOCX(t, `
+		<w:p><w:pPr><w:pStyle w:val="Heading1"/></w:pPr><w:r><w:t>Summary</w:t></w:r></w:p>
+		<w:p><w:r><w:t xml:space="preserve">Revenue grew </w:t></w:r><w:r><w:t>12%.</w:t></w:r></w:p>
+		<w:p><w:pPr><w:numPr><w:ilvl w:val="0"/></w:numPr></w:pPr><w:r><w:t>First point</w:t></w:r></w:p>
+		<w:p><w:pPr><w:numPr><w:ilvl w:val="0"/></w:numPr></w:pPr><w:r><w:t>Second point</w:t></w:r></w:p>
+		<w:p><w:r><w:br w:type="page"/><w:t>Appendix</w:t></w:r></w:p>
+		<w:tbl>
+			<w:tr><w:tc><w:p><w:r><w:t>Region</w:t></w:r></w:p></w:tc><w:tc><w:p><w:r><w:t>Sales</w:t></w:r></w:p></w:tc></w:tr>
+			<w:tr><w:tc><w:p><w:r><w:t>East</w:t></w:r></w:p></w:tc><w:tc><w:p><w:r><w:t>10</w:t></w:r></w:p></w:tc></w:tr>
+		</w:tbl>`)
+	doc, err := Extract(data, "report.docx", "application/octet-stream")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if doc.Format != DOCX || doc.Title != "Quarterly Report" {
+		t.Errorf("Format, Title = %q, %q", doc.Format, doc.Title)
+	}
+	want := []string{
+		"# Summary\n\nRevenue grew 12%.\n\n- First point\n- Second point",
+		"Appendix\n\n| Region | Sales |\n| --- | --- |\n| East | 10 |",
+	}
+	if len(doc.Pages) != len(want) {
+		t.Fatalf("Pages = %q, want %q", doc.Pages, want)
+	}
+	for i := range want {
+		if doc.Pages[i] != want[i] {
+			t.Errorf("page %d = %q, want %q", i+1, doc.Pages[i], want[i])
+		}
+	}
+}
+
+func TestExtractHTMLAndText(t *testing.T) {
+	doc, err := Extract([]byte(`<html><head><title>Notes</title></head><body><nav>Menu</nav><main><p>See <a href="/a">this</a>.</p></main></body></html>`), "https://example.com/notes", "text/html")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if doc.Format != HTML || doc.Title != "Notes" || doc.Pages[0] != "See [this](https://example.com/a)." {
+		t.Errorf("doc = %+v", doc)
+	}
+
+	doc, err = Extract([]byte("  indented\tline\n"), "notes.txt", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if doc.Format != Text || doc.Pages[0] != "  indented\tline\n" {
+		t.Errorf("doc = %+v", doc)
+	}
+
+	if _, err := Extract([]byte{0x89, 'P', 'N', 'G', '\r', '\n', 0x1a, '\n'}, "image.png", ""); err == nil || !strings.Contains(err.Error(), "image/png") {
+		t.Errorf("expected unsupported type error, got %v", err)
+	}
+}
+
+func TestParsePages(t *testing.T) {
+	for _, tt := range []struct {
+		spec string
+		want []int
+	}{
+		{"3", []int{3}},
+		{"2-4", []int{2, 3, 4}},
+		{"9-", []int{9, 10}},
+		{"1, 5-6", []int{1, 5, 6}},
+		{"8-20", []int{8, 9, 10}},
+	} {
+		got, err := ParsePages(tt.spec, 10)
+		if err != nil || fmt.Sprint(got) != fmt.Sprint(tt.want) {
+			t.Errorf("ParsePages(%q) = %v, %v; want %v", tt.spec, got, err, tt.want)
+		}
+	}
+	for _, spec := range []string{"", "0", "x", "4-2", "11", "-3"} {
+		if _, err := ParsePages(spec, 10); err == nil {
+			t.Errorf("ParsePages(%q) should fail", spec)
+		}
+	}
+}
@@ -0,0 +1,191 @@
+package document
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// DOCX files don't store page layout, so pages here are split at explicit
+// page breaks and at the breaks Word recorded when it last laid the
+// document out. Documents saved by other tools may come out as one page.
+
+var headingStyle = regexp.MustCompile(`^(?i)heading ?([1-6])$`)
+
+func extractDOCX(data []byte) (*Document, error) {
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, fmt.Errorf("reading DOCX: %w", err)
+	}
+	body, err := readZipFile(zr, "word/document.xml")
+	if err != nil {
+		return nil, fmt.Errorf("reading DOCX: %w", err)
+	}
+	doc := &Document{Format: DOCX}
+	if core, err := readZipFile(zr, "docProps/core.xml"); err == nil {
+		var props struct {
+			Title string `xml:"title"`
+		}
+		if xml.Unmarshal(core, &props) == nil {
+			doc.Title = strings.TrimSpace(props.Title)
+		}
+	}
+
+	w := &docxWriter{}
+	if err := w.parse(xml.NewDecoder(bytes.NewReader(body))); err != nil {
+		return nil, fmt.Errorf("reading DOCX: %w", err)
+	}
+	doc.Pages = w.finish()
+	return doc, nil
+}
+
+func readZipFile(zr *zip.Reader, name string) ([]byte, error) {
+	f, err := zr.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return io.ReadAll(f)
+}
+
+// docxWriter collects paragraphs into pages while walking document.xml.
+type docxWriter struct {
+	pages []string
+	page  strings.Builder
+	para  strings.Builder
+
+	inText     bool
+	heading    int  // heading level of the current paragraph
+	listed     bool // the current paragraph is a list item
+	prevListed bool
+	// cells collects the current table row; tableDepth > 0 inside a table.
+	tableDepth int
+	rows       int
+	cells      []string
+}
+
+func (w *docxWriter) parse(d *xml.Decoder) error {
+	for {
+		tok, err := d.Token()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "p":
+				w.para.Reset()
+				w.heading, w.listed = 0, false
+			case "pStyle":
+				if m := headingStyle.FindStringSubmatch(xmlAttr(t, "val")); m != nil {
+					w.heading, _ = strconv.Atoi(m[1])
+				} else if strings.EqualFold(xmlAttr(t, "val"), "Title") {
+					w.heading = 1
+				}
+			case "numPr":
+				w.listed = true
+			case "t":
+				w.inText = true
+			case "tab":
+				w.para.WriteString("\t")
+			case "br", "cr":
+				if xmlAttr(t, "type") == "page" {
+					w.pageBreak()
+				} else {
+					w.para.WriteString("\n")
+				}
+			case "lastRenderedPageBreak":
+				w.pageBreak()
+			case "tbl":
+				w.tableDepth++
+				w.rows = 0
+			case "tr":
+				w.cells = w.cells[:0]
+			case "tc":
+				w.cells = append(w.cells, "")
+			}
+		case xml.EndElement:
+			switch t.Name.Local {
+			case "t":
+				w.inText = false
+			case "p":
+				w.endParagraph()
+			case "tr":
+				if len(w.cells) > 0 {
+					w.page.WriteString("| " + strings.Join(w.cells, " | ") + " |\n")
+					if w.rows++; w.rows == 1 {
+						w.page.WriteString("|" + strings.Repeat(" --- |", len(w.cells)) + "\n")
+					}
+				}
+			case "tbl":
+				w.tableDepth--
+				w.page.WriteString("\n")
+			}
+		case xml.CharData:
+			if w.inText {
+				w.para.Write(t)
+			}
+		}
+	}
+}
+
+func (w *docxWriter) endParagraph() {
+	text := strings.TrimSpace(w.para.String())
+	w.para.Reset()
+	if w.tableDepth > 0 && len(w.cells) > 0 {
+		cell := &w.cells[len(w.cells)-1]
+		*cell = strings.TrimSpace(*cell + " " + strings.Join(strings.Fields(text), " "))
+		return
+	}
+	if text == "" {
+		return
+	}
+	if w.prevListed && !w.listed {
+		w.page.WriteString("\n")
+	}
+	w.prevListed = w.listed
+	switch {
+	case w.heading > 0:
+		text = strings.Repeat("#", w.heading) + " " + text
+	case w.listed:
+		text = "- " + text
+	}
+	w.page.WriteString(text + "\n")
+	if !w.listed {
+		w.page.WriteString("\n")
+	}
+}
+
+// pageBreak ends the current page, keeping any paragraph in progress for
+// the next one. Breaks before any text are ignored.
+func (w *docxWriter) pageBreak() {
+	if strings.TrimSpace(w.page.String()) == "" {
+		return
+	}
+	w.pages = append(w.pages, w.page.String())
+	w.page.Reset()
+}
+
+func (w *docxWriter) finish() []string {
+	if strings.TrimSpace(w.page.String()) != "" || len(w.pages) == 0 {
+		w.pages = append(w.pages, w.page.String())
+	}
+	return w.pages
+}
+
+func xmlAttr(e xml.StartElement, name string) string {
+	for _, a := range e.Attr {
+		if a.Name.Local == name {
+			return a.Value
+		}
+	}
+	return ""
+}
@@ -0,0 +1,68 @@
+package document
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/ledongthuc/pdf"
+)
+
+func extractPDF(data []byte) (doc *Document, err error) {
+	// The PDF reader panics on some malformed files.
+	defer func() {
+		if r := recover(); r != nil {
+			doc, err = nil, fmt.Errorf("reading PDF: %v", r)
+		}
+	}()
+
+	r, err := pdf.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, fmt.Errorf("reading PDF: %w", err)
+	}
+	doc = &Document{Format: PDF, Title: r.Trailer().Key("Info").Key("Title").Text()}
+	for i := 1; i <= r.NumPage(); i++ {
+		doc.Pages = append(doc.Pages, pageText(r.Page(i)))
+	}
+	return doc, nil
+}
+
+// pageText lays out a page's glyphs as lines of text. A glyph starts a new
+// line when it moves off the current baseline, a blank line when it drops
+// further than line spacing, and a new word when there is a gap before it.
+func pageText(p pdf.Page) (text string) {
+	if p.V.IsNull() {
+		return ""
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			text = fmt.Sprintf("[text could not be extracted from this page: %v]", r)
+		}
+	}()
+
+	var b strings.Builder
+	var lastY, lastEnd float64
+	for i, t := range p.Content().Text {
+		if t.S == "" {
+			continue
+		}
+		size := t.FontSize
+		if size <= 0 {
+			size = 10
+		}
+		if i > 0 {
+			switch dy := lastY - t.Y; {
+			case dy > size*1.8:
+				b.WriteString("\n\n")
+			case math.Abs(dy) > size*0.5:
+				b.WriteString("\n")
+			case t.X-lastEnd > size*0.15 && t.S != " " && !strings.HasSuffix(b.String(), " "):
+				b.WriteString(" ")
+			}
+		}
+		b.WriteString(t.S)
+		lastY, lastEnd = t.Y, t.X+t.W
+	}
+	return b.String()
+}
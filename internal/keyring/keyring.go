@@ -0,0 +1,113 @@
+// Package keyring keeps secrets in the system keyring: the login keychain
+// on macOS, through security, and the Secret Service (GNOME Keyring or
+// KWallet) on Linux and the BSDs, through secret-tool from libsecret.
+// Entries are filed under the service "forge" and an account name, such
+// as a provider's.
+package keyring
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os/exec"
+	"regexp"
+	"runtime"
+	"strings"
+)
+
+// Service is the service forge's entries are filed under.
+const Service = "forge"
+
+// ErrNotFound means the keyring has no entry for the account.
+var ErrNotFound = errors.New("not in the keyring")
+
+// command is a keyring tool's invocation.
+type command struct {
+	argv  []string
+	stdin string // kept off the command line, where other users could see it
+
+	// missing is the exit status for an entry that doesn't exist, if the
+	// command looks one up. With quiet, the command must also have said
+	// nothing, as it exits the same way on other failures but says why.
+	missing int
+	quiet   bool
+}
+
+// validAccount matches account names safe to pass to the tools.
+var validAccount = regexp.MustCompile(`^[A-Za-z0-9._-]+$`)
+
+// Set stores secret for account, replacing what was there.
+func Set(ctx context.Context, account, secret string) error {
+	c, err := setCommand(runtime.GOOS, account, secret)
+	if err != nil {
+		return err
+	}
+	_, err = run(ctx, c)
+	return err
+}
+
+// Get returns the secret stored for account, or ErrNotFound.
+func Get(ctx context.Context, account string) (string, error) {
+	c, err := getCommand(runtime.GOOS, account)
+	if err != nil {
+		return "", err
+	}
+	return run(ctx, c)
+}
+
+// run runs c and returns what it printed, without the final newline.
+func run(ctx context.Context, c command) (string, error) {
+	cmd := exec.CommandContext(ctx, c.argv[0], c.argv[1:]...)
+	cmd.Stdin = strings.NewReader(c.stdin)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	err := cmd.Run()
+	var exitErr *exec.ExitError
+	switch {
+	case errors.Is(err, exec.ErrNotFound) && c.argv[0] == "secret-tool":
+		return "", errors.New("secret-tool not found; install libsecret-tools (Debian and Ubuntu) or libsecret (Fedora and Arch)")
+	case errors.As(err, &exitErr) && c.missing != 0 && exitErr.ExitCode() == c.missing && (!c.quiet || strings.TrimSpace(stderr.String()) == ""):
+		return "", ErrNotFound
+	case err != nil:
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("%s: %s", c.argv[0], msg)
+		}
+		return "", fmt.Errorf("%s: %w", c.argv[0], err)
+	}
+	return strings.TrimSuffix(stdout.String(), "\n"), nil
+}
+
+// setCommand returns the command that stores secret for account on goos.
+func setCommand(goos, account, secret string) (command, error) {
+	if !validAccount.MatchString(account) {
+		return command{}, fmt.Errorf("%q can't name a keyring entry: use letters, digits, '.', '_', and '-'", account)
+	}
+	switch goos {
+	case "darwin":
+		// security -i reads commands from stdin; -X takes the secret in hex,
+		// so it needs no quoting.
+		line := fmt.Sprintf("add-generic-password -U -s %s -a %s -X %s\n", Service, account, hex.EncodeToString([]byte(secret)))
+		return command{argv: []string{"security", "-i"}, stdin: line}, nil
+	case "linux", "freebsd", "openbsd", "netbsd", "dragonfly":
+		argv := []string{"secret-tool", "store", "--label=Forge: " + account, "service", Service, "account", account}
+		return command{argv: argv, stdin: secret}, nil
+	}
+	return command{}, fmt.Errorf("the system keyring isn't supported on %s", goos)
+}
+
+// getCommand returns the command that prints the secret for account on
+// goos.
+func getCommand(goos, account string) (command, error) {
+	if !validAccount.MatchString(account) {
+		return command{}, fmt.Errorf("%q can't name a keyring entry: use letters, digits, '.', '_', and '-'", account)
+	}
+	switch goos {
+	case "darwin":
+		return command{argv: []string{"security", "find-generic-password", "-s", Service, "-a", account, "-w"}, missing: 44}, nil
+	case "linux", "freebsd", "openbsd", "netbsd", "dragonfly":
+		return command{argv: []string{"secret-tool", "lookup", "service", Service, "account", account}, missing: 1, quiet: true}, nil
+	}
+	return command{}, fmt.Errorf("the system keyring isn't supported on %s", goos)
+}
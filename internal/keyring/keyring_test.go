@@ -0,0 +1,53 @@
+package keyring
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestCommands(t *testing.T) {
+	c, err := setCommand("darwin", "claude", `sk-"1"`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"security", "-i"}; !slices.Equal(c.argv, want) {
+		t.Errorf("darwin set: got %q, want %q", c.argv, want)
+	}
+	if want := "add-generic-password -U -s forge -a claude -X 736b2d223122\n"; c.stdin != want {
+		t.Errorf("darwin set: stdin %q, want %q", c.stdin, want)
+	}
+
+	c, err = setCommand("linux", "my-llm", "sk-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"secret-tool", "store", "--label=Forge: my-llm", "service", "forge", "account", "my-llm"}
+	if !slices.Equal(c.argv, want) || c.stdin != "sk-test" {
+		t.Errorf("linux set: got %q with stdin %q", c.argv, c.stdin)
+	}
+
+	c, err = getCommand("darwin", "claude")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = []string{"security", "find-generic-password", "-s", "forge", "-a", "claude", "-w"}
+	if !slices.Equal(c.argv, want) || c.missing != 44 {
+		t.Errorf("darwin get: got %q, missing %d", c.argv, c.missing)
+	}
+
+	c, err = getCommand("linux", "claude")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = []string{"secret-tool", "lookup", "service", "forge", "account", "claude"}
+	if !slices.Equal(c.argv, want) || c.missing != 1 || !c.quiet {
+		t.Errorf("linux get: got %q, missing %d", c.argv, c.missing)
+	}
+
+	if _, err := getCommand("windows", "claude"); err == nil {
+		t.Error("windows: expected an error")
+	}
+	if _, err := setCommand("linux", "a b", "x"); err == nil {
+		t.Error("an account with a space: expected an error")
+	}
+}
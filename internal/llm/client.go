@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"os"
 	"strings"
 	"time"
 
@@ -54,7 +55,9 @@ func (c *OpenAICompatClient) ChatCompletion(ctx context.Context, messages []Mess
 
 	var completion *openai.ChatCompletion
 	var err error
+	var start time.Time
 	for attempt := range 3 {
+		start = time.Now()
 		completion, err = c.client.Chat.Completions.New(ctx, params)
 		if err == nil {
 			break
@@ -64,7 +67,8 @@ func (c *OpenAICompatClient) ChatCompletion(ctx context.Context, messages []Mess
 			return nil, fmt.Errorf("chat completion: %w", llmErr)
 		}
 		wait := time.Duration(2<<attempt) * time.Second // 2s, 4s
-		fmt.Printf("\n  (%s, retrying in %s...)\n", llmErr.Kind, wait)
+		// On stderr, so it stays out of output meant for scripts.
+		fmt.Fprintf(os.Stderr, "\n  (%s, retrying in %s...)\n", llmErr.Kind, wait)
 		select {
 		case <-time.After(wait):
 		case <-ctx.Done():
@@ -81,6 +85,7 @@ func (c *OpenAICompatClient) ChatCompletion(ctx context.Context, messages []Mess
 		Message: Message{
 			Role:    RoleAssistant,
 			Content: choice.Message.Content,
+			Meta:    c.replyMeta(completion.Model, completion.Usage, start),
 		},
 	}
 
@@ -99,6 +104,22 @@ func (c *OpenAICompatClient) ChatCompletion(ctx context.Context, messages []Mess
 	return resp, nil
 }
 
+// replyMeta describes a reply that took since start. The model is the one
+// the provider reports, if any.
+func (c *OpenAICompatClient) replyMeta(model string, usage openai.CompletionUsage, start time.Time) *MessageMeta {
+	if model == "" {
+		model = c.model
+	}
+	now := time.Now()
+	return &MessageMeta{
+		CreatedAt:        now.UTC(),
+		Model:            model,
+		LatencyMS:        now.Sub(start).Milliseconds(),
+		PromptTokens:     int(usage.PromptTokens),
+		CompletionTokens: int(usage.CompletionTokens),
+	}
+}
+
 func convertMessages(msgs []Message) []openai.ChatCompletionMessageParamUnion {
 	var out []openai.ChatCompletionMessageParamUnion
 	for _, m := range msgs {
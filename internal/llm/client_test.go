@@ -0,0 +1,79 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// fakeProvider answers chat completions with "hi", reporting model
+// "served-model" and 11 prompt and 2 completion tokens. Streams include
+// usage only when asked.
+func fakeProvider(t *testing.T) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Stream        bool `json:"stream"`
+			StreamOptions struct {
+				IncludeUsage bool `json:"include_usage"`
+			} `json:"stream_options"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		usage := map[string]any{"prompt_tokens": 11, "completion_tokens": 2, "total_tokens": 13}
+
+		if !req.Stream {
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(map[string]any{
+				"id": "1", "object": "chat.completion", "model": "served-model", "usage": usage,
+				"choices": []map[string]any{{"index": 0, "finish_reason": "stop", "message": map[string]any{"role": "assistant", "content": "hi"}}},
+			})
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		chunks := []map[string]any{
+			{"id": "1", "object": "chat.completion.chunk", "model": "served-model",
+				"choices": []map[string]any{{"index": 0, "delta": map[string]any{"role": "assistant", "content": "hi"}}}},
+			{"id": "1", "object": "chat.completion.chunk", "model": "served-model",
+				"choices": []map[string]any{{"index": 0, "delta": map[string]any{}, "finish_reason": "stop"}}},
+		}
+		if req.StreamOptions.IncludeUsage {
+			chunks = append(chunks, map[string]any{"id": "1", "object": "chat.completion.chunk", "model": "served-model", "choices": []any{}, "usage": usage})
+		}
+		for _, c := range chunks {
+			data, _ := json.Marshal(c)
+			fmt.Fprintf(w, "data: %s\n\n", data)
+		}
+		fmt.Fprint(w, "data: [DONE]\n\n")
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestReplyMeta(t *testing.T) {
+	client := NewClient(fakeProvider(t).URL+"/", "x", "requested-model")
+	messages := []Message{UserMessage("hello")}
+
+	resp, err := client.ChatCompletion(context.Background(), messages, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	streamed, err := client.ChatCompletionStream(context.Background(), messages, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, r := range map[string]*Response{"completion": resp, "stream": streamed} {
+		meta := r.Message.Meta
+		if meta == nil {
+			t.Errorf("%s: no meta", name)
+			continue
+		}
+		if meta.Model != "served-model" || meta.PromptTokens != 11 || meta.CompletionTokens != 2 || meta.CreatedAt.IsZero() {
+			t.Errorf("%s: meta = %+v", name, meta)
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package llm
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/openai/openai-go/option"
+)
+
+// Ping checks that the provider answers and takes the client's API key by
+// listing its models once, without retrying.
+func (c *OpenAICompatClient) Ping(ctx context.Context) error {
+	if _, err := c.client.Models.List(ctx, option.WithMaxRetries(0)); err != nil {
+		return fmt.Errorf("listing models: %w", NewLLMError(err))
+	}
+	return nil
+}
+
+// ContextLength asks Ollama's native /api/show endpoint for the context
+// window of model, in tokens.
+func (c *OpenAICompatClient) ContextLength(ctx context.Context, model string) (int, error) {
+	base := strings.TrimSuffix(strings.TrimRight(c.baseURL, "/"), "/v1")
+	body, _ := json.Marshal(map[string]string{"model": model})
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, base+"/api/show", bytes.NewReader(body))
+	if err != nil {
+		return 0, fmt.Errorf("creating request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("showing model: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return 0, fmt.Errorf("ollama API returned %d: %s", resp.StatusCode, string(body))
+	}
+
+	// The length is under an architecture-specific key such as
+	// "qwen3.context_length".
+	var result struct {
+		ModelInfo map[string]any `json:"model_info"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return 0, fmt.Errorf("decoding response: %w", err)
+	}
+	for key, v := range result.ModelInfo {
+		if n, ok := v.(float64); ok && strings.HasSuffix(key, ".context_length") {
+			return int(n), nil
+		}
+	}
+	return 0, fmt.Errorf("ollama reported no context length for %s", model)
+}
+
+// knownContextLengths maps model name prefixes of hosted models to their
+// context windows in tokens.
+var knownContextLengths = map[string]int{
+	"claude-":          200_000,
+	"gemini-1.5-pro":   2_097_152,
+	"gemini-1.5-flash": 1_048_576,
+	"gemini-2":         1_048_576,
+	"gpt-3.5-turbo":    16_385,
+	"gpt-4-turbo":      128_000,
+	"gpt-4o":           128_000,
+	"gpt-4.1":          1_047_576,
+	"gpt-5":            400_000,
+	"o1":               200_000,
+	"o3":               200_000,
+	"o4-mini":          200_000,
+}
+
+// KnownContextLength returns the context window of a well-known hosted
+// model in tokens, or 0 if it isn't known. The longest matching prefix wins.
+func KnownContextLength(model string) int {
+	best, n := 0, 0
+	for prefix, length := range knownContextLengths {
+		if strings.HasPrefix(model, prefix) && len(prefix) > best {
+			best, n = len(prefix), length
+		}
+	}
+	return n
+}
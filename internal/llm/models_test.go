@@ -0,0 +1,64 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestPing(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.Header.Get("Authorization") != "Bearer good" {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"error":{"message":"bad key"}}`))
+			return
+		}
+		w.Write([]byte(`{"object":"list","data":[{"id":"m","object":"model"}]}`))
+	}))
+	defer srv.Close()
+
+	if err := NewClient(srv.URL+"/v1/", "good", "").Ping(context.Background()); err != nil {
+		t.Errorf("good key: %v", err)
+	}
+	if err := NewClient(srv.URL+"/v1/", "bad", "").Ping(context.Background()); err == nil {
+		t.Error("bad key: expected an error")
+	}
+}
+
+func TestContextLength(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct{ Model string }
+		json.NewDecoder(r.Body).Decode(&req)
+		if r.URL.Path != "/api/show" || req.Model != "qwen3:14b" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(`{"model_info":{"general.architecture":"qwen3","qwen3.context_length":40960}}`))
+	}))
+	defer srv.Close()
+
+	client := NewClient(srv.URL+"/v1/", "ollama", "")
+	if n, err := client.ContextLength(context.Background(), "qwen3:14b"); err != nil || n != 40960 {
+		t.Errorf("ContextLength = %d, %v; want 40960", n, err)
+	}
+	if _, err := client.ContextLength(context.Background(), "missing"); err == nil {
+		t.Error("missing model: expected an error")
+	}
+}
+
+func TestKnownContextLength(t *testing.T) {
+	for model, want := range map[string]int{
+		"claude-sonnet-4-5-20250929": 200_000,
+		"gemini-1.5-pro-002":         2_097_152,
+		"gemini-2.0-flash":           1_048_576,
+		"gpt-4o-mini":                128_000,
+		"qwen3:14b":                  0,
+	} {
+		if got := KnownContextLength(model); got != want {
+			t.Errorf("KnownContextLength(%q) = %d, want %d", model, got, want)
+		}
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"os"
 	"time"
 
 	"github.com/openai/openai-go"
@@ -22,6 +23,9 @@ func (c *OpenAICompatClient) ChatCompletionStream(ctx context.Context, messages
 	params := openai.ChatCompletionNewParams{
 		Model:    c.model,
 		Messages: convertMessages(messages),
+		// Ask for token usage in a final chunk; providers that don't
+		// support it leave the usage empty.
+		StreamOptions: openai.ChatCompletionStreamOptionsParam{IncludeUsage: param.NewOpt(true)},
 	}
 
 	if len(tools) > 0 {
@@ -30,7 +34,9 @@ func (c *OpenAICompatClient) ChatCompletionStream(ctx context.Context, messages
 
 	var stream *ssestream.Stream[openai.ChatCompletionChunk]
 	var err error
+	var start time.Time
 	for attempt := range 3 {
+		start = time.Now()
 		stream = c.client.Chat.Completions.NewStreaming(ctx, params)
 		err = stream.Err()
 		if err == nil {
@@ -42,7 +48,7 @@ func (c *OpenAICompatClient) ChatCompletionStream(ctx context.Context, messages
 		}
 		stream.Close()
 		wait := time.Duration(2<<attempt) * time.Second
-		fmt.Printf("\n  (%s, retrying in %s...)\n", llmErr.Kind, wait)
+		fmt.Fprintf(os.Stderr, "\n  (%s, retrying in %s...)\n", llmErr.Kind, wait)
 		select {
 		case <-time.After(wait):
 		case <-ctx.Done():
@@ -79,6 +85,7 @@ func (c *OpenAICompatClient) ChatCompletionStream(ctx context.Context, messages
 		Message: Message{
 			Role:    RoleAssistant,
 			Content: choice.Message.Content,
+			Meta:    c.replyMeta(acc.Model, acc.Usage, start),
 		},
 	}
 
@@ -0,0 +1,20 @@
+package llm
+
+import "encoding/json"
+
+// EstimateTokens returns an approximate token count for a message.
+// Uses chars/4 heuristic — accurate enough for context management.
+func EstimateTokens(m Message) int {
+	tokens := len(m.Content) / 4
+	for _, tc := range m.ToolCalls {
+		tokens += len(tc.Name) / 4
+		if argsJSON, err := json.Marshal(tc.Args); err == nil {
+			tokens += len(argsJSON) / 4
+		}
+	}
+	// Minimum 1 token per message for role overhead
+	if tokens == 0 {
+		tokens = 1
+	}
+	return tokens
+}
@@ -1,5 +1,7 @@
 package llm
 
+import "time"
+
 // Role represents a chat message role.
 type Role string
 
@@ -12,10 +14,22 @@ const (
 
 // Message is a single message in a conversation.
 type Message struct {
-	Role       Role       `json:"role"`
-	Content    string     `json:"content,omitempty"`
-	ToolCalls  []ToolCall `json:"tool_calls,omitempty"`
-	ToolCallID string     `json:"tool_call_id,omitempty"` // For tool result messages
+	Role       Role         `json:"role"`
+	Content    string       `json:"content,omitempty"`
+	ToolCalls  []ToolCall   `json:"tool_calls,omitempty"`
+	ToolCallID string       `json:"tool_call_id,omitempty"` // For tool result messages
+	Meta       *MessageMeta `json:"meta,omitempty"`         // Stored with the message, never sent to the provider
+}
+
+// MessageMeta records when and how a message was produced. Model and token
+// usage are set on assistant replies; latency is the time the model took to
+// reply, or for a tool result, the time the tool took to run.
+type MessageMeta struct {
+	CreatedAt        time.Time `json:"created_at"`
+	Model            string    `json:"model,omitempty"`
+	LatencyMS        int64     `json:"latency_ms,omitempty"`
+	PromptTokens     int       `json:"prompt_tokens,omitempty"`
+	CompletionTokens int       `json:"completion_tokens,omitempty"`
 }
 
 // ToolCall represents a tool invocation requested by the LLM.
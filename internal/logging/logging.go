@@ -0,0 +1,117 @@
+// Package logging sets up forge's structured logs. Loggers are named for a
+// component, each component's level can be set separately, and records
+// logged with a context carrying a request ID are tagged with it, so a
+// request's agent and tool activity can be picked out of the log.
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+)
+
+// Options configures New.
+type Options struct {
+	Format     string            // "text" (the default) or "json"
+	Level      string            // minimum level: debug, info (the default), warn, or error
+	Components map[string]string // levels for particular components, overriding Level
+}
+
+// New returns a logger writing to w as opts asks.
+func New(w io.Writer, opts Options) (*slog.Logger, error) {
+	level, err := parseLevel(opts.Level)
+	if err != nil {
+		return nil, err
+	}
+	levels := make(map[string]slog.Level, len(opts.Components))
+	lowest := level
+	for name, s := range opts.Components {
+		l, err := parseLevel(s)
+		if err != nil {
+			return nil, fmt.Errorf("component %s: %w", name, err)
+		}
+		levels[name] = l
+		lowest = min(lowest, l)
+	}
+
+	// The inner handler writes whatever it's given; ours does the filtering.
+	ho := &slog.HandlerOptions{Level: lowest}
+	var inner slog.Handler
+	switch strings.ToLower(opts.Format) {
+	case "", "text":
+		inner = slog.NewTextHandler(w, ho)
+	case "json":
+		inner = slog.NewJSONHandler(w, ho)
+	default:
+		return nil, fmt.Errorf("invalid log format %q (want text or json)", opts.Format)
+	}
+	return slog.New(&handler{Handler: inner, levels: levels, level: level}), nil
+}
+
+// parseLevel parses a level name, defaulting to info.
+func parseLevel(s string) (slog.Level, error) {
+	var l slog.Level
+	if s == "" {
+		return slog.LevelInfo, nil
+	}
+	if err := l.UnmarshalText([]byte(s)); err != nil {
+		return 0, fmt.Errorf("invalid log level %q (want debug, info, warn, or error)", s)
+	}
+	return l, nil
+}
+
+// For returns the default logger for a component.
+func For(component string) *slog.Logger {
+	return slog.Default().With("component", component)
+}
+
+type requestIDKey struct{}
+
+// WithRequestID returns a copy of ctx carrying a request ID for log records.
+func WithRequestID(ctx context.Context, id string) context.Context {
+	if id == "" {
+		return ctx
+	}
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestID returns the request ID ctx carries, if any.
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// handler filters records by their component's level and tags them with
+// their context's request ID.
+type handler struct {
+	slog.Handler
+	levels map[string]slog.Level
+	level  slog.Level
+}
+
+func (h *handler) Enabled(_ context.Context, l slog.Level) bool {
+	return l >= h.level
+}
+
+func (h *handler) Handle(ctx context.Context, r slog.Record) error {
+	if id := RequestID(ctx); id != "" {
+		r.AddAttrs(slog.String("request_id", id))
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h *handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	level := h.level
+	for _, a := range attrs {
+		if l, ok := h.levels[a.Value.String()]; ok && a.Key == "component" {
+			level = l
+		}
+	}
+	return &handler{Handler: h.Handler.WithAttrs(attrs), levels: h.levels, level: level}
+}
+
+func (h *handler) WithGroup(name string) slog.Handler {
+	return &handler{Handler: h.Handler.WithGroup(name), levels: h.levels, level: h.level}
+}
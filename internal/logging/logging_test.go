@@ -0,0 +1,49 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestComponentLevels(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := New(&buf, Options{Format: "json", Level: "warn", Components: map[string]string{"agent": "debug"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := WithRequestID(context.Background(), "req-1")
+	logger.With("component", "server").InfoContext(ctx, "dropped")
+	logger.With("component", "server").WarnContext(ctx, "kept")
+	logger.With("component", "agent").DebugContext(ctx, "llm call", "iteration", 1)
+	logger.Info("dropped too")
+
+	var got []map[string]any
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		var rec map[string]any
+		if err := json.Unmarshal([]byte(line), &rec); err != nil {
+			t.Fatalf("bad record %q: %v", line, err)
+		}
+		got = append(got, rec)
+	}
+	if len(got) != 2 || got[0]["msg"] != "kept" || got[1]["msg"] != "llm call" {
+		t.Fatalf("records = %v", got)
+	}
+	if got[1]["request_id"] != "req-1" || got[1]["component"] != "agent" || got[1]["iteration"] != 1.0 {
+		t.Errorf("record = %v", got[1])
+	}
+}
+
+func TestNewRejectsBadOptions(t *testing.T) {
+	for _, opts := range []Options{
+		{Format: "xml"},
+		{Level: "loud"},
+		{Components: map[string]string{"agent": "chatty"}},
+	} {
+		if _, err := New(&bytes.Buffer{}, opts); err == nil {
+			t.Errorf("New(%+v) succeeded", opts)
+		}
+	}
+}
@@ -0,0 +1,243 @@
+// Package mention expands @file and @url references in a chat message
+// into what they point to, so users can share context without pasting it.
+package mention
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/michaelbrown/forge/internal/document"
+)
+
+const (
+	// DefaultMaxBytes bounds how much of each reference is included.
+	DefaultMaxBytes = 100 << 10
+	// maxReferences bounds how many references one message may expand.
+	maxReferences = 10
+	// maxDownload bounds how much of a URL is downloaded, enough for
+	// most PDFs, whose text is far smaller.
+	maxDownload = 10 << 20
+)
+
+// Options controls Expand.
+type Options struct {
+	Dir      string       // relative paths are resolved against it; "" is the working directory
+	MaxBytes int          // of each reference's text; 0 is DefaultMaxBytes
+	Client   *http.Client // for URLs; nil uses one with a 30-second timeout
+}
+
+// Reference is a reference Expand included.
+type Reference struct {
+	Name      string // the path or URL, as written
+	Size      int    // bytes of text included
+	Truncated bool   // the text was cut at MaxBytes
+}
+
+// pattern matches an @ at the start of the message or after whitespace,
+// and what follows it up to the next whitespace.
+var pattern = regexp.MustCompile(`(^|\s)@(\S+)`)
+
+var defaultClient = &http.Client{Timeout: 30 * time.Second}
+
+// Expand finds @path and @http(s)://… references in message and appends
+// the text of each to it in a fenced block, leaving the message itself as
+// written. A path is only a reference if the file exists, so @names and the
+// like are left alone; trailing punctuation is dropped from references, as
+// in "see @main.go.". Files and pages are included as text, and PDF and
+// DOCX documents and HTML pages as their extracted text, up to MaxBytes
+// each. A reference that can't be read is an error.
+func Expand(ctx context.Context, message string, opts Options) (string, []Reference, error) {
+	if opts.MaxBytes <= 0 {
+		opts.MaxBytes = DefaultMaxBytes
+	}
+	if opts.Client == nil {
+		opts.Client = defaultClient
+	}
+
+	var (
+		refs   []Reference
+		blocks []string
+		seen   = map[string]bool{}
+	)
+	for _, m := range pattern.FindAllStringSubmatch(message, -1) {
+		name, text, err := resolve(ctx, m[2], opts)
+		if err != nil {
+			return "", nil, err
+		}
+		if name == "" || seen[name] {
+			continue
+		}
+		seen[name] = true
+		if len(refs) == maxReferences {
+			return "", nil, fmt.Errorf("too many references: at most %d per message", maxReferences)
+		}
+
+		ref := Reference{Name: name}
+		if len(text) > opts.MaxBytes {
+			text = cut(text, opts.MaxBytes)
+			ref.Truncated = true
+		}
+		ref.Size = len(text)
+		refs = append(refs, ref)
+
+		block := fmt.Sprintf("Contents of %s:\n%s", name, fence(text, language(name)))
+		if ref.Truncated {
+			block += fmt.Sprintf("\n(truncated to the first %d KB)", opts.MaxBytes>>10)
+		}
+		blocks = append(blocks, block)
+	}
+	if len(refs) == 0 {
+		return message, nil, nil
+	}
+	return message + "\n\n" + strings.Join(blocks, "\n\n"), refs, nil
+}
+
+// resolve returns the name and text of what word refers to, or "" if it
+// isn't a reference.
+func resolve(ctx context.Context, word string, opts Options) (name, text string, err error) {
+	if strings.HasPrefix(word, "http://") || strings.HasPrefix(word, "https://") {
+		url := trimURL(word)
+		text, err := fetch(ctx, url, opts.Client)
+		if err != nil {
+			return "", "", fmt.Errorf("fetching %s: %w", url, err)
+		}
+		return url, text, nil
+	}
+
+	// Try the word as written, then without trailing punctuation.
+	for _, candidate := range []string{word, strings.TrimRight(word, ".,;:!?)]}'\"")} {
+		path := candidate
+		if rest, ok := strings.CutPrefix(path, "~/"); ok {
+			if home, err := os.UserHomeDir(); err == nil {
+				path = filepath.Join(home, rest)
+			}
+		}
+		if !filepath.IsAbs(path) && opts.Dir != "" {
+			path = filepath.Join(opts.Dir, path)
+		}
+		info, err := os.Stat(path)
+		if err != nil || !info.Mode().IsRegular() {
+			continue
+		}
+		text, err := readFile(path)
+		if err != nil {
+			return "", "", fmt.Errorf("reading %s: %w", candidate, err)
+		}
+		return candidate, text, nil
+	}
+	return "", "", nil
+}
+
+// readFile returns a file's text: text files as written, PDF and DOCX
+// documents extracted.
+func readFile(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	switch document.Detect(data, path, "") {
+	case document.PDF, document.DOCX:
+		return extract(data, path, "")
+	}
+	if !utf8.Valid(data) {
+		return "", errors.New("not a text file")
+	}
+	return string(data), nil
+}
+
+// fetch downloads url and returns its text.
+func fetch(ctx context.Context, url string, client *http.Client) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("User-Agent", "Forge/0.1")
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 400 {
+		return "", fmt.Errorf("HTTP %s", resp.Status)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxDownload))
+	if err != nil {
+		return "", err
+	}
+	return extract(data, resp.Request.URL.String(), resp.Header.Get("Content-Type"))
+}
+
+// extract returns the text of a document, its pages separated by blank
+// lines.
+func extract(data []byte, name, contentType string) (string, error) {
+	doc, err := document.Extract(data, name, contentType)
+	if err != nil {
+		return "", err
+	}
+	text := strings.Join(doc.Pages, "\n\n")
+	if doc.Title != "" {
+		text = "# " + doc.Title + "\n\n" + text
+	}
+	return text, nil
+}
+
+// trimURL drops punctuation that more likely ends the sentence than the
+// URL, keeping a closing parenthesis the URL opened.
+func trimURL(url string) string {
+	for {
+		trimmed := strings.TrimRight(url, ".,;:!?'\"")
+		if strings.HasSuffix(trimmed, ")") && strings.Count(trimmed, "(") < strings.Count(trimmed, ")") {
+			trimmed = strings.TrimSuffix(trimmed, ")")
+		}
+		if trimmed == url {
+			return url
+		}
+		url = trimmed
+	}
+}
+
+// cut returns the first n bytes of s, backing off to a rune boundary.
+func cut(s string, n int) string {
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
+
+// fence wraps text in a code fence longer than any run of backticks in it.
+func fence(text, lang string) string {
+	longest, run := 0, 0
+	for _, r := range text {
+		if r == '`' {
+			run++
+			longest = max(longest, run)
+		} else {
+			run = 0
+		}
+	}
+	marker := strings.Repeat("`", max(3, longest+1))
+	return marker + lang + "\n" + strings.TrimRight(text, "\n") + "\n" + marker
+}
+
+// language names the code fence's language from a file's extension, for
+// files included as written.
+func language(name string) string {
+	if strings.Contains(name, "://") {
+		return ""
+	}
+	switch ext := strings.TrimPrefix(strings.ToLower(filepath.Ext(name)), "."); ext {
+	case "pdf", "docx":
+		return ""
+	default:
+		return ext
+	}
+}
@@ -0,0 +1,136 @@
+package mention
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeFile(t *testing.T, dir, name, content string) {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestExpand_Files(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "main.go", "package main\n")
+	writeFile(t, dir, "notes.txt", "remember\n")
+
+	got, refs, err := Expand(context.Background(), "@main.go: why? Compare @notes.txt. Ask @alice, or mail bob@main.go", Options{Dir: dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "@main.go: why? Compare @notes.txt. Ask @alice, or mail bob@main.go\n\n" +
+		"Contents of main.go:\n```go\npackage main\n```\n\n" +
+		"Contents of notes.txt:\n```txt\nremember\n```"
+	if got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+	if len(refs) != 2 || refs[0] != (Reference{Name: "main.go", Size: 13}) || refs[1].Name != "notes.txt" {
+		t.Errorf("refs = %+v", refs)
+	}
+}
+
+func TestExpand_NoReferences(t *testing.T) {
+	msg := "ping @nobody about @missing/file.go"
+	got, refs, err := Expand(context.Background(), msg, Options{Dir: t.TempDir()})
+	if err != nil || got != msg || refs != nil {
+		t.Errorf("got %q, %v, %v; want the message unchanged", got, refs, err)
+	}
+}
+
+func TestExpand_RepeatedReference(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "a.md", "x")
+	got, refs, err := Expand(context.Background(), "@a.md and again @a.md", Options{Dir: dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(refs) != 1 || strings.Count(got, "Contents of") != 1 {
+		t.Errorf("a repeated reference should be included once, got %d: %s", len(refs), got)
+	}
+}
+
+func TestExpand_Truncates(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "big.txt", strings.Repeat("é", 2000))
+	got, refs, err := Expand(context.Background(), "@big.txt", Options{Dir: dir, MaxBytes: 1025})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(refs) != 1 || !refs[0].Truncated || refs[0].Size != 1024 {
+		t.Errorf("refs = %+v, want 1024 bytes, truncated", refs)
+	}
+	if !strings.HasSuffix(got, "(truncated to the first 1 KB)") {
+		t.Errorf("missing truncation note: %s", got[len(got)-60:])
+	}
+}
+
+func TestExpand_FenceAroundBackticks(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "README.md", "# Title\n\n```sh\nmake\n```\n")
+	got, _, err := Expand(context.Background(), "@README.md", Options{Dir: dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(got, "````md\n# Title") || !strings.HasSuffix(got, "```\n````") {
+		t.Errorf("fence should outlast the file's own:\n%s", got)
+	}
+}
+
+func TestExpand_BinaryFile(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "blob.bin", "\xff\xfe\x00")
+	_, _, err := Expand(context.Background(), "look at @blob.bin", Options{Dir: dir})
+	if err == nil || !strings.Contains(err.Error(), "blob.bin: not a text file") {
+		t.Errorf("err = %v, want not a text file", err)
+	}
+}
+
+func TestExpand_URL(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/page":
+			w.Header().Set("Content-Type", "text/html")
+			w.Write([]byte("<html><head><title>Docs</title></head><body><main><p>Hello <b>there</b>.</p></main></body></html>"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	got, refs, err := Expand(context.Background(), "summarize @"+srv.URL+"/page.", Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(refs) != 1 || refs[0].Name != srv.URL+"/page" {
+		t.Fatalf("refs = %+v", refs)
+	}
+	if !strings.Contains(got, "Contents of "+srv.URL+"/page:\n```\n# Docs\n\nHello **there**.") {
+		t.Errorf("got:\n%s", got)
+	}
+
+	_, _, err = Expand(context.Background(), "@"+srv.URL+"/missing", Options{})
+	if err == nil || !strings.Contains(err.Error(), "404") {
+		t.Errorf("err = %v, want the 404", err)
+	}
+}
+
+func TestTrimURL(t *testing.T) {
+	for in, want := range map[string]string{
+		"https://example.com/a.":                  "https://example.com/a",
+		"https://example.com/a),":                 "https://example.com/a",
+		"https://en.wikipedia.org/wiki/Go_(lang)": "https://en.wikipedia.org/wiki/Go_(lang)",
+		"https://example.com/?q=1":                "https://example.com/?q=1",
+	} {
+		if got := trimURL(in); got != want {
+			t.Errorf("trimURL(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
@@ -0,0 +1,50 @@
+// Package notify shows desktop notifications: with osascript on macOS, and
+// with notify-send, from libnotify, on Linux and the BSDs.
+package notify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// Send shows a notification with title and body.
+func Send(ctx context.Context, title, body string) error {
+	argv, err := command(runtime.GOOS, title, body)
+	if err != nil {
+		return err
+	}
+	out, err := exec.CommandContext(ctx, argv[0], argv[1:]...).CombinedOutput()
+	switch {
+	case errors.Is(err, exec.ErrNotFound) && argv[0] == "notify-send":
+		return errors.New("notify-send not found; install libnotify (libnotify-bin on Debian and Ubuntu)")
+	case err != nil:
+		if msg := strings.TrimSpace(string(out)); msg != "" {
+			return fmt.Errorf("%s: %s", argv[0], msg)
+		}
+		return fmt.Errorf("%s: %w", argv[0], err)
+	}
+	return nil
+}
+
+// command returns the command that shows a notification on goos.
+func command(goos, title, body string) ([]string, error) {
+	switch goos {
+	case "darwin":
+		script := fmt.Sprintf("display notification %s with title %s", appleScriptString(body), appleScriptString(title))
+		return []string{"osascript", "-e", script}, nil
+	case "linux", "freebsd", "openbsd", "netbsd", "dragonfly":
+		return []string{"notify-send", "--app-name=Forge", "--", title, body}, nil
+	}
+	return nil, fmt.Errorf("desktop notifications aren't supported on %s", goos)
+}
+
+// appleScriptString quotes s as an AppleScript string literal.
+func appleScriptString(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, `"`, `\"`)
+	return `"` + s + `"`
+}
@@ -0,0 +1,30 @@
+package notify
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestCommand(t *testing.T) {
+	argv, err := command("darwin", `Forge: "fix" it`, `C:\temp done`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"osascript", "-e", `display notification "C:\\temp done" with title "Forge: \"fix\" it"`}
+	if !slices.Equal(argv, want) {
+		t.Errorf("darwin: got %q, want %q", argv, want)
+	}
+
+	argv, err = command("linux", "Forge", "-n is not a flag")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = []string{"notify-send", "--app-name=Forge", "--", "Forge", "-n is not a flag"}
+	if !slices.Equal(argv, want) {
+		t.Errorf("linux: got %q, want %q", argv, want)
+	}
+
+	if _, err := command("windows", "Forge", "done"); err == nil {
+		t.Error("windows: expected an error")
+	}
+}
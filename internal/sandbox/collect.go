@@ -0,0 +1,141 @@
+package sandbox
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// OutputDir is where runs can leave files to be collected, such as charts
+// or reports. Programs also find it in $OUTPUT_DIR.
+const OutputDir = "/output"
+
+// maxOutputFiles bounds how many files one run can leave in OutputDir.
+const maxOutputFiles = 20
+
+// OutputFile is a file a run left in OutputDir.
+type OutputFile struct {
+	Name string // relative to OutputDir, with forward slashes
+	Data []byte
+}
+
+// addOutput records a file a run left in OutputDir, unless it would take
+// the run past limit bytes or maxOutputFiles files, in which case it is
+// only named in OutputsDropped. Empty files are skipped.
+func (r *ExecResult) addOutput(name string, size, limit int64, read func() ([]byte, error)) error {
+	if size == 0 {
+		return nil
+	}
+	total := size
+	for _, f := range r.Outputs {
+		total += int64(len(f.Data))
+	}
+	if total > limit || len(r.Outputs) >= maxOutputFiles {
+		r.OutputsDropped = append(r.OutputsDropped, name)
+		return nil
+	}
+	data, err := read()
+	if err != nil {
+		return fmt.Errorf("reading %s: %w", name, err)
+	}
+	r.Outputs = append(r.Outputs, OutputFile{Name: name, Data: data})
+	return nil
+}
+
+// collectOutputs copies what a run left in OutputDir out of container ref,
+// running or stopped, into result. The archive is read as docker cp writes
+// it, and the copy is stopped once it runs past what limit allows, so a run
+// can't make the server hold more than that; files after that point aren't
+// named.
+func collectOutputs(ctx context.Context, ref string, limit int64, result *ExecResult) error {
+	// docker cp writes a tar archive of the directory, entries prefixed
+	// with its name, to stdout.
+	cmd := exec.CommandContext(ctx, "docker", "cp", ref+":"+OutputDir, "-")
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return fmt.Errorf("collecting outputs: %w", err)
+	}
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("collecting outputs: %w", err)
+	}
+
+	archive := &io.LimitedReader{R: stdout, N: limit + tarOverhead}
+	err = readOutputs(tar.NewReader(archive), limit, result)
+	if err == nil {
+		// Read the end of the archive so the copy can finish.
+		_, err = io.Copy(io.Discard, archive)
+	}
+	stopped := archive.N <= 0
+	if stopped || err != nil {
+		cmd.Process.Kill()
+	}
+	waitErr := cmd.Wait()
+	switch {
+	case stopped:
+		return nil
+	case err != nil:
+		return fmt.Errorf("collecting outputs: %w", err)
+	case waitErr != nil:
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return fmt.Errorf("collecting outputs: %s", msg)
+		}
+		return fmt.Errorf("collecting outputs: %w", waitErr)
+	}
+	return nil
+}
+
+// tarOverhead is how much of an archive, beyond the files' contents, is
+// read for headers, directories and padding.
+const tarOverhead = 1 << 20
+
+// readOutputs adds the regular files in the archive tr to result.
+func readOutputs(tr *tar.Reader, limit int64, result *ExecResult) error {
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		_, name, _ := strings.Cut(path.Clean(hdr.Name), "/")
+		if err := result.addOutput(name, hdr.Size, limit, func() ([]byte, error) { return io.ReadAll(tr) }); err != nil {
+			return err
+		}
+	}
+}
+
+// collectDir collects the regular files a native run left in dir, the
+// run's OutputDir. Symlinks are skipped, so a run can't point one at the
+// server's files.
+func collectDir(dir string, limit int64, result *ExecResult) error {
+	return filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || !d.Type().IsRegular() {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		return result.addOutput(filepath.ToSlash(rel), info.Size(), limit, func() ([]byte, error) {
+			return os.ReadFile(p)
+		})
+	})
+}
@@ -0,0 +1,110 @@
+package sandbox
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeCp puts a docker on PATH whose "cp" runs script, with the archive
+// in $FAKE_DOCKER_DIR/archive.tar.
+func fakeCp(t *testing.T, archive []byte, script string) {
+	t.Helper()
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "archive.tar"), archive, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "docker"), []byte("#!/bin/sh\n"+script+"\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+	t.Setenv("FAKE_DOCKER_DIR", dir)
+}
+
+// tarFile is a file in an archive built by tarOf.
+type tarFile struct {
+	name string
+	size int64 // negative for a huge file the archive ends in the middle of
+}
+
+// tarOf returns an archive of files, as docker cp writes one for
+// /output, each filled with size bytes.
+func tarOf(t *testing.T, files ...tarFile) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	tw.WriteHeader(&tar.Header{Name: "output/", Typeflag: tar.TypeDir, Mode: 0o755})
+	for _, f := range files {
+		size := f.size
+		if size < 0 {
+			size = 1 << 40
+		}
+		if err := tw.WriteHeader(&tar.Header{Name: "output/" + f.name, Typeflag: tar.TypeReg, Mode: 0o644, Size: size}); err != nil {
+			t.Fatal(err)
+		}
+		if f.size < 0 {
+			return buf.Bytes()
+		}
+		tw.Write(bytes.Repeat([]byte("x"), int(size)))
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestCollectOutputs(t *testing.T) {
+	fakeCp(t, tarOf(t, tarFile{"a.txt", 10}, tarFile{"big.bin", 100}, tarFile{"charts/plot.png", 3}, tarFile{"empty", 0}),
+		`cat "$FAKE_DOCKER_DIR/archive.tar"`)
+	result := &ExecResult{}
+	if err := collectOutputs(context.Background(), "ctr-1", 16, result); err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, f := range result.Outputs {
+		got = append(got, f.Name+"="+string(f.Data))
+	}
+	if want := []string{"a.txt=xxxxxxxxxx", "charts/plot.png=xxx"}; !slices.Equal(got, want) {
+		t.Errorf("Outputs = %q, want %q", got, want)
+	}
+	if want := []string{"big.bin"}; !slices.Equal(result.OutputsDropped, want) {
+		t.Errorf("OutputsDropped = %q, want %q", result.OutputsDropped, want)
+	}
+
+	// A failed copy reports docker's message.
+	fakeCp(t, nil, `echo "Error: No such container:path: ctr-1:/output" >&2; exit 1`)
+	err := collectOutputs(context.Background(), "ctr-1", 16, &ExecResult{})
+	if err == nil || !strings.Contains(err.Error(), "No such container:path") {
+		t.Errorf("collectOutputs = %v, want docker's error", err)
+	}
+}
+
+func TestCollectOutputsStops(t *testing.T) {
+	// The archive names a huge file and then never ends; collection keeps
+	// what came before it and kills the copy rather than read it all.
+	fakeCp(t, tarOf(t, tarFile{"a.txt", 10}, tarFile{"huge.bin", -1}),
+		`cat "$FAKE_DOCKER_DIR/archive.tar"; exec cat /dev/zero`)
+	result := &ExecResult{}
+	done := make(chan error, 1)
+	go func() { done <- collectOutputs(context.Background(), "ctr-1", 16, result) }()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("collectOutputs read on past the limit")
+	}
+	if len(result.Outputs) != 1 || result.Outputs[0].Name != "a.txt" {
+		t.Errorf("Outputs = %+v, want a.txt", result.Outputs)
+	}
+	if want := []string{"huge.bin"}; !slices.Equal(result.OutputsDropped, want) {
+		t.Errorf("OutputsDropped = %q, want %q", result.OutputsDropped, want)
+	}
+}
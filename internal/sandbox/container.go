@@ -0,0 +1,207 @@
+package sandbox
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Container is a long-lived sandbox container. Commands run in it one after
+// another, and files, installed packages, and other state in /workspace
+// carry over between them until it is stopped.
+type Container struct {
+	ID    string
+	Image string
+}
+
+// StartOpts describes a container to start.
+type StartOpts struct {
+	Image  string
+	Env    []string
+	Mounts []Mount
+	// ReadOnly makes everything but /workspace, /tmp, and OutputDir, if
+	// the policy has one, read-only, so runs can't leave changes
+	// elsewhere for later ones to trip over.
+	ReadOnly bool
+}
+
+// Start runs a container that idles until commands are sent to it. It has
+// the policy's runtime, memory and network limits, and a writable
+// /workspace.
+func (d *DockerSandbox) Start(ctx context.Context, opts StartOpts) (*Container, error) {
+	if !d.Policy.IsImageAllowed(opts.Image) {
+		return nil, fmt.Errorf("image %q not in allowlist", opts.Image)
+	}
+
+	args := []string{"run", "-d", "--rm", "--init"}
+	args = append(args, d.Policy.runArgs()...)
+	args = append(args, "-w", "/workspace", "--label", "forge.sandbox=1")
+	if opts.ReadOnly {
+		// docker cp can write to a volume but not to a tmpfs.
+		args = append(args, "--read-only", "-v", "/workspace")
+		if d.Policy.MaxDisk == "" {
+			args = append(args, "--tmpfs", "/tmp:rw,exec")
+		}
+		if d.Policy.MaxOutput != "" {
+			args = append(args, "-v", OutputDir)
+		}
+	}
+	for _, m := range opts.Mounts {
+		spec := m.Source + ":" + m.Target
+		if m.ReadOnly {
+			spec += ":ro"
+		}
+		args = append(args, "-v", spec)
+	}
+	for _, e := range opts.Env {
+		args = append(args, "-e", e)
+	}
+	args = append(args, opts.Image, "tail", "-f", "/dev/null")
+
+	out, err := docker(ctx, args...)
+	if err != nil {
+		return nil, fmt.Errorf("starting container: %w", err)
+	}
+	return &Container{ID: strings.TrimSpace(out), Image: opts.Image}, nil
+}
+
+// WriteFiles copies files, keyed by path relative to /workspace, into the
+// container, replacing any that exist.
+func (c *Container) WriteFiles(ctx context.Context, files map[string]string) error {
+	if len(files) == 0 {
+		return nil
+	}
+	tmpDir, err := os.MkdirTemp("", "forge-sandbox-*")
+	if err != nil {
+		return fmt.Errorf("creating temp dir: %w", err)
+	}
+	defer os.RemoveAll(tmpDir)
+	if err := writeFiles(tmpDir, files); err != nil {
+		return err
+	}
+	if _, err := docker(ctx, "cp", tmpDir+"/.", c.ID+":/workspace"); err != nil {
+		return fmt.Errorf("copying files: %w", err)
+	}
+	return nil
+}
+
+// ReadFile copies a regular file, given by path relative to /workspace, out
+// of the container. Files larger than maxBytes are refused.
+func (c *Container) ReadFile(ctx context.Context, name string, maxBytes int64) ([]byte, error) {
+	rel, err := workspacePath("/workspace", name)
+	if err != nil {
+		return nil, err
+	}
+	// docker cp writes a tar archive to stdout.
+	cmd := exec.CommandContext(ctx, "docker", "cp", c.ID+":"+filepath.ToSlash(rel), "-")
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("reading %s: %s", name, msg)
+		}
+		return nil, fmt.Errorf("reading %s: %w", name, err)
+	}
+
+	tr := tar.NewReader(&stdout)
+	hdr, err := tr.Next()
+	if err != nil {
+		return nil, fmt.Errorf("reading %s: %w", name, err)
+	}
+	if hdr.Typeflag != tar.TypeReg {
+		return nil, fmt.Errorf("%s is not a regular file", name)
+	}
+	if hdr.Size > maxBytes {
+		return nil, fmt.Errorf("%s is larger than %d MB", name, maxBytes>>20)
+	}
+	return io.ReadAll(tr)
+}
+
+// Exec runs command in /workspace, passing its output to onOutput, if
+// set, as it is written. When ctx ends first, docker stops waiting but the
+// process keeps running in the container; callers that time out should
+// Stop it.
+func (c *Container) Exec(ctx context.Context, command []string, stdin string, onOutput OutputFunc) (*ExecResult, error) {
+	result, err := c.run(ctx, command, stdin, nil, onOutput)
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// run runs command in /workspace with env added to the container's. When
+// ctx ends first, it returns the output so far along with ctx's error.
+func (c *Container) run(ctx context.Context, command []string, stdin string, env []string, onOutput OutputFunc) (*ExecResult, error) {
+	args := []string{"exec", "-w", "/workspace"}
+	if stdin != "" {
+		args = append(args, "-i")
+	}
+	for _, e := range env {
+		args = append(args, "-e", e)
+	}
+	command, marker := withMetrics(command, false)
+	args = append(append(args, c.ID), command...)
+
+	cmd := exec.CommandContext(ctx, "docker", args...)
+	stdout, stderr := newOutputs(onOutput, marker)
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	if stdin != "" {
+		cmd.Stdin = strings.NewReader(stdin)
+	}
+
+	started := time.Now()
+	err := cmd.Run()
+	stderr.flush()
+	if ctx.Err() != nil {
+		return &ExecResult{Stdout: stdout.String(), Stderr: stderr.String(), ExitCode: -1, Duration: time.Since(started)}, ctx.Err()
+	}
+	exitCode := 0
+	if err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			exitCode = exitErr.ExitCode()
+		} else {
+			return nil, fmt.Errorf("running docker: %w", err)
+		}
+	}
+	result := &ExecResult{
+		Stdout:   stdout.String(),
+		Stderr:   stderr.String(),
+		ExitCode: exitCode,
+		Duration: time.Since(started),
+	}
+	parseMetrics(result, marker)
+	return result, nil
+}
+
+// Stop removes the container.
+func (c *Container) Stop() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	_, err := docker(ctx, "rm", "-f", c.ID)
+	return err
+}
+
+// docker runs a docker command and returns its stdout, folding stderr into
+// the error when it fails.
+func docker(ctx context.Context, args ...string) (string, error) {
+	cmd := exec.CommandContext(ctx, "docker", args...)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("%w: %s", err, msg)
+		}
+		return "", err
+	}
+	return stdout.String(), nil
+}
@@ -1,13 +1,15 @@
 package sandbox
 
 import (
-	"bytes"
 	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"fmt"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"strings"
+	"time"
 )
 
 // DockerSandbox runs code in Docker containers.
@@ -24,6 +26,10 @@ func (d *DockerSandbox) Exec(ctx context.Context, opts ExecOpts) (*ExecResult, e
 	if !d.Policy.IsImageAllowed(opts.Image) {
 		return nil, fmt.Errorf("image %q not in allowlist", opts.Image)
 	}
+	outputLimit, err := d.Policy.outputBytes()
+	if err != nil {
+		return nil, err
+	}
 
 	// Create a temp dir for the code file
 	tmpDir, err := os.MkdirTemp("", "forge-sandbox-*")
@@ -33,9 +39,8 @@ func (d *DockerSandbox) Exec(ctx context.Context, opts ExecOpts) (*ExecResult, e
 	defer os.RemoveAll(tmpDir)
 
 	// Write code to a file
-	codePath := filepath.Join(tmpDir, "code")
-	if err := os.WriteFile(codePath, []byte(opts.Code), 0o644); err != nil {
-		return nil, fmt.Errorf("writing code file: %w", err)
+	if err := writeFiles(tmpDir, opts.files()); err != nil {
+		return nil, err
 	}
 
 	// Write stdin if provided
@@ -47,47 +52,187 @@ func (d *DockerSandbox) Exec(ctx context.Context, opts ExecOpts) (*ExecResult, e
 		}
 	}
 
-	// Build docker command
-	timeout := d.Policy.MaxTimeout
+	// Pull the image first, so the time it takes doesn't count against the
+	// run.
+	if err := ensureImage(ctx, opts.Image); err != nil {
+		return nil, err
+	}
+
+	// Build docker command. The container is named so it can be killed
+	// when the run times out: killing the docker client leaves it running.
+	name, err := containerName()
+	if err != nil {
+		return nil, err
+	}
+	args := []string{"run", "--name", name, "--label", "forge.sandbox=1"}
+	if outputLimit > 0 {
+		// The container is kept after it exits, until its outputs are
+		// copied out.
+		args = append(args, "-v", OutputDir, "-e", "OUTPUT_DIR="+OutputDir)
+		defer removeContainer(name)
+	} else {
+		args = append(args, "--rm")
+	}
+	args = append(args, d.Policy.runArgs()...)
+	args = append(args, "-v", tmpDir+":/workspace:ro", "-w", "/workspace")
 
-	args := []string{
-		"run", "--rm",
-		"--memory", d.Policy.MaxMemory,
-		"--stop-timeout", fmt.Sprintf("%d", int(timeout.Seconds())),
-		"-v", tmpDir + ":/workspace:ro",
-		"-w", "/workspace",
+	for _, m := range opts.Mounts {
+		spec := m.Source + ":" + m.Target
+		if m.ReadOnly {
+			spec += ":ro"
+		}
+		args = append(args, "-v", spec)
+	}
+	for _, e := range opts.Env {
+		args = append(args, "-e", e)
 	}
 
-	if !d.Policy.Network {
-		args = append(args, "--network=none")
+	// Without -i, docker doesn't pass stdin through to the container.
+	if opts.Stdin != "" {
+		args = append(args, "-i")
 	}
 
+	command, marker := withMetrics(opts.Command, true)
 	args = append(args, opts.Image)
-	args = append(args, opts.Command...)
+	args = append(args, command...)
 
-	cmd := exec.CommandContext(ctx, "docker", args...)
+	runCtx := ctx
+	if timeout := d.Policy.MaxTimeout; timeout > 0 {
+		var cancel context.CancelFunc
+		runCtx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	cmd := exec.CommandContext(runCtx, "docker", args...)
 
-	var stdout, stderr bytes.Buffer
-	cmd.Stdout = &stdout
-	cmd.Stderr = &stderr
+	stdout, stderr := newOutputs(opts.OnOutput, marker)
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
 
 	if opts.Stdin != "" {
 		cmd.Stdin = strings.NewReader(opts.Stdin)
 	}
 
+	started := time.Now()
 	err = cmd.Run()
-	exitCode := 0
-	if err != nil {
-		if exitErr, ok := err.(*exec.ExitError); ok {
-			exitCode = exitErr.ExitCode()
-		} else {
-			return nil, fmt.Errorf("running docker: %w", err)
+	stderr.flush()
+	result := &ExecResult{
+		Stdout:   stdout.String(),
+		Stderr:   stderr.String(),
+		Duration: time.Since(started),
+	}
+	if runCtx.Err() != nil {
+		killContainer(name)
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		result.ExitCode = -1
+		result.TimedOut = true
+	} else {
+		if err != nil {
+			exitErr, ok := err.(*exec.ExitError)
+			if !ok {
+				return nil, fmt.Errorf("running docker: %w", err)
+			}
+			result.ExitCode = exitErr.ExitCode()
+		}
+		parseMetrics(result, marker)
+	}
+	// What a run that timed out wrote before it was killed is kept too.
+	if outputLimit > 0 {
+		if err := collectOutputs(ctx, name, outputLimit, result); err != nil {
+			return nil, err
 		}
 	}
+	return result, nil
+}
 
-	return &ExecResult{
-		Stdout:   stdout.String(),
-		Stderr:   stderr.String(),
-		ExitCode: exitCode,
-	}, nil
+// ImagePresent reports whether image has been pulled.
+func ImagePresent(ctx context.Context, image string) bool {
+	_, err := docker(ctx, "image", "inspect", "--format", "{{.Id}}", image)
+	return err == nil
+}
+
+// PullImage pulls image, which can take minutes for a large one.
+func PullImage(ctx context.Context, image string) error {
+	if _, err := docker(ctx, "pull", "--quiet", image); err != nil {
+		return fmt.Errorf("pulling %s: %w", image, err)
+	}
+	return nil
+}
+
+// ensureImage pulls image unless it is already present.
+func ensureImage(ctx context.Context, image string) error {
+	if ImagePresent(ctx, image) {
+		return nil
+	}
+	return PullImage(ctx, image)
+}
+
+// containerName returns a name for a one-off sandbox container.
+func containerName() (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("naming container: %w", err)
+	}
+	return "forge-sandbox-" + hex.EncodeToString(b), nil
+}
+
+// killContainer kills a container whose run was cut short; --rm or
+// removeContainer then removes it.
+func killContainer(name string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	docker(ctx, "kill", name)
+}
+
+// removeContainer removes a one-off container run without --rm, along
+// with its volumes.
+func removeContainer(name string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	docker(ctx, "rm", "-f", "-v", name)
+}
+
+// files returns the files to place in /workspace: Files, plus Code saved
+// as Filename.
+func (opts ExecOpts) files() map[string]string {
+	files := make(map[string]string, len(opts.Files)+1)
+	for name, content := range opts.Files {
+		files[name] = content
+	}
+	if opts.Code != "" || len(opts.Files) == 0 {
+		filename := opts.Filename
+		if filename == "" {
+			filename = "code"
+		}
+		files[filename] = opts.Code
+	}
+	return files
+}
+
+// writeFiles writes files, keyed by path relative to dir, into dir.
+func writeFiles(dir string, files map[string]string) error {
+	for name, content := range files {
+		path, err := workspacePath(dir, name)
+		if err != nil {
+			return err
+		}
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			return fmt.Errorf("writing %s: %w", name, err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			return fmt.Errorf("writing %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// workspacePath maps a relative file name to its path under dir, refusing
+// names that would land outside it.
+func workspacePath(dir, name string) (string, error) {
+	clean := filepath.Clean(filepath.FromSlash(name))
+	if name == "" || filepath.IsAbs(clean) || clean == "." || clean == ".." || strings.HasPrefix(clean, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("invalid file path %q: must be relative and stay inside /workspace", name)
+	}
+	return filepath.Join(dir, clean), nil
 }
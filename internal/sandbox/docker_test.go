@@ -0,0 +1,36 @@
+package sandbox
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestWorkspacePath(t *testing.T) {
+	dir := filepath.FromSlash("/work")
+	tests := []struct {
+		name string
+		want string // empty when refused
+	}{
+		{"main.py", "/work/main.py"},
+		{"src/util.py", "/work/src/util.py"},
+		{"./src/../main.py", "/work/main.py"},
+		{"a/b/../../c", "/work/c"},
+		{"..hidden", "/work/..hidden"},
+		{"", ""},
+		{".", ""},
+		{"..", ""},
+		{"../escape", ""},
+		{"src/../../escape", ""},
+		{"/etc/passwd", ""},
+	}
+
+	for _, tt := range tests {
+		got, err := workspacePath(dir, tt.name)
+		switch {
+		case tt.want == "" && err == nil:
+			t.Errorf("workspacePath(%q) = %q, want an error", tt.name, got)
+		case tt.want != "" && (err != nil || got != filepath.FromSlash(tt.want)):
+			t.Errorf("workspacePath(%q) = %q, %v; want %q", tt.name, got, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package sandbox
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"strconv"
+	"strings"
+)
+
+// Containers don't report how much memory a run used or whether it was
+// OOM-killed once they exit, so commands run under a shell that reads
+// both from the container's cgroup afterwards, cgroup v2 or v1, and adds
+// them to stderr behind a marker for parseMetrics to take out again. The
+// shell uses only builtins, so any image with sh works; where the cgroup
+// files can't be read, the metrics are left out.
+
+// metricsScript runs "$@", then writes the marker, the peak memory use in
+// bytes (if $PEAK is set), and how many processes were OOM-killed during
+// the run, and exits with the command's status.
+const metricsScript = `oom() { for f in /sys/fs/cgroup/memory.events /sys/fs/cgroup/memory/memory.oom_control; do [ -r "$f" ] || continue; while read -r k v; do [ "$k" = oom_kill ] && { echo "$v"; return; }; done < "$f"; done; echo 0; }
+b=$(oom); "$@"; rc=$?; p=
+if [ -n "$PEAK" ]; then for f in /sys/fs/cgroup/memory.peak /sys/fs/cgroup/memory/memory.max_usage_in_bytes; do [ -r "$f" ] && { read -r p < "$f"; break; }; done; fi
+echo "$MARKER ${p:-0} $(( $(oom) - b ))" >&2; exit $rc`
+
+// withMetrics wraps command to report metrics, and returns the marker
+// they follow. peak is false in long-lived containers, where the cgroup's
+// peak covers every run since the container started.
+func withMetrics(command []string, peak bool) ([]string, string) {
+	b := make([]byte, 8)
+	rand.Read(b)
+	marker := "forge-metrics-" + hex.EncodeToString(b)
+	script := "MARKER=" + marker + "; "
+	if peak {
+		script += "PEAK=1; "
+	}
+	return append([]string{"sh", "-c", script + metricsScript, "sh"}, command...), marker
+}
+
+// parseMetrics takes the metrics line behind marker out of result's
+// stderr and records what it says.
+func parseMetrics(result *ExecResult, marker string) {
+	i := strings.LastIndex(result.Stderr, marker+" ")
+	if i < 0 {
+		return
+	}
+	line, rest, _ := strings.Cut(result.Stderr[i:], "\n")
+	result.Stderr = result.Stderr[:i] + rest
+	fields := strings.Fields(line)
+	if len(fields) != 3 {
+		return
+	}
+	result.MaxMemory, _ = strconv.ParseInt(fields[1], 10, 64)
+	if n, _ := strconv.Atoi(fields[2]); n > 0 {
+		result.OOMKilled = true
+	}
+}
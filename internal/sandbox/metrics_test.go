@@ -0,0 +1,69 @@
+package sandbox
+
+import (
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+func TestParseMetrics(t *testing.T) {
+	const marker = "forge-metrics-0123456789abcdef"
+	tests := []struct {
+		name       string
+		stderr     string
+		wantStderr string
+		wantMemory int64
+		wantOOM    bool
+	}{
+		{"no metrics", "boom\n", "boom\n", 0, false},
+		{"alone", marker + " 1048576 0\n", "", 1048576, false},
+		{"after output", "warning: x\n" + marker + " 2048 0\n", "warning: x\n", 2048, false},
+		{"no trailing newline", "oops\n" + marker + " 2048 0", "oops\n", 2048, false},
+		{"OOM-killed", marker + " 0 1\n", "", 0, true},
+		{"output echoing the marker", marker + " 1 0\n" + "more\n" + marker + " 4096 2\n", marker + " 1 0\nmore\n", 4096, true},
+		{"malformed", marker + " lots\n", "", 0, false},
+		{"marker without a space", marker + "x 1 1\n", marker + "x 1 1\n", 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := &ExecResult{Stderr: tt.stderr}
+			parseMetrics(result, marker)
+			if result.Stderr != tt.wantStderr || result.MaxMemory != tt.wantMemory || result.OOMKilled != tt.wantOOM {
+				t.Errorf("parseMetrics(%q) = stderr %q, memory %d, OOM %v; want %q, %d, %v",
+					tt.stderr, result.Stderr, result.MaxMemory, result.OOMKilled, tt.wantStderr, tt.wantMemory, tt.wantOOM)
+			}
+		})
+	}
+}
+
+func TestWithMetrics(t *testing.T) {
+	sh, err := exec.LookPath("sh")
+	if err != nil {
+		t.Skip("no sh")
+	}
+
+	command, marker := withMetrics([]string{"sh", "-c", `echo out; echo err >&2; exit 3`}, true)
+	if !strings.HasPrefix(marker, "forge-metrics-") || command[0] != "sh" || command[1] != "-c" {
+		t.Fatalf("withMetrics = %q, %q", command, marker)
+	}
+	if _, other := withMetrics(nil, false); other == marker {
+		t.Error("markers repeat")
+	}
+
+	// The wrapper runs the command and keeps its status and output; the
+	// metrics line comes last on stderr, where parseMetrics finds it.
+	cmd := exec.Command(sh, command[1:]...)
+	var stdout, stderr strings.Builder
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	err = cmd.Run()
+	exitErr, ok := err.(*exec.ExitError)
+	if !ok || exitErr.ExitCode() != 3 {
+		t.Fatalf("run = %v, want exit status 3", err)
+	}
+	result := &ExecResult{Stdout: stdout.String(), Stderr: stderr.String()}
+	parseMetrics(result, marker)
+	if result.Stdout != "out\n" || result.Stderr != "err\n" || result.OOMKilled {
+		t.Errorf("result = %+v", result)
+	}
+}
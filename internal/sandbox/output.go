@@ -0,0 +1,77 @@
+package sandbox
+
+import (
+	"bytes"
+	"strings"
+)
+
+// OutputFunc receives a program's output as it writes it. stream is
+// "stdout" or "stderr". It is called from one goroutine per stream, so it
+// must be safe for concurrent use.
+type OutputFunc func(stream, chunk string)
+
+// output collects what a program writes to one stream and passes it on
+// to fn, if set, as it arrives. The buffer isn't embedded: its ReadFrom
+// would let io.Copy, and so os/exec, fill it without calling Write.
+type output struct {
+	buf    bytes.Buffer
+	stream string
+	fn     OutputFunc
+	marker string // where metrics start, which fn doesn't get
+	held   string // the end of the output so far, which may start the marker
+	ended  bool   // the marker has been seen
+}
+
+// newOutputs returns the writers for a program's stdout and stderr.
+func newOutputs(fn OutputFunc, marker string) (stdout, stderr *output) {
+	return &output{stream: "stdout", fn: fn}, &output{stream: "stderr", fn: fn, marker: marker}
+}
+
+// Write keeps p and passes it on to fn, up to the marker. The marker can
+// arrive split across writes, so output that could be its start is held
+// back until the next write shows whether it is.
+func (o *output) Write(p []byte) (int, error) {
+	o.buf.Write(p)
+	if o.fn == nil || o.ended {
+		return len(p), nil
+	}
+	chunk := string(p)
+	if o.marker != "" {
+		chunk, o.held = o.held+chunk, ""
+		if i := strings.Index(chunk, o.marker); i >= 0 {
+			chunk, o.ended = chunk[:i], true
+		} else {
+			n := partialMarker(chunk, o.marker)
+			chunk, o.held = chunk[:len(chunk)-n], chunk[len(chunk)-n:]
+		}
+	}
+	if chunk != "" {
+		o.fn(o.stream, chunk)
+	}
+	return len(p), nil
+}
+
+// String returns everything written.
+func (o *output) String() string {
+	return o.buf.String()
+}
+
+// flush passes on output held back in case it started the marker, once
+// the program has finished without it.
+func (o *output) flush() {
+	if o.held != "" && o.fn != nil {
+		o.fn(o.stream, o.held)
+	}
+	o.held = ""
+}
+
+// partialMarker returns the length of the longest end of s that is the
+// start of marker.
+func partialMarker(s, marker string) int {
+	for n := min(len(s), len(marker)-1); n > 0; n-- {
+		if strings.HasSuffix(s, marker[:n]) {
+			return n
+		}
+	}
+	return 0
+}
@@ -0,0 +1,74 @@
+package sandbox
+
+import (
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestOutputStreaming(t *testing.T) {
+	const marker = "forge-metrics-0123456789abcdef"
+	tests := []struct {
+		name   string
+		writes []string
+		want   string // what the callback gets
+	}{
+		{"no marker", []string{"one\n", "two\n"}, "one\ntwo\n"},
+		{"marker in one write", []string{"warn\n", marker + " 1024 0\n"}, "warn\n"},
+		{"marker after output in one write", []string{"warn\n" + marker + " 1024 0\n"}, "warn\n"},
+		{"marker split", []string{"warn\nforge-met", "rics-0123456789abcdef 1024 0\n"}, "warn\n"},
+		{"marker split three ways", []string{"f", "orge-metrics-01234", "56789abcdef 1024 0\n"}, ""},
+		{"held prefix that isn't the marker", []string{"see forge-", "metal\n"}, "see forge-metal\n"},
+		{"prefix at the end", []string{"done: forge-me"}, "done: forge-me"},
+		{"after the marker", []string{marker + " 1 0\n", "late\n"}, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var mu sync.Mutex
+			var got strings.Builder
+			_, stderr := newOutputs(func(stream, chunk string) {
+				mu.Lock()
+				defer mu.Unlock()
+				if stream != "stderr" {
+					t.Errorf("stream = %q", stream)
+				}
+				if strings.Contains(chunk, "forge-metrics") || chunk == "" {
+					t.Errorf("callback got %q", chunk)
+				}
+				got.WriteString(chunk)
+			}, marker)
+			for _, w := range tt.writes {
+				if n, err := stderr.Write([]byte(w)); n != len(w) || err != nil {
+					t.Fatalf("Write = %d, %v", n, err)
+				}
+			}
+			stderr.flush()
+			if got.String() != tt.want {
+				t.Errorf("callback got %q, want %q", got.String(), tt.want)
+			}
+			// The buffer keeps everything, for parseMetrics.
+			if all := strings.Join(tt.writes, ""); stderr.String() != all {
+				t.Errorf("buffer = %q, want %q", stderr.String(), all)
+			}
+		})
+	}
+}
+
+func TestOutputStdout(t *testing.T) {
+	var got []string
+	stdout, _ := newOutputs(func(stream, chunk string) { got = append(got, stream+":"+chunk) }, "forge-metrics-x")
+	stdout.Write([]byte("forge-metrics-x is only looked for on stderr"))
+	if len(got) != 1 || got[0] != "stdout:forge-metrics-x is only looked for on stderr" {
+		t.Errorf("callback got %q", got)
+	}
+
+	// Without a callback, output is only kept.
+	stdout, stderr := newOutputs(nil, "m")
+	stdout.Write([]byte("a"))
+	stderr.Write([]byte("b"))
+	stderr.flush()
+	if stdout.String() != "a" || stderr.String() != "b" {
+		t.Errorf("buffers = %q, %q", stdout.String(), stderr.String())
+	}
+}
@@ -1,13 +1,33 @@
 package sandbox
 
-import "time"
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
 
 // Policy defines resource limits for sandbox execution.
 type Policy struct {
 	MaxMemory  string        // Docker memory limit (e.g. "256m")
-	MaxTimeout time.Duration // Maximum execution time
-	Network    bool          // Whether network access is allowed
-	Images     []string      // Allowed Docker images
+	MaxTimeout time.Duration // Maximum execution time, from start to finish
+	MaxCPUs    float64       // CPUs the container may use (e.g. 1.5)
+	MaxPids    int           // Processes and threads the container may have
+	// MaxDisk is the size of the tmpfs mounted at /tmp, the one place a
+	// one-off run can write (e.g. "256m"). It counts toward MaxMemory.
+	// Empty leaves /tmp on the container's own, unlimited filesystem.
+	MaxDisk string
+	// MaxOutput is how much a run can leave in OutputDir to be collected
+	// (e.g. "16m"); files past it are dropped. Empty means runs get no
+	// OutputDir.
+	MaxOutput string
+	Network   bool     // Whether network access is allowed
+	Images    []string // Allowed Docker images
+	// Runtime is the OCI runtime Docker runs containers with, as named in
+	// the daemon's config: "runsc" for gVisor or "kata" for Kata
+	// Containers, say. Empty means Docker's default, usually runc.
+	Runtime string
 }
 
 // DefaultPolicy returns safe defaults for code execution.
@@ -15,6 +35,10 @@ func DefaultPolicy() Policy {
 	return Policy{
 		MaxMemory:  "256m",
 		MaxTimeout: 30 * time.Second,
+		MaxCPUs:    1,
+		MaxPids:    256,
+		MaxDisk:    "256m",
+		MaxOutput:  "16m",
 		Network:    false,
 		Images: []string{
 			"python:3.12-slim",
@@ -25,6 +49,101 @@ func DefaultPolicy() Policy {
 	}
 }
 
+// runtimeName keeps Runtime from being read as another docker option.
+var runtimeName = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// Validate reports settings docker would reject or misread.
+func (p Policy) Validate() error {
+	if p.Runtime != "" && !runtimeName.MatchString(p.Runtime) {
+		return fmt.Errorf("invalid runtime %q", p.Runtime)
+	}
+	if p.MaxCPUs < 0 {
+		return fmt.Errorf("invalid CPU limit %g", p.MaxCPUs)
+	}
+	if p.MaxPids < 0 {
+		return fmt.Errorf("invalid process limit %d", p.MaxPids)
+	}
+	if _, err := p.memoryBytes(); err != nil {
+		return err
+	}
+	if _, err := parseSize(p.MaxDisk); err != nil {
+		return fmt.Errorf("invalid disk limit %q", p.MaxDisk)
+	}
+	if _, err := p.outputBytes(); err != nil {
+		return err
+	}
+	return nil
+}
+
+// memoryBytes parses MaxMemory. Zero means no limit.
+func (p Policy) memoryBytes() (int64, error) {
+	n, err := parseSize(p.MaxMemory)
+	if err != nil {
+		return 0, fmt.Errorf("invalid memory limit %q", p.MaxMemory)
+	}
+	return n, nil
+}
+
+// outputBytes parses MaxOutput. Zero means runs get no OutputDir.
+func (p Policy) outputBytes() (int64, error) {
+	n, err := parseSize(p.MaxOutput)
+	if err != nil {
+		return 0, fmt.Errorf("invalid output limit %q", p.MaxOutput)
+	}
+	return n, nil
+}
+
+// parseSize parses a number of bytes with an optional b, k, m, or g
+// suffix, as docker takes it. Empty is zero.
+func parseSize(size string) (int64, error) {
+	s := strings.ToLower(strings.TrimSpace(size))
+	if s == "" {
+		return 0, nil
+	}
+	unit := int64(1)
+	switch s[len(s)-1] {
+	case 'b':
+		s = s[:len(s)-1]
+	case 'k':
+		unit, s = 1<<10, s[:len(s)-1]
+	case 'm':
+		unit, s = 1<<20, s[:len(s)-1]
+	case 'g':
+		unit, s = 1<<30, s[:len(s)-1]
+	}
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid size %q", size)
+	}
+	return n * unit, nil
+}
+
+// runArgs returns the docker run flags that apply the policy's isolation
+// and limits.
+func (p Policy) runArgs() []string {
+	var args []string
+	if p.MaxMemory != "" {
+		args = append(args, "--memory", p.MaxMemory)
+	}
+	if p.MaxCPUs > 0 {
+		args = append(args, "--cpus", strconv.FormatFloat(p.MaxCPUs, 'f', -1, 64))
+	}
+	if p.MaxPids > 0 {
+		args = append(args, "--pids-limit", strconv.Itoa(p.MaxPids))
+	}
+	if p.MaxDisk != "" {
+		// Compiled languages build into /tmp and run from there.
+		args = append(args, "--tmpfs", "/tmp:rw,exec,size="+p.MaxDisk)
+	}
+	if p.Runtime != "" {
+		args = append(args, "--runtime", p.Runtime)
+	}
+	if !p.Network {
+		args = append(args, "--network=none")
+	}
+	return args
+}
+
 // IsImageAllowed checks if an image is on the allowlist.
 func (p Policy) IsImageAllowed(image string) bool {
 	for _, allowed := range p.Images {
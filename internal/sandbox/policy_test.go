@@ -0,0 +1,102 @@
+package sandbox
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestParseSize(t *testing.T) {
+	tests := []struct {
+		size    string
+		want    int64
+		wantErr bool
+	}{
+		{"", 0, false},
+		{"0", 0, false},
+		{"512", 512, false},
+		{"512b", 512, false},
+		{"4k", 4 << 10, false},
+		{"256m", 256 << 20, false},
+		{"256M", 256 << 20, false},
+		{" 2g ", 2 << 30, false},
+		{"1.5g", 0, true},
+		{"-1m", 0, true},
+		{"m", 0, true},
+		{"10t", 0, true},
+		{"lots", 0, true},
+	}
+
+	for _, tt := range tests {
+		got, err := parseSize(tt.size)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("parseSize(%q) = %d, %v; want %d, error %v", tt.size, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestPolicyValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		change func(*Policy)
+		want   string // in the error; empty for none
+	}{
+		{"defaults", func(p *Policy) {}, ""},
+		{"runtime", func(p *Policy) { p.Runtime = "runsc" }, ""},
+		{"dotted runtime", func(p *Policy) { p.Runtime = "kata-qemu.v2" }, ""},
+		{"no limits", func(p *Policy) { *p = Policy{} }, ""},
+		{"runtime flag", func(p *Policy) { p.Runtime = "--privileged" }, `invalid runtime "--privileged"`},
+		{"runtime with space", func(p *Policy) { p.Runtime = "runsc --debug" }, "invalid runtime"},
+		{"negative CPUs", func(p *Policy) { p.MaxCPUs = -1 }, "invalid CPU limit -1"},
+		{"negative pids", func(p *Policy) { p.MaxPids = -5 }, "invalid process limit -5"},
+		{"bad memory", func(p *Policy) { p.MaxMemory = "lots" }, `invalid memory limit "lots"`},
+		{"bad disk", func(p *Policy) { p.MaxDisk = "1.5g" }, `invalid disk limit "1.5g"`},
+		{"bad output", func(p *Policy) { p.MaxOutput = "-1m" }, `invalid output limit "-1m"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := DefaultPolicy()
+			tt.change(&p)
+			err := p.Validate()
+			switch {
+			case tt.want == "" && err != nil:
+				t.Errorf("Validate() = %v, want nil", err)
+			case tt.want != "" && (err == nil || !strings.Contains(err.Error(), tt.want)):
+				t.Errorf("Validate() = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestPolicyRunArgs(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy Policy
+		want   []string
+	}{
+		{
+			"defaults",
+			DefaultPolicy(),
+			[]string{"--memory", "256m", "--cpus", "1", "--pids-limit", "256", "--tmpfs", "/tmp:rw,exec,size=256m", "--network=none"},
+		},
+		{
+			"runtime and network",
+			Policy{MaxCPUs: 1.5, Runtime: "runsc", Network: true},
+			[]string{"--cpus", "1.5", "--runtime", "runsc"},
+		},
+		{
+			"no limits",
+			Policy{},
+			[]string{"--network=none"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.policy.runArgs(); !slices.Equal(got, tt.want) {
+				t.Errorf("runArgs() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,255 @@
+package sandbox
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// PoolOptions sizes a Pool.
+type PoolOptions struct {
+	Size    int           // idle containers kept ready per image
+	IdleTTL time.Duration // how long one may sit idle before it is stopped; zero keeps them
+}
+
+// Pool runs code in containers started ahead of time, which saves the
+// seconds a fresh container takes to start. Each run gets one container to
+// itself, with its files in an empty /workspace; afterwards its outputs are
+// collected, /workspace, /tmp, and OutputDir are emptied, and the
+// container goes back to the pool, unless the run
+// timed out or left processes behind, in which case it is stopped. Pooled
+// containers have a read-only root filesystem, so runs can't change what
+// later ones see elsewhere, and HOME is /tmp.
+//
+// Containers are pooled by image and mounts, and a pool is only filled
+// once an image has been used, or by Warm.
+type Pool struct {
+	docker *DockerSandbox
+	opts   PoolOptions
+
+	mu       sync.Mutex
+	idle     map[string][]pooled // by poolKey, most recently used last
+	starting map[string]int      // containers being started, by poolKey
+	closed   bool
+	done     chan struct{}
+}
+
+// pooled is an idle container.
+type pooled struct {
+	c     *Container
+	since time.Time
+}
+
+// NewPool creates a pool of containers run under policy. Close stops them.
+func NewPool(policy Policy, opts PoolOptions) *Pool {
+	p := &Pool{
+		docker:   NewDockerSandbox(policy),
+		opts:     opts,
+		idle:     make(map[string][]pooled),
+		starting: make(map[string]int),
+		done:     make(chan struct{}),
+	}
+	if opts.IdleTTL > 0 {
+		go p.expire()
+	}
+	return p
+}
+
+// Warm fills the pool for image in the background.
+func (p *Pool) Warm(image string, mounts []Mount) {
+	go p.fill(StartOpts{Image: image, Mounts: mounts, ReadOnly: true})
+}
+
+// Exec runs opts in a pooled container, starting one if none is idle.
+// Like DockerSandbox.Exec, a run longer than the policy's MaxTimeout is
+// killed and reported as timed out.
+func (p *Pool) Exec(ctx context.Context, opts ExecOpts) (*ExecResult, error) {
+	if !p.docker.Policy.IsImageAllowed(opts.Image) {
+		return nil, fmt.Errorf("image %q not in allowlist", opts.Image)
+	}
+	outputLimit, err := p.docker.Policy.outputBytes()
+	if err != nil {
+		return nil, err
+	}
+	start := StartOpts{Image: opts.Image, Mounts: opts.Mounts, ReadOnly: true}
+	c, err := p.take(ctx, start)
+	if err != nil {
+		return nil, err
+	}
+	go p.fill(start)
+
+	if err := c.WriteFiles(ctx, opts.files()); err != nil {
+		c.Stop()
+		return nil, err
+	}
+	runCtx := ctx
+	if timeout := p.docker.Policy.MaxTimeout; timeout > 0 {
+		var cancel context.CancelFunc
+		runCtx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	env := []string{"HOME=/tmp"}
+	if outputLimit > 0 {
+		env = append(env, "OUTPUT_DIR="+OutputDir)
+	}
+	result, err := c.run(runCtx, opts.Command, opts.Stdin, append(env, opts.Env...), opts.OnOutput)
+	if err != nil {
+		// The program may still be running.
+		go c.Stop()
+		if runCtx.Err() == nil || ctx.Err() != nil {
+			return nil, err
+		}
+		result.TimedOut = true
+		return result, nil
+	}
+	if outputLimit > 0 {
+		if err := collectOutputs(ctx, c.ID, outputLimit, result); err != nil {
+			go c.Stop()
+			return nil, err
+		}
+	}
+	go p.release(start, c)
+	return result, nil
+}
+
+// Close stops every idle container. Ones in use are stopped when their
+// runs finish.
+func (p *Pool) Close() {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return
+	}
+	p.closed = true
+	close(p.done)
+	idle := p.idle
+	p.idle = make(map[string][]pooled)
+	p.mu.Unlock()
+
+	for _, list := range idle {
+		for _, pc := range list {
+			pc.c.Stop()
+		}
+	}
+}
+
+// take returns an idle container for start, or starts one.
+func (p *Pool) take(ctx context.Context, start StartOpts) (*Container, error) {
+	key := poolKey(start)
+	p.mu.Lock()
+	if list := p.idle[key]; len(list) > 0 {
+		pc := list[len(list)-1]
+		p.idle[key] = list[:len(list)-1]
+		p.mu.Unlock()
+		return pc.c, nil
+	}
+	p.mu.Unlock()
+
+	if err := ensureImage(ctx, start.Image); err != nil {
+		return nil, err
+	}
+	return p.docker.Start(ctx, start)
+}
+
+// fill starts containers until Size are idle or starting for start.
+func (p *Pool) fill(start StartOpts) {
+	key := poolKey(start)
+	p.mu.Lock()
+	need := p.opts.Size - len(p.idle[key]) - p.starting[key]
+	if p.closed || need <= 0 {
+		p.mu.Unlock()
+		return
+	}
+	p.starting[key] += need
+	p.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+	for range need {
+		var c *Container
+		err := ensureImage(ctx, start.Image)
+		if err == nil {
+			c, err = p.docker.Start(ctx, start)
+		}
+		p.mu.Lock()
+		p.starting[key]--
+		if err == nil && !p.closed {
+			p.idle[key] = append(p.idle[key], pooled{c: c, since: time.Now()})
+			c = nil
+		}
+		p.mu.Unlock()
+		if c != nil {
+			c.Stop()
+		}
+	}
+}
+
+// resetScript empties /workspace, /tmp, and OutputDir and prints how many processes
+// are left in the container. Idle, there are three: init, the command
+// keeping the container up, and the script itself.
+const resetScript = `rm -rf /workspace/* /workspace/.[!.]* /workspace/..?* /tmp/* /tmp/.[!.]* /tmp/..?* /output/* /output/.[!.]* /output/..?* 2>/dev/null; n=0; for d in /proc/[0-9]*; do n=$((n+1)); done; echo $n`
+
+// release readies a container for its next run and puts it back, or stops
+// it if it can't be reset or the pool is full.
+func (p *Pool) release(start StartOpts, c *Container) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	out, err := docker(ctx, "exec", c.ID, "sh", "-c", resetScript)
+	if err != nil || strings.TrimSpace(out) != "3" {
+		c.Stop()
+		return
+	}
+
+	key := poolKey(start)
+	p.mu.Lock()
+	if p.closed || len(p.idle[key]) >= p.opts.Size {
+		p.mu.Unlock()
+		c.Stop()
+		return
+	}
+	p.idle[key] = append(p.idle[key], pooled{c: c, since: time.Now()})
+	p.mu.Unlock()
+}
+
+// expire stops containers that have been idle longer than IdleTTL.
+func (p *Pool) expire() {
+	ticker := time.NewTicker(max(p.opts.IdleTTL/2, time.Second))
+	defer ticker.Stop()
+	for {
+		select {
+		case <-p.done:
+			return
+		case <-ticker.C:
+		}
+		var expired []*Container
+		p.mu.Lock()
+		for key, list := range p.idle {
+			kept := list[:0]
+			for _, pc := range list {
+				if time.Since(pc.since) > p.opts.IdleTTL {
+					expired = append(expired, pc.c)
+				} else {
+					kept = append(kept, pc)
+				}
+			}
+			p.idle[key] = kept
+		}
+		p.mu.Unlock()
+		for _, c := range expired {
+			c.Stop()
+		}
+	}
+}
+
+// poolKey identifies the containers interchangeable for start.
+func poolKey(start StartOpts) string {
+	var b strings.Builder
+	b.WriteString(start.Image)
+	for _, m := range start.Mounts {
+		b.WriteString("\x00" + m.Source + ":" + m.Target + ":" + strconv.FormatBool(m.ReadOnly))
+	}
+	return b.String()
+}
@@ -0,0 +1,195 @@
+package sandbox
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeDocker puts a docker on PATH that logs its arguments and answers
+// like the real one: "run" starts containers named ctr-1, ctr-2, and so
+// on, runs go through the metrics wrapper around an echo of the command,
+// or sleep for $FAKE_DOCKER_SLEEP, and the pool's reset reports the
+// processes in $FAKE_DOCKER_PROCS, or three. It returns the log's path.
+func fakeDocker(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	script := `#!/bin/sh
+echo "$*" >> "$FAKE_DOCKER_DIR/log"
+case "$1" in
+run)
+	n=$(cat "$FAKE_DOCKER_DIR/count" 2>/dev/null || echo 0); n=$((n+1)); echo $n > "$FAKE_DOCKER_DIR/count"
+	echo "ctr-$n" ;;
+exec)
+	while [ $# -gt 0 ]; do case "$1" in ctr-*) break ;; esac; shift; done
+	id=$1; shift
+	case "$3" in
+	*/proc/*) echo "${FAKE_DOCKER_PROCS:-3}" ;;
+	*) [ -n "$FAKE_DOCKER_SLEEP" ] && exec sleep "$FAKE_DOCKER_SLEEP"
+		wrapper=$3; shift 4; sh -c "$wrapper" sh echo "ran in $id: $*" ;;
+	esac ;;
+esac
+`
+	if err := os.WriteFile(filepath.Join(dir, "docker"), []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+	t.Setenv("FAKE_DOCKER_DIR", dir)
+	return filepath.Join(dir, "log")
+}
+
+// testPool returns a pool of containers running the "sh" image.
+func testPool(opts PoolOptions) *Pool {
+	policy := DefaultPolicy()
+	policy.Images = []string{"sh"}
+	policy.MaxOutput = ""
+	return NewPool(policy, opts)
+}
+
+// idleIDs returns the IDs of the pool's idle containers for start.
+func idleIDs(p *Pool, start StartOpts) []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	var ids []string
+	for _, pc := range p.idle[poolKey(start)] {
+		ids = append(ids, pc.c.ID)
+	}
+	return ids
+}
+
+// waitFor fails the test if cond doesn't hold within five seconds.
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	for deadline := time.Now().Add(5 * time.Second); !cond(); time.Sleep(10 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+	}
+}
+
+func TestPoolReuse(t *testing.T) {
+	log := fakeDocker(t)
+	p := testPool(PoolOptions{Size: 1})
+	defer p.Close()
+	start := StartOpts{Image: "sh", ReadOnly: true}
+
+	p.Warm("sh", nil)
+	waitFor(t, "the pool to fill", func() bool { return len(idleIDs(p, start)) == 1 })
+
+	// The run takes the warm container rather than starting one.
+	result, err := p.Exec(context.Background(), ExecOpts{Image: "sh", Command: []string{"python", "main.py"}, Code: "print(1)", Filename: "main.py"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Stdout != "ran in ctr-1: python main.py\n" || result.Stderr != "" {
+		t.Errorf("result = %+v", result)
+	}
+	logged, _ := os.ReadFile(log)
+	for _, want := range []string{"--read-only", "cp ", "ctr-1:/workspace", "exec -w /workspace -e HOME=/tmp ctr-1 sh -c MARKER=forge-metrics-"} {
+		if !strings.Contains(string(logged), want) {
+			t.Errorf("docker log missing %q:\n%s", want, logged)
+		}
+	}
+
+	// The pool refills while ctr-1 is reset; whichever finishes second is
+	// kept or stopped.
+	settled := func(id string) bool {
+		logged, _ := os.ReadFile(log)
+		return slices.Contains(idleIDs(p, start), id) || strings.Contains(string(logged), "rm -f "+id+"\n")
+	}
+	waitFor(t, "the refill and release", func() bool { return settled("ctr-1") && settled("ctr-2") })
+	if len(idleIDs(p, start)) == 0 {
+		t.Error("no container ready after the run")
+	}
+
+	// A released container that resets cleanly is reused once there's
+	// room for it.
+	q := testPool(PoolOptions{Size: 1})
+	defer q.Close()
+	q.release(start, &Container{ID: "ctr-used"})
+	if ids := idleIDs(q, start); len(ids) != 1 || ids[0] != "ctr-used" {
+		t.Errorf("idle after release = %q, want ctr-used", ids)
+	}
+	c, err := q.take(context.Background(), start)
+	if err != nil || c.ID != "ctr-used" {
+		t.Errorf("take = %v, %v; want ctr-used again", c, err)
+	}
+	if logged, _ := os.ReadFile(log); strings.Count(string(logged), "run -d") != 2 {
+		t.Errorf("take started a container:\n%s", logged)
+	}
+}
+
+func TestPoolEviction(t *testing.T) {
+	log := fakeDocker(t)
+	start := StartOpts{Image: "sh", ReadOnly: true}
+	stopped := func(id string) bool {
+		logged, _ := os.ReadFile(log)
+		return strings.Contains(string(logged), "rm -f "+id+"\n")
+	}
+
+	p := testPool(PoolOptions{Size: 1})
+	defer p.Close()
+
+	// Runs that leave processes behind aren't reused.
+	t.Setenv("FAKE_DOCKER_PROCS", "4")
+	p.release(start, &Container{ID: "ctr-busy"})
+	if !stopped("ctr-busy") || len(idleIDs(p, start)) != 0 {
+		t.Errorf("container with leftover processes kept: idle %q", idleIDs(p, start))
+	}
+	t.Setenv("FAKE_DOCKER_PROCS", "3")
+
+	// Nor are containers past the pool's size.
+	p.release(start, &Container{ID: "ctr-a"})
+	p.release(start, &Container{ID: "ctr-b"})
+	if ids := idleIDs(p, start); len(ids) != 1 || ids[0] != "ctr-a" || !stopped("ctr-b") {
+		t.Errorf("idle = %q, want only ctr-a with ctr-b stopped", ids)
+	}
+
+	// Closing stops the idle ones, and nothing is pooled after.
+	p.Close()
+	if !stopped("ctr-a") || len(idleIDs(p, start)) != 0 {
+		t.Error("Close left ctr-a running")
+	}
+	p.release(start, &Container{ID: "ctr-c"})
+	if !stopped("ctr-c") {
+		t.Error("container released after Close kept")
+	}
+
+	// Containers idle past IdleTTL are stopped.
+	p = testPool(PoolOptions{Size: 1, IdleTTL: time.Millisecond})
+	defer p.Close()
+	p.release(start, &Container{ID: "ctr-old"})
+	waitFor(t, "ctr-old to expire", func() bool { return stopped("ctr-old") && len(idleIDs(p, start)) == 0 })
+}
+
+func TestPoolTimeout(t *testing.T) {
+	log := fakeDocker(t)
+	policy := DefaultPolicy()
+	policy.Images = []string{"sh"}
+	policy.MaxOutput = ""
+	policy.MaxTimeout = 200 * time.Millisecond
+	p := NewPool(policy, PoolOptions{})
+	defer p.Close()
+	t.Setenv("FAKE_DOCKER_SLEEP", "30")
+
+	// A run that outlives the timeout is reported as timed out, and its
+	// container stopped rather than pooled.
+	result, err := p.Exec(context.Background(), ExecOpts{Image: "sh", Command: []string{"python", "main.py"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.TimedOut {
+		t.Errorf("result = %+v, want a timeout", result)
+	}
+	waitFor(t, "the container to stop", func() bool {
+		logged, _ := os.ReadFile(log)
+		return strings.Contains(string(logged), "rm -f ctr-1\n")
+	})
+	if ids := idleIDs(p, StartOpts{Image: "sh", ReadOnly: true}); len(ids) != 0 {
+		t.Errorf("idle = %q after a timeout", ids)
+	}
+}
@@ -0,0 +1,169 @@
+package sandbox
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// ProcessSandbox runs code as a plain child process, for machines without
+// Docker. Each run gets a fresh temp directory as its working directory,
+// home, and TMPDIR, an environment with nothing of the server's but PATH,
+// the policy's memory limit and timeout, and, where the OS allows, a CPU
+// time limit. Going over the memory limit makes allocations fail rather
+// than getting the program killed, so OOMKilled is never set. It is much weaker than a container: the code runs as the
+// server's user, can read whatever that user can, and has the network.
+// Image only names the language; its interpreter or compiler must be
+// installed on the host.
+type ProcessSandbox struct {
+	Policy Policy
+}
+
+// NewProcessSandbox creates a process sandbox with the given policy.
+func NewProcessSandbox(policy Policy) *ProcessSandbox {
+	return &ProcessSandbox{Policy: policy}
+}
+
+// DockerAvailable reports whether the docker CLI is installed and its
+// daemon answers.
+func DockerAvailable(ctx context.Context) bool {
+	if _, err := exec.LookPath("docker"); err != nil {
+		return false
+	}
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	_, err := docker(ctx, "version", "--format", "{{.Server.Version}}")
+	return err == nil
+}
+
+// Exec runs opts.Command in a temp directory holding the code and files.
+// Commands written for a container still work where their arguments are
+// paths: /workspace, /tmp, and OutputDir, and paths under them, refer to
+// the run's own directories. Other arguments, such as a script given to
+// sh -c, are passed as they are, so scripts should find a temp directory
+// in $TMPDIR and OutputDir in $OUTPUT_DIR. Mounts need Docker and are
+// refused.
+func (p *ProcessSandbox) Exec(ctx context.Context, opts ExecOpts) (*ExecResult, error) {
+	if !p.Policy.IsImageAllowed(opts.Image) {
+		return nil, fmt.Errorf("image %q not in allowlist", opts.Image)
+	}
+	if len(opts.Mounts) > 0 {
+		return nil, errors.New("mounts need Docker, which isn't available")
+	}
+	if len(opts.Command) == 0 {
+		return nil, errors.New("no command to run")
+	}
+	memory, err := p.Policy.memoryBytes()
+	if err != nil {
+		return nil, err
+	}
+	outputLimit, err := p.Policy.outputBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	root, err := os.MkdirTemp("", "forge-sandbox-*")
+	if err != nil {
+		return nil, fmt.Errorf("creating temp dir: %w", err)
+	}
+	defer os.RemoveAll(root)
+	workspace := filepath.Join(root, "workspace")
+	tmp := filepath.Join(root, "tmp")
+	output := filepath.Join(root, "output")
+	for _, dir := range []string{workspace, tmp, output} {
+		if err := os.Mkdir(dir, 0o700); err != nil {
+			return nil, fmt.Errorf("creating temp dir: %w", err)
+		}
+	}
+	if err := writeFiles(workspace, opts.files()); err != nil {
+		return nil, err
+	}
+
+	timeout := p.Policy.MaxTimeout
+	runCtx := ctx
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		runCtx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	dirs := map[string]string{"/workspace": workspace, "/tmp": tmp, OutputDir: output}
+	command := make([]string, len(opts.Command))
+	for i, arg := range opts.Command {
+		command[i] = hostPath(arg, dirs)
+	}
+	command = limitCommand(command, memory, timeout)
+
+	cmd := exec.CommandContext(runCtx, command[0], command[1:]...)
+	cmd.Dir = workspace
+	env := []string{
+		"PATH=" + os.Getenv("PATH"),
+		"HOME=" + root,
+		"TMPDIR=" + tmp,
+		"LANG=C.UTF-8",
+	}
+	if outputLimit > 0 {
+		env = append(env, "OUTPUT_DIR="+output)
+	}
+	cmd.Env = append(env, opts.Env...)
+	setProcessGroup(cmd)
+	cmd.Cancel = func() error { return killProcessGroup(cmd) }
+	cmd.WaitDelay = time.Second
+
+	stdout, stderr := newOutputs(opts.OnOutput, "")
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	if opts.Stdin != "" {
+		cmd.Stdin = strings.NewReader(opts.Stdin)
+	}
+
+	started := time.Now()
+	err = cmd.Run()
+	result := &ExecResult{
+		Stdout:   stdout.String(),
+		Stderr:   stderr.String(),
+		Duration: time.Since(started),
+	}
+	if runCtx.Err() != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		result.ExitCode = -1
+		result.TimedOut = true
+	} else {
+		if err != nil {
+			exitErr, ok := err.(*exec.ExitError)
+			if !ok {
+				return nil, fmt.Errorf("running %s: %w", opts.Command[0], err)
+			}
+			result.ExitCode = exitErr.ExitCode()
+		}
+		result.MaxMemory = maxRSS(cmd.ProcessState)
+	}
+	if outputLimit > 0 {
+		if err := collectDir(output, outputLimit, result); err != nil {
+			return nil, fmt.Errorf("collecting outputs: %w", err)
+		}
+	}
+	return result, nil
+}
+
+// hostPath returns arg with its leading directory replaced by the one dirs
+// maps it to, when arg is one of those directories or a path under one,
+// and arg itself otherwise.
+func hostPath(arg string, dirs map[string]string) string {
+	for from, to := range dirs {
+		if arg == from {
+			return to
+		}
+		if rest, ok := strings.CutPrefix(arg, from+"/"); ok {
+			return filepath.Join(to, filepath.FromSlash(rest))
+		}
+	}
+	return arg
+}
@@ -0,0 +1,31 @@
+//go:build !unix
+
+package sandbox
+
+import (
+	"os"
+	"os/exec"
+	"time"
+)
+
+// limitCommand returns command as is: only the timeout applies where
+// rlimits aren't available.
+func limitCommand(command []string, memory int64, timeout time.Duration) []string {
+	return command
+}
+
+// setProcessGroup is a no-op where process groups aren't available.
+func setProcessGroup(cmd *exec.Cmd) {}
+
+// killProcessGroup kills the command itself; its children may survive.
+func killProcessGroup(cmd *exec.Cmd) error {
+	if cmd.Process == nil {
+		return nil
+	}
+	return cmd.Process.Kill()
+}
+
+// maxRSS is unknown where rusage isn't available.
+func maxRSS(state *os.ProcessState) int64 {
+	return 0
+}
@@ -0,0 +1,55 @@
+//go:build unix
+
+package sandbox
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"os/exec"
+	"runtime"
+	"syscall"
+	"time"
+)
+
+// limitCommand wraps command in a shell that sets its resource limits
+// before running it: memory as the data segment limit (address space would
+// trip up runtimes that reserve more than they use), CPU time, and no core
+// dumps. Limits the OS won't set are skipped.
+func limitCommand(command []string, memory int64, timeout time.Duration) []string {
+	script := "ulimit -c 0 2>/dev/null; "
+	if memory > 0 {
+		script += fmt.Sprintf("ulimit -d %d 2>/dev/null; ", max(memory>>10, 1))
+	}
+	if timeout > 0 {
+		script += fmt.Sprintf("ulimit -t %d 2>/dev/null; ", int(math.Ceil(timeout.Seconds())))
+	}
+	script += `exec "$@"`
+	return append([]string{"/bin/sh", "-c", script, "sh"}, command...)
+}
+
+// setProcessGroup starts the command in its own process group.
+func setProcessGroup(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+}
+
+// killProcessGroup kills the command and everything it started.
+func killProcessGroup(cmd *exec.Cmd) error {
+	if cmd.Process == nil {
+		return nil
+	}
+	return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+}
+
+// maxRSS returns the peak resident memory of a finished process and the
+// children it waited for, in bytes.
+func maxRSS(state *os.ProcessState) int64 {
+	usage, ok := state.SysUsage().(*syscall.Rusage)
+	if !ok {
+		return 0
+	}
+	if runtime.GOOS == "darwin" {
+		return int64(usage.Maxrss) // already bytes
+	}
+	return int64(usage.Maxrss) << 10
+}
@@ -0,0 +1,172 @@
+//go:build unix
+
+package sandbox
+
+import (
+	"context"
+	"os/exec"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLimitCommand(t *testing.T) {
+	tests := []struct {
+		name    string
+		memory  int64
+		timeout time.Duration
+		want    string
+	}{
+		{"no limits", 0, 0, `ulimit -c 0 2>/dev/null; exec "$@"`},
+		{"defaults", 256 << 20, 30 * time.Second, `ulimit -c 0 2>/dev/null; ulimit -d 262144 2>/dev/null; ulimit -t 30 2>/dev/null; exec "$@"`},
+		{"rounded", 100, 1500 * time.Millisecond, `ulimit -c 0 2>/dev/null; ulimit -d 1 2>/dev/null; ulimit -t 2 2>/dev/null; exec "$@"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := limitCommand([]string{"python", "main.py"}, tt.memory, tt.timeout)
+			want := []string{"/bin/sh", "-c", tt.want, "sh", "python", "main.py"}
+			if !slices.Equal(got, want) {
+				t.Errorf("limitCommand = %q, want %q", got, want)
+			}
+		})
+	}
+
+	out, err := exec.Command("/bin/sh", limitCommand([]string{"sh", "-c", "ulimit -t; ulimit -c"}, 0, 2*time.Second)[1:]...).Output()
+	if err != nil || string(out) != "2\n0\n" {
+		t.Errorf("limits set = %q, %v; want 2 and 0", out, err)
+	}
+}
+
+func TestHostPath(t *testing.T) {
+	dirs := map[string]string{"/workspace": "/run/ws", "/tmp": "/run/tmp", OutputDir: "/run/out"}
+	tests := []struct{ arg, want string }{
+		{"/workspace", "/run/ws"},
+		{"/workspace/main.py", "/run/ws/main.py"},
+		{"/tmp/main", "/run/tmp/main"},
+		{"/output/plots/a.png", "/run/out/plots/a.png"},
+		{"/workspaces", "/workspaces"},
+		{"/tmpfile", "/tmpfile"},
+		{"main.py", "main.py"},
+		{"-c", "-c"},
+		{`open("/tmp/x", "w").write("/workspace")`, `open("/tmp/x", "w").write("/workspace")`},
+		{"gcc -o /tmp/main main.c && /tmp/main", "gcc -o /tmp/main main.c && /tmp/main"},
+	}
+
+	for _, tt := range tests {
+		if got := hostPath(tt.arg, dirs); got != tt.want {
+			t.Errorf("hostPath(%q) = %q, want %q", tt.arg, got, tt.want)
+		}
+	}
+}
+
+// testProcessSandbox returns a process sandbox that runs the "sh" image.
+func testProcessSandbox(change func(*Policy)) *ProcessSandbox {
+	policy := DefaultPolicy()
+	policy.Images = []string{"sh"}
+	if change != nil {
+		change(&policy)
+	}
+	return NewProcessSandbox(policy)
+}
+
+func TestProcessSandboxExec(t *testing.T) {
+	t.Setenv("FORGE_TEST_SECRET", "hunter2")
+	sb := testProcessSandbox(nil)
+	ctx := context.Background()
+
+	result, err := sb.Exec(ctx, ExecOpts{
+		Image:    "sh",
+		Command:  []string{"sh", "-c", `. lib/helper.sh; . "$1"; echo "${FORGE_TEST_SECRET:-none} $HOME"; echo /tmp/x; echo oops >&2; exit 3`, "sh", "/workspace/main.sh"},
+		Code:     "echo from code",
+		Filename: "main.sh",
+		Files:    map[string]string{"lib/helper.sh": "echo from helper"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(result.Stdout, "\n")
+	if len(lines) != 5 || lines[0] != "from helper" || lines[1] != "from code" || !strings.HasPrefix(lines[2], "none /") || lines[3] != "/tmp/x" {
+		t.Errorf("Stdout = %q", result.Stdout)
+	}
+	if result.Stderr != "oops\n" || result.ExitCode != 3 || result.TimedOut {
+		t.Errorf("result = %+v, want exit code 3 with stderr oops", result)
+	}
+
+	for _, tt := range []struct {
+		opts ExecOpts
+		want string
+	}{
+		{ExecOpts{Image: "python:3.12-slim", Command: []string{"python"}}, `image "python:3.12-slim" not in allowlist`},
+		{ExecOpts{Image: "sh", Command: []string{"true"}, Mounts: []Mount{{Source: "deps", Target: "/deps"}}}, "mounts need Docker"},
+		{ExecOpts{Image: "sh"}, "no command to run"},
+		{ExecOpts{Image: "sh", Command: []string{"true"}, Files: map[string]string{"../escape": "x"}}, "invalid file path"},
+	} {
+		if _, err := sb.Exec(ctx, tt.opts); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("Exec(%+v) = %v, want %q", tt.opts, err, tt.want)
+		}
+	}
+}
+
+func TestProcessSandboxTimeout(t *testing.T) {
+	sb := testProcessSandbox(func(p *Policy) { p.MaxTimeout = 300 * time.Millisecond })
+
+	// The child sleep is killed along with the shell, so the run ends on
+	// time rather than when the sleep would.
+	started := time.Now()
+	result, err := sb.Exec(context.Background(), ExecOpts{Image: "sh", Command: []string{"sh", "-c", "echo started; sleep 30 & wait"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.TimedOut || result.ExitCode != -1 || result.Stdout != "started\n" {
+		t.Errorf("result = %+v, want a timeout after the output so far", result)
+	}
+	if elapsed := time.Since(started); elapsed > 5*time.Second {
+		t.Errorf("timed-out run took %s", elapsed)
+	}
+
+	// A caller giving up isn't a timeout.
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	sb = testProcessSandbox(nil)
+	if _, err := sb.Exec(ctx, ExecOpts{Image: "sh", Command: []string{"sleep", "30"}}); err != context.DeadlineExceeded {
+		t.Errorf("Exec with a cancelled context = %v, want %v", err, context.DeadlineExceeded)
+	}
+}
+
+func TestProcessSandboxOutputs(t *testing.T) {
+	sb := testProcessSandbox(func(p *Policy) { p.MaxOutput = "16b" })
+	script := `printf 0123456789 > "$OUTPUT_DIR/a.txt"
+mkdir "$OUTPUT_DIR/charts" && printf PNG > "$OUTPUT_DIR/charts/plot.png"
+printf 0123456789 > "$1/z.txt"
+: > "$OUTPUT_DIR/empty"
+ln -s /etc/passwd "$OUTPUT_DIR/link"`
+	result, err := sb.Exec(context.Background(), ExecOpts{Image: "sh", Command: []string{"sh", "-c", script, "sh", "/output"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.ExitCode != 0 {
+		t.Fatalf("result = %+v", result)
+	}
+
+	// Files past the limit are named but not collected; empty files and
+	// symlinks are skipped.
+	var got []string
+	for _, f := range result.Outputs {
+		got = append(got, f.Name+"="+string(f.Data))
+	}
+	if want := []string{"a.txt=0123456789", "charts/plot.png=PNG"}; !slices.Equal(got, want) {
+		t.Errorf("Outputs = %q, want %q", got, want)
+	}
+	if want := []string{"z.txt"}; !slices.Equal(result.OutputsDropped, want) {
+		t.Errorf("OutputsDropped = %q, want %q", result.OutputsDropped, want)
+	}
+
+	// Without an output limit, runs get no OUTPUT_DIR.
+	sb = testProcessSandbox(func(p *Policy) { p.MaxOutput = "" })
+	result, err = sb.Exec(context.Background(), ExecOpts{Image: "sh", Command: []string{"sh", "-c", `echo "${OUTPUT_DIR:-unset}"`}})
+	if err != nil || result.Stdout != "unset\n" || len(result.Outputs) != 0 {
+		t.Errorf("without MaxOutput = %+v, %v", result, err)
+	}
+}
@@ -1,21 +1,48 @@
 package sandbox
 
-import "context"
+import (
+	"context"
+	"time"
+)
 
 // ExecOpts describes a code execution request.
 type ExecOpts struct {
-	Image   string // Docker image (e.g. "python:3.12-slim")
-	Command []string
-	Code    string // Source code to execute
+	Image    string // Docker image (e.g. "python:3.12-slim")
+	Command  []string
+	Code     string // Source code to execute
+	Filename string // Name Code is written to in /workspace (default "code")
+	// Files are written into /workspace alongside Code, keyed by relative
+	// path.
+	Files   map[string]string
 	Stdin   string
 	Workdir string
+	Env     []string // KEY=value pairs set in the container
+	Mounts  []Mount  // Extra volumes, alongside the code in /workspace
+	// OnOutput, if set, gets the output as the program writes it, so
+	// callers can show progress. The result still holds all of it.
+	OnOutput OutputFunc
+}
+
+// Mount attaches a host path or named volume to the container.
+type Mount struct {
+	Source   string // Host path or Docker volume name
+	Target   string // Path in the container
+	ReadOnly bool
 }
 
 // ExecResult is the output of a sandboxed execution.
 type ExecResult struct {
-	Stdout   string
-	Stderr   string
-	ExitCode int
+	Stdout    string
+	Stderr    string
+	ExitCode  int
+	TimedOut  bool          // killed when the policy's MaxTimeout ran out; ExitCode is -1
+	OOMKilled bool          // a process was killed for going over the memory limit
+	Duration  time.Duration // wall time, including starting a container
+	MaxMemory int64         // peak memory use in bytes; 0 when it couldn't be measured
+	// Outputs are the files the run left in OutputDir, and OutputsDropped
+	// the ones past the policy's MaxOutput, which weren't collected.
+	Outputs        []OutputFile
+	OutputsDropped []string
 }
 
 // Sandbox runs code in an isolated environment.
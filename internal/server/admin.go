@@ -0,0 +1,250 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/michaelbrown/forge/internal/config"
+	"github.com/michaelbrown/forge/internal/logging"
+)
+
+// The /api/admin routes manage a running server: reloading its config,
+// restarting and checking on tool servers, and draining sessions before a
+// shutdown. When the API has credentials, they need admin scope.
+
+// defaultDrainTimeout is how long a drain request waits for turns to
+// finish unless it says otherwise.
+const defaultDrainTimeout = 5 * time.Minute
+
+// requireAdmin refuses requests without admin credentials, when the API
+// has credentials at all.
+func (s *Server) requireAdmin(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.config().Server.Auth.Enabled() {
+			if c, _ := clientFrom(r.Context()); c.scope != config.ScopeAdmin {
+				writeError(w, http.StatusForbidden, "admin credentials required")
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// OnReload adds fn to what runs after the config is reloaded, for settings
+// the server doesn't own, such as logging.
+func (s *Server) OnReload(fn func(*config.Config)) {
+	s.reloadMu.Lock()
+	defer s.reloadMu.Unlock()
+	s.reloadHooks = append(s.reloadHooks, fn)
+}
+
+// reloadResult reports what a config reload did.
+type reloadResult struct {
+	Changed         []string          `json:"changed"`                    // settings that changed, such as providers.claude.base_url
+	ToolServers     map[string]string `json:"tool_servers"`               // server → started, restarted, stopped, or why it failed
+	RestartRequired []string          `json:"restart_required,omitempty"` // changed settings that apply only after a restart
+}
+
+// handleReloadConfig loads the config again and applies it.
+func (s *Server) handleReloadConfig(w http.ResponseWriter, r *http.Request) {
+	result, err := s.reload(r.Context())
+	if err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("loading config: %v", err))
+		return
+	}
+	writeJSON(w, http.StatusOK, result)
+}
+
+// reload loads the config again and applies it. Auth, rate limits,
+// webhooks, and providers apply to the next request; sessions already in
+// memory keep their agents. Tool servers that were added or changed are
+// started or restarted, and ones removed or disabled are stopped. Where to
+// listen and what to store in can't change while running.
+//
+// A config that doesn't load, or whose providers or agent settings are
+// broken, is refused and the running one kept; a tool server that won't
+// start is reported in the result instead.
+func (s *Server) reload(ctx context.Context) (reloadResult, error) {
+	cfg, err := s.loadConfig()
+	if err != nil {
+		return reloadResult{}, err
+	}
+	for _, p := range cfg.Check() {
+		if !p.Warning && !strings.HasPrefix(p.Key, "tools.") {
+			return reloadResult{}, errors.New(p.String())
+		}
+	}
+
+	s.reloadMu.Lock()
+	defer s.reloadMu.Unlock()
+
+	old := s.config()
+	result := reloadResult{Changed: config.Changes(old, cfg), ToolServers: map[string]string{}}
+	for name, changed := range map[string]bool{
+		"server.port":   old.Server.Port != cfg.Server.Port,
+		"server.listen": old.Server.Listen != cfg.Server.Listen,
+		"server.tls":    !reflect.DeepEqual(old.Server.TLS, cfg.Server.TLS),
+		"storage":       old.Storage != cfg.Storage,
+	} {
+		if changed {
+			result.RestartRequired = append(result.RestartRequired, name)
+		}
+	}
+	slices.Sort(result.RestartRequired)
+
+	s.cfg.Store(cfg)
+	if s.registry != nil && len(cfg.Server.Webhooks) > 0 {
+		s.registry.SetApprover(s.requestApproval)
+	}
+
+	for name, tc := range cfg.Tools {
+		running := s.registry.HasServer(name)
+		var err error
+		switch {
+		case !tc.Enabled && running:
+			err = s.registry.Remove(name)
+			result.ToolServers[name] = "stopped"
+		case tc.Enabled && !running:
+			err = s.registry.Register(name, tc)
+			result.ToolServers[name] = "started"
+		case tc.Enabled && !reflect.DeepEqual(old.Tools[name], tc):
+			err = s.registry.Restart(name, tc)
+			result.ToolServers[name] = "restarted"
+		}
+		if err != nil {
+			result.ToolServers[name] = err.Error()
+		}
+	}
+	for _, name := range s.registry.ServerNames() {
+		if _, ok := cfg.Tools[name]; !ok {
+			result.ToolServers[name] = "stopped"
+			if err := s.registry.Remove(name); err != nil {
+				result.ToolServers[name] = err.Error()
+			}
+		}
+	}
+
+	for _, fn := range s.reloadHooks {
+		fn(cfg)
+	}
+	logging.For("server").InfoContext(ctx, "config reloaded", "changed", result.Changed, "tool_servers", result.ToolServers, "restart_required", result.RestartRequired)
+	return result, nil
+}
+
+// toolServerHealth is how a tool server answered a ping.
+type toolServerHealth struct {
+	Name      string   `json:"name"`
+	Tools     []string `json:"tools"`
+	OK        bool     `json:"ok"`
+	Error     string   `json:"error,omitempty"`
+	LatencyMS int64    `json:"latency_ms"`
+}
+
+// handleToolServerHealth pings every registered tool server at once.
+func (s *Server) handleToolServerHealth(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	names := s.registry.ServerNames()
+	health := make([]toolServerHealth, len(names))
+	var wg sync.WaitGroup
+	for i, name := range names {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			start := time.Now()
+			err := s.registry.Ping(ctx, name)
+			h := toolServerHealth{
+				Name:      name,
+				Tools:     s.registry.ServerTools(name),
+				OK:        err == nil,
+				LatencyMS: time.Since(start).Milliseconds(),
+			}
+			if err != nil {
+				h.Error = err.Error()
+			}
+			health[i] = h
+		}()
+	}
+	wg.Wait()
+	writeJSON(w, http.StatusOK, health)
+}
+
+// handleToolServerLogs returns the latest lines a tool server wrote to
+// stderr. Only servers run as subprocesses have any.
+func (s *Server) handleToolServerLogs(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "name")
+	if !s.registry.HasServer(name) {
+		writeError(w, http.StatusNotFound, "tool server not found")
+		return
+	}
+	lines := s.registry.Logs(name)
+	if lines == nil {
+		lines = []string{}
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"name": name, "lines": lines})
+}
+
+// handleRestartToolServer replaces a tool server with a fresh one started
+// from its config. Its calls in progress fail; if it won't start again,
+// the old one keeps running.
+func (s *Server) handleRestartToolServer(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "name")
+	if !s.registry.HasServer(name) {
+		writeError(w, http.StatusNotFound, "tool server not found")
+		return
+	}
+	tc, ok := s.config().Tools[name]
+	if !ok {
+		writeError(w, http.StatusConflict, fmt.Sprintf("tool server %s is not in the config", name))
+		return
+	}
+	if err := s.registry.Restart(name, tc); err != nil {
+		writeError(w, http.StatusBadGateway, fmt.Sprintf("restarting %s: %v", name, err))
+		return
+	}
+	logging.For("tools").InfoContext(r.Context(), "tool server restarted", "server", name)
+	writeJSON(w, http.StatusOK, toolServerInfo{Name: name, Tools: s.registry.ServerTools(name)})
+}
+
+// handleDrain turns away new messages and waits, up to ?timeout= (a
+// duration such as 30s; 5m by default), for the turns running or queued
+// to finish. It reports whether they did; asking again keeps waiting.
+// Scheduled tasks wait too.
+func (s *Server) handleDrain(w http.ResponseWriter, r *http.Request) {
+	timeout := defaultDrainTimeout
+	if v := r.URL.Query().Get("timeout"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d < 0 {
+			writeError(w, http.StatusBadRequest, "invalid timeout")
+			return
+		}
+		timeout = d
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), timeout)
+	defer cancel()
+
+	err := s.sessions.Drain(ctx)
+	if r.Context().Err() != nil {
+		return
+	}
+	busy := s.sessions.Busy()
+	logging.For("server").InfoContext(r.Context(), "draining", "busy_sessions", busy)
+	writeJSON(w, http.StatusOK, map[string]any{"draining": true, "idle": err == nil, "busy": busy})
+}
+
+// handleResume accepts messages again after a drain.
+func (s *Server) handleResume(w http.ResponseWriter, r *http.Request) {
+	s.sessions.Resume()
+	logging.For("server").InfoContext(r.Context(), "resumed after draining")
+	w.WriteHeader(http.StatusNoContent)
+}
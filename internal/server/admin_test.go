@@ -0,0 +1,194 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/michaelbrown/forge/internal/config"
+	"github.com/michaelbrown/forge/internal/storage"
+	"github.com/michaelbrown/forge/internal/tools"
+)
+
+func TestAdminNeedsAdminScope(t *testing.T) {
+	srv := newTestServer(t)
+	srv.config().Server.Auth.APIKeys = []config.APIKey{
+		{Name: "ci", Key: "full-key", Scope: config.ScopeFull},
+		{Name: "ops", Key: "admin-key", Scope: config.ScopeAdmin},
+	}
+	for key, want := range map[string]int{"full-key": http.StatusForbidden, "admin-key": http.StatusOK} {
+		req := httptest.NewRequest("GET", "/api/admin/tool-servers", nil)
+		req.Header.Set("X-API-Key", key)
+		w := httptest.NewRecorder()
+		srv.router.ServeHTTP(w, req)
+		if w.Code != want {
+			t.Errorf("%s: expected %d, got %d", key, want, w.Code)
+		}
+	}
+
+	// Admin credentials can do what full ones can.
+	req := httptest.NewRequest("POST", "/api/sessions", strings.NewReader(`{}`))
+	req.Header.Set("X-API-Key", "admin-key")
+	w := httptest.NewRecorder()
+	srv.router.ServeHTTP(w, req)
+	if w.Code != http.StatusCreated {
+		t.Errorf("admin write: expected 201, got %d", w.Code)
+	}
+}
+
+func TestReloadConfig(t *testing.T) {
+	srv := newTestServer(t)
+	if err := srv.registry.Add("dropped", tools.NewBuiltinServer()); err != nil {
+		t.Fatal(err)
+	}
+
+	next := *srv.config()
+	next.Server.Port = srv.config().Server.Port + 1
+	next.Server.RateLimit.RequestsPerMinute = 30
+	next.Tools = map[string]tools.ToolServerConfig{
+		"broken": {Binary: "/nonexistent/binary", Enabled: true},
+	}
+	srv.loadConfig = func() (*config.Config, error) {
+		cfg := next
+		return &cfg, nil
+	}
+	var hooked *config.Config
+	srv.OnReload(func(cfg *config.Config) { hooked = cfg })
+
+	w := httptest.NewRecorder()
+	srv.router.ServeHTTP(w, httptest.NewRequest("POST", "/api/admin/reload", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var result reloadResult
+	json.NewDecoder(w.Body).Decode(&result)
+	want := []string{"server.port", "server.rate_limit.requests_per_minute", "tools.broken.binary", "tools.broken.enabled"}
+	if !slices.Equal(result.Changed, want) {
+		t.Errorf("changed = %v, want %v", result.Changed, want)
+	}
+	if !slices.Equal(result.RestartRequired, []string{"server.port"}) {
+		t.Errorf("restart_required = %v", result.RestartRequired)
+	}
+	if result.ToolServers["dropped"] != "stopped" || srv.registry.HasServer("dropped") {
+		t.Errorf("dropped server: %q", result.ToolServers["dropped"])
+	}
+	if got := result.ToolServers["broken"]; got == "started" || got == "" {
+		t.Errorf("broken server: %q, want an error", got)
+	}
+	if srv.config().Server.RateLimit.RequestsPerMinute != 30 {
+		t.Error("reloaded settings weren't applied")
+	}
+	if hooked == nil || hooked.Server.Port != next.Server.Port {
+		t.Error("reload hook didn't get the new config")
+	}
+}
+
+func TestReloadRefusesBrokenConfig(t *testing.T) {
+	srv := newTestServer(t)
+	next := *srv.config()
+	next.DefaultProvider = "missing"
+	next.Agent.MaxIterations = 50
+	srv.loadConfig = func() (*config.Config, error) {
+		cfg := next
+		return &cfg, nil
+	}
+
+	w := httptest.NewRecorder()
+	srv.router.ServeHTTP(w, httptest.NewRequest("POST", "/api/admin/reload", nil))
+	if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "default_provider") {
+		t.Errorf("expected 400 naming default_provider, got %d: %s", w.Code, w.Body.String())
+	}
+	if srv.config().DefaultProvider != "ollama" || srv.config().Agent.MaxIterations == 50 {
+		t.Error("a broken config was applied")
+	}
+}
+
+func TestToolServerAdmin(t *testing.T) {
+	srv := newTestServer(t)
+	if err := srv.registry.Add("pack", tools.NewBuiltinServer()); err != nil {
+		t.Fatal(err)
+	}
+
+	w := httptest.NewRecorder()
+	srv.router.ServeHTTP(w, httptest.NewRequest("GET", "/api/admin/tool-servers", nil))
+	var health []toolServerHealth
+	json.NewDecoder(w.Body).Decode(&health)
+	if len(health) != 1 || health[0].Name != "pack" || !health[0].OK || len(health[0].Tools) == 0 {
+		t.Errorf("health = %+v", health)
+	}
+
+	for _, tt := range []struct {
+		method, url string
+		want        int
+	}{
+		{"GET", "/api/admin/tool-servers/pack/logs", http.StatusOK},
+		{"GET", "/api/admin/tool-servers/missing/logs", http.StatusNotFound},
+		{"POST", "/api/admin/tool-servers/pack/restart", http.StatusConflict}, // not in the config
+		{"POST", "/api/admin/tool-servers/missing/restart", http.StatusNotFound},
+	} {
+		w := httptest.NewRecorder()
+		srv.router.ServeHTTP(w, httptest.NewRequest(tt.method, tt.url, nil))
+		if w.Code != tt.want {
+			t.Errorf("%s %s: expected %d, got %d", tt.method, tt.url, tt.want, w.Code)
+		}
+	}
+}
+
+func TestDrain(t *testing.T) {
+	srv := newTestServer(t)
+	ctx := context.Background()
+	sess := &storage.Session{ID: "drain1", Title: "Busy", Status: storage.StatusActive}
+	srv.store.CreateSession(ctx, sess)
+	as, err := srv.sessions.GetOrCreate(ctx, sess, srv.config(), srv.store, srv.registry)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := &blockingClient{started: make(chan struct{}, 1)}
+	as.Agent.SetClient(client)
+
+	send := func() *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		srv.router.ServeHTTP(w, httptest.NewRequest("POST", "/api/sessions/drain1/messages", strings.NewReader(`{"content":"hi"}`)))
+		return w
+	}
+	drain := func() map[string]any {
+		w := httptest.NewRecorder()
+		srv.router.ServeHTTP(w, httptest.NewRequest("POST", "/api/admin/drain?timeout=50ms", nil))
+		var resp map[string]any
+		json.NewDecoder(w.Body).Decode(&resp)
+		return resp
+	}
+
+	sent := make(chan *httptest.ResponseRecorder)
+	go func() { sent <- send() }()
+	<-client.started
+
+	if resp := drain(); resp["idle"] != false || resp["busy"] != float64(1) {
+		t.Errorf("drain with a turn running = %v", resp)
+	}
+	if w := send(); w.Code != http.StatusServiceUnavailable {
+		t.Errorf("message while draining: expected 503, got %d", w.Code)
+	}
+
+	as.cancelTurn()
+	<-sent
+	if resp := drain(); resp["idle"] != true {
+		t.Errorf("drain once idle = %v", resp)
+	}
+
+	w := httptest.NewRecorder()
+	srv.router.ServeHTTP(w, httptest.NewRequest("DELETE", "/api/admin/drain", nil))
+	if w.Code != http.StatusNoContent || srv.sessions.Draining() {
+		t.Fatalf("resume: got %d, draining = %v", w.Code, srv.sessions.Draining())
+	}
+	go func() { sent <- send() }()
+	<-client.started
+	as.cancelTurn()
+	if w := <-sent; w.Code == http.StatusServiceUnavailable {
+		t.Error("message after resuming was refused")
+	}
+}
@@ -0,0 +1,140 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/michaelbrown/forge/internal/document"
+	"github.com/michaelbrown/forge/internal/storage"
+)
+
+// Attachments are files uploaded to a session, kept as its artifacts.
+// Messages name the attachments they come with, and the agent gets each
+// one's text if it's a document short enough to include, and otherwise
+// where the file is, so its tools can open it.
+
+const (
+	attachmentTool    = "upload"  // Artifact.Tool of uploaded files
+	maxUploadSize     = 100 << 20 // the most one request may upload
+	maxAttachmentText = 100 << 10 // the most text of one attachment put in a message
+)
+
+// handleUploadAttachments saves the files of a multipart upload as the
+// session's artifacts and returns their records, whose names messages use
+// to refer to them.
+func (s *Server) handleUploadAttachments(w http.ResponseWriter, r *http.Request) {
+	sess, err := s.store.GetSession(r.Context(), chi.URLParam(r, "id"))
+	if err != nil {
+		writeError(w, http.StatusNotFound, "session not found")
+		return
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, maxUploadSize)
+	mr, err := r.MultipartReader()
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "expected a multipart/form-data upload")
+		return
+	}
+
+	saved := []*storage.Artifact{}
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			break
+		}
+		var tooBig *http.MaxBytesError
+		if errors.As(err, &tooBig) {
+			writeError(w, http.StatusRequestEntityTooLarge, fmt.Sprintf("uploads are limited to %d MB", maxUploadSize>>20))
+			return
+		}
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if part.FileName() == "" {
+			continue
+		}
+		data, err := io.ReadAll(io.LimitReader(part, storage.MaxArtifactSize+1))
+		if errors.As(err, &tooBig) || len(data) > storage.MaxArtifactSize {
+			writeError(w, http.StatusRequestEntityTooLarge, fmt.Sprintf("%s is larger than %d MB", part.FileName(), storage.MaxArtifactSize>>20))
+			return
+		}
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		// Browsers send octet-stream for types they don't know; the name
+		// says more.
+		contentType := part.Header.Get("Content-Type")
+		if contentType == "application/octet-stream" {
+			contentType = ""
+		}
+		art, err := s.artifacts.Save(r.Context(), sess.ID, part.FileName(), contentType, attachmentTool, data)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		saved = append(saved, art)
+	}
+	if len(saved) == 0 {
+		writeError(w, http.StatusBadRequest, "no files in the upload")
+		return
+	}
+	writeJSON(w, http.StatusCreated, saved)
+}
+
+// withAttachments returns content followed by the named artifacts of the
+// session: the text of each document, or where the file is if its text
+// can't be extracted or is too long.
+func (s *Server) withAttachments(ctx context.Context, sessionID, content string, names []string) (string, error) {
+	if len(names) == 0 {
+		return content, nil
+	}
+	var b strings.Builder
+	b.WriteString(content)
+	for _, name := range names {
+		art, path, err := s.artifacts.Open(ctx, sessionID, name)
+		if err != nil {
+			return "", fmt.Errorf("attachment %s not found", name)
+		}
+		if abs, err := filepath.Abs(path); err == nil {
+			path = abs
+		}
+		desc := fmt.Sprintf("%s (%s, %d bytes)", art.Name, art.MIMEType, art.Size)
+		if art.MIMEType == "" {
+			desc = fmt.Sprintf("%s (%d bytes)", art.Name, art.Size)
+		}
+
+		if text, ok := attachmentText(path, art); ok {
+			fmt.Fprintf(&b, "\n\n[Attached file %s]\n%s\n[End of %s]", desc, text, art.Name)
+		} else {
+			fmt.Fprintf(&b, "\n\n[Attached file %s is at %s]", desc, path)
+		}
+	}
+	return b.String(), nil
+}
+
+// attachmentText extracts the text of an attachment, if it's a document
+// whose text fits in a message.
+func attachmentText(path string, art *storage.Artifact) (string, bool) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", false
+	}
+	doc, err := document.Extract(data, art.Name, art.MIMEType)
+	if err != nil {
+		return "", false
+	}
+	text := strings.TrimSpace(strings.Join(doc.Pages, "\n\n"))
+	if text == "" || len(text) > maxAttachmentText {
+		return "", false
+	}
+	return text, true
+}
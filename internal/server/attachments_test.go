@@ -0,0 +1,89 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/michaelbrown/forge/internal/llm"
+	"github.com/michaelbrown/forge/internal/storage"
+)
+
+func TestAttachments(t *testing.T) {
+	srv := newTestServer(t)
+	srv.config().Storage.ArtifactsDir = t.TempDir()
+	srv.artifacts = storage.NewArtifacts(srv.store, srv.config().Storage.ArtifactsDir)
+	ctx := context.Background()
+	sess := &storage.Session{ID: "attach1", Title: "Docs", Status: storage.StatusActive}
+	srv.store.CreateSession(ctx, sess)
+
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	fw, _ := mw.CreateFormFile("file", "notes.txt")
+	fw.Write([]byte("The launch code is 42."))
+	fw, _ = mw.CreateFormFile("file", "pixel.png")
+	fw.Write([]byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR"))
+	mw.Close()
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest("POST", "/api/sessions/attach1/attachments", &body)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	srv.router.ServeHTTP(w, req)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("upload: expected 201, got %d: %s", w.Code, w.Body.String())
+	}
+	var saved []storage.Artifact
+	json.NewDecoder(w.Body).Decode(&saved)
+	if len(saved) != 2 || saved[0].Name != "notes.txt" || saved[1].Tool != attachmentTool {
+		t.Fatalf("saved = %+v", saved)
+	}
+
+	as, err := srv.sessions.GetOrCreate(ctx, sess, srv.config(), srv.store, srv.registry)
+	if err != nil {
+		t.Fatal(err)
+	}
+	as.Agent.SetClient(&streamingClient{})
+	w = httptest.NewRecorder()
+	srv.router.ServeHTTP(w, httptest.NewRequest("POST", "/api/sessions/attach1/messages",
+		strings.NewReader(`{"content":"What's in these?","attachments":["notes.txt","pixel.png"]}`)))
+	if w.Code != http.StatusOK {
+		t.Fatalf("send: expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	msgs, _ := srv.store.LoadMessages(ctx, "attach1")
+	var prompt string
+	for _, m := range msgs {
+		if m.Role == llm.RoleUser {
+			prompt = m.Content
+		}
+	}
+	if !strings.HasPrefix(prompt, "What's in these?") || !strings.Contains(prompt, "The launch code is 42.") {
+		t.Errorf("prompt doesn't include the document's text:\n%s", prompt)
+	}
+	if !strings.Contains(prompt, "[Attached file pixel.png (image/png, 16 bytes) is at /") {
+		t.Errorf("prompt doesn't say where the image is:\n%s", prompt)
+	}
+
+	for _, tt := range []struct {
+		path, contentType, body string
+		want                    int
+	}{
+		{"/api/sessions/attach1/messages", "", `{"content":"hi","attachments":["missing.pdf"]}`, http.StatusBadRequest},
+		{"/api/sessions/attach1/attachments", "application/json", `{}`, http.StatusBadRequest},
+		{"/api/sessions/nope/attachments", "", ``, http.StatusNotFound},
+	} {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest("POST", tt.path, strings.NewReader(tt.body))
+		if tt.contentType != "" {
+			req.Header.Set("Content-Type", tt.contentType)
+		}
+		srv.router.ServeHTTP(w, req)
+		if w.Code != tt.want {
+			t.Errorf("POST %s %s: expected %d, got %d", tt.path, tt.body, tt.want, w.Code)
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package server
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/michaelbrown/forge/internal/config"
+)
+
+// authenticate checks the credentials of API requests against
+// server.auth, when it has any. Clients send an API key as a bearer token
+// or in X-API-Key, or a username and password with basic auth. Browsers
+// can't set headers on a WebSocket upgrade, so it may pass the key as
+// ?api_key= instead. Read-only credentials may only make GET requests, and
+// the WebSocket, which runs the agent, needs full or admin scope.
+func (s *Server) authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth := s.config().Server.Auth
+		if !auth.Enabled() {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		cred, ok := matchCredential(auth, r)
+		if !ok {
+			if len(auth.Users) > 0 {
+				w.Header().Set("WWW-Authenticate", `Basic realm="forge", charset="UTF-8"`)
+			}
+			writeError(w, http.StatusUnauthorized, "authentication required")
+			return
+		}
+		upgrade := websocket.IsWebSocketUpgrade(r)
+		readOnly := (r.Method == http.MethodGet || r.Method == http.MethodHead) && !upgrade
+		if cred.scope == config.ScopeRead && !readOnly {
+			writeError(w, http.StatusForbidden, "credentials are read-only")
+			return
+		}
+		// Browsers send basic auth credentials they remember along with
+		// WebSocket upgrades from any site, so those must come from ours
+		// or one trusted with credentials in server.cors.
+		if _, _, basic := r.BasicAuth(); basic && upgrade && !sameOrigin(r) && !s.trustsOrigin(r) {
+			writeError(w, http.StatusForbidden, "cross-origin WebSocket")
+			return
+		}
+		limits := s.config().Server.RateLimit
+		if cred.rateLimit != nil {
+			limits = *cred.rateLimit
+		}
+		next.ServeHTTP(w, r.WithContext(withClient(r.Context(), client{id: cred.id, scope: cred.scope, limits: limits})))
+	})
+}
+
+// credential is a configured credential a request carried.
+type credential struct {
+	id        string // names it in rate limits
+	scope     string
+	rateLimit *config.RateLimitConfig
+}
+
+// matchCredential returns the configured credential r carries, if any.
+func matchCredential(auth config.AuthConfig, r *http.Request) (credential, bool) {
+	if user, password, ok := r.BasicAuth(); ok {
+		for _, u := range auth.Users {
+			// Compare both, so a wrong username takes as long as a wrong password.
+			userOK := secretEqual(user, u.Username)
+			if secretEqual(password, u.Password) && userOK {
+				return credential{id: "user:" + u.Username, scope: u.Scope, rateLimit: u.RateLimit}, true
+			}
+		}
+		return credential{}, false
+	}
+
+	key := r.Header.Get("X-API-Key")
+	if bearer, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+		key = strings.TrimSpace(bearer)
+	}
+	if key == "" && websocket.IsWebSocketUpgrade(r) {
+		key = r.URL.Query().Get("api_key")
+	}
+	if key == "" {
+		return credential{}, false
+	}
+	for i, k := range auth.APIKeys {
+		if secretEqual(key, k.Key) {
+			id := "key:" + k.Name
+			if k.Name == "" {
+				id = fmt.Sprintf("key:%d", i+1)
+			}
+			return credential{id: id, scope: k.Scope, rateLimit: k.RateLimit}, true
+		}
+	}
+	return credential{}, false
+}
+
+// sameOrigin reports whether r has no Origin header or one naming the host
+// it was sent to.
+func sameOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	return err == nil && strings.EqualFold(u.Host, r.Host)
+}
+
+// trustsOrigin reports whether server.cors lets r's origin send credentials.
+func (s *Server) trustsOrigin(r *http.Request) bool {
+	cors := s.config().Server.CORS
+	return cors.AllowCredentials && cors.AllowsOrigin(r.Header.Get("Origin"))
+}
+
+// secretEqual compares a credential in constant time.
+func secretEqual(given, want string) bool {
+	return subtle.ConstantTimeCompare([]byte(given), []byte(want)) == 1
+}
@@ -0,0 +1,75 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/michaelbrown/forge/internal/config"
+)
+
+func TestAuthenticate(t *testing.T) {
+	srv := newTestServer(t)
+
+	// Without credentials configured, the API is open.
+	w := httptest.NewRecorder()
+	srv.router.ServeHTTP(w, httptest.NewRequest("GET", "/api/sessions", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("open API: expected 200, got %d", w.Code)
+	}
+
+	srv.config().Server.Auth = config.AuthConfig{
+		APIKeys: []config.APIKey{
+			{Name: "ci", Key: "full-key", Scope: config.ScopeFull},
+			{Name: "dash", Key: "read-key", Scope: config.ScopeRead},
+		},
+		Users: []config.BasicUser{{Username: "me", Password: "pw", Scope: config.ScopeFull}},
+	}
+
+	wsHeaders := map[string]string{"Connection": "Upgrade", "Upgrade": "websocket"}
+	for _, tt := range []struct {
+		name    string
+		method  string
+		url     string
+		headers map[string]string
+		user    string // basic auth as user:password
+		want    int
+	}{
+		{"no credentials", "GET", "/api/sessions", nil, "", http.StatusUnauthorized},
+		{"wrong key", "GET", "/api/sessions", map[string]string{"X-API-Key": "nope"}, "", http.StatusUnauthorized},
+		{"bearer key", "GET", "/api/sessions", map[string]string{"Authorization": "Bearer full-key"}, "", http.StatusOK},
+		{"header key", "GET", "/api/sessions", map[string]string{"X-API-Key": "read-key"}, "", http.StatusOK},
+		{"read-only write", "POST", "/api/sessions", map[string]string{"X-API-Key": "read-key"}, "", http.StatusForbidden},
+		{"full write", "POST", "/api/sessions", map[string]string{"X-API-Key": "full-key"}, "", http.StatusCreated},
+		{"basic auth", "GET", "/api/sessions", nil, "me:pw", http.StatusOK},
+		{"wrong password", "GET", "/api/sessions", nil, "me:nope", http.StatusUnauthorized},
+		{"query key ignored", "GET", "/api/sessions?api_key=full-key", nil, "", http.StatusUnauthorized},
+		{"read-only websocket", "GET", "/api/sessions/none/ws?api_key=read-key", wsHeaders, "", http.StatusForbidden},
+		{"cross-origin websocket", "GET", "/api/sessions/none/ws", map[string]string{"Connection": "Upgrade", "Upgrade": "websocket", "Origin": "https://evil.example"}, "me:pw", http.StatusForbidden},
+		{"websocket query key", "GET", "/api/sessions/none/ws?api_key=full-key", wsHeaders, "", http.StatusNotFound},
+	} {
+		req := httptest.NewRequest(tt.method, tt.url, strings.NewReader(`{}`))
+		for k, v := range tt.headers {
+			req.Header.Set(k, v)
+		}
+		if user, password, ok := strings.Cut(tt.user, ":"); ok {
+			req.SetBasicAuth(user, password)
+		}
+		w := httptest.NewRecorder()
+		srv.router.ServeHTTP(w, req)
+		if w.Code != tt.want {
+			t.Errorf("%s: expected %d, got %d: %s", tt.name, tt.want, w.Code, w.Body.String())
+		}
+		if tt.want == http.StatusUnauthorized && w.Header().Get("WWW-Authenticate") == "" {
+			t.Errorf("%s: no basic auth challenge", tt.name)
+		}
+	}
+
+	// The web UI itself is served without credentials.
+	w = httptest.NewRecorder()
+	srv.router.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	if w.Code == http.StatusUnauthorized {
+		t.Error("web UI requires credentials")
+	}
+}
@@ -0,0 +1,62 @@
+package server
+
+import (
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// defaultCORSMaxAge is how long, in seconds, browsers may cache a preflight
+// answer unless server.cors.max_age says otherwise.
+const defaultCORSMaxAge = 600
+
+// corsHeaders are the request headers cross-origin callers may always send.
+var corsHeaders = []string{"Content-Type", "Authorization", "X-API-Key"}
+
+// corsExposed are the response headers cross-origin callers may read.
+const corsExposed = "X-Total-Count, X-Next-Cursor, Retry-After, Content-Disposition"
+
+// cors lets the origins in server.cors call the API from a browser. With
+// none configured, only pages the server itself serves can. Requests from
+// other origins still reach the handlers; browsers just won't show their
+// responses to the page.
+func (s *Server) cors(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cors := s.config().Server.CORS
+		origin := r.Header.Get("Origin")
+		if origin == "" || sameOrigin(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		h := w.Header()
+		h.Add("Vary", "Origin")
+		if !cors.AllowsOrigin(origin) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if slices.Contains(cors.AllowedOrigins, "*") && !cors.AllowCredentials {
+			h.Set("Access-Control-Allow-Origin", "*")
+		} else {
+			h.Set("Access-Control-Allow-Origin", origin)
+		}
+		if cors.AllowCredentials {
+			h.Set("Access-Control-Allow-Credentials", "true")
+		}
+
+		if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+			maxAge := cors.MaxAge
+			if maxAge == 0 {
+				maxAge = defaultCORSMaxAge
+			}
+			h.Set("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, DELETE")
+			h.Set("Access-Control-Allow-Headers", strings.Join(slices.Concat(corsHeaders, cors.AllowedHeaders), ", "))
+			h.Set("Access-Control-Max-Age", strconv.Itoa(maxAge))
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		h.Set("Access-Control-Expose-Headers", corsExposed)
+		next.ServeHTTP(w, r)
+	})
+}
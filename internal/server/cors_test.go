@@ -0,0 +1,74 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/michaelbrown/forge/internal/config"
+)
+
+func TestCORS(t *testing.T) {
+	srv := newTestServer(t)
+	request := func(method, origin string, headers map[string]string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/api/sessions", nil)
+		req.Header.Set("Origin", origin)
+		for k, v := range headers {
+			req.Header.Set(k, v)
+		}
+		w := httptest.NewRecorder()
+		srv.router.ServeHTTP(w, req)
+		return w
+	}
+
+	// By default, only the server's own pages may read responses.
+	if w := request("GET", "https://app.example.com", nil); w.Header().Get("Access-Control-Allow-Origin") != "" {
+		t.Error("cross-origin request allowed by default")
+	}
+	if w := request("GET", "http://example.com", nil); w.Code != http.StatusOK || w.Header().Get("Vary") != "" {
+		t.Errorf("same-origin request: %d, Vary %q", w.Code, w.Header().Get("Vary"))
+	}
+
+	srv.config().Server.CORS = config.CORSConfig{
+		AllowedOrigins:   []string{"https://app.example.com"},
+		AllowedHeaders:   []string{"X-Trace"},
+		AllowCredentials: true,
+	}
+	srv.config().Server.Auth = config.AuthConfig{APIKeys: []config.APIKey{{Key: "k", Scope: config.ScopeFull}}}
+
+	// Preflights are answered before credentials are checked.
+	w := request("OPTIONS", "https://app.example.com", map[string]string{"Access-Control-Request-Method": "POST"})
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("preflight: expected 204, got %d", w.Code)
+	}
+	for header, want := range map[string]string{
+		"Access-Control-Allow-Origin":      "https://app.example.com",
+		"Access-Control-Allow-Credentials": "true",
+		"Access-Control-Allow-Headers":     "Content-Type, Authorization, X-API-Key, X-Trace",
+		"Access-Control-Max-Age":           "600",
+		"Vary":                             "Origin",
+	} {
+		if got := w.Header().Get(header); got != want {
+			t.Errorf("preflight %s = %q, want %q", header, got, want)
+		}
+	}
+
+	w = request("GET", "https://app.example.com", map[string]string{"X-API-Key": "k"})
+	if w.Code != http.StatusOK || w.Header().Get("Access-Control-Allow-Origin") != "https://app.example.com" {
+		t.Errorf("allowed origin: %d, allow-origin %q", w.Code, w.Header().Get("Access-Control-Allow-Origin"))
+	}
+	if w.Header().Get("Access-Control-Expose-Headers") == "" {
+		t.Error("no exposed headers")
+	}
+
+	w = request("OPTIONS", "https://evil.example", map[string]string{"Access-Control-Request-Method": "POST"})
+	if w.Code == http.StatusNoContent || w.Header().Get("Access-Control-Allow-Origin") != "" {
+		t.Errorf("disallowed origin: %d, allow-origin %q", w.Code, w.Header().Get("Access-Control-Allow-Origin"))
+	}
+
+	// Without credentials, a wildcard is sent as such.
+	srv.config().Server.CORS = config.CORSConfig{AllowedOrigins: []string{"*"}}
+	if w := request("GET", "https://anywhere.example", map[string]string{"X-API-Key": "k"}); w.Header().Get("Access-Control-Allow-Origin") != "*" {
+		t.Errorf("wildcard allow-origin = %q", w.Header().Get("Access-Control-Allow-Origin"))
+	}
+}
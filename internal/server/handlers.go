@@ -3,16 +3,28 @@ package server
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"maps"
+	"mime"
 	"net/http"
+	"os"
+	"slices"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/google/uuid"
 
+	"github.com/michaelbrown/forge/internal/agent"
+	"github.com/michaelbrown/forge/internal/config"
 	"github.com/michaelbrown/forge/internal/llm"
+	"github.com/michaelbrown/forge/internal/logging"
 	"github.com/michaelbrown/forge/internal/storage"
+	"github.com/michaelbrown/forge/internal/tools"
 )
 
 // --- JSON helpers ---
@@ -33,14 +45,23 @@ func decodeJSON(r *http.Request, v any) error {
 
 // --- Session handlers ---
 
+// defaultPageSize is how many sessions a listing returns without a limit.
+const defaultPageSize = 50
+
+// handleListSessions returns a page of sessions. The X-Total-Count header
+// gives how many sessions match the filters, and X-Next-Cursor, when there
+// are more, the cursor parameter for the next page.
 func (s *Server) handleListSessions(w http.ResponseWriter, r *http.Request) {
-	opts := storage.SessionListOptions{}
+	opts := storage.SessionListOptions{Limit: defaultPageSize}
 
 	if status := r.URL.Query().Get("status"); status != "" {
 		opts.Status = storage.SessionStatus(status)
 	}
+	opts.Tag = r.URL.Query().Get("tag")
+	opts.Profile = r.URL.Query().Get("profile")
+	opts.All = r.URL.Query().Get("all") == "true"
 	if limit := r.URL.Query().Get("limit"); limit != "" {
-		if n, err := strconv.Atoi(limit); err == nil {
+		if n, err := strconv.Atoi(limit); err == nil && n > 0 {
 			opts.Limit = n
 		}
 	}
@@ -49,13 +70,33 @@ func (s *Server) handleListSessions(w http.ResponseWriter, r *http.Request) {
 			opts.Offset = n
 		}
 	}
+	if opts.Cursor = r.URL.Query().Get("cursor"); opts.Cursor != "" {
+		if _, _, err := storage.ParseSessionCursor(opts.Cursor); err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
 
+	total, err := s.store.CountSessions(r.Context(), opts)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	// Ask for one more session than the page holds to learn whether
+	// there's a next page.
+	limit := opts.Limit
+	opts.Limit++
 	sessions, err := s.store.ListSessions(r.Context(), opts)
 	if err != nil {
 		writeError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
 
+	w.Header().Set("X-Total-Count", strconv.Itoa(total))
+	if len(sessions) > limit {
+		sessions = sessions[:limit]
+		w.Header().Set("X-Next-Cursor", storage.SessionCursor(sessions[limit-1]))
+	}
 	if sessions == nil {
 		sessions = []storage.Session{}
 	}
@@ -63,10 +104,11 @@ func (s *Server) handleListSessions(w http.ResponseWriter, r *http.Request) {
 }
 
 type createSessionRequest struct {
-	Provider string `json:"provider"`
-	Model    string `json:"model"`
-	Profile  string `json:"profile"`
-	Title    string `json:"title"`
+	Provider string   `json:"provider"`
+	Model    string   `json:"model"`
+	Profile  string   `json:"profile"`
+	Title    string   `json:"title"`
+	Tags     []string `json:"tags"`
 }
 
 func (s *Server) handleCreateSession(w http.ResponseWriter, r *http.Request) {
@@ -76,12 +118,13 @@ func (s *Server) handleCreateSession(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	cfg := s.config()
 	providerName := req.Provider
 	if providerName == "" {
-		providerName = s.cfg.DefaultProvider
+		providerName = cfg.DefaultProvider
 	}
 
-	provider, err := s.cfg.Provider(providerName)
+	provider, err := cfg.Provider(providerName)
 	if err != nil {
 		writeError(w, http.StatusBadRequest, err.Error())
 		return
@@ -92,6 +135,12 @@ func (s *Server) handleCreateSession(w http.ResponseWriter, r *http.Request) {
 		model = provider.Models["default"]
 	}
 
+	tags, err := storage.NormalizeTags(req.Tags)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
 	sess := &storage.Session{
 		ID:       uuid.New().String(),
 		Title:    req.Title,
@@ -99,12 +148,14 @@ func (s *Server) handleCreateSession(w http.ResponseWriter, r *http.Request) {
 		Provider: providerName,
 		Model:    model,
 		Profile:  req.Profile,
+		Tags:     tags,
 	}
 
 	if err := s.store.CreateSession(r.Context(), sess); err != nil {
 		writeError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
+	s.notify(config.EventSessionCreated, sess, nil)
 
 	writeJSON(w, http.StatusCreated, sess)
 }
@@ -138,17 +189,57 @@ func (s *Server) handleUpdateSession(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var req struct {
-		Provider string `json:"provider"`
-		Model    string `json:"model"`
+		Title    *string   `json:"title"`
+		Provider string    `json:"provider"`
+		Model    string    `json:"model"`
+		Profile  *string   `json:"profile"` // "" clears it
+		Status   *string   `json:"status"`
+		Tags     *[]string `json:"tags"` // replaces the tags when set
 	}
 	if err := decodeJSON(r, &req); err != nil {
 		writeError(w, http.StatusBadRequest, "invalid JSON: "+err.Error())
 		return
 	}
+	// The agent is rebuilt when its settings change; a new title alone
+	// leaves a conversation in progress alone.
+	evict := req.Provider != "" || req.Model != "" || req.Profile != nil || req.Status != nil
+
+	if req.Title != nil {
+		title := strings.TrimSpace(*req.Title)
+		if title == "" {
+			writeError(w, http.StatusBadRequest, "title must not be empty")
+			return
+		}
+		sess.Title = title
+	}
+
+	if req.Profile != nil {
+		if err := s.checkProfile(*req.Profile); err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		sess.Profile = *req.Profile
+	}
+
+	if req.Status != nil {
+		status := storage.SessionStatus(*req.Status)
+		switch status {
+		case storage.StatusActive, storage.StatusCompleted, storage.StatusFailed, storage.StatusArchived:
+		default:
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid status %q (want active, completed, failed, or archived)", *req.Status))
+			return
+		}
+		if sess.Status == storage.StatusRunning && status != sess.Status {
+			writeError(w, http.StatusConflict, "session is running")
+			return
+		}
+		sess.Status = status
+	}
 
 	// If only model is set and it matches a provider name, treat it as a provider switch
+	cfg := s.config()
 	if req.Provider == "" && req.Model != "" {
-		if p, ok := s.cfg.Providers[req.Model]; ok {
+		if p, ok := cfg.Providers[req.Model]; ok {
 			req.Provider = req.Model
 			req.Model = p.Models["default"]
 		}
@@ -156,14 +247,14 @@ func (s *Server) handleUpdateSession(w http.ResponseWriter, r *http.Request) {
 
 	if req.Provider != "" {
 		// Validate provider exists
-		if _, err := s.cfg.Provider(req.Provider); err != nil {
+		if _, err := cfg.Provider(req.Provider); err != nil {
 			writeError(w, http.StatusBadRequest, err.Error())
 			return
 		}
 		sess.Provider = req.Provider
 		// If switching provider without specifying a model, use provider's default
 		if req.Model == "" {
-			if p, ok := s.cfg.Providers[req.Provider]; ok {
+			if p, ok := cfg.Providers[req.Provider]; ok {
 				sess.Model = p.Models["default"]
 			}
 		}
@@ -172,19 +263,37 @@ func (s *Server) handleUpdateSession(w http.ResponseWriter, r *http.Request) {
 		sess.Model = req.Model
 	}
 
+	if req.Tags != nil {
+		tags, err := storage.NormalizeTags(*req.Tags)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if err := s.store.SetSessionTags(r.Context(), sess.ID, tags); err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		sess.Tags = tags
+	}
+
 	if err := s.store.UpdateSession(r.Context(), sess); err != nil {
 		writeError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
 
-	// Evict active session so it gets recreated with new model
-	s.sessions.Remove(sess.ID)
+	// Evict active session so it gets recreated with new settings
+	if evict {
+		s.sessions.Remove(sess.ID)
+	}
 
 	writeJSON(w, http.StatusOK, sess)
 }
 
 func (s *Server) handleDeleteSession(w http.ResponseWriter, r *http.Request) {
 	id := chi.URLParam(r, "id")
+	if sess, err := s.store.GetSession(r.Context(), id); err == nil {
+		id = sess.ID
+	}
 
 	// Remove from active sessions first
 	s.sessions.Remove(id)
@@ -197,29 +306,361 @@ func (s *Server) handleDeleteSession(w http.ResponseWriter, r *http.Request) {
 		}
 		return
 	}
+	if err := s.artifacts.RemoveSession(id); err != nil {
+		logging.For("server").ErrorContext(r.Context(), "deleting artifacts", "session", id, "error", err)
+	}
 
 	w.WriteHeader(http.StatusNoContent)
 }
 
+// handleSwitchModel switches a session to another provider or model, like
+// the /model command. The body's model is provider/model, a provider name
+// for its default model, or a model of the session's provider; provider,
+// if given, names the provider outright. A session with an agent in memory
+// keeps it and its history, switching between messages: after the turn in
+// progress and any messages already waiting.
+func (s *Server) handleSwitchModel(w http.ResponseWriter, r *http.Request) {
+	sess, err := s.store.GetSession(r.Context(), chi.URLParam(r, "id"))
+	if err != nil {
+		writeError(w, http.StatusNotFound, "session not found")
+		return
+	}
+
+	var req struct {
+		Provider string `json:"provider"`
+		Model    string `json:"model"`
+	}
+	if err := decodeJSON(r, &req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid JSON: "+err.Error())
+		return
+	}
+	cfg := s.config()
+	target := req.Model
+	if req.Provider != "" {
+		if _, ok := cfg.Providers[req.Provider]; !ok {
+			writeError(w, http.StatusBadRequest, "unknown provider: "+req.Provider)
+			return
+		}
+		target = req.Provider
+		if req.Model != "" {
+			target += "/" + req.Model
+		}
+	}
+	if target == "" {
+		writeError(w, http.StatusBadRequest, "model is required")
+		return
+	}
+	providerName, model, err := cfg.ResolveModel(sess.Provider, target)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if as, ok := s.sessions.Get(sess.ID); ok {
+		t, ok := enqueueTurn(w, r, as)
+		if !ok {
+			return
+		}
+		if err := t.wait(r.Context(), nil); err != nil {
+			writeError(w, http.StatusConflict, err.Error())
+			return
+		}
+		defer t.end()
+
+		// The turns before this one may have retitled the session.
+		if sess, err = s.store.GetSession(r.Context(), sess.ID); err != nil {
+			writeError(w, http.StatusNotFound, "session not found")
+			return
+		}
+		provider, _ := cfg.Provider(providerName)
+		as.Agent.SetClient(llm.NewClient(provider.BaseURL, provider.APIKey, model))
+		var utility llm.Client
+		if name := provider.Models["utility"]; name != "" {
+			utility = llm.NewClient(provider.BaseURL, provider.APIKey, name)
+		}
+		as.Agent.SetUtilityLLM(utility)
+	}
+
+	sess.Provider, sess.Model = providerName, model
+	if err := s.store.UpdateSession(r.Context(), sess); err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, sess)
+}
+
+// checkProfile reports an error if name isn't an agent profile in the
+// profiles directory. An empty name means no profile.
+func (s *Server) checkProfile(name string) error {
+	if name == "" {
+		return nil
+	}
+	if !validProfileName(name) {
+		return fmt.Errorf("invalid profile name %q", name)
+	}
+	if _, err := agent.LoadProfile(s.profilePath(name)); err != nil {
+		return fmt.Errorf("unknown profile %q: %v", name, err)
+	}
+	return nil
+}
+
+func (s *Server) handleListArtifacts(w http.ResponseWriter, r *http.Request) {
+	sess, err := s.store.GetSession(r.Context(), chi.URLParam(r, "id"))
+	if err != nil {
+		writeError(w, http.StatusNotFound, "session not found")
+		return
+	}
+	artifacts, err := s.store.ListArtifacts(r.Context(), sess.ID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if artifacts == nil {
+		artifacts = []storage.Artifact{}
+	}
+	writeJSON(w, http.StatusOK, artifacts)
+}
+
+// handleGetArtifact downloads an artifact's contents.
+func (s *Server) handleGetArtifact(w http.ResponseWriter, r *http.Request) {
+	sess, err := s.store.GetSession(r.Context(), chi.URLParam(r, "id"))
+	if err != nil {
+		writeError(w, http.StatusNotFound, "session not found")
+		return
+	}
+	art, path, err := s.artifacts.Open(r.Context(), sess.ID, chi.URLParam(r, "name"))
+	if err != nil {
+		writeError(w, http.StatusNotFound, "artifact not found")
+		return
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	defer f.Close()
+
+	contentType := art.MIMEType
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": art.Name}))
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	http.ServeContent(w, r, art.Name, art.CreatedAt, f)
+}
+
+// exportTypes maps the formats sessions export to to their media types.
+var exportTypes = map[string]string{
+	"md":   "text/markdown; charset=utf-8",
+	"json": "application/json",
+	"html": "text/html; charset=utf-8",
+}
+
+// exportFormat returns the format a request for an export asks for: its
+// ?format=, or else the first media type in its Accept header that
+// sessions export to, or else JSON.
+func exportFormat(r *http.Request) (string, bool) {
+	if format := r.URL.Query().Get("format"); format != "" {
+		if format == "markdown" {
+			format = "md"
+		}
+		_, ok := exportTypes[format]
+		return format, ok
+	}
+	for _, accept := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType, _, _ := mime.ParseMediaType(strings.TrimSpace(accept))
+		switch mediaType {
+		case "text/markdown":
+			return "md", true
+		case "text/html":
+			return "html", true
+		case "application/json":
+			return "json", true
+		}
+	}
+	return "json", true
+}
+
+// handleExportSession downloads a session's transcript as Markdown, JSON
+// (which POST /sessions/import takes back), or a standalone HTML page.
+func (s *Server) handleExportSession(w http.ResponseWriter, r *http.Request) {
+	format, ok := exportFormat(r)
+	if !ok {
+		writeError(w, http.StatusBadRequest, "format must be md, json, or html")
+		return
+	}
+	sess, err := s.store.GetSession(r.Context(), chi.URLParam(r, "id"))
+	if err != nil {
+		writeError(w, http.StatusNotFound, "session not found")
+		return
+	}
+	messages, err := s.store.LoadMessages(r.Context(), sess.ID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	var data []byte
+	switch format {
+	case "md":
+		data = []byte(storage.ExportMarkdown(sess, messages))
+	case "html":
+		data = []byte(storage.ExportHTML(sess, messages))
+	default:
+		if data, err = storage.ExportJSON(sess, messages); err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+	}
+	filename := exportFilename(sess) + "." + format
+	w.Header().Set("Content-Type", exportTypes[format])
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": filename}))
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Add("Vary", "Accept")
+	w.Write(data)
+}
+
+// exportFilename names an export of sess after its title, or its ID if the
+// title has nothing usable in a file name.
+func exportFilename(sess *storage.Session) string {
+	var b strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(sess.Title) {
+		switch {
+		case r >= 'a' && r <= 'z' || r >= '0' && r <= '9':
+			if dash && b.Len() > 0 {
+				b.WriteByte('-')
+			}
+			b.WriteRune(r)
+			dash = false
+		default:
+			dash = true
+		}
+		if b.Len() >= 60 {
+			break
+		}
+	}
+	if b.Len() == 0 {
+		return "session-" + sess.ID
+	}
+	return b.String()
+}
+
+// handleImportSession recreates a session from the JSON that
+// `forge sessions export --format json` writes, under a new ID.
+func (s *Server) handleImportSession(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+	data, err := io.ReadAll(r.Body)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	export, err := storage.ParseExport(data)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	sess := export.Session
+	sess.ID = uuid.New().String()
+	if err := s.store.ImportSession(r.Context(), sess, export.Messages); err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusCreated, sess)
+}
+
+// handleSearch finds sessions whose title or messages contain every word of
+// ?q=, with matching passages highlighted in **bold**.
+func (s *Server) handleSearch(w http.ResponseWriter, r *http.Request) {
+	q := strings.TrimSpace(r.URL.Query().Get("q"))
+	if q == "" {
+		writeError(w, http.StatusBadRequest, "q is required")
+		return
+	}
+	limit := 0
+	if v := r.URL.Query().Get("limit"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil {
+			limit = n
+		}
+	}
+
+	results, err := s.store.SearchSessions(r.Context(), q, storage.SessionListOptions{All: true, Limit: limit})
+	if err != nil {
+		if strings.Contains(err.Error(), "no words") {
+			writeError(w, http.StatusBadRequest, err.Error())
+		} else {
+			writeError(w, http.StatusInternalServerError, err.Error())
+		}
+		return
+	}
+
+	if results == nil {
+		results = []storage.SearchResult{}
+	}
+	writeJSON(w, http.StatusOK, results)
+}
+
 // --- Message handlers ---
 
+// handleGetMessages returns a session's messages, oldest first: all of
+// them, or with limit, a page. Like handleListSessions, it sets
+// X-Total-Count and, when there are more, X-Next-Cursor.
 func (s *Server) handleGetMessages(w http.ResponseWriter, r *http.Request) {
 	id := chi.URLParam(r, "id")
 
-	messages, err := s.store.LoadMessages(r.Context(), id)
+	var opts storage.MessageListOptions
+	if limit := r.URL.Query().Get("limit"); limit != "" {
+		if n, err := strconv.Atoi(limit); err == nil && n > 0 {
+			opts.Limit = n
+		}
+	}
+	if opts.Cursor = r.URL.Query().Get("cursor"); opts.Cursor != "" {
+		if _, err := storage.ParseMessageCursor(opts.Cursor); err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+
+	page, err := s.store.ListMessages(r.Context(), id, opts)
 	if err != nil {
 		writeError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
 
-	if messages == nil {
-		messages = []llm.Message{}
+	w.Header().Set("X-Total-Count", strconv.Itoa(page.Total))
+	if page.Next != "" {
+		w.Header().Set("X-Next-Cursor", page.Next)
 	}
-	writeJSON(w, http.StatusOK, messages)
+	writeJSON(w, http.StatusOK, page.Messages)
+}
+
+// handleListToolCalls returns the tool calls made in a session, in order,
+// with their arguments and results. Each call's call_id matches the
+// tool_calls entry of the message that asked for it and the tool_call_id of
+// its result message.
+func (s *Server) handleListToolCalls(w http.ResponseWriter, r *http.Request) {
+	sess, err := s.store.GetSession(r.Context(), chi.URLParam(r, "id"))
+	if err != nil {
+		writeError(w, http.StatusNotFound, "session not found")
+		return
+	}
+	calls, err := s.store.ListSessionToolCalls(r.Context(), sess.ID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if calls == nil {
+		calls = []tools.CallRecord{}
+	}
+	writeJSON(w, http.StatusOK, calls)
 }
 
 type sendMessageRequest struct {
-	Content string `json:"content"`
+	Content     string   `json:"content"`
+	Attachments []string `json:"attachments"` // names of the session's artifacts to include
 }
 
 func (s *Server) handleSendMessage(w http.ResponseWriter, r *http.Request) {
@@ -243,68 +684,213 @@ func (s *Server) handleSendMessage(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	as, err := s.sessions.GetOrCreate(r.Context(), sess, s.cfg, s.store, s.registry)
+	prompt, err := s.withAttachments(r.Context(), sess.ID, req.Content, req.Attachments)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	as, err := s.sessions.GetOrCreate(r.Context(), sess, s.config(), s.store, s.registry)
 	if err != nil {
 		writeError(w, http.StatusInternalServerError, fmt.Sprintf("initializing agent: %v", err))
 		return
 	}
 
-	// Lock to ensure one message at a time
-	as.mu.Lock()
-	defer as.mu.Unlock()
+	// Wait for the messages ahead of this one
+	t, ok := enqueueTurn(w, r, as)
+	if !ok {
+		return
+	}
+	if err := t.wait(r.Context(), nil); err != nil {
+		writeError(w, http.StatusConflict, err.Error())
+		return
+	}
+	defer t.end()
 
 	// Auto-generate title from first message
-	if sess.Title == "" {
+	firstMessage := sess.Title == ""
+	if firstMessage {
 		sess.Title = generateTitle(req.Content)
 		s.store.UpdateSession(r.Context(), sess)
 	}
 
 	// Run agent (non-streaming)
-	ctx, cancel := context.WithCancel(r.Context())
-	as.Cancel = cancel
-	defer func() { as.Cancel = nil }()
-
-	response, err := as.Agent.Run(ctx, req.Content)
-	cancel()
+	ctx, cancel := context.WithCancel(tools.WithSession(r.Context(), sess.ID))
+	t.start(cancel)
+	response, err := as.Agent.Run(ctx, prompt)
+	s.turnFinished(sess, response, err, ctx.Err() != nil)
+	s.chargeTurn(ctx, as.Agent.History())
 
 	// Save messages
-	if saveErr := s.store.SaveMessages(r.Context(), sess.ID, as.Agent.History()); saveErr != nil {
+	if saveErr := storage.SaveHistory(r.Context(), s.store, sess.ID, as.Agent); saveErr != nil {
 		writeError(w, http.StatusInternalServerError, fmt.Sprintf("saving messages: %v", saveErr))
 		return
 	}
 
 	if err != nil {
+		if ctx.Err() != nil && r.Context().Err() == nil {
+			writeError(w, http.StatusConflict, "interrupted")
+			return
+		}
 		writeError(w, http.StatusInternalServerError, fmt.Sprintf("agent error: %v", err))
 		return
 	}
+	if firstMessage {
+		s.retitle(as.Agent, sess.ID, sess.Title)
+	}
 
 	writeJSON(w, http.StatusOK, map[string]string{"content": response})
 }
 
+// enqueueTurn puts a message in line for the session's agent. It answers
+// 409 with the position the message would have had if the session is busy
+// and the request has ?wait=false, or if the queue is full.
+func enqueueTurn(w http.ResponseWriter, r *http.Request, as *ActiveSession) (*turn, bool) {
+	t, position, err := as.enqueue(r.URL.Query().Get("wait") != "false")
+	if errors.Is(err, errDraining) {
+		writeError(w, http.StatusServiceUnavailable, err.Error())
+		return nil, false
+	}
+	if err != nil {
+		writeJSON(w, http.StatusConflict, map[string]any{"error": err.Error(), "position": position})
+		return nil, false
+	}
+	return t, true
+}
+
+// handleCancelSession interrupts the turn a session's agent is running,
+// like Ctrl+C in the CLI, and returns the messages the turn had added.
+// Messages waiting behind it still run.
+func (s *Server) handleCancelSession(w http.ResponseWriter, r *http.Request) {
+	sess, err := s.store.GetSession(r.Context(), chi.URLParam(r, "id"))
+	if err != nil {
+		writeError(w, http.StatusNotFound, "session not found")
+		return
+	}
+	var t *turn
+	if as, ok := s.sessions.Get(sess.ID); ok {
+		t = as.cancelTurn()
+	}
+	if t == nil {
+		writeError(w, http.StatusConflict, "session has no turn in progress")
+		return
+	}
+
+	// The turn saves its messages before it ends.
+	<-t.ended
+	writeJSON(w, http.StatusOK, map[string]any{"cancelled": true, "messages": t.messages})
+}
+
+// lastTurn returns a copy of the messages from the last user message on.
+func lastTurn(history []llm.Message) []llm.Message {
+	i := len(history) - 1
+	for i > 0 && history[i].Role != llm.RoleUser {
+		i--
+	}
+	return slices.Clone(history[i:])
+}
+
 // --- Provider/Model handlers ---
 
 type providerInfo struct {
-	Name     string            `json:"name"`
-	Models   map[string]string `json:"models"`
-	IsOllama bool              `json:"is_ollama"`
+	Name           string            `json:"name"`
+	Models         map[string]string `json:"models"`
+	IsOllama       bool              `json:"is_ollama"`
+	Available      bool              `json:"available"`
+	Error          string            `json:"error,omitempty"`           // why it isn't available
+	ContextLengths map[string]int    `json:"context_lengths,omitempty"` // tokens per model, where known
+}
+
+const (
+	providerCheckTimeout = 3 * time.Second  // bounds each provider's check
+	providerCheckTTL     = 30 * time.Second // how long a check's result is reused
+)
+
+// providerCheck is what checking a provider found.
+type providerCheck struct {
+	at             time.Time
+	err            error
+	contextLengths map[string]int
 }
 
+// checkProvider checks that a provider answers and takes its API key, and
+// looks up its models' context lengths: from Ollama when it's up, or for
+// well-known hosted models.
+func checkProvider(ctx context.Context, p config.ProviderConfig) providerCheck {
+	ctx, cancel := context.WithTimeout(ctx, providerCheckTimeout)
+	defer cancel()
+
+	client := llm.NewClient(p.BaseURL, p.APIKey, "")
+	check := providerCheck{at: time.Now(), err: client.Ping(ctx), contextLengths: map[string]int{}}
+	for _, model := range p.Models {
+		n := llm.KnownContextLength(model)
+		if p.IsOllama() && check.err == nil {
+			if length, err := client.ContextLength(ctx, model); err == nil {
+				n = length
+			}
+		}
+		if n > 0 {
+			check.contextLengths[model] = n
+		}
+	}
+	return check
+}
+
+// checkProviders checks every provider at once, reusing recent results
+// unless refresh is set.
+func (s *Server) checkProviders(ctx context.Context, refresh bool) map[string]providerCheck {
+	s.providerMu.Lock()
+	defer s.providerMu.Unlock()
+	if s.providerChecks == nil {
+		s.providerChecks = make(map[string]providerCheck)
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for name, p := range s.config().Providers {
+		if check, ok := s.providerChecks[name]; ok && !refresh && time.Since(check.at) < providerCheckTTL {
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			check := checkProvider(ctx, p)
+			mu.Lock()
+			s.providerChecks[name] = check
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+	return maps.Clone(s.providerChecks)
+}
+
+// handleListProviders lists the configured providers, sorted by name, with
+// whether each is reachable with its API key and the context lengths of
+// its models. Results are reused for 30 seconds unless ?refresh=true.
 func (s *Server) handleListProviders(w http.ResponseWriter, r *http.Request) {
-	var providers []providerInfo
-	for name, p := range s.cfg.Providers {
-		providers = append(providers, providerInfo{
-			Name:     name,
-			Models:   p.Models,
-			IsOllama: p.IsOllama(),
-		})
+	checks := s.checkProviders(r.Context(), r.URL.Query().Get("refresh") == "true")
+	providers := []providerInfo{}
+	for name, p := range s.config().Providers {
+		info := providerInfo{
+			Name:           name,
+			Models:         p.Models,
+			IsOllama:       p.IsOllama(),
+			Available:      checks[name].err == nil,
+			ContextLengths: checks[name].contextLengths,
+		}
+		if err := checks[name].err; err != nil {
+			info.Error = err.Error()
+		}
+		providers = append(providers, info)
 	}
+	slices.SortFunc(providers, func(a, b providerInfo) int { return strings.Compare(a.Name, b.Name) })
 	writeJSON(w, http.StatusOK, providers)
 }
 
 func (s *Server) handleListModels(w http.ResponseWriter, r *http.Request) {
 	providerName := chi.URLParam(r, "provider")
 
-	provider, err := s.cfg.Provider(providerName)
+	provider, err := s.config().Provider(providerName)
 	if err != nil {
 		writeError(w, http.StatusNotFound, err.Error())
 		return
@@ -332,6 +918,247 @@ func (s *Server) handleListModels(w http.ResponseWriter, r *http.Request) {
 	writeJSON(w, http.StatusOK, models)
 }
 
+// --- Tool server handlers ---
+
+type registerToolServerRequest struct {
+	Name   string            `json:"name"`
+	Binary string            `json:"binary"`
+	URL    string            `json:"url"`
+	Env    map[string]string `json:"env"`
+}
+
+type toolServerInfo struct {
+	Name  string   `json:"name"`
+	Tools []string `json:"tools"`
+}
+
+// handleRegisterToolServer launches a new MCP server, adds it to the running
+// registry, and persists it to the config file. Sessions see the new tools
+// on their next message. It runs whatever binary the caller names, so it
+// is an admin route. A server that can't be saved is stopped again.
+func (s *Server) handleRegisterToolServer(w http.ResponseWriter, r *http.Request) {
+	var req registerToolServerRequest
+	if err := decodeJSON(r, &req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid JSON: "+err.Error())
+		return
+	}
+
+	if req.Name == "" || (req.Binary == "" && req.URL == "") {
+		writeError(w, http.StatusBadRequest, "name and binary or url are required")
+		return
+	}
+
+	if s.registry.HasServer(req.Name) {
+		writeError(w, http.StatusConflict, fmt.Sprintf("tool server %s already registered", req.Name))
+		return
+	}
+
+	toolCfg := tools.ToolServerConfig{
+		Binary:  req.Binary,
+		URL:     req.URL,
+		Env:     req.Env,
+		Enabled: true,
+	}
+	if err := s.registry.Register(req.Name, toolCfg); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err := s.saveToolServer(req.Name, toolCfg); err != nil {
+		s.registry.Remove(req.Name)
+		writeError(w, http.StatusInternalServerError, fmt.Sprintf("saving tool server: %v", err))
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, toolServerInfo{
+		Name:  req.Name,
+		Tools: s.registry.ServerTools(req.Name),
+	})
+}
+
+// saveToolServer adds a tool server to the config file and publishes a
+// config with it, leaving the running config alone if the file can't be
+// written.
+func (s *Server) saveToolServer(name string, tc tools.ToolServerConfig) error {
+	s.reloadMu.Lock()
+	defer s.reloadMu.Unlock()
+	cfg := *s.config()
+	cfg.Tools = maps.Clone(cfg.Tools)
+	if err := cfg.SaveToolServer(name, tc); err != nil {
+		return err
+	}
+	s.cfg.Store(&cfg)
+	return nil
+}
+
+// --- Tool handlers ---
+
+// builtinServerName names the built-in tool pack in tool listings.
+const builtinServerName = "builtin"
+
+// toolGroup is one tool server's tools, with their schemas.
+type toolGroup struct {
+	Server string        `json:"server"`
+	Tools  []llm.ToolDef `json:"tools"`
+}
+
+// handleListTools lists the tools agents can call, grouped by server: the
+// registered servers' tools, or the built-in pack if none have any, as
+// agents do.
+func (s *Server) handleListTools(w http.ResponseWriter, r *http.Request) {
+	groups := []toolGroup{}
+	if !s.registry.HasTools() {
+		groups = append(groups, toolGroup{Server: builtinServerName, Tools: tools.NewBuiltinServer().ToolDefs()})
+	}
+	for _, name := range s.registry.ServerNames() {
+		groups = append(groups, toolGroup{Server: name, Tools: s.registry.ServerToolDefs(name)})
+	}
+	for i, g := range groups {
+		groups[i].Tools = slices.Clone(g.Tools)
+		slices.SortFunc(groups[i].Tools, func(a, b llm.ToolDef) int { return strings.Compare(a.Name, b.Name) })
+	}
+	writeJSON(w, http.StatusOK, groups)
+}
+
+type callToolRequest struct {
+	Args      map[string]any `json:"args"`
+	SessionID string         `json:"session_id"` // optional; the session to call the tool for
+}
+
+// handleCallTool runs a tool directly and returns its result. The call goes
+// through the same middleware and tool server policies as an agent's, so a
+// shell command that needs approval is refused. With a session_id, the call
+// is made for that session: tools see it as the session's, it appears in
+// the session's tool calls, and it goes to the session's own tool servers
+// when tools are isolated.
+func (s *Server) handleCallTool(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "name")
+	var req callToolRequest
+	if err := decodeJSON(r, &req); err != nil && !errors.Is(err, io.EOF) {
+		writeError(w, http.StatusBadRequest, "invalid JSON: "+err.Error())
+		return
+	}
+
+	ctx := r.Context()
+	registry := s.registry
+	if req.SessionID != "" {
+		sess, err := s.store.GetSession(ctx, req.SessionID)
+		if err != nil {
+			writeError(w, http.StatusNotFound, "session not found")
+			return
+		}
+		ctx = tools.WithSession(ctx, sess.ID)
+		if as, ok := s.sessions.Get(sess.ID); ok && as.Registry != nil {
+			registry = as.Registry
+		}
+	}
+
+	call, defs := registry.CallTool, registry.AllTools()
+	if !registry.HasTools() {
+		builtins := tools.NewBuiltinServer()
+		call, defs = builtins.CallTool, builtins.ToolDefs()
+	}
+	if !slices.ContainsFunc(defs, func(d llm.ToolDef) bool { return d.Name == name }) {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("unknown tool %q", name))
+		return
+	}
+
+	result, err := call(tools.WithCallID(ctx, "api_"+uuid.New().String()), name, req.Args)
+	if err != nil {
+		writeError(w, http.StatusBadGateway, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"result": result,
+		"failed": strings.HasPrefix(result, "error: "),
+	})
+}
+
+// --- Stats handlers ---
+
+// handleUsageStats reports sessions, messages, tool calls, tokens, and
+// estimated cost by day, model, and session. Optional ?from= and ?to=
+// (YYYY-MM-DD, UTC) limit the days included.
+func (s *Server) handleUsageStats(w http.ResponseWriter, r *http.Request) {
+	var from, to time.Time
+	for _, p := range []struct {
+		name string
+		t    *time.Time
+	}{{"from", &from}, {"to", &to}} {
+		v := r.URL.Query().Get(p.name)
+		if v == "" {
+			continue
+		}
+		t, err := time.Parse(time.DateOnly, v)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "invalid "+p.name+" date (want YYYY-MM-DD): "+v)
+			return
+		}
+		*p.t = t
+	}
+
+	records, err := s.store.ListUsage(r.Context(), from, to)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, storage.SummarizeUsage(records, s.config().EstimateCost))
+}
+
+// handleToolStats reports per-tool call counts, error rates, and latency
+// percentiles from the persisted usage log. An optional ?since=<duration>
+// (e.g. 24h) limits the window.
+func (s *Server) handleToolStats(w http.ResponseWriter, r *http.Request) {
+	var since time.Time
+	if v := r.URL.Query().Get("since"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			writeError(w, http.StatusBadRequest, "invalid since duration: "+v)
+			return
+		}
+		since = time.Now().Add(-d)
+	}
+
+	calls, err := s.store.ListToolCalls(r.Context(), since)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, tools.SummarizeCalls(calls))
+}
+
+// titleTimeout bounds how long the utility model may take to write a title.
+const titleTimeout = 30 * time.Second
+
+// retitle replaces a session's stopgap title, cut from its first message,
+// with one the utility model writes after the first exchange. It runs in
+// the background; the stopgap stays if there's no utility model, the call
+// fails, or the title is changed in the meantime.
+func (s *Server) retitle(a *agent.Agent, sessionID, stopgap string) {
+	client := a.UtilityLLM()
+	if client == nil {
+		return
+	}
+	messages := slices.Clone(a.History())
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), titleTimeout)
+		defer cancel()
+		title, err := agent.GenerateTitle(ctx, client, messages)
+		if err != nil {
+			logging.For("agent").Warn("generating title", "session", sessionID, "error", err)
+			return
+		}
+		sess, err := s.store.GetSession(ctx, sessionID)
+		if err != nil || sess.Title != stopgap {
+			return
+		}
+		sess.Title = title
+		if err := s.store.UpdateSession(ctx, sess); err != nil {
+			logging.For("server").Error("saving title", "session", sessionID, "error", err)
+		}
+	}()
+}
+
 // generateTitle creates a session title from the first user message.
 func generateTitle(firstMessage string) string {
 	t := strings.TrimSpace(firstMessage)
@@ -4,11 +4,19 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"fmt"
+	"log/slog"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/michaelbrown/forge/internal/config"
+	"github.com/michaelbrown/forge/internal/llm"
+	"github.com/michaelbrown/forge/internal/logging"
 	"github.com/michaelbrown/forge/internal/storage"
 	"github.com/michaelbrown/forge/internal/storage/sqlite"
 	"github.com/michaelbrown/forge/internal/tools"
@@ -87,6 +95,62 @@ func TestListProviders_ReturnsAllConfigured(t *testing.T) {
 	}
 }
 
+func TestListProviders_Availability(t *testing.T) {
+	srv := newTestServer(t)
+
+	ollama := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/ollama/v1/models":
+			w.Write([]byte(`{"object":"list","data":[{"id":"qwen3:14b","object":"model"}]}`))
+		case "/ollama/api/show":
+			w.Write([]byte(`{"model_info":{"qwen3.context_length":40960}}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer ollama.Close()
+	down := httptest.NewServer(http.NotFoundHandler())
+	down.Close()
+
+	srv.config().Providers = map[string]config.ProviderConfig{
+		"ollama": {BaseURL: ollama.URL + "/ollama/v1/", APIKey: "ollama", Models: map[string]string{"default": "qwen3:14b"}},
+		"claude": {BaseURL: down.URL + "/v1/", APIKey: "test-key", Models: map[string]string{"default": "claude-sonnet-4-5-20250929"}},
+	}
+
+	w := httptest.NewRecorder()
+	srv.router.ServeHTTP(w, httptest.NewRequest("GET", "/api/providers", nil))
+	var providers []providerInfo
+	if err := json.Unmarshal(w.Body.Bytes(), &providers); err != nil {
+		t.Fatal(err)
+	}
+	if len(providers) != 2 || providers[0].Name != "claude" || providers[1].Name != "ollama" {
+		t.Fatalf("providers = %+v", providers)
+	}
+	claude, ollamaInfo := providers[0], providers[1]
+	if claude.Available || claude.Error == "" || claude.ContextLengths["claude-sonnet-4-5-20250929"] != 200_000 {
+		t.Errorf("unreachable provider = %+v", claude)
+	}
+	if !ollamaInfo.Available || ollamaInfo.ContextLengths["qwen3:14b"] != 40960 {
+		t.Errorf("ollama = %+v", ollamaInfo)
+	}
+
+	// Results are reused until they're stale or a refresh is asked for.
+	ollama.Close()
+	w = httptest.NewRecorder()
+	srv.router.ServeHTTP(w, httptest.NewRequest("GET", "/api/providers", nil))
+	json.Unmarshal(w.Body.Bytes(), &providers)
+	if !providers[1].Available {
+		t.Error("cached check was redone")
+	}
+	w = httptest.NewRecorder()
+	srv.router.ServeHTTP(w, httptest.NewRequest("GET", "/api/providers?refresh=true", nil))
+	json.Unmarshal(w.Body.Bytes(), &providers)
+	if providers[1].Available {
+		t.Error("refresh kept the old check")
+	}
+}
+
 func TestCreateSession_DefaultProvider(t *testing.T) {
 	srv := newTestServer(t)
 
@@ -244,7 +308,7 @@ func TestUpdateSession_EvictsActiveSession(t *testing.T) {
 	// Simulate an active session in the manager
 	registry := tools.NewRegistry()
 	defer registry.Close()
-	srv.sessions.GetOrCreate(context.Background(), sess, srv.cfg, srv.store, registry)
+	srv.sessions.GetOrCreate(context.Background(), sess, srv.config(), srv.store, registry)
 
 	// Verify it's active
 	if _, ok := srv.sessions.Get("evict-test"); !ok {
@@ -268,6 +332,68 @@ func TestUpdateSession_EvictsActiveSession(t *testing.T) {
 	}
 }
 
+func TestUpdateSession_Metadata(t *testing.T) {
+	srv := newTestServer(t)
+	srv.config().Agent.ProfilesDir = t.TempDir()
+	os.WriteFile(filepath.Join(srv.config().Agent.ProfilesDir, "coder.yaml"), []byte("name: coder\nsystem_prompt: Write code.\n"), 0o644)
+
+	sess := &storage.Session{ID: "meta-test", Title: "Auto title", Status: storage.StatusActive, Provider: "ollama", Model: "qwen3:14b"}
+	srv.store.CreateSession(context.Background(), sess)
+	registry := tools.NewRegistry()
+	defer registry.Close()
+	srv.sessions.GetOrCreate(context.Background(), sess, srv.config(), srv.store, registry)
+
+	patch := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("PATCH", "/api/sessions/meta-test", bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		srv.router.ServeHTTP(w, req)
+		return w
+	}
+
+	// Renaming leaves a conversation in progress alone.
+	if w := patch(`{"title": "  Release checklist "}`); w.Code != http.StatusOK {
+		t.Fatalf("rename: %d %s", w.Code, w.Body.String())
+	}
+	if _, ok := srv.sessions.Get("meta-test"); !ok {
+		t.Error("rename evicted the active session")
+	}
+
+	w := patch(`{"profile": "coder", "status": "completed", "tags": ["release"]}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("update: %d %s", w.Code, w.Body.String())
+	}
+	got, _ := srv.store.GetSession(context.Background(), "meta-test")
+	if got.Title != "Release checklist" || got.Profile != "coder" || got.Status != storage.StatusCompleted || strings.Join(got.Tags, ",") != "release" {
+		t.Errorf("stored session = %+v", got)
+	}
+	if _, ok := srv.sessions.Get("meta-test"); ok {
+		t.Error("expected active session to be evicted after profile change")
+	}
+
+	for _, body := range []string{
+		`{"title": " "}`,
+		`{"profile": "missing"}`,
+		`{"profile": "../coder"}`,
+		`{"status": "running"}`,
+		`{"status": "done"}`,
+	} {
+		if w := patch(body); w.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d", body, w.Code)
+		}
+	}
+
+	if w := patch(`{"profile": ""}`); w.Code != http.StatusOK {
+		t.Errorf("clearing profile: %d %s", w.Code, w.Body.String())
+	}
+
+	got.Status = storage.StatusRunning
+	srv.store.UpdateSession(context.Background(), got)
+	if w := patch(`{"status": "archived"}`); w.Code != http.StatusConflict {
+		t.Errorf("archiving a running session: expected 409, got %d", w.Code)
+	}
+}
+
 func TestUpdateSession_BareProviderName(t *testing.T) {
 	srv := newTestServer(t)
 
@@ -446,3 +572,835 @@ func TestSessionCRUD_FullLifecycle(t *testing.T) {
 		t.Fatalf("get after delete: expected 404, got %d", w.Code)
 	}
 }
+
+func TestRegisterToolServer_MissingFields(t *testing.T) {
+	srv := newTestServer(t)
+
+	body := `{"name": "custom"}`
+	req := httptest.NewRequest("POST", "/api/tools/servers", bytes.NewBufferString(body))
+	w := httptest.NewRecorder()
+	srv.router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestRegisterToolServer_BadBinary(t *testing.T) {
+	srv := newTestServer(t)
+
+	body := `{"name": "custom", "binary": "/nonexistent/binary"}`
+	req := httptest.NewRequest("POST", "/api/tools/servers", bytes.NewBufferString(body))
+	w := httptest.NewRecorder()
+	srv.router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d: %s", w.Code, w.Body.String())
+	}
+	if srv.registry.HasServer("custom") {
+		t.Error("failed server should not be registered")
+	}
+	if _, ok := srv.config().Tools["custom"]; ok {
+		t.Error("failed server should not be saved to config")
+	}
+}
+
+func TestRegisterToolServer_NeedsAdmin(t *testing.T) {
+	srv := newTestServer(t)
+	srv.config().Server.Auth.APIKeys = []config.APIKey{{Name: "ci", Key: "full-key", Scope: config.ScopeFull}}
+
+	body := `{"name": "custom", "binary": "/bin/sh"}`
+	req := httptest.NewRequest("POST", "/api/tools/servers", bytes.NewBufferString(body))
+	req.Header.Set("X-API-Key", "full-key")
+	w := httptest.NewRecorder()
+	srv.router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("expected 403, got %d: %s", w.Code, w.Body.String())
+	}
+	if srv.registry.HasServer("custom") {
+		t.Error("server should not be registered without admin credentials")
+	}
+}
+
+func TestRegisterToolServer_NotSaved(t *testing.T) {
+	binary, err := filepath.Abs("../../bin/forge-tool-time-ops")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(binary); err != nil {
+		t.Skipf("binary not found at %s (run make build-tools first)", binary)
+	}
+	srv := newTestServer(t)
+	srv.config().SetPath(filepath.Join(t.TempDir(), "missing", "forge.yaml"))
+
+	body := fmt.Sprintf(`{"name": "custom", "binary": %q}`, binary)
+	req := httptest.NewRequest("POST", "/api/tools/servers", bytes.NewBufferString(body))
+	w := httptest.NewRecorder()
+	srv.router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusInternalServerError {
+		t.Fatalf("expected 500, got %d: %s", w.Code, w.Body.String())
+	}
+	if srv.registry.HasServer("custom") {
+		t.Error("a server that couldn't be saved should be stopped")
+	}
+}
+
+func TestToolStats(t *testing.T) {
+	srv := newTestServer(t)
+	ctx := context.Background()
+
+	now := time.Now()
+	for _, rec := range []tools.CallRecord{
+		{Tool: "file_read", Server: "file-ops", Duration: 2 * time.Millisecond, At: now},
+		{Tool: "file_read", Server: "file-ops", Duration: 4 * time.Millisecond, Failed: true, At: now},
+		{Tool: "shell_exec", Server: "shell-exec", Duration: 10 * time.Millisecond, At: now.Add(-72 * time.Hour)},
+	} {
+		if err := srv.store.RecordToolCall(ctx, rec); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	req := httptest.NewRequest("GET", "/api/stats/tools", nil)
+	w := httptest.NewRecorder()
+	srv.router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var stats []tools.ToolStats
+	json.NewDecoder(w.Body).Decode(&stats)
+	if len(stats) != 2 {
+		t.Fatalf("got %d tools, want 2", len(stats))
+	}
+	if stats[0].Tool != "file_read" || stats[0].Calls != 2 || stats[0].ErrorRate != 0.5 {
+		t.Errorf("hottest tool = %+v", stats[0])
+	}
+
+	req = httptest.NewRequest("GET", "/api/stats/tools?since=24h", nil)
+	w = httptest.NewRecorder()
+	srv.router.ServeHTTP(w, req)
+	json.NewDecoder(w.Body).Decode(&stats)
+	if len(stats) != 1 {
+		t.Errorf("since=24h: got %d tools, want 1", len(stats))
+	}
+
+	req = httptest.NewRequest("GET", "/api/stats/tools?since=yesterday", nil)
+	w = httptest.NewRecorder()
+	srv.router.ServeHTTP(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("invalid since: expected 400, got %d", w.Code)
+	}
+}
+
+func TestSearch(t *testing.T) {
+	srv := newTestServer(t)
+	ctx := context.Background()
+
+	srv.store.CreateSession(ctx, &storage.Session{ID: "s1", Title: "Build", Status: storage.StatusActive})
+	srv.store.AppendMessages(ctx, "s1", []llm.Message{llm.UserMessage("Why does the Makefile fail?")})
+
+	req := httptest.NewRequest("GET", "/api/search?q=makefile", nil)
+	w := httptest.NewRecorder()
+	srv.router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var results []storage.SearchResult
+	json.NewDecoder(w.Body).Decode(&results)
+	if len(results) != 1 || results[0].Session.ID != "s1" || results[0].Snippets[0] != "Why does the **Makefile** fail?" {
+		t.Errorf("results = %+v", results)
+	}
+
+	for query, want := range map[string]int{"q=nothing+here": http.StatusOK, "q=": http.StatusBadRequest, "q=%2B%2B": http.StatusBadRequest} {
+		req := httptest.NewRequest("GET", "/api/search?"+query, nil)
+		w := httptest.NewRecorder()
+		srv.router.ServeHTTP(w, req)
+		if w.Code != want {
+			t.Errorf("%s: expected %d, got %d: %s", query, want, w.Code, w.Body.String())
+		}
+		if want == http.StatusOK && w.Body.String() != "[]\n" {
+			t.Errorf("%s: body = %q, want []", query, w.Body.String())
+		}
+	}
+}
+
+func TestSessionTags(t *testing.T) {
+	srv := newTestServer(t)
+
+	body := `{"title": "invoices", "tags": ["Billing", "bug"]}`
+	req := httptest.NewRequest("POST", "/api/sessions", bytes.NewBufferString(body))
+	w := httptest.NewRecorder()
+	srv.router.ServeHTTP(w, req)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", w.Code, w.Body.String())
+	}
+	var sess storage.Session
+	json.NewDecoder(w.Body).Decode(&sess)
+	if strings.Join(sess.Tags, ",") != "billing,bug" {
+		t.Errorf("created tags = %q", sess.Tags)
+	}
+
+	req = httptest.NewRequest("PATCH", "/api/sessions/"+sess.ID, bytes.NewBufferString(`{"tags": ["billing", "docs"]}`))
+	w = httptest.NewRecorder()
+	srv.router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	list := func(query string) []storage.Session {
+		t.Helper()
+		req := httptest.NewRequest("GET", "/api/sessions"+query, nil)
+		w := httptest.NewRecorder()
+		srv.router.ServeHTTP(w, req)
+		var sessions []storage.Session
+		json.NewDecoder(w.Body).Decode(&sessions)
+		return sessions
+	}
+	if got := list("?tag=docs"); len(got) != 1 || strings.Join(got[0].Tags, ",") != "billing,docs" {
+		t.Errorf("tag=docs = %+v", got)
+	}
+	if got := list("?tag=bug"); len(got) != 0 {
+		t.Errorf("tag=bug = %+v", got)
+	}
+
+	// A PATCH without tags leaves them alone; an invalid tag is rejected.
+	req = httptest.NewRequest("PATCH", "/api/sessions/"+sess.ID, bytes.NewBufferString(`{"model": "qwen3:8b"}`))
+	srv.router.ServeHTTP(httptest.NewRecorder(), req)
+	if got := list("?tag=docs"); len(got) != 1 {
+		t.Errorf("tags lost on model change: %+v", got)
+	}
+	req = httptest.NewRequest("PATCH", "/api/sessions/"+sess.ID, bytes.NewBufferString(`{"tags": ["two words"]}`))
+	w = httptest.NewRecorder()
+	srv.router.ServeHTTP(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("invalid tag: expected 400, got %d", w.Code)
+	}
+}
+
+func TestExportSession(t *testing.T) {
+	srv := newTestServer(t)
+	ctx := context.Background()
+	sess := &storage.Session{ID: "exp1", Title: "Deploy notes: v2!", Status: storage.StatusActive}
+	srv.store.CreateSession(ctx, sess)
+	srv.store.SaveMessages(ctx, sess.ID, []llm.Message{llm.UserMessage("How do I deploy?"), llm.AssistantMessage("Run `make deploy`.")})
+
+	for _, tt := range []struct {
+		query, accept string
+		wantType      string
+		wantBody      string
+		wantFile      string
+	}{
+		{"?format=md", "", "text/markdown; charset=utf-8", "Run `make deploy`.", "deploy-notes-v2.md"},
+		{"?format=html", "application/json", "text/html; charset=utf-8", "<code>make deploy</code>", "deploy-notes-v2.html"},
+		{"", "text/html,application/xhtml+xml,*/*;q=0.8", "text/html; charset=utf-8", "<!DOCTYPE html>", "deploy-notes-v2.html"},
+		{"", "text/markdown", "text/markdown; charset=utf-8", "## You", "deploy-notes-v2.md"},
+		{"", "", "application/json", `"messages"`, "deploy-notes-v2.json"},
+	} {
+		req := httptest.NewRequest("GET", "/api/sessions/exp1/export"+tt.query, nil)
+		if tt.accept != "" {
+			req.Header.Set("Accept", tt.accept)
+		}
+		w := httptest.NewRecorder()
+		srv.router.ServeHTTP(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s %s: expected 200, got %d", tt.query, tt.accept, w.Code)
+		}
+		if got := w.Header().Get("Content-Type"); got != tt.wantType {
+			t.Errorf("%s %s: Content-Type = %q, want %q", tt.query, tt.accept, got, tt.wantType)
+		}
+		if got := w.Header().Get("Content-Disposition"); got != "attachment; filename="+tt.wantFile {
+			t.Errorf("%s %s: Content-Disposition = %q", tt.query, tt.accept, got)
+		}
+		if !strings.Contains(w.Body.String(), tt.wantBody) {
+			t.Errorf("%s %s: body lacks %q", tt.query, tt.accept, tt.wantBody)
+		}
+	}
+
+	for url, want := range map[string]int{
+		"/api/sessions/exp1/export?format=pdf": http.StatusBadRequest,
+		"/api/sessions/nope/export":            http.StatusNotFound,
+	} {
+		w := httptest.NewRecorder()
+		srv.router.ServeHTTP(w, httptest.NewRequest("GET", url, nil))
+		if w.Code != want {
+			t.Errorf("%s: expected %d, got %d", url, want, w.Code)
+		}
+	}
+}
+
+func TestImportSession(t *testing.T) {
+	srv := newTestServer(t)
+	ctx := context.Background()
+
+	orig := &storage.Session{ID: "orig", Title: "Deploy notes", Status: storage.StatusRunning, Provider: "claude", Model: "m", Tags: []string{"ops"}}
+	srv.store.CreateSession(ctx, orig)
+	messages := []llm.Message{llm.UserMessage("How do I deploy?"), {Role: llm.RoleAssistant, Content: "Run make deploy."}}
+	srv.store.SaveMessages(ctx, orig.ID, messages)
+	orig, _ = srv.store.GetSession(ctx, orig.ID)
+	data, err := storage.ExportJSON(orig, messages)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := httptest.NewRequest("POST", "/api/sessions/import", bytes.NewReader(data))
+	w := httptest.NewRecorder()
+	srv.router.ServeHTTP(w, req)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", w.Code, w.Body.String())
+	}
+	var sess storage.Session
+	json.NewDecoder(w.Body).Decode(&sess)
+	if sess.ID == orig.ID || sess.Title != orig.Title || sess.Provider != "claude" || strings.Join(sess.Tags, ",") != "ops" {
+		t.Errorf("imported session = %+v", sess)
+	}
+	if sess.Status != storage.StatusActive {
+		t.Errorf("status = %s, want active", sess.Status)
+	}
+	if !sess.CreatedAt.Equal(orig.CreatedAt) {
+		t.Errorf("created_at = %v, want %v", sess.CreatedAt, orig.CreatedAt)
+	}
+	got, _ := srv.store.LoadMessages(ctx, sess.ID)
+	if len(got) != 2 || got[1].Content != "Run make deploy." {
+		t.Errorf("imported messages = %+v", got)
+	}
+
+	for _, body := range []string{`not json`, `{"messages": []}`, `{"session": {}, "messages": [{"role": "robot"}]}`} {
+		req := httptest.NewRequest("POST", "/api/sessions/import", bytes.NewBufferString(body))
+		w := httptest.NewRecorder()
+		srv.router.ServeHTTP(w, req)
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d", body, w.Code)
+		}
+	}
+}
+
+func TestSessionArtifacts(t *testing.T) {
+	srv := newTestServer(t)
+	srv.artifacts = storage.NewArtifacts(srv.store, t.TempDir())
+	ctx := context.Background()
+
+	srv.store.CreateSession(ctx, &storage.Session{ID: "art-session", Status: storage.StatusActive})
+	if _, err := srv.artifacts.Save(ctx, "art-session", "index.html", "", "sandbox_exec", []byte("<p>hi</p>")); err != nil {
+		t.Fatal(err)
+	}
+
+	req := httptest.NewRequest("GET", "/api/sessions/art-session/artifacts", nil)
+	w := httptest.NewRecorder()
+	srv.router.ServeHTTP(w, req)
+	var list []storage.Artifact
+	json.NewDecoder(w.Body).Decode(&list)
+	if w.Code != http.StatusOK || len(list) != 1 || list[0].Name != "index.html" || list[0].Size != 9 {
+		t.Fatalf("list: %d %+v", w.Code, list)
+	}
+
+	req = httptest.NewRequest("GET", "/api/sessions/art-session/artifacts/index.html", nil)
+	w = httptest.NewRecorder()
+	srv.router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK || w.Body.String() != "<p>hi</p>" {
+		t.Fatalf("download: %d %q", w.Code, w.Body.String())
+	}
+	if got := w.Header().Get("Content-Disposition"); got != "attachment; filename=index.html" {
+		t.Errorf("Content-Disposition = %q", got)
+	}
+	if got := w.Header().Get("Content-Type"); !strings.HasPrefix(got, "text/html") {
+		t.Errorf("Content-Type = %q", got)
+	}
+
+	for _, path := range []string{
+		"/api/sessions/art-session/artifacts/missing.txt",
+		"/api/sessions/no-such-session/artifacts/index.html",
+		"/api/sessions/no-such-session/artifacts",
+	} {
+		w := httptest.NewRecorder()
+		srv.router.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+		if w.Code != http.StatusNotFound {
+			t.Errorf("%s: expected 404, got %d", path, w.Code)
+		}
+	}
+
+	w = httptest.NewRecorder()
+	srv.router.ServeHTTP(w, httptest.NewRequest("DELETE", "/api/sessions/art-session", nil))
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("delete: %d", w.Code)
+	}
+	if _, _, err := srv.artifacts.Open(ctx, "art-session", "index.html"); err == nil {
+		t.Error("artifact still there after deleting the session")
+	}
+}
+
+func TestUsageStats(t *testing.T) {
+	srv := newTestServer(t)
+	srv.config().Pricing = []config.ModelPrice{{Model: "m1", Input: 1, Output: 2}}
+	ctx := context.Background()
+
+	srv.store.CreateSession(ctx, &storage.Session{ID: "stats1", Title: "Stats", Status: storage.StatusActive})
+	at := time.Date(2026, 10, 5, 12, 0, 0, 0, time.UTC)
+	srv.store.AppendMessages(ctx, "stats1", []llm.Message{
+		{Role: llm.RoleUser, Content: "hi", Meta: &llm.MessageMeta{CreatedAt: at}},
+		{Role: llm.RoleAssistant, Content: "hello", Meta: &llm.MessageMeta{CreatedAt: at.Add(time.Second), Model: "m1", PromptTokens: 1_000_000, CompletionTokens: 500_000}},
+	})
+
+	for _, tt := range []struct {
+		query    string
+		messages int
+	}{
+		{"", 2},
+		{"?from=2026-10-05&to=2026-10-05", 2},
+		{"?from=2026-10-06", 0},
+	} {
+		w := httptest.NewRecorder()
+		srv.router.ServeHTTP(w, httptest.NewRequest("GET", "/api/stats"+tt.query, nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s: expected 200, got %d: %s", tt.query, w.Code, w.Body.String())
+		}
+		var report storage.UsageReport
+		json.NewDecoder(w.Body).Decode(&report)
+		if report.Totals.Messages != tt.messages {
+			t.Errorf("%s: messages = %d, want %d", tt.query, report.Totals.Messages, tt.messages)
+		}
+		if tt.messages > 0 && (report.Totals.Cost != 2 || len(report.Models) != 1 || report.Sessions[0].Title != "Stats") {
+			t.Errorf("%s: report = %+v", tt.query, report)
+		}
+	}
+
+	w := httptest.NewRecorder()
+	srv.router.ServeHTTP(w, httptest.NewRequest("GET", "/api/stats?from=yesterday", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("bad date: expected 400, got %d", w.Code)
+	}
+}
+
+func TestSendMessage_GeneratesTitle(t *testing.T) {
+	for _, tt := range []struct {
+		name, reply string
+		status      int
+		want        string
+	}{
+		{"utility model", `"Checking Disk Usage on Linux."`, http.StatusOK, "Checking Disk Usage on Linux"},
+		{"utility model fails", "", http.StatusBadRequest, "how much disk space is free?"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			titled := make(chan struct{})
+			llmSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				var req struct {
+					Model string `json:"model"`
+				}
+				json.NewDecoder(r.Body).Decode(&req)
+				content := "About 20 GB."
+				if req.Model == "titler" {
+					defer close(titled)
+					if tt.status != http.StatusOK {
+						http.Error(w, "overloaded", tt.status)
+						return
+					}
+					content = tt.reply
+				}
+				w.Header().Set("Content-Type", "application/json")
+				json.NewEncoder(w).Encode(map[string]any{
+					"id": "1", "object": "chat.completion", "model": req.Model,
+					"choices": []map[string]any{{
+						"index":         0,
+						"finish_reason": "stop",
+						"message":       map[string]any{"role": "assistant", "content": content},
+					}},
+				})
+			}))
+			defer llmSrv.Close()
+
+			srv := newTestServer(t)
+			srv.config().Providers["fake"] = config.ProviderConfig{
+				BaseURL: llmSrv.URL + "/", APIKey: "x",
+				Models: map[string]string{"default": "fake", "utility": "titler"},
+			}
+			ctx := context.Background()
+			srv.store.CreateSession(ctx, &storage.Session{ID: "title1", Status: storage.StatusActive, Provider: "fake", Model: "fake"})
+
+			body := strings.NewReader(`{"content":"how much disk space is free?"}`)
+			w := httptest.NewRecorder()
+			srv.router.ServeHTTP(w, httptest.NewRequest("POST", "/api/sessions/title1/messages", body))
+			if w.Code != http.StatusOK {
+				t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+			}
+
+			// The title is written in the background.
+			select {
+			case <-titled:
+			case <-time.After(5 * time.Second):
+				t.Fatal("utility model wasn't asked for a title")
+			}
+			var title string
+			for deadline := time.Now().Add(time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+				sess, err := srv.store.GetSession(ctx, "title1")
+				if err != nil {
+					t.Fatal(err)
+				}
+				if title = sess.Title; title != "how much disk space is free?" {
+					break
+				}
+			}
+			if title != tt.want {
+				t.Errorf("title = %q, want %q", title, tt.want)
+			}
+		})
+	}
+}
+
+func TestListSessions_Cursor(t *testing.T) {
+	srv := newTestServer(t)
+	ctx := context.Background()
+	for _, id := range []string{"p1", "p2", "p3"} {
+		srv.store.CreateSession(ctx, &storage.Session{ID: id, Status: storage.StatusActive})
+	}
+	srv.store.AppendMessages(ctx, "p1", []llm.Message{llm.UserMessage("a"), llm.AssistantMessage("b"), llm.UserMessage("c")})
+
+	var ids []string
+	for url := "/api/sessions?limit=2"; ; {
+		w := httptest.NewRecorder()
+		srv.router.ServeHTTP(w, httptest.NewRequest("GET", url, nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+		}
+		if total := w.Header().Get("X-Total-Count"); total != "3" {
+			t.Errorf("X-Total-Count = %q, want 3", total)
+		}
+		var page []storage.Session
+		json.NewDecoder(w.Body).Decode(&page)
+		for _, sess := range page {
+			ids = append(ids, sess.ID)
+		}
+		next := w.Header().Get("X-Next-Cursor")
+		if next == "" {
+			break
+		}
+		url = "/api/sessions?limit=2&cursor=" + next
+	}
+	if len(ids) != 3 || ids[0] == ids[1] || ids[1] == ids[2] || ids[0] == ids[2] {
+		t.Errorf("sessions = %v, want each session once", ids)
+	}
+
+	w := httptest.NewRecorder()
+	srv.router.ServeHTTP(w, httptest.NewRequest("GET", "/api/sessions/p1/messages?limit=2", nil))
+	var msgs []llm.Message
+	json.NewDecoder(w.Body).Decode(&msgs)
+	next := w.Header().Get("X-Next-Cursor")
+	if len(msgs) != 2 || w.Header().Get("X-Total-Count") != "3" || next == "" {
+		t.Fatalf("first message page = %+v, headers %v", msgs, w.Header())
+	}
+	w = httptest.NewRecorder()
+	srv.router.ServeHTTP(w, httptest.NewRequest("GET", "/api/sessions/p1/messages?limit=2&cursor="+next, nil))
+	json.NewDecoder(w.Body).Decode(&msgs)
+	if len(msgs) != 1 || msgs[0].Content != "c" || w.Header().Get("X-Next-Cursor") != "" {
+		t.Errorf("last message page = %+v, headers %v", msgs, w.Header())
+	}
+
+	for _, url := range []string{"/api/sessions?cursor=bogus", "/api/sessions/p1/messages?cursor=bogus"} {
+		w := httptest.NewRecorder()
+		srv.router.ServeHTTP(w, httptest.NewRequest("GET", url, nil))
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d", url, w.Code)
+		}
+	}
+}
+
+func TestListToolCalls(t *testing.T) {
+	srv := newTestServer(t)
+	ctx := context.Background()
+	srv.store.CreateSession(ctx, &storage.Session{ID: "calls1", Status: storage.StatusActive})
+	srv.store.RecordToolCall(ctx, tools.CallRecord{
+		Tool: "shell_exec", Server: "shell-exec", SessionID: "calls1", CallID: "call_1",
+		Args: map[string]any{"command": "ls"}, Result: "a.txt", Duration: time.Millisecond, At: time.Now(),
+	})
+
+	w := httptest.NewRecorder()
+	srv.router.ServeHTTP(w, httptest.NewRequest("GET", "/api/sessions/calls1/tool-calls", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var calls []tools.CallRecord
+	json.NewDecoder(w.Body).Decode(&calls)
+	if len(calls) != 1 || calls[0].CallID != "call_1" || calls[0].Args["command"] != "ls" || calls[0].Result != "a.txt" {
+		t.Errorf("calls = %+v", calls)
+	}
+
+	w = httptest.NewRecorder()
+	srv.router.ServeHTTP(w, httptest.NewRequest("GET", "/api/sessions/missing/tool-calls", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("missing session: expected 404, got %d", w.Code)
+	}
+}
+
+// blockingClient is an LLM that doesn't answer until its call is cancelled.
+// It signals started, which should be buffered, as each call starts.
+type blockingClient struct {
+	started chan struct{}
+}
+
+func (c *blockingClient) ChatCompletion(ctx context.Context, messages []llm.Message, tools []llm.ToolDef) (*llm.Response, error) {
+	select {
+	case c.started <- struct{}{}:
+	default:
+	}
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func (c *blockingClient) ChatCompletionStream(ctx context.Context, messages []llm.Message, tools []llm.ToolDef, handler llm.StreamHandler) (*llm.Response, error) {
+	return c.ChatCompletion(ctx, messages, tools)
+}
+
+func TestCancelSession(t *testing.T) {
+	srv := newTestServer(t)
+	ctx := context.Background()
+	sess := &storage.Session{ID: "cancel1", Title: "Runaway", Status: storage.StatusActive}
+	srv.store.CreateSession(ctx, sess)
+
+	w := httptest.NewRecorder()
+	srv.router.ServeHTTP(w, httptest.NewRequest("POST", "/api/sessions/cancel1/cancel", nil))
+	if w.Code != http.StatusConflict {
+		t.Errorf("idle session: expected 409, got %d", w.Code)
+	}
+
+	as, err := srv.sessions.GetOrCreate(ctx, sess, srv.config(), srv.store, srv.registry)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := &blockingClient{started: make(chan struct{}, 1)}
+	as.Agent.SetClient(client)
+
+	sent := make(chan *httptest.ResponseRecorder)
+	go func() {
+		w := httptest.NewRecorder()
+		srv.router.ServeHTTP(w, httptest.NewRequest("POST", "/api/sessions/cancel1/messages", strings.NewReader(`{"content":"loop forever"}`)))
+		sent <- w
+	}()
+	<-client.started
+
+	w = httptest.NewRecorder()
+	srv.router.ServeHTTP(w, httptest.NewRequest("POST", "/api/sessions/cancel1/cancel", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var resp struct {
+		Cancelled bool          `json:"cancelled"`
+		Messages  []llm.Message `json:"messages"`
+	}
+	json.NewDecoder(w.Body).Decode(&resp)
+	if !resp.Cancelled || len(resp.Messages) != 1 || resp.Messages[0].Content != "loop forever" {
+		t.Errorf("cancel response = %+v", resp)
+	}
+
+	if w := <-sent; w.Code != http.StatusConflict || !strings.Contains(w.Body.String(), "interrupted") {
+		t.Errorf("interrupted send: got %d: %s", w.Code, w.Body.String())
+	}
+	if msgs, _ := srv.store.LoadMessages(ctx, "cancel1"); len(msgs) != 2 {
+		t.Errorf("saved %d messages, want the system prompt and the user message", len(msgs))
+	}
+}
+
+func TestSendMessage_Queue(t *testing.T) {
+	srv := newTestServer(t)
+	ctx := context.Background()
+	sess := &storage.Session{ID: "queue1", Title: "Busy", Status: storage.StatusActive}
+	srv.store.CreateSession(ctx, sess)
+	as, err := srv.sessions.GetOrCreate(ctx, sess, srv.config(), srv.store, srv.registry)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := &blockingClient{started: make(chan struct{}, 1)}
+	as.Agent.SetClient(client)
+
+	send := func(url, content string) chan *httptest.ResponseRecorder {
+		done := make(chan *httptest.ResponseRecorder, 1)
+		go func() {
+			w := httptest.NewRecorder()
+			srv.router.ServeHTTP(w, httptest.NewRequest("POST", url, strings.NewReader(`{"content":"`+content+`"}`)))
+			done <- w
+		}()
+		return done
+	}
+	first := send("/api/sessions/queue1/messages", "first")
+	<-client.started
+
+	w := <-send("/api/sessions/queue1/messages?wait=false", "impatient")
+	var busy struct {
+		Error    string `json:"error"`
+		Position int    `json:"position"`
+	}
+	json.NewDecoder(w.Body).Decode(&busy)
+	if w.Code != http.StatusConflict || busy.Position != 1 {
+		t.Errorf("busy session: got %d %+v", w.Code, busy)
+	}
+
+	second := send("/api/sessions/queue1/messages", "second")
+	for queued := 0; queued == 0; time.Sleep(time.Millisecond) {
+		as.queueMu.Lock()
+		queued = len(as.queue)
+		as.queueMu.Unlock()
+	}
+	as.cancelTurn()
+	if w := <-first; w.Code != http.StatusConflict {
+		t.Errorf("first: got %d", w.Code)
+	}
+
+	// The queued message runs once the first is done.
+	<-client.started
+	as.cancelTurn()
+	if w := <-second; w.Code != http.StatusConflict {
+		t.Errorf("second: got %d", w.Code)
+	}
+	msgs, _ := srv.store.LoadMessages(ctx, "queue1")
+	var users []string
+	for _, m := range msgs {
+		if m.Role == llm.RoleUser {
+			users = append(users, m.Content)
+		}
+	}
+	if strings.Join(users, ",") != "first,second" {
+		t.Errorf("user messages = %v", users)
+	}
+}
+
+func TestSwitchModel(t *testing.T) {
+	srv := newChatTestServer(t)
+	ctx := context.Background()
+	sess := &storage.Session{ID: "switch1", Title: "Switch", Status: storage.StatusActive, Provider: "ollama", Model: "qwen3:14b"}
+	srv.store.CreateSession(ctx, sess)
+	as, err := srv.sessions.GetOrCreate(ctx, sess, srv.config(), srv.store, srv.registry)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	patch := func(body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		srv.router.ServeHTTP(w, httptest.NewRequest("PATCH", "/api/sessions/switch1/model", strings.NewReader(body)))
+		return w
+	}
+	for _, body := range []string{`{"provider":"nope","model":"x"}`, `{"model":"claude/"}`, `{}`} {
+		if w := patch(body); w.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d", body, w.Code)
+		}
+	}
+
+	w := patch(`{"model":"fake"}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	got, _ := srv.store.GetSession(ctx, "switch1")
+	if got.Provider != "fake" || got.Model != "fake" || got.Title != "Switch" {
+		t.Errorf("session after switch = %+v", got)
+	}
+
+	// The same agent now talks to the fake provider.
+	if current, _ := srv.sessions.Get("switch1"); current != as {
+		t.Error("switching replaced the session's agent")
+	}
+	w = httptest.NewRecorder()
+	srv.router.ServeHTTP(w, httptest.NewRequest("POST", "/api/sessions/switch1/messages", strings.NewReader(`{"content":"hi"}`)))
+	if !strings.Contains(w.Body.String(), "(2 messages)") {
+		t.Errorf("reply after switch: %d %s", w.Code, w.Body.String())
+	}
+
+	// A session without an agent in memory just gets the new settings.
+	if w := patch(`{"provider":"claude","model":"claude-opus-4-1"}`); w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", w.Code)
+	}
+	srv.sessions.Remove("switch1")
+	if w := patch(`{"model":"gemini"}`); w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", w.Code)
+	}
+	got, _ = srv.store.GetSession(ctx, "switch1")
+	if got.Provider != "gemini" || got.Model != "gemini-2.0-flash" {
+		t.Errorf("session after switch = %+v", got)
+	}
+}
+
+func TestListTools(t *testing.T) {
+	srv := newTestServer(t)
+	list := func() []toolGroup {
+		w := httptest.NewRecorder()
+		srv.router.ServeHTTP(w, httptest.NewRequest("GET", "/api/tools", nil))
+		var groups []toolGroup
+		json.NewDecoder(w.Body).Decode(&groups)
+		return groups
+	}
+
+	// Without tool servers, agents use the built-in pack.
+	groups := list()
+	if len(groups) != 1 || groups[0].Server != "builtin" || len(groups[0].Tools) == 0 || groups[0].Tools[0].Parameters == nil {
+		t.Errorf("without servers: %+v", groups)
+	}
+
+	srv.registry.Add("fake", &fakeToolServer{tools: []string{"echo", "about"}})
+	groups = list()
+	if len(groups) != 1 || groups[0].Server != "fake" || len(groups[0].Tools) != 2 || groups[0].Tools[0].Name != "about" {
+		t.Errorf("with a server: %+v", groups)
+	}
+}
+
+func TestCallTool(t *testing.T) {
+	srv := newTestServer(t)
+	srv.registry.Add("fake", &fakeToolServer{tools: []string{"echo"}})
+	var records []tools.CallRecord
+	srv.registry.OnCall(func(rec tools.CallRecord) { records = append(records, rec) })
+	srv.store.CreateSession(context.Background(), &storage.Session{ID: "tools1", Status: storage.StatusActive})
+
+	call := func(name, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		srv.router.ServeHTTP(w, httptest.NewRequest("POST", "/api/tools/"+name+"/call", strings.NewReader(body)))
+		return w
+	}
+
+	w := call("echo", `{"args":{"text":"hi"},"session_id":"tools1"}`)
+	var resp struct {
+		Result string `json:"result"`
+		Failed bool   `json:"failed"`
+	}
+	json.NewDecoder(w.Body).Decode(&resp)
+	if w.Code != http.StatusOK || resp.Result != `echo {"text":"hi"}` || resp.Failed {
+		t.Errorf("call: %d %+v", w.Code, resp)
+	}
+	if len(records) != 1 || records[0].SessionID != "tools1" || !strings.HasPrefix(records[0].CallID, "api_") {
+		t.Errorf("records = %+v", records)
+	}
+
+	if w := call("echo", ""); w.Code != http.StatusOK {
+		t.Errorf("no body: expected 200, got %d", w.Code)
+	}
+	if w := call("nope", `{}`); w.Code != http.StatusNotFound {
+		t.Errorf("unknown tool: expected 404, got %d", w.Code)
+	}
+	if w := call("echo", `{"session_id":"missing"}`); w.Code != http.StatusNotFound {
+		t.Errorf("unknown session: expected 404, got %d", w.Code)
+	}
+}
+
+func TestLogRequests(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := logging.New(&buf, logging.Options{Format: "json"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer slog.SetDefault(slog.Default())
+	slog.SetDefault(logger)
+
+	srv := newTestServer(t)
+	w := httptest.NewRecorder()
+	srv.router.ServeHTTP(w, httptest.NewRequest("GET", "/api/sessions?api_key=secret&all=true", nil))
+
+	var rec map[string]any
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		json.Unmarshal([]byte(line), &rec)
+		if rec["msg"] == "request" {
+			break
+		}
+	}
+	if rec["component"] != "http" || rec["path"] != "/api/sessions" || rec["status"] != 200.0 || rec["request_id"] == nil {
+		t.Errorf("request log = %v", rec)
+	}
+	if q, _ := rec["query"].(string); strings.Contains(q, "secret") || !strings.Contains(q, "all=true") {
+		t.Errorf("query = %q, want the api_key redacted", q)
+	}
+}
@@ -0,0 +1,63 @@
+package server
+
+import (
+	"context"
+	"time"
+
+	"github.com/michaelbrown/forge/internal/logging"
+)
+
+// janitorInterval is how often the janitor applies the retention policy.
+const janitorInterval = time.Hour
+
+// runJanitor applies storage.retention until ctx is done: it archives
+// sessions left idle too long and deletes sessions archived too long ago.
+// It does nothing when neither limit is set.
+func (s *Server) runJanitor(ctx context.Context) {
+	r := s.config().Storage.Retention
+	if r.ArchiveAfterDays <= 0 && r.DeleteAfterDays <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(janitorInterval)
+	defer ticker.Stop()
+	for {
+		s.applyRetention(ctx, time.Now())
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// applyRetention archives and deletes sessions as of now. Affected sessions
+// are dropped from memory so a later request reloads them from the store.
+func (s *Server) applyRetention(ctx context.Context, now time.Time) {
+	r := s.config().Storage.Retention
+	if r.ArchiveAfterDays > 0 {
+		ids, err := s.store.ArchiveIdleSessions(ctx, now.AddDate(0, 0, -r.ArchiveAfterDays))
+		if err != nil {
+			logging.For("janitor").Error("archiving idle sessions", "error", err)
+		} else if len(ids) > 0 {
+			logging.For("janitor").Info("archived idle sessions", "count", len(ids))
+		}
+		for _, id := range ids {
+			s.sessions.Remove(id)
+		}
+	}
+	if r.DeleteAfterDays > 0 {
+		ids, err := s.store.DeleteArchivedSessions(ctx, now.AddDate(0, 0, -r.DeleteAfterDays))
+		if err != nil {
+			logging.For("janitor").Error("deleting archived sessions", "error", err)
+		} else if len(ids) > 0 {
+			logging.For("janitor").Info("deleted archived sessions", "count", len(ids))
+		}
+		for _, id := range ids {
+			s.sessions.Remove(id)
+			if err := s.artifacts.RemoveSession(id); err != nil {
+				logging.For("janitor").Error("deleting artifacts", "session", id, "error", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,47 @@
+package server
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/michaelbrown/forge/internal/config"
+	"github.com/michaelbrown/forge/internal/llm"
+	"github.com/michaelbrown/forge/internal/storage"
+)
+
+func TestJanitorAppliesRetention(t *testing.T) {
+	srv := newTestServer(t)
+	srv.config().Storage.Retention = config.RetentionConfig{ArchiveAfterDays: 7, DeleteAfterDays: 30}
+	ctx := context.Background()
+
+	sess := &storage.Session{ID: "janitor-1", Status: storage.StatusActive}
+	srv.store.CreateSession(ctx, sess)
+	srv.store.SaveMessages(ctx, sess.ID, []llm.Message{{Role: llm.RoleUser, Content: "hi"}})
+
+	status := func() storage.SessionStatus {
+		t.Helper()
+		got, err := srv.store.GetSession(ctx, sess.ID)
+		if err != nil {
+			return ""
+		}
+		return got.Status
+	}
+
+	srv.applyRetention(ctx, time.Now().AddDate(0, 0, 6))
+	if got := status(); got != storage.StatusActive {
+		t.Fatalf("status after 6 days = %q, want active", got)
+	}
+	srv.applyRetention(ctx, time.Now().AddDate(0, 0, 8))
+	if got := status(); got != storage.StatusArchived {
+		t.Fatalf("status after 8 days = %q, want archived", got)
+	}
+	srv.applyRetention(ctx, time.Now().AddDate(0, 0, 29))
+	if got := status(); got != storage.StatusArchived {
+		t.Fatalf("status 29 days after archiving = %q, want archived", got)
+	}
+	srv.applyRetention(ctx, time.Now().AddDate(0, 0, 31))
+	if got := status(); got != "" {
+		t.Fatalf("status 31 days after archiving = %q, want deleted", got)
+	}
+}
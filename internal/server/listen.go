@@ -0,0 +1,84 @@
+package server
+
+import (
+	"crypto/tls"
+	"fmt"
+	"io/fs"
+	"net"
+	"os"
+	"strings"
+
+	"golang.org/x/crypto/acme/autocert"
+)
+
+// listen opens addr, a "host:port" address or "unix:///path/to.sock". A
+// socket file left behind by a server that didn't shut down cleanly is
+// replaced, and the socket is removed when the listener closes.
+func listen(addr string) (net.Listener, error) {
+	path, ok := strings.CutPrefix(addr, "unix://")
+	if !ok {
+		return net.Listen("tcp", addr)
+	}
+	if path == "" {
+		return nil, fmt.Errorf("listen %s: no socket path", addr)
+	}
+	if info, err := os.Stat(path); err == nil {
+		if info.Mode()&fs.ModeSocket == 0 {
+			return nil, fmt.Errorf("listen %s: file exists and is not a socket", path)
+		}
+		if conn, err := net.Dial("unix", path); err == nil {
+			conn.Close()
+			return nil, fmt.Errorf("listen %s: socket is in use", path)
+		}
+		os.Remove(path)
+	}
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	// Only the owner and its group, such as a reverse proxy's, may connect.
+	if err := os.Chmod(path, 0o660); err != nil {
+		ln.Close()
+		return nil, err
+	}
+	return ln, nil
+}
+
+// tlsConfig returns the TLS setup server.tls asks for, or nil to serve
+// plain HTTP.
+func (s *Server) tlsConfig() (*tls.Config, error) {
+	t := s.config().Server.TLS
+	switch {
+	case t.CertFile != "":
+		cert, err := tls.LoadX509KeyPair(t.CertFile, t.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("loading TLS certificate: %w", err)
+		}
+		return &tls.Config{Certificates: []tls.Certificate{cert}, MinVersion: tls.VersionTLS12}, nil
+	case len(t.Autocert.Domains) > 0:
+		m := &autocert.Manager{
+			Prompt:     autocert.AcceptTOS,
+			HostPolicy: autocert.HostWhitelist(t.Autocert.Domains...),
+			Cache:      autocert.DirCache(t.Autocert.CacheDir),
+			Email:      t.Autocert.Email,
+		}
+		return m.TLSConfig(), nil
+	}
+	return nil, nil
+}
+
+// serverURL describes where a server listening on addr can be reached, for
+// logging.
+func serverURL(addr string, tls bool) string {
+	if strings.HasPrefix(addr, "unix://") {
+		return addr
+	}
+	scheme := "http"
+	if tls {
+		scheme = "https"
+	}
+	if strings.HasPrefix(addr, ":") {
+		addr = "localhost" + addr
+	}
+	return scheme + "://" + addr
+}
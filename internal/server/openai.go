@@ -0,0 +1,268 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/michaelbrown/forge/internal/llm"
+)
+
+// The /v1 routes let OpenAI clients drive forge agents. The model a client
+// asks for names an agent profile, and the agent runs its tools on the
+// server, so clients only ever see its final answers.
+
+type chatCompletionRequest struct {
+	Model    string             `json:"model"`
+	Messages []chatMessageIn    `json:"messages"`
+	Stream   bool               `json:"stream"`
+	Options  *chatStreamOptions `json:"stream_options,omitempty"`
+}
+
+type chatStreamOptions struct {
+	IncludeUsage bool `json:"include_usage"`
+}
+
+// chatMessageIn is a message as OpenAI clients send it. Content is a string
+// or an array of parts, of which only text parts are used.
+type chatMessageIn struct {
+	Role    llm.Role        `json:"role"`
+	Content json.RawMessage `json:"content"`
+}
+
+// text returns the message's text content.
+func (m chatMessageIn) text() string {
+	var s string
+	if json.Unmarshal(m.Content, &s) == nil {
+		return s
+	}
+	var parts []struct {
+		Type string `json:"type"`
+		Text string `json:"text"`
+	}
+	json.Unmarshal(m.Content, &parts)
+	var texts []string
+	for _, p := range parts {
+		if p.Type == "text" {
+			texts = append(texts, p.Text)
+		}
+	}
+	return strings.Join(texts, "\n")
+}
+
+type chatMessageOut struct {
+	Role    llm.Role `json:"role,omitempty"`
+	Content string   `json:"content,omitempty"`
+}
+
+type chatChoice struct {
+	Index        int             `json:"index"`
+	Message      *chatMessageOut `json:"message,omitempty"`
+	Delta        *chatMessageOut `json:"delta,omitempty"`
+	FinishReason *string         `json:"finish_reason"`
+}
+
+type chatUsage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	TotalTokens      int `json:"total_tokens"`
+}
+
+type chatCompletion struct {
+	ID      string       `json:"id"`
+	Object  string       `json:"object"`
+	Created int64        `json:"created"`
+	Model   string       `json:"model"`
+	Choices []chatChoice `json:"choices"`
+	Usage   *chatUsage   `json:"usage,omitempty"`
+}
+
+// writeOpenAIError writes an error in the shape OpenAI clients expect.
+func writeOpenAIError(w http.ResponseWriter, status int, code, msg string) {
+	kind := "invalid_request_error"
+	if status >= 500 {
+		kind = "server_error"
+	}
+	writeJSON(w, status, map[string]any{"error": map[string]any{
+		"message": msg, "type": kind, "code": code,
+	}})
+}
+
+// handleChatCompletions runs the agent for the profile named by the
+// request's model on the request's messages, streaming the reply if asked.
+// Each request gets a fresh agent whose history is the request's messages,
+// with any system messages added to the profile's prompt, and nothing is
+// saved.
+func (s *Server) handleChatCompletions(w http.ResponseWriter, r *http.Request) {
+	var req chatCompletionRequest
+	if err := decodeJSON(r, &req); err != nil {
+		writeOpenAIError(w, http.StatusBadRequest, "invalid_json", "invalid JSON: "+err.Error())
+		return
+	}
+	if req.Model == "" || s.checkProfile(req.Model) != nil {
+		writeOpenAIError(w, http.StatusNotFound, "model_not_found",
+			fmt.Sprintf("model %q is not an agent profile; see GET /v1/models", req.Model))
+		return
+	}
+	cfg := s.config()
+	profile, err := loadProfile(cfg, req.Model)
+	if err != nil {
+		writeOpenAIError(w, http.StatusNotFound, "model_not_found", err.Error())
+		return
+	}
+
+	var system []string
+	var history []llm.Message
+	for _, m := range req.Messages {
+		switch text := m.text(); m.Role {
+		case "system", "developer":
+			system = append(system, text)
+		case llm.RoleUser:
+			history = append(history, llm.UserMessage(text))
+		case llm.RoleAssistant:
+			// Replies that only called client-side tools have nothing to keep.
+			if text != "" {
+				history = append(history, llm.AssistantMessage(text))
+			}
+		}
+	}
+	if len(history) == 0 || history[len(history)-1].Role != llm.RoleUser || history[len(history)-1].Content == "" {
+		writeOpenAIError(w, http.StatusBadRequest, "invalid_messages", "the last message must be a user message with text")
+		return
+	}
+	prompt := history[len(history)-1].Content
+	history = history[:len(history)-1]
+
+	// Completions keep no session, but a drain waits for them all the same.
+	done, err := s.sessions.StartUnsaved()
+	if err != nil {
+		writeOpenAIError(w, http.StatusServiceUnavailable, "server_draining", err.Error())
+		return
+	}
+	defer done()
+
+	a, owned, err := newAgent(cfg, s.registry, "chat completion", profile.Provider, profile.Model, profile)
+	if err != nil {
+		writeOpenAIError(w, http.StatusInternalServerError, "agent_error", fmt.Sprintf("initializing agent: %v", err))
+		return
+	}
+	if owned != nil {
+		defer owned.Close()
+	}
+	systemPrompt := a.History()[0].Content
+	if len(system) > 0 {
+		systemPrompt += "\n\n" + strings.Join(system, "\n\n")
+	}
+	a.SetHistory(append([]llm.Message{llm.SystemMessage(systemPrompt)}, history...))
+
+	completion := chatCompletion{
+		ID:      "chatcmpl-" + uuid.New().String(),
+		Object:  "chat.completion",
+		Created: time.Now().Unix(),
+		Model:   req.Model,
+	}
+	stop := "stop"
+
+	if !req.Stream {
+		reply, err := a.Run(r.Context(), prompt)
+		s.chargeTurn(r.Context(), a.History())
+		if err != nil {
+			writeOpenAIError(w, http.StatusInternalServerError, "agent_error", err.Error())
+			return
+		}
+		completion.Choices = []chatChoice{{
+			Message:      &chatMessageOut{Role: llm.RoleAssistant, Content: reply},
+			FinishReason: &stop,
+		}}
+		completion.Usage = turnUsage(a.History())
+		writeJSON(w, http.StatusOK, completion)
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeOpenAIError(w, http.StatusInternalServerError, "streaming_unsupported", "streaming not supported")
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	completion.Object = "chat.completion.chunk"
+	var mu sync.Mutex
+	send := func(v any) {
+		mu.Lock()
+		defer mu.Unlock()
+		data, _ := json.Marshal(v)
+		fmt.Fprintf(w, "data: %s\n\n", data)
+		flusher.Flush()
+	}
+	chunk := func(delta *chatMessageOut, finish *string) chatCompletion {
+		c := completion
+		c.Choices = []chatChoice{{Delta: delta, FinishReason: finish}}
+		return c
+	}
+
+	send(chunk(&chatMessageOut{Role: llm.RoleAssistant}, nil))
+	a.OnTextDelta = func(delta string) {
+		send(chunk(&chatMessageOut{Content: delta}, nil))
+	}
+	_, err = a.RunStreaming(r.Context(), prompt)
+	s.chargeTurn(r.Context(), a.History())
+	if err != nil {
+		send(map[string]any{"error": map[string]any{"message": err.Error(), "type": "server_error", "code": "agent_error"}})
+		return
+	}
+	send(chunk(&chatMessageOut{}, &stop))
+	if req.Options != nil && req.Options.IncludeUsage {
+		c := completion
+		c.Choices = []chatChoice{}
+		c.Usage = turnUsage(a.History())
+		send(c)
+	}
+	mu.Lock()
+	fmt.Fprint(w, "data: [DONE]\n\n")
+	flusher.Flush()
+	mu.Unlock()
+}
+
+// turnUsage totals the tokens the provider reported for the replies after
+// the last user message.
+func turnUsage(history []llm.Message) *chatUsage {
+	var u chatUsage
+	for i := len(history) - 1; i >= 0 && history[i].Role != llm.RoleUser; i-- {
+		if meta := history[i].Meta; meta != nil {
+			u.PromptTokens += meta.PromptTokens
+			u.CompletionTokens += meta.CompletionTokens
+		}
+	}
+	u.TotalTokens = u.PromptTokens + u.CompletionTokens
+	return &u
+}
+
+// handleListChatModels lists the agent profiles, which are the models
+// /v1/chat/completions accepts.
+func (s *Server) handleListChatModels(w http.ResponseWriter, r *http.Request) {
+	models := []map[string]any{}
+	for _, path := range s.config().ProfilePaths() {
+		info, err := os.Stat(path)
+		if err != nil {
+			continue
+		}
+		models = append(models, map[string]any{
+			"id":       strings.TrimSuffix(filepath.Base(path), ".yaml"),
+			"object":   "model",
+			"created":  info.ModTime().Unix(),
+			"owned_by": "forge",
+		})
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"object": "list", "data": models})
+}
@@ -0,0 +1,246 @@
+package server
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/michaelbrown/forge/internal/config"
+)
+
+// newChatTestServer returns a Server with a "helper" profile whose provider
+// is a fake LLM. The LLM echoes the system prompt's last line and the
+// number of messages it was sent, streaming if asked.
+func newChatTestServer(t *testing.T) *Server {
+	t.Helper()
+
+	llmSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Stream   bool `json:"stream"`
+			Messages []struct {
+				Content string `json:"content"`
+			} `json:"messages"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		lines := strings.Split(strings.TrimSpace(req.Messages[0].Content), "\n")
+		reply := fmt.Sprintf("%s (%d messages)", lines[len(lines)-1], len(req.Messages))
+
+		if !req.Stream {
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(map[string]any{
+				"id": "1", "object": "chat.completion", "model": "fake",
+				"choices": []map[string]any{{
+					"index": 0, "finish_reason": "stop",
+					"message": map[string]any{"role": "assistant", "content": reply},
+				}},
+				"usage": map[string]any{"prompt_tokens": 10, "completion_tokens": 5, "total_tokens": 15},
+			})
+			return
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, delta := range []string{reply[:4], reply[4:]} {
+			data, _ := json.Marshal(map[string]any{
+				"id": "1", "object": "chat.completion.chunk", "model": "fake",
+				"choices": []map[string]any{{"index": 0, "delta": map[string]any{"content": delta}}},
+			})
+			fmt.Fprintf(w, "data: %s\n\n", data)
+		}
+		data, _ := json.Marshal(map[string]any{
+			"id": "1", "object": "chat.completion.chunk", "model": "fake",
+			"choices": []map[string]any{{"index": 0, "delta": map[string]any{}, "finish_reason": "stop"}},
+		})
+		fmt.Fprintf(w, "data: %s\n\ndata: [DONE]\n\n", data)
+	}))
+	t.Cleanup(llmSrv.Close)
+
+	profiles := t.TempDir()
+	profile := "name: helper\nprovider: fake\nsystem_prompt: You help.\n"
+	if err := os.WriteFile(filepath.Join(profiles, "helper.yaml"), []byte(profile), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	srv := newTestServer(t)
+	srv.config().Agent.ProfilesDir = profiles
+	srv.config().Providers["fake"] = config.ProviderConfig{BaseURL: llmSrv.URL + "/", APIKey: "x", Models: map[string]string{"default": "fake"}}
+	return srv
+}
+
+func TestChatCompletions(t *testing.T) {
+	srv := newChatTestServer(t)
+
+	body := `{"model":"helper","messages":[
+		{"role":"system","content":"Be brief."},
+		{"role":"user","content":"hi"},
+		{"role":"assistant","content":"hello"},
+		{"role":"user","content":[{"type":"text","text":"how are you?"}]}
+	]}`
+	w := httptest.NewRecorder()
+	srv.router.ServeHTTP(w, httptest.NewRequest("POST", "/v1/chat/completions", strings.NewReader(body)))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var resp chatCompletion
+	json.NewDecoder(w.Body).Decode(&resp)
+	if resp.Object != "chat.completion" || resp.Model != "helper" || len(resp.Choices) != 1 {
+		t.Fatalf("response = %+v", resp)
+	}
+	// The client's system message follows the profile's prompt, and the
+	// LLM sees it plus the three conversation messages.
+	if got := resp.Choices[0].Message.Content; got != "Be brief. (4 messages)" {
+		t.Errorf("reply = %q", got)
+	}
+	if resp.Usage == nil || resp.Usage.TotalTokens != 15 {
+		t.Errorf("usage = %+v", resp.Usage)
+	}
+
+	for _, tt := range []struct {
+		body string
+		want int
+	}{
+		{`{"model":"nope","messages":[{"role":"user","content":"hi"}]}`, http.StatusNotFound},
+		{`{"model":"../helper","messages":[{"role":"user","content":"hi"}]}`, http.StatusNotFound},
+		{`{"model":"helper","messages":[{"role":"assistant","content":"hi"}]}`, http.StatusBadRequest},
+		{`{"model":"helper"`, http.StatusBadRequest},
+	} {
+		w := httptest.NewRecorder()
+		srv.router.ServeHTTP(w, httptest.NewRequest("POST", "/v1/chat/completions", strings.NewReader(tt.body)))
+		var e struct {
+			Error struct{ Message string } `json:"error"`
+		}
+		json.NewDecoder(w.Body).Decode(&e)
+		if w.Code != tt.want || e.Error.Message == "" {
+			t.Errorf("%s: got %d %+v, want %d with an error message", tt.body, w.Code, e, tt.want)
+		}
+	}
+}
+
+func TestChatCompletions_Stream(t *testing.T) {
+	srv := newChatTestServer(t)
+	ts := httptest.NewServer(srv.router)
+	defer ts.Close()
+
+	body := `{"model":"helper","stream":true,"stream_options":{"include_usage":true},"messages":[{"role":"user","content":"hi"}]}`
+	resp, err := http.Post(ts.URL+"/v1/chat/completions", "application/json", strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.Header.Get("Content-Type") != "text/event-stream" {
+		t.Fatalf("Content-Type = %q", resp.Header.Get("Content-Type"))
+	}
+
+	var content strings.Builder
+	var finished, usage, done bool
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		data, ok := strings.CutPrefix(scanner.Text(), "data: ")
+		if !ok {
+			continue
+		}
+		if data == "[DONE]" {
+			done = true
+			break
+		}
+		var chunk chatCompletion
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			t.Fatalf("bad chunk %q: %v", data, err)
+		}
+		if chunk.Object != "chat.completion.chunk" {
+			t.Errorf("chunk object = %q", chunk.Object)
+		}
+		if chunk.Usage != nil {
+			usage = true
+		}
+		for _, c := range chunk.Choices {
+			content.WriteString(c.Delta.Content)
+			if c.FinishReason != nil && *c.FinishReason == "stop" {
+				finished = true
+			}
+		}
+	}
+	if got := content.String(); got != "You help. (2 messages)" {
+		t.Errorf("streamed content = %q", got)
+	}
+	if !finished || !usage || !done {
+		t.Errorf("finished = %v, usage = %v, done = %v", finished, usage, done)
+	}
+}
+
+func TestListChatModels(t *testing.T) {
+	srv := newChatTestServer(t)
+	w := httptest.NewRecorder()
+	srv.router.ServeHTTP(w, httptest.NewRequest("GET", "/v1/models", nil))
+	var list struct {
+		Object string
+		Data   []struct{ ID string }
+	}
+	json.NewDecoder(w.Body).Decode(&list)
+	if list.Object != "list" || len(list.Data) != 1 || list.Data[0].ID != "helper" {
+		t.Errorf("models = %+v", list)
+	}
+}
+
+func TestChatCompletions_Drain(t *testing.T) {
+	// An LLM that answers once released.
+	started, release := make(chan struct{}, 1), make(chan struct{})
+	llmSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case started <- struct{}{}:
+		default:
+		}
+		<-release
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]any{
+			"id": "1", "object": "chat.completion", "model": "fake",
+			"choices": []map[string]any{{"index": 0, "finish_reason": "stop", "message": map[string]any{"role": "assistant", "content": "done"}}},
+		})
+	}))
+	defer llmSrv.Close()
+	srv := newChatTestServer(t)
+	srv.config().Providers["fake"] = config.ProviderConfig{BaseURL: llmSrv.URL + "/", APIKey: "x", Models: map[string]string{"default": "fake"}}
+
+	complete := func() *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		srv.router.ServeHTTP(w, httptest.NewRequest("POST", "/v1/chat/completions", strings.NewReader(`{"model":"helper","messages":[{"role":"user","content":"hi"}]}`)))
+		return w
+	}
+	drain := func() map[string]any {
+		w := httptest.NewRecorder()
+		srv.router.ServeHTTP(w, httptest.NewRequest("POST", "/api/admin/drain?timeout=50ms", nil))
+		var resp map[string]any
+		json.NewDecoder(w.Body).Decode(&resp)
+		return resp
+	}
+
+	done := make(chan *httptest.ResponseRecorder)
+	go func() { done <- complete() }()
+	<-started
+
+	// The drain waits for the completion in progress and turns new ones
+	// away.
+	if resp := drain(); resp["idle"] != false || resp["busy"] != float64(1) {
+		t.Errorf("drain with a completion running = %v", resp)
+	}
+	if w := complete(); w.Code != http.StatusServiceUnavailable {
+		t.Errorf("completion while draining: expected 503, got %d: %s", w.Code, w.Body.String())
+	}
+
+	close(release)
+	if w := <-done; w.Code != http.StatusOK {
+		t.Errorf("completion started before the drain: got %d: %s", w.Code, w.Body.String())
+	}
+	if resp := drain(); resp["idle"] != true || resp["busy"] != float64(0) {
+		t.Errorf("drain once the completion ended = %v", resp)
+	}
+
+	srv.sessions.Resume()
+	if w := complete(); w.Code != http.StatusOK {
+		t.Errorf("completion after resuming: got %d", w.Code)
+	}
+}
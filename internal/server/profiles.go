@@ -0,0 +1,177 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/michaelbrown/forge/internal/agent"
+	"github.com/michaelbrown/forge/internal/logging"
+	"github.com/michaelbrown/forge/internal/storage"
+)
+
+// Agent profiles are the YAML files in the profiles directory. The API
+// names a profile by its file name, which is also what sessions store, so
+// a profile's name field always matches it.
+
+// validProfileName reports whether name can name a profile file.
+func validProfileName(name string) bool {
+	return name != "" && !strings.ContainsAny(name, `/\`) && !strings.HasPrefix(name, ".")
+}
+
+// profilePath returns the file for the named profile: the project's own,
+// if it has one, or the one in the profiles directory.
+func (s *Server) profilePath(name string) string {
+	return s.config().ProfilePath(name)
+}
+
+// validateProfile reports what's wrong with p: its provider must be
+// configured and its tools must exist.
+func (s *Server) validateProfile(p *agent.Profile) error {
+	if !validProfileName(p.Name) {
+		return fmt.Errorf("invalid profile name %q", p.Name)
+	}
+	if p.Provider != "" {
+		if _, ok := s.config().Providers[p.Provider]; !ok {
+			return fmt.Errorf("unknown provider %q", p.Provider)
+		}
+	}
+	if p.MaxIter < 0 {
+		return errors.New("max_iterations must not be negative")
+	}
+	var known []string
+	for _, t := range s.registry.AllTools() {
+		known = append(known, t.Name)
+	}
+	for _, name := range p.Tools {
+		if !slices.Contains(known, name) {
+			return fmt.Errorf("unknown tool %q", name)
+		}
+	}
+	return nil
+}
+
+// handleListProfiles returns the agent profiles, sorted by name. Files
+// that don't parse are left out.
+func (s *Server) handleListProfiles(w http.ResponseWriter, r *http.Request) {
+	profiles := []*agent.Profile{}
+	for _, path := range s.config().ProfilePaths() {
+		p, err := agent.LoadProfile(path)
+		if err != nil {
+			logging.For("server").WarnContext(r.Context(), "skipping profile", "error", err)
+			continue
+		}
+		p.Name = strings.TrimSuffix(filepath.Base(path), ".yaml")
+		profiles = append(profiles, p)
+	}
+	writeJSON(w, http.StatusOK, profiles)
+}
+
+// loadProfileByName loads the profile named in the URL, writing a 404 if
+// there isn't one.
+func (s *Server) loadProfileByName(w http.ResponseWriter, r *http.Request) (*agent.Profile, bool) {
+	name := chi.URLParam(r, "name")
+	if !validProfileName(name) {
+		writeError(w, http.StatusNotFound, "profile not found")
+		return nil, false
+	}
+	p, err := agent.LoadProfile(s.profilePath(name))
+	if errors.Is(err, fs.ErrNotExist) {
+		writeError(w, http.StatusNotFound, "profile not found")
+		return nil, false
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return nil, false
+	}
+	p.Name = name
+	return p, true
+}
+
+func (s *Server) handleGetProfile(w http.ResponseWriter, r *http.Request) {
+	if p, ok := s.loadProfileByName(w, r); ok {
+		writeJSON(w, http.StatusOK, p)
+	}
+}
+
+// handleCreateProfile saves a new profile, refusing to replace one.
+func (s *Server) handleCreateProfile(w http.ResponseWriter, r *http.Request) {
+	var p agent.Profile
+	if err := decodeJSON(r, &p); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid JSON: "+err.Error())
+		return
+	}
+	if err := s.validateProfile(&p); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if _, err := os.Stat(s.profilePath(p.Name)); err == nil {
+		writeError(w, http.StatusConflict, fmt.Sprintf("profile %s already exists", p.Name))
+		return
+	}
+	if err := agent.SaveProfile(s.profilePath(p.Name), &p); err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusCreated, p)
+}
+
+// handleUpdateProfile replaces a profile. Sessions using it pick up the
+// change when their agent is next loaded, such as after a restart or a
+// PATCH that sets their profile.
+func (s *Server) handleUpdateProfile(w http.ResponseWriter, r *http.Request) {
+	old, ok := s.loadProfileByName(w, r)
+	if !ok {
+		return
+	}
+	var p agent.Profile
+	if err := decodeJSON(r, &p); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid JSON: "+err.Error())
+		return
+	}
+	if p.Name == "" {
+		p.Name = old.Name
+	}
+	if p.Name != old.Name {
+		writeError(w, http.StatusBadRequest, "profiles can't be renamed")
+		return
+	}
+	if err := s.validateProfile(&p); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err := agent.SaveProfile(s.profilePath(p.Name), &p); err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, p)
+}
+
+// handleDeleteProfile deletes a profile no session uses.
+func (s *Server) handleDeleteProfile(w http.ResponseWriter, r *http.Request) {
+	p, ok := s.loadProfileByName(w, r)
+	if !ok {
+		return
+	}
+	n, err := s.store.CountSessions(r.Context(), storage.SessionListOptions{Profile: p.Name, All: true})
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if n > 0 {
+		writeError(w, http.StatusConflict, fmt.Sprintf("profile %s is used by %d sessions", p.Name, n))
+		return
+	}
+	if err := os.Remove(s.profilePath(p.Name)); err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
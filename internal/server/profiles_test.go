@@ -0,0 +1,117 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/michaelbrown/forge/internal/agent"
+	"github.com/michaelbrown/forge/internal/llm"
+	"github.com/michaelbrown/forge/internal/storage"
+)
+
+// fakeToolServer offers tools that echo their arguments.
+type fakeToolServer struct {
+	tools []string
+}
+
+func (f *fakeToolServer) ToolDefs() []llm.ToolDef {
+	var defs []llm.ToolDef
+	for _, name := range f.tools {
+		defs = append(defs, llm.ToolDef{Name: name, Description: "Echoes its arguments."})
+	}
+	return defs
+}
+
+func (f *fakeToolServer) ToolNames() []string { return f.tools }
+
+func (f *fakeToolServer) CallTool(ctx context.Context, name string, args map[string]any) (string, error) {
+	data, _ := json.Marshal(args)
+	return name + " " + string(data), nil
+}
+
+func (f *fakeToolServer) Close() {}
+
+func TestProfiles(t *testing.T) {
+	srv := newTestServer(t)
+	srv.config().Agent.ProfilesDir = filepath.Join(t.TempDir(), "profiles")
+	if err := srv.registry.Add("fake", &fakeToolServer{tools: []string{"echo"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	do := func(method, url, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		srv.router.ServeHTTP(w, httptest.NewRequest(method, url, strings.NewReader(body)))
+		return w
+	}
+
+	w := do("POST", "/api/profiles", `{"name":"coder","provider":"claude","system_prompt":"You write Go.","tools":["echo"],"max_iterations":5}`)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("create: expected 201, got %d: %s", w.Code, w.Body.String())
+	}
+	saved, err := agent.LoadProfile(filepath.Join(srv.config().Agent.ProfilesDir, "coder.yaml"))
+	if err != nil || saved.SystemPrompt != "You write Go." || saved.MaxIter != 5 {
+		t.Fatalf("saved profile %+v, err %v", saved, err)
+	}
+
+	for _, tt := range []struct {
+		name string
+		body string
+		want int
+	}{
+		{"duplicate", `{"name":"coder"}`, http.StatusConflict},
+		{"bad name", `{"name":"../etc"}`, http.StatusBadRequest},
+		{"no name", `{"system_prompt":"hi"}`, http.StatusBadRequest},
+		{"unknown provider", `{"name":"x","provider":"nope"}`, http.StatusBadRequest},
+		{"unknown tool", `{"name":"x","tools":["nope"]}`, http.StatusBadRequest},
+		{"negative iterations", `{"name":"x","max_iterations":-1}`, http.StatusBadRequest},
+	} {
+		if w := do("POST", "/api/profiles", tt.body); w.Code != tt.want {
+			t.Errorf("%s: expected %d, got %d: %s", tt.name, tt.want, w.Code, w.Body.String())
+		}
+	}
+
+	w = do("PUT", "/api/profiles/coder", `{"system_prompt":"You write Rust."}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("update: expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if w := do("PUT", "/api/profiles/coder", `{"name":"other"}`); w.Code != http.StatusBadRequest {
+		t.Errorf("rename: expected 400, got %d", w.Code)
+	}
+	if w := do("PUT", "/api/profiles/nope", `{}`); w.Code != http.StatusNotFound {
+		t.Errorf("update missing: expected 404, got %d", w.Code)
+	}
+
+	w = do("GET", "/api/profiles/coder", "")
+	var got agent.Profile
+	json.NewDecoder(w.Body).Decode(&got)
+	if got.Name != "coder" || got.SystemPrompt != "You write Rust." || got.Provider != "" {
+		t.Errorf("get: %+v", got)
+	}
+
+	os.WriteFile(filepath.Join(srv.config().Agent.ProfilesDir, "broken.yaml"), []byte("tools: ["), 0o644)
+	w = do("GET", "/api/profiles", "")
+	var list []agent.Profile
+	json.NewDecoder(w.Body).Decode(&list)
+	if len(list) != 1 || list[0].Name != "coder" {
+		t.Errorf("list: %+v", list)
+	}
+
+	// A profile in use can't be deleted.
+	srv.store.CreateSession(context.Background(), &storage.Session{ID: "p1", Status: storage.StatusActive, Profile: "coder"})
+	if w := do("DELETE", "/api/profiles/coder", ""); w.Code != http.StatusConflict {
+		t.Errorf("delete in use: expected 409, got %d", w.Code)
+	}
+	srv.store.DeleteSession(context.Background(), "p1")
+	if w := do("DELETE", "/api/profiles/coder", ""); w.Code != http.StatusNoContent {
+		t.Errorf("delete: expected 204, got %d: %s", w.Code, w.Body.String())
+	}
+	if w := do("GET", "/api/profiles/coder", ""); w.Code != http.StatusNotFound {
+		t.Errorf("get deleted: expected 404, got %d", w.Code)
+	}
+}
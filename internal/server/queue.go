@@ -0,0 +1,207 @@
+package server
+
+import (
+	"context"
+	"errors"
+
+	"github.com/michaelbrown/forge/internal/llm"
+)
+
+// A session's agent runs one message at a time. Messages that arrive while
+// it's busy wait in a bounded queue and run in the order they came, however
+// they were sent.
+
+// maxQueuedTurns is how many messages may wait behind a session's turn in
+// progress.
+const maxQueuedTurns = 8
+
+var (
+	errSessionBusy   = errors.New("session is busy")
+	errQueueFull     = errors.New("too many messages waiting")
+	errSessionClosed = errors.New("session closed")
+	errDraining      = errors.New("server is draining; try again later")
+)
+
+// turn is one message's use of a session's agent, from joining the queue
+// until its messages are saved.
+type turn struct {
+	as    *ActiveSession
+	ready chan struct{} // closed when the turn may run or can't
+	err   error         // why the turn can't run, set before ready closes
+
+	cancel   context.CancelFunc // interrupts the turn once started
+	ended    chan struct{}      // closed by end
+	messages []llm.Message      // the turn's messages, set before ended closes
+}
+
+// enqueue joins the session's queue and returns the turn with its position:
+// how many turns are ahead of it, 0 if it may run now. Unless wait is true,
+// it returns errSessionBusy instead of queueing.
+func (as *ActiveSession) enqueue(wait bool) (*turn, int, error) {
+	as.queueMu.Lock()
+	defer as.queueMu.Unlock()
+
+	if as.closed {
+		return nil, 0, errSessionClosed
+	}
+	if as.draining {
+		return nil, 0, errDraining
+	}
+	t := &turn{as: as, ready: make(chan struct{}), ended: make(chan struct{})}
+	if as.running == nil {
+		as.running = t
+		close(t.ready)
+		return t, 0, nil
+	}
+	position := len(as.queue) + 1
+	if !wait {
+		return nil, position, errSessionBusy
+	}
+	if len(as.queue) >= maxQueuedTurns {
+		return nil, position, errQueueFull
+	}
+	as.queue = append(as.queue, t)
+	as.signal()
+	return t, position, nil
+}
+
+// signal wakes everything watching the queue. The caller must hold queueMu.
+func (as *ActiveSession) signal() {
+	if as.moved != nil {
+		close(as.moved)
+	}
+	as.moved = make(chan struct{})
+}
+
+// position returns how many turns are ahead of t, or 0 if t isn't queued.
+// The caller must hold queueMu.
+func (as *ActiveSession) position(t *turn) int {
+	for i, q := range as.queue {
+		if q == t {
+			return i + 1
+		}
+	}
+	return 0
+}
+
+// next hands the agent to the first queued turn. The caller must hold
+// queueMu.
+func (as *ActiveSession) next() {
+	as.running = nil
+	if len(as.queue) > 0 {
+		as.running = as.queue[0]
+		as.queue = as.queue[1:]
+		close(as.running.ready)
+	}
+	as.signal()
+}
+
+// wait blocks until it's t's turn and returns holding the session's mu,
+// calling notify, if it's not nil, with t's position whenever it changes.
+// If ctx is done first, t leaves the queue and wait returns ctx's error.
+func (t *turn) wait(ctx context.Context, notify func(position int)) error {
+	as := t.as
+	last := 0
+	for {
+		as.queueMu.Lock()
+		position, moved := as.position(t), as.moved
+		as.queueMu.Unlock()
+		if position == 0 {
+			break
+		}
+		if position != last && notify != nil {
+			notify(position)
+		}
+		last = position
+
+		select {
+		case <-moved:
+		case <-ctx.Done():
+			t.leave()
+			return ctx.Err()
+		}
+	}
+
+	<-t.ready
+	if t.err != nil {
+		return t.err
+	}
+	as.mu.Lock()
+	return nil
+}
+
+// leave gives up t's place, passing the agent on if it was t's turn.
+func (t *turn) leave() {
+	as := t.as
+	as.queueMu.Lock()
+	defer as.queueMu.Unlock()
+	if i := as.position(t); i > 0 {
+		as.queue = append(as.queue[:i-1], as.queue[i:]...)
+		as.signal()
+	} else if as.running == t {
+		as.next()
+	}
+}
+
+// start records cancel as the way to interrupt t.
+func (t *turn) start(cancel context.CancelFunc) {
+	t.as.queueMu.Lock()
+	t.cancel = cancel
+	t.as.queueMu.Unlock()
+}
+
+// end finishes t, which must hold the session's mu, and passes the agent on.
+// Callers save the agent's history before ending the turn.
+func (t *turn) end() {
+	as := t.as
+	as.queueMu.Lock()
+	cancel := t.cancel
+	t.cancel = nil
+	as.queueMu.Unlock()
+	if cancel != nil {
+		cancel()
+	}
+
+	t.messages = lastTurn(as.Agent.History())
+	close(t.ended)
+	as.mu.Unlock()
+
+	as.queueMu.Lock()
+	as.next()
+	as.queueMu.Unlock()
+}
+
+// waitIdle waits until the session has no turn running or queued, or ctx
+// is done.
+func (as *ActiveSession) waitIdle(ctx context.Context) error {
+	for {
+		as.queueMu.Lock()
+		idle := as.running == nil && len(as.queue) == 0
+		if as.moved == nil {
+			as.signal()
+		}
+		moved := as.moved
+		as.queueMu.Unlock()
+		if idle {
+			return nil
+		}
+		select {
+		case <-moved:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// cancelTurn interrupts the turn in progress and returns it, or returns nil
+// if no turn has started.
+func (as *ActiveSession) cancelTurn() *turn {
+	as.queueMu.Lock()
+	defer as.queueMu.Unlock()
+	t := as.running
+	if t == nil || t.cancel == nil {
+		return nil
+	}
+	t.cancel()
+	return t
+}
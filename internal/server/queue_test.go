@@ -0,0 +1,106 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/michaelbrown/forge/internal/agent"
+	"github.com/michaelbrown/forge/internal/tools"
+)
+
+func TestTurnQueue(t *testing.T) {
+	as := &ActiveSession{Agent: agent.New(nil, tools.NewRegistry(), 1)}
+	ctx := context.Background()
+
+	first, position, err := as.enqueue(false)
+	if err != nil || position != 0 {
+		t.Fatalf("first turn: position %d, err %v", position, err)
+	}
+	if err := first.wait(ctx, nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, position, err := as.enqueue(false); !errors.Is(err, errSessionBusy) || position != 1 {
+		t.Errorf("busy without waiting: position %d, err %v", position, err)
+	}
+
+	// Queue turns behind the first and check they run in order.
+	order := make(chan int, maxQueuedTurns)
+	var turns []*turn
+	for i := 1; i <= maxQueuedTurns; i++ {
+		tn, position, err := as.enqueue(true)
+		if err != nil || position != i {
+			t.Fatalf("turn %d: position %d, err %v", i, position, err)
+		}
+		turns = append(turns, tn)
+	}
+	if _, _, err := as.enqueue(true); !errors.Is(err, errQueueFull) {
+		t.Errorf("full queue: err %v", err)
+	}
+
+	// The second queued turn gives up; the one behind it moves up.
+	leaving, cancel := context.WithCancel(ctx)
+	positions := make(chan int, maxQueuedTurns)
+	go func() {
+		turns[2].wait(ctx, func(position int) { positions <- position })
+		order <- 2
+		turns[2].end()
+	}()
+	if got := <-positions; got != 3 {
+		t.Errorf("initial position %d, want 3", got)
+	}
+	cancel()
+	if err := turns[1].wait(leaving, nil); !errors.Is(err, context.Canceled) {
+		t.Errorf("abandoned wait: err %v", err)
+	}
+	if got := <-positions; got != 2 {
+		t.Errorf("position after the turn ahead left = %d, want 2", got)
+	}
+
+	for i, tn := range turns {
+		if i == 1 || i == 2 {
+			continue
+		}
+		go func() {
+			if err := tn.wait(ctx, nil); err != nil {
+				order <- -1
+				return
+			}
+			order <- i
+			tn.end()
+		}()
+	}
+	first.end()
+	for _, want := range []int{0, 2, 3, 4, 5, 6, 7} {
+		select {
+		case got := <-order:
+			if got != want {
+				t.Fatalf("turn %d ran, want %d", got, want)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("turn %d never ran", want)
+		}
+	}
+}
+
+func TestTurnQueue_Close(t *testing.T) {
+	as := &ActiveSession{Agent: agent.New(nil, tools.NewRegistry(), 1)}
+	ctx := context.Background()
+
+	first, _, _ := as.enqueue(true)
+	first.wait(ctx, nil)
+	running, cancel := context.WithCancel(ctx)
+	first.start(cancel)
+	queued, _, _ := as.enqueue(true)
+
+	as.close()
+	<-running.Done()
+	if err := queued.wait(ctx, nil); !errors.Is(err, errSessionClosed) {
+		t.Errorf("queued turn after close: err %v", err)
+	}
+	if _, _, err := as.enqueue(true); !errors.Is(err, errSessionClosed) {
+		t.Errorf("enqueue after close: err %v", err)
+	}
+	first.end()
+}
@@ -0,0 +1,201 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/michaelbrown/forge/internal/config"
+	"github.com/michaelbrown/forge/internal/llm"
+	"github.com/michaelbrown/forge/internal/logging"
+)
+
+// Each client gets server.rate_limit's allowance, or its credential's own:
+// a number of requests per minute, and a number of LLM tokens per UTC day
+// for the messages it sends. Past either, it gets 429s with a Retry-After
+// header. Usage is kept in memory, so a restart starts every client afresh.
+
+// client is who an API request came from.
+type client struct {
+	id     string // "key:NAME", "user:NAME", or "ip:ADDR"
+	scope  string // its credential's scope; empty without credentials
+	limits config.RateLimitConfig
+}
+
+type clientKey struct{}
+
+func withClient(ctx context.Context, c client) context.Context {
+	return context.WithValue(ctx, clientKey{}, c)
+}
+
+// clientFrom returns the client ctx's request came from, if it has one.
+func clientFrom(ctx context.Context) (client, bool) {
+	c, ok := ctx.Value(clientKey{}).(client)
+	return c, ok
+}
+
+// rateLimit refuses requests from clients over their requests per minute.
+// It must follow authenticate, which names clients that send credentials;
+// others are known by IP address.
+func (s *Server) rateLimit(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c, ok := clientFrom(r.Context())
+		if !ok {
+			host, _, err := net.SplitHostPort(r.RemoteAddr)
+			if err != nil {
+				host = r.RemoteAddr
+			}
+			c = client{id: "ip:" + host, limits: s.config().Server.RateLimit}
+			r = r.WithContext(withClient(r.Context(), c))
+		}
+		if wait, ok := s.limiter.allow(c); !ok {
+			tooManyRequests(w, wait, "rate limit exceeded")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// tokenBudget refuses requests from clients that have used up their daily
+// tokens. It goes on the routes that run the agent, after rateLimit.
+func (s *Server) tokenBudget(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if c, ok := clientFrom(r.Context()); ok {
+			if wait, ok := s.limiter.budgetLeft(c); !ok {
+				tooManyRequests(w, wait, "daily token budget used up")
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// checkMessage applies the limits of the client ctx's request came from to
+// a message sent over its WebSocket, as rateLimit and tokenBudget do to
+// requests.
+func (s *Server) checkMessage(ctx context.Context) error {
+	c, ok := clientFrom(ctx)
+	if !ok {
+		return nil
+	}
+	if _, ok := s.limiter.allow(c); !ok {
+		return errors.New("rate limit exceeded")
+	}
+	if _, ok := s.limiter.budgetLeft(c); !ok {
+		return errors.New("daily token budget used up")
+	}
+	return nil
+}
+
+// tooManyRequests writes a 429 telling the client to wait.
+func tooManyRequests(w http.ResponseWriter, wait time.Duration, msg string) {
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+	writeError(w, http.StatusTooManyRequests, msg)
+}
+
+// chargeTurn counts the tokens the turn that ends history used against
+// the budget of the client ctx's request came from, if any.
+func (s *Server) chargeTurn(ctx context.Context, history []llm.Message) {
+	c, ok := clientFrom(ctx)
+	if !ok {
+		return
+	}
+	if n := turnUsage(history).TotalTokens; n > 0 {
+		s.limiter.charge(c, n)
+		logging.For("http").DebugContext(ctx, "charged tokens", "client", c.id, "tokens", n)
+	}
+}
+
+// rateLimiter tracks each client's requests and tokens.
+type rateLimiter struct {
+	mu      sync.Mutex
+	clients map[string]*clientUsage
+	pruned  time.Time
+}
+
+type clientUsage struct {
+	requests float64 // requests the client may make now
+	filled   time.Time
+	day      string // UTC date tokens were used on
+	tokens   int
+}
+
+func newRateLimiter() *rateLimiter {
+	return &rateLimiter{clients: make(map[string]*clientUsage)}
+}
+
+// usage returns c's usage as of now, with its allowance of requests
+// refilled and yesterday's tokens forgotten. l.mu must be held.
+func (l *rateLimiter) usage(c client, now time.Time) *clientUsage {
+	if now.Sub(l.pruned) > time.Minute {
+		// Forget clients whose usage no longer matters.
+		for id, u := range l.clients {
+			if now.Sub(u.filled) > time.Minute && (u.tokens == 0 || u.day != day(now)) {
+				delete(l.clients, id)
+			}
+		}
+		l.pruned = now
+	}
+	u, ok := l.clients[c.id]
+	if !ok {
+		u = &clientUsage{requests: float64(c.limits.RequestsPerMinute), filled: now}
+		l.clients[c.id] = u
+	}
+	if rpm := float64(c.limits.RequestsPerMinute); rpm > 0 {
+		// Allowances refill steadily, up to a minute's worth.
+		u.requests = min(rpm, u.requests+now.Sub(u.filled).Minutes()*rpm)
+	}
+	u.filled = now
+	if u.day != day(now) {
+		u.day, u.tokens = day(now), 0
+	}
+	return u
+}
+
+// allow counts a request from c, or reports how long c must wait before
+// making one.
+func (l *rateLimiter) allow(c client) (time.Duration, bool) {
+	if c.limits.RequestsPerMinute <= 0 {
+		return 0, true
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	u := l.usage(c, time.Now())
+	if u.requests < 1 {
+		return time.Duration((1 - u.requests) / float64(c.limits.RequestsPerMinute) * float64(time.Minute)), false
+	}
+	u.requests--
+	return 0, true
+}
+
+// budgetLeft reports whether c has tokens left today, or else how long it
+// is until tomorrow.
+func (l *rateLimiter) budgetLeft(c client) (time.Duration, bool) {
+	if c.limits.DailyTokens <= 0 {
+		return 0, true
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := time.Now()
+	if l.usage(c, now).tokens < c.limits.DailyTokens {
+		return 0, true
+	}
+	y, m, d := now.UTC().Date()
+	return time.Date(y, m, d+1, 0, 0, 0, 0, time.UTC).Sub(now), false
+}
+
+// charge counts tokens c used.
+func (l *rateLimiter) charge(c client, tokens int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.usage(c, time.Now()).tokens += tokens
+}
+
+func day(t time.Time) string {
+	return t.UTC().Format(time.DateOnly)
+}
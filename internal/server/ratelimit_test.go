@@ -0,0 +1,94 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/michaelbrown/forge/internal/config"
+)
+
+func TestRateLimit(t *testing.T) {
+	srv := newTestServer(t)
+	srv.config().Server.RateLimit.RequestsPerMinute = 2
+
+	get := func(remoteAddr, key string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/api/sessions", nil)
+		req.RemoteAddr = remoteAddr
+		if key != "" {
+			req.Header.Set("X-API-Key", key)
+		}
+		w := httptest.NewRecorder()
+		srv.router.ServeHTTP(w, req)
+		return w
+	}
+
+	for i := range 2 {
+		if w := get("192.0.2.1:1234", ""); w.Code != http.StatusOK {
+			t.Fatalf("request %d: expected 200, got %d", i+1, w.Code)
+		}
+	}
+	w := get("192.0.2.1:5678", "")
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("third request: expected 429, got %d", w.Code)
+	}
+	if after, err := strconv.Atoi(w.Header().Get("Retry-After")); err != nil || after < 1 || after > 30 {
+		t.Errorf("Retry-After = %q", w.Header().Get("Retry-After"))
+	}
+	if w := get("192.0.2.2:1234", ""); w.Code != http.StatusOK {
+		t.Errorf("another IP: expected 200, got %d", w.Code)
+	}
+
+	// Keys are limited apart from their IP, by their own limits if they
+	// have them.
+	srv.config().Server.Auth.APIKeys = []config.APIKey{
+		{Name: "ci", Key: "ci-key", Scope: config.ScopeFull, RateLimit: &config.RateLimitConfig{}},
+		{Name: "dash", Key: "dash-key", Scope: config.ScopeRead},
+	}
+	for i := range 5 {
+		if w := get("192.0.2.1:1234", "ci-key"); w.Code != http.StatusOK {
+			t.Fatalf("unlimited key, request %d: expected 200, got %d", i+1, w.Code)
+		}
+	}
+	for i := range 2 {
+		if w := get("192.0.2.1:1234", "dash-key"); w.Code != http.StatusOK {
+			t.Fatalf("limited key, request %d: expected 200, got %d", i+1, w.Code)
+		}
+	}
+	if w := get("192.0.2.2:1234", "dash-key"); w.Code != http.StatusTooManyRequests {
+		t.Errorf("limited key from another IP: expected 429, got %d", w.Code)
+	}
+}
+
+func TestTokenBudget(t *testing.T) {
+	srv := newChatTestServer(t)
+	srv.config().Server.RateLimit.DailyTokens = 10
+
+	chat := func() *httptest.ResponseRecorder {
+		body := `{"model":"helper","messages":[{"role":"user","content":"hi"}]}`
+		w := httptest.NewRecorder()
+		srv.router.ServeHTTP(w, httptest.NewRequest("POST", "/v1/chat/completions", strings.NewReader(body)))
+		return w
+	}
+
+	// The first reply uses 15 tokens, over the budget.
+	if w := chat(); w.Code != http.StatusOK {
+		t.Fatalf("first request: expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	w := chat()
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("second request: expected 429, got %d", w.Code)
+	}
+	if after, err := strconv.Atoi(w.Header().Get("Retry-After")); err != nil || after < 1 || after > 24*60*60 {
+		t.Errorf("Retry-After = %q", w.Header().Get("Retry-After"))
+	}
+
+	// Requests that don't run the agent still work.
+	w = httptest.NewRecorder()
+	srv.router.ServeHTTP(w, httptest.NewRequest("GET", "/v1/models", nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("models: expected 200, got %d", w.Code)
+	}
+}
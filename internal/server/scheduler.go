@@ -0,0 +1,130 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/michaelbrown/forge/internal/config"
+	"github.com/michaelbrown/forge/internal/logging"
+	"github.com/michaelbrown/forge/internal/storage"
+	"github.com/michaelbrown/forge/internal/tools"
+)
+
+// schedulerInterval is how often the scheduler looks for due tasks.
+const schedulerInterval = 15 * time.Second
+
+// runScheduler runs scheduled tasks as they come due, until ctx is done.
+// Tasks left running by a previous server were interrupted mid-run; they
+// are marked failed rather than run twice.
+func (s *Server) runScheduler(ctx context.Context) {
+	stale, err := s.store.ListTasks(ctx, storage.TaskListOptions{Status: storage.TaskRunning, Limit: 1000})
+	if err != nil {
+		logging.For("scheduler").Error("listing interrupted tasks", "error", err)
+	}
+	for _, t := range stale {
+		s.store.FinishTask(ctx, t.ID, storage.TaskFailed, "interrupted by a server restart")
+	}
+
+	ticker := time.NewTicker(schedulerInterval)
+	defer ticker.Stop()
+	for {
+		s.runDueTasks(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// runDueTasks claims the tasks that are due and starts each in the
+// background. While the server drains, due tasks wait.
+func (s *Server) runDueTasks(ctx context.Context) {
+	if s.sessions.Draining() {
+		return
+	}
+	due, err := s.store.ClaimDueTasks(ctx, time.Now())
+	if err != nil {
+		logging.For("scheduler").Error("claiming due tasks", "error", err)
+		return
+	}
+	for _, t := range due {
+		s.tasks.Add(1)
+		go func() {
+			defer s.tasks.Done()
+			s.runTask(ctx, t)
+		}()
+	}
+}
+
+// runTask sends a task's prompt to its session, or to a new session if it
+// has none or the session was deleted, and records the outcome.
+func (s *Server) runTask(ctx context.Context, t storage.Task) {
+	response, err := s.runTaskPrompt(ctx, t)
+	status, result := storage.TaskDone, response
+	if err != nil {
+		status, result = storage.TaskFailed, err.Error()
+		logging.For("scheduler").Warn("task failed", "task", t.ID, "error", err)
+	}
+	// Record the outcome even when ctx was cancelled by a shutdown.
+	if err := s.store.FinishTask(context.WithoutCancel(ctx), t.ID, status, result); err != nil {
+		logging.For("scheduler").Error("recording task outcome", "task", t.ID, "error", err)
+	}
+}
+
+func (s *Server) runTaskPrompt(ctx context.Context, t storage.Task) (string, error) {
+	var sess *storage.Session
+	if t.SessionID != "" {
+		sess, _ = s.store.GetSession(ctx, t.SessionID)
+	}
+	cfg := s.config()
+	if sess == nil {
+		provider, err := cfg.Provider(cfg.DefaultProvider)
+		if err != nil {
+			return "", err
+		}
+		sess = &storage.Session{
+			ID:       uuid.New().String(),
+			Title:    "Scheduled: " + t.Title,
+			Status:   storage.StatusActive,
+			Provider: cfg.DefaultProvider,
+			Model:    provider.Models["default"],
+		}
+		if err := s.store.CreateSession(ctx, sess); err != nil {
+			return "", err
+		}
+		s.notify(config.EventSessionCreated, sess, nil)
+	}
+
+	as, err := s.sessions.GetOrCreate(ctx, sess, cfg, s.store, s.registry)
+	if err != nil {
+		return "", fmt.Errorf("initializing agent: %w", err)
+	}
+	queued, _, err := as.enqueue(true)
+	if err != nil {
+		return "", err
+	}
+	if err := queued.wait(ctx, nil); err != nil {
+		return "", err
+	}
+	defer queued.end()
+
+	runCtx, cancel := context.WithCancel(tools.WithSession(ctx, sess.ID))
+	queued.start(cancel)
+
+	prompt := fmt.Sprintf("[Scheduled task %d, set for %s: %s]\n\n%s",
+		t.ID, t.RunAt.Local().Format("Mon 2006-01-02 15:04 MST"), t.Title, t.Prompt)
+	response, err := as.Agent.Run(runCtx, prompt)
+	s.turnFinished(sess, response, err, runCtx.Err() != nil)
+
+	if saveErr := storage.SaveHistory(context.WithoutCancel(ctx), s.store, sess.ID, as.Agent); saveErr != nil && err == nil {
+		err = fmt.Errorf("saving messages: %w", saveErr)
+	}
+	if err != nil {
+		return "", fmt.Errorf("agent error: %w", err)
+	}
+	return response, nil
+}
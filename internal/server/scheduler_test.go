@@ -0,0 +1,155 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/michaelbrown/forge/internal/config"
+	"github.com/michaelbrown/forge/internal/storage"
+	"github.com/michaelbrown/forge/internal/storage/sqlite"
+	"github.com/michaelbrown/forge/internal/tools"
+)
+
+// newSchedulerTestServer creates a Server whose default provider is a fake
+// chat completions endpoint that replies "done: <last user message>".
+func newSchedulerTestServer(t *testing.T) (*Server, *[]string) {
+	t.Helper()
+
+	var mu sync.Mutex
+	var prompts []string
+	llm := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Messages []struct {
+				Role    string `json:"role"`
+				Content string `json:"content"`
+			} `json:"messages"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		last := req.Messages[len(req.Messages)-1].Content
+		mu.Lock()
+		prompts = append(prompts, last)
+		mu.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]any{
+			"id": "1", "object": "chat.completion", "model": "fake",
+			"choices": []map[string]any{{
+				"index":         0,
+				"finish_reason": "stop",
+				"message":       map[string]any{"role": "assistant", "content": "done: " + strings.SplitN(last, "\n\n", 2)[1]},
+			}},
+		})
+	}))
+	t.Cleanup(llm.Close)
+
+	// Tasks run concurrently, so use a file: each connection to :memory:
+	// would get its own empty database.
+	store, err := sqlite.Open(filepath.Join(t.TempDir(), "forge.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { store.Close() })
+
+	cfg := &config.Config{
+		Providers: map[string]config.ProviderConfig{
+			"fake": {BaseURL: llm.URL + "/", APIKey: "x", Models: map[string]string{"default": "fake"}},
+		},
+		DefaultProvider: "fake",
+		Agent:           config.AgentConfig{MaxIterations: 5, ContextMaxTokens: 4000},
+	}
+	registry := tools.NewRegistry()
+	t.Cleanup(func() { registry.Close() })
+	return New(cfg, store, registry), &prompts
+}
+
+func TestSchedulerRunsDueTasks(t *testing.T) {
+	srv, prompts := newSchedulerTestServer(t)
+	ctx := context.Background()
+
+	sess := &storage.Session{ID: "sess-1", Title: "deploy", Status: storage.StatusActive, Provider: "fake", Model: "fake"}
+	if err := srv.store.CreateSession(ctx, sess); err != nil {
+		t.Fatal(err)
+	}
+	followUp := &storage.Task{SessionID: sess.ID, Title: "check deploy", Prompt: "Check whether the deploy finished.", RunAt: time.Now().Add(-time.Minute)}
+	orphan := &storage.Task{SessionID: "deleted", Title: "report", Prompt: "Write the weekly report.", RunAt: time.Now().Add(-time.Second)}
+	later := &storage.Task{SessionID: sess.ID, Title: "later", Prompt: "Not yet.", RunAt: time.Now().Add(time.Hour)}
+	for _, task := range []*storage.Task{followUp, orphan, later} {
+		if err := srv.store.CreateTask(ctx, task); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	srv.runDueTasks(ctx)
+	srv.tasks.Wait()
+
+	got, err := srv.store.GetTask(ctx, followUp.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Status != storage.TaskDone || got.Result != "done: Check whether the deploy finished." {
+		t.Errorf("follow-up task = %+v", got)
+	}
+	msgs, err := srv.store.LoadMessages(ctx, sess.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(msgs) < 2 || !strings.HasPrefix(msgs[len(msgs)-2].Content, "[Scheduled task 1, set for ") {
+		t.Errorf("session history = %+v", msgs)
+	}
+
+	// A task whose session is gone runs in a new one.
+	if got, _ := srv.store.GetTask(ctx, orphan.ID); got.Status != storage.TaskDone {
+		t.Errorf("orphan task = %+v", got)
+	}
+	sessions, _ := srv.store.ListSessions(ctx, storage.SessionListOptions{})
+	if len(sessions) != 2 || !(sessions[0].Title == "Scheduled: report" || sessions[1].Title == "Scheduled: report") {
+		t.Errorf("sessions = %+v", sessions)
+	}
+
+	if got, _ := srv.store.GetTask(ctx, later.ID); got.Status != storage.TaskPending {
+		t.Errorf("task ran early: %+v", got)
+	}
+	if len(*prompts) != 2 {
+		t.Errorf("LLM saw %d prompts, want 2", len(*prompts))
+	}
+}
+
+func TestSchedulerFailsInterruptedTasks(t *testing.T) {
+	srv, _ := newSchedulerTestServer(t)
+	ctx, cancel := context.WithCancel(context.Background())
+
+	task := &storage.Task{Title: "x", Prompt: "x", RunAt: time.Now().Add(time.Hour)}
+	if err := srv.store.CreateTask(ctx, task); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := srv.store.ClaimDueTasks(ctx, task.RunAt); err != nil {
+		t.Fatal(err)
+	}
+
+	go srv.runScheduler(ctx)
+	defer cancel()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		got, err := srv.store.GetTask(context.Background(), task.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.Status != storage.TaskRunning {
+			if got.Status != storage.TaskFailed || got.Result != "interrupted by a server restart" {
+				t.Errorf("interrupted task = %+v", got)
+			}
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("interrupted task was never marked failed")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
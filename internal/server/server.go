@@ -2,71 +2,160 @@ package server
 
 import (
 	"context"
-	"fmt"
-	"log"
 	"net/http"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
 
 	"github.com/michaelbrown/forge/internal/config"
+	"github.com/michaelbrown/forge/internal/logging"
 	"github.com/michaelbrown/forge/internal/storage"
 	"github.com/michaelbrown/forge/internal/tools"
 )
 
 // Server is the HTTP server for the Forge web API.
 type Server struct {
-	cfg      *config.Config
-	store    storage.Store
-	registry *tools.Registry
-	sessions *SessionManager
-	router   chi.Router
-	http     *http.Server
+	cfg       atomic.Pointer[config.Config] // replaced whole on reload; read it through config
+	store     storage.Store
+	registry  *tools.Registry
+	sessions  *SessionManager
+	artifacts *storage.Artifacts
+	limiter   *rateLimiter
+	router    chi.Router
+	http      *http.Server
+
+	stopBackground context.CancelFunc // stops the scheduler, janitor, and config watcher
+	tasks          sync.WaitGroup     // scheduled tasks in progress
+	deliveries     sync.WaitGroup     // webhook deliveries in progress
+	webhookBackoff time.Duration      // wait before retrying a webhook, doubling each time
+
+	providerMu     sync.Mutex // one round of provider checks at a time
+	providerChecks map[string]providerCheck
+
+	pongWait time.Duration // how long a WebSocket client may go without answering a ping
+
+	loadConfig  func() (*config.Config, error) // reads the config for reloads
+	reloadMu    sync.Mutex                     // one reload at a time
+	reloadHooks []func(*config.Config)
 }
 
 // New creates a new Server.
 func New(cfg *config.Config, store storage.Store, registry *tools.Registry) *Server {
 	s := &Server{
-		cfg:      cfg,
-		store:    store,
-		registry: registry,
-		sessions: NewSessionManager(),
-		router:   chi.NewRouter(),
+		store:     store,
+		registry:  registry,
+		sessions:  NewSessionManager(),
+		artifacts: storage.NewArtifacts(store, cfg.Storage.ArtifactsDir),
+		limiter:   newRateLimiter(),
+		router:    chi.NewRouter(),
+		pongWait:  wsPongWait,
+
+		loadConfig: config.Load,
+
+		webhookBackoff: time.Second,
+	}
+	s.cfg.Store(cfg)
+	if registry != nil && len(cfg.Server.Webhooks) > 0 {
+		registry.SetApprover(s.requestApproval)
 	}
 	s.setupRoutes()
 	return s
 }
 
+// config returns the config the server is running with. A reload publishes
+// a new one rather than changing this one, so callers needing several
+// settings to agree should read it once.
+func (s *Server) config() *config.Config {
+	return s.cfg.Load()
+}
+
 func (s *Server) setupRoutes() {
 	r := s.router
 
 	// Global middleware
-	r.Use(middleware.Logger)
-	r.Use(middleware.Recoverer)
 	r.Use(middleware.RequestID)
+	r.Use(logRequests)
+	r.Use(middleware.Recoverer)
 
 	// API routes
 	r.Route("/api", func(r chi.Router) {
 		r.Use(jsonContentType)
+		r.Use(s.cors)
+		r.Use(s.authenticate)
+		r.Use(s.rateLimit)
 
 		// Sessions
 		r.Get("/sessions", s.handleListSessions)
 		r.Post("/sessions", s.handleCreateSession)
+		r.Post("/sessions/import", s.handleImportSession)
 		r.Get("/sessions/{id}", s.handleGetSession)
 		r.Patch("/sessions/{id}", s.handleUpdateSession)
 		r.Delete("/sessions/{id}", s.handleDeleteSession)
+		r.Patch("/sessions/{id}/model", s.handleSwitchModel)
+		r.Post("/sessions/{id}/cancel", s.handleCancelSession)
+		r.Get("/sessions/{id}/export", s.handleExportSession)
 
 		// Messages
 		r.Get("/sessions/{id}/messages", s.handleGetMessages)
-		r.Post("/sessions/{id}/messages", s.handleSendMessage)
+		r.With(s.tokenBudget).Post("/sessions/{id}/messages", s.handleSendMessage)
+		r.With(s.tokenBudget).Post("/sessions/{id}/messages/stream", s.handleStreamMessage)
+		r.Get("/sessions/{id}/tool-calls", s.handleListToolCalls)
+
+		// Artifacts
+		r.Get("/sessions/{id}/artifacts", s.handleListArtifacts)
+		r.Get("/sessions/{id}/artifacts/{name}", s.handleGetArtifact)
+		r.Post("/sessions/{id}/attachments", s.handleUploadAttachments)
+
+		// Search
+		r.Get("/search", s.handleSearch)
 
 		// WebSocket (no JSON content-type)
-		r.Get("/sessions/{id}/ws", s.handleWebSocket)
+		r.With(s.tokenBudget).Get("/sessions/{id}/ws", s.handleWebSocket)
+
+		// Agent profiles
+		r.Get("/profiles", s.handleListProfiles)
+		r.Post("/profiles", s.handleCreateProfile)
+		r.Get("/profiles/{name}", s.handleGetProfile)
+		r.Put("/profiles/{name}", s.handleUpdateProfile)
+		r.Delete("/profiles/{name}", s.handleDeleteProfile)
 
 		// Providers & models
 		r.Get("/providers", s.handleListProviders)
 		r.Get("/models/{provider}", s.handleListModels)
+
+		// Tools and tool servers
+		r.Get("/tools", s.handleListTools)
+		r.Post("/tools/{name}/call", s.handleCallTool)
+		r.With(s.requireAdmin).Post("/tools/servers", s.handleRegisterToolServer)
+
+		// Stats
+		r.Get("/stats", s.handleUsageStats)
+		r.Get("/stats/tools", s.handleToolStats)
+
+		// Admin
+		r.Route("/admin", func(r chi.Router) {
+			r.Use(s.requireAdmin)
+			r.Post("/reload", s.handleReloadConfig)
+			r.Get("/tool-servers", s.handleToolServerHealth)
+			r.Get("/tool-servers/{name}/logs", s.handleToolServerLogs)
+			r.Post("/tool-servers/{name}/restart", s.handleRestartToolServer)
+			r.Post("/drain", s.handleDrain)
+			r.Delete("/drain", s.handleResume)
+		})
+	})
+
+	// OpenAI-compatible API, for OpenAI clients
+	r.Route("/v1", func(r chi.Router) {
+		r.Use(jsonContentType)
+		r.Use(s.cors)
+		r.Use(s.authenticate)
+		r.Use(s.rateLimit)
+
+		r.With(s.tokenBudget).Post("/chat/completions", s.handleChatCompletions)
+		r.Get("/models", s.handleListChatModels)
 	})
 
 	// SPA fallback
@@ -81,22 +170,70 @@ func jsonContentType(next http.Handler) http.Handler {
 	})
 }
 
-// Start begins listening on the given port.
-func (s *Server) Start(port int) error {
-	addr := fmt.Sprintf(":%d", port)
+// logRequests logs each request once it's handled, and tags its context
+// with the ID middleware.RequestID gave it so everything logged for it
+// carries the ID. An api_key in the query string is redacted.
+func logRequests(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := logging.WithRequestID(r.Context(), middleware.GetReqID(r.Context()))
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		start := time.Now()
+		defer func() {
+			attrs := []any{"method", r.Method, "path", r.URL.Path}
+			if q := r.URL.Query(); len(q) > 0 {
+				if q.Has("api_key") {
+					q.Set("api_key", "REDACTED")
+				}
+				attrs = append(attrs, "query", q.Encode())
+			}
+			attrs = append(attrs, "status", ww.Status(), "bytes", ww.BytesWritten(),
+				"duration", time.Since(start), "remote", r.RemoteAddr)
+			logging.For("http").InfoContext(ctx, "request", attrs...)
+		}()
+		next.ServeHTTP(ww, r.WithContext(ctx))
+	})
+}
+
+// Start begins listening on addr, a "host:port" address or
+// "unix:///path/to.sock", over TLS if server.tls sets it up, and starts
+// running scheduled tasks and the retention janitor, and watching the
+// config files for changes.
+func (s *Server) Start(addr string) error {
+	tlsCfg, err := s.tlsConfig()
+	if err != nil {
+		return err
+	}
+	ln, err := listen(addr)
+	if err != nil {
+		return err
+	}
 	s.http = &http.Server{
-		Addr:    addr,
-		Handler: s.router,
+		Handler:   s.router,
+		TLSConfig: tlsCfg,
 	}
 
-	log.Printf("Forge server starting on http://localhost%s", addr)
-	return s.http.ListenAndServe()
+	ctx, cancel := context.WithCancel(context.Background())
+	s.stopBackground = cancel
+	go s.runScheduler(ctx)
+	go s.runJanitor(ctx)
+	go s.watchConfig(ctx)
+
+	logging.For("server").Info("Forge server starting", "url", serverURL(addr, tlsCfg != nil))
+	if tlsCfg != nil {
+		return s.http.ServeTLS(ln, "", "")
+	}
+	return s.http.Serve(ln)
 }
 
 // Shutdown gracefully shuts down the server.
 func (s *Server) Shutdown(ctx context.Context) error {
-	log.Println("Shutting down server...")
+	logging.For("server").Info("Shutting down server")
+	if s.stopBackground != nil {
+		s.stopBackground()
+	}
 	s.sessions.CloseAll()
+	s.tasks.Wait()
+	s.deliveries.Wait()
 
 	shutdownCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
 	defer cancel()
@@ -3,33 +3,70 @@ package server
 import (
 	"context"
 	"fmt"
-	"path/filepath"
 	"sync"
 
 	"github.com/michaelbrown/forge/internal/agent"
 	"github.com/michaelbrown/forge/internal/config"
 	"github.com/michaelbrown/forge/internal/llm"
+	"github.com/michaelbrown/forge/internal/logging"
 	"github.com/michaelbrown/forge/internal/storage"
 	"github.com/michaelbrown/forge/internal/tools"
 )
 
 // ActiveSession tracks an in-memory agent for a session.
 type ActiveSession struct {
-	Agent  *agent.Agent
-	Cancel context.CancelFunc // cancels in-flight RunStreaming
-	mu     sync.Mutex         // one message at a time per session
+	Agent    *agent.Agent
+	Registry *tools.Registry // session-owned registry when tool isolation is on, else nil
+	mu       sync.Mutex      // held by the turn in progress; see queue.go
+
+	queueMu  sync.Mutex    // guards the fields below
+	running  *turn         // the turn holding, or about to take, mu
+	queue    []*turn       // turns waiting behind it, oldest first
+	moved    chan struct{} // closed and replaced whenever the queue changes
+	closed   bool
+	draining bool // new messages are turned away
+}
+
+// close cancels in-flight work, turns away queued messages, and shuts down
+// any session-owned tool servers.
+func (as *ActiveSession) close() {
+	as.queueMu.Lock()
+	as.closed = true
+	for _, t := range as.queue {
+		t.err = errSessionClosed
+		close(t.ready)
+	}
+	as.queue = nil
+	as.signal()
+	as.queueMu.Unlock()
+
+	as.cancelTurn()
+	if as.Registry != nil {
+		as.Registry.Close()
+	}
 }
 
 // SessionManager tracks which sessions have an active Agent in memory.
 type SessionManager struct {
 	mu       sync.RWMutex
 	sessions map[string]*ActiveSession
+	draining bool
+
+	// Turns outside any session, such as chat completions, are only
+	// counted, so Drain can wait for them too.
+	unsaved      int
+	unsavedMoved chan struct{} // closed and replaced whenever unsaved changes
+
+	streamMu sync.Mutex
+	streams  map[string]*eventStream // see stream.go
 }
 
 // NewSessionManager creates a new SessionManager.
 func NewSessionManager() *SessionManager {
 	return &SessionManager{
-		sessions: make(map[string]*ActiveSession),
+		sessions:     make(map[string]*ActiveSession),
+		unsavedMoved: make(chan struct{}),
+		streams:      make(map[string]*eventStream),
 	}
 }
 
@@ -57,40 +94,91 @@ func (sm *SessionManager) GetOrCreate(
 		return as, nil
 	}
 
+	// Load profile if specified
+	var profile *agent.Profile
+	if sess.Profile != "" {
+		var err error
+		if profile, err = loadProfile(cfg, sess.Profile); err != nil {
+			return nil, err
+		}
+	}
+
+	a, owned, err := newAgent(cfg, registry, "session "+sess.ID, sess.Provider, sess.Model, profile)
+	if err != nil {
+		return nil, err
+	}
+
+	// Load existing history if any
+	messages, err := store.LoadMessages(ctx, sess.ID)
+	if err != nil {
+		if owned != nil {
+			owned.Close()
+		}
+		return nil, fmt.Errorf("loading messages: %w", err)
+	}
+	if len(messages) > 0 {
+		a.SetHistory(messages)
+	}
+
+	as := &ActiveSession{
+		Agent:    a,
+		Registry: owned,
+		draining: sm.draining,
+	}
+	sm.sessions[sess.ID] = as
+	return as, nil
+}
+
+// loadProfile reads the named agent profile, the project's own or the one
+// in the profiles directory.
+func loadProfile(cfg *config.Config, name string) (*agent.Profile, error) {
+	profile, err := agent.LoadProfile(cfg.ProfilePath(name))
+	if err != nil {
+		return nil, fmt.Errorf("loading profile: %w", err)
+	}
+	return profile, nil
+}
+
+// newAgent creates an agent for a provider and model, either of which may
+// be empty for the defaults, with profile's prompt, tools, and iteration
+// limit if it's not nil. With tool isolation, the agent gets its own tool
+// server processes, returned as owned for the caller to close; label names
+// the agent in their log messages.
+func newAgent(cfg *config.Config, registry *tools.Registry, label, providerName, model string, profile *agent.Profile) (a *agent.Agent, owned *tools.Registry, err error) {
 	// Resolve provider
-	providerName := sess.Provider
 	if providerName == "" {
 		providerName = cfg.DefaultProvider
 	}
 	provider, err := cfg.Provider(providerName)
 	if err != nil {
-		return nil, fmt.Errorf("resolving provider: %w", err)
+		return nil, nil, fmt.Errorf("resolving provider: %w", err)
 	}
 
 	// Resolve model
-	model := sess.Model
 	if model == "" {
 		model = provider.Models["default"]
 	}
 
-	// Load profile if specified
-	var profile *agent.Profile
-	if sess.Profile != "" {
-		profilePath := filepath.Join(cfg.Agent.ProfilesDir, sess.Profile+".yaml")
-		profile, err = agent.LoadProfile(profilePath)
-		if err != nil {
-			return nil, fmt.Errorf("loading profile: %w", err)
-		}
-	}
-
 	maxIter := cfg.Agent.MaxIterations
 	if profile != nil && profile.MaxIter > 0 {
 		maxIter = profile.MaxIter
 	}
 
+	// With tool isolation, the session gets its own server processes so
+	// stateful tools never leak between sessions.
+	if cfg.Server.IsolateTools() {
+		owned = registry.Fork()
+		for name, toolCfg := range cfg.Tools {
+			if err := owned.Register(name, toolCfg); err != nil {
+				logging.For("tools").Warn("failed to start tool server", "for", label, "server", name, "error", err)
+			}
+		}
+		registry = owned
+	}
+
 	// Create LLM client and agent
 	client := llm.NewClient(provider.BaseURL, provider.APIKey, model)
-	a := agent.New(client, registry, maxIter)
+	a = agent.New(client, registry, maxIter)
 	a.SetMaxTokens(cfg.Agent.ContextMaxTokens)
 
 	// Set up utility LLM if configured
@@ -105,32 +193,120 @@ func (sm *SessionManager) GetOrCreate(
 		a.FilterTools(profile.Tools)
 	}
 
-	// Load existing history if any
-	messages, err := store.LoadMessages(ctx, sess.ID)
+	// The project's FORGE.md is read for each agent, so edits to it apply
+	// to new sessions without a restart.
+	projectContext, err := cfg.ProjectContext()
 	if err != nil {
-		return nil, fmt.Errorf("loading messages: %w", err)
+		logging.For("server").Warn("skipping project context", "for", label, "error", err)
 	}
-	if len(messages) > 0 {
-		a.SetHistory(messages)
+	a.AppendSystemPrompt(projectContext)
+	return a, owned, nil
+}
+
+// Remove removes an active session, cancels any in-flight work, and stops
+// its session-owned tool servers.
+func (sm *SessionManager) Remove(sessionID string) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	if as, ok := sm.sessions[sessionID]; ok {
+		as.close()
+		delete(sm.sessions, sessionID)
 	}
+}
 
-	as := &ActiveSession{
-		Agent: a,
+// Drain turns away new messages, to every session and outside them, and
+// waits for the turns running or queued to finish, or for ctx to be done.
+// Messages are accepted again after Resume.
+func (sm *SessionManager) Drain(ctx context.Context) error {
+	sm.mu.Lock()
+	sm.draining = true
+	active := make([]*ActiveSession, 0, len(sm.sessions))
+	for _, as := range sm.sessions {
+		as.queueMu.Lock()
+		as.draining = true
+		as.queueMu.Unlock()
+		active = append(active, as)
+	}
+	sm.mu.Unlock()
+
+	for _, as := range active {
+		if err := as.waitIdle(ctx); err != nil {
+			return err
+		}
+	}
+	for {
+		sm.mu.RLock()
+		idle, moved := sm.unsaved == 0, sm.unsavedMoved
+		sm.mu.RUnlock()
+		if idle {
+			return nil
+		}
+		select {
+		case <-moved:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
 	}
-	sm.sessions[sess.ID] = as
-	return as, nil
 }
 
-// Remove removes an active session and cancels any in-flight work.
-func (sm *SessionManager) Remove(sessionID string) {
+// StartUnsaved counts a turn that runs outside any session, such as a chat
+// completion, so Drain waits for it. It returns errDraining while the
+// server drains; otherwise the caller must call done when the turn ends.
+func (sm *SessionManager) StartUnsaved() (done func(), err error) {
 	sm.mu.Lock()
 	defer sm.mu.Unlock()
-	if as, ok := sm.sessions[sessionID]; ok {
-		if as.Cancel != nil {
-			as.Cancel()
+	if sm.draining {
+		return nil, errDraining
+	}
+	sm.moveUnsaved(1)
+	return func() {
+		sm.mu.Lock()
+		defer sm.mu.Unlock()
+		sm.moveUnsaved(-1)
+	}, nil
+}
+
+// moveUnsaved changes the count of unsaved turns by n and wakes Drain. The
+// caller must hold mu.
+func (sm *SessionManager) moveUnsaved(n int) {
+	sm.unsaved += n
+	close(sm.unsavedMoved)
+	sm.unsavedMoved = make(chan struct{})
+}
+
+// Resume accepts messages again after Drain.
+func (sm *SessionManager) Resume() {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	sm.draining = false
+	for _, as := range sm.sessions {
+		as.queueMu.Lock()
+		as.draining = false
+		as.queueMu.Unlock()
+	}
+}
+
+// Draining reports whether new messages are being turned away.
+func (sm *SessionManager) Draining() bool {
+	sm.mu.RLock()
+	defer sm.mu.RUnlock()
+	return sm.draining
+}
+
+// Busy returns how many sessions have a turn running or queued, plus the
+// turns running outside a session.
+func (sm *SessionManager) Busy() int {
+	sm.mu.RLock()
+	defer sm.mu.RUnlock()
+	n := sm.unsaved
+	for _, as := range sm.sessions {
+		as.queueMu.Lock()
+		if as.running != nil || len(as.queue) > 0 {
+			n++
 		}
-		delete(sm.sessions, sessionID)
+		as.queueMu.Unlock()
 	}
+	return n
 }
 
 // CloseAll cancels all active sessions.
@@ -138,9 +314,7 @@ func (sm *SessionManager) CloseAll() {
 	sm.mu.Lock()
 	defer sm.mu.Unlock()
 	for id, as := range sm.sessions {
-		if as.Cancel != nil {
-			as.Cancel()
-		}
+		as.close()
 		delete(sm.sessions, id)
 	}
 }
@@ -168,3 +168,88 @@ func TestSessionManager_CloseAll(t *testing.T) {
 		t.Error("expected all sessions to be cleared")
 	}
 }
+
+func TestSessionManager_ToolIsolation(t *testing.T) {
+	sm := NewSessionManager()
+	defer sm.CloseAll()
+
+	store, err := sqlite.Open(":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	cfg := &config.Config{
+		Providers: map[string]config.ProviderConfig{
+			"test": {
+				BaseURL: "http://localhost:11434/v1/",
+				APIKey:  "test",
+				Models:  map[string]string{"default": "test-model"},
+			},
+		},
+		DefaultProvider: "test",
+		Server:          config.ServerConfig{ToolIsolation: config.ToolIsolationSession},
+		Tools: map[string]tools.ToolServerConfig{
+			"disabled": {Binary: "/nonexistent/binary", Enabled: false},
+		},
+	}
+
+	registry := tools.NewRegistry()
+	defer registry.Close()
+
+	var registries []*tools.Registry
+	for _, id := range []string{"iso-a", "iso-b"} {
+		sess := &storage.Session{ID: id, Status: storage.StatusActive, Provider: "test"}
+		if err := store.CreateSession(context.Background(), sess); err != nil {
+			t.Fatal(err)
+		}
+		as, err := sm.GetOrCreate(context.Background(), sess, cfg, store, registry)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if as.Registry == nil {
+			t.Fatalf("session %s: expected a session-owned registry", id)
+		}
+		if as.Registry == registry {
+			t.Fatalf("session %s: expected registry distinct from the shared one", id)
+		}
+		registries = append(registries, as.Registry)
+	}
+
+	if registries[0] == registries[1] {
+		t.Error("expected each session to get its own registry")
+	}
+}
+
+func TestSessionManager_SharedToolsByDefault(t *testing.T) {
+	sm := NewSessionManager()
+	defer sm.CloseAll()
+
+	store, err := sqlite.Open(":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	cfg := &config.Config{
+		Providers: map[string]config.ProviderConfig{
+			"test": {BaseURL: "http://localhost:11434/v1/", Models: map[string]string{"default": "m"}},
+		},
+		DefaultProvider: "test",
+	}
+
+	registry := tools.NewRegistry()
+	defer registry.Close()
+
+	sess := &storage.Session{ID: "shared-a", Status: storage.StatusActive, Provider: "test"}
+	if err := store.CreateSession(context.Background(), sess); err != nil {
+		t.Fatal(err)
+	}
+	as, err := sm.GetOrCreate(context.Background(), sess, cfg, store, registry)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if as.Registry != nil {
+		t.Error("shared mode should not create a session-owned registry")
+	}
+}
@@ -0,0 +1,94 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/michaelbrown/forge/internal/logging"
+)
+
+// handleStreamMessage sends a message like handleSendMessage, but streams
+// the agent's progress as Server-Sent Events: the text_delta, tool_call,
+// tool_output, tool_result, and done or error events the WebSocket sends, each with the
+// same JSON as data, after queued and started events if it has to wait.
+// Closing the connection interrupts the agent.
+func (s *Server) handleStreamMessage(w http.ResponseWriter, r *http.Request) {
+	var req sendMessageRequest
+	if err := decodeJSON(r, &req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid JSON: "+err.Error())
+		return
+	}
+	if req.Content == "" {
+		writeError(w, http.StatusBadRequest, "content is required")
+		return
+	}
+
+	sess, err := s.store.GetSession(r.Context(), chi.URLParam(r, "id"))
+	if err != nil {
+		writeError(w, http.StatusNotFound, "session not found")
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, http.StatusInternalServerError, "streaming not supported")
+		return
+	}
+
+	prompt, err := s.withAttachments(r.Context(), sess.ID, req.Content, req.Attachments)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	as, err := s.sessions.GetOrCreate(r.Context(), sess, s.config(), s.store, s.registry)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, fmt.Sprintf("initializing agent: %v", err))
+		return
+	}
+
+	t, ok := enqueueTurn(w, r, as)
+	if !ok {
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no") // keep proxies such as nginx from buffering
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	var mu sync.Mutex
+	emit := func(out wsOutgoing) {
+		mu.Lock()
+		defer mu.Unlock()
+		if err := writeEvent(w, out); err != nil {
+			logging.For("http").InfoContext(r.Context(), "event stream write failed", "error", err)
+			return
+		}
+		flusher.Flush()
+	}
+	queued, err := waitTurn(r.Context(), t, req.Content, emit)
+	if err != nil {
+		emit(wsOutgoing{Type: "error", Content: err.Error()})
+		return
+	}
+	if queued {
+		emit(wsOutgoing{Type: "started", Content: req.Content})
+	}
+	s.streamTurn(r.Context(), t, sess, prompt, emit)
+}
+
+// writeEvent writes out as a Server-Sent Event named for its type.
+func writeEvent(w http.ResponseWriter, out wsOutgoing) error {
+	data, err := json.Marshal(out)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", out.Type, data)
+	return err
+}
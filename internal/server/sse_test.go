@@ -0,0 +1,98 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/michaelbrown/forge/internal/llm"
+	"github.com/michaelbrown/forge/internal/storage"
+)
+
+// streamingClient replies with a tool call, then streams its final answer.
+type streamingClient struct {
+	calls int
+}
+
+func (c *streamingClient) ChatCompletion(ctx context.Context, messages []llm.Message, tools []llm.ToolDef) (*llm.Response, error) {
+	return c.ChatCompletionStream(ctx, messages, tools, nil)
+}
+
+func (c *streamingClient) ChatCompletionStream(ctx context.Context, messages []llm.Message, tools []llm.ToolDef, handler llm.StreamHandler) (*llm.Response, error) {
+	c.calls++
+	if c.calls == 1 {
+		return &llm.Response{Message: llm.Message{Role: llm.RoleAssistant, ToolCalls: []llm.ToolCall{
+			{ID: "call_1", Name: "shell_exec", Args: map[string]any{"command": "echo hi"}},
+		}}}, nil
+	}
+	for _, delta := range []string{"It printed ", "hi."} {
+		if handler != nil {
+			handler(delta)
+		}
+	}
+	return &llm.Response{Message: llm.AssistantMessage("It printed hi.")}, nil
+}
+
+func TestStreamMessage(t *testing.T) {
+	srv := newTestServer(t)
+	ctx := context.Background()
+	sess := &storage.Session{ID: "sse1", Status: storage.StatusActive}
+	srv.store.CreateSession(ctx, sess)
+	as, err := srv.sessions.GetOrCreate(ctx, sess, srv.config(), srv.store, srv.registry)
+	if err != nil {
+		t.Fatal(err)
+	}
+	as.Agent.SetClient(&streamingClient{})
+
+	ts := httptest.NewServer(srv.router)
+	defer ts.Close()
+	resp, err := http.Post(ts.URL+"/api/sessions/sse1/messages/stream", "application/json", strings.NewReader(`{"content":"run echo hi"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "text/event-stream" {
+		t.Fatalf("response %d, Content-Type %q", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+
+	var events []string
+	var last wsOutgoing
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if name, ok := strings.CutPrefix(line, "event: "); ok {
+			events = append(events, name)
+		}
+		if data, ok := strings.CutPrefix(line, "data: "); ok {
+			last = wsOutgoing{}
+			if err := json.Unmarshal([]byte(data), &last); err != nil {
+				t.Fatalf("bad event data %q: %v", data, err)
+			}
+		}
+	}
+
+	want := "tool_call tool_result text_delta text_delta done"
+	if got := strings.Join(events, " "); got != want {
+		t.Errorf("events = %s, want %s", got, want)
+	}
+	if last.Type != "done" || last.Content != "It printed hi." {
+		t.Errorf("last event = %+v", last)
+	}
+	msgs, _ := srv.store.LoadMessages(ctx, "sse1")
+	if len(msgs) != 5 {
+		t.Errorf("saved %d messages, want 5", len(msgs))
+	}
+
+	resp, err = http.Post(ts.URL+"/api/sessions/sse1/messages/stream", "application/json", strings.NewReader(`{}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("empty content: expected 400, got %d", resp.StatusCode)
+	}
+}
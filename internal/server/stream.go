@@ -0,0 +1,222 @@
+package server
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/michaelbrown/forge/internal/logging"
+)
+
+// Each session's WebSocket events are numbered, and those of its latest
+// turn are kept, so a client whose connection drops mid-turn can reconnect
+// and resume from the last event it got instead of losing the output.
+
+const (
+	maxStreamEvents = 4096             // events kept per session, oldest dropped first
+	streamIdleTTL   = 10 * time.Minute // how long an unwatched stream outlives its last event
+
+	wsWriteWait = 10 * time.Second // time allowed to write one message
+	wsPongWait  = 60 * time.Second // time allowed between pongs before a client counts as gone
+)
+
+// eventStream numbers a session's WebSocket events and sends them to the
+// connections watching the session.
+type eventStream struct {
+	mu     sync.Mutex
+	last   int          // index of the latest event
+	events []wsOutgoing // since the latest turn started, oldest first
+	subs   map[*wsClient]struct{}
+	used   time.Time
+}
+
+// startTurn forgets the previous turn's events.
+func (st *eventStream) startTurn() {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	st.events = nil
+}
+
+// publish numbers out, keeps it, and sends it to every subscriber.
+func (st *eventStream) publish(out wsOutgoing) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	st.last++
+	out.Index = st.last
+	st.events = append(st.events, out)
+	if len(st.events) > maxStreamEvents {
+		st.events = st.events[1:]
+	}
+	st.used = time.Now()
+	for c := range st.subs {
+		c.send(out)
+	}
+}
+
+// subscribe sends c the stream's events from now on. When resuming, it
+// first replays the events after index last; if some of those are no
+// longer kept, or last isn't an index the stream has reached, it sends a
+// resync event and replays the whole latest turn instead, and the client
+// should reload the session's messages.
+func (st *eventStream) subscribe(c *wsClient, resume bool, last int) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	if resume {
+		first := st.last - len(st.events) + 1
+		if last < first-1 || last > st.last {
+			c.send(wsOutgoing{Type: "resync"})
+			last = first - 1
+		}
+		for _, out := range st.events {
+			if out.Index > last {
+				c.send(out)
+			}
+		}
+	}
+	st.subs[c] = struct{}{}
+	st.used = time.Now()
+}
+
+func (st *eventStream) unsubscribe(c *wsClient) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	delete(st.subs, c)
+	st.used = time.Now()
+}
+
+// idle reports whether the stream has no subscribers and hasn't been used
+// since before.
+func (st *eventStream) idle(before time.Time) bool {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	return len(st.subs) == 0 && st.used.Before(before)
+}
+
+// Stream returns the session's event stream. Streams outlive the session's
+// agent, so clients can resume after it is reloaded; ones nobody has used
+// for a while are dropped.
+func (sm *SessionManager) Stream(sessionID string) *eventStream {
+	sm.streamMu.Lock()
+	defer sm.streamMu.Unlock()
+	now := time.Now()
+	for id, st := range sm.streams {
+		if id != sessionID && st.idle(now.Add(-streamIdleTTL)) {
+			delete(sm.streams, id)
+		}
+	}
+	st, ok := sm.streams[sessionID]
+	if !ok {
+		st = &eventStream{subs: make(map[*wsClient]struct{}), used: now}
+		sm.streams[sessionID] = st
+	}
+	return st
+}
+
+// maxPendingEvents is how far a client may fall behind before it is
+// disconnected; it can then reconnect and resume.
+const maxPendingEvents = maxStreamEvents + 256
+
+// wsClient is one WebSocket connection. Messages queue for a writer
+// goroutine, so a slow client never holds up the agent, and the writer
+// also pings the client to keep the connection alive.
+type wsClient struct {
+	conn    *websocket.Conn
+	ping    time.Duration // how often to ping
+	mu      sync.Mutex
+	pending []wsOutgoing
+	wake    chan struct{}
+	done    chan struct{}
+	once    sync.Once
+}
+
+func newWSClient(conn *websocket.Conn, ping time.Duration) *wsClient {
+	return &wsClient{
+		conn: conn,
+		ping: ping,
+		wake: make(chan struct{}, 1),
+		done: make(chan struct{}),
+	}
+}
+
+// send queues out to be written, disconnecting the client if it has
+// fallen too far behind.
+func (c *wsClient) send(out wsOutgoing) {
+	c.mu.Lock()
+	if len(c.pending) >= maxPendingEvents {
+		c.mu.Unlock()
+		logging.For("websocket").Warn("client fell behind; disconnecting", "pending", maxPendingEvents)
+		c.close()
+		return
+	}
+	c.pending = append(c.pending, out)
+	c.mu.Unlock()
+	select {
+	case c.wake <- struct{}{}:
+	default:
+	}
+}
+
+// close closes the connection, which ends the read loop too.
+func (c *wsClient) close() {
+	c.once.Do(func() {
+		close(c.done)
+		c.conn.Close()
+	})
+}
+
+// writeLoop writes queued messages and pings until the client closes or a
+// write fails.
+func (c *wsClient) writeLoop() {
+	ping := time.NewTicker(c.ping)
+	defer ping.Stop()
+	for {
+		select {
+		case <-c.wake:
+			c.mu.Lock()
+			batch := c.pending
+			c.pending = nil
+			c.mu.Unlock()
+			for _, out := range mergeDeltas(batch) {
+				if err := c.write(out); err != nil {
+					logging.For("websocket").Info("write failed", "error", err)
+					c.close()
+					return
+				}
+			}
+		case <-ping.C:
+			if err := c.conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(wsWriteWait)); err != nil {
+				c.close()
+				return
+			}
+		case <-c.done:
+			return
+		}
+	}
+}
+
+func (c *wsClient) write(out wsOutgoing) error {
+	data, err := json.Marshal(out)
+	if err != nil {
+		return err
+	}
+	c.conn.SetWriteDeadline(time.Now().Add(wsWriteWait))
+	return c.conn.WriteMessage(websocket.TextMessage, data)
+}
+
+// mergeDeltas joins runs of text_delta events, so a client that has
+// fallen behind catches up in fewer messages. A merged event has the last
+// index of its run.
+func mergeDeltas(events []wsOutgoing) []wsOutgoing {
+	var merged []wsOutgoing
+	for _, out := range events {
+		if n := len(merged); n > 0 && out.Type == "text_delta" && merged[n-1].Type == "text_delta" {
+			merged[n-1].Content += out.Content
+			merged[n-1].Index = out.Index
+			continue
+		}
+		merged = append(merged, out)
+	}
+	return merged
+}
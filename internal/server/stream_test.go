@@ -0,0 +1,26 @@
+package server
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMergeDeltas(t *testing.T) {
+	got := mergeDeltas([]wsOutgoing{
+		{Type: "text_delta", Content: "a", Index: 1},
+		{Type: "text_delta", Content: "b", Index: 2},
+		{Type: "tool_call", Name: "x", Index: 3},
+		{Type: "text_delta", Content: "c", Index: 4},
+		{Type: "text_delta", Content: "d", Index: 5},
+		{Type: "done", Content: "abcd", Index: 6},
+	})
+	want := []wsOutgoing{
+		{Type: "text_delta", Content: "ab", Index: 2},
+		{Type: "tool_call", Name: "x", Index: 3},
+		{Type: "text_delta", Content: "cd", Index: 5},
+		{Type: "done", Content: "abcd", Index: 6},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("mergeDeltas = %+v, want %+v", got, want)
+	}
+}
@@ -0,0 +1,72 @@
+package server
+
+import (
+	"context"
+	"path/filepath"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+
+	"github.com/michaelbrown/forge/internal/logging"
+)
+
+// configSettle is how long the config files must go unchanged before they
+// are reloaded, so an editor's several writes make one reload.
+const configSettle = 500 * time.Millisecond
+
+// watchConfig reloads the config whenever one of its files changes, until
+// ctx is done. It watches the files' directories rather than the files,
+// since editors often save by writing a new file and renaming it over the
+// old one. A config with no files, such as one from FORGE_* variables
+// alone, isn't watched.
+func (s *Server) watchConfig(ctx context.Context) {
+	files := s.config().Files()
+	if len(files) == 0 {
+		return
+	}
+	log := logging.For("server")
+	w, err := fsnotify.NewWatcher()
+	if err != nil {
+		log.Warn("not watching the config for changes", "error", err)
+		return
+	}
+	defer w.Close()
+
+	watched := map[string]bool{}
+	for _, path := range files {
+		path, err := filepath.Abs(path)
+		if err == nil {
+			err = w.Add(filepath.Dir(path))
+		}
+		if err != nil {
+			log.Warn("not watching the config for changes", "file", path, "error", err)
+			return
+		}
+		watched[path] = true
+	}
+
+	var settled <-chan time.Time
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case ev, ok := <-w.Events:
+			if !ok {
+				return
+			}
+			if watched[filepath.Clean(ev.Name)] && ev.Op != fsnotify.Chmod {
+				settled = time.After(configSettle)
+			}
+		case err, ok := <-w.Errors:
+			if !ok {
+				return
+			}
+			log.Warn("watching the config", "error", err)
+		case <-settled:
+			settled = nil
+			if _, err := s.reload(ctx); err != nil {
+				log.Error("config change rejected; keeping the running config", "error", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/michaelbrown/forge/internal/config"
+)
+
+func TestWatchConfig(t *testing.T) {
+	srv := newTestServer(t)
+	path := filepath.Join(t.TempDir(), "forge.yaml")
+	if err := os.WriteFile(path, []byte("agent:\n  max_iterations: 5\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	srv.config().SetPath(path)
+
+	var loads atomic.Int32
+	var bad atomic.Bool
+	srv.loadConfig = func() (*config.Config, error) {
+		loads.Add(1)
+		if bad.Load() {
+			return nil, errors.New("bad yaml")
+		}
+		cfg := *srv.config()
+		cfg.Agent.MaxIterations = 7
+		return &cfg, nil
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go srv.watchConfig(ctx)
+	time.Sleep(100 * time.Millisecond) // let the watcher start
+
+	waitFor := func(what string, cond func() bool) {
+		t.Helper()
+		for deadline := time.Now().Add(5 * time.Second); !cond(); time.Sleep(20 * time.Millisecond) {
+			if time.Now().After(deadline) {
+				t.Fatalf("timed out waiting for %s", what)
+			}
+		}
+	}
+
+	// Several quick writes make one reload.
+	for i := 0; i < 3; i++ {
+		if err := os.WriteFile(path, []byte("agent:\n  max_iterations: 7\n"), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	waitFor("the reload", func() bool { return loads.Load() > 0 })
+	time.Sleep(2 * configSettle)
+	if n := loads.Load(); n != 1 {
+		t.Errorf("loaded %d times, want 1", n)
+	}
+	if srv.config().Agent.MaxIterations != 7 {
+		t.Errorf("max_iterations = %d, want 7", srv.config().Agent.MaxIterations)
+	}
+
+	// A file saved by renaming a new one over it counts, and a config that
+	// doesn't load is refused.
+	bad.Store(true)
+	running := srv.config()
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, []byte("agent: ["), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		t.Fatal(err)
+	}
+	waitFor("the second reload", func() bool { return loads.Load() > 1 })
+	if srv.config() != running {
+		t.Error("a config that doesn't load replaced the running one")
+	}
+
+	// Other files in the directory are ignored.
+	if err := os.WriteFile(filepath.Join(filepath.Dir(path), "notes.txt"), []byte("hi"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(2 * configSettle)
+	if n := loads.Load(); n != 2 {
+		t.Errorf("loaded %d times, want 2", n)
+	}
+}
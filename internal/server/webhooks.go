@@ -0,0 +1,145 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/michaelbrown/forge/internal/config"
+	"github.com/michaelbrown/forge/internal/logging"
+	"github.com/michaelbrown/forge/internal/storage"
+	"github.com/michaelbrown/forge/internal/tools"
+)
+
+// Webhooks tell other systems, such as chat notifiers or CI, what the
+// server's agents are doing. Deliveries happen in the background and are
+// retried a few times, so a webhook that's down never holds up an agent.
+
+const (
+	webhookAttempts = 3
+	webhookTimeout  = 10 * time.Second
+)
+
+// webhookPayload is the body of a webhook request.
+type webhookPayload struct {
+	ID        string           `json:"id"`
+	Event     string           `json:"event"`
+	CreatedAt time.Time        `json:"created_at"`
+	Session   *storage.Session `json:"session,omitempty"`
+	Data      map[string]any   `json:"data,omitempty"`
+}
+
+// notify sends event, about sess if it isn't nil, to the webhooks that
+// want it.
+func (s *Server) notify(event string, sess *storage.Session, data map[string]any) {
+	var hooks []config.WebhookConfig
+	for _, h := range s.config().Server.Webhooks {
+		if h.Wants(event) {
+			hooks = append(hooks, h)
+		}
+	}
+	if len(hooks) == 0 {
+		return
+	}
+
+	id := uuid.New().String()
+	body, err := json.Marshal(webhookPayload{ID: id, Event: event, CreatedAt: time.Now().UTC(), Session: sess, Data: data})
+	if err != nil {
+		logging.For("webhooks").Error("encoding payload", "event", event, "error", err)
+		return
+	}
+	for _, h := range hooks {
+		s.deliveries.Add(1)
+		go func() {
+			defer s.deliveries.Done()
+			s.deliver(h, id, event, body)
+		}()
+	}
+}
+
+// deliver posts body to a webhook, backing off and retrying when the
+// request fails or the webhook answers 429 or a server error.
+func (s *Server) deliver(h config.WebhookConfig, id, event string, body []byte) {
+	delay := s.webhookBackoff
+	for attempt := 1; ; attempt++ {
+		retry, err := postWebhook(h, id, event, body)
+		if err == nil {
+			return
+		}
+		if !retry || attempt == webhookAttempts {
+			logging.For("webhooks").Warn("delivery failed", "url", h.URL, "event", event, "delivery", id, "attempts", attempt, "error", err)
+			return
+		}
+		time.Sleep(delay)
+		delay *= 2
+	}
+}
+
+// postWebhook makes one delivery attempt, reporting whether a failure is
+// worth retrying.
+func postWebhook(h config.WebhookConfig, id, event string, body []byte) (retry bool, err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), webhookTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, "POST", h.URL, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "forge-webhook")
+	req.Header.Set("X-Forge-Event", event)
+	req.Header.Set("X-Forge-Delivery", id)
+	if h.Secret != "" {
+		req.Header.Set("X-Forge-Signature", "sha256="+webhookSignature(h.Secret, body))
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return true, err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		retry = resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+		return retry, fmt.Errorf("webhook answered %s", resp.Status)
+	}
+	return false, nil
+}
+
+// webhookSignature returns the hex HMAC-SHA256 of body with secret as the
+// key, which receivers compare with X-Forge-Signature.
+func webhookSignature(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// turnFinished tells webhooks how a turn of sess ended, unless it was
+// interrupted.
+func (s *Server) turnFinished(sess *storage.Session, response string, err error, interrupted bool) {
+	switch {
+	case interrupted:
+	case err != nil:
+		s.notify(config.EventSessionFailed, sess, map[string]any{"error": err.Error()})
+	default:
+		s.notify(config.EventSessionCompleted, sess, map[string]any{"response": response})
+	}
+}
+
+// requestApproval is the tool registry's approver when webhooks are set
+// up. The server has no one to ask, so it refuses, but the webhooks hear
+// what was wanted.
+func (s *Server) requestApproval(ctx context.Context, server, message string) (bool, error) {
+	var sess *storage.Session
+	if id := tools.SessionFromContext(ctx); id != "" {
+		sess, _ = s.store.GetSession(ctx, id)
+	}
+	s.notify(config.EventApprovalRequest, sess, map[string]any{"server": server, "message": message})
+	return false, errors.New("no one is available to approve this")
+}
@@ -0,0 +1,125 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/michaelbrown/forge/internal/config"
+	"github.com/michaelbrown/forge/internal/storage"
+	"github.com/michaelbrown/forge/internal/tools"
+)
+
+// webhookReceiver records the deliveries it gets, failing the first
+// failures of them with a 500.
+type webhookReceiver struct {
+	mu         sync.Mutex
+	failures   int
+	deliveries []webhookDelivery
+}
+
+type webhookDelivery struct {
+	header  http.Header
+	body    []byte
+	payload webhookPayload
+}
+
+func (rv *webhookReceiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	rv.mu.Lock()
+	defer rv.mu.Unlock()
+	d := webhookDelivery{header: r.Header, body: body}
+	json.Unmarshal(body, &d.payload)
+	rv.deliveries = append(rv.deliveries, d)
+	if rv.failures > 0 {
+		rv.failures--
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
+
+// take returns and forgets the deliveries so far.
+func (rv *webhookReceiver) take() []webhookDelivery {
+	rv.mu.Lock()
+	defer rv.mu.Unlock()
+	d := rv.deliveries
+	rv.deliveries = nil
+	return d
+}
+
+func TestWebhooks(t *testing.T) {
+	all, failures := &webhookReceiver{}, &webhookReceiver{}
+	allSrv, failuresSrv := httptest.NewServer(all), httptest.NewServer(failures)
+	defer allSrv.Close()
+	defer failuresSrv.Close()
+
+	srv := newTestServer(t)
+	srv.config().Server.Webhooks = []config.WebhookConfig{
+		{URL: allSrv.URL, Secret: "s3cret"},
+		{URL: failuresSrv.URL, Events: []string{config.EventSessionFailed}},
+	}
+	srv.webhookBackoff = time.Millisecond
+
+	w := httptest.NewRecorder()
+	srv.router.ServeHTTP(w, httptest.NewRequest("POST", "/api/sessions", strings.NewReader(`{"title":"Hooked"}`)))
+	var sess storage.Session
+	json.NewDecoder(w.Body).Decode(&sess)
+	srv.deliveries.Wait()
+
+	got := all.take()
+	if len(got) != 1 {
+		t.Fatalf("got %d deliveries, want 1", len(got))
+	}
+	d := got[0]
+	if d.payload.Event != config.EventSessionCreated || d.payload.Session == nil || d.payload.Session.ID != sess.ID {
+		t.Errorf("payload = %+v", d.payload)
+	}
+	if d.header.Get("X-Forge-Event") != config.EventSessionCreated || d.header.Get("X-Forge-Delivery") != d.payload.ID {
+		t.Errorf("headers = %v", d.header)
+	}
+	if got, want := d.header.Get("X-Forge-Signature"), "sha256="+webhookSignature("s3cret", d.body); got != want {
+		t.Errorf("signature = %q, want %q", got, want)
+	}
+	if n := len(failures.take()); n != 0 {
+		t.Errorf("webhook for failures got %d deliveries", n)
+	}
+
+	// Failed turns go to both, and a webhook that errors gets retries.
+	failures.failures = 1
+	srv.turnFinished(&sess, "", errors.New("model not found"), false)
+	srv.turnFinished(&sess, "", errors.New("interrupted"), true)
+	srv.turnFinished(&sess, "all done", nil, false)
+	srv.deliveries.Wait()
+	got = failures.take()
+	if len(got) != 2 || got[0].payload.ID != got[1].payload.ID || got[1].payload.Data["error"] != "model not found" {
+		t.Errorf("failure deliveries = %+v", got)
+	}
+	if got[0].header.Get("X-Forge-Signature") != "" {
+		t.Error("delivery signed without a secret")
+	}
+	var events []string
+	for _, d := range all.take() {
+		events = append(events, d.payload.Event)
+	}
+	if len(events) != 2 || !strings.Contains(strings.Join(events, " "), config.EventSessionCompleted) {
+		t.Errorf("events = %v", events)
+	}
+
+	// Approval requests are refused, but reported.
+	ok, err := srv.requestApproval(tools.WithSession(context.Background(), sess.ID), "shell-exec", "rm -rf build")
+	if ok || err == nil {
+		t.Errorf("requestApproval = %v, %v", ok, err)
+	}
+	srv.deliveries.Wait()
+	got = all.take()
+	if len(got) != 1 || got[0].payload.Event != config.EventApprovalRequest ||
+		got[0].payload.Data["message"] != "rm -rf build" || got[0].payload.Session == nil {
+		t.Errorf("approval deliveries = %+v", got)
+	}
+}
@@ -2,39 +2,48 @@ package server
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
-	"log"
 	"net/http"
-	"sync"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/gorilla/websocket"
 
 	"github.com/michaelbrown/forge/internal/config"
 	"github.com/michaelbrown/forge/internal/llm"
+	"github.com/michaelbrown/forge/internal/logging"
 	"github.com/michaelbrown/forge/internal/storage"
+	"github.com/michaelbrown/forge/internal/tools"
 )
 
 var upgrader = websocket.Upgrader{
 	CheckOrigin: func(r *http.Request) bool {
-		return true // Tailscale handles auth
+		return true // authenticate checks credentials, if server.auth has any
 	},
 }
 
-// wsIncoming is a message from the client.
+// wsIncoming is a message from the client: a "message" with content for
+// the agent, and the names of any attachments to include, a "cancel" to interrupt the agent's turn in progress, or, as
+// the first message on a new connection, a "resume" with the index of the
+// last event the client got before its old connection dropped.
 type wsIncoming struct {
-	Type    string `json:"type"`
-	Content string `json:"content"`
+	Type        string   `json:"type"`
+	Content     string   `json:"content"`
+	Attachments []string `json:"attachments"`
+	LastEvent   int      `json:"last_event"`
 }
 
-// wsOutgoing is a message to the client.
+// wsOutgoing is a message to the client, over the WebSocket or as a
+// Server-Sent Event.
 type wsOutgoing struct {
 	Type            string                  `json:"type"`
 	Content         string                  `json:"content,omitempty"`
 	Name            string                  `json:"name,omitempty"`
 	Args            any                     `json:"args,omitempty"`
 	FallbackOptions []config.FallbackOption  `json:"fallback_options,omitempty"`
+	Messages        []llm.Message           `json:"messages,omitempty"` // an interrupted turn's messages
+	Position        int                     `json:"position,omitempty"` // turns ahead of a queued message
+	Index           int                     `json:"index,omitempty"`    // the event's place in the session's stream
 }
 
 func (s *Server) handleWebSocket(w http.ResponseWriter, r *http.Request) {
@@ -49,118 +58,199 @@ func (s *Server) handleWebSocket(w http.ResponseWriter, r *http.Request) {
 	// Upgrade to WebSocket
 	conn, err := upgrader.Upgrade(w, r, nil)
 	if err != nil {
-		log.Printf("websocket upgrade error: %v", err)
+		logging.For("websocket").WarnContext(r.Context(), "upgrade failed", "error", err)
 		return
 	}
-	defer conn.Close()
+	c := newWSClient(conn, s.pongWait*9/10)
+	defer c.close()
+	go c.writeLoop()
+
+	// A client that stops answering pings is gone, even if the connection
+	// never closed.
+	conn.SetReadDeadline(time.Now().Add(s.pongWait))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(s.pongWait))
+	})
+
+	// The connection watches the session's events from its first message,
+	// which may ask to resume an earlier connection's.
+	stream := s.sessions.Stream(id)
+	defer stream.unsubscribe(c)
+	subscribed := false
+
+	// Messages wait their turn in the background, so the read loop can take
+	// a cancel while the agent works. Those still waiting when the client
+	// goes leave the queue.
+	ctx := logging.WithRequestID(context.Background(), logging.RequestID(r.Context()))
+	if cl, ok := clientFrom(r.Context()); ok {
+		ctx = withClient(ctx, cl)
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
 
-	// Read loop — re-fetch session and agent on each message so model
-	// changes via PATCH take effect without reconnecting.
 	for {
 		var msg wsIncoming
 		if err := conn.ReadJSON(&msg); err != nil {
 			if websocket.IsCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway, websocket.CloseNoStatusReceived) {
 				return
 			}
-			log.Printf("websocket read error: %v", err)
+			logging.For("websocket").InfoContext(ctx, "connection lost", "session", id, "error", err)
 			return
 		}
+		conn.SetReadDeadline(time.Now().Add(s.pongWait))
 
-		if msg.Type != "message" || msg.Content == "" {
-			wsWriteJSON(conn, wsOutgoing{Type: "error", Content: "invalid message"})
-			continue
+		if !subscribed {
+			subscribed = true
+			stream.subscribe(c, msg.Type == "resume", msg.LastEvent)
+			if msg.Type == "resume" {
+				continue
+			}
 		}
 
-		// Re-read session from DB to pick up model/provider changes
-		sess, err := s.store.GetSession(context.Background(), id)
-		if err != nil {
-			wsWriteJSON(conn, wsOutgoing{Type: "error", Content: "session not found"})
-			return
+		switch {
+		case msg.Type == "cancel":
+			sess, err := s.store.GetSession(context.Background(), id)
+			if err != nil {
+				c.send(wsOutgoing{Type: "error", Content: "session not found"})
+				return
+			}
+			if as, ok := s.sessions.Get(sess.ID); !ok || as.cancelTurn() == nil {
+				c.send(wsOutgoing{Type: "error", Content: "nothing to cancel"})
+			}
+		case msg.Type == "message" && msg.Content != "":
+			if err := s.checkMessage(ctx); err != nil {
+				c.send(wsOutgoing{Type: "error", Content: err.Error()})
+				continue
+			}
+			content, err := s.withAttachments(ctx, id, msg.Content, msg.Attachments)
+			if err != nil {
+				c.send(wsOutgoing{Type: "error", Content: err.Error()})
+				continue
+			}
+			s.processWebSocketMessage(ctx, id, content, c.send, stream)
+		case msg.Type == "resume":
+			c.send(wsOutgoing{Type: "error", Content: "resume must be the first message"})
+		default:
+			c.send(wsOutgoing{Type: "error", Content: "invalid message"})
 		}
+	}
+}
 
-		as, err := s.sessions.GetOrCreate(context.Background(), sess, s.cfg, s.store, s.registry)
-		if err != nil {
-			wsWriteJSON(conn, wsOutgoing{Type: "error", Content: fmt.Sprintf("initializing agent: %v", err)})
-			continue
-		}
+// processWebSocketMessage queues a message the client sent and runs it in
+// the background when its turn comes, re-fetching the session and agent so
+// model changes via PATCH take effect without reconnecting. Errors before
+// the message is queued go to write; everything after is published to the
+// session's stream.
+func (s *Server) processWebSocketMessage(ctx context.Context, id, content string, write func(wsOutgoing), stream *eventStream) {
+	sess, err := s.store.GetSession(context.Background(), id)
+	if err != nil {
+		write(wsOutgoing{Type: "error", Content: "session not found"})
+		return
+	}
 
-		s.processWebSocketMessage(conn, as, sess, msg.Content)
+	as, err := s.sessions.GetOrCreate(context.Background(), sess, s.config(), s.store, s.registry)
+	if err != nil {
+		write(wsOutgoing{Type: "error", Content: fmt.Sprintf("initializing agent: %v", err)})
+		return
 	}
+
+	// Join the queue here so messages keep the order they were sent in.
+	t, position, err := as.enqueue(true)
+	if err != nil {
+		write(wsOutgoing{Type: "error", Content: err.Error(), Position: position})
+		return
+	}
+	go func() {
+		queued, err := waitTurn(ctx, t, content, stream.publish)
+		if err != nil {
+			if ctx.Err() == nil {
+				write(wsOutgoing{Type: "error", Content: err.Error()})
+			}
+			return
+		}
+		stream.startTurn()
+		if queued {
+			stream.publish(wsOutgoing{Type: "started", Content: content})
+		}
+		s.streamTurn(context.WithoutCancel(ctx), t, sess, content, stream.publish)
+	}()
 }
 
-func (s *Server) processWebSocketMessage(conn *websocket.Conn, as *ActiveSession, sess *storage.Session, content string) {
-	// Ensure one message at a time
-	as.mu.Lock()
-	defer as.mu.Unlock()
+// waitTurn waits for t's turn, sending a queued event with t's position
+// whenever it changes, and reports whether it had to wait. Callers send a
+// started event when it did.
+func waitTurn(ctx context.Context, t *turn, content string, emit func(wsOutgoing)) (queued bool, err error) {
+	err = t.wait(ctx, func(position int) {
+		queued = true
+		emit(wsOutgoing{Type: "queued", Content: content, Position: position})
+	})
+	return queued, err
+}
 
-	// Mutex for thread-safe writes to the WebSocket connection
-	var wsMu sync.Mutex
+// streamTurn runs content through the session's agent with streaming as
+// turn t, which must have waited its turn, passing text_delta, tool_call,
+// tool_output, and tool_result events to emit as they happen and ending
+// with a done or error event. The turn is interrupted when ctx is cancelled.
+func (s *Server) streamTurn(ctx context.Context, t *turn, sess *storage.Session, content string, emit func(wsOutgoing)) {
+	as := t.as
+	defer t.end()
 
 	// Auto-generate title from first message
-	if sess.Title == "" {
+	firstMessage := sess.Title == ""
+	if firstMessage {
 		sess.Title = generateTitle(content)
 		s.store.UpdateSession(context.Background(), sess)
 	}
 
 	// Create cancellable context — cancelled on client disconnect
-	ctx, cancel := context.WithCancel(context.Background())
-	as.Cancel = cancel
-	defer func() {
-		cancel()
-		as.Cancel = nil
-	}()
+	ctx, cancel := context.WithCancel(tools.WithSession(ctx, sess.ID))
+	t.start(cancel)
 
-	// Wire agent callbacks to send WebSocket messages
+	// Wire agent callbacks to emit events
 	as.Agent.OnTextDelta = func(delta string) {
-		wsMu.Lock()
-		wsWriteJSON(conn, wsOutgoing{Type: "text_delta", Content: delta})
-		wsMu.Unlock()
+		emit(wsOutgoing{Type: "text_delta", Content: delta})
 	}
 	as.Agent.OnToolCall = func(name string, args map[string]any) {
-		wsMu.Lock()
-		wsWriteJSON(conn, wsOutgoing{Type: "tool_call", Name: name, Args: args})
-		wsMu.Unlock()
+		emit(wsOutgoing{Type: "tool_call", Name: name, Args: args})
+	}
+	as.Agent.OnToolOutput = func(name string, output string) {
+		emit(wsOutgoing{Type: "tool_output", Name: name, Content: output})
 	}
 	as.Agent.OnToolResult = func(name string, result string) {
-		wsMu.Lock()
-		wsWriteJSON(conn, wsOutgoing{Type: "tool_result", Name: name, Content: result})
-		wsMu.Unlock()
+		emit(wsOutgoing{Type: "tool_result", Name: name, Content: result})
 	}
+	defer func() {
+		as.Agent.OnTextDelta = nil
+		as.Agent.OnToolCall = nil
+		as.Agent.OnToolOutput = nil
+		as.Agent.OnToolResult = nil
+	}()
 
 	// Run agent with streaming
 	response, err := as.Agent.RunStreaming(ctx, content)
+	s.turnFinished(sess, response, err, ctx.Err() != nil)
+	s.chargeTurn(ctx, as.Agent.History())
 
 	// Save messages regardless of error
-	if saveErr := s.store.SaveMessages(context.Background(), sess.ID, as.Agent.History()); saveErr != nil {
-		log.Printf("failed to save messages for session %s: %v", sess.ID, saveErr)
+	if saveErr := storage.SaveHistory(context.Background(), s.store, sess.ID, as.Agent); saveErr != nil {
+		logging.For("server").ErrorContext(ctx, "saving messages", "session", sess.ID, "error", saveErr)
 	}
 
-	wsMu.Lock()
-	defer wsMu.Unlock()
-
 	if err != nil {
 		if ctx.Err() != nil {
-			wsWriteJSON(conn, wsOutgoing{Type: "error", Content: "interrupted"})
+			emit(wsOutgoing{Type: "error", Content: "interrupted", Messages: lastTurn(as.Agent.History())})
 		} else {
 			out := wsOutgoing{Type: "error", Content: err.Error()}
 			if llm.IsFallbackEligible(err) {
-				out.FallbackOptions = s.cfg.FallbackProviders(sess.Provider)
+				out.FallbackOptions = s.config().FallbackProviders(sess.Provider)
 			}
-			wsWriteJSON(conn, out)
+			emit(out)
 		}
 		return
 	}
 
-	wsWriteJSON(conn, wsOutgoing{Type: "done", Content: response})
-}
-
-func wsWriteJSON(conn *websocket.Conn, v any) {
-	data, err := json.Marshal(v)
-	if err != nil {
-		log.Printf("websocket marshal error: %v", err)
-		return
-	}
-	if err := conn.WriteMessage(websocket.TextMessage, data); err != nil {
-		log.Printf("websocket write error: %v", err)
+	if firstMessage {
+		s.retitle(as.Agent, sess.ID, sess.Title)
 	}
+	emit(wsOutgoing{Type: "done", Content: response})
 }
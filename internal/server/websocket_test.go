@@ -0,0 +1,266 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/michaelbrown/forge/internal/llm"
+	"github.com/michaelbrown/forge/internal/storage"
+)
+
+func TestWebSocketCancel(t *testing.T) {
+	srv := newTestServer(t)
+	ctx := context.Background()
+	sess := &storage.Session{ID: "wscancel", Title: "Runaway", Status: storage.StatusActive}
+	srv.store.CreateSession(ctx, sess)
+	as, err := srv.sessions.GetOrCreate(ctx, sess, srv.config(), srv.store, srv.registry)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := &blockingClient{started: make(chan struct{}, 1)}
+	as.Agent.SetClient(client)
+
+	ts := httptest.NewServer(srv.router)
+	defer ts.Close()
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(ts.URL, "http")+"/api/sessions/wscancel/ws", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+
+	if err := conn.WriteJSON(wsIncoming{Type: "message", Content: "loop forever"}); err != nil {
+		t.Fatal(err)
+	}
+	<-client.started
+	if err := conn.WriteJSON(wsIncoming{Type: "cancel"}); err != nil {
+		t.Fatal(err)
+	}
+
+	var out wsOutgoing
+	if err := conn.ReadJSON(&out); err != nil {
+		t.Fatal(err)
+	}
+	if out.Type != "error" || out.Content != "interrupted" || len(out.Messages) != 1 || out.Messages[0].Content != "loop forever" {
+		t.Errorf("after cancel got %+v", out)
+	}
+
+	// With nothing running, there's nothing to cancel.
+	conn.WriteJSON(wsIncoming{Type: "cancel"})
+	if err := conn.ReadJSON(&out); err != nil {
+		t.Fatal(err)
+	}
+	if out.Type != "error" || out.Content != "nothing to cancel" {
+		t.Errorf("idle cancel got %+v", out)
+	}
+}
+
+func TestWebSocketQueue(t *testing.T) {
+	srv := newTestServer(t)
+	ctx := context.Background()
+	sess := &storage.Session{ID: "wsqueue", Title: "Busy", Status: storage.StatusActive}
+	srv.store.CreateSession(ctx, sess)
+	as, err := srv.sessions.GetOrCreate(ctx, sess, srv.config(), srv.store, srv.registry)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := &blockingClient{started: make(chan struct{}, 1)}
+	as.Agent.SetClient(client)
+
+	ts := httptest.NewServer(srv.router)
+	defer ts.Close()
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(ts.URL, "http")+"/api/sessions/wsqueue/ws", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+
+	conn.WriteJSON(wsIncoming{Type: "message", Content: "first"})
+	<-client.started
+	conn.WriteJSON(wsIncoming{Type: "message", Content: "second"})
+
+	var events []string
+	read := func() {
+		var out wsOutgoing
+		if err := conn.ReadJSON(&out); err != nil {
+			t.Fatal(err)
+		}
+		events = append(events, fmt.Sprintf("%s %s %d", out.Type, out.Content, out.Position))
+	}
+	read()
+	conn.WriteJSON(wsIncoming{Type: "cancel"})
+	read()
+	read()
+	<-client.started
+	conn.WriteJSON(wsIncoming{Type: "cancel"})
+	read()
+
+	want := "queued second 1|error interrupted 0|started second 0|error interrupted 0"
+	if got := strings.Join(events, "|"); got != want {
+		t.Errorf("events = %s, want %s", got, want)
+	}
+}
+
+// pausingClient streams "a", then waits for release before streaming "b"
+// and "c".
+type pausingClient struct {
+	paused  chan struct{}
+	release chan struct{}
+}
+
+func (c *pausingClient) ChatCompletion(ctx context.Context, messages []llm.Message, tools []llm.ToolDef) (*llm.Response, error) {
+	return c.ChatCompletionStream(ctx, messages, tools, func(string) {})
+}
+
+func (c *pausingClient) ChatCompletionStream(ctx context.Context, messages []llm.Message, tools []llm.ToolDef, handler llm.StreamHandler) (*llm.Response, error) {
+	handler("a")
+	close(c.paused)
+	select {
+	case <-c.release:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	handler("b")
+	handler("c")
+	return &llm.Response{Message: llm.AssistantMessage("abc")}, nil
+}
+
+func TestWebSocketResume(t *testing.T) {
+	srv := newTestServer(t)
+	ctx := context.Background()
+	sess := &storage.Session{ID: "wsresume", Title: "Flaky", Status: storage.StatusActive}
+	srv.store.CreateSession(ctx, sess)
+	as, err := srv.sessions.GetOrCreate(ctx, sess, srv.config(), srv.store, srv.registry)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := &pausingClient{paused: make(chan struct{}), release: make(chan struct{})}
+	as.Agent.SetClient(client)
+
+	ts := httptest.NewServer(srv.router)
+	defer ts.Close()
+	dial := func() *websocket.Conn {
+		conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(ts.URL, "http")+"/api/sessions/wsresume/ws", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+		return conn
+	}
+	// readTurn reads events until the turn is done, returning their types
+	// and the text streamed.
+	readTurn := func(conn *websocket.Conn) (types []string, text string) {
+		for {
+			var out wsOutgoing
+			if err := conn.ReadJSON(&out); err != nil {
+				t.Fatal(err)
+			}
+			types = append(types, out.Type)
+			if out.Type == "text_delta" {
+				text += out.Content
+			}
+			if out.Type == "done" || out.Type == "error" {
+				return types, text
+			}
+		}
+	}
+
+	// The first connection drops after the first delta.
+	conn := dial()
+	conn.WriteJSON(wsIncoming{Type: "message", Content: "go"})
+	var first wsOutgoing
+	if err := conn.ReadJSON(&first); err != nil {
+		t.Fatal(err)
+	}
+	if first.Type != "text_delta" || first.Content != "a" || first.Index == 0 {
+		t.Fatalf("first event = %+v", first)
+	}
+	<-client.paused
+	conn.Close()
+	close(client.release)
+
+	// Resuming picks up where it left off.
+	conn = dial()
+	defer conn.Close()
+	conn.WriteJSON(wsIncoming{Type: "resume", LastEvent: first.Index})
+	if _, text := readTurn(conn); text != "bc" {
+		t.Errorf("resumed text = %q, want bc", text)
+	}
+	conn.WriteJSON(wsIncoming{Type: "resume"})
+	var out wsOutgoing
+	if err := conn.ReadJSON(&out); err != nil {
+		t.Fatal(err)
+	}
+	if out.Type != "error" {
+		t.Errorf("second resume got %+v", out)
+	}
+
+	// An index the stream never reached means the client has to resync,
+	// and gets the whole turn.
+	other := dial()
+	defer other.Close()
+	other.WriteJSON(wsIncoming{Type: "resume", LastEvent: first.Index + 100})
+	types, text := readTurn(other)
+	if types[0] != "resync" || text != "abc" {
+		t.Errorf("resync got %v with text %q", types, text)
+	}
+}
+
+func TestWebSocketKeepalive(t *testing.T) {
+	srv := newTestServer(t)
+	srv.pongWait = 100 * time.Millisecond
+	srv.store.CreateSession(context.Background(), &storage.Session{ID: "wsping", Status: storage.StatusActive})
+	ts := httptest.NewServer(srv.router)
+	defer ts.Close()
+	url := "ws" + strings.TrimPrefix(ts.URL, "http") + "/api/sessions/wsping/ws"
+
+	// A client that keeps reading answers pings and stays connected.
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	pings := make(chan struct{}, 100)
+	conn.SetPingHandler(func(data string) error {
+		pings <- struct{}{}
+		return conn.WriteControl(websocket.PongMessage, []byte(data), time.Now().Add(time.Second))
+	})
+	replies := make(chan wsOutgoing)
+	go func() {
+		for {
+			var out wsOutgoing
+			if err := conn.ReadJSON(&out); err != nil {
+				close(replies)
+				return
+			}
+			replies <- out
+		}
+	}()
+	time.Sleep(3 * srv.pongWait)
+	conn.WriteJSON(wsIncoming{Type: "cancel"})
+	if out, ok := <-replies; !ok || out.Content != "nothing to cancel" {
+		t.Errorf("after %v got %+v, %v", 3*srv.pongWait, out, ok)
+	}
+	if len(pings) == 0 {
+		t.Error("no pings")
+	}
+
+	// One that doesn't is disconnected.
+	dead, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dead.Close()
+	dead.SetPingHandler(func(string) error { return nil })
+	dead.SetReadDeadline(time.Now().Add(5 * time.Second))
+	if _, _, err := dead.ReadMessage(); err == nil || strings.Contains(err.Error(), "timeout") {
+		t.Errorf("unanswered pings: read got %v, want the connection closed", err)
+	}
+}
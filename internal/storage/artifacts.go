@@ -0,0 +1,125 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"mime"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/michaelbrown/forge/internal/tools"
+)
+
+// MaxArtifactSize is the largest file Artifacts will keep.
+const MaxArtifactSize = 25 << 20
+
+// Artifacts keeps files saved during sessions: their contents on disk,
+// one directory per session under dir, and their records in the store.
+type Artifacts struct {
+	store Store
+	dir   string
+}
+
+// NewArtifacts returns an artifact store that records artifacts in store
+// and writes their contents under dir.
+func NewArtifacts(store Store, dir string) *Artifacts {
+	return &Artifacts{store: store, dir: dir}
+}
+
+// Save writes data as an artifact of a session. If the session already has
+// an artifact with the name, a number is added to it, so nothing saved is
+// replaced.
+func (a *Artifacts) Save(ctx context.Context, sessionID, name, mimeType, tool string, data []byte) (*Artifact, error) {
+	if a.dir == "" {
+		return nil, fmt.Errorf("no artifacts directory is configured")
+	}
+	if len(data) > MaxArtifactSize {
+		return nil, fmt.Errorf("artifact is larger than %d MB", MaxArtifactSize>>20)
+	}
+	name = cleanArtifactName(name)
+	if mimeType == "" {
+		mimeType = mime.TypeByExtension(filepath.Ext(name))
+	}
+
+	dir := filepath.Join(a.dir, sessionID)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("creating artifacts directory: %w", err)
+	}
+
+	ext := filepath.Ext(name)
+	base := strings.TrimSuffix(name, ext)
+	for n := 1; n <= 100; n++ {
+		candidate := name
+		if n > 1 {
+			candidate = fmt.Sprintf("%s-%d%s", base, n, ext)
+		}
+		if _, err := a.store.GetArtifact(ctx, sessionID, candidate); err == nil {
+			continue
+		}
+		art := &Artifact{SessionID: sessionID, Name: candidate, MIMEType: mimeType, Size: int64(len(data)), Tool: tool}
+		path := filepath.Join(dir, candidate)
+		if err := os.WriteFile(path, data, 0o644); err != nil {
+			return nil, fmt.Errorf("writing artifact: %w", err)
+		}
+		if err := a.store.CreateArtifact(ctx, art); err != nil {
+			os.Remove(path)
+			return nil, err
+		}
+		return art, nil
+	}
+	return nil, fmt.Errorf("session already has too many artifacts named %s", name)
+}
+
+// Saver adapts Save for tools.Registry.SetArtifactSaver.
+func (a *Artifacts) Saver() tools.ArtifactSaver {
+	return func(ctx context.Context, sessionID string, art tools.Artifact) (string, error) {
+		saved, err := a.Save(ctx, sessionID, art.Name, art.MIMEType, art.Tool, art.Data)
+		if err != nil {
+			return "", err
+		}
+		return saved.Name, nil
+	}
+}
+
+// Open returns a session's artifact and the path of its contents.
+func (a *Artifacts) Open(ctx context.Context, sessionID, name string) (*Artifact, string, error) {
+	art, err := a.store.GetArtifact(ctx, sessionID, name)
+	if err != nil {
+		return nil, "", err
+	}
+	path := filepath.Join(a.dir, sessionID, art.Name)
+	if _, err := os.Stat(path); err != nil {
+		return nil, "", fmt.Errorf("artifact %s: %w", name, err)
+	}
+	return art, path, nil
+}
+
+// RemoveSession deletes the contents of a session's artifacts. Their
+// records go with the session in Store.DeleteSession.
+func (a *Artifacts) RemoveSession(sessionID string) error {
+	if a.dir == "" || sessionID == "" {
+		return nil
+	}
+	return os.RemoveAll(filepath.Join(a.dir, sessionID))
+}
+
+// cleanArtifactName reduces name to a plain file name that's safe to use as
+// a path element.
+func cleanArtifactName(name string) string {
+	name = filepath.Base(filepath.FromSlash(strings.ReplaceAll(name, `\`, "/")))
+	name = strings.Map(func(r rune) rune {
+		if r < 0x20 || r == 0x7f || strings.ContainsRune(`/\:*?"<>|`, r) {
+			return '_'
+		}
+		return r
+	}, name)
+	name = strings.TrimLeft(name, ".")
+	if r := []rune(name); len(r) > 128 {
+		name = string(r[len(r)-128:])
+	}
+	if name == "" {
+		name = "artifact"
+	}
+	return name
+}
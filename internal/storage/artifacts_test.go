@@ -0,0 +1,78 @@
+package storage_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/michaelbrown/forge/internal/storage"
+	"github.com/michaelbrown/forge/internal/storage/sqlite"
+	"github.com/michaelbrown/forge/internal/tools"
+)
+
+func TestArtifactsSave(t *testing.T) {
+	store, err := sqlite.Open(":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	ctx := context.Background()
+	store.CreateSession(ctx, &storage.Session{ID: "s1", Status: storage.StatusActive})
+
+	dir := t.TempDir()
+	arts := storage.NewArtifacts(store, dir)
+
+	a, err := arts.Save(ctx, "s1", "out/report.csv", "", "sandbox_exec", []byte("a,b\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.Name != "report.csv" || a.MIMEType != "text/csv; charset=utf-8" || a.Size != 4 {
+		t.Errorf("Save = %+v", a)
+	}
+
+	// Saving the same name again keeps both.
+	save := arts.Saver()
+	name, err := save(ctx, "s1", tools.Artifact{Name: "report.csv", Data: []byte("c,d\n")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if name != "report-2.csv" {
+		t.Errorf("second save named %q, want report-2.csv", name)
+	}
+
+	_, path, err := arts.Open(ctx, "s1", "report.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(path); string(data) != "a,b\n" {
+		t.Errorf("contents = %q", data)
+	}
+
+	for in, want := range map[string]string{
+		"../../etc/passwd": "passwd",
+		`C:\tmp\x.txt`:     "x.txt",
+		"..hidden":         "hidden",
+		"a?b*.txt":         "a_b_.txt",
+		"..":               "artifact",
+	} {
+		a, err := arts.Save(ctx, "s1", in, "", "", []byte("x"))
+		if err != nil {
+			t.Fatalf("Save(%q): %v", in, err)
+		}
+		if a.Name != want {
+			t.Errorf("Save(%q) named %q, want %q", in, a.Name, want)
+		}
+	}
+
+	if _, err := arts.Save(ctx, "s1", "big.bin", "", "", make([]byte, storage.MaxArtifactSize+1)); err == nil {
+		t.Error("expected error for oversized artifact")
+	}
+
+	if err := arts.RemoveSession("s1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "s1")); !os.IsNotExist(err) {
+		t.Errorf("session directory still exists: %v", err)
+	}
+}
@@ -0,0 +1,71 @@
+package storage
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/michaelbrown/forge/internal/llm"
+)
+
+// Cursors mark a place in a listing, so the next page starts after the last
+// item seen even if items were added in the meantime. They are opaque to
+// clients.
+
+// SessionCursor returns the cursor for the sessions that follow sess in
+// ListSessions order.
+func SessionCursor(sess Session) string {
+	key := sess.UpdatedAt.UTC().Format(time.RFC3339) + " " + sess.ID
+	return base64.RawURLEncoding.EncodeToString([]byte(key))
+}
+
+// ParseSessionCursor decodes a cursor returned by SessionCursor.
+func ParseSessionCursor(cursor string) (updatedAt time.Time, id string, err error) {
+	key, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return time.Time{}, "", fmt.Errorf("invalid cursor %q", cursor)
+	}
+	at, id, ok := strings.Cut(string(key), " ")
+	if !ok || id == "" {
+		return time.Time{}, "", fmt.Errorf("invalid cursor %q", cursor)
+	}
+	updatedAt, err = time.Parse(time.RFC3339, at)
+	if err != nil {
+		return time.Time{}, "", fmt.Errorf("invalid cursor %q", cursor)
+	}
+	return updatedAt, id, nil
+}
+
+// MessageCursor returns the cursor for the messages stored after the
+// message with row ID id.
+func MessageCursor(id int64) string {
+	return base64.RawURLEncoding.EncodeToString([]byte("m" + strconv.FormatInt(id, 10)))
+}
+
+// ParseMessageCursor decodes a cursor returned by MessageCursor.
+func ParseMessageCursor(cursor string) (int64, error) {
+	key, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil || !strings.HasPrefix(string(key), "m") {
+		return 0, fmt.Errorf("invalid cursor %q", cursor)
+	}
+	id, err := strconv.ParseInt(string(key[1:]), 10, 64)
+	if err != nil || id < 0 {
+		return 0, fmt.Errorf("invalid cursor %q", cursor)
+	}
+	return id, nil
+}
+
+// MessageListOptions controls pagination for ListMessages.
+type MessageListOptions struct {
+	Cursor string // from a previous page's Next; empty starts at the first message
+	Limit  int    // 0 for no limit
+}
+
+// MessagePage is a page of a session's messages, oldest first.
+type MessagePage struct {
+	Messages []llm.Message
+	Next     string // cursor for the following page, empty on the last page
+	Total    int    // messages in the session
+}
@@ -19,6 +19,9 @@ func ExportMarkdown(sess *Session, messages []llm.Message) string {
 	if sess.Profile != "" {
 		b.WriteString(fmt.Sprintf("- **Profile:** %s\n", sess.Profile))
 	}
+	if len(sess.Tags) > 0 {
+		b.WriteString(fmt.Sprintf("- **Tags:** %s\n", strings.Join(sess.Tags, ", ")))
+	}
 	b.WriteString(fmt.Sprintf("- **Created:** %s\n", sess.CreatedAt.Format("2006-01-02 15:04:05")))
 	b.WriteString(fmt.Sprintf("- **Status:** %s\n", sess.Status))
 	b.WriteString("\n---\n\n")
@@ -45,14 +48,46 @@ func ExportMarkdown(sess *Session, messages []llm.Message) string {
 	return b.String()
 }
 
+// SessionExport is a session and its messages as written by ExportJSON.
+type SessionExport struct {
+	Session  *Session      `json:"session"`
+	Messages []llm.Message `json:"messages"`
+}
+
 // ExportJSON renders a session and its messages as formatted JSON.
 func ExportJSON(sess *Session, messages []llm.Message) ([]byte, error) {
-	export := struct {
-		Session  *Session      `json:"session"`
-		Messages []llm.Message `json:"messages"`
-	}{
-		Session:  sess,
-		Messages: messages,
-	}
-	return json.MarshalIndent(export, "", "  ")
+	return json.MarshalIndent(SessionExport{Session: sess, Messages: messages}, "", "  ")
+}
+
+// ParseExport reads a session written by ExportJSON so it can be imported.
+// The caller gives the session a new ID. A session exported while running
+// comes back active, since nothing is running it any more.
+func ParseExport(data []byte) (*SessionExport, error) {
+	var export SessionExport
+	if err := json.Unmarshal(data, &export); err != nil {
+		return nil, fmt.Errorf("reading session export: %w", err)
+	}
+	if export.Session == nil {
+		return nil, fmt.Errorf("not a session export: no session")
+	}
+	for i, m := range export.Messages {
+		switch m.Role {
+		case llm.RoleSystem, llm.RoleUser, llm.RoleAssistant, llm.RoleTool:
+		default:
+			return nil, fmt.Errorf("message %d has invalid role %q", i+1, m.Role)
+		}
+	}
+
+	sess := export.Session
+	switch sess.Status {
+	case StatusActive, StatusCompleted, StatusFailed, StatusArchived:
+	default:
+		sess.Status = StatusActive
+	}
+	tags, err := NormalizeTags(sess.Tags)
+	if err != nil {
+		return nil, err
+	}
+	sess.Tags = tags
+	return &export, nil
 }
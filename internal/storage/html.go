@@ -0,0 +1,302 @@
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+	"html"
+	"regexp"
+	"strings"
+
+	"github.com/michaelbrown/forge/internal/llm"
+)
+
+// ExportHTML renders a session and its messages as a standalone HTML page,
+// with messages' markdown formatted and code blocks highlighted.
+func ExportHTML(sess *Session, messages []llm.Message) string {
+	var b strings.Builder
+	title := html.EscapeString(sess.Title)
+	fmt.Fprintf(&b, "<!DOCTYPE html>\n<html lang=\"en\">\n<head>\n<meta charset=\"utf-8\">\n<title>%s</title>\n<style>%s</style>\n</head>\n<body>\n", title, exportCSS)
+	fmt.Fprintf(&b, "<h1>%s</h1>\n<dl class=\"meta\">\n", title)
+	meta := [][2]string{
+		{"Session", sess.ID},
+		{"Provider", sess.Provider},
+		{"Model", sess.Model},
+		{"Profile", sess.Profile},
+		{"Tags", strings.Join(sess.Tags, ", ")},
+		{"Created", sess.CreatedAt.Format("2006-01-02 15:04:05")},
+		{"Status", string(sess.Status)},
+	}
+	for _, kv := range meta {
+		if kv[1] != "" {
+			fmt.Fprintf(&b, "<dt>%s</dt><dd>%s</dd>\n", kv[0], html.EscapeString(kv[1]))
+		}
+	}
+	b.WriteString("</dl>\n")
+
+	for _, m := range messages {
+		switch m.Role {
+		case llm.RoleUser:
+			fmt.Fprintf(&b, "<section class=\"user\">\n<h2>You</h2>\n%s</section>\n", renderMarkdown(m.Content))
+		case llm.RoleAssistant:
+			if m.Content == "" && len(m.ToolCalls) == 0 {
+				continue
+			}
+			b.WriteString("<section class=\"assistant\">\n<h2>Forge</h2>\n")
+			b.WriteString(renderMarkdown(m.Content))
+			for _, tc := range m.ToolCalls {
+				args, _ := json.MarshalIndent(tc.Args, "", "  ")
+				fmt.Fprintf(&b, "<details class=\"tool\"><summary>Tool call: <code>%s</code></summary>\n%s</details>\n",
+					html.EscapeString(tc.Name), codeBlock("json", string(args)))
+			}
+			b.WriteString("</section>\n")
+		case llm.RoleTool:
+			fmt.Fprintf(&b, "<details class=\"tool\"><summary>Tool result</summary>\n%s</details>\n", codeBlock("", m.Content))
+		}
+	}
+	b.WriteString("</body>\n</html>\n")
+	return b.String()
+}
+
+const exportCSS = `
+body{max-width:50rem;margin:2rem auto;padding:0 1rem;font:16px/1.55 system-ui,sans-serif;color:#1f2328}
+h1{font-size:1.6rem}h2{font-size:.85rem;text-transform:uppercase;letter-spacing:.05em;color:#59636e;margin:0 0 .5rem}
+.meta{display:grid;grid-template-columns:max-content 1fr;gap:.2rem 1rem;font-size:.9rem;color:#59636e}
+.meta dt{font-weight:600}.meta dd{margin:0}
+section{border-top:1px solid #d1d9e0;padding:1rem 0}section.user{background:#f6f8fa;padding:1rem;border-radius:6px;border:0;margin:1rem 0}
+pre{background:#f6f8fa;border:1px solid #d1d9e0;border-radius:6px;padding:.75rem;overflow-x:auto;font-size:.85rem}
+code{font-family:ui-monospace,SFMono-Regular,Menlo,monospace}p code,li code{background:#eff1f3;padding:.1em .3em;border-radius:4px}
+details.tool{margin:.5rem 0;font-size:.9rem}summary{cursor:pointer;color:#59636e}
+blockquote{margin:0;padding-left:1rem;border-left:3px solid #d1d9e0;color:#59636e}
+.k{color:#cf222e}.s{color:#0a3069}.c{color:#6e7781;font-style:italic}.n{color:#0550ae}
+`
+
+// renderMarkdown formats the common parts of markdown as HTML: headings,
+// paragraphs, lists, quotes, rules, fenced code, and inline code, emphasis,
+// and links. Anything else shows as text.
+func renderMarkdown(src string) string {
+	var b strings.Builder
+	lines := strings.Split(strings.ReplaceAll(src, "\r\n", "\n"), "\n")
+	var para []string
+	flush := func() {
+		if len(para) > 0 {
+			fmt.Fprintf(&b, "<p>%s</p>\n", renderInline(strings.Join(para, "\n")))
+			para = nil
+		}
+	}
+
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case trimmed == "":
+			flush()
+		case strings.HasPrefix(trimmed, "```"):
+			flush()
+			lang := strings.TrimSpace(strings.TrimPrefix(trimmed, "```"))
+			var code []string
+			for i++; i < len(lines) && !strings.HasPrefix(strings.TrimSpace(lines[i]), "```"); i++ {
+				code = append(code, lines[i])
+			}
+			b.WriteString(codeBlock(lang, strings.Join(code, "\n")))
+		case headingRE.MatchString(trimmed):
+			flush()
+			m := headingRE.FindStringSubmatch(trimmed)
+			fmt.Fprintf(&b, "<h%d>%s</h%d>\n", len(m[1])+2, renderInline(m[2]), len(m[1])+2)
+		case ruleRE.MatchString(trimmed):
+			flush()
+			b.WriteString("<hr>\n")
+		case strings.HasPrefix(trimmed, ">"):
+			flush()
+			var quote []string
+			for ; i < len(lines) && strings.HasPrefix(strings.TrimSpace(lines[i]), ">"); i++ {
+				quote = append(quote, strings.TrimPrefix(strings.TrimPrefix(strings.TrimSpace(lines[i]), ">"), " "))
+			}
+			i--
+			fmt.Fprintf(&b, "<blockquote>\n%s</blockquote>\n", renderMarkdown(strings.Join(quote, "\n")))
+		case bulletRE.MatchString(line) || numberRE.MatchString(line):
+			flush()
+			re, tag := bulletRE, "ul"
+			if !bulletRE.MatchString(line) {
+				re, tag = numberRE, "ol"
+			}
+			fmt.Fprintf(&b, "<%s>\n", tag)
+			for ; i < len(lines) && re.MatchString(lines[i]); i++ {
+				fmt.Fprintf(&b, "<li>%s</li>\n", renderInline(re.ReplaceAllString(lines[i], "")))
+			}
+			i--
+			fmt.Fprintf(&b, "</%s>\n", tag)
+		default:
+			para = append(para, trimmed)
+		}
+	}
+	flush()
+	return b.String()
+}
+
+var (
+	headingRE = regexp.MustCompile(`^(#{1,6})\s+(.*)$`)
+	ruleRE    = regexp.MustCompile(`^(-{3,}|\*{3,}|_{3,})$`)
+	bulletRE  = regexp.MustCompile(`^\s*[-*+]\s+`)
+	numberRE  = regexp.MustCompile(`^\s*\d+[.)]\s+`)
+
+	inlineCodeRE = regexp.MustCompile("`([^`]+)`")
+	boldRE       = regexp.MustCompile(`\*\*([^*]+)\*\*|__([^_]+)__`)
+	italicRE     = regexp.MustCompile(`\*([^*\s][^*]*)\*|\b_([^_\s][^_]*)_\b`)
+	linkRE       = regexp.MustCompile(`\[([^\]]+)\]\(((?:https?://|mailto:)[^)\s]+)\)`)
+)
+
+// renderInline formats a run of text. Code spans are set aside first, so
+// nothing inside them is formatted.
+func renderInline(text string) string {
+	var spans []string
+	text = inlineCodeRE.ReplaceAllStringFunc(text, func(m string) string {
+		spans = append(spans, "<code>"+html.EscapeString(m[1:len(m)-1])+"</code>")
+		return fmt.Sprintf("\x00%d\x00", len(spans)-1)
+	})
+	text = html.EscapeString(text)
+	text = linkRE.ReplaceAllString(text, `<a href="$2">$1</a>`)
+	text = boldRE.ReplaceAllString(text, "<strong>$1$2</strong>")
+	text = italicRE.ReplaceAllString(text, "<em>$1$2</em>")
+	text = strings.ReplaceAll(text, "\n", "<br>\n")
+	for i, span := range spans {
+		text = strings.Replace(text, fmt.Sprintf("\x00%d\x00", i), span, 1)
+	}
+	return text
+}
+
+// codeBlock renders code as a pre block, highlighted if lang is one
+// highlightCode knows.
+func codeBlock(lang, code string) string {
+	class := ""
+	if lang != "" {
+		class = fmt.Sprintf(` class="language-%s"`, html.EscapeString(lang))
+	}
+	return fmt.Sprintf("<pre><code%s>%s</code></pre>\n", class, highlightCode(lang, code))
+}
+
+// syntax describes a language well enough to highlight it: its keywords
+// and how its comments start.
+type syntax struct {
+	keywords map[string]bool
+	comments []string // line comment markers
+	block    bool     // has /* */ comments
+}
+
+func words(s string) map[string]bool {
+	m := make(map[string]bool)
+	for _, w := range strings.Fields(s) {
+		m[w] = true
+	}
+	return m
+}
+
+var (
+	cLike  = []string{"//"}
+	hashes = []string{"#"}
+
+	syntaxes = map[string]syntax{
+		"go":         {words("break case chan const continue default defer else fallthrough for func go goto if import interface map package range return select struct switch type var nil true false"), cLike, true},
+		"python":     {words("and as assert async await break class continue def del elif else except finally for from global if import in is lambda nonlocal not or pass raise return try while with yield None True False"), hashes, false},
+		"javascript": {words("async await break case catch class const continue default delete do else export extends finally for from function if import in instanceof let new of return switch this throw try typeof var void while yield null undefined true false"), cLike, true},
+		"typescript": {words("async await break case catch class const continue default delete do else enum export extends finally for from function if implements import in instanceof interface let new of return switch this throw try type typeof var void while yield null undefined true false"), cLike, true},
+		"rust":       {words("as async await break const continue crate else enum extern fn for if impl in let loop match mod move mut pub ref return self Self static struct super trait type unsafe use where while true false"), cLike, true},
+		"java":       {words("abstract boolean break case catch class continue default do double else enum extends final finally float for if implements import instanceof int interface long new package private protected public return static super switch this throw throws try void while null true false"), cLike, true},
+		"c":          {words("auto break case char const continue default do double else enum extern float for goto if int long register return short signed sizeof static struct switch typedef union unsigned void volatile while NULL"), cLike, true},
+		"bash":       {words("if then else elif fi for while until do done case esac in function return local export echo"), hashes, false},
+		"sql":        {words("select from where insert into values update set delete create table drop alter index join left right inner outer on group by order having limit and or not null as distinct SELECT FROM WHERE INSERT INTO VALUES UPDATE SET DELETE CREATE TABLE DROP ALTER INDEX JOIN LEFT RIGHT INNER OUTER ON GROUP BY ORDER HAVING LIMIT AND OR NOT NULL AS DISTINCT"), []string{"--"}, true},
+		"json":       {words("true false null"), nil, false},
+		"yaml":       {words("true false null yes no"), hashes, false},
+	}
+
+	aliases = map[string]string{
+		"golang": "go", "py": "python", "js": "javascript", "jsx": "javascript", "ts": "typescript", "tsx": "typescript",
+		"rs": "rust", "cpp": "c", "c++": "c", "h": "c", "sh": "bash", "shell": "bash", "zsh": "bash", "yml": "yaml",
+	}
+)
+
+// highlightCode escapes code and wraps its keywords, strings, comments, and
+// numbers in spans with classes k, s, c, and n.
+func highlightCode(lang, code string) string {
+	lang = strings.ToLower(lang)
+	if a, ok := aliases[lang]; ok {
+		lang = a
+	}
+	syn, ok := syntaxes[lang]
+	if !ok {
+		return html.EscapeString(code)
+	}
+
+	var b strings.Builder
+	span := func(class, text string) {
+		fmt.Fprintf(&b, `<span class="%s">%s</span>`, class, html.EscapeString(text))
+	}
+	for i := 0; i < len(code); {
+		rest := code[i:]
+		c := code[i]
+		switch {
+		case syn.block && strings.HasPrefix(rest, "/*"):
+			end := strings.Index(rest[2:], "*/")
+			n := len(rest)
+			if end >= 0 {
+				n = end + 4
+			}
+			span("c", rest[:n])
+			i += n
+		case hasAnyPrefix(rest, syn.comments):
+			n := strings.IndexByte(rest, '\n')
+			if n < 0 {
+				n = len(rest)
+			}
+			span("c", rest[:n])
+			i += n
+		case c == '"' || c == '\'' || c == '`':
+			n := 1
+			for n < len(rest) && rest[n] != c && (c == '`' || rest[n] != '\n') {
+				if rest[n] == '\\' && c != '`' {
+					n++
+				}
+				n++
+			}
+			n = min(n+1, len(rest))
+			span("s", rest[:n])
+			i += n
+		case isDigit(c):
+			n := 1
+			for n < len(rest) && (isWordByte(rest[n]) || rest[n] == '.') {
+				n++
+			}
+			span("n", rest[:n])
+			i += n
+		case isWordByte(c):
+			n := 1
+			for n < len(rest) && isWordByte(rest[n]) {
+				n++
+			}
+			if syn.keywords[rest[:n]] {
+				span("k", rest[:n])
+			} else {
+				b.WriteString(html.EscapeString(rest[:n]))
+			}
+			i += n
+		default:
+			b.WriteString(html.EscapeString(rest[:1]))
+			i++
+		}
+	}
+	return b.String()
+}
+
+func hasAnyPrefix(s string, prefixes []string) bool {
+	for _, p := range prefixes {
+		if strings.HasPrefix(s, p) {
+			return true
+		}
+	}
+	return false
+}
+
+func isDigit(c byte) bool { return c >= '0' && c <= '9' }
+
+func isWordByte(c byte) bool {
+	return c == '_' || isDigit(c) || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
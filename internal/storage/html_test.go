@@ -0,0 +1,43 @@
+package storage_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/michaelbrown/forge/internal/llm"
+	"github.com/michaelbrown/forge/internal/storage"
+)
+
+func TestExportHTML(t *testing.T) {
+	sess := &storage.Session{ID: "s1", Title: "Fix <the> build", Provider: "claude", Status: storage.StatusActive}
+	reply := llm.AssistantMessage("## Plan\n\nRun **this** and see [docs](https://example.com):\n\n```go\n// check it\nif err != nil {\n\treturn \"oops\"\n}\n```\n\n- one `a<b`\n- two")
+	reply.ToolCalls = []llm.ToolCall{{ID: "c1", Name: "shell_exec", Args: map[string]any{"command": "make"}}}
+	messages := []llm.Message{
+		llm.SystemMessage("secret system prompt"),
+		llm.UserMessage("why does <script>alert(1)</script> fail?"),
+		reply,
+		{Role: llm.RoleTool, Content: "ok <done>", ToolCallID: "c1"},
+	}
+
+	out := storage.ExportHTML(sess, messages)
+	for _, want := range []string{
+		"<title>Fix &lt;the&gt; build</title>",
+		"why does &lt;script&gt;alert(1)&lt;/script&gt; fail?",
+		"<h4>Plan</h4>",
+		"<strong>this</strong>",
+		`<a href="https://example.com">docs</a>`,
+		`<pre><code class="language-go"><span class="c">// check it</span>`,
+		`<span class="k">if</span> err != <span class="k">nil</span>`,
+		`<span class="s">&#34;oops&#34;</span>`,
+		"<li>one <code>a&lt;b</code></li>",
+		"<code>shell_exec</code>",
+		"ok &lt;done&gt;",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("export is missing %q", want)
+		}
+	}
+	if strings.Contains(out, "secret system prompt") || strings.Contains(out, "<script>") {
+		t.Error("export has the system prompt or unescaped HTML")
+	}
+}
@@ -0,0 +1,66 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/michaelbrown/forge/internal/storage"
+)
+
+const artifactColumns = `session_id, name, mime_type, size, tool, created_at`
+
+func (s *SQLiteStore) CreateArtifact(ctx context.Context, a *storage.Artifact) error {
+	if a.CreatedAt.IsZero() {
+		a.CreatedAt = time.Now().UTC()
+	}
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO artifacts (session_id, name, mime_type, size, tool, created_at) VALUES (?, ?, ?, ?, ?, ?)`,
+		a.SessionID, a.Name, a.MIMEType, a.Size, a.Tool, a.CreatedAt.UTC().Format(sortableTimeFormat),
+	)
+	if err != nil {
+		return fmt.Errorf("recording artifact: %w", err)
+	}
+	return nil
+}
+
+func (s *SQLiteStore) ListArtifacts(ctx context.Context, sessionID string) ([]storage.Artifact, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT `+artifactColumns+` FROM artifacts WHERE session_id = ? ORDER BY id`, sessionID)
+	if err != nil {
+		return nil, fmt.Errorf("listing artifacts: %w", err)
+	}
+	defer rows.Close()
+
+	var artifacts []storage.Artifact
+	for rows.Next() {
+		a, err := scanArtifact(rows)
+		if err != nil {
+			return nil, err
+		}
+		artifacts = append(artifacts, *a)
+	}
+	return artifacts, rows.Err()
+}
+
+func (s *SQLiteStore) GetArtifact(ctx context.Context, sessionID, name string) (*storage.Artifact, error) {
+	row := s.db.QueryRowContext(ctx, `
+		SELECT `+artifactColumns+` FROM artifacts WHERE session_id = ? AND name = ?`, sessionID, name)
+	a, err := scanArtifact(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("artifact not found: %s", name)
+	}
+	return a, err
+}
+
+func scanArtifact(row scanner) (*storage.Artifact, error) {
+	var a storage.Artifact
+	var created string
+	if err := row.Scan(&a.SessionID, &a.Name, &a.MIMEType, &a.Size, &a.Tool, &created); err != nil {
+		return nil, err
+	}
+	a.CreatedAt, _ = time.Parse(time.RFC3339, created)
+	return &a, nil
+}
@@ -1,8 +1,11 @@
 package sqlite
 
-import "database/sql"
+import (
+	"context"
+	"database/sql"
+)
 
-const schemaVersion = 1
+const schemaVersion = 11
 
 const schemaV1 = `
 CREATE TABLE IF NOT EXISTS schema_version (
@@ -31,6 +34,233 @@ CREATE TABLE IF NOT EXISTS session_messages (
 );
 `
 
+const schemaV2 = `
+CREATE TABLE IF NOT EXISTS tool_calls (
+    id          INTEGER PRIMARY KEY AUTOINCREMENT,
+    tool        TEXT NOT NULL,
+    server      TEXT NOT NULL DEFAULT '',
+    duration_ms REAL NOT NULL DEFAULT 0,
+    failed      INTEGER NOT NULL DEFAULT 0,
+    created_at  DATETIME NOT NULL DEFAULT (datetime('now'))
+);
+
+CREATE INDEX IF NOT EXISTS idx_tool_calls_created ON tool_calls(created_at);
+`
+
+const schemaV3 = `
+CREATE TABLE IF NOT EXISTS scheduled_tasks (
+    id         INTEGER PRIMARY KEY AUTOINCREMENT,
+    session_id TEXT NOT NULL DEFAULT '',
+    title      TEXT NOT NULL DEFAULT '',
+    prompt     TEXT NOT NULL,
+    run_at     DATETIME NOT NULL,
+    status     TEXT NOT NULL DEFAULT 'pending'
+               CHECK(status IN ('pending','running','done','failed','cancelled')),
+    result     TEXT NOT NULL DEFAULT '',
+    created_at DATETIME NOT NULL DEFAULT (datetime('now')),
+    updated_at DATETIME NOT NULL DEFAULT (datetime('now'))
+);
+
+CREATE INDEX IF NOT EXISTS idx_scheduled_tasks_due ON scheduled_tasks(status, run_at);
+`
+
+// schemaV4 moves message histories from one JSON array per session to a row
+// per message, so a turn appends its messages instead of rewriting the
+// history. Token counts for migrated messages are approximate.
+const schemaV4 = `
+CREATE TABLE IF NOT EXISTS messages (
+    id         INTEGER PRIMARY KEY AUTOINCREMENT,
+    session_id TEXT NOT NULL REFERENCES sessions(id) ON DELETE CASCADE,
+    seq        INTEGER NOT NULL,
+    role       TEXT NOT NULL DEFAULT '',
+    data       TEXT NOT NULL,
+    tokens     INTEGER NOT NULL DEFAULT 0,
+    created_at DATETIME NOT NULL DEFAULT (datetime('now')),
+    UNIQUE(session_id, seq)
+);
+
+INSERT INTO messages (session_id, seq, role, data, tokens, created_at)
+SELECT m.session_id, CAST(j.key AS INTEGER),
+       COALESCE(json_extract(j.value, '$.role'), ''), j.value,
+       MAX(1, (length(COALESCE(json_extract(j.value, '$.content'), '')) +
+               length(COALESCE(json_extract(j.value, '$.tool_calls'), ''))) / 4),
+       m.updated_at
+FROM session_messages m, json_each(m.messages) j;
+
+DROP TABLE session_messages;
+`
+
+// sessionFTSTriggers keep session_fts in step with sessions, by rowid.
+const sessionFTSTriggers = `
+CREATE TRIGGER IF NOT EXISTS sessions_fts_insert AFTER INSERT ON sessions
+BEGIN
+    INSERT INTO session_fts (rowid, session_id, title) VALUES (new.rowid, new.id, new.title);
+END;
+
+CREATE TRIGGER IF NOT EXISTS sessions_fts_update AFTER UPDATE OF title ON sessions
+BEGIN
+    DELETE FROM session_fts WHERE rowid = old.rowid;
+    INSERT INTO session_fts (rowid, session_id, title) VALUES (new.rowid, new.id, new.title);
+END;
+
+CREATE TRIGGER IF NOT EXISTS sessions_fts_delete AFTER DELETE ON sessions
+BEGIN
+    DELETE FROM session_fts WHERE rowid = old.rowid;
+END;
+`
+
+// schemaV5 adds full-text indexes over session titles and messages, kept up
+// to date by triggers. The first system prompt, which every session shares,
+// isn't indexed.
+const schemaV5 = `
+CREATE VIRTUAL TABLE IF NOT EXISTS message_fts USING fts5(
+    session_id UNINDEXED,
+    content,
+    tool_calls,
+    tokenize = 'porter unicode61'
+);
+
+CREATE TRIGGER IF NOT EXISTS messages_fts_insert AFTER INSERT ON messages
+WHEN NOT (new.seq = 0 AND new.role = 'system')
+BEGIN
+    INSERT INTO message_fts (rowid, session_id, content, tool_calls) VALUES (
+        new.id, new.session_id,
+        COALESCE(json_extract(new.data, '$.content'), ''),
+        COALESCE(json_extract(new.data, '$.tool_calls'), ''));
+END;
+
+CREATE TRIGGER IF NOT EXISTS messages_fts_delete AFTER DELETE ON messages
+BEGIN
+    DELETE FROM message_fts WHERE rowid = old.id;
+END;
+
+INSERT INTO message_fts (rowid, session_id, content, tool_calls)
+SELECT id, session_id,
+       COALESCE(json_extract(data, '$.content'), ''),
+       COALESCE(json_extract(data, '$.tool_calls'), '')
+FROM messages WHERE NOT (seq = 0 AND role = 'system');
+
+CREATE VIRTUAL TABLE IF NOT EXISTS session_fts USING fts5(
+    session_id UNINDEXED,
+    title,
+    tokenize = 'porter unicode61'
+);
+
+` + sessionFTSTriggers + `
+INSERT INTO session_fts (rowid, session_id, title) SELECT rowid, id, title FROM sessions;
+`
+
+const schemaV6 = `
+CREATE TABLE IF NOT EXISTS session_tags (
+    session_id TEXT NOT NULL REFERENCES sessions(id) ON DELETE CASCADE,
+    tag        TEXT NOT NULL,
+    PRIMARY KEY (session_id, tag)
+);
+
+CREATE INDEX IF NOT EXISTS idx_session_tags_tag ON session_tags(tag);
+`
+
+// schemaV7 adds the archived status. SQLite can't change a CHECK
+// constraint, so the sessions table is rebuilt, keeping rowids for
+// session_fts.
+const schemaV7 = `
+CREATE TABLE sessions_v7 (
+    id         TEXT PRIMARY KEY,
+    title      TEXT NOT NULL DEFAULT '',
+    status     TEXT NOT NULL DEFAULT 'active'
+               CHECK(status IN ('active','running','completed','failed','archived')),
+    provider   TEXT NOT NULL DEFAULT '',
+    model      TEXT NOT NULL DEFAULT '',
+    profile    TEXT NOT NULL DEFAULT '',
+    created_at DATETIME NOT NULL DEFAULT (datetime('now')),
+    updated_at DATETIME NOT NULL DEFAULT (datetime('now'))
+);
+
+INSERT INTO sessions_v7 (rowid, id, title, status, provider, model, profile, created_at, updated_at)
+SELECT rowid, id, title, status, provider, model, profile, created_at, updated_at FROM sessions;
+
+DROP TABLE sessions;
+ALTER TABLE sessions_v7 RENAME TO sessions;
+
+CREATE INDEX IF NOT EXISTS idx_sessions_status ON sessions(status);
+CREATE INDEX IF NOT EXISTS idx_sessions_updated ON sessions(updated_at DESC);
+` + sessionFTSTriggers
+
+// schemaV8 records files saved during sessions. Their contents live on disk,
+// under storage.artifacts_dir.
+const schemaV8 = `
+CREATE TABLE IF NOT EXISTS artifacts (
+    id         INTEGER PRIMARY KEY AUTOINCREMENT,
+    session_id TEXT NOT NULL REFERENCES sessions(id) ON DELETE CASCADE,
+    name       TEXT NOT NULL,
+    mime_type  TEXT NOT NULL DEFAULT '',
+    size       INTEGER NOT NULL DEFAULT 0,
+    tool       TEXT NOT NULL DEFAULT '',
+    created_at DATETIME NOT NULL DEFAULT (datetime('now')),
+    UNIQUE(session_id, name)
+);
+`
+
+// schemaV9 stores embeddings for storage.VectorStore, as float32 BLOBs.
+const schemaV9 = `
+CREATE TABLE IF NOT EXISTS vectors (
+    collection TEXT NOT NULL,
+    id         TEXT NOT NULL,
+    session_id TEXT NOT NULL DEFAULT '',
+    content    TEXT NOT NULL DEFAULT '',
+    metadata   TEXT NOT NULL DEFAULT '{}',
+    dims       INTEGER NOT NULL,
+    embedding  BLOB NOT NULL,
+    updated_at DATETIME NOT NULL DEFAULT (datetime('now')),
+    PRIMARY KEY (collection, id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_vectors_session ON vectors(session_id);
+`
+
+// schemaV10 adds the usage log: messages, tool calls, and tokens per day,
+// session, and model. It's kept apart from messages because compaction
+// rewrites histories, and outlives deleted sessions. Messages saved with
+// metadata are counted in.
+const schemaV10 = `
+CREATE TABLE IF NOT EXISTS usage_daily (
+    day               TEXT NOT NULL,
+    session_id        TEXT NOT NULL,
+    model             TEXT NOT NULL DEFAULT '',
+    messages          INTEGER NOT NULL DEFAULT 0,
+    tool_calls        INTEGER NOT NULL DEFAULT 0,
+    prompt_tokens     INTEGER NOT NULL DEFAULT 0,
+    completion_tokens INTEGER NOT NULL DEFAULT 0,
+    last_at           TEXT NOT NULL DEFAULT '',
+    PRIMARY KEY (day, session_id, model)
+);
+
+CREATE INDEX IF NOT EXISTS idx_usage_daily_session ON usage_daily(session_id);
+
+INSERT INTO usage_daily (day, session_id, model, messages, tool_calls, prompt_tokens, completion_tokens, last_at)
+SELECT substr(created_at, 1, 10), session_id, COALESCE(json_extract(data, '$.meta.model'), ''),
+       COUNT(*),
+       SUM(COALESCE(json_array_length(data, '$.tool_calls'), 0)),
+       SUM(COALESCE(json_extract(data, '$.meta.prompt_tokens'), 0)),
+       SUM(COALESCE(json_extract(data, '$.meta.completion_tokens'), 0)),
+       MAX(created_at)
+FROM messages
+WHERE role != 'system' AND json_extract(data, '$.meta.created_at') IS NOT NULL
+GROUP BY 1, 2, 3;
+`
+
+// schemaV11 keeps each tool call's session, call ID, arguments, and result,
+// so a session's calls can be read without parsing its messages. The call
+// ID matches the tool call and tool result messages.
+const schemaV11 = `
+ALTER TABLE tool_calls ADD COLUMN session_id TEXT NOT NULL DEFAULT '';
+ALTER TABLE tool_calls ADD COLUMN call_id TEXT NOT NULL DEFAULT '';
+ALTER TABLE tool_calls ADD COLUMN args TEXT NOT NULL DEFAULT '';
+ALTER TABLE tool_calls ADD COLUMN result TEXT NOT NULL DEFAULT '';
+
+CREATE INDEX IF NOT EXISTS idx_tool_calls_session ON tool_calls(session_id);
+`
+
 func runMigrations(db *sql.DB) error {
 	// Enable foreign keys
 	if _, err := db.Exec("PRAGMA foreign_keys = ON"); err != nil {
@@ -55,6 +285,31 @@ func runMigrations(db *sql.DB) error {
 		}
 	}
 
+	if current < 2 {
+		if _, err := db.Exec(schemaV2); err != nil {
+			return err
+		}
+	}
+
+	if current < 3 {
+		if _, err := db.Exec(schemaV3); err != nil {
+			return err
+		}
+	}
+
+	// Later versions copy data, so each runs in a transaction with its
+	// version bump and never runs twice.
+	for _, m := range []struct {
+		version int
+		schema  string
+	}{{4, schemaV4}, {5, schemaV5}, {6, schemaV6}, {7, schemaV7}, {8, schemaV8}, {9, schemaV9}, {10, schemaV10}, {11, schemaV11}} {
+		if current < m.version {
+			if err := migrateTx(db, m.version, m.schema); err != nil {
+				return err
+			}
+		}
+	}
+
 	// Upsert schema version
 	_, err := db.Exec(`
 		DELETE FROM schema_version;
@@ -62,3 +317,34 @@ func runMigrations(db *sql.DB) error {
 	`, schemaVersion)
 	return err
 }
+
+// migrateTx applies schema and records version in one transaction. Foreign
+// keys are off while it runs, so rebuilding a table doesn't cascade to the
+// rows that reference it.
+func migrateTx(db *sql.DB, version int, schema string) error {
+	ctx := context.Background()
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, "PRAGMA foreign_keys = OFF"); err != nil {
+		return err
+	}
+	defer conn.ExecContext(ctx, "PRAGMA foreign_keys = ON")
+
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(schema); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`UPDATE schema_version SET version = ?`, version); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,122 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/michaelbrown/forge/internal/storage"
+)
+
+// maxSnippets is how many matching passages a search result shows.
+const maxSnippets = 3
+
+func (s *SQLiteStore) SearchSessions(ctx context.Context, query string, opts storage.SessionListOptions) ([]storage.SearchResult, error) {
+	match := ftsQuery(query)
+	if match == "" {
+		return nil, fmt.Errorf("search query has no words")
+	}
+	limit := opts.Limit
+	if limit <= 0 {
+		limit = 20
+	}
+	where, filterArgs := sessionFilter(opts)
+	args := append([]any{match}, filterArgs...)
+
+	// Sessions whose title matches come first, then the rest by their best
+	// matching message.
+	var results []storage.SearchResult
+	index := make(map[string]int)
+	add := func(sessionID string) *storage.SearchResult {
+		if i, ok := index[sessionID]; ok {
+			return &results[i]
+		}
+		index[sessionID] = len(results)
+		results = append(results, storage.SearchResult{Session: storage.Session{ID: sessionID}})
+		return &results[len(results)-1]
+	}
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT session_id, highlight(session_fts, 1, '**', '**')
+		FROM session_fts WHERE session_fts MATCH ?
+		AND session_id IN (SELECT id FROM sessions WHERE `+where+`) ORDER BY rank`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("searching sessions: %w", err)
+	}
+	for rows.Next() {
+		var id, title string
+		if err := rows.Scan(&id, &title); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		add(id).Title = title
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	rows, err = s.db.QueryContext(ctx, `
+		SELECT session_id, snippet(message_fts, -1, '**', '**', '…', 16)
+		FROM message_fts WHERE message_fts MATCH ?
+		AND session_id IN (SELECT id FROM sessions WHERE `+where+`) ORDER BY rank LIMIT 1000`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("searching messages: %w", err)
+	}
+	for rows.Next() {
+		var id, snippet string
+		if err := rows.Scan(&id, &snippet); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		if _, ok := index[id]; !ok && len(results) >= limit {
+			continue
+		}
+		if r := add(id); len(r.Snippets) < maxSnippets {
+			r.Snippets = append(r.Snippets, strings.Join(strings.Fields(snippet), " "))
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(results) > limit {
+		results = results[:limit]
+	}
+	found := results[:0]
+	for _, r := range results {
+		sess, err := s.getSessionExact(ctx, r.Session.ID)
+		if err == sql.ErrNoRows {
+			continue // messages saved without a session
+		}
+		if err != nil {
+			return nil, fmt.Errorf("loading session %s: %w", r.Session.ID, err)
+		}
+		r.Session = *sess
+		if r.Title == "" {
+			r.Title = sess.Title
+		}
+		if r.Snippets == nil {
+			r.Snippets = []string{}
+		}
+		found = append(found, r)
+	}
+	return found, nil
+}
+
+// ftsQuery turns free text into an FTS5 query that matches rows containing
+// every word, each as a prefix. Words are quoted, so FTS5 syntax in the text
+// is searched for literally.
+func ftsQuery(text string) string {
+	var terms []string
+	for _, word := range strings.Fields(text) {
+		if !strings.ContainsFunc(word, func(r rune) bool { return unicode.IsLetter(r) || unicode.IsDigit(r) }) {
+			continue
+		}
+		terms = append(terms, `"`+strings.ReplaceAll(word, `"`, `""`)+`"*`)
+	}
+	return strings.Join(terms, " ")
+}
@@ -7,10 +7,13 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/michaelbrown/forge/internal/llm"
 	"github.com/michaelbrown/forge/internal/storage"
+	"github.com/michaelbrown/forge/internal/tools"
 
 	_ "modernc.org/sqlite"
 )
@@ -30,10 +33,22 @@ func Open(dbPath string) (*SQLiteStore, error) {
 		}
 	}
 
-	db, err := sql.Open("sqlite", dbPath)
+	// The database is shared between processes: forge serve, forge chat,
+	// and tool servers such as time-ops. WAL lets readers run alongside a
+	// writer, and transactions take the write lock when they begin, so a
+	// writer waits out another's lock instead of failing part way through.
+	dsn := dbPath
+	if dbPath != ":memory:" {
+		dsn += "?_pragma=busy_timeout(10000)&_pragma=journal_mode(WAL)&_pragma=synchronous(NORMAL)&_txlock=immediate"
+	}
+	db, err := sql.Open("sqlite", dsn)
 	if err != nil {
 		return nil, fmt.Errorf("opening database: %w", err)
 	}
+	if dbPath == ":memory:" {
+		// Every connection to :memory: is a separate, empty database.
+		db.SetMaxOpenConns(1)
+	}
 
 	if err := runMigrations(db); err != nil {
 		db.Close()
@@ -44,11 +59,17 @@ func Open(dbPath string) (*SQLiteStore, error) {
 }
 
 func (s *SQLiteStore) CreateSession(ctx context.Context, sess *storage.Session) error {
+	tags, err := storage.NormalizeTags(sess.Tags)
+	if err != nil {
+		return err
+	}
+	sess.Tags = tags
+
 	now := time.Now().UTC()
 	sess.CreatedAt = now
 	sess.UpdatedAt = now
 
-	_, err := s.db.ExecContext(ctx, `
+	_, err = s.db.ExecContext(ctx, `
 		INSERT INTO sessions (id, title, status, provider, model, profile, created_at, updated_at)
 		VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
 		sess.ID, sess.Title, sess.Status, sess.Provider, sess.Model, sess.Profile,
@@ -57,13 +78,51 @@ func (s *SQLiteStore) CreateSession(ctx context.Context, sess *storage.Session)
 	if err != nil {
 		return fmt.Errorf("inserting session: %w", err)
 	}
+	if len(tags) > 0 {
+		return s.SetSessionTags(ctx, sess.ID, tags)
+	}
+	return nil
+}
 
-	// Initialize empty messages row
-	_, err = s.db.ExecContext(ctx, `
-		INSERT INTO session_messages (session_id, messages) VALUES (?, '[]')`,
-		sess.ID,
+func (s *SQLiteStore) ImportSession(ctx context.Context, sess *storage.Session, messages []llm.Message) error {
+	tags, err := storage.NormalizeTags(sess.Tags)
+	if err != nil {
+		return err
+	}
+	sess.Tags = tags
+
+	now := time.Now().UTC()
+	if sess.CreatedAt.IsZero() {
+		sess.CreatedAt = now
+	}
+	if sess.UpdatedAt.IsZero() {
+		sess.UpdatedAt = now
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO sessions (id, title, status, provider, model, profile, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		sess.ID, sess.Title, sess.Status, sess.Provider, sess.Model, sess.Profile,
+		sess.CreatedAt.UTC().Format(time.RFC3339), sess.UpdatedAt.UTC().Format(time.RFC3339),
 	)
-	return err
+	if err != nil {
+		return fmt.Errorf("inserting session: %w", err)
+	}
+	for _, tag := range tags {
+		if _, err := tx.ExecContext(ctx, `INSERT INTO session_tags (session_id, tag) VALUES (?, ?)`, sess.ID, tag); err != nil {
+			return fmt.Errorf("tagging session: %w", err)
+		}
+	}
+	if err := insertMessages(ctx, tx, sess.ID, 0, messages); err != nil {
+		return err
+	}
+	return tx.Commit()
 }
 
 func (s *SQLiteStore) GetSession(ctx context.Context, id string) (*storage.Session, error) {
@@ -75,8 +134,7 @@ func (s *SQLiteStore) GetSession(ctx context.Context, id string) (*storage.Sessi
 
 	// Prefix match
 	rows, err := s.db.QueryContext(ctx, `
-		SELECT id, title, status, provider, model, profile, created_at, updated_at
-		FROM sessions WHERE id LIKE ? || '%'`, id)
+		SELECT `+sessionColumns+` FROM sessions WHERE id LIKE ? || '%'`, id)
 	if err != nil {
 		return nil, fmt.Errorf("querying session: %w", err)
 	}
@@ -103,8 +161,7 @@ func (s *SQLiteStore) GetSession(ctx context.Context, id string) (*storage.Sessi
 
 func (s *SQLiteStore) getSessionExact(ctx context.Context, id string) (*storage.Session, error) {
 	row := s.db.QueryRowContext(ctx, `
-		SELECT id, title, status, provider, model, profile, created_at, updated_at
-		FROM sessions WHERE id = ?`, id)
+		SELECT `+sessionColumns+` FROM sessions WHERE id = ?`, id)
 	return scanSessionRow(row)
 }
 
@@ -114,15 +171,18 @@ func (s *SQLiteStore) ListSessions(ctx context.Context, opts storage.SessionList
 		limit = 50
 	}
 
-	query := `SELECT id, title, status, provider, model, profile, created_at, updated_at FROM sessions`
-	var args []any
-
-	if opts.Status != "" {
-		query += ` WHERE status = ?`
-		args = append(args, string(opts.Status))
+	where, args := sessionFilter(opts)
+	if opts.Cursor != "" {
+		updatedAt, id, err := storage.ParseSessionCursor(opts.Cursor)
+		if err != nil {
+			return nil, err
+		}
+		after := updatedAt.UTC().Format(time.RFC3339)
+		where += ` AND (updated_at < ? OR (updated_at = ? AND id < ?))`
+		args = append(args, after, after, id)
 	}
 
-	query += ` ORDER BY updated_at DESC LIMIT ? OFFSET ?`
+	query := `SELECT ` + sessionColumns + ` FROM sessions WHERE ` + where + ` ORDER BY updated_at DESC, id DESC LIMIT ? OFFSET ?`
 	args = append(args, limit, opts.Offset)
 
 	rows, err := s.db.QueryContext(ctx, query, args...)
@@ -142,15 +202,85 @@ func (s *SQLiteStore) ListSessions(ctx context.Context, opts storage.SessionList
 	return sessions, rows.Err()
 }
 
+func (s *SQLiteStore) CountSessions(ctx context.Context, opts storage.SessionListOptions) (int, error) {
+	where, args := sessionFilter(opts)
+	var n int
+	if err := s.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM sessions WHERE `+where, args...).Scan(&n); err != nil {
+		return 0, fmt.Errorf("counting sessions: %w", err)
+	}
+	return n, nil
+}
+
+// sessionFilter returns the WHERE condition for opts' filters.
+func sessionFilter(opts storage.SessionListOptions) (string, []any) {
+	where := `1 = 1`
+	var args []any
+
+	if opts.Status != "" {
+		where += ` AND status = ?`
+		args = append(args, string(opts.Status))
+	} else if !opts.All {
+		where += ` AND status != 'archived'`
+	}
+	if opts.Tag != "" {
+		where += ` AND EXISTS (SELECT 1 FROM session_tags t WHERE t.session_id = sessions.id AND t.tag = ?)`
+		args = append(args, strings.ToLower(strings.TrimSpace(opts.Tag)))
+	}
+	if opts.Profile != "" {
+		where += ` AND profile = ?`
+		args = append(args, opts.Profile)
+	}
+	if opts.Provider != "" {
+		where += ` AND provider = ?`
+		args = append(args, opts.Provider)
+	}
+	if opts.Model != "" {
+		where += ` AND model = ?`
+		args = append(args, opts.Model)
+	}
+	if !opts.Since.IsZero() {
+		where += ` AND updated_at >= ?`
+		args = append(args, opts.Since.UTC().Format(time.RFC3339))
+	}
+	if !opts.Until.IsZero() {
+		where += ` AND created_at < ?`
+		args = append(args, opts.Until.UTC().Format(time.RFC3339))
+	}
+	return where, args
+}
+
 func (s *SQLiteStore) UpdateSession(ctx context.Context, sess *storage.Session) error {
 	sess.UpdatedAt = time.Now().UTC()
 	_, err := s.db.ExecContext(ctx, `
-		UPDATE sessions SET title = ?, status = ?, provider = ?, model = ?, updated_at = ? WHERE id = ?`,
-		sess.Title, sess.Status, sess.Provider, sess.Model, sess.UpdatedAt.Format(time.RFC3339), sess.ID,
+		UPDATE sessions SET title = ?, status = ?, provider = ?, model = ?, profile = ?, updated_at = ? WHERE id = ?`,
+		sess.Title, sess.Status, sess.Provider, sess.Model, sess.Profile, sess.UpdatedAt.Format(time.RFC3339), sess.ID,
 	)
 	return err
 }
 
+func (s *SQLiteStore) SetSessionTags(ctx context.Context, id string, tags []string) error {
+	tags, err := storage.NormalizeTags(tags)
+	if err != nil {
+		return err
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM session_tags WHERE session_id = ?`, id); err != nil {
+		return fmt.Errorf("clearing tags: %w", err)
+	}
+	for _, tag := range tags {
+		if _, err := tx.ExecContext(ctx, `INSERT INTO session_tags (session_id, tag) VALUES (?, ?)`, id, tag); err != nil {
+			return fmt.Errorf("tagging session: %w", err)
+		}
+	}
+	return tx.Commit()
+}
+
 func (s *SQLiteStore) DeleteSession(ctx context.Context, id string) error {
 	// Resolve prefix first
 	sess, err := s.GetSession(ctx, id)
@@ -158,46 +288,407 @@ func (s *SQLiteStore) DeleteSession(ctx context.Context, id string) error {
 		return err
 	}
 
-	// Delete messages first (foreign key), then session
-	_, err = s.db.ExecContext(ctx, `DELETE FROM session_messages WHERE session_id = ?`, sess.ID)
-	if err != nil {
+	// Delete the session's rows in other tables first (foreign key), then
+	// the session
+	for _, table := range []string{"messages", "session_tags", "artifacts", "vectors"} {
+		if _, err := s.db.ExecContext(ctx, `DELETE FROM `+table+` WHERE session_id = ?`, sess.ID); err != nil {
+			return err
+		}
+	}
+	// Tool calls stay in the usage log, without what they were given and
+	// returned.
+	if _, err := s.db.ExecContext(ctx, `UPDATE tool_calls SET args = '', result = '' WHERE session_id = ?`, sess.ID); err != nil {
 		return err
 	}
 	_, err = s.db.ExecContext(ctx, `DELETE FROM sessions WHERE id = ?`, sess.ID)
 	return err
 }
 
+func (s *SQLiteStore) ArchiveIdleSessions(ctx context.Context, before time.Time) ([]string, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		UPDATE sessions SET status = 'archived', updated_at = ?
+		WHERE status IN ('active', 'completed', 'failed') AND julianday(updated_at) < julianday(?)
+		RETURNING id`,
+		time.Now().UTC().Format(time.RFC3339), before.UTC().Format(time.RFC3339),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("archiving sessions: %w", err)
+	}
+	defer rows.Close()
+	return scanIDs(rows)
+}
+
+func (s *SQLiteStore) DeleteArchivedSessions(ctx context.Context, before time.Time) ([]string, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	const expired = `SELECT id FROM sessions WHERE status = 'archived' AND julianday(updated_at) < julianday(?)`
+	cutoff := before.UTC().Format(time.RFC3339)
+	for _, table := range []string{"messages", "session_tags", "artifacts", "vectors"} {
+		if _, err := tx.ExecContext(ctx, `DELETE FROM `+table+` WHERE session_id IN (`+expired+`)`, cutoff); err != nil {
+			return nil, fmt.Errorf("deleting archived sessions: %w", err)
+		}
+	}
+	if _, err := tx.ExecContext(ctx, `UPDATE tool_calls SET args = '', result = '' WHERE session_id IN (`+expired+`)`, cutoff); err != nil {
+		return nil, fmt.Errorf("deleting archived sessions: %w", err)
+	}
+	rows, err := tx.QueryContext(ctx, `DELETE FROM sessions WHERE id IN (`+expired+`) RETURNING id`, cutoff)
+	if err != nil {
+		return nil, fmt.Errorf("deleting archived sessions: %w", err)
+	}
+	ids, err := scanIDs(rows)
+	rows.Close()
+	if err != nil {
+		return nil, err
+	}
+	return ids, tx.Commit()
+}
+
+func scanIDs(rows *sql.Rows) ([]string, error) {
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
 func (s *SQLiteStore) SaveMessages(ctx context.Context, sessionID string, messages []llm.Message) error {
-	data, err := json.Marshal(messages)
+	tx, err := s.db.BeginTx(ctx, nil)
 	if err != nil {
-		return fmt.Errorf("marshaling messages: %w", err)
+		return err
 	}
+	defer tx.Rollback()
 
-	now := time.Now().UTC().Format(time.RFC3339)
-	_, err = s.db.ExecContext(ctx, `
-		INSERT INTO session_messages (session_id, messages, updated_at) VALUES (?, ?, ?)
-		ON CONFLICT(session_id) DO UPDATE SET messages = excluded.messages, updated_at = excluded.updated_at`,
-		sessionID, string(data), now,
-	)
+	if _, err := tx.ExecContext(ctx, `DELETE FROM messages WHERE session_id = ?`, sessionID); err != nil {
+		return fmt.Errorf("clearing messages: %w", err)
+	}
+	if err := insertMessages(ctx, tx, sessionID, 0, messages); err != nil {
+		return err
+	}
+	if err := recordUsage(ctx, tx, sessionID, messages); err != nil {
+		return err
+	}
+	if err := touchSession(ctx, tx, sessionID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *SQLiteStore) AppendMessages(ctx context.Context, sessionID string, messages []llm.Message) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var next int
+	err = tx.QueryRowContext(ctx, `
+		SELECT COALESCE(MAX(seq) + 1, 0) FROM messages WHERE session_id = ?`, sessionID).Scan(&next)
+	if err != nil {
+		return fmt.Errorf("appending messages: %w", err)
+	}
+	if err := insertMessages(ctx, tx, sessionID, next, messages); err != nil {
+		return err
+	}
+	if err := recordUsage(ctx, tx, sessionID, messages); err != nil {
+		return err
+	}
+	if err := touchSession(ctx, tx, sessionID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// touchSession marks a session updated now, so new messages keep it from
+// looking idle, and unarchives it.
+func touchSession(ctx context.Context, tx *sql.Tx, sessionID string) error {
+	_, err := tx.ExecContext(ctx, `
+		UPDATE sessions SET updated_at = ?,
+			status = CASE status WHEN 'archived' THEN 'active' ELSE status END
+		WHERE id = ?`,
+		time.Now().UTC().Format(time.RFC3339), sessionID)
 	return err
 }
 
-func (s *SQLiteStore) LoadMessages(ctx context.Context, sessionID string) ([]llm.Message, error) {
-	var data string
-	err := s.db.QueryRowContext(ctx, `
-		SELECT messages FROM session_messages WHERE session_id = ?`, sessionID).Scan(&data)
-	if err == sql.ErrNoRows {
-		return nil, nil
+// insertMessages stores messages as rows numbered from seq.
+func insertMessages(ctx context.Context, tx *sql.Tx, sessionID string, seq int, messages []llm.Message) error {
+	if len(messages) == 0 {
+		return nil
+	}
+	stmt, err := tx.PrepareContext(ctx, `
+		INSERT INTO messages (session_id, seq, role, data, tokens, created_at) VALUES (?, ?, ?, ?, ?, ?)`)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	now := time.Now().UTC().Format(sortableTimeFormat)
+	for i, m := range messages {
+		data, err := json.Marshal(m)
+		if err != nil {
+			return fmt.Errorf("marshaling messages: %w", err)
+		}
+		created := now
+		if m.Meta != nil && !m.Meta.CreatedAt.IsZero() {
+			created = m.Meta.CreatedAt.UTC().Format(sortableTimeFormat)
+		}
+		if _, err := stmt.ExecContext(ctx, sessionID, seq+i, m.Role, string(data), llm.EstimateTokens(m), created); err != nil {
+			return fmt.Errorf("inserting message: %w", err)
+		}
 	}
+	return nil
+}
+
+// recordUsage adds messages to the usage log. Only messages with metadata
+// newer than any already counted for the session are added, so histories
+// saved again after compaction aren't counted twice.
+func recordUsage(ctx context.Context, tx *sql.Tx, sessionID string, messages []llm.Message) error {
+	var counted string
+	err := tx.QueryRowContext(ctx, `
+		SELECT COALESCE(MAX(last_at), '') FROM usage_daily WHERE session_id = ?`, sessionID).Scan(&counted)
+	if err != nil {
+		return fmt.Errorf("recording usage: %w", err)
+	}
+	for _, m := range messages {
+		if m.Role == llm.RoleSystem || m.Meta == nil || m.Meta.CreatedAt.IsZero() {
+			continue
+		}
+		at := m.Meta.CreatedAt.UTC().Format(sortableTimeFormat)
+		if at <= counted {
+			continue
+		}
+		_, err := tx.ExecContext(ctx, `
+			INSERT INTO usage_daily (day, session_id, model, messages, tool_calls, prompt_tokens, completion_tokens, last_at)
+			VALUES (?, ?, ?, 1, ?, ?, ?, ?)
+			ON CONFLICT (day, session_id, model) DO UPDATE SET
+				messages = messages + 1,
+				tool_calls = tool_calls + excluded.tool_calls,
+				prompt_tokens = prompt_tokens + excluded.prompt_tokens,
+				completion_tokens = completion_tokens + excluded.completion_tokens,
+				last_at = MAX(last_at, excluded.last_at)`,
+			at[:10], sessionID, m.Meta.Model, len(m.ToolCalls), m.Meta.PromptTokens, m.Meta.CompletionTokens, at)
+		if err != nil {
+			return fmt.Errorf("recording usage: %w", err)
+		}
+	}
+	return nil
+}
+
+func (s *SQLiteStore) LoadMessages(ctx context.Context, sessionID string) ([]llm.Message, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT data FROM messages WHERE session_id = ? ORDER BY seq`, sessionID)
 	if err != nil {
 		return nil, fmt.Errorf("loading messages: %w", err)
 	}
+	defer rows.Close()
 
 	var messages []llm.Message
-	if err := json.Unmarshal([]byte(data), &messages); err != nil {
-		return nil, fmt.Errorf("unmarshaling messages: %w", err)
+	for rows.Next() {
+		var data string
+		if err := rows.Scan(&data); err != nil {
+			return nil, fmt.Errorf("loading messages: %w", err)
+		}
+		var m llm.Message
+		if err := json.Unmarshal([]byte(data), &m); err != nil {
+			return nil, fmt.Errorf("unmarshaling messages: %w", err)
+		}
+		messages = append(messages, m)
+	}
+	return messages, rows.Err()
+}
+
+func (s *SQLiteStore) ListMessages(ctx context.Context, sessionID string, opts storage.MessageListOptions) (*storage.MessagePage, error) {
+	var after int64
+	if opts.Cursor != "" {
+		var err error
+		if after, err = storage.ParseMessageCursor(opts.Cursor); err != nil {
+			return nil, err
+		}
+	}
+	// Fetch one message past the page to learn whether there's another.
+	limit := -1
+	if opts.Limit > 0 {
+		limit = opts.Limit + 1
+	}
+
+	page := &storage.MessagePage{Messages: []llm.Message{}}
+	err := s.db.QueryRowContext(ctx, `
+		SELECT COUNT(*) FROM messages WHERE session_id = ?`, sessionID).Scan(&page.Total)
+	if err != nil {
+		return nil, fmt.Errorf("counting messages: %w", err)
+	}
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT id, data FROM messages WHERE session_id = ? AND id > ? ORDER BY seq LIMIT ?`,
+		sessionID, after, limit)
+	if err != nil {
+		return nil, fmt.Errorf("listing messages: %w", err)
+	}
+	defer rows.Close()
+
+	var last int64
+	for rows.Next() {
+		if opts.Limit > 0 && len(page.Messages) == opts.Limit {
+			page.Next = storage.MessageCursor(last)
+			break
+		}
+		var data string
+		if err := rows.Scan(&last, &data); err != nil {
+			return nil, fmt.Errorf("listing messages: %w", err)
+		}
+		var m llm.Message
+		if err := json.Unmarshal([]byte(data), &m); err != nil {
+			return nil, fmt.Errorf("unmarshaling messages: %w", err)
+		}
+		page.Messages = append(page.Messages, m)
+	}
+	return page, rows.Err()
+}
+
+// sortableTimeFormat is fixed-width so timestamps such as tool_calls.created_at
+// and scheduled_tasks.run_at compare correctly as text.
+const sortableTimeFormat = "2006-01-02T15:04:05.000000Z07:00"
+
+func (s *SQLiteStore) RecordToolCall(ctx context.Context, rec tools.CallRecord) error {
+	var args []byte
+	if rec.Args != nil {
+		var err error
+		if args, err = json.Marshal(rec.Args); err != nil {
+			return fmt.Errorf("recording tool call: %w", err)
+		}
+	}
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO tool_calls (tool, server, session_id, call_id, args, result, duration_ms, failed, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		rec.Tool, rec.Server, rec.SessionID, rec.CallID, string(args), rec.Result,
+		float64(rec.Duration)/float64(time.Millisecond), rec.Failed,
+		rec.At.UTC().Format(sortableTimeFormat),
+	)
+	if err != nil {
+		return fmt.Errorf("recording tool call: %w", err)
+	}
+	return nil
+}
+
+func (s *SQLiteStore) ListToolCalls(ctx context.Context, since time.Time) ([]tools.CallRecord, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT tool, server, duration_ms, failed, created_at FROM tool_calls
+		WHERE created_at >= ? ORDER BY id`,
+		since.UTC().Format(sortableTimeFormat),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("listing tool calls: %w", err)
+	}
+	defer rows.Close()
+
+	var records []tools.CallRecord
+	for rows.Next() {
+		var rec tools.CallRecord
+		var durationMs float64
+		var createdAt string
+		if err := rows.Scan(&rec.Tool, &rec.Server, &durationMs, &rec.Failed, &createdAt); err != nil {
+			return nil, err
+		}
+		rec.Duration = time.Duration(durationMs * float64(time.Millisecond))
+		rec.At, _ = time.Parse(sortableTimeFormat, createdAt)
+		records = append(records, rec)
 	}
-	return messages, nil
+	return records, rows.Err()
+}
+
+func (s *SQLiteStore) ListSessionToolCalls(ctx context.Context, sessionID string) ([]tools.CallRecord, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT tool, server, session_id, call_id, args, result, duration_ms, failed, created_at
+		FROM tool_calls WHERE session_id = ? ORDER BY id`, sessionID)
+	if err != nil {
+		return nil, fmt.Errorf("listing tool calls: %w", err)
+	}
+	defer rows.Close()
+
+	var records []tools.CallRecord
+	for rows.Next() {
+		var rec tools.CallRecord
+		var args, createdAt string
+		var durationMs float64
+		if err := rows.Scan(&rec.Tool, &rec.Server, &rec.SessionID, &rec.CallID, &args, &rec.Result,
+			&durationMs, &rec.Failed, &createdAt); err != nil {
+			return nil, err
+		}
+		if args != "" {
+			if err := json.Unmarshal([]byte(args), &rec.Args); err != nil {
+				return nil, fmt.Errorf("unmarshaling tool call args: %w", err)
+			}
+		}
+		rec.Duration = time.Duration(durationMs * float64(time.Millisecond))
+		rec.At, _ = time.Parse(sortableTimeFormat, createdAt)
+		records = append(records, rec)
+	}
+	return records, rows.Err()
+}
+
+func (s *SQLiteStore) ListUsage(ctx context.Context, from, to time.Time) ([]storage.UsageRecord, error) {
+	q := `SELECT u.day, u.session_id, COALESCE(s.title, ''), u.model,
+		u.messages, u.tool_calls, u.prompt_tokens, u.completion_tokens
+		FROM usage_daily u LEFT JOIN sessions s ON s.id = u.session_id WHERE 1 = 1`
+	var args []any
+	if !from.IsZero() {
+		q += ` AND u.day >= ?`
+		args = append(args, from.UTC().Format(time.DateOnly))
+	}
+	if !to.IsZero() {
+		q += ` AND u.day <= ?`
+		args = append(args, to.UTC().Format(time.DateOnly))
+	}
+	rows, err := s.db.QueryContext(ctx, q+` ORDER BY u.day, u.session_id, u.model`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("listing usage: %w", err)
+	}
+	defer rows.Close()
+
+	var records []storage.UsageRecord
+	for rows.Next() {
+		var r storage.UsageRecord
+		if err := rows.Scan(&r.Day, &r.SessionID, &r.Title, &r.Model, &r.Messages, &r.ToolCalls, &r.PromptTokens, &r.CompletionTokens); err != nil {
+			return nil, err
+		}
+		records = append(records, r)
+	}
+	return records, rows.Err()
+}
+
+// Check runs SQLite's quick integrity check, returning an error that lists
+// what it found wrong, if anything.
+func (s *SQLiteStore) Check(ctx context.Context) error {
+	rows, err := s.db.QueryContext(ctx, "PRAGMA quick_check")
+	if err != nil {
+		return fmt.Errorf("checking database: %w", err)
+	}
+	defer rows.Close()
+
+	var problems []string
+	for rows.Next() {
+		var line string
+		if err := rows.Scan(&line); err != nil {
+			return fmt.Errorf("checking database: %w", err)
+		}
+		if line != "ok" {
+			problems = append(problems, line)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("checking database: %w", err)
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("database is damaged: %s", strings.Join(problems, "; "))
+	}
+	return nil
 }
 
 func (s *SQLiteStore) Close() error {
@@ -209,14 +700,23 @@ type scanner interface {
 	Scan(dest ...any) error
 }
 
+// sessionColumns selects a session with its tags, comma-separated.
+const sessionColumns = `id, title, status, provider, model, profile, created_at, updated_at,
+	COALESCE((SELECT group_concat(tag) FROM session_tags WHERE session_id = sessions.id), '')`
+
 func scanSessionFromScanner(s scanner) (*storage.Session, error) {
 	var sess storage.Session
-	var createdAt, updatedAt string
+	var createdAt, updatedAt, tags string
 	err := s.Scan(&sess.ID, &sess.Title, &sess.Status, &sess.Provider,
-		&sess.Model, &sess.Profile, &createdAt, &updatedAt)
+		&sess.Model, &sess.Profile, &createdAt, &updatedAt, &tags)
 	if err != nil {
 		return nil, err
 	}
+	sess.Tags = []string{}
+	if tags != "" {
+		sess.Tags = strings.Split(tags, ",")
+		slices.Sort(sess.Tags)
+	}
 	sess.CreatedAt, _ = time.Parse(time.RFC3339, createdAt)
 	sess.UpdatedAt, _ = time.Parse(time.RFC3339, updatedAt)
 	return &sess, nil
@@ -2,10 +2,18 @@ package sqlite
 
 import (
 	"context"
+	"database/sql"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/michaelbrown/forge/internal/llm"
 	"github.com/michaelbrown/forge/internal/storage"
+	"github.com/michaelbrown/forge/internal/tools"
 )
 
 func testStore(t *testing.T) *SQLiteStore {
@@ -132,6 +140,65 @@ func TestListSessionsFilterByStatus(t *testing.T) {
 	}
 }
 
+func TestListSessionsFilterByProfile(t *testing.T) {
+	s := testStore(t)
+	ctx := context.Background()
+
+	s.CreateSession(ctx, &storage.Session{ID: "p1", Status: storage.StatusActive, Profile: "coder"})
+	s.CreateSession(ctx, &storage.Session{ID: "p2", Status: storage.StatusActive})
+	s.CreateSession(ctx, &storage.Session{ID: "p3", Status: storage.StatusArchived, Profile: "coder"})
+
+	n, err := s.CountSessions(ctx, storage.SessionListOptions{Profile: "coder", All: true})
+	if err != nil {
+		t.Fatalf("CountSessions: %v", err)
+	}
+	if n != 2 {
+		t.Errorf("got %d sessions with the profile, want 2", n)
+	}
+}
+
+func TestListSessionsFilters(t *testing.T) {
+	s := testStore(t)
+	ctx := context.Background()
+
+	day := func(d int) time.Time { return time.Date(2026, 10, d, 12, 0, 0, 0, time.UTC) }
+	for _, sess := range []*storage.Session{
+		{ID: "early", Status: storage.StatusActive, Provider: "ollama", Model: "qwen3:14b", CreatedAt: day(1), UpdatedAt: day(2)},
+		{ID: "long", Status: storage.StatusActive, Provider: "claude", Model: "claude-sonnet-4", CreatedAt: day(3), UpdatedAt: day(20)},
+		{ID: "late", Status: storage.StatusActive, Provider: "ollama", Model: "llama3", CreatedAt: day(15), UpdatedAt: day(16)},
+	} {
+		if err := s.ImportSession(ctx, sess, nil); err != nil {
+			t.Fatalf("ImportSession: %v", err)
+		}
+	}
+
+	tests := []struct {
+		name string
+		opts storage.SessionListOptions
+		want string
+	}{
+		{"provider", storage.SessionListOptions{Provider: "ollama"}, "late,early"},
+		{"model", storage.SessionListOptions{Model: "claude-sonnet-4"}, "long"},
+		{"since", storage.SessionListOptions{Since: day(10)}, "long,late"},
+		{"until", storage.SessionListOptions{Until: day(10)}, "long,early"},
+		{"active between", storage.SessionListOptions{Since: day(5), Until: day(10)}, "long"},
+		{"provider and since", storage.SessionListOptions{Provider: "ollama", Since: day(10)}, "late"},
+	}
+	for _, tt := range tests {
+		sessions, err := s.ListSessions(ctx, tt.opts)
+		if err != nil {
+			t.Fatalf("%s: ListSessions: %v", tt.name, err)
+		}
+		var ids []string
+		for _, sess := range sessions {
+			ids = append(ids, sess.ID)
+		}
+		if got := strings.Join(ids, ","); got != tt.want {
+			t.Errorf("%s: got %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
+
 func TestListSessionsLimit(t *testing.T) {
 	s := testStore(t)
 	ctx := context.Background()
@@ -149,6 +216,55 @@ func TestListSessionsLimit(t *testing.T) {
 	}
 }
 
+func TestListSessionsCursor(t *testing.T) {
+	s := testStore(t)
+	ctx := context.Background()
+
+	// All but one share an updated_at, so pages must break ties by ID.
+	at := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	for i, id := range []string{"s1", "s2", "s3", "s4", "s5"} {
+		updated := at
+		if i == 0 {
+			updated = at.Add(time.Hour)
+		}
+		if err := s.ImportSession(ctx, &storage.Session{ID: id, Status: storage.StatusActive, UpdatedAt: updated}, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var got []string
+	opts := storage.SessionListOptions{Limit: 2}
+	for {
+		page, err := s.ListSessions(ctx, opts)
+		if err != nil {
+			t.Fatalf("ListSessions: %v", err)
+		}
+		for _, sess := range page {
+			got = append(got, sess.ID)
+		}
+		if len(page) < opts.Limit {
+			break
+		}
+		opts.Cursor = storage.SessionCursor(page[len(page)-1])
+		// A session created between pages doesn't shift the next one.
+		s.CreateSession(ctx, &storage.Session{ID: fmt.Sprintf("new%d", len(got)), Status: storage.StatusActive})
+	}
+	if want := []string{"s1", "s5", "s4", "s3", "s2"}; !slices.Equal(got, want) {
+		t.Errorf("pages = %v, want %v", got, want)
+	}
+
+	n, err := s.CountSessions(ctx, storage.SessionListOptions{Limit: 1, Cursor: opts.Cursor})
+	if err != nil {
+		t.Fatalf("CountSessions: %v", err)
+	}
+	if n != 7 {
+		t.Errorf("CountSessions = %d, want 7", n)
+	}
+	if _, err := s.ListSessions(ctx, storage.SessionListOptions{Cursor: "bogus"}); err == nil {
+		t.Error("expected an error for an invalid cursor")
+	}
+}
+
 func TestUpdateSession(t *testing.T) {
 	s := testStore(t)
 	ctx := context.Background()
@@ -203,6 +319,12 @@ func TestUpdateSession_ProviderAndModel(t *testing.T) {
 	if got.Model != "claude-sonnet-4-5-20250929" {
 		t.Errorf("model = %q, want %q", got.Model, "claude-sonnet-4-5-20250929")
 	}
+
+	sess.Profile = "coder"
+	s.UpdateSession(ctx, sess)
+	if got, _ := s.GetSession(ctx, "upd-model"); got.Profile != "coder" {
+		t.Errorf("profile = %q, want %q", got.Profile, "coder")
+	}
 }
 
 func TestDeleteSession(t *testing.T) {
@@ -231,6 +353,274 @@ func TestDeleteSession(t *testing.T) {
 	}
 }
 
+func TestArtifacts(t *testing.T) {
+	s := testStore(t)
+	ctx := context.Background()
+
+	s.CreateSession(ctx, &storage.Session{ID: "art1", Status: storage.StatusActive})
+	for _, name := range []string{"plot.png", "report.csv"} {
+		if err := s.CreateArtifact(ctx, &storage.Artifact{SessionID: "art1", Name: name, MIMEType: "text/csv", Size: 42, Tool: "sandbox_exec"}); err != nil {
+			t.Fatalf("CreateArtifact(%s): %v", name, err)
+		}
+	}
+	if err := s.CreateArtifact(ctx, &storage.Artifact{SessionID: "art1", Name: "plot.png"}); err == nil {
+		t.Error("expected error for duplicate name")
+	}
+
+	list, err := s.ListArtifacts(ctx, "art1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 2 || list[0].Name != "plot.png" || list[1].Name != "report.csv" {
+		t.Fatalf("ListArtifacts = %+v", list)
+	}
+
+	a, err := s.GetArtifact(ctx, "art1", "report.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.Size != 42 || a.Tool != "sandbox_exec" || a.MIMEType != "text/csv" || a.CreatedAt.IsZero() {
+		t.Errorf("GetArtifact = %+v", a)
+	}
+	if _, err := s.GetArtifact(ctx, "art1", "missing.txt"); err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Errorf("expected not found, got %v", err)
+	}
+
+	if err := s.DeleteSession(ctx, "art1"); err != nil {
+		t.Fatal(err)
+	}
+	if list, _ := s.ListArtifacts(ctx, "art1"); len(list) != 0 {
+		t.Errorf("artifacts after delete = %+v", list)
+	}
+}
+
+func TestVectors(t *testing.T) {
+	s := testStore(t)
+	ctx := context.Background()
+	s.CreateSession(ctx, &storage.Session{ID: "vec1", Status: storage.StatusActive})
+
+	items := []storage.VectorItem{
+		{ID: "a", Content: "cats", Embedding: []float32{1, 0, 0}, Metadata: map[string]string{"kind": "pet"}},
+		{ID: "b", Content: "dogs", Embedding: []float32{0.9, 0.1, 0}, Metadata: map[string]string{"kind": "pet"}, SessionID: "vec1"},
+		{ID: "c", Content: "cars", Embedding: []float32{0, 1, 0}, Metadata: map[string]string{"kind": "vehicle"}},
+		{ID: "d", Content: "boats", Embedding: []float32{0, 0.2, 1}},
+	}
+	if err := s.UpsertVectors(ctx, "notes", items); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.UpsertVectors(ctx, "notes", []storage.VectorItem{{ID: "e", Embedding: []float32{1, 2}}}); err == nil {
+		t.Error("expected error for mismatched dimensions")
+	}
+
+	ids := func(matches []storage.VectorMatch) string {
+		var out []string
+		for _, m := range matches {
+			out = append(out, m.ID)
+		}
+		return strings.Join(out, ",")
+	}
+	query := []float32{1, 0.05, 0}
+	for _, tt := range []struct {
+		name string
+		opts storage.VectorSearchOptions
+		want string
+	}{
+		{"all", storage.VectorSearchOptions{}, "a,b,c,d"},
+		{"k", storage.VectorSearchOptions{K: 2}, "a,b"},
+		{"filter", storage.VectorSearchOptions{Filter: map[string]string{"kind": "vehicle"}}, "c"},
+		{"session", storage.VectorSearchOptions{SessionID: "vec1"}, "b"},
+		{"min score", storage.VectorSearchOptions{MinScore: 0.5}, "a,b"},
+	} {
+		got, err := s.SearchVectors(ctx, "notes", query, tt.opts)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if ids(got) != tt.want {
+			t.Errorf("%s: got %s, want %s", tt.name, ids(got), tt.want)
+		}
+	}
+
+	// Upserting replaces, and the results carry content and metadata.
+	s.UpsertVectors(ctx, "notes", []storage.VectorItem{{ID: "c", Content: "trucks", Embedding: []float32{1, 0.05, 0}, Metadata: map[string]string{"kind": "vehicle"}}})
+	got, _ := s.SearchVectors(ctx, "notes", query, storage.VectorSearchOptions{K: 1})
+	if len(got) != 1 || got[0].ID != "c" || got[0].Content != "trucks" || got[0].Metadata["kind"] != "vehicle" || got[0].Score < 0.999 {
+		t.Errorf("after upsert: %+v", got)
+	}
+
+	if _, err := s.SearchVectors(ctx, "notes", []float32{1, 0}, storage.VectorSearchOptions{}); err == nil {
+		t.Error("expected error for query with wrong dimensions")
+	}
+	if got, err := s.SearchVectors(ctx, "other", query, storage.VectorSearchOptions{}); err != nil || len(got) != 0 {
+		t.Errorf("empty collection: %v, %v", got, err)
+	}
+
+	s.DeleteVectors(ctx, "notes", "a", "d")
+	s.DeleteSession(ctx, "vec1")
+	got, _ = s.SearchVectors(ctx, "notes", query, storage.VectorSearchOptions{})
+	if ids(got) != "c" {
+		t.Errorf("after deletes: %s, want c", ids(got))
+	}
+}
+
+func TestSessionTags(t *testing.T) {
+	s := testStore(t)
+	ctx := context.Background()
+
+	s.CreateSession(ctx, &storage.Session{ID: "tag1", Status: storage.StatusActive, Tags: []string{"Billing-API", "bug", "bug"}})
+	s.CreateSession(ctx, &storage.Session{ID: "tag2", Status: storage.StatusActive})
+	if err := s.CreateSession(ctx, &storage.Session{ID: "tag3", Tags: []string{"two words"}}); err == nil {
+		t.Error("expected an error for a tag with a space")
+	}
+
+	got, err := s.GetSession(ctx, "tag1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(got.Tags, ",") != "billing-api,bug" {
+		t.Errorf("tags = %q, want billing-api,bug", got.Tags)
+	}
+	if got, _ := s.GetSession(ctx, "tag2"); got.Tags == nil || len(got.Tags) != 0 {
+		t.Errorf("untagged session tags = %#v, want empty", got.Tags)
+	}
+
+	if err := s.SetSessionTags(ctx, "tag2", []string{"billing-api", "docs"}); err != nil {
+		t.Fatalf("SetSessionTags: %v", err)
+	}
+	s.SetSessionTags(ctx, "tag1", []string{"bug"})
+
+	for tag, want := range map[string]string{"billing-api": "tag2", "BUG": "tag1", "docs": "tag2", "none": ""} {
+		sessions, err := s.ListSessions(ctx, storage.SessionListOptions{Tag: tag})
+		if err != nil {
+			t.Fatalf("ListSessions: %v", err)
+		}
+		var ids []string
+		for _, sess := range sessions {
+			ids = append(ids, sess.ID)
+		}
+		if got := strings.Join(ids, ","); got != want {
+			t.Errorf("tag %s: sessions = %s, want %s", tag, got, want)
+		}
+	}
+
+	// Tags go with the session.
+	s.DeleteSession(ctx, "tag2")
+	if sessions, _ := s.ListSessions(ctx, storage.SessionListOptions{Tag: "docs"}); len(sessions) != 0 {
+		t.Errorf("deleted session still listed: %+v", sessions)
+	}
+}
+
+func TestArchiveAndDeleteSessions(t *testing.T) {
+	s := testStore(t)
+	ctx := context.Background()
+
+	for id, status := range map[string]storage.SessionStatus{
+		"idle": storage.StatusActive, "done": storage.StatusCompleted, "busy": storage.StatusRunning, "fresh": storage.StatusActive,
+	} {
+		s.CreateSession(ctx, &storage.Session{ID: id, Status: status})
+		s.SaveMessages(ctx, id, []llm.Message{{Role: llm.RoleUser, Content: "hello from " + id}})
+	}
+	old := time.Now().AddDate(0, 0, -10).UTC().Format(time.RFC3339)
+	s.db.ExecContext(ctx, `UPDATE sessions SET updated_at = ? WHERE id != 'fresh'`, old)
+
+	ids, err := s.ArchiveIdleSessions(ctx, time.Now().AddDate(0, 0, -5))
+	if err != nil {
+		t.Fatalf("ArchiveIdleSessions: %v", err)
+	}
+	slices.Sort(ids)
+	if got := strings.Join(ids, ","); got != "done,idle" {
+		t.Errorf("archived %s, want done,idle", got)
+	}
+
+	listed := func(opts storage.SessionListOptions) string {
+		t.Helper()
+		sessions, err := s.ListSessions(ctx, opts)
+		if err != nil {
+			t.Fatalf("ListSessions: %v", err)
+		}
+		var ids []string
+		for _, sess := range sessions {
+			ids = append(ids, sess.ID)
+		}
+		slices.Sort(ids)
+		return strings.Join(ids, ",")
+	}
+	if got := listed(storage.SessionListOptions{}); got != "busy,fresh" {
+		t.Errorf("default list = %s, want busy,fresh", got)
+	}
+	if got := listed(storage.SessionListOptions{All: true}); got != "busy,done,fresh,idle" {
+		t.Errorf("list --all = %s, want every session", got)
+	}
+	if got := listed(storage.SessionListOptions{Status: storage.StatusArchived}); got != "done,idle" {
+		t.Errorf("archived list = %s, want done,idle", got)
+	}
+
+	// A new message brings a session back.
+	if err := s.AppendMessages(ctx, "idle", []llm.Message{{Role: llm.RoleUser, Content: "back again"}}); err != nil {
+		t.Fatal(err)
+	}
+	if sess, _ := s.GetSession(ctx, "idle"); sess.Status != storage.StatusActive {
+		t.Errorf("status after new message = %s, want active", sess.Status)
+	}
+
+	// Archiving starts the deletion clock.
+	if ids, _ := s.DeleteArchivedSessions(ctx, time.Now().Add(-time.Hour)); len(ids) != 0 {
+		t.Errorf("deleted %v, want none yet", ids)
+	}
+	ids, err = s.DeleteArchivedSessions(ctx, time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatalf("DeleteArchivedSessions: %v", err)
+	}
+	if strings.Join(ids, ",") != "done" {
+		t.Errorf("deleted %v, want [done]", ids)
+	}
+	if _, err := s.GetSession(ctx, "done"); err == nil {
+		t.Error("deleted session still exists")
+	}
+	if msgs, _ := s.LoadMessages(ctx, "done"); len(msgs) != 0 {
+		t.Errorf("deleted session still has %d messages", len(msgs))
+	}
+	if results, _ := s.SearchSessions(ctx, "hello", storage.SessionListOptions{All: true, Limit: 10}); len(results) != 3 {
+		t.Errorf("search found %d sessions, want 3", len(results))
+	}
+}
+
+func TestImportSession(t *testing.T) {
+	s := testStore(t)
+	ctx := context.Background()
+
+	created := time.Date(2025, 3, 1, 9, 30, 0, 0, time.UTC)
+	sess := &storage.Session{ID: "imported", Title: "Old chat", Status: storage.StatusCompleted, Tags: []string{"Backup"}, CreatedAt: created, UpdatedAt: created.Add(time.Hour)}
+	messages := []llm.Message{{Role: llm.RoleUser, Content: "restore the kubeconfig"}, {Role: llm.RoleAssistant, Content: "done"}}
+	if err := s.ImportSession(ctx, sess, messages); err != nil {
+		t.Fatalf("ImportSession: %v", err)
+	}
+
+	got, err := s.GetSession(ctx, "imported")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !got.CreatedAt.Equal(created) || !got.UpdatedAt.Equal(created.Add(time.Hour)) {
+		t.Errorf("timestamps = %v, %v; want %v, %v", got.CreatedAt, got.UpdatedAt, created, created.Add(time.Hour))
+	}
+	if got.Status != storage.StatusCompleted || strings.Join(got.Tags, ",") != "backup" {
+		t.Errorf("imported session = %+v", got)
+	}
+	if loaded, _ := s.LoadMessages(ctx, "imported"); len(loaded) != 2 {
+		t.Errorf("loaded %d messages, want 2", len(loaded))
+	}
+	if results, _ := s.SearchSessions(ctx, "kubeconfig", storage.SessionListOptions{All: true, Limit: 10}); len(results) != 1 {
+		t.Errorf("search found %d sessions, want the imported one", len(results))
+	}
+
+	// A duplicate ID leaves nothing behind.
+	if err := s.ImportSession(ctx, &storage.Session{ID: "imported"}, messages); err == nil {
+		t.Error("expected an error importing a duplicate ID")
+	}
+	if loaded, _ := s.LoadMessages(ctx, "imported"); len(loaded) != 2 {
+		t.Errorf("after failed import: %d messages, want 2", len(loaded))
+	}
+}
+
 func TestSaveAndLoadMessages(t *testing.T) {
 	s := testStore(t)
 	ctx := context.Background()
@@ -301,6 +691,427 @@ func TestSaveMessagesOverwrites(t *testing.T) {
 	}
 }
 
+func TestAppendMessages(t *testing.T) {
+	s := testStore(t)
+	ctx := context.Background()
+
+	sess := &storage.Session{ID: "ap1", Status: storage.StatusActive}
+	s.CreateSession(ctx, sess)
+
+	if err := s.AppendMessages(ctx, "ap1", []llm.Message{
+		{Role: llm.RoleSystem, Content: "You are helpful."},
+		{Role: llm.RoleUser, Content: "Hello"},
+	}); err != nil {
+		t.Fatalf("AppendMessages: %v", err)
+	}
+	if err := s.AppendMessages(ctx, "ap1", []llm.Message{
+		{Role: llm.RoleAssistant, ToolCalls: []llm.ToolCall{{ID: "tc1", Name: "file_read", Args: map[string]any{"path": "go.mod"}}}},
+		{Role: llm.RoleTool, Content: "module example.com/forge", ToolCallID: "tc1"},
+	}); err != nil {
+		t.Fatalf("AppendMessages: %v", err)
+	}
+
+	loaded, err := s.LoadMessages(ctx, "ap1")
+	if err != nil {
+		t.Fatalf("LoadMessages: %v", err)
+	}
+	var roles []string
+	for _, m := range loaded {
+		roles = append(roles, string(m.Role))
+	}
+	if got := strings.Join(roles, ","); got != "system,user,assistant,tool" {
+		t.Fatalf("roles = %s, want system,user,assistant,tool", got)
+	}
+	if loaded[2].ToolCalls[0].Args["path"] != "go.mod" || loaded[3].ToolCallID != "tc1" {
+		t.Errorf("appended messages = %+v", loaded[2:])
+	}
+
+	// Each message is a row with its own metadata.
+	rows, err := s.db.QueryContext(ctx, `SELECT seq, role, tokens, created_at FROM messages WHERE session_id = ? ORDER BY seq`, "ap1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	for i := 0; rows.Next(); i++ {
+		var seq, tokens int
+		var role, createdAt string
+		if err := rows.Scan(&seq, &role, &tokens, &createdAt); err != nil {
+			t.Fatal(err)
+		}
+		if seq != i || role != roles[i] || tokens != llm.EstimateTokens(loaded[i]) || createdAt == "" {
+			t.Errorf("row %d = seq %d, role %s, tokens %d, created_at %q", i, seq, role, tokens, createdAt)
+		}
+	}
+
+	// SaveMessages still replaces the whole history.
+	if err := s.SaveMessages(ctx, "ap1", []llm.Message{{Role: llm.RoleSystem, Content: "summary"}}); err != nil {
+		t.Fatalf("SaveMessages: %v", err)
+	}
+	s.AppendMessages(ctx, "ap1", []llm.Message{{Role: llm.RoleUser, Content: "again"}})
+	loaded, _ = s.LoadMessages(ctx, "ap1")
+	if len(loaded) != 2 || loaded[0].Content != "summary" || loaded[1].Content != "again" {
+		t.Errorf("after rewrite = %+v", loaded)
+	}
+
+	// Deleting the session deletes its messages.
+	if err := s.DeleteSession(ctx, "ap1"); err != nil {
+		t.Fatal(err)
+	}
+	if loaded, _ := s.LoadMessages(ctx, "ap1"); loaded != nil {
+		t.Errorf("messages after delete = %+v", loaded)
+	}
+}
+
+func TestMigrateMessagesToRows(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "forge.db")
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A version 3 database, with histories stored as JSON arrays.
+	for _, stmt := range []string{schemaV1, schemaV2, schemaV3,
+		`INSERT INTO schema_version (version) VALUES (3)`,
+		`INSERT INTO sessions (id) VALUES ('old'), ('empty')`,
+		`INSERT INTO session_messages (session_id, messages, updated_at) VALUES
+			('old', '[{"role":"system","content":"You are helpful."},{"role":"user","content":"List the files"},{"role":"assistant","tool_calls":[{"id":"tc1","name":"shell_exec","arguments":{"command":"ls"}}]}]', '2026-01-02T03:04:05Z'),
+			('empty', '[]', '2026-01-02T03:04:05Z')`,
+	} {
+		if _, err := db.Exec(stmt); err != nil {
+			t.Fatalf("%s: %v", stmt, err)
+		}
+	}
+	db.Close()
+
+	s, err := Open(path)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	defer s.Close()
+	ctx := context.Background()
+
+	loaded, err := s.LoadMessages(ctx, "old")
+	if err != nil {
+		t.Fatalf("LoadMessages: %v", err)
+	}
+	if len(loaded) != 3 || loaded[1].Content != "List the files" || loaded[2].ToolCalls[0].Args["command"] != "ls" {
+		t.Errorf("migrated messages = %+v", loaded)
+	}
+	if loaded, _ := s.LoadMessages(ctx, "empty"); loaded != nil {
+		t.Errorf("migrated empty history = %+v", loaded)
+	}
+
+	// New turns append after the migrated messages.
+	if err := s.AppendMessages(ctx, "old", []llm.Message{{Role: llm.RoleTool, Content: "go.mod", ToolCallID: "tc1"}}); err != nil {
+		t.Fatalf("AppendMessages: %v", err)
+	}
+	if loaded, _ := s.LoadMessages(ctx, "old"); len(loaded) != 4 || loaded[3].ToolCallID != "tc1" {
+		t.Errorf("after append = %+v", loaded)
+	}
+
+	if results, err := s.SearchSessions(ctx, "files", storage.SessionListOptions{All: true}); err != nil || len(results) != 1 || results[0].Session.ID != "old" {
+		t.Errorf("search after migration = %+v, %v", results, err)
+	}
+
+	var version, tables int
+	s.db.QueryRow(`SELECT version FROM schema_version`).Scan(&version)
+	s.db.QueryRow(`SELECT count(*) FROM sqlite_master WHERE name = 'session_messages'`).Scan(&tables)
+	if version != schemaVersion || tables != 0 {
+		t.Errorf("schema version = %d, session_messages tables = %d", version, tables)
+	}
+}
+
+func TestMigrateUsageLog(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "forge.db")
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A version 9 database with messages saved before the usage log.
+	for _, stmt := range []string{schemaV1, schemaV2, schemaV3, schemaV4, schemaV5, schemaV6, schemaV7, schemaV8, schemaV9,
+		`INSERT INTO schema_version (version) VALUES (9)`,
+		`INSERT INTO sessions (id) VALUES ('old')`,
+		`INSERT INTO messages (session_id, seq, role, data, created_at) VALUES
+			('old', 0, 'system', '{"role":"system","content":"hi"}', '2026-03-01T10:00:00.000000Z'),
+			('old', 1, 'user', '{"role":"user","content":"q","meta":{"created_at":"2026-03-01T10:00:00Z"}}', '2026-03-01T10:00:00.000000Z'),
+			('old', 2, 'assistant', '{"role":"assistant","tool_calls":[{"id":"1","name":"ls","arguments":{}}],"meta":{"created_at":"2026-03-01T10:00:01Z","model":"m1","prompt_tokens":50,"completion_tokens":5}}', '2026-03-01T10:00:01.000000Z'),
+			('old', 3, 'user', '{"role":"user","content":"no meta"}', '2026-03-01T10:00:02.000000Z')`,
+	} {
+		if _, err := db.Exec(stmt); err != nil {
+			t.Fatalf("%s: %v", stmt, err)
+		}
+	}
+	db.Close()
+
+	s, err := Open(path)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	defer s.Close()
+
+	records, err := s.ListUsage(context.Background(), time.Time{}, time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []storage.UsageRecord{
+		{Day: "2026-03-01", SessionID: "old", Model: "", Messages: 1},
+		{Day: "2026-03-01", SessionID: "old", Model: "m1", Messages: 1, ToolCalls: 1, PromptTokens: 50, CompletionTokens: 5},
+	}
+	if !slices.Equal(records, want) {
+		t.Errorf("migrated usage =\n%+v\nwant\n%+v", records, want)
+	}
+}
+
+func TestSearchSessions(t *testing.T) {
+	s := testStore(t)
+	ctx := context.Background()
+
+	for _, sess := range []*storage.Session{
+		{ID: "make-1", Title: "Build broken", Status: storage.StatusActive, Model: "qwen3:14b"},
+		{ID: "make-2", Title: "Makefile cleanup", Status: storage.StatusActive},
+		{ID: "other", Title: "Trip planning", Status: storage.StatusActive},
+	} {
+		s.CreateSession(ctx, sess)
+	}
+	s.AppendMessages(ctx, "make-1", []llm.Message{
+		{Role: llm.RoleSystem, Content: "You are helpful. Never touch the Makefile."},
+		{Role: llm.RoleUser, Content: "The build fails after the last merge, can you look?"},
+		{Role: llm.RoleAssistant, ToolCalls: []llm.ToolCall{{ID: "tc1", Name: "file_patch", Args: map[string]any{"path": "Makefile"}}}},
+		{Role: llm.RoleAssistant, Content: "I fixed the Makefile: the test target was missing a tab."},
+	})
+	s.AppendMessages(ctx, "make-2", []llm.Message{{Role: llm.RoleUser, Content: "Remove unused targets"}})
+	s.AppendMessages(ctx, "other", []llm.Message{{Role: llm.RoleSystem, Content: "You are helpful. Never touch the Makefile."}})
+
+	results, err := s.SearchSessions(ctx, "fixing make", storage.SessionListOptions{All: true})
+	if err != nil {
+		t.Fatalf("SearchSessions: %v", err)
+	}
+	if len(results) != 1 || results[0].Session.ID != "make-1" || results[0].Title != "Build broken" {
+		t.Fatalf("results = %+v", results)
+	}
+	if got := results[0].Snippets; len(got) != 1 || got[0] != "I **fixed** the **Makefile**: the test target was missing a tab." {
+		t.Errorf("snippets = %q", got)
+	}
+
+	// Title matches come first; the shared system prompt doesn't match.
+	results, _ = s.SearchSessions(ctx, "makefile", storage.SessionListOptions{All: true})
+	var ids []string
+	for _, r := range results {
+		ids = append(ids, r.Session.ID)
+	}
+	if got := strings.Join(ids, ","); got != "make-2,make-1" {
+		t.Errorf("makefile results = %s, want make-2,make-1", got)
+	}
+	if results[0].Title != "**Makefile** cleanup" || len(results[0].Snippets) != 0 {
+		t.Errorf("title match = %+v", results[0])
+	}
+	if len(results[1].Snippets) != 2 {
+		t.Errorf("make-1 snippets = %q", results[1].Snippets)
+	}
+
+	if results, _ := s.SearchSessions(ctx, "makefile", storage.SessionListOptions{All: true, Limit: 1}); len(results) != 1 {
+		t.Errorf("limit 1: got %d results", len(results))
+	}
+	// Filters apply before the limit.
+	if results, _ := s.SearchSessions(ctx, "makefile", storage.SessionListOptions{Model: "qwen3:14b", Limit: 1}); len(results) != 1 || results[0].Session.ID != "make-1" {
+		t.Errorf("model filter = %+v", results)
+	}
+
+	// Renamed and deleted sessions are reindexed.
+	s.UpdateSession(ctx, &storage.Session{ID: "other", Title: "Makefile trip", Status: storage.StatusActive})
+	s.DeleteSession(ctx, "make-1")
+	results, _ = s.SearchSessions(ctx, "makefile", storage.SessionListOptions{All: true})
+	ids = nil
+	for _, r := range results {
+		ids = append(ids, r.Session.ID)
+	}
+	if got := strings.Join(ids, ","); got != "make-2,other" {
+		t.Errorf("after rename and delete = %s, want make-2,other", got)
+	}
+
+	// FTS5 syntax is searched for literally.
+	if results, err := s.SearchSessions(ctx, `"unbalanced AND (target*`, storage.SessionListOptions{All: true}); err != nil || len(results) != 0 {
+		t.Errorf("syntax query = %+v, %v", results, err)
+	}
+	if _, err := s.SearchSessions(ctx, " -- ", storage.SessionListOptions{All: true}); err == nil {
+		t.Error("expected an error for a query with no words")
+	}
+}
+
+func TestConcurrentWriters(t *testing.T) {
+	// Two stores on one file stand in for forge serve and forge chat.
+	path := filepath.Join(t.TempDir(), "forge.db")
+	server, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+	chat, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer chat.Close()
+
+	ctx := context.Background()
+	const sessions, turns = 8, 20
+	for i := range sessions {
+		server.CreateSession(ctx, &storage.Session{ID: fmt.Sprintf("s%d", i), Status: storage.StatusActive})
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, sessions*turns)
+	for i := range sessions {
+		store := server
+		if i%2 == 1 {
+			store = chat
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			id := fmt.Sprintf("s%d", i)
+			var history []llm.Message
+			for turn := range turns {
+				history = append(history, llm.UserMessage(fmt.Sprintf("turn %d", turn)))
+				var err error
+				if turn%5 == 0 {
+					err = store.SaveMessages(ctx, id, history)
+				} else {
+					err = store.AppendMessages(ctx, id, history[len(history)-1:])
+				}
+				if err != nil {
+					errs <- fmt.Errorf("%s turn %d: %w", id, turn, err)
+					return
+				}
+				if _, err := store.ListSessions(ctx, storage.SessionListOptions{}); err != nil {
+					errs <- err
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+
+	for i := range sessions {
+		msgs, err := server.LoadMessages(ctx, fmt.Sprintf("s%d", i))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(msgs) != turns {
+			t.Errorf("s%d has %d messages, want %d", i, len(msgs), turns)
+		}
+	}
+
+	var mode string
+	server.db.QueryRow("PRAGMA journal_mode").Scan(&mode)
+	if mode != "wal" {
+		t.Errorf("journal_mode = %s, want wal", mode)
+	}
+}
+
+func TestUsageLog(t *testing.T) {
+	s := testStore(t)
+	ctx := context.Background()
+	s.CreateSession(ctx, &storage.Session{ID: "use1", Title: "Usage", Status: storage.StatusActive})
+
+	day1 := time.Date(2026, 10, 1, 23, 0, 0, 0, time.UTC)
+	day2 := day1.Add(2 * time.Hour)
+	meta := func(at time.Time, model string, prompt, completion int) *llm.MessageMeta {
+		return &llm.MessageMeta{CreatedAt: at, Model: model, PromptTokens: prompt, CompletionTokens: completion}
+	}
+	history := []llm.Message{
+		llm.SystemMessage("system"),
+		{Role: llm.RoleUser, Content: "hi", Meta: meta(day1, "", 0, 0)},
+		{Role: llm.RoleAssistant, ToolCalls: []llm.ToolCall{{ID: "1", Name: "ls"}, {ID: "2", Name: "cat"}}, Meta: meta(day1.Add(time.Second), "m1", 100, 10)},
+		{Role: llm.RoleTool, Content: "out", ToolCallID: "1", Meta: meta(day1.Add(2*time.Second), "", 0, 0)},
+	}
+	if err := s.AppendMessages(ctx, "use1", history); err != nil {
+		t.Fatal(err)
+	}
+	// A rewritten history counts only its new messages.
+	history = append(history, llm.Message{Role: llm.RoleAssistant, Content: "done", Meta: meta(day2, "m1", 200, 20)})
+	if err := s.SaveMessages(ctx, "use1", history); err != nil {
+		t.Fatal(err)
+	}
+
+	records, err := s.ListUsage(ctx, time.Time{}, time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []storage.UsageRecord{
+		{Day: "2026-10-01", SessionID: "use1", Title: "Usage", Model: "", Messages: 2},
+		{Day: "2026-10-01", SessionID: "use1", Title: "Usage", Model: "m1", Messages: 1, ToolCalls: 2, PromptTokens: 100, CompletionTokens: 10},
+		{Day: "2026-10-02", SessionID: "use1", Title: "Usage", Model: "m1", Messages: 1, PromptTokens: 200, CompletionTokens: 20},
+	}
+	if !slices.Equal(records, want) {
+		t.Errorf("ListUsage =\n%+v\nwant\n%+v", records, want)
+	}
+
+	records, _ = s.ListUsage(ctx, day2, time.Time{})
+	if len(records) != 1 || records[0].Day != "2026-10-02" {
+		t.Errorf("from day2 = %+v", records)
+	}
+	records, _ = s.ListUsage(ctx, time.Time{}, day1)
+	if len(records) != 2 {
+		t.Errorf("to day1 = %+v", records)
+	}
+
+	// Usage outlives the session, and imports aren't usage.
+	s.DeleteSession(ctx, "use1")
+	s.ImportSession(ctx, &storage.Session{ID: "use2"}, history)
+	records, _ = s.ListUsage(ctx, time.Time{}, time.Time{})
+	if len(records) != 3 || records[0].SessionID != "use1" || records[0].Title != "" {
+		t.Errorf("after delete and import = %+v", records)
+	}
+}
+
+func TestListMessages(t *testing.T) {
+	s := testStore(t)
+	ctx := context.Background()
+	s.CreateSession(ctx, &storage.Session{ID: "page1", Status: storage.StatusActive})
+	s.AppendMessages(ctx, "page1", []llm.Message{
+		llm.SystemMessage("sys"), llm.UserMessage("one"), llm.AssistantMessage("two"), llm.UserMessage("three"),
+	})
+
+	var got []string
+	opts := storage.MessageListOptions{Limit: 3}
+	for {
+		page, err := s.ListMessages(ctx, "page1", opts)
+		if err != nil {
+			t.Fatalf("ListMessages: %v", err)
+		}
+		if page.Total != 4 {
+			t.Errorf("Total = %d, want 4", page.Total)
+		}
+		for _, m := range page.Messages {
+			got = append(got, m.Content)
+		}
+		if page.Next == "" {
+			break
+		}
+		opts.Cursor = page.Next
+	}
+	if want := []string{"sys", "one", "two", "three"}; !slices.Equal(got, want) {
+		t.Errorf("messages = %v, want %v", got, want)
+	}
+
+	// Messages appended later follow the last page.
+	s.AppendMessages(ctx, "page1", []llm.Message{llm.AssistantMessage("four")})
+	page, err := s.ListMessages(ctx, "page1", storage.MessageListOptions{Cursor: opts.Cursor})
+	if err != nil {
+		t.Fatalf("ListMessages: %v", err)
+	}
+	if len(page.Messages) != 2 || page.Messages[1].Content != "four" || page.Next != "" {
+		t.Errorf("page after append = %+v", page)
+	}
+
+	if _, err := s.ListMessages(ctx, "page1", storage.MessageListOptions{Cursor: "bogus"}); err == nil {
+		t.Error("expected an error for an invalid cursor")
+	}
+}
+
 func TestLoadMessagesEmpty(t *testing.T) {
 	s := testStore(t)
 	ctx := context.Background()
@@ -313,3 +1124,167 @@ func TestLoadMessagesEmpty(t *testing.T) {
 		t.Errorf("expected nil for nonexistent session, got %v", msgs)
 	}
 }
+
+func TestRecordAndListToolCalls(t *testing.T) {
+	s := testStore(t)
+	ctx := context.Background()
+
+	now := time.Now()
+	calls := []tools.CallRecord{
+		{Tool: "file_read", Server: "file-ops", Duration: 1500 * time.Microsecond, At: now.Add(-48 * time.Hour)},
+		{Tool: "shell_exec", Server: "shell-exec", Duration: 20 * time.Millisecond, Failed: true, At: now.Add(-time.Hour)},
+		{Tool: "file_read", Server: "file-ops", Duration: 3 * time.Millisecond, At: now},
+	}
+	for _, c := range calls {
+		if err := s.RecordToolCall(ctx, c); err != nil {
+			t.Fatalf("RecordToolCall: %v", err)
+		}
+	}
+
+	all, err := s.ListToolCalls(ctx, time.Time{})
+	if err != nil {
+		t.Fatalf("ListToolCalls: %v", err)
+	}
+	if len(all) != 3 {
+		t.Fatalf("got %d calls, want 3", len(all))
+	}
+	if all[0].Duration != 1500*time.Microsecond || all[0].Server != "file-ops" {
+		t.Errorf("first call = %+v", all[0])
+	}
+	if !all[1].Failed {
+		t.Error("failed flag not persisted")
+	}
+
+	recent, err := s.ListToolCalls(ctx, now.Add(-24*time.Hour))
+	if err != nil {
+		t.Fatalf("ListToolCalls since: %v", err)
+	}
+	if len(recent) != 2 {
+		t.Errorf("got %d recent calls, want 2", len(recent))
+	}
+}
+
+func TestSessionToolCalls(t *testing.T) {
+	s := testStore(t)
+	ctx := context.Background()
+	s.CreateSession(ctx, &storage.Session{ID: "tc1", Status: storage.StatusActive})
+
+	now := time.Now()
+	for _, rec := range []tools.CallRecord{
+		{Tool: "shell_exec", Server: "shell-exec", SessionID: "tc1", CallID: "call_1", Args: map[string]any{"command": "ls"}, Result: "a.txt", Duration: time.Millisecond, At: now},
+		{Tool: "file_read", Server: "file-ops", SessionID: "other", CallID: "call_1", At: now},
+		{Tool: "file_read", Server: "file-ops", SessionID: "tc1", CallID: "call_2", Args: map[string]any{"path": "b"}, Result: "error: no such file", Failed: true, At: now},
+	} {
+		if err := s.RecordToolCall(ctx, rec); err != nil {
+			t.Fatalf("RecordToolCall: %v", err)
+		}
+	}
+
+	calls, err := s.ListSessionToolCalls(ctx, "tc1")
+	if err != nil {
+		t.Fatalf("ListSessionToolCalls: %v", err)
+	}
+	if len(calls) != 2 {
+		t.Fatalf("got %d calls, want 2", len(calls))
+	}
+	if c := calls[0]; c.CallID != "call_1" || c.Args["command"] != "ls" || c.Result != "a.txt" || c.Duration != time.Millisecond {
+		t.Errorf("first call = %+v", c)
+	}
+	if c := calls[1]; c.CallID != "call_2" || !c.Failed || c.Result != "error: no such file" {
+		t.Errorf("second call = %+v", c)
+	}
+
+	// Deleting the session keeps its calls for stats, without their contents.
+	if err := s.DeleteSession(ctx, "tc1"); err != nil {
+		t.Fatal(err)
+	}
+	calls, _ = s.ListSessionToolCalls(ctx, "tc1")
+	if len(calls) != 2 || calls[0].Args != nil || calls[0].Result != "" {
+		t.Errorf("calls after delete = %+v", calls)
+	}
+}
+
+func TestScheduledTasks(t *testing.T) {
+	s := testStore(t)
+	ctx := context.Background()
+
+	now := time.Now().Truncate(time.Second)
+	tasks := []*storage.Task{
+		{SessionID: "s1", Title: "later", Prompt: "check again", RunAt: now.Add(time.Hour)},
+		{SessionID: "s1", Title: "due", Prompt: "check the deploy", RunAt: now.Add(-time.Minute)},
+		{Title: "cancel me", Prompt: "never mind", RunAt: now.Add(-time.Second)},
+	}
+	for _, task := range tasks {
+		if err := s.CreateTask(ctx, task); err != nil {
+			t.Fatalf("CreateTask: %v", err)
+		}
+	}
+	if tasks[0].ID == 0 || tasks[0].Status != storage.TaskPending {
+		t.Fatalf("created task = %+v", tasks[0])
+	}
+	if err := s.CancelTask(ctx, tasks[2].ID); err != nil {
+		t.Fatalf("CancelTask: %v", err)
+	}
+
+	due, err := s.ClaimDueTasks(ctx, now)
+	if err != nil {
+		t.Fatalf("ClaimDueTasks: %v", err)
+	}
+	if len(due) != 1 || due[0].ID != tasks[1].ID || due[0].Status != storage.TaskRunning || due[0].Prompt != "check the deploy" || !due[0].RunAt.Equal(tasks[1].RunAt) {
+		t.Fatalf("claimed %+v, want task %d", due, tasks[1].ID)
+	}
+	if again, _ := s.ClaimDueTasks(ctx, now); len(again) != 0 {
+		t.Errorf("claimed twice: %+v", again)
+	}
+	if err := s.CancelTask(ctx, tasks[1].ID); err == nil || err.Error() != "task 2 is running, not pending" {
+		t.Errorf("CancelTask on a running task = %v", err)
+	}
+	if err := s.FinishTask(ctx, tasks[1].ID, storage.TaskDone, "deploy is green"); err != nil {
+		t.Fatalf("FinishTask: %v", err)
+	}
+
+	got, err := s.GetTask(ctx, tasks[1].ID)
+	if err != nil {
+		t.Fatalf("GetTask: %v", err)
+	}
+	if got.Status != storage.TaskDone || got.Result != "deploy is green" {
+		t.Errorf("finished task = %+v", got)
+	}
+	if _, err := s.GetTask(ctx, 99); err == nil {
+		t.Error("GetTask(99) succeeded")
+	}
+
+	list, err := s.ListTasks(ctx, storage.TaskListOptions{SessionID: "s1"})
+	if err != nil {
+		t.Fatalf("ListTasks: %v", err)
+	}
+	if len(list) != 2 || list[0].Title != "due" || list[1].Title != "later" {
+		t.Errorf("ListTasks(s1) = %+v", list)
+	}
+	pending, err := s.ListTasks(ctx, storage.TaskListOptions{Status: storage.TaskPending})
+	if err != nil {
+		t.Fatalf("ListTasks: %v", err)
+	}
+	if len(pending) != 1 || pending[0].Title != "later" {
+		t.Errorf("ListTasks(pending) = %+v", pending)
+	}
+}
+
+func TestCheck(t *testing.T) {
+	s, err := Open(filepath.Join(t.TempDir(), "forge.db"))
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	ctx := context.Background()
+	if err := s.CreateSession(ctx, &storage.Session{ID: "s1", Title: "checked", Status: storage.StatusActive}); err != nil {
+		t.Fatalf("CreateSession: %v", err)
+	}
+	if err := s.Check(ctx); err != nil {
+		t.Errorf("Check: %v", err)
+	}
+
+	s.Close()
+	if err := s.Check(ctx); err == nil {
+		t.Error("Check on a closed store succeeded")
+	}
+}
@@ -0,0 +1,137 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/michaelbrown/forge/internal/storage"
+)
+
+const taskColumns = `id, session_id, title, prompt, run_at, status, result, created_at, updated_at`
+
+func (s *SQLiteStore) CreateTask(ctx context.Context, t *storage.Task) error {
+	now := time.Now().UTC()
+	t.Status = storage.TaskPending
+	t.CreatedAt = now
+	t.UpdatedAt = now
+
+	res, err := s.db.ExecContext(ctx, `
+		INSERT INTO scheduled_tasks (session_id, title, prompt, run_at, status, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		t.SessionID, t.Title, t.Prompt, t.RunAt.UTC().Format(sortableTimeFormat), t.Status,
+		now.Format(sortableTimeFormat), now.Format(sortableTimeFormat),
+	)
+	if err != nil {
+		return fmt.Errorf("inserting task: %w", err)
+	}
+	t.ID, err = res.LastInsertId()
+	return err
+}
+
+func (s *SQLiteStore) GetTask(ctx context.Context, id int64) (*storage.Task, error) {
+	row := s.db.QueryRowContext(ctx, `SELECT `+taskColumns+` FROM scheduled_tasks WHERE id = ?`, id)
+	t, err := scanTask(row)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("task not found: %d", id)
+	}
+	return t, err
+}
+
+func (s *SQLiteStore) ListTasks(ctx context.Context, opts storage.TaskListOptions) ([]storage.Task, error) {
+	limit := opts.Limit
+	if limit <= 0 {
+		limit = 50
+	}
+
+	query := `SELECT ` + taskColumns + ` FROM scheduled_tasks WHERE 1 = 1`
+	var args []any
+	if opts.Status != "" {
+		query += ` AND status = ?`
+		args = append(args, string(opts.Status))
+	}
+	if opts.SessionID != "" {
+		query += ` AND session_id = ?`
+		args = append(args, opts.SessionID)
+	}
+	query += ` ORDER BY run_at, id LIMIT ?`
+	args = append(args, limit)
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("listing tasks: %w", err)
+	}
+	defer rows.Close()
+	return scanTasks(rows)
+}
+
+func (s *SQLiteStore) ClaimDueTasks(ctx context.Context, now time.Time) ([]storage.Task, error) {
+	// A single UPDATE ... RETURNING claims atomically, so two servers
+	// sharing the database never both run a task.
+	rows, err := s.db.QueryContext(ctx, `
+		UPDATE scheduled_tasks SET status = ?, updated_at = ?
+		WHERE status = ? AND run_at <= ?
+		RETURNING `+taskColumns,
+		storage.TaskRunning, time.Now().UTC().Format(sortableTimeFormat),
+		storage.TaskPending, now.UTC().Format(sortableTimeFormat),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("claiming tasks: %w", err)
+	}
+	defer rows.Close()
+	return scanTasks(rows)
+}
+
+func (s *SQLiteStore) FinishTask(ctx context.Context, id int64, status storage.TaskStatus, result string) error {
+	_, err := s.db.ExecContext(ctx, `
+		UPDATE scheduled_tasks SET status = ?, result = ?, updated_at = ? WHERE id = ?`,
+		status, result, time.Now().UTC().Format(sortableTimeFormat), id,
+	)
+	return err
+}
+
+func (s *SQLiteStore) CancelTask(ctx context.Context, id int64) error {
+	res, err := s.db.ExecContext(ctx, `
+		UPDATE scheduled_tasks SET status = ?, updated_at = ? WHERE id = ? AND status = ?`,
+		storage.TaskCancelled, time.Now().UTC().Format(sortableTimeFormat), id, storage.TaskPending,
+	)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		t, err := s.GetTask(ctx, id)
+		if err != nil {
+			return err
+		}
+		return fmt.Errorf("task %d is %s, not pending", id, t.Status)
+	}
+	return nil
+}
+
+func scanTasks(rows *sql.Rows) ([]storage.Task, error) {
+	var tasks []storage.Task
+	for rows.Next() {
+		t, err := scanTask(rows)
+		if err != nil {
+			return nil, err
+		}
+		tasks = append(tasks, *t)
+	}
+	return tasks, rows.Err()
+}
+
+func scanTask(s scanner) (*storage.Task, error) {
+	var t storage.Task
+	var runAt, createdAt, updatedAt string
+	err := s.Scan(&t.ID, &t.SessionID, &t.Title, &t.Prompt, &runAt, &t.Status, &t.Result, &createdAt, &updatedAt)
+	if err != nil {
+		return nil, err
+	}
+	// The driver may hand DATETIME columns back reformatted, without
+	// trailing zero fractions, so parse leniently.
+	t.RunAt, _ = time.Parse(time.RFC3339, runAt)
+	t.CreatedAt, _ = time.Parse(time.RFC3339, createdAt)
+	t.UpdatedAt, _ = time.Parse(time.RFC3339, updatedAt)
+	return &t, nil
+}
@@ -0,0 +1,179 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/michaelbrown/forge/internal/storage"
+)
+
+var _ storage.VectorStore = (*SQLiteStore)(nil)
+
+const defaultVectorK = 10
+
+// Embeddings are stored as little-endian float32 BLOBs and searched by
+// scanning the collection. The pure-Go driver can't load sqlite-vec, and a
+// scan is exact and quick enough for the tens of thousands of vectors a
+// local forge keeps.
+
+func (s *SQLiteStore) UpsertVectors(ctx context.Context, collection string, items []storage.VectorItem) error {
+	if collection == "" {
+		return fmt.Errorf("collection is required")
+	}
+	if len(items) == 0 {
+		return nil
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	dims, err := collectionDims(ctx, tx, collection)
+	if err != nil {
+		return err
+	}
+	now := time.Now().UTC().Format(sortableTimeFormat)
+	for _, item := range items {
+		if item.ID == "" {
+			return fmt.Errorf("vector id is required")
+		}
+		if len(item.Embedding) == 0 {
+			return fmt.Errorf("vector %s has no embedding", item.ID)
+		}
+		if dims == 0 {
+			dims = len(item.Embedding)
+		} else if len(item.Embedding) != dims {
+			return fmt.Errorf("vector %s has %d dimensions, collection %s has %d", item.ID, len(item.Embedding), collection, dims)
+		}
+		meta, err := json.Marshal(item.Metadata)
+		if err != nil {
+			return err
+		}
+		_, err = tx.ExecContext(ctx, `
+			INSERT INTO vectors (collection, id, session_id, content, metadata, dims, embedding, updated_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+			ON CONFLICT (collection, id) DO UPDATE SET
+				session_id = excluded.session_id, content = excluded.content, metadata = excluded.metadata,
+				dims = excluded.dims, embedding = excluded.embedding, updated_at = excluded.updated_at`,
+			collection, item.ID, item.SessionID, item.Content, string(meta), dims, encodeVector(item.Embedding), now,
+		)
+		if err != nil {
+			return fmt.Errorf("saving vector %s: %w", item.ID, err)
+		}
+	}
+	return tx.Commit()
+}
+
+func (s *SQLiteStore) DeleteVectors(ctx context.Context, collection string, ids ...string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	args := []any{collection}
+	for _, id := range ids {
+		args = append(args, id)
+	}
+	placeholders := strings.TrimSuffix(strings.Repeat("?,", len(ids)), ",")
+	_, err := s.db.ExecContext(ctx, `DELETE FROM vectors WHERE collection = ? AND id IN (`+placeholders+`)`, args...)
+	return err
+}
+
+func (s *SQLiteStore) SearchVectors(ctx context.Context, collection string, query []float32, opts storage.VectorSearchOptions) ([]storage.VectorMatch, error) {
+	if len(query) == 0 {
+		return nil, fmt.Errorf("query vector is empty")
+	}
+	k := opts.K
+	if k <= 0 {
+		k = defaultVectorK
+	}
+
+	q := `SELECT id, session_id, content, metadata, embedding FROM vectors WHERE collection = ?`
+	args := []any{collection}
+	if opts.SessionID != "" {
+		q += ` AND session_id = ?`
+		args = append(args, opts.SessionID)
+	}
+	rows, err := s.db.QueryContext(ctx, q, args...)
+	if err != nil {
+		return nil, fmt.Errorf("searching vectors: %w", err)
+	}
+	defer rows.Close()
+
+	// best holds the top k so far, most similar first.
+	var best []storage.VectorMatch
+	for rows.Next() {
+		var m storage.VectorMatch
+		var meta string
+		var blob []byte
+		if err := rows.Scan(&m.ID, &m.SessionID, &m.Content, &meta, &blob); err != nil {
+			return nil, err
+		}
+		m.Embedding = decodeVector(blob)
+		if len(m.Embedding) != len(query) {
+			return nil, fmt.Errorf("query has %d dimensions, collection %s has %d", len(query), collection, len(m.Embedding))
+		}
+		m.Score = storage.CosineSimilarity(query, m.Embedding)
+		if m.Score < opts.MinScore || len(best) == k && m.Score <= best[k-1].Score {
+			continue
+		}
+		if err := json.Unmarshal([]byte(meta), &m.Metadata); err != nil {
+			return nil, fmt.Errorf("reading metadata of vector %s: %w", m.ID, err)
+		}
+		if !matchesFilter(m.Metadata, opts.Filter) {
+			continue
+		}
+		i := sort.Search(len(best), func(i int) bool { return best[i].Score < m.Score })
+		best = append(best, storage.VectorMatch{})
+		copy(best[i+1:], best[i:])
+		best[i] = m
+		if len(best) > k {
+			best = best[:k]
+		}
+	}
+	return best, rows.Err()
+}
+
+// collectionDims returns the number of dimensions of a collection's
+// embeddings, or 0 if it is empty.
+func collectionDims(ctx context.Context, tx *sql.Tx, collection string) (int, error) {
+	var dims int
+	err := tx.QueryRowContext(ctx, `SELECT dims FROM vectors WHERE collection = ? LIMIT 1`, collection).Scan(&dims)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, nil
+	}
+	return dims, err
+}
+
+func matchesFilter(meta, filter map[string]string) bool {
+	for k, v := range filter {
+		if got, ok := meta[k]; !ok || got != v {
+			return false
+		}
+	}
+	return true
+}
+
+func encodeVector(v []float32) []byte {
+	buf := make([]byte, 4*len(v))
+	for i, f := range v {
+		binary.LittleEndian.PutUint32(buf[4*i:], math.Float32bits(f))
+	}
+	return buf
+}
+
+func decodeVector(buf []byte) []float32 {
+	v := make([]float32, len(buf)/4)
+	for i := range v {
+		v[i] = math.Float32frombits(binary.LittleEndian.Uint32(buf[4*i:]))
+	}
+	return v
+}
@@ -2,9 +2,14 @@ package storage
 
 import (
 	"context"
+	"fmt"
+	"slices"
+	"strings"
 	"time"
+	"unicode"
 
 	"github.com/michaelbrown/forge/internal/llm"
+	"github.com/michaelbrown/forge/internal/tools"
 )
 
 // SessionStatus represents the lifecycle state of a session.
@@ -15,6 +20,7 @@ const (
 	StatusRunning   SessionStatus = "running"
 	StatusCompleted SessionStatus = "completed"
 	StatusFailed    SessionStatus = "failed"
+	StatusArchived  SessionStatus = "archived"
 )
 
 // Session is the metadata for a saved conversation.
@@ -25,40 +31,232 @@ type Session struct {
 	Provider  string        `json:"provider"`
 	Model     string        `json:"model"`
 	Profile   string        `json:"profile"`
+	Tags      []string      `json:"tags"`
 	CreatedAt time.Time     `json:"created_at"`
 	UpdatedAt time.Time     `json:"updated_at"`
 }
 
+// Artifact describes a file saved during a session, such as a program's
+// output. Its contents are kept by Artifacts.
+type Artifact struct {
+	SessionID string    `json:"session_id"`
+	Name      string    `json:"name"`
+	MIMEType  string    `json:"mime_type"`
+	Size      int64     `json:"size"`
+	Tool      string    `json:"tool"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
 // SessionListOptions controls filtering and pagination for ListSessions.
 type SessionListOptions struct {
-	Status SessionStatus
+	Status   SessionStatus // without a status, archived sessions are left out
+	Tag      string
+	Profile  string
+	Provider string
+	Model    string
+	// Since and Until, if not zero, keep the sessions active at some time
+	// between them: updated at or after Since, and created before Until.
+	Since  time.Time
+	Until  time.Time
+	All    bool   // include archived sessions
+	Cursor string // from SessionCursor; lists the sessions after that one
 	Limit  int
 	Offset int
 }
 
-// Store is the persistence interface for sessions and messages.
+// NormalizeTags lowercases tags, drops duplicates, and sorts them. Tags may
+// contain letters, digits, and - _ . / : but no spaces.
+func NormalizeTags(tags []string) ([]string, error) {
+	out := []string{}
+	for _, tag := range tags {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag == "" {
+			continue
+		}
+		if strings.IndexFunc(tag, func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsDigit(r) && !strings.ContainsRune("-_./:", r)
+		}) >= 0 || len(tag) > 64 {
+			return nil, fmt.Errorf("invalid tag %q: use up to 64 letters, digits, and - _ . / :", tag)
+		}
+		out = append(out, tag)
+	}
+	slices.Sort(out)
+	return slices.Compact(out), nil
+}
+
+// SearchResult is a session that matches a full-text search. Matching words
+// in Title and Snippets are marked in **bold**.
+type SearchResult struct {
+	Session  Session  `json:"session"`
+	Title    string   `json:"title"`
+	Snippets []string `json:"snippets"` // passages from matching messages, best first
+}
+
+// TaskStatus represents the lifecycle state of a scheduled task.
+type TaskStatus string
+
+const (
+	TaskPending   TaskStatus = "pending"
+	TaskRunning   TaskStatus = "running"
+	TaskDone      TaskStatus = "done"
+	TaskFailed    TaskStatus = "failed"
+	TaskCancelled TaskStatus = "cancelled"
+)
+
+// Task is a prompt scheduled to run at a later time, usually as a follow-up
+// in the session that scheduled it. The forge server runs tasks when they
+// come due.
+type Task struct {
+	ID        int64      `json:"id"`
+	SessionID string     `json:"session_id"` // empty runs the task in a new session
+	Title     string     `json:"title"`
+	Prompt    string     `json:"prompt"`
+	RunAt     time.Time  `json:"run_at"`
+	Status    TaskStatus `json:"status"`
+	Result    string     `json:"result"` // the agent's reply, or why the task failed
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+}
+
+// TaskListOptions controls filtering for ListTasks.
+type TaskListOptions struct {
+	Status    TaskStatus
+	SessionID string
+	Limit     int
+}
+
+// History is a conversation that tracks which of its messages have been
+// persisted, such as an agent's.
+type History interface {
+	UnsavedHistory() (messages []llm.Message, rewrite bool)
+	MarkSaved()
+}
+
+// SaveHistory persists the messages h gained since it was last saved. Only
+// when h was compacted or reset is the stored history rewritten in full.
+func SaveHistory(ctx context.Context, s Store, sessionID string, h History) error {
+	messages, rewrite := h.UnsavedHistory()
+	var err error
+	switch {
+	case rewrite:
+		err = s.SaveMessages(ctx, sessionID, messages)
+	case len(messages) > 0:
+		err = s.AppendMessages(ctx, sessionID, messages)
+	}
+	if err != nil {
+		return err
+	}
+	h.MarkSaved()
+	return nil
+}
+
+// Store is the persistence interface for sessions, messages, and tool usage.
 type Store interface {
 	// CreateSession inserts a new session. The ID field must be set by the caller.
 	CreateSession(ctx context.Context, s *Session) error
 
+	// ImportSession inserts a session with its messages, keeping its
+	// timestamps (zero ones are set to now). The ID field must be set by the
+	// caller.
+	ImportSession(ctx context.Context, s *Session, messages []llm.Message) error
+
 	// GetSession returns a session by ID or ID prefix.
 	GetSession(ctx context.Context, id string) (*Session, error)
 
-	// ListSessions returns sessions ordered by updated_at descending.
+	// ListSessions returns sessions ordered by updated_at descending, then
+	// by ID. Saving messages counts as an update.
 	ListSessions(ctx context.Context, opts SessionListOptions) ([]Session, error)
 
-	// UpdateSession updates mutable fields (title, status, updated_at).
+	// CountSessions returns how many sessions match opts' filters, ignoring
+	// its cursor, limit, and offset.
+	CountSessions(ctx context.Context, opts SessionListOptions) (int, error)
+
+	// UpdateSession updates mutable fields (title, status, provider, model,
+	// profile, updated_at).
 	UpdateSession(ctx context.Context, s *Session) error
 
-	// DeleteSession removes a session and its messages.
+	// SetSessionTags replaces a session's tags.
+	SetSessionTags(ctx context.Context, id string, tags []string) error
+
+	// DeleteSession removes a session and its messages, artifact records,
+	// and vectors, and clears the arguments and results of its tool calls.
 	DeleteSession(ctx context.Context, id string) error
 
+	// ArchiveIdleSessions archives sessions not updated since before, other
+	// than running ones, and returns their IDs.
+	ArchiveIdleSessions(ctx context.Context, before time.Time) ([]string, error)
+
+	// DeleteArchivedSessions deletes sessions archived before before, with
+	// their messages, artifact records, and vectors, clears the arguments
+	// and results of their tool calls, and returns their IDs.
+	DeleteArchivedSessions(ctx context.Context, before time.Time) ([]string, error)
+
 	// SaveMessages overwrites the full message history for a session.
 	SaveMessages(ctx context.Context, sessionID string, messages []llm.Message) error
 
+	// AppendMessages adds messages to the end of a session's history.
+	AppendMessages(ctx context.Context, sessionID string, messages []llm.Message) error
+
 	// LoadMessages returns the message history for a session.
 	LoadMessages(ctx context.Context, sessionID string) ([]llm.Message, error)
 
+	// ListMessages returns a page of a session's message history. Rewriting
+	// the history gives its messages new places, so a cursor from before a
+	// rewrite continues from the start of the new history.
+	ListMessages(ctx context.Context, sessionID string, opts MessageListOptions) (*MessagePage, error)
+
+	// CreateArtifact records an artifact. It fails if the session already
+	// has one with the same name.
+	CreateArtifact(ctx context.Context, a *Artifact) error
+
+	// ListArtifacts returns a session's artifacts, oldest first.
+	ListArtifacts(ctx context.Context, sessionID string) ([]Artifact, error)
+
+	// GetArtifact returns a session's artifact by name.
+	GetArtifact(ctx context.Context, sessionID, name string) (*Artifact, error)
+
+	// SearchSessions returns the sessions whose title or messages contain
+	// every word of query, best matches first, among those opts' filters
+	// keep. Its cursor and offset are ignored.
+	SearchSessions(ctx context.Context, query string, opts SessionListOptions) ([]SearchResult, error)
+
+	// RecordToolCall appends a tool call, with its arguments and result, to
+	// the tool call log.
+	RecordToolCall(ctx context.Context, rec tools.CallRecord) error
+
+	// ListToolCalls returns tool calls made at or after since (all calls if
+	// zero), without their arguments and results.
+	ListToolCalls(ctx context.Context, since time.Time) ([]tools.CallRecord, error)
+
+	// ListSessionToolCalls returns the tool calls made in a session, in
+	// order, with their arguments and results.
+	ListSessionToolCalls(ctx context.Context, sessionID string) ([]tools.CallRecord, error)
+
+	// ListUsage returns the usage log for the days from through to, as UTC
+	// dates; a zero time leaves that end open. Usage outlives deleted
+	// sessions.
+	ListUsage(ctx context.Context, from, to time.Time) ([]UsageRecord, error)
+
+	// CreateTask inserts a pending task and sets its ID.
+	CreateTask(ctx context.Context, t *Task) error
+
+	// GetTask returns a task by ID.
+	GetTask(ctx context.Context, id int64) (*Task, error)
+
+	// ListTasks returns tasks ordered by run_at.
+	ListTasks(ctx context.Context, opts TaskListOptions) ([]Task, error)
+
+	// ClaimDueTasks marks pending tasks due at or before now as running and
+	// returns them. A task is only ever claimed once.
+	ClaimDueTasks(ctx context.Context, now time.Time) ([]Task, error)
+
+	// FinishTask records the outcome of a running task.
+	FinishTask(ctx context.Context, id int64, status TaskStatus, result string) error
+
+	// CancelTask cancels a pending task. It fails if the task has already
+	// started.
+	CancelTask(ctx context.Context, id int64) error
+
 	// Close releases resources.
 	Close() error
 }
@@ -0,0 +1,157 @@
+package storage
+
+import (
+	"slices"
+	"sort"
+)
+
+// UsageRecord is one row of the usage log: what a session did on a day
+// (UTC) with a model. Messages without a model, such as the user's and
+// tool results, are counted under an empty Model.
+type UsageRecord struct {
+	Day              string `json:"day"` // YYYY-MM-DD
+	SessionID        string `json:"session_id"`
+	Title            string `json:"title"` // empty if the session was deleted
+	Model            string `json:"model"`
+	Messages         int    `json:"messages"`
+	ToolCalls        int    `json:"tool_calls"`
+	PromptTokens     int64  `json:"prompt_tokens"`
+	CompletionTokens int64  `json:"completion_tokens"`
+}
+
+// CostFunc estimates what a model's token usage cost in US dollars. ok is
+// false when the model's price isn't known.
+type CostFunc func(model string, promptTokens, completionTokens int64) (cost float64, ok bool)
+
+// UsageTotals sums usage over some set of records.
+type UsageTotals struct {
+	Sessions         int     `json:"sessions"`
+	Messages         int     `json:"messages"`
+	ToolCalls        int     `json:"tool_calls"`
+	PromptTokens     int64   `json:"prompt_tokens"`
+	CompletionTokens int64   `json:"completion_tokens"`
+	Cost             float64 `json:"estimated_cost"`
+	UnpricedTokens   int64   `json:"unpriced_tokens"` // tokens of models without a price
+}
+
+// DayUsage is the usage on one day.
+type DayUsage struct {
+	Day string `json:"day"`
+	UsageTotals
+}
+
+// SessionUsage is the usage of one session, with the models it used.
+type SessionUsage struct {
+	SessionID string   `json:"session_id"`
+	Title     string   `json:"title"`
+	Models    []string `json:"models"`
+	UsageTotals
+}
+
+// ModelUsage is the usage of one model.
+type ModelUsage struct {
+	Model string `json:"model"`
+	UsageTotals
+}
+
+// UsageReport summarizes usage by day, session, and model.
+type UsageReport struct {
+	Totals   UsageTotals    `json:"totals"`
+	Days     []DayUsage     `json:"days"`
+	Sessions []SessionUsage `json:"sessions"`
+	Models   []ModelUsage   `json:"models"`
+}
+
+// SummarizeUsage aggregates usage records into a report: days in order,
+// sessions and models with the most tokens first. cost may be nil.
+func SummarizeUsage(records []UsageRecord, cost CostFunc) UsageReport {
+	var report UsageReport
+	days := map[string]*DayUsage{}
+	sessions := map[string]*SessionUsage{}
+	models := map[string]*ModelUsage{}
+	daySessions := map[string]map[string]bool{}
+	modelSessions := map[string]map[string]bool{}
+	allSessions := map[string]bool{}
+
+	for _, r := range records {
+		var c float64
+		priced := r.PromptTokens+r.CompletionTokens == 0
+		if cost != nil && !priced {
+			c, priced = cost(r.Model, r.PromptTokens, r.CompletionTokens)
+		}
+
+		d := days[r.Day]
+		if d == nil {
+			d = &DayUsage{Day: r.Day}
+			days[r.Day] = d
+			daySessions[r.Day] = map[string]bool{}
+		}
+		s := sessions[r.SessionID]
+		if s == nil {
+			s = &SessionUsage{SessionID: r.SessionID, Title: r.Title, Models: []string{}, UsageTotals: UsageTotals{Sessions: 1}}
+			sessions[r.SessionID] = s
+		}
+		if r.Model != "" && !slices.Contains(s.Models, r.Model) {
+			s.Models = append(s.Models, r.Model)
+		}
+		daySessions[r.Day][r.SessionID] = true
+		allSessions[r.SessionID] = true
+
+		totals := []*UsageTotals{&report.Totals, &d.UsageTotals, &s.UsageTotals}
+		if r.Model != "" {
+			m := models[r.Model]
+			if m == nil {
+				m = &ModelUsage{Model: r.Model}
+				models[r.Model] = m
+				modelSessions[r.Model] = map[string]bool{}
+			}
+			modelSessions[r.Model][r.SessionID] = true
+			totals = append(totals, &m.UsageTotals)
+		}
+		for _, t := range totals {
+			t.Messages += r.Messages
+			t.ToolCalls += r.ToolCalls
+			t.PromptTokens += r.PromptTokens
+			t.CompletionTokens += r.CompletionTokens
+			t.Cost += c
+			if !priced {
+				t.UnpricedTokens += r.PromptTokens + r.CompletionTokens
+			}
+		}
+	}
+
+	report.Totals.Sessions = len(allSessions)
+	report.Days = []DayUsage{}
+	for day, d := range days {
+		d.Sessions = len(daySessions[day])
+		report.Days = append(report.Days, *d)
+	}
+	sort.Slice(report.Days, func(i, j int) bool { return report.Days[i].Day < report.Days[j].Day })
+
+	report.Sessions = []SessionUsage{}
+	for _, s := range sessions {
+		slices.Sort(s.Models)
+		report.Sessions = append(report.Sessions, *s)
+	}
+	sort.Slice(report.Sessions, func(i, j int) bool {
+		a, b := report.Sessions[i], report.Sessions[j]
+		if ta, tb := a.PromptTokens+a.CompletionTokens, b.PromptTokens+b.CompletionTokens; ta != tb {
+			return ta > tb
+		}
+		return a.SessionID < b.SessionID
+	})
+
+	report.Models = []ModelUsage{}
+	for model, m := range models {
+		m.Sessions = len(modelSessions[model])
+		report.Models = append(report.Models, *m)
+	}
+	sort.Slice(report.Models, func(i, j int) bool {
+		a, b := report.Models[i], report.Models[j]
+		if ta, tb := a.PromptTokens+a.CompletionTokens, b.PromptTokens+b.CompletionTokens; ta != tb {
+			return ta > tb
+		}
+		return a.Model < b.Model
+	})
+	return report
+}
@@ -0,0 +1,50 @@
+package storage_test
+
+import (
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/michaelbrown/forge/internal/storage"
+)
+
+func TestSummarizeUsage(t *testing.T) {
+	records := []storage.UsageRecord{
+		{Day: "2026-10-01", SessionID: "s1", Title: "One", Model: "", Messages: 2},
+		{Day: "2026-10-01", SessionID: "s1", Title: "One", Model: "claude", Messages: 1, ToolCalls: 2, PromptTokens: 1000, CompletionTokens: 100},
+		{Day: "2026-10-01", SessionID: "s2", Title: "Two", Model: "local", Messages: 3, PromptTokens: 5000, CompletionTokens: 500},
+		{Day: "2026-10-02", SessionID: "s1", Title: "One", Model: "claude", Messages: 1, PromptTokens: 2000, CompletionTokens: 200},
+	}
+	cost := func(model string, prompt, completion int64) (float64, bool) {
+		if model != "claude" {
+			return 0, false
+		}
+		return float64(prompt+completion) / 1000, true
+	}
+
+	r := storage.SummarizeUsage(records, cost)
+
+	if r.Totals.Sessions != 2 || r.Totals.Messages != 7 || r.Totals.ToolCalls != 2 || r.Totals.PromptTokens != 8000 || r.Totals.CompletionTokens != 800 {
+		t.Errorf("totals = %+v", r.Totals)
+	}
+	if math.Abs(r.Totals.Cost-3.3) > 1e-9 || r.Totals.UnpricedTokens != 5500 {
+		t.Errorf("cost = %v, unpriced = %d", r.Totals.Cost, r.Totals.UnpricedTokens)
+	}
+
+	if len(r.Days) != 2 || r.Days[0].Day != "2026-10-01" || r.Days[0].Sessions != 2 || r.Days[0].Messages != 6 || r.Days[1].Sessions != 1 {
+		t.Errorf("days = %+v", r.Days)
+	}
+
+	if len(r.Sessions) != 2 || r.Sessions[0].SessionID != "s2" || r.Sessions[1].Messages != 4 || strings.Join(r.Sessions[1].Models, ",") != "claude" {
+		t.Errorf("sessions = %+v", r.Sessions)
+	}
+
+	if len(r.Models) != 2 || r.Models[0].Model != "local" || r.Models[1].Model != "claude" || r.Models[1].Sessions != 1 || math.Abs(r.Models[1].Cost-3.3) > 1e-9 {
+		t.Errorf("models = %+v", r.Models)
+	}
+
+	empty := storage.SummarizeUsage(nil, nil)
+	if empty.Days == nil || empty.Sessions == nil || empty.Models == nil || empty.Totals.Sessions != 0 {
+		t.Errorf("empty report = %+v", empty)
+	}
+}
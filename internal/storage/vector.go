@@ -0,0 +1,66 @@
+package storage
+
+import (
+	"context"
+	"math"
+)
+
+// VectorItem is an embedding with the text it was made from and metadata
+// to filter on. Items are keyed by ID within a collection, such as
+// "memory" or "docs".
+type VectorItem struct {
+	ID        string            `json:"id"`
+	SessionID string            `json:"session_id,omitempty"` // deleted with the session when set
+	Content   string            `json:"content,omitempty"`
+	Metadata  map[string]string `json:"metadata,omitempty"`
+	Embedding []float32         `json:"-"`
+}
+
+// VectorSearchOptions controls a nearest-neighbour search.
+type VectorSearchOptions struct {
+	K         int               // number of results; default 10
+	SessionID string            // only items from this session
+	Filter    map[string]string // only items whose metadata has these values
+	MinScore  float64           // drop results less similar than this
+}
+
+// VectorMatch is a search result. Score is the cosine similarity to the
+// query, from -1 to 1.
+type VectorMatch struct {
+	VectorItem
+	Score float64 `json:"score"`
+}
+
+// VectorStore keeps embeddings and finds the ones nearest a query vector.
+// All embeddings in a collection must have the same number of dimensions.
+type VectorStore interface {
+	// UpsertVectors adds items to a collection, replacing any with the
+	// same IDs.
+	UpsertVectors(ctx context.Context, collection string, items []VectorItem) error
+
+	// DeleteVectors removes items from a collection by ID.
+	DeleteVectors(ctx context.Context, collection string, ids ...string) error
+
+	// SearchVectors returns the k items in a collection most similar to
+	// query, best first.
+	SearchVectors(ctx context.Context, collection string, query []float32, opts VectorSearchOptions) ([]VectorMatch, error)
+}
+
+// CosineSimilarity returns the cosine of the angle between a and b, or 0 if
+// either is all zeros or their lengths differ.
+func CosineSimilarity(a, b []float32) float64 {
+	if len(a) != len(b) {
+		return 0
+	}
+	var dot, na, nb float64
+	for i := range a {
+		x, y := float64(a[i]), float64(b[i])
+		dot += x * y
+		na += x * x
+		nb += y * y
+	}
+	if na == 0 || nb == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(na) * math.Sqrt(nb))
+}
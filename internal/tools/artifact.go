@@ -0,0 +1,66 @@
+package tools
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net/url"
+	"path"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// Artifact is a file a tool returned with its result, as an MCP embedded
+// resource, such as a chart a program drew or a document it fetched.
+type Artifact struct {
+	Name     string // from the resource URI's last path element
+	MIMEType string
+	Data     []byte
+	Tool     string
+}
+
+// ArtifactSaver keeps an artifact for a session and returns the name it was
+// saved under, which may differ from a.Name to avoid replacing another.
+type ArtifactSaver func(ctx context.Context, sessionID string, a Artifact) (string, error)
+
+type artifactSaverKey struct{}
+
+func withArtifactSaver(ctx context.Context, save ArtifactSaver) context.Context {
+	return context.WithValue(ctx, artifactSaverKey{}, save)
+}
+
+// saveResource saves an embedded resource returned by tool and returns a
+// note for the tool result. Without a session or saver, the resource is
+// dropped and the note is empty.
+func saveResource(ctx context.Context, tool string, res mcp.ResourceContents) string {
+	save, _ := ctx.Value(artifactSaverKey{}).(ArtifactSaver)
+	session := SessionFromContext(ctx)
+	if save == nil || session == "" {
+		return ""
+	}
+
+	a := Artifact{Tool: tool}
+	var uri string
+	switch r := res.(type) {
+	case mcp.TextResourceContents:
+		uri, a.MIMEType, a.Data = r.URI, r.MIMEType, []byte(r.Text)
+	case mcp.BlobResourceContents:
+		data, err := base64.StdEncoding.DecodeString(r.Blob)
+		if err != nil {
+			return fmt.Sprintf("Could not save artifact %s: %v", r.URI, err)
+		}
+		uri, a.MIMEType, a.Data = r.URI, r.MIMEType, data
+	default:
+		return ""
+	}
+	a.Name = uri
+	if u, err := url.Parse(uri); err == nil && u.Path != "" {
+		a.Name = path.Base(u.Path)
+	}
+
+	name, err := save(ctx, session, a)
+	if err != nil {
+		return fmt.Sprintf("Could not save artifact %s: %v", a.Name, err)
+	}
+	return fmt.Sprintf("Saved artifact %s (%d bytes) to this session.", name, len(a.Data))
+}
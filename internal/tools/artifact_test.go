@@ -0,0 +1,74 @@
+package tools_test
+
+import (
+	"context"
+	"encoding/base64"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+
+	"github.com/michaelbrown/forge/internal/tools"
+)
+
+func TestRegistry_SavesEmbeddedResources(t *testing.T) {
+	s := server.NewMCPServer("artifact-test", "0.1.0")
+	s.AddTool(mcp.NewTool("make_chart"),
+		func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+			return &mcp.CallToolResult{Content: []mcp.Content{
+				mcp.TextContent{Type: "text", Text: "drew a chart"},
+				mcp.EmbeddedResource{Type: "resource", Resource: mcp.BlobResourceContents{
+					URI: "artifact:///out/chart.png", MIMEType: "image/png",
+					Blob: base64.StdEncoding.EncodeToString([]byte{0x89, 'P', 'N', 'G'}),
+				}},
+				mcp.EmbeddedResource{Type: "resource", Resource: mcp.TextResourceContents{
+					URI: "artifact:///notes.txt", Text: "some notes",
+				}},
+			}}, nil
+		})
+	ts := httptest.NewServer(server.NewStreamableHTTPServer(s))
+	defer ts.Close()
+
+	r := tools.NewRegistry()
+	defer r.Close()
+	if err := r.Register("charts", tools.ToolServerConfig{URL: ts.URL, Enabled: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	var saved []tools.Artifact
+	r.SetArtifactSaver(func(ctx context.Context, sessionID string, a tools.Artifact) (string, error) {
+		if sessionID != "sess-1" {
+			t.Errorf("session = %q", sessionID)
+		}
+		saved = append(saved, a)
+		return a.Name, nil
+	})
+
+	// Without a session there is nowhere to keep the files.
+	result, err := r.CallTool(context.Background(), "make_chart", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result != "drew a chart" || len(saved) != 0 {
+		t.Errorf("without session: %q, saved %d", result, len(saved))
+	}
+
+	result, err = r.CallTool(tools.WithSession(context.Background(), "sess-1"), "make_chart", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(saved) != 2 {
+		t.Fatalf("saved %d artifacts, want 2", len(saved))
+	}
+	if a := saved[0]; a.Name != "chart.png" || a.MIMEType != "image/png" || string(a.Data) != "\x89PNG" || a.Tool != "make_chart" {
+		t.Errorf("chart = %+v", a)
+	}
+	if a := saved[1]; a.Name != "notes.txt" || string(a.Data) != "some notes" {
+		t.Errorf("notes = %+v", a)
+	}
+	if !strings.Contains(result, "Saved artifact chart.png (4 bytes)") || !strings.Contains(result, "Saved artifact notes.txt") {
+		t.Errorf("result = %q", result)
+	}
+}
@@ -0,0 +1,281 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// TokenSource supplies bearer tokens for remote tool servers.
+type TokenSource interface {
+	Token(ctx context.Context) (string, error)
+}
+
+// DevicePrompt shows the user where to approve an OAuth device login.
+type DevicePrompt func(verificationURI, userCode string)
+
+// DefaultTokenCacheDir returns ~/.forge/tokens.
+func DefaultTokenCacheDir() string {
+	home, _ := os.UserHomeDir()
+	return filepath.Join(home, ".forge", "tokens")
+}
+
+// NewTokenSource builds a TokenSource for a server's auth config. It returns
+// nil for AuthNone. cacheDir is where OAuth tokens are persisted.
+func NewTokenSource(server string, cfg AuthConfig, cacheDir string, prompt DevicePrompt) (TokenSource, error) {
+	switch cfg.Type {
+	case AuthNone:
+		return nil, nil
+	case AuthBearer:
+		token := expandEnvRef(cfg.Token)
+		if cfg.TokenEnv != "" {
+			token = os.Getenv(cfg.TokenEnv)
+		}
+		if token == "" {
+			return nil, fmt.Errorf("tool server %s: bearer auth has no token (set auth.token or auth.token_env)", server)
+		}
+		return staticToken(token), nil
+	case AuthOAuthDevice:
+		if cfg.ClientID == "" || cfg.DeviceAuthURL == "" || cfg.TokenURL == "" {
+			return nil, fmt.Errorf("tool server %s: oauth_device auth needs client_id, device_auth_url, and token_url", server)
+		}
+		if prompt == nil {
+			prompt = func(uri, code string) {
+				fmt.Fprintf(os.Stderr, "To authorize tool server %s, visit %s and enter code %s\n", server, uri, code)
+			}
+		}
+		return &deviceFlowSource{
+			cfg:       cfg,
+			cachePath: filepath.Join(cacheDir, server+".json"),
+			prompt:    prompt,
+		}, nil
+	default:
+		return nil, fmt.Errorf("tool server %s: unknown auth type %q", server, cfg.Type)
+	}
+}
+
+type staticToken string
+
+func (t staticToken) Token(context.Context) (string, error) { return string(t), nil }
+
+// oauthToken is the cached form of an OAuth token response.
+type oauthToken struct {
+	AccessToken  string    `json:"access_token"`
+	RefreshToken string    `json:"refresh_token,omitempty"`
+	ExpiresAt    time.Time `json:"expires_at,omitempty"`
+}
+
+// valid reports whether the token can be used without refreshing.
+func (t *oauthToken) valid() bool {
+	if t == nil || t.AccessToken == "" {
+		return false
+	}
+	return t.ExpiresAt.IsZero() || time.Now().Add(30*time.Second).Before(t.ExpiresAt)
+}
+
+// deviceFlowSource implements the OAuth 2.0 device authorization grant
+// (RFC 8628) with refresh and an on-disk token cache.
+type deviceFlowSource struct {
+	cfg       AuthConfig
+	cachePath string
+	prompt    DevicePrompt
+
+	mu  sync.Mutex
+	tok *oauthToken
+}
+
+var oauthHTTPClient = &http.Client{Timeout: 30 * time.Second}
+
+func (d *deviceFlowSource) Token(ctx context.Context) (string, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.tok == nil {
+		d.tok = d.loadCache()
+	}
+	if d.tok.valid() {
+		return d.tok.AccessToken, nil
+	}
+
+	var tok *oauthToken
+	var err error
+	if d.tok != nil && d.tok.RefreshToken != "" {
+		tok, err = d.refresh(ctx, d.tok.RefreshToken)
+	}
+	if tok == nil {
+		tok, err = d.deviceLogin(ctx)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	d.tok = tok
+	if err := d.saveCache(tok); err != nil {
+		return "", err
+	}
+	return tok.AccessToken, nil
+}
+
+func (d *deviceFlowSource) loadCache() *oauthToken {
+	data, err := os.ReadFile(d.cachePath)
+	if err != nil {
+		return nil
+	}
+	var tok oauthToken
+	if err := json.Unmarshal(data, &tok); err != nil {
+		return nil
+	}
+	return &tok
+}
+
+func (d *deviceFlowSource) saveCache(tok *oauthToken) error {
+	if err := os.MkdirAll(filepath.Dir(d.cachePath), 0o700); err != nil {
+		return fmt.Errorf("creating token cache dir: %w", err)
+	}
+	data, err := json.Marshal(tok)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(d.cachePath, data, 0o600); err != nil {
+		return fmt.Errorf("writing token cache: %w", err)
+	}
+	return nil
+}
+
+func (d *deviceFlowSource) refresh(ctx context.Context, refreshToken string) (*oauthToken, error) {
+	resp, err := postForm(ctx, d.cfg.TokenURL, url.Values{
+		"grant_type":    {"refresh_token"},
+		"refresh_token": {refreshToken},
+		"client_id":     {d.cfg.ClientID},
+	})
+	if err != nil || resp.Error != "" {
+		return nil, nil // fall back to a fresh device login
+	}
+	return resp.token(refreshToken), nil
+}
+
+func (d *deviceFlowSource) deviceLogin(ctx context.Context) (*oauthToken, error) {
+	form := url.Values{"client_id": {d.cfg.ClientID}}
+	if len(d.cfg.Scopes) > 0 {
+		form.Set("scope", strings.Join(d.cfg.Scopes, " "))
+	}
+	auth, err := postForm(ctx, d.cfg.DeviceAuthURL, form)
+	if err != nil {
+		return nil, fmt.Errorf("starting device login: %w", err)
+	}
+	if auth.Error != "" {
+		return nil, fmt.Errorf("starting device login: %s", auth.Error)
+	}
+	if auth.DeviceCode == "" {
+		return nil, fmt.Errorf("starting device login: no device_code in response")
+	}
+
+	uri := auth.VerificationURIComplete
+	if uri == "" {
+		uri = auth.VerificationURI
+	}
+	d.prompt(uri, auth.UserCode)
+
+	interval := time.Duration(auth.Interval) * time.Second
+	if interval <= 0 {
+		interval = 5 * time.Second
+	}
+	deadline := time.Now().Add(time.Duration(auth.ExpiresIn) * time.Second)
+	if auth.ExpiresIn <= 0 {
+		deadline = time.Now().Add(15 * time.Minute)
+	}
+
+	for time.Now().Before(deadline) {
+		select {
+		case <-time.After(interval):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+
+		resp, err := postForm(ctx, d.cfg.TokenURL, url.Values{
+			"grant_type":  {"urn:ietf:params:oauth:grant-type:device_code"},
+			"device_code": {auth.DeviceCode},
+			"client_id":   {d.cfg.ClientID},
+		})
+		if err != nil {
+			return nil, fmt.Errorf("polling for token: %w", err)
+		}
+		switch resp.Error {
+		case "":
+			return resp.token(""), nil
+		case "authorization_pending":
+		case "slow_down":
+			interval += 5 * time.Second
+		default:
+			return nil, fmt.Errorf("device login failed: %s", resp.Error)
+		}
+	}
+	return nil, fmt.Errorf("device login expired before it was approved")
+}
+
+// oauthResponse covers both device authorization and token endpoint replies.
+type oauthResponse struct {
+	// Device authorization
+	DeviceCode              string `json:"device_code"`
+	UserCode                string `json:"user_code"`
+	VerificationURI         string `json:"verification_uri"`
+	VerificationURIComplete string `json:"verification_uri_complete"`
+	Interval                int    `json:"interval"`
+
+	// Token
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int    `json:"expires_in"`
+
+	Error string `json:"error"`
+}
+
+// token converts a token response, keeping the previous refresh token if the
+// server didn't rotate it.
+func (r *oauthResponse) token(prevRefresh string) *oauthToken {
+	tok := &oauthToken{AccessToken: r.AccessToken, RefreshToken: r.RefreshToken}
+	if tok.RefreshToken == "" {
+		tok.RefreshToken = prevRefresh
+	}
+	if r.ExpiresIn > 0 {
+		tok.ExpiresAt = time.Now().Add(time.Duration(r.ExpiresIn) * time.Second)
+	}
+	return tok
+}
+
+func postForm(ctx context.Context, endpoint string, form url.Values) (*oauthResponse, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := oauthHTTPClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, err
+	}
+
+	var out oauthResponse
+	if err := json.Unmarshal(body, &out); err != nil {
+		return nil, fmt.Errorf("%s returned %d: %s", endpoint, resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	if out.Error == "" && resp.StatusCode >= 400 {
+		out.Error = fmt.Sprintf("HTTP %d", resp.StatusCode)
+	}
+	return &out, nil
+}
@@ -0,0 +1,231 @@
+package tools_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+
+	"github.com/michaelbrown/forge/internal/tools"
+)
+
+// newRemoteServer starts a streamable-HTTP MCP server with one echo tool that
+// only accepts requests carrying the given bearer token.
+func newRemoteServer(t *testing.T, token string) *httptest.Server {
+	t.Helper()
+	s := server.NewMCPServer("remote-test", "0.1.0")
+	s.AddTool(mcp.NewTool("remote_echo", mcp.WithString("text", mcp.Required())),
+		func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+			return mcp.NewToolResultText(req.GetString("text", "")), nil
+		})
+	h := server.NewStreamableHTTPServer(s)
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer "+token {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		h.ServeHTTP(w, r)
+	}))
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+func TestRegistry_RemoteBearerAuth(t *testing.T) {
+	ts := newRemoteServer(t, "s3cret")
+	t.Setenv("FORGE_TEST_TOKEN", "s3cret")
+
+	r := tools.NewRegistry()
+	defer r.Close()
+
+	err := r.Register("remote", tools.ToolServerConfig{
+		URL:     ts.URL,
+		Enabled: true,
+		Auth:    tools.AuthConfig{Type: tools.AuthBearer, TokenEnv: "FORGE_TEST_TOKEN"},
+	})
+	if err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+
+	result, err := r.CallTool(context.Background(), "remote_echo", map[string]any{"text": "over http"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result != "over http" {
+		t.Errorf("remote_echo = %q", result)
+	}
+
+	err = r.Register("remote-bad", tools.ToolServerConfig{
+		URL:     ts.URL,
+		Enabled: true,
+		Auth:    tools.AuthConfig{Type: tools.AuthBearer, Token: "wrong"},
+	})
+	if err == nil {
+		t.Error("expected error for wrong token")
+	}
+
+	err = r.Register("remote-empty", tools.ToolServerConfig{
+		URL:     ts.URL,
+		Enabled: true,
+		Auth:    tools.AuthConfig{Type: tools.AuthBearer, TokenEnv: "FORGE_TEST_UNSET"},
+	})
+	if err == nil || !strings.Contains(err.Error(), "no token") {
+		t.Errorf("expected missing token error, got %v", err)
+	}
+}
+
+// TestRegistry_RemoteProgress checks that progress a server reports during
+// a call reaches the caller's WithProgress function.
+func TestRegistry_RemoteProgress(t *testing.T) {
+	s := server.NewMCPServer("progress-test", "0.1.0")
+	s.AddTool(mcp.NewTool("count"),
+		func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+			if req.Params.Meta == nil || req.Params.Meta.ProgressToken == nil {
+				return mcp.NewToolResultError("no progress token"), nil
+			}
+			for i := 1; i <= 3; i++ {
+				server.ServerFromContext(ctx).SendNotificationToClient(ctx, "notifications/progress", map[string]any{
+					"progressToken": req.Params.Meta.ProgressToken,
+					"progress":      i,
+					"message":       fmt.Sprintf("%d\n", i),
+				})
+			}
+			// Give the notifications time to go out ahead of the result.
+			time.Sleep(100 * time.Millisecond)
+			return mcp.NewToolResultText("done"), nil
+		})
+	ts := httptest.NewServer(server.NewStreamableHTTPServer(s))
+	defer ts.Close()
+
+	r := tools.NewRegistry()
+	defer r.Close()
+	if err := r.Register("progress", tools.ToolServerConfig{URL: ts.URL, Enabled: true}); err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+
+	var mu sync.Mutex
+	var got strings.Builder
+	ctx := tools.WithProgress(context.Background(), func(message string) {
+		mu.Lock()
+		defer mu.Unlock()
+		got.WriteString(message)
+	})
+	result, err := r.CallTool(ctx, "count", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result != "done" {
+		t.Fatalf("count = %q", result)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if got.String() != "1\n2\n3\n" {
+		t.Errorf("progress = %q", got.String())
+	}
+}
+
+func TestDeviceFlowTokenSource(t *testing.T) {
+	var polls atomic.Int32
+	oauth := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/device":
+			if r.Form.Get("client_id") != "forge-cli" || r.Form.Get("scope") != "tools:read tools:call" {
+				http.Error(w, `{"error":"invalid_request"}`, http.StatusBadRequest)
+				return
+			}
+			fmt.Fprint(w, `{"device_code":"dev-1","user_code":"ABCD-1234","verification_uri":"https://example.com/activate","interval":1,"expires_in":60}`)
+		case "/token":
+			if r.Form.Get("device_code") != "dev-1" {
+				http.Error(w, `{"error":"invalid_grant"}`, http.StatusBadRequest)
+				return
+			}
+			if polls.Add(1) == 1 {
+				w.WriteHeader(http.StatusBadRequest)
+				fmt.Fprint(w, `{"error":"authorization_pending"}`)
+				return
+			}
+			fmt.Fprint(w, `{"access_token":"device-token","refresh_token":"refresh-1","expires_in":3600}`)
+		}
+	}))
+	defer oauth.Close()
+
+	dir := t.TempDir()
+	cfg := tools.AuthConfig{
+		Type:          tools.AuthOAuthDevice,
+		ClientID:      "forge-cli",
+		DeviceAuthURL: oauth.URL + "/device",
+		TokenURL:      oauth.URL + "/token",
+		Scopes:        []string{"tools:read", "tools:call"},
+	}
+
+	var prompted string
+	src, err := tools.NewTokenSource("remote", cfg, dir, func(uri, code string) {
+		prompted = uri + " " + code
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	token, err := src.Token(context.Background())
+	if err != nil {
+		t.Fatalf("Token: %v", err)
+	}
+	if token != "device-token" {
+		t.Errorf("token = %q", token)
+	}
+	if prompted != "https://example.com/activate ABCD-1234" {
+		t.Errorf("prompt = %q", prompted)
+	}
+	if polls.Load() != 2 {
+		t.Errorf("polls = %d, want 2", polls.Load())
+	}
+
+	cachePath := filepath.Join(dir, "remote.json")
+	info, err := os.Stat(cachePath)
+	if err != nil {
+		t.Fatalf("token cache not written: %v", err)
+	}
+	if info.Mode().Perm() != 0o600 {
+		t.Errorf("cache mode = %v, want 0600", info.Mode().Perm())
+	}
+	var cached map[string]any
+	data, _ := os.ReadFile(cachePath)
+	json.Unmarshal(data, &cached)
+	if cached["access_token"] != "device-token" || cached["refresh_token"] != "refresh-1" {
+		t.Errorf("cache contents = %s", data)
+	}
+
+	// A new source reads the cache instead of starting another login.
+	src2, _ := tools.NewTokenSource("remote", cfg, dir, func(string, string) {
+		t.Error("cached token should not prompt")
+	})
+	token, err = src2.Token(context.Background())
+	if err != nil || token != "device-token" {
+		t.Errorf("cached Token = %q, %v", token, err)
+	}
+}
+
+func TestNewTokenSource_Validation(t *testing.T) {
+	if src, err := tools.NewTokenSource("s", tools.AuthConfig{}, "", nil); src != nil || err != nil {
+		t.Errorf("AuthNone = %v, %v; want nil, nil", src, err)
+	}
+	if _, err := tools.NewTokenSource("s", tools.AuthConfig{Type: tools.AuthOAuthDevice}, "", nil); err == nil {
+		t.Error("expected error for incomplete oauth_device config")
+	}
+	if _, err := tools.NewTokenSource("s", tools.AuthConfig{Type: "basic"}, "", nil); err == nil {
+		t.Error("expected error for unknown auth type")
+	}
+}
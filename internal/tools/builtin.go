@@ -0,0 +1,510 @@
+package tools
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/michaelbrown/forge/internal/llm"
+)
+
+// maxBuiltinOutput caps tool output to keep the context window manageable.
+const maxBuiltinOutput = 4000
+
+// builtinTool pairs a tool definition with its in-process implementation.
+type builtinTool struct {
+	def llm.ToolDef
+	run func(ctx context.Context, args map[string]any) (string, error)
+}
+
+// BuiltinServer provides a basic tool set implemented in-process, so the
+// agent is useful before any MCP tool servers are built or configured.
+type BuiltinServer struct {
+	tools []builtinTool
+	index map[string]int
+}
+
+var builtinHTTPClient = &http.Client{Timeout: 30 * time.Second}
+
+// NewBuiltinServer returns the built-in tool pack: shell_exec, file_read,
+// file_write, file_patch, file_list, grep, glob, and http_fetch.
+func NewBuiltinServer() *BuiltinServer {
+	b := &BuiltinServer{
+		tools: []builtinTool{
+			{def: toolDef("shell_exec",
+				"Execute a shell command and return the combined stdout and stderr output. Use this to run system commands, check files, install packages, etc.",
+				map[string]any{
+					"command": stringParam("The shell command to execute"),
+					"workdir": stringParam("Working directory for the command (optional)"),
+				}, "command"), run: builtinShellExec},
+			{def: toolDef("file_read",
+				"Read the contents of a file. Optionally specify a line range; long files are cut off, so read them a range at a time.",
+				map[string]any{
+					"path":       stringParam("Path to the file to read"),
+					"start_line": intParam("First line to read (1-based, optional)"),
+					"end_line":   intParam("Last line to read (1-based, inclusive, optional)"),
+				}, "path"), run: builtinFileRead},
+			{def: toolDef("file_write",
+				"Write content to a file, creating it if it doesn't exist. Overwrites existing content.",
+				map[string]any{
+					"path":    stringParam("Path to the file to write"),
+					"content": stringParam("Content to write to the file"),
+				}, "path", "content"), run: builtinFileWrite},
+			{def: toolDef("file_patch",
+				"Replace the first occurrence of a search string with a replacement string in a file.",
+				map[string]any{
+					"path":    stringParam("Path to the file to patch"),
+					"search":  stringParam("The exact text to search for"),
+					"replace": stringParam("The text to replace it with"),
+				}, "path", "search", "replace"), run: builtinFilePatch},
+			{def: toolDef("file_list",
+				"List files in a directory.",
+				map[string]any{
+					"path": stringParam("Directory path to list"),
+				}, "path"), run: builtinFileList},
+			{def: toolDef("grep",
+				"Search file contents recursively with a regular expression. Returns matching lines as path:line: text.",
+				map[string]any{
+					"pattern":     stringParam("Regular expression to search for"),
+					"path":        stringParam("File or directory to search (default: current directory)"),
+					"include":     stringParam("Only search files whose name matches this glob (e.g. '*.go', optional)"),
+					"max_results": intParam("Maximum number of matching lines (default: 100)"),
+				}, "pattern"), run: builtinGrep},
+			{def: toolDef("glob",
+				"Find files by glob pattern. Supports ** to match any number of directories (e.g. 'src/**/*.ts').",
+				map[string]any{
+					"pattern": stringParam("Glob pattern relative to path"),
+					"path":    stringParam("Base directory (default: current directory)"),
+				}, "pattern"), run: builtinGlob},
+			{def: toolDef("http_fetch",
+				"Fetch the text content of a URL via HTTP GET.",
+				map[string]any{
+					"url": stringParam("The URL to fetch"),
+				}, "url"), run: builtinHTTPFetch},
+		},
+		index: make(map[string]int),
+	}
+	for i, t := range b.tools {
+		b.index[t.def.Name] = i
+	}
+	return b
+}
+
+// ToolDefs returns definitions for all built-in tools.
+func (b *BuiltinServer) ToolDefs() []llm.ToolDef {
+	defs := make([]llm.ToolDef, len(b.tools))
+	for i, t := range b.tools {
+		defs[i] = t.def
+	}
+	return defs
+}
+
+// ToolNames returns the names of all built-in tools.
+func (b *BuiltinServer) ToolNames() []string {
+	names := make([]string, len(b.tools))
+	for i, t := range b.tools {
+		names[i] = t.def.Name
+	}
+	return names
+}
+
+// CallTool runs a built-in tool. Tool failures are returned as "error: ..."
+// text so the agent can see and react to them, matching MCP servers.
+func (b *BuiltinServer) CallTool(ctx context.Context, name string, args map[string]any) (string, error) {
+	i, ok := b.index[name]
+	if !ok {
+		return "", fmt.Errorf("unknown tool: %s", name)
+	}
+	if args == nil {
+		args = map[string]any{}
+	}
+	result, err := b.tools[i].run(ctx, args)
+	if err != nil {
+		return "error: " + err.Error(), nil
+	}
+	return result, nil
+}
+
+// Close is a no-op; built-in tools hold no resources.
+func (b *BuiltinServer) Close() {}
+
+// --- Schema helpers ---
+
+func toolDef(name, description string, props map[string]any, required ...string) llm.ToolDef {
+	params := map[string]any{
+		"type":       "object",
+		"properties": props,
+	}
+	if len(required) > 0 {
+		params["required"] = required
+	}
+	return llm.ToolDef{Name: name, Description: description, Parameters: params}
+}
+
+func stringParam(description string) map[string]any {
+	return map[string]any{"type": "string", "description": description}
+}
+
+func intParam(description string) map[string]any {
+	return map[string]any{"type": "integer", "description": description}
+}
+
+// argInt reads an integer argument; JSON numbers arrive as float64.
+func argInt(v any) (int, bool) {
+	switch n := v.(type) {
+	case float64:
+		return int(n), true
+	case int:
+		return n, true
+	case string:
+		i, err := strconv.Atoi(n)
+		return i, err == nil
+	}
+	return 0, false
+}
+
+func truncateOutput(s string) string {
+	if len(s) > maxBuiltinOutput {
+		return s[:maxBuiltinOutput] + "\n... (output truncated)"
+	}
+	return s
+}
+
+// --- Tool implementations ---
+
+func builtinShellExec(ctx context.Context, args map[string]any) (string, error) {
+	command, ok := args["command"].(string)
+	if !ok {
+		return "", fmt.Errorf("'command' argument must be a string")
+	}
+
+	cmd := exec.CommandContext(ctx, "sh", "-c", command)
+	if workdir, ok := args["workdir"].(string); ok && workdir != "" {
+		cmd.Dir = workdir
+	}
+
+	// Same layout as the shell-exec server: exit code, then labeled streams.
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	err := cmd.Run()
+
+	var b strings.Builder
+	var exitErr *exec.ExitError
+	switch {
+	case errors.As(err, &exitErr):
+		fmt.Fprintf(&b, "exit_code: %d\n", exitErr.ExitCode())
+	case err != nil:
+		fmt.Fprintf(&b, "exit_code: -1 (%s)\n", err)
+	default:
+		b.WriteString("exit_code: 0\n")
+	}
+	if stdout.Len() > 0 {
+		b.WriteString("[stdout]\n" + strings.TrimSuffix(truncateOutput(stdout.String()), "\n") + "\n")
+	}
+	if stderr.Len() > 0 {
+		b.WriteString("[stderr]\n" + strings.TrimSuffix(truncateOutput(stderr.String()), "\n") + "\n")
+	}
+	return strings.TrimSuffix(b.String(), "\n"), nil
+}
+
+func builtinFileRead(_ context.Context, args map[string]any) (string, error) {
+	path, _ := args["path"].(string)
+	if path == "" {
+		return "", fmt.Errorf("'path' is required")
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("reading file: %w", err)
+	}
+	if bytes.IndexByte(data[:min(len(data), 8000)], 0) >= 0 {
+		return "", fmt.Errorf("%s looks like a binary file", path)
+	}
+	content := string(data)
+
+	startLine, hasStart := argInt(args["start_line"])
+	endLine, hasEnd := argInt(args["end_line"])
+	if hasStart || hasEnd {
+		lines := strings.Split(content, "\n")
+		if !hasStart || startLine < 1 {
+			startLine = 1
+		}
+		if !hasEnd || endLine > len(lines) {
+			endLine = len(lines)
+		}
+		if startLine > endLine {
+			return "", fmt.Errorf("start_line > end_line")
+		}
+		content = strings.Join(lines[startLine-1:endLine], "\n")
+	}
+	return truncateLines(content), nil
+}
+
+// truncateLines cuts file content to maxBuiltinOutput bytes at a line
+// break, telling the model how to read the rest.
+func truncateLines(content string) string {
+	if len(content) <= maxBuiltinOutput {
+		return content
+	}
+	shown := content[:maxBuiltinOutput]
+	if i := strings.LastIndexByte(shown, '\n'); i > 0 {
+		shown = shown[:i]
+	}
+	return fmt.Sprintf("%s\n... (truncated after %d of %d lines; use start_line and end_line to read the rest)",
+		shown, strings.Count(shown, "\n")+1, strings.Count(content, "\n")+1)
+}
+
+func builtinFileWrite(_ context.Context, args map[string]any) (string, error) {
+	path, _ := args["path"].(string)
+	content, _ := args["content"].(string)
+	if path == "" {
+		return "", fmt.Errorf("'path' is required")
+	}
+
+	if dir := filepath.Dir(path); dir != "." {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return "", fmt.Errorf("creating directories: %w", err)
+		}
+	}
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		return "", fmt.Errorf("writing file: %w", err)
+	}
+	return fmt.Sprintf("wrote %d bytes to %s", len(content), path), nil
+}
+
+func builtinFilePatch(_ context.Context, args map[string]any) (string, error) {
+	path, _ := args["path"].(string)
+	search, _ := args["search"].(string)
+	replace, _ := args["replace"].(string)
+	if path == "" || search == "" {
+		return "", fmt.Errorf("'path' and 'search' are required")
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("reading file: %w", err)
+	}
+	content := string(data)
+	if !strings.Contains(content, search) {
+		return "", fmt.Errorf("search string not found in file")
+	}
+
+	newContent := strings.Replace(content, search, replace, 1)
+	if err := os.WriteFile(path, []byte(newContent), 0o644); err != nil {
+		return "", fmt.Errorf("writing file: %w", err)
+	}
+	return fmt.Sprintf("patched %s", path), nil
+}
+
+func builtinFileList(_ context.Context, args map[string]any) (string, error) {
+	path, _ := args["path"].(string)
+	if path == "" {
+		path = "."
+	}
+
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		return "", fmt.Errorf("listing directory: %w", err)
+	}
+
+	var lines []string
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() {
+			name += "/"
+		}
+		lines = append(lines, name)
+	}
+	return truncateOutput(strings.Join(lines, "\n")), nil
+}
+
+// skipDir reports whether a directory should be skipped during recursive walks.
+func skipDir(name string) bool {
+	return name == "node_modules" || (strings.HasPrefix(name, ".") && name != "." && name != "..")
+}
+
+func builtinGrep(ctx context.Context, args map[string]any) (string, error) {
+	pattern, _ := args["pattern"].(string)
+	if pattern == "" {
+		return "", fmt.Errorf("'pattern' is required")
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return "", fmt.Errorf("invalid pattern: %w", err)
+	}
+
+	root, _ := args["path"].(string)
+	if root == "" {
+		root = "."
+	}
+	include, _ := args["include"].(string)
+	maxResults, ok := argInt(args["max_results"])
+	if !ok || maxResults <= 0 {
+		maxResults = 100
+	}
+
+	var matches []string
+	errLimit := fmt.Errorf("limit reached")
+	walkErr := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil // unreadable entries are skipped
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if d.IsDir() {
+			if path != root && skipDir(d.Name()) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if include != "" {
+			if ok, _ := filepath.Match(include, d.Name()); !ok {
+				return nil
+			}
+		}
+
+		f, err := os.Open(path)
+		if err != nil {
+			return nil
+		}
+		defer f.Close()
+
+		scanner := bufio.NewScanner(f)
+		for lineNo := 1; scanner.Scan(); lineNo++ {
+			line := scanner.Text()
+			if strings.IndexByte(line, 0) >= 0 {
+				return nil // binary file
+			}
+			if re.MatchString(line) {
+				matches = append(matches, fmt.Sprintf("%s:%d: %s", path, lineNo, line))
+				if len(matches) >= maxResults {
+					return errLimit
+				}
+			}
+		}
+		return nil
+	})
+	if walkErr != nil && walkErr != errLimit {
+		return "", walkErr
+	}
+
+	if len(matches) == 0 {
+		return "No matches found.", nil
+	}
+	out := strings.Join(matches, "\n")
+	if len(matches) >= maxResults {
+		out += fmt.Sprintf("\n... (stopped after %d matches)", maxResults)
+	}
+	return truncateOutput(out), nil
+}
+
+func builtinGlob(ctx context.Context, args map[string]any) (string, error) {
+	pattern, _ := args["pattern"].(string)
+	if pattern == "" {
+		return "", fmt.Errorf("'pattern' is required")
+	}
+	root, _ := args["path"].(string)
+	if root == "" {
+		root = "."
+	}
+
+	var matches []string
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if d.IsDir() && path != root && skipDir(d.Name()) {
+			return filepath.SkipDir
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil || rel == "." {
+			return nil
+		}
+		if matchGlob(pattern, filepath.ToSlash(rel)) {
+			matches = append(matches, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+
+	if len(matches) == 0 {
+		return "No files matched.", nil
+	}
+	sort.Strings(matches)
+	return truncateOutput(strings.Join(matches, "\n")), nil
+}
+
+// matchGlob matches a slash-separated path against a glob pattern where a
+// "**" segment matches zero or more directories.
+func matchGlob(pattern, path string) bool {
+	return matchSegments(strings.Split(pattern, "/"), strings.Split(path, "/"))
+}
+
+func matchSegments(pattern, path []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := 0; i <= len(path); i++ {
+				if matchSegments(pattern[1:], path[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(path) == 0 {
+			return false
+		}
+		if ok, _ := filepath.Match(pattern[0], path[0]); !ok {
+			return false
+		}
+		pattern, path = pattern[1:], path[1:]
+	}
+	return len(path) == 0
+}
+
+func builtinHTTPFetch(ctx context.Context, args map[string]any) (string, error) {
+	url, _ := args["url"].(string)
+	if url == "" {
+		return "", fmt.Errorf("'url' is required")
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("User-Agent", "Forge/0.1")
+
+	resp, err := builtinHTTPClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 50_000))
+	if err != nil {
+		return "", fmt.Errorf("reading body: %w", err)
+	}
+	if resp.StatusCode >= 400 {
+		return "", fmt.Errorf("HTTP %d: %s", resp.StatusCode, truncateOutput(string(body)))
+	}
+	return truncateOutput(string(body)), nil
+}
@@ -0,0 +1,214 @@
+package tools_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/michaelbrown/forge/internal/tools"
+)
+
+func TestBuiltinServer_ToolNames(t *testing.T) {
+	b := tools.NewBuiltinServer()
+
+	want := []string{"shell_exec", "file_read", "file_write", "file_patch", "file_list", "grep", "glob", "http_fetch"}
+	got := strings.Join(b.ToolNames(), ",")
+	if got != strings.Join(want, ",") {
+		t.Errorf("ToolNames() = %s, want %s", got, strings.Join(want, ","))
+	}
+
+	for _, td := range b.ToolDefs() {
+		if td.Description == "" {
+			t.Errorf("%s has no description", td.Name)
+		}
+		if td.Parameters["type"] != "object" {
+			t.Errorf("%s parameters should be an object schema", td.Name)
+		}
+	}
+
+	if _, err := b.CallTool(context.Background(), "nonexistent", nil); err == nil {
+		t.Error("unknown tool should return error")
+	}
+}
+
+func TestBuiltinServer_FileOps(t *testing.T) {
+	b := tools.NewBuiltinServer()
+	ctx := context.Background()
+	dir := t.TempDir()
+	path := filepath.Join(dir, "sub", "test.txt")
+
+	result, _ := b.CallTool(ctx, "file_write", map[string]any{"path": path, "content": "line1\nline2\nline3\n"})
+	if !strings.Contains(result, "wrote") {
+		t.Fatalf("file_write result: %q", result)
+	}
+
+	result, _ = b.CallTool(ctx, "file_read", map[string]any{
+		"path":       path,
+		"start_line": float64(2),
+		"end_line":   float64(2),
+	})
+	if result != "line2" {
+		t.Errorf("file_read range = %q, want line2", result)
+	}
+
+	result, _ = b.CallTool(ctx, "file_patch", map[string]any{"path": path, "search": "line2", "replace": "REPLACED"})
+	if !strings.Contains(result, "patched") {
+		t.Errorf("file_patch result: %q", result)
+	}
+	data, _ := os.ReadFile(path)
+	if !strings.Contains(string(data), "REPLACED") {
+		t.Errorf("file not patched: %q", data)
+	}
+
+	result, _ = b.CallTool(ctx, "file_patch", map[string]any{"path": path, "search": "missing", "replace": "x"})
+	if !strings.HasPrefix(result, "error: ") {
+		t.Errorf("expected error for missing search string, got %q", result)
+	}
+
+	result, _ = b.CallTool(ctx, "file_list", map[string]any{"path": dir})
+	if result != "sub/" {
+		t.Errorf("file_list = %q, want sub/", result)
+	}
+}
+
+func TestBuiltinServer_OutputLimits(t *testing.T) {
+	b := tools.NewBuiltinServer()
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	var big strings.Builder
+	for i := 1; i <= 2000; i++ {
+		fmt.Fprintf(&big, "line %d\n", i)
+	}
+	path := filepath.Join(dir, "big.txt")
+	if err := os.WriteFile(path, []byte(big.String()), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	result, _ := b.CallTool(ctx, "file_read", map[string]any{"path": path})
+	if len(result) > 4200 || !strings.Contains(result, "use start_line and end_line") || !strings.Contains(result, "of 2001 lines") {
+		t.Errorf("file_read of a large file returned %d bytes, ending %q", len(result), result[max(0, len(result)-120):])
+	}
+	if strings.Contains(result, "line 2000") {
+		t.Error("file_read should cut off a large file")
+	}
+	result, _ = b.CallTool(ctx, "file_read", map[string]any{"path": path, "start_line": float64(1999), "end_line": float64(2000)})
+	if result != "line 1999\nline 2000" {
+		t.Errorf("file_read range of a large file = %q", result)
+	}
+
+	bin := filepath.Join(dir, "blob.bin")
+	if err := os.WriteFile(bin, []byte{0x7f, 'E', 'L', 'F', 0, 0, 1}, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if result, _ := b.CallTool(ctx, "file_read", map[string]any{"path": bin}); !strings.Contains(result, "binary file") {
+		t.Errorf("file_read of a binary file = %q", result)
+	}
+
+	many := filepath.Join(dir, "many")
+	for i := 0; i < 500; i++ {
+		if err := os.MkdirAll(filepath.Join(many, fmt.Sprintf("directory-%03d", i)), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	result, _ = b.CallTool(ctx, "file_list", map[string]any{"path": many})
+	if len(result) > 4100 || !strings.HasSuffix(result, "(output truncated)") {
+		t.Errorf("file_list of a large directory returned %d bytes", len(result))
+	}
+}
+
+func TestBuiltinServer_GrepAndGlob(t *testing.T) {
+	b := tools.NewBuiltinServer()
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	files := map[string]string{
+		"main.go":             "package main\n\nfunc main() {}\n",
+		"pkg/util/util.go":    "package util\n\nfunc Helper() {}\n",
+		"pkg/util/README.md":  "func in markdown\n",
+		".git/config":         "func hidden\n",
+		"node_modules/x/a.go": "func vendored() {}\n",
+	}
+	for name, content := range files {
+		p := filepath.Join(dir, name)
+		os.MkdirAll(filepath.Dir(p), 0o755)
+		os.WriteFile(p, []byte(content), 0o644)
+	}
+
+	result, _ := b.CallTool(ctx, "grep", map[string]any{"pattern": `^func \w+\(`, "path": dir, "include": "*.go"})
+	if !strings.Contains(result, "main.go:3: func main() {}") {
+		t.Errorf("grep missing main.go match: %q", result)
+	}
+	if !strings.Contains(result, "util.go:3: func Helper() {}") {
+		t.Errorf("grep missing util.go match: %q", result)
+	}
+	if strings.Contains(result, "README") || strings.Contains(result, ".git") || strings.Contains(result, "node_modules") {
+		t.Errorf("grep should skip excluded files: %q", result)
+	}
+
+	result, _ = b.CallTool(ctx, "grep", map[string]any{"pattern": "func", "path": dir, "max_results": float64(1)})
+	if !strings.Contains(result, "stopped after 1 matches") {
+		t.Errorf("grep should report the result limit: %q", result)
+	}
+
+	result, _ = b.CallTool(ctx, "grep", map[string]any{"pattern": "(", "path": dir})
+	if !strings.HasPrefix(result, "error: ") {
+		t.Errorf("expected error for invalid regex, got %q", result)
+	}
+
+	result, _ = b.CallTool(ctx, "glob", map[string]any{"pattern": "**/*.go", "path": dir})
+	want := filepath.Join(dir, "main.go") + "\n" + filepath.Join(dir, "pkg/util/util.go")
+	if result != want {
+		t.Errorf("glob = %q, want %q", result, want)
+	}
+
+	result, _ = b.CallTool(ctx, "glob", map[string]any{"pattern": "pkg/*/*.md", "path": dir})
+	if result != filepath.Join(dir, "pkg/util/README.md") {
+		t.Errorf("glob single-level = %q", result)
+	}
+}
+
+func TestBuiltinServer_HTTPFetch(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, "hello from server")
+	}))
+	defer ts.Close()
+
+	b := tools.NewBuiltinServer()
+	ctx := context.Background()
+
+	result, _ := b.CallTool(ctx, "http_fetch", map[string]any{"url": ts.URL})
+	if result != "hello from server" {
+		t.Errorf("http_fetch = %q", result)
+	}
+
+	result, _ = b.CallTool(ctx, "http_fetch", map[string]any{"url": ts.URL + "/missing"})
+	if !strings.HasPrefix(result, "error: HTTP 404") {
+		t.Errorf("expected 404 error, got %q", result)
+	}
+}
+
+func TestBuiltinServer_ShellExec(t *testing.T) {
+	b := tools.NewBuiltinServer()
+
+	result, err := b.CallTool(context.Background(), "shell_exec", map[string]any{"command": "echo builtin"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result != "exit_code: 0\n[stdout]\nbuiltin" {
+		t.Errorf("shell_exec = %q", result)
+	}
+
+	result, _ = b.CallTool(context.Background(), "shell_exec", map[string]any{"command": "echo oops >&2; exit 2"})
+	if result != "exit_code: 2\n[stderr]\noops" {
+		t.Errorf("shell_exec failure = %q", result)
+	}
+}
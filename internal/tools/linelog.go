@@ -0,0 +1,52 @@
+package tools
+
+import (
+	"bufio"
+	"io"
+	"sync"
+)
+
+// maxLogLines is how many stderr lines are kept per tool server.
+const maxLogLines = 500
+
+// lineLog keeps the last lines read from a stream.
+type lineLog struct {
+	mu   sync.Mutex
+	max  int
+	kept []string
+}
+
+func newLineLog(max int) *lineLog {
+	return &lineLog{max: max}
+}
+
+// readFrom keeps r's lines until it ends.
+func (l *lineLog) readFrom(r io.Reader) {
+	if r == nil {
+		return
+	}
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		l.add(scanner.Text())
+	}
+	// Past a line too long to keep, discard the rest rather than leave
+	// the writer blocked.
+	io.Copy(io.Discard, r)
+}
+
+func (l *lineLog) add(line string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.kept = append(l.kept, line)
+	if len(l.kept) > l.max {
+		l.kept = append(l.kept[:0], l.kept[len(l.kept)-l.max:]...)
+	}
+}
+
+// lines returns the kept lines, oldest first.
+func (l *lineLog) lines() []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]string(nil), l.kept...)
+}
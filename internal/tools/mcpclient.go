@@ -2,32 +2,132 @@ package tools
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"os"
 	"strings"
+	"sync"
+	"sync/atomic"
 
 	"github.com/mark3labs/mcp-go/client"
+	"github.com/mark3labs/mcp-go/client/transport"
 	"github.com/mark3labs/mcp-go/mcp"
 	"github.com/michaelbrown/forge/internal/llm"
+	"github.com/michaelbrown/forge/internal/logging"
 )
 
-// MCPConnection wraps an mcp-go stdio client for a single tool server.
+// MCPConnection wraps an mcp-go client (stdio or streamable HTTP) for a single tool server.
 type MCPConnection struct {
 	name   string
 	client *client.Client
 	tools  []mcp.Tool
+	stderr *lineLog // what a subprocess server writes to stderr; nil for remote servers
+
+	progress     sync.Map // progress token → func(string), for calls in progress
+	nextProgress atomic.Int64
 }
 
-// NewMCPConnection launches an MCP server subprocess and initializes the connection.
-func NewMCPConnection(name, binary string, env []string) (*MCPConnection, error) {
-	c, err := client.NewStdioMCPClient(binary, env)
-	if err != nil {
+// NewMCPConnection launches an MCP server subprocess and initializes the
+// connection. If approve is non-nil, the server may ask it to approve actions
+// via MCP elicitation.
+func NewMCPConnection(name, binary string, env []string, approve Approver) (*MCPConnection, error) {
+	var opts []client.ClientOption
+	if approve != nil {
+		opts = append(opts, client.WithElicitationHandler(approvalHandler{server: name, approve: approve}))
+	}
+	stdio := transport.NewStdioWithOptions(binary, env, nil, transport.WithCommandLogger(stdioLogger{server: name}))
+	c := client.NewClient(stdio, opts...)
+	// The subprocess lives as long as this context, so it must not be canceled.
+	if err := c.Start(context.Background()); err != nil {
 		return nil, fmt.Errorf("starting MCP server %s (%s): %w", name, binary, err)
 	}
+	// Read stderr from the start, so a server that writes a lot of it
+	// doesn't block on a full pipe.
+	stderr := newLineLog(maxLogLines)
+	go stderr.readFrom(stdio.Stderr())
+
+	mc, err := initConnection(context.Background(), name, c)
+	if err != nil {
+		return nil, err
+	}
+	mc.stderr = stderr
+	return mc, nil
+}
+
+// stdioLogger sends what the stdio transport logs to the tools logger,
+// leaving out the read error it reports when a server is closed while its
+// output is being read, which is how every close goes.
+type stdioLogger struct {
+	server string
+}
+
+func (l stdioLogger) Infof(format string, v ...any) {
+	logging.For("tools").Info(fmt.Sprintf(format, v...), "server", l.server)
+}
+
+func (l stdioLogger) Errorf(format string, v ...any) {
+	for _, arg := range v {
+		if err, ok := arg.(error); ok && errors.Is(err, os.ErrClosed) {
+			return
+		}
+	}
+	logging.For("tools").Error(fmt.Sprintf(format, v...), "server", l.server)
+}
+
+// approvalHandler answers a server's elicitation requests by asking the
+// approver. Forge only uses elicitation for yes/no approval, so any fields
+// in the requested schema are left empty.
+type approvalHandler struct {
+	server  string
+	approve Approver
+}
+
+func (h approvalHandler) Elicit(ctx context.Context, request mcp.ElicitationRequest) (*mcp.ElicitationResult, error) {
+	ok, err := h.approve(ctx, h.server, request.Params.Message)
+	if err != nil {
+		return nil, err
+	}
+	action := mcp.ElicitationResponseActionDecline
+	if ok {
+		action = mcp.ElicitationResponseActionAccept
+	}
+	return &mcp.ElicitationResult{ElicitationResponse: mcp.ElicitationResponse{Action: action}}, nil
+}
+
+// NewRemoteMCPConnection connects to an MCP server over streamable HTTP.
+// If tokens is non-nil, each request carries an Authorization bearer header.
+func NewRemoteMCPConnection(ctx context.Context, name, url string, tokens TokenSource) (*MCPConnection, error) {
+	var opts []transport.StreamableHTTPCOption
+	if tokens != nil {
+		// Fetch once up front so misconfigured auth fails at connect time.
+		if _, err := tokens.Token(ctx); err != nil {
+			return nil, fmt.Errorf("authenticating to %s: %w", name, err)
+		}
+		opts = append(opts, transport.WithHTTPHeaderFunc(func(ctx context.Context) map[string]string {
+			token, err := tokens.Token(ctx)
+			if err != nil {
+				logging.For("tools").WarnContext(ctx, "fetching token", "server", name, "error", err)
+				return nil
+			}
+			return map[string]string{"Authorization": "Bearer " + token}
+		}))
+	}
 
-	ctx := context.Background()
+	c, err := client.NewStreamableHttpClient(url, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("connecting to MCP server %s (%s): %w", name, url, err)
+	}
+	if err := c.Start(ctx); err != nil {
+		c.Close()
+		return nil, fmt.Errorf("connecting to MCP server %s (%s): %w", name, url, err)
+	}
+	return initConnection(ctx, name, c)
+}
 
+// initConnection runs the MCP handshake and discovers the server's tools.
+func initConnection(ctx context.Context, name string, c *client.Client) (*MCPConnection, error) {
 	// Initialize the MCP protocol
-	_, err = c.Initialize(ctx, mcp.InitializeRequest{
+	_, err := c.Initialize(ctx, mcp.InitializeRequest{
 		Params: mcp.InitializeParams{
 			ClientInfo: mcp.Implementation{
 				Name:    "forge",
@@ -47,11 +147,26 @@ func NewMCPConnection(name, binary string, env []string) (*MCPConnection, error)
 		return nil, fmt.Errorf("listing tools from %s: %w", name, err)
 	}
 
-	return &MCPConnection{
+	mc := &MCPConnection{
 		name:   name,
 		client: c,
 		tools:  result.Tools,
-	}, nil
+	}
+	c.OnNotification(mc.handleNotification)
+	return mc, nil
+}
+
+// handleNotification passes progress a server reports on a call to the
+// call's WithProgress function.
+func (mc *MCPConnection) handleNotification(n mcp.JSONRPCNotification) {
+	if n.Method != "notifications/progress" {
+		return
+	}
+	fn, ok := mc.progress.Load(n.Params.AdditionalFields["progressToken"])
+	message, _ := n.Params.AdditionalFields["message"].(string)
+	if ok && message != "" {
+		fn.(func(string))(message)
+	}
 }
 
 // ToolDefs converts MCP tool schemas to llm.ToolDef for the LLM API.
@@ -78,21 +193,38 @@ func (mc *MCPConnection) ToolDefs() []llm.ToolDef {
 
 // CallTool invokes a tool on this MCP server and returns the text result.
 func (mc *MCPConnection) CallTool(ctx context.Context, name string, args map[string]any) (string, error) {
-	result, err := mc.client.CallTool(ctx, mcp.CallToolRequest{
-		Params: mcp.CallToolParams{
-			Name:      name,
-			Arguments: args,
-		},
-	})
+	params := mcp.CallToolParams{
+		Name:      name,
+		Arguments: args,
+	}
+	if session := SessionFromContext(ctx); session != "" {
+		params.Meta = &mcp.Meta{AdditionalFields: map[string]any{SessionMetaKey: session}}
+	}
+	if fn := progressFromContext(ctx); fn != nil {
+		token := fmt.Sprintf("%s-%d", mc.name, mc.nextProgress.Add(1))
+		mc.progress.Store(token, fn)
+		defer mc.progress.Delete(token)
+		if params.Meta == nil {
+			params.Meta = &mcp.Meta{}
+		}
+		params.Meta.ProgressToken = token
+	}
+	result, err := mc.client.CallTool(ctx, mcp.CallToolRequest{Params: params})
 	if err != nil {
 		return "", fmt.Errorf("calling tool %s on %s: %w", name, mc.name, err)
 	}
 
-	// Extract text content from the result
+	// Extract text content from the result; embedded files are saved as
+	// artifacts.
 	var parts []string
 	for _, c := range result.Content {
-		if tc, ok := c.(mcp.TextContent); ok {
-			parts = append(parts, tc.Text)
+		switch c := c.(type) {
+		case mcp.TextContent:
+			parts = append(parts, c.Text)
+		case mcp.EmbeddedResource:
+			if note := saveResource(ctx, name, c.Resource); note != "" {
+				parts = append(parts, note)
+			}
 		}
 	}
 
@@ -112,7 +244,20 @@ func (mc *MCPConnection) ToolNames() []string {
 	return names
 }
 
-// Close shuts down the MCP server subprocess.
+// Ping checks that the server is responding.
+func (mc *MCPConnection) Ping(ctx context.Context) error {
+	return mc.client.Ping(ctx)
+}
+
+// Logs returns the latest lines the server wrote to stderr, oldest first.
+func (mc *MCPConnection) Logs() []string {
+	if mc.stderr == nil {
+		return nil
+	}
+	return mc.stderr.lines()
+}
+
+// Close shuts down the MCP server subprocess or remote session.
 func (mc *MCPConnection) Close() {
 	mc.client.Close()
 }
@@ -0,0 +1,126 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ToolFunc executes a tool call and returns its text result.
+type ToolFunc func(ctx context.Context, name string, args map[string]any) (string, error)
+
+// Middleware wraps a ToolFunc with pre/post processing such as redaction,
+// argument rewriting, caching, or metrics.
+type Middleware func(next ToolFunc) ToolFunc
+
+// Redact replaces every occurrence of the given secrets in tool results
+// (and error messages) with "[REDACTED]". Empty secrets are ignored.
+func Redact(secrets ...string) Middleware {
+	var pairs []string
+	for _, s := range secrets {
+		if s != "" {
+			pairs = append(pairs, s, "[REDACTED]")
+		}
+	}
+	replacer := strings.NewReplacer(pairs...)
+
+	return func(next ToolFunc) ToolFunc {
+		return func(ctx context.Context, name string, args map[string]any) (string, error) {
+			result, err := next(ctx, name, args)
+			if len(pairs) == 0 {
+				return result, err
+			}
+			if err != nil {
+				err = redactedError{msg: replacer.Replace(err.Error()), err: err}
+			}
+			return replacer.Replace(result), err
+		}
+	}
+}
+
+// redactedError hides secrets in the message while keeping the original
+// error available to errors.Is/As.
+type redactedError struct {
+	msg string
+	err error
+}
+
+func (e redactedError) Error() string { return e.msg }
+func (e redactedError) Unwrap() error { return e.err }
+
+// RewriteArgs lets fn inspect or replace a tool's arguments before the call.
+// Returning an error aborts the call and surfaces the error to the agent.
+func RewriteArgs(fn func(name string, args map[string]any) (map[string]any, error)) Middleware {
+	return func(next ToolFunc) ToolFunc {
+		return func(ctx context.Context, name string, args map[string]any) (string, error) {
+			rewritten, err := fn(name, args)
+			if err != nil {
+				return "", err
+			}
+			return next(ctx, name, rewritten)
+		}
+	}
+}
+
+// Cache memoizes successful results for identical calls within ttl.
+// Only the named tools are cached, so with no names nothing is; name only
+// read-only tools, since a cached call skips the tool's effects. Each
+// registry the middleware is used in, forks included, has a cache of its
+// own, so sessions don't see each other's results.
+func Cache(ttl time.Duration, toolNames ...string) Middleware {
+	only := make(map[string]bool, len(toolNames))
+	for _, n := range toolNames {
+		only[n] = true
+	}
+
+	type cacheKey struct {
+		registry *Registry
+		call     string
+	}
+	type entry struct {
+		result  string
+		expires time.Time
+	}
+	var mu sync.Mutex
+	entries := make(map[cacheKey]entry)
+
+	return func(next ToolFunc) ToolFunc {
+		return func(ctx context.Context, name string, args map[string]any) (string, error) {
+			if !only[name] {
+				return next(ctx, name, args)
+			}
+
+			argsJSON, err := json.Marshal(args)
+			if err != nil {
+				return next(ctx, name, args)
+			}
+			key := cacheKey{registry: registryFromContext(ctx), call: name + "\x00" + string(argsJSON)}
+
+			now := time.Now()
+			mu.Lock()
+			if e, ok := entries[key]; ok && now.Before(e.expires) {
+				mu.Unlock()
+				return e.result, nil
+			}
+			mu.Unlock()
+
+			result, err := next(ctx, name, args)
+			if err != nil || strings.HasPrefix(result, "error: ") {
+				return result, err
+			}
+
+			mu.Lock()
+			entries[key] = entry{result: result, expires: now.Add(ttl)}
+			// Drop expired entries so the map doesn't grow without bound
+			for k, e := range entries {
+				if now.After(e.expires) {
+					delete(entries, k)
+				}
+			}
+			mu.Unlock()
+			return result, nil
+		}
+	}
+}
@@ -0,0 +1,239 @@
+package tools_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/michaelbrown/forge/internal/tools"
+)
+
+// stubTool returns a ToolFunc that counts calls and returns a fixed result.
+func stubTool(calls *int, result string) tools.ToolFunc {
+	return func(_ context.Context, name string, args map[string]any) (string, error) {
+		*calls++
+		return result, nil
+	}
+}
+
+func TestRegistryUse_Order(t *testing.T) {
+	r := tools.NewRegistry()
+	defer r.Close()
+
+	var order []string
+	trace := func(label string) tools.Middleware {
+		return func(next tools.ToolFunc) tools.ToolFunc {
+			return func(ctx context.Context, name string, args map[string]any) (string, error) {
+				order = append(order, label+":before")
+				result, err := next(ctx, name, args)
+				order = append(order, label+":after")
+				return result, err
+			}
+		}
+	}
+	// Terminal middleware short-circuits so no MCP server is needed.
+	terminal := func(next tools.ToolFunc) tools.ToolFunc {
+		return func(ctx context.Context, name string, args map[string]any) (string, error) {
+			order = append(order, "terminal")
+			return "ok", nil
+		}
+	}
+
+	r.Use(trace("outer"), trace("inner"))
+	r.Use(terminal)
+
+	result, err := r.CallTool(context.Background(), "anything", nil)
+	if err != nil {
+		t.Fatalf("CallTool: %v", err)
+	}
+	if result != "ok" {
+		t.Errorf("result = %q, want ok", result)
+	}
+
+	want := "outer:before,inner:before,terminal,inner:after,outer:after"
+	if got := strings.Join(order, ","); got != want {
+		t.Errorf("order = %s, want %s", got, want)
+	}
+}
+
+func TestRegistryUse_PassesThroughToServer(t *testing.T) {
+	r := tools.NewRegistry()
+	defer r.Close()
+
+	var seen string
+	r.Use(func(next tools.ToolFunc) tools.ToolFunc {
+		return func(ctx context.Context, name string, args map[string]any) (string, error) {
+			seen = name
+			return next(ctx, name, args)
+		}
+	})
+
+	if _, err := r.CallTool(context.Background(), "nonexistent", nil); err == nil {
+		t.Fatal("expected unknown tool error to propagate through middleware")
+	}
+	if seen != "nonexistent" {
+		t.Errorf("middleware saw %q, want nonexistent", seen)
+	}
+}
+
+func TestRedact(t *testing.T) {
+	base := func(_ context.Context, _ string, _ map[string]any) (string, error) {
+		return "token=sk-secret-123 user=bob", errors.New("auth failed for sk-secret-123")
+	}
+
+	call := tools.Redact("sk-secret-123", "")(base)
+	result, err := call(context.Background(), "x", nil)
+
+	if strings.Contains(result, "sk-secret-123") {
+		t.Errorf("result not redacted: %q", result)
+	}
+	if !strings.Contains(result, "[REDACTED]") || !strings.Contains(result, "user=bob") {
+		t.Errorf("unexpected redacted result: %q", result)
+	}
+	if err == nil || strings.Contains(err.Error(), "sk-secret-123") {
+		t.Errorf("error not redacted: %v", err)
+	}
+}
+
+func TestRewriteArgs(t *testing.T) {
+	var gotArgs map[string]any
+	base := func(_ context.Context, _ string, args map[string]any) (string, error) {
+		gotArgs = args
+		return "ok", nil
+	}
+
+	rewrite := tools.RewriteArgs(func(name string, args map[string]any) (map[string]any, error) {
+		if args["path"] == "/etc/passwd" {
+			return nil, errors.New("path outside workspace")
+		}
+		out := map[string]any{"path": "/workspace/" + args["path"].(string)}
+		return out, nil
+	})
+	call := rewrite(base)
+
+	if _, err := call(context.Background(), "file_read", map[string]any{"path": "a.txt"}); err != nil {
+		t.Fatalf("call: %v", err)
+	}
+	if gotArgs["path"] != "/workspace/a.txt" {
+		t.Errorf("path = %v, want /workspace/a.txt", gotArgs["path"])
+	}
+
+	gotArgs = nil
+	if _, err := call(context.Background(), "file_read", map[string]any{"path": "/etc/passwd"}); err == nil {
+		t.Fatal("expected rewrite error to abort the call")
+	}
+	if gotArgs != nil {
+		t.Error("next should not be called when rewrite fails")
+	}
+}
+
+func TestCache(t *testing.T) {
+	calls := 0
+	call := tools.Cache(time.Minute, "web_fetch")(stubTool(&calls, "page"))
+	ctx := context.Background()
+
+	for range 3 {
+		if _, err := call(ctx, "web_fetch", map[string]any{"url": "https://example.com"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if calls != 1 {
+		t.Errorf("identical cached calls hit the tool %d times, want 1", calls)
+	}
+
+	call(ctx, "web_fetch", map[string]any{"url": "https://example.org"})
+	if calls != 2 {
+		t.Errorf("different args should miss the cache, calls = %d", calls)
+	}
+
+	call(ctx, "shell_exec", map[string]any{"command": "date"})
+	call(ctx, "shell_exec", map[string]any{"command": "date"})
+	if calls != 4 {
+		t.Errorf("uncached tool should always run, calls = %d", calls)
+	}
+}
+
+func TestCache_SkipsErrors(t *testing.T) {
+	calls := 0
+	call := tools.Cache(time.Minute, "x")(stubTool(&calls, "error: boom"))
+
+	call(context.Background(), "x", nil)
+	call(context.Background(), "x", nil)
+	if calls != 2 {
+		t.Errorf("error results should not be cached, calls = %d", calls)
+	}
+}
+
+func TestCache_Expires(t *testing.T) {
+	calls := 0
+	call := tools.Cache(time.Millisecond, "x")(stubTool(&calls, "ok"))
+
+	call(context.Background(), "x", nil)
+	time.Sleep(5 * time.Millisecond)
+	call(context.Background(), "x", nil)
+	if calls != 2 {
+		t.Errorf("expired entry should be refreshed, calls = %d", calls)
+	}
+}
+
+func TestCache_NoNames(t *testing.T) {
+	calls := 0
+	call := tools.Cache(time.Minute)(stubTool(&calls, "ok"))
+
+	call(context.Background(), "file_write", map[string]any{"path": "a"})
+	call(context.Background(), "file_write", map[string]any{"path": "a"})
+	if calls != 2 {
+		t.Errorf("Cache with no tool names cached a call, calls = %d", calls)
+	}
+}
+
+func TestCache_PerRegistry(t *testing.T) {
+	calls := 0
+	parent := tools.NewRegistry()
+	defer parent.Close()
+	parent.Use(tools.Cache(time.Minute, "lookup"), func(tools.ToolFunc) tools.ToolFunc {
+		return stubTool(&calls, "ok")
+	})
+	child := parent.Fork()
+	defer child.Close()
+	ctx := context.Background()
+
+	parent.CallTool(ctx, "lookup", nil)
+	parent.CallTool(ctx, "lookup", nil)
+	if calls != 1 {
+		t.Fatalf("identical calls hit the tool %d times, want 1", calls)
+	}
+	// A fork shares the middleware but not what it cached.
+	child.CallTool(ctx, "lookup", nil)
+	child.CallTool(ctx, "lookup", nil)
+	if calls != 2 {
+		t.Errorf("fork's calls hit the tool %d times in all, want 2", calls)
+	}
+}
+
+func TestRegistryFork_InheritsMiddleware(t *testing.T) {
+	parent := tools.NewRegistry()
+	defer parent.Close()
+
+	parent.Use(func(next tools.ToolFunc) tools.ToolFunc {
+		return func(ctx context.Context, name string, args map[string]any) (string, error) {
+			return "from parent middleware", nil
+		}
+	})
+
+	child := parent.Fork()
+	defer child.Close()
+
+	result, err := child.CallTool(context.Background(), "anything", nil)
+	if err != nil {
+		t.Fatalf("CallTool: %v", err)
+	}
+	if result != "from parent middleware" {
+		t.Errorf("result = %q, want parent middleware to run", result)
+	}
+	if child.HasTools() {
+		t.Error("forked registry should start without servers")
+	}
+}
@@ -4,58 +4,303 @@ import (
 	"context"
 	"fmt"
 	"os"
+	"sort"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/michaelbrown/forge/internal/llm"
 )
 
 // Registry manages multiple MCP tool server connections.
+// It is safe for concurrent use so servers can be added while sessions run.
 type Registry struct {
-	connections map[string]*MCPConnection // server name → connection
-	toolIndex   map[string]string         // tool name → server name
+	mu          sync.RWMutex
+	connections map[string]Server // server name → connection
+	toolIndex   map[string]string // tool name → server name
+	middleware  []Middleware
+	onCall      []func(CallRecord)
+	tokenDir    string // OAuth token cache for remote servers
+	approver    Approver
+	artifacts   ArtifactSaver
 }
 
+// Approver asks the user to sign off on something a tool server wants to do,
+// such as a shell command that matches a require_approval pattern. message
+// describes the action; the result reports whether the user approved.
+type Approver func(ctx context.Context, server, message string) (bool, error)
+
 // NewRegistry creates an empty tool registry.
 func NewRegistry() *Registry {
 	return &Registry{
-		connections: make(map[string]*MCPConnection),
+		connections: make(map[string]Server),
 		toolIndex:   make(map[string]string),
+		tokenDir:    DefaultTokenCacheDir(),
 	}
 }
 
-// Register launches an MCP tool server and adds its tools to the registry.
+// SetTokenCacheDir changes where OAuth tokens for remote servers are cached.
+func (r *Registry) SetTokenCacheDir(dir string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.tokenDir = dir
+}
+
+// SetApprover sets who tool servers ask when an action needs the user's
+// approval. Without one, such actions are refused.
+func (r *Registry) SetApprover(fn Approver) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.approver = fn
+}
+
+// SetArtifactSaver sets where files that tools return as embedded resources
+// are kept. Without one, they are dropped.
+func (r *Registry) SetArtifactSaver(fn ArtifactSaver) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.artifacts = fn
+}
+
+// approve routes an approval request from the named server to the approver.
+func (r *Registry) approve(ctx context.Context, server, message string) (bool, error) {
+	r.mu.RLock()
+	fn := r.approver
+	r.mu.RUnlock()
+	if fn == nil {
+		return false, fmt.Errorf("no one is available to approve this")
+	}
+	return fn(ctx, server, message)
+}
+
+// Fork returns an empty registry that shares r's middleware chain, call
+// hooks, approver, and artifact saver, for callers that need an isolated set
+// of servers with the same call policy.
+func (r *Registry) Fork() *Registry {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	child := NewRegistry()
+	child.middleware = append([]Middleware(nil), r.middleware...)
+	child.onCall = append([]func(CallRecord){}, r.onCall...)
+	child.tokenDir = r.tokenDir
+	child.approver = r.approver
+	child.artifacts = r.artifacts
+	return child
+}
+
+// Register launches an MCP tool server (or loads a WASM plugin, or connects
+// to a remote server with the configured auth) and adds its tools to the registry.
 func (r *Registry) Register(name string, cfg ToolServerConfig) error {
 	if !cfg.Enabled {
 		return nil
 	}
 
-	// Build environment variables
-	var env []string
-	env = append(env, os.Environ()...)
-	for k, v := range cfg.Env {
-		// Expand environment variable references like ${VAR}
-		if strings.HasPrefix(v, "${") && strings.HasSuffix(v, "}") {
-			envVar := v[2 : len(v)-1]
-			v = os.Getenv(envVar)
+	if r.HasServer(name) {
+		return fmt.Errorf("tool server %s already registered", name)
+	}
+
+	srv, err := r.start(name, cfg)
+	if err != nil {
+		return err
+	}
+	if err := r.Add(name, srv); err != nil {
+		srv.Close()
+		return err
+	}
+	return nil
+}
+
+// start launches or connects to the server cfg describes.
+func (r *Registry) start(name string, cfg ToolServerConfig) (Server, error) {
+	var srv Server
+	var err error
+	switch {
+	case cfg.URL != "":
+		r.mu.RLock()
+		tokenDir := r.tokenDir
+		r.mu.RUnlock()
+		var tokens TokenSource
+		tokens, err = NewTokenSource(name, cfg.Auth, tokenDir, nil)
+		if err != nil {
+			return nil, err
 		}
-		env = append(env, k+"="+v)
+		srv, err = NewRemoteMCPConnection(context.Background(), name, cfg.URL, tokens)
+	case strings.HasSuffix(cfg.Binary, ".wasm"):
+		srv, err = LoadWASMPlugin(context.Background(), name, cfg.Binary, cfg.Env)
+	default:
+		srv, err = NewMCPConnection(name, cfg.Binary, buildEnv(cfg.Env), r.approve)
 	}
+	return srv, err
+}
 
-	conn, err := NewMCPConnection(name, cfg.Binary, env)
+// Add registers an already-started server under name.
+func (r *Registry) Add(name string, srv Server) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.connections[name]; ok {
+		return fmt.Errorf("tool server %s already registered", name)
+	}
+
+	r.connections[name] = srv
+	for _, toolName := range srv.ToolNames() {
+		r.toolIndex[toolName] = name
+	}
+	return nil
+}
+
+// Restart replaces the named server with a fresh one started from cfg,
+// closing the old one once calls go to the new. Calls in flight on the old
+// server fail. If the new server won't start, the old one is kept.
+func (r *Registry) Restart(name string, cfg ToolServerConfig) error {
+	if !r.HasServer(name) {
+		return fmt.Errorf("tool server %s not registered", name)
+	}
+	srv, err := r.start(name, cfg)
 	if err != nil {
 		return err
 	}
 
-	r.connections[name] = conn
-	for _, toolName := range conn.ToolNames() {
+	r.mu.Lock()
+	old := r.connections[name]
+	r.unindex(name, old)
+	r.connections[name] = srv
+	for _, toolName := range srv.ToolNames() {
 		r.toolIndex[toolName] = name
 	}
+	r.mu.Unlock()
+
+	if old != nil {
+		old.Close()
+	}
+	return nil
+}
 
+// Remove closes the named server and drops its tools.
+func (r *Registry) Remove(name string) error {
+	r.mu.Lock()
+	srv, ok := r.connections[name]
+	if ok {
+		r.unindex(name, srv)
+		delete(r.connections, name)
+	}
+	r.mu.Unlock()
+	if !ok {
+		return fmt.Errorf("tool server %s not registered", name)
+	}
+	srv.Close()
 	return nil
 }
 
+// unindex drops the tools srv provides as name. The caller must hold mu.
+func (r *Registry) unindex(name string, srv Server) {
+	if srv == nil {
+		return
+	}
+	for _, toolName := range srv.ToolNames() {
+		if r.toolIndex[toolName] == name {
+			delete(r.toolIndex, toolName)
+		}
+	}
+}
+
+// Ping checks that the named server is responding. Servers that run in
+// process, such as WASM plugins, always are.
+func (r *Registry) Ping(ctx context.Context, name string) error {
+	r.mu.RLock()
+	srv, ok := r.connections[name]
+	r.mu.RUnlock()
+	if !ok {
+		return fmt.Errorf("tool server %s not registered", name)
+	}
+	if p, ok := srv.(interface{ Ping(context.Context) error }); ok {
+		return p.Ping(ctx)
+	}
+	return nil
+}
+
+// Logs returns the latest lines the named server wrote to stderr, oldest
+// first, or nil if it doesn't run as a subprocess.
+func (r *Registry) Logs(name string) []string {
+	r.mu.RLock()
+	srv := r.connections[name]
+	r.mu.RUnlock()
+	if l, ok := srv.(interface{ Logs() []string }); ok {
+		return l.Logs()
+	}
+	return nil
+}
+
+// Environ returns the environment a subprocess server runs with: the
+// process environment plus the configured overrides.
+func (cfg ToolServerConfig) Environ() []string {
+	return buildEnv(cfg.Env)
+}
+
+// buildEnv returns the process environment plus the configured overrides.
+func buildEnv(overrides map[string]string) []string {
+	var env []string
+	env = append(env, os.Environ()...)
+	for k, v := range overrides {
+		env = append(env, k+"="+expandEnvRef(v))
+	}
+	return env
+}
+
+// expandEnvRef expands environment variable references like ${VAR}.
+func expandEnvRef(v string) string {
+	if strings.HasPrefix(v, "${") && strings.HasSuffix(v, "}") {
+		return os.Getenv(v[2 : len(v)-1])
+	}
+	return v
+}
+
+// HasServer reports whether a server with the given name is registered.
+func (r *Registry) HasServer(name string) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	_, ok := r.connections[name]
+	return ok
+}
+
+// ServerNames returns the names of all registered servers, sorted.
+func (r *Registry) ServerNames() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	names := make([]string, 0, len(r.connections))
+	for name := range r.connections {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ServerTools returns the tool names provided by a registered server.
+func (r *Registry) ServerTools(name string) []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	conn, ok := r.connections[name]
+	if !ok {
+		return nil
+	}
+	return conn.ToolNames()
+}
+
+// ServerToolDefs returns the tool definitions of a registered server.
+func (r *Registry) ServerToolDefs(name string) []llm.ToolDef {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	conn, ok := r.connections[name]
+	if !ok {
+		return nil
+	}
+	return conn.ToolDefs()
+}
+
 // AllTools returns tool definitions from all registered servers.
 func (r *Registry) AllTools() []llm.ToolDef {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
 	var all []llm.ToolDef
 	for _, conn := range r.connections {
 		all = append(all, conn.ToolDefs()...)
@@ -63,23 +308,85 @@ func (r *Registry) AllTools() []llm.ToolDef {
 	return all
 }
 
-// CallTool routes a tool call to the appropriate MCP server.
+// Use appends middleware to the tool call chain. Middleware added first runs
+// outermost, so it sees the call before and the result after all others.
+func (r *Registry) Use(mw ...Middleware) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.middleware = append(r.middleware, mw...)
+}
+
+// OnCall adds a hook that receives a CallRecord after every call that reaches
+// a server. Hooks run synchronously and must be safe for concurrent use.
+func (r *Registry) OnCall(fn func(CallRecord)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.onCall = append(r.onCall, fn)
+}
+
+// CallTool routes a tool call through the middleware chain to the appropriate MCP server.
 func (r *Registry) CallTool(ctx context.Context, name string, args map[string]any) (string, error) {
+	r.mu.RLock()
+	chain := r.middleware
+	r.mu.RUnlock()
+
+	call := ToolFunc(r.callServer)
+	for i := len(chain) - 1; i >= 0; i-- {
+		call = chain[i](call)
+	}
+	return call(context.WithValue(ctx, registryKey{}, r), name, args)
+}
+
+// callServer sends a tool call directly to the MCP server that owns it.
+func (r *Registry) callServer(ctx context.Context, name string, args map[string]any) (string, error) {
+	r.mu.RLock()
 	serverName, ok := r.toolIndex[name]
+	conn := r.connections[serverName]
+	hooks := r.onCall
+	artifacts := r.artifacts
+	r.mu.RUnlock()
 	if !ok {
 		return "", fmt.Errorf("unknown tool: %s", name)
 	}
-	conn := r.connections[serverName]
-	return conn.CallTool(ctx, name, args)
+	if artifacts != nil {
+		ctx = withArtifactSaver(ctx, artifacts)
+	}
+
+	start := time.Now()
+	result, err := conn.CallTool(ctx, name, args)
+	if len(hooks) > 0 {
+		rec := CallRecord{
+			Tool:      name,
+			Server:    serverName,
+			SessionID: SessionFromContext(ctx),
+			CallID:    CallIDFromContext(ctx),
+			Args:      args,
+			Result:    result,
+			Duration:  time.Since(start),
+			Failed:    err != nil || isErrorResult(result),
+			At:        start,
+		}
+		if err != nil {
+			rec.Result = fmt.Sprintf("error: %s", err)
+		}
+		for _, fn := range hooks {
+			fn(rec)
+		}
+	}
+	return result, err
 }
 
 // HasTools returns true if any tools are registered.
 func (r *Registry) HasTools() bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
 	return len(r.toolIndex) > 0
 }
 
 // Close shuts down all MCP server connections.
 func (r *Registry) Close() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
 	for _, conn := range r.connections {
 		conn.Close()
 	}
@@ -0,0 +1,87 @@
+package tools
+
+import (
+	"sort"
+	"strings"
+	"time"
+)
+
+// CallRecord describes one completed tool call, as reported to OnCall hooks.
+// SessionID and CallID are set when the call's context has them; CallID
+// matches the llm.ToolCall that asked for it and the tool result message.
+type CallRecord struct {
+	Tool      string         `json:"tool"`
+	Server    string         `json:"server"`
+	SessionID string         `json:"session_id,omitempty"`
+	CallID    string         `json:"call_id,omitempty"`
+	Args      map[string]any `json:"args,omitempty"`
+	Result    string         `json:"result,omitempty"` // as the model sees it
+	Duration  time.Duration  `json:"duration"`
+	Failed    bool           `json:"failed"` // Go error or an "error: " result
+	At        time.Time      `json:"at"`
+}
+
+// ToolStats summarizes calls to a single tool.
+type ToolStats struct {
+	Tool      string  `json:"tool"`
+	Server    string  `json:"server"`
+	Calls     int     `json:"calls"`
+	Errors    int     `json:"errors"`
+	ErrorRate float64 `json:"error_rate"`
+	P50Ms     float64 `json:"p50_ms"`
+	P90Ms     float64 `json:"p90_ms"`
+	P99Ms     float64 `json:"p99_ms"`
+}
+
+// SummarizeCalls aggregates call records per tool, hottest tools first.
+func SummarizeCalls(records []CallRecord) []ToolStats {
+	byTool := make(map[string][]CallRecord)
+	for _, rec := range records {
+		byTool[rec.Tool] = append(byTool[rec.Tool], rec)
+	}
+
+	stats := make([]ToolStats, 0, len(byTool))
+	for tool, recs := range byTool {
+		st := ToolStats{Tool: tool, Calls: len(recs)}
+		durations := make([]time.Duration, len(recs))
+		for i, rec := range recs {
+			durations[i] = rec.Duration
+			if rec.Failed {
+				st.Errors++
+			}
+			// Servers can change across restarts; report the latest.
+			st.Server = rec.Server
+		}
+		sort.Slice(durations, func(i, j int) bool { return durations[i] < durations[j] })
+		st.ErrorRate = float64(st.Errors) / float64(st.Calls)
+		st.P50Ms = percentileMs(durations, 50)
+		st.P90Ms = percentileMs(durations, 90)
+		st.P99Ms = percentileMs(durations, 99)
+		stats = append(stats, st)
+	}
+
+	sort.Slice(stats, func(i, j int) bool {
+		if stats[i].Calls != stats[j].Calls {
+			return stats[i].Calls > stats[j].Calls
+		}
+		return stats[i].Tool < stats[j].Tool
+	})
+	return stats
+}
+
+// percentileMs returns the nearest-rank percentile of sorted durations in milliseconds.
+func percentileMs(sorted []time.Duration, p int) float64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := (p*len(sorted) + 99) / 100 // ceil(p/100 * n)
+	if rank < 1 {
+		rank = 1
+	}
+	return float64(sorted[rank-1]) / float64(time.Millisecond)
+}
+
+// isErrorResult reports whether a tool result follows the "error: " convention.
+func isErrorResult(result string) bool {
+	return strings.HasPrefix(result, "error: ")
+}
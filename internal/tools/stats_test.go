@@ -0,0 +1,94 @@
+package tools_test
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/michaelbrown/forge/internal/tools"
+)
+
+func TestSummarizeCalls(t *testing.T) {
+	var records []tools.CallRecord
+	for i := 1; i <= 10; i++ {
+		records = append(records, tools.CallRecord{
+			Tool:     "file_read",
+			Server:   "file-ops",
+			Duration: time.Duration(i) * time.Millisecond,
+			Failed:   i == 10,
+		})
+	}
+	records = append(records, tools.CallRecord{Tool: "shell_exec", Server: "shell-exec", Duration: 50 * time.Millisecond})
+
+	stats := tools.SummarizeCalls(records)
+	if len(stats) != 2 {
+		t.Fatalf("got %d tools, want 2", len(stats))
+	}
+
+	fr := stats[0]
+	if fr.Tool != "file_read" || fr.Calls != 10 || fr.Errors != 1 {
+		t.Errorf("hottest tool = %+v", fr)
+	}
+	if fr.ErrorRate != 0.1 {
+		t.Errorf("error rate = %v, want 0.1", fr.ErrorRate)
+	}
+	if fr.P50Ms != 5 || fr.P90Ms != 9 || fr.P99Ms != 10 {
+		t.Errorf("percentiles = %v/%v/%v, want 5/9/10", fr.P50Ms, fr.P90Ms, fr.P99Ms)
+	}
+
+	if stats[1].Tool != "shell_exec" || stats[1].P99Ms != 50 {
+		t.Errorf("second tool = %+v", stats[1])
+	}
+
+	if len(tools.SummarizeCalls(nil)) != 0 {
+		t.Error("no records should produce no stats")
+	}
+}
+
+func TestRegistryOnCall(t *testing.T) {
+	r := tools.NewRegistry()
+	defer r.Close()
+	if err := r.Add("builtin", tools.NewBuiltinServer()); err != nil {
+		t.Fatal(err)
+	}
+
+	var mu sync.Mutex
+	var records []tools.CallRecord
+	r.OnCall(func(rec tools.CallRecord) {
+		mu.Lock()
+		records = append(records, rec)
+		mu.Unlock()
+	})
+
+	ctx := context.Background()
+	r.CallTool(tools.WithCallID(tools.WithSession(ctx, "sess-1"), "call_1"), "shell_exec", map[string]any{"command": "echo hi"})
+	r.CallTool(ctx, "file_read", map[string]any{"path": "/nonexistent/forge-stats"})
+	r.CallTool(ctx, "not_a_tool", nil)
+
+	// Forked registries report to the parent's hooks.
+	child := r.Fork()
+	defer child.Close()
+	child.Add("builtin", tools.NewBuiltinServer())
+	child.CallTool(ctx, "file_list", map[string]any{"path": t.TempDir()})
+
+	if len(records) != 3 {
+		t.Fatalf("got %d records, want 3 (unknown tools are not recorded): %+v", len(records), records)
+	}
+	if records[0].Tool != "shell_exec" || records[0].Server != "builtin" || records[0].Failed {
+		t.Errorf("shell_exec record = %+v", records[0])
+	}
+	if rec := records[0]; rec.SessionID != "sess-1" || rec.CallID != "call_1" || rec.Args["command"] != "echo hi" || !strings.Contains(rec.Result, "hi") {
+		t.Errorf("shell_exec record missing the call: %+v", rec)
+	}
+	if records[0].Duration <= 0 || records[0].At.IsZero() {
+		t.Errorf("shell_exec record missing timing: %+v", records[0])
+	}
+	if !records[1].Failed {
+		t.Errorf("error result should be recorded as failed: %+v", records[1])
+	}
+	if records[2].Tool != "file_list" {
+		t.Errorf("forked call record = %+v", records[2])
+	}
+}
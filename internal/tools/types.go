@@ -1,8 +1,106 @@
 package tools
 
-// ToolServerConfig describes an MCP tool server binary.
+import (
+	"context"
+
+	"github.com/michaelbrown/forge/internal/llm"
+)
+
+// ToolServerConfig describes an MCP tool server binary or remote endpoint.
+// A Binary ending in ".wasm" is loaded as a WASM plugin instead of a subprocess.
+// If URL is set, the server is reached over streamable HTTP instead.
 type ToolServerConfig struct {
 	Binary  string            `mapstructure:"binary"`
+	URL     string            `mapstructure:"url"`
 	Env     map[string]string `mapstructure:"env"`
 	Enabled bool              `mapstructure:"enabled"`
+	Auth    AuthConfig        `mapstructure:"auth"`
+}
+
+// Auth types for AuthConfig.Type.
+const (
+	AuthNone        = ""
+	AuthBearer      = "bearer"       // static or env-sourced bearer token
+	AuthOAuthDevice = "oauth_device" // OAuth 2.0 device authorization grant
+)
+
+// AuthConfig describes how to authenticate to a remote tool server.
+type AuthConfig struct {
+	Type string `mapstructure:"type"`
+
+	// Bearer: Token may be a literal or a ${VAR} reference; TokenEnv names
+	// an environment variable read at connect time.
+	Token    string `mapstructure:"token"`
+	TokenEnv string `mapstructure:"token_env"`
+
+	// OAuth device flow. Tokens are cached under ~/.forge/tokens.
+	ClientID      string   `mapstructure:"client_id"`
+	DeviceAuthURL string   `mapstructure:"device_auth_url"`
+	TokenURL      string   `mapstructure:"token_url"`
+	Scopes        []string `mapstructure:"scopes"`
+}
+
+// Server is a source of tools the registry routes calls to. MCPConnection
+// and WASMPlugin both implement it.
+type Server interface {
+	ToolDefs() []llm.ToolDef
+	ToolNames() []string
+	CallTool(ctx context.Context, name string, args map[string]any) (string, error)
+	Close()
+}
+
+// SessionMetaKey is the MCP request _meta field that carries the forge
+// session ID, so tool servers can scope state such as file backups.
+const SessionMetaKey = "forge/session"
+
+type sessionKey struct{}
+
+// WithSession returns a context whose tool calls are attributed to sessionID.
+func WithSession(ctx context.Context, sessionID string) context.Context {
+	return context.WithValue(ctx, sessionKey{}, sessionID)
+}
+
+// SessionFromContext returns the session ID set by WithSession, if any.
+func SessionFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(sessionKey{}).(string)
+	return id
+}
+
+type callIDKey struct{}
+
+// WithCallID returns a context for the tool call with the given ID, as the
+// model assigned it, so the call's CallRecord can be matched to the
+// messages that request it and carry its result.
+func WithCallID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, callIDKey{}, id)
+}
+
+// CallIDFromContext returns the call ID set by WithCallID, if any.
+func CallIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(callIDKey{}).(string)
+	return id
+}
+
+type progressKey struct{}
+
+// WithProgress returns a context whose tool calls pass what a tool reports
+// while it runs, such as a program's output so far, to fn. Servers that
+// report nothing never call it.
+func WithProgress(ctx context.Context, fn func(message string)) context.Context {
+	return context.WithValue(ctx, progressKey{}, fn)
+}
+
+// progressFromContext returns the function set by WithProgress, if any.
+func progressFromContext(ctx context.Context) func(string) {
+	fn, _ := ctx.Value(progressKey{}).(func(string))
+	return fn
+}
+
+type registryKey struct{}
+
+// registryFromContext returns the registry making the call, set by
+// CallTool, for middleware that keeps state per registry.
+func registryFromContext(ctx context.Context) *Registry {
+	r, _ := ctx.Value(registryKey{}).(*Registry)
+	return r
 }
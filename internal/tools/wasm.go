@@ -0,0 +1,208 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/imports/wasi_snapshot_preview1"
+
+	"github.com/michaelbrown/forge/internal/llm"
+)
+
+// WASMPlugin runs tools from a WebAssembly module inside a wazero sandbox.
+// The module has no filesystem or network access; it only sees its own
+// memory and the environment variables from its config.
+//
+// Plugin ABI — the module must export:
+//
+//	memory
+//	forge_alloc(size u32) u32
+//	    Returns a pointer to size bytes the host may write into.
+//	forge_describe() u64
+//	    Returns (ptr<<32 | len) of a JSON array of tool definitions:
+//	    [{"name": "...", "description": "...", "parameters": {JSON Schema}}]
+//	forge_call(name_ptr, name_len, args_ptr, args_len u32) u64
+//	    Runs the named tool with JSON-encoded arguments and returns
+//	    (ptr<<32 | len) of {"content": "...", "is_error": false}.
+//
+// WASI reactors (e.g. Go built with -buildmode=c-shared) have their
+// _initialize export run once at load time.
+type WASMPlugin struct {
+	name     string
+	runtime  wazero.Runtime
+	compiled wazero.CompiledModule
+	modCfg   wazero.ModuleConfig
+	tools    []llm.ToolDef
+
+	mu  sync.Mutex // module instances are single-threaded
+	mod api.Module
+}
+
+// LoadWASMPlugin compiles and instantiates a plugin module and reads its tool list.
+func LoadWASMPlugin(ctx context.Context, name, path string, env map[string]string) (*WASMPlugin, error) {
+	wasm, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading WASM plugin %s (%s): %w", name, path, err)
+	}
+
+	// Close the module when a call's context ends so runaway plugins can be stopped.
+	rt := wazero.NewRuntimeWithConfig(ctx, wazero.NewRuntimeConfig().WithCloseOnContextDone(true))
+	if _, err := wasi_snapshot_preview1.Instantiate(ctx, rt); err != nil {
+		rt.Close(ctx)
+		return nil, fmt.Errorf("instantiating WASI for %s: %w", name, err)
+	}
+
+	compiled, err := rt.CompileModule(ctx, wasm)
+	if err != nil {
+		rt.Close(ctx)
+		return nil, fmt.Errorf("compiling WASM plugin %s: %w", name, err)
+	}
+
+	modCfg := wazero.NewModuleConfig().WithName("")
+	if _, ok := compiled.ExportedFunctions()["_initialize"]; ok {
+		modCfg = modCfg.WithStartFunctions("_initialize")
+	} else {
+		modCfg = modCfg.WithStartFunctions()
+	}
+	for k, v := range env {
+		modCfg = modCfg.WithEnv(k, expandEnvRef(v))
+	}
+
+	p := &WASMPlugin{
+		name:     name,
+		runtime:  rt,
+		compiled: compiled,
+		modCfg:   modCfg,
+	}
+
+	if err := p.instantiate(ctx); err != nil {
+		rt.Close(ctx)
+		return nil, err
+	}
+
+	out, err := p.invoke(ctx, "forge_describe")
+	if err != nil {
+		rt.Close(ctx)
+		return nil, fmt.Errorf("describing WASM plugin %s: %w", name, err)
+	}
+	if err := json.Unmarshal(out, &p.tools); err != nil {
+		rt.Close(ctx)
+		return nil, fmt.Errorf("parsing tool list from %s: %w", name, err)
+	}
+
+	return p, nil
+}
+
+// instantiate creates a fresh module instance and checks the ABI exports.
+func (p *WASMPlugin) instantiate(ctx context.Context) error {
+	mod, err := p.runtime.InstantiateModule(ctx, p.compiled, p.modCfg)
+	if err != nil {
+		return fmt.Errorf("instantiating WASM plugin %s: %w", p.name, err)
+	}
+	for _, fn := range []string{"forge_alloc", "forge_describe", "forge_call"} {
+		if mod.ExportedFunction(fn) == nil {
+			mod.Close(ctx)
+			return fmt.Errorf("WASM plugin %s does not export %s", p.name, fn)
+		}
+	}
+	p.mod = mod
+	return nil
+}
+
+// ToolDefs returns the tool definitions the plugin described at load time.
+func (p *WASMPlugin) ToolDefs() []llm.ToolDef {
+	return p.tools
+}
+
+// ToolNames returns the names of all tools in the plugin.
+func (p *WASMPlugin) ToolNames() []string {
+	names := make([]string, len(p.tools))
+	for i, t := range p.tools {
+		names[i] = t.Name
+	}
+	return names
+}
+
+// CallTool runs a tool inside the plugin and returns the text result.
+func (p *WASMPlugin) CallTool(ctx context.Context, name string, args map[string]any) (string, error) {
+	argsJSON, err := json.Marshal(args)
+	if err != nil {
+		return "", fmt.Errorf("encoding arguments for %s: %w", name, err)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	// A cancelled call closes the instance; start a fresh one.
+	if p.mod == nil || p.mod.IsClosed() {
+		if err := p.instantiate(ctx); err != nil {
+			return "", err
+		}
+	}
+
+	namePtr, err := p.write(ctx, []byte(name))
+	if err != nil {
+		return "", err
+	}
+	argsPtr, err := p.write(ctx, argsJSON)
+	if err != nil {
+		return "", err
+	}
+
+	out, err := p.invoke(ctx, "forge_call",
+		uint64(namePtr), uint64(len(name)), uint64(argsPtr), uint64(len(argsJSON)))
+	if err != nil {
+		return "", fmt.Errorf("calling tool %s on %s: %w", name, p.name, err)
+	}
+
+	var result struct {
+		Content string `json:"content"`
+		IsError bool   `json:"is_error"`
+	}
+	if err := json.Unmarshal(out, &result); err != nil {
+		return "", fmt.Errorf("parsing result of %s from %s: %w", name, p.name, err)
+	}
+	if result.IsError {
+		return "error: " + result.Content, nil
+	}
+	return result.Content, nil
+}
+
+// write copies data into plugin memory via forge_alloc and returns its pointer.
+func (p *WASMPlugin) write(ctx context.Context, data []byte) (uint32, error) {
+	res, err := p.mod.ExportedFunction("forge_alloc").Call(ctx, uint64(len(data)))
+	if err != nil {
+		return 0, fmt.Errorf("allocating in %s: %w", p.name, err)
+	}
+	ptr := uint32(res[0])
+	if !p.mod.Memory().Write(ptr, data) {
+		return 0, fmt.Errorf("writing to %s memory: out of range", p.name)
+	}
+	return ptr, nil
+}
+
+// invoke calls an exported function that returns a packed (ptr<<32 | len)
+// and reads the referenced bytes from plugin memory.
+func (p *WASMPlugin) invoke(ctx context.Context, fn string, params ...uint64) ([]byte, error) {
+	res, err := p.mod.ExportedFunction(fn).Call(ctx, params...)
+	if err != nil {
+		return nil, err
+	}
+	ptr, size := uint32(res[0]>>32), uint32(res[0])
+	data, ok := p.mod.Memory().Read(ptr, size)
+	if !ok {
+		return nil, fmt.Errorf("%s returned out-of-range memory (%d+%d)", fn, ptr, size)
+	}
+	// Copy out: the view aliases plugin memory, which later calls may reuse.
+	return append([]byte(nil), data...), nil
+}
+
+// Close releases the wazero runtime and all module instances.
+func (p *WASMPlugin) Close() {
+	p.runtime.Close(context.Background())
+}
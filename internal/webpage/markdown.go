@@ -0,0 +1,386 @@
+package webpage
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// converter renders an HTML tree as Markdown.
+type converter struct {
+	base     *url.URL
+	readable bool       // drop page chrome around the content
+	root     *html.Node // the element being converted; never skipped
+}
+
+// alwaysSkip holds elements that never carry readable content.
+var alwaysSkip = map[atom.Atom]bool{
+	atom.Head: true, atom.Script: true, atom.Style: true, atom.Noscript: true,
+	atom.Template: true, atom.Svg: true, atom.Canvas: true, atom.Iframe: true,
+	atom.Object: true, atom.Embed: true, atom.Input: true, atom.Button: true,
+	atom.Select: true, atom.Textarea: true,
+}
+
+// chromeSkip holds elements readable mode treats as page chrome.
+var chromeSkip = map[atom.Atom]bool{
+	atom.Nav: true, atom.Aside: true, atom.Form: true, atom.Dialog: true,
+}
+
+var chromeRoles = map[string]bool{
+	"navigation": true, "banner": true, "contentinfo": true,
+	"complementary": true, "search": true, "dialog": true,
+}
+
+// boilerplate matches class and id words that mark non-content blocks.
+var boilerplate = regexp.MustCompile(`(?i)(^|[^a-z0-9])(cookies?|consent|share|sharing|social|sidebar|comments?|advert|advertisement|ads?|promo|newsletter|breadcrumbs?|related|popup|modal|skip-?link)($|[^a-z0-9])`)
+
+func (c *converter) skip(n *html.Node) bool {
+	if alwaysSkip[n.DataAtom] || hasAttr(n, "hidden") || attr(n, "aria-hidden") == "true" {
+		return true
+	}
+	if style := strings.ReplaceAll(attr(n, "style"), " ", ""); strings.Contains(style, "display:none") {
+		return true
+	}
+	if !c.readable || n == c.root {
+		return false
+	}
+	if chromeSkip[n.DataAtom] || chromeRoles[attr(n, "role")] {
+		return true
+	}
+	// Headers and footers of the page are chrome; an article's own header
+	// usually holds its headline.
+	if (n.DataAtom == atom.Header || n.DataAtom == atom.Footer) && !insideArticle(n) {
+		return true
+	}
+	return boilerplate.MatchString(attr(n, "class")) || boilerplate.MatchString(attr(n, "id"))
+}
+
+func insideArticle(n *html.Node) bool {
+	for p := n.Parent; p != nil; p = p.Parent {
+		if p.DataAtom == atom.Article {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *converter) children(n *html.Node) string {
+	var b strings.Builder
+	for ch := n.FirstChild; ch != nil; ch = ch.NextSibling {
+		b.WriteString(c.node(ch))
+	}
+	return b.String()
+}
+
+func (c *converter) node(n *html.Node) string {
+	switch n.Type {
+	case html.TextNode:
+		return collapseSpace(n.Data)
+	case html.DocumentNode:
+		return c.children(n)
+	case html.ElementNode:
+	default:
+		return ""
+	}
+	if c.skip(n) {
+		return ""
+	}
+
+	switch n.DataAtom {
+	case atom.H1, atom.H2, atom.H3, atom.H4, atom.H5, atom.H6:
+		level := int(n.Data[1] - '0')
+		return block(strings.Repeat("#", level) + " " + c.inline(n))
+	case atom.P:
+		return block(c.paragraph(n))
+	case atom.Br:
+		return "\n"
+	case atom.Hr:
+		return block("---")
+	case atom.Pre:
+		return block(codeBlock(n))
+	case atom.Code, atom.Kbd, atom.Samp:
+		return inlineCode(textContent(n))
+	case atom.Strong, atom.B:
+		return wrap(c.children(n), "**")
+	case atom.Em, atom.I:
+		return wrap(c.children(n), "*")
+	case atom.Del, atom.S, atom.Strike:
+		return wrap(c.children(n), "~~")
+	case atom.A:
+		return c.link(n)
+	case atom.Img:
+		return c.image(n)
+	case atom.Ul:
+		return block(c.list(n, false))
+	case atom.Ol:
+		return block(c.list(n, true))
+	case atom.Li:
+		// An item outside a list.
+		return block("- " + strings.TrimSpace(c.children(n)))
+	case atom.Blockquote:
+		return block(prefixLines(strings.TrimSpace(c.children(n)), "> "))
+	case atom.Table:
+		return block(c.table(n))
+	case atom.Dt:
+		return block("**" + c.inline(n) + "**")
+	case atom.Div, atom.Section, atom.Article, atom.Main, atom.Header, atom.Footer,
+		atom.Figure, atom.Figcaption, atom.Details, atom.Summary, atom.Address,
+		atom.Dl, atom.Dd, atom.Center, atom.Fieldset, atom.Nav, atom.Aside, atom.Form, atom.Body:
+		return block(strings.TrimSpace(c.children(n)))
+	}
+	return c.children(n)
+}
+
+// inline renders n's children as a single line of text.
+func (c *converter) inline(n *html.Node) string {
+	return strings.Join(strings.Fields(c.children(n)), " ")
+}
+
+// paragraph renders n's children as text, keeping <br> line breaks.
+func (c *converter) paragraph(n *html.Node) string {
+	var lines []string
+	for _, line := range strings.Split(c.children(n), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+func (c *converter) resolve(ref string) string {
+	if c.base == nil {
+		return ref
+	}
+	u, err := url.Parse(strings.TrimSpace(ref))
+	if err != nil {
+		return ref
+	}
+	return c.base.ResolveReference(u).String()
+}
+
+func (c *converter) link(n *html.Node) string {
+	inner := c.children(n)
+	text := strings.Join(strings.Fields(inner), " ")
+	href := strings.TrimSpace(attr(n, "href"))
+	if text == "" || href == "" || strings.HasPrefix(href, "#") || strings.HasPrefix(strings.ToLower(href), "javascript:") {
+		return keepSpace(inner, text)
+	}
+	return keepSpace(inner, "["+text+"]("+c.resolve(href)+")")
+}
+
+func (c *converter) image(n *html.Node) string {
+	alt := strings.Join(strings.Fields(attr(n, "alt")), " ")
+	src := attr(n, "src")
+	if src == "" {
+		src = attr(n, "data-src")
+	}
+	if src == "" || strings.HasPrefix(src, "data:") {
+		return alt
+	}
+	return "![" + alt + "](" + c.resolve(src) + ")"
+}
+
+func (c *converter) list(n *html.Node, ordered bool) string {
+	num := 1
+	if start, err := strconv.Atoi(attr(n, "start")); err == nil {
+		num = start
+	}
+	var items []string
+	for li := n.FirstChild; li != nil; li = li.NextSibling {
+		if li.Type != html.ElementNode {
+			continue
+		}
+		if li.DataAtom != atom.Li {
+			// Nested lists sometimes sit directly inside the parent list.
+			if s := strings.TrimSpace(c.node(li)); s != "" {
+				items = append(items, prefixLines(s, "  "))
+			}
+			continue
+		}
+		if c.skip(li) {
+			continue
+		}
+		content := strings.TrimSpace(c.children(li))
+		if !strings.Contains(content, "```") {
+			content = blankLines.ReplaceAllString(content, "\n")
+		}
+		marker := "- "
+		if ordered {
+			marker = fmt.Sprintf("%d. ", num)
+			num++
+		}
+		lines := strings.Split(content, "\n")
+		for i := range lines {
+			switch {
+			case i == 0:
+				lines[i] = marker + lines[i]
+			case lines[i] != "":
+				lines[i] = strings.Repeat(" ", len(marker)) + lines[i]
+			}
+		}
+		items = append(items, strings.Join(lines, "\n"))
+	}
+	return strings.Join(items, "\n")
+}
+
+func (c *converter) table(n *html.Node) string {
+	var rows [][]string
+	var collect func(*html.Node)
+	collect = func(n *html.Node) {
+		for ch := n.FirstChild; ch != nil; ch = ch.NextSibling {
+			switch ch.DataAtom {
+			case atom.Thead, atom.Tbody, atom.Tfoot:
+				collect(ch)
+			case atom.Tr:
+				var row []string
+				for cell := ch.FirstChild; cell != nil; cell = cell.NextSibling {
+					if cell.DataAtom == atom.Td || cell.DataAtom == atom.Th {
+						row = append(row, strings.ReplaceAll(c.inline(cell), "|", `\|`))
+					}
+				}
+				if len(row) > 0 {
+					rows = append(rows, row)
+				}
+			}
+		}
+	}
+	collect(n)
+	if len(rows) == 0 {
+		return ""
+	}
+
+	cols := 0
+	for _, row := range rows {
+		cols = max(cols, len(row))
+	}
+	var b strings.Builder
+	for i, row := range rows {
+		for len(row) < cols {
+			row = append(row, "")
+		}
+		b.WriteString("| " + strings.Join(row, " | ") + " |\n")
+		if i == 0 {
+			b.WriteString("|" + strings.Repeat(" --- |", cols) + "\n")
+		}
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+// codeBlock renders a <pre> as a fenced block, taking the language from a
+// language-* class on it or its <code>.
+func codeBlock(pre *html.Node) string {
+	lang := ""
+	for _, n := range []*html.Node{pre, findFirst(pre, isAtom(atom.Code))} {
+		if n == nil {
+			continue
+		}
+		for _, class := range strings.Fields(attr(n, "class")) {
+			if l, ok := strings.CutPrefix(class, "language-"); ok {
+				lang = l
+			}
+		}
+	}
+	code := strings.Trim(textContent(pre), "\n")
+	fence := "```"
+	for strings.Contains(code, fence) {
+		fence += "`"
+	}
+	return fence + lang + "\n" + code + "\n" + fence
+}
+
+func inlineCode(s string) string {
+	s = strings.Join(strings.Fields(s), " ")
+	if s == "" {
+		return ""
+	}
+	if strings.Contains(s, "`") {
+		return "`` " + s + " ``"
+	}
+	return "`" + s + "`"
+}
+
+// wrap surrounds the trimmed text with a Markdown marker, keeping the
+// surrounding spaces outside it.
+func wrap(s, marker string) string {
+	text := strings.TrimSpace(s)
+	if text == "" {
+		return s
+	}
+	return keepSpace(s, marker+text+marker)
+}
+
+// keepSpace carries leading and trailing whitespace from orig over to s, so
+// inline elements don't run into neighboring words.
+func keepSpace(orig, s string) string {
+	if strings.TrimLeft(orig, " \n") != orig {
+		s = " " + s
+	}
+	if strings.TrimRight(orig, " \n") != orig {
+		s += " "
+	}
+	return s
+}
+
+func block(s string) string {
+	if strings.TrimSpace(s) == "" {
+		return ""
+	}
+	return "\n\n" + s + "\n\n"
+}
+
+func prefixLines(s, prefix string) string {
+	lines := strings.Split(s, "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight(prefix+line, " ")
+	}
+	return strings.Join(lines, "\n")
+}
+
+var (
+	spaceRun   = regexp.MustCompile(`[ \t\n\r\f]+`)
+	blankLines = regexp.MustCompile(`\n{2,}`)
+)
+
+func collapseSpace(s string) string {
+	return spaceRun.ReplaceAllString(s, " ")
+}
+
+// normalize trims trailing spaces and squeezes blank lines outside fenced
+// code blocks.
+func normalize(md string) string {
+	var out []string
+	fence := ""
+	blank := false
+	for _, line := range strings.Split(md, "\n") {
+		if fence != "" {
+			out = append(out, line)
+			if strings.TrimSpace(line) == fence {
+				fence = ""
+			}
+			continue
+		}
+		line = strings.TrimRight(line, " \t")
+		if strings.TrimSpace(line) == "" {
+			blank = len(out) > 0
+			continue
+		}
+		if blank {
+			out = append(out, "")
+			blank = false
+		}
+		// Text that followed a <br> starts with the space between words.
+		if !strings.HasPrefix(line, "  ") {
+			line = strings.TrimLeft(line, " ")
+		}
+		if t := strings.TrimLeft(line, " "); strings.HasPrefix(t, "```") {
+			fence = t[:strings.LastIndex(t, "`")+1]
+		}
+		out = append(out, line)
+	}
+	return strings.Join(out, "\n")
+}
@@ -0,0 +1,330 @@
+package webpage
+
+import (
+	"fmt"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// Selector is a parsed CSS selector list. It supports the subset agents
+// reach for when targeting part of a page: type (div, *), #id, .class,
+// attribute ([attr], [attr=v], [attr~=v], [attr^=v], [attr$=v], [attr*=v]),
+// descendant and child (>) combinators, and comma-separated groups.
+// Pseudo-classes are not supported.
+type Selector []complexSelector
+
+// complexSelector is a chain of compounds joined by combinators, where
+// combinators[i] sits between parts[i] and parts[i+1].
+type complexSelector struct {
+	parts       []compound
+	combinators []byte // ' ' (descendant) or '>' (child)
+}
+
+type compound struct {
+	tag     string // "" matches any element
+	id      string
+	classes []string
+	attrs   []attrSelector
+}
+
+type attrSelector struct {
+	key, op, val string // op is "" when only presence is tested
+}
+
+// ParseSelector parses a CSS selector list.
+func ParseSelector(s string) (Selector, error) {
+	var sel Selector
+	for _, group := range splitGroups(s) {
+		cs, err := parseComplex(group)
+		if err != nil {
+			return nil, fmt.Errorf("invalid selector %q: %w", s, err)
+		}
+		sel = append(sel, cs)
+	}
+	if len(sel) == 0 {
+		return nil, fmt.Errorf("invalid selector %q: empty", s)
+	}
+	return sel, nil
+}
+
+// Select returns the elements under root that match, in document order.
+// Matches nested inside an earlier match are left out, since their content
+// is already part of it.
+func (sel Selector) Select(root *html.Node) []*html.Node {
+	return findAll(root, sel.Matches)
+}
+
+// Matches reports whether n matches any selector in the list.
+func (sel Selector) Matches(n *html.Node) bool {
+	for _, cs := range sel {
+		if cs.matchAt(len(cs.parts)-1, n) {
+			return true
+		}
+	}
+	return false
+}
+
+func (cs complexSelector) matchAt(i int, n *html.Node) bool {
+	if !cs.parts[i].matches(n) {
+		return false
+	}
+	if i == 0 {
+		return true
+	}
+	if cs.combinators[i-1] == '>' {
+		p := n.Parent
+		return p != nil && p.Type == html.ElementNode && cs.matchAt(i-1, p)
+	}
+	for p := n.Parent; p != nil; p = p.Parent {
+		if p.Type == html.ElementNode && cs.matchAt(i-1, p) {
+			return true
+		}
+	}
+	return false
+}
+
+func (c compound) matches(n *html.Node) bool {
+	if n.Type != html.ElementNode {
+		return false
+	}
+	if c.tag != "" && c.tag != n.Data {
+		return false
+	}
+	if c.id != "" && attr(n, "id") != c.id {
+		return false
+	}
+	classes := strings.Fields(attr(n, "class"))
+	for _, want := range c.classes {
+		found := false
+		for _, class := range classes {
+			if class == want {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	for _, a := range c.attrs {
+		if !hasAttr(n, a.key) {
+			return false
+		}
+		v := attr(n, a.key)
+		switch a.op {
+		case "=":
+			if v != a.val {
+				return false
+			}
+		case "~=":
+			found := false
+			for _, word := range strings.Fields(v) {
+				found = found || word == a.val
+			}
+			if !found {
+				return false
+			}
+		case "^=":
+			if !strings.HasPrefix(v, a.val) {
+				return false
+			}
+		case "$=":
+			if !strings.HasSuffix(v, a.val) {
+				return false
+			}
+		case "*=":
+			if !strings.Contains(v, a.val) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// splitGroups splits on commas outside brackets and quotes.
+func splitGroups(s string) []string {
+	var groups []string
+	depth, quote, start := 0, byte(0), 0
+	for i := 0; i < len(s); i++ {
+		switch ch := s[i]; {
+		case quote != 0:
+			if ch == quote {
+				quote = 0
+			}
+		case ch == '"' || ch == '\'':
+			quote = ch
+		case ch == '[':
+			depth++
+		case ch == ']':
+			depth--
+		case ch == ',' && depth == 0:
+			groups = append(groups, s[start:i])
+			start = i + 1
+		}
+	}
+	groups = append(groups, s[start:])
+	var out []string
+	for _, g := range groups {
+		if g = strings.TrimSpace(g); g != "" {
+			out = append(out, g)
+		}
+	}
+	return out
+}
+
+func parseComplex(s string) (complexSelector, error) {
+	var cs complexSelector
+	p := &selParser{s: s}
+	for {
+		comb := byte(0)
+		if p.skipSpace() {
+			comb = ' '
+		}
+		if p.done() {
+			break
+		}
+		if p.peek() == '>' {
+			p.i++
+			p.skipSpace()
+			comb = '>'
+		}
+		if len(cs.parts) > 0 {
+			if comb == 0 {
+				return cs, fmt.Errorf("unexpected %q", p.peek())
+			}
+			cs.combinators = append(cs.combinators, comb)
+		} else if comb == '>' {
+			return cs, fmt.Errorf("selector starts with >")
+		}
+		c, err := p.compound()
+		if err != nil {
+			return cs, err
+		}
+		cs.parts = append(cs.parts, c)
+	}
+	if len(cs.parts) == 0 || len(cs.combinators) != len(cs.parts)-1 {
+		return cs, fmt.Errorf("incomplete selector")
+	}
+	return cs, nil
+}
+
+type selParser struct {
+	s string
+	i int
+}
+
+func (p *selParser) done() bool { return p.i >= len(p.s) }
+func (p *selParser) peek() byte { return p.s[p.i] }
+
+func (p *selParser) skipSpace() bool {
+	start := p.i
+	for !p.done() && strings.IndexByte(" \t\n", p.peek()) >= 0 {
+		p.i++
+	}
+	return p.i > start
+}
+
+func (p *selParser) ident() string {
+	start := p.i
+	for !p.done() {
+		ch := p.peek()
+		if ch == '-' || ch == '_' || ch >= '0' && ch <= '9' || ch >= 'a' && ch <= 'z' || ch >= 'A' && ch <= 'Z' || ch >= 0x80 {
+			p.i++
+			continue
+		}
+		break
+	}
+	return p.s[start:p.i]
+}
+
+func (p *selParser) compound() (compound, error) {
+	var c compound
+	if p.done() {
+		return c, fmt.Errorf("incomplete selector")
+	}
+	start := p.i
+	if p.peek() == '*' {
+		p.i++
+	} else {
+		c.tag = strings.ToLower(p.ident())
+	}
+loop:
+	for !p.done() {
+		switch p.peek() {
+		case '#':
+			p.i++
+			if c.id = p.ident(); c.id == "" {
+				return c, fmt.Errorf("missing id after #")
+			}
+		case '.':
+			p.i++
+			class := p.ident()
+			if class == "" {
+				return c, fmt.Errorf("missing class after .")
+			}
+			c.classes = append(c.classes, class)
+		case '[':
+			a, err := p.attr()
+			if err != nil {
+				return c, err
+			}
+			c.attrs = append(c.attrs, a)
+		case ':':
+			return c, fmt.Errorf("pseudo-classes are not supported")
+		case ' ', '\t', '\n', '>':
+			break loop
+		default:
+			return c, fmt.Errorf("unexpected %q", p.peek())
+		}
+	}
+	if p.i == start {
+		return c, fmt.Errorf("unexpected %q", p.peek())
+	}
+	return c, nil
+}
+
+func (p *selParser) attr() (attrSelector, error) {
+	p.i++ // [
+	p.skipSpace()
+	a := attrSelector{key: strings.ToLower(p.ident())}
+	if a.key == "" {
+		return a, fmt.Errorf("missing attribute name")
+	}
+	p.skipSpace()
+	if p.done() {
+		return a, fmt.Errorf("unclosed [")
+	}
+	if p.peek() != ']' {
+		for _, op := range []string{"=", "~=", "^=", "$=", "*="} {
+			if strings.HasPrefix(p.s[p.i:], op) {
+				a.op = op
+				p.i += len(op)
+				break
+			}
+		}
+		if a.op == "" {
+			return a, fmt.Errorf("unsupported attribute operator at %q", p.s[p.i:])
+		}
+		p.skipSpace()
+		if p.done() {
+			return a, fmt.Errorf("unclosed [")
+		}
+		if q := p.peek(); q == '"' || q == '\'' {
+			end := strings.IndexByte(p.s[p.i+1:], q)
+			if end < 0 {
+				return a, fmt.Errorf("unclosed quote")
+			}
+			a.val = p.s[p.i+1 : p.i+1+end]
+			p.i += end + 2
+		} else {
+			a.val = p.ident()
+		}
+		p.skipSpace()
+	}
+	if p.done() || p.peek() != ']' {
+		return a, fmt.Errorf("unclosed [")
+	}
+	p.i++
+	return a, nil
+}
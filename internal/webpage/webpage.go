@@ -0,0 +1,168 @@
+// Package webpage extracts the readable part of an HTML page as Markdown,
+// for tools that hand web content to a model. Scripts, styles, and (in
+// readable mode) navigation and other page chrome are dropped; headings,
+// lists, tables, code blocks, and links are kept.
+package webpage
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// Options controls what Extract keeps.
+type Options struct {
+	// BaseURL resolves relative links and image sources. May be nil.
+	BaseURL *url.URL
+	// Selector limits the output to elements matching a CSS selector. See
+	// ParseSelector for the supported syntax.
+	Selector string
+	// Full converts the whole body instead of guessing the main content,
+	// keeping navigation, headers, footers, and sidebars.
+	Full bool
+}
+
+// Page is an extracted page.
+type Page struct {
+	Title    string
+	Markdown string
+}
+
+// Extract parses an HTML document and converts its content to Markdown.
+func Extract(r io.Reader, opts Options) (*Page, error) {
+	doc, err := html.Parse(r)
+	if err != nil {
+		return nil, fmt.Errorf("parsing HTML: %w", err)
+	}
+
+	c := &converter{base: opts.BaseURL}
+	var roots []*html.Node
+	switch {
+	case opts.Selector != "":
+		sel, err := ParseSelector(opts.Selector)
+		if err != nil {
+			return nil, err
+		}
+		if roots = sel.Select(doc); len(roots) == 0 {
+			return nil, fmt.Errorf("selector %q matched nothing", opts.Selector)
+		}
+	case opts.Full:
+		roots = []*html.Node{findFirst(doc, isAtom(atom.Body))}
+	default:
+		c.readable = true
+		roots = []*html.Node{mainContent(findFirst(doc, isAtom(atom.Body)))}
+	}
+
+	var parts []string
+	for _, root := range roots {
+		if root == nil {
+			continue
+		}
+		c.root = root
+		parts = append(parts, c.node(root))
+	}
+	return &Page{
+		Title:    pageTitle(doc),
+		Markdown: normalize(strings.Join(parts, "\n\n")),
+	}, nil
+}
+
+// mainContent guesses which element holds the page's content: the single
+// <main> (or role=main) element, else the single <article>, else body.
+func mainContent(body *html.Node) *html.Node {
+	if body == nil {
+		return nil
+	}
+	mains := findAll(body, func(n *html.Node) bool {
+		return n.DataAtom == atom.Main || attr(n, "role") == "main"
+	})
+	if len(mains) == 1 {
+		return mains[0]
+	}
+	if articles := findAll(body, isAtom(atom.Article)); len(articles) == 1 {
+		return articles[0]
+	}
+	return body
+}
+
+// pageTitle returns the document's <title>, or its first <h1>.
+func pageTitle(doc *html.Node) string {
+	for _, match := range []func(*html.Node) bool{isAtom(atom.Title), isAtom(atom.H1)} {
+		if n := findFirst(doc, match); n != nil {
+			if title := strings.Join(strings.Fields(textContent(n)), " "); title != "" {
+				return title
+			}
+		}
+	}
+	return ""
+}
+
+func isAtom(a atom.Atom) func(*html.Node) bool {
+	return func(n *html.Node) bool { return n.DataAtom == a }
+}
+
+// findFirst returns the first element under n, in document order, that
+// matches.
+func findFirst(n *html.Node, match func(*html.Node) bool) *html.Node {
+	if n.Type == html.ElementNode && match(n) {
+		return n
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if found := findFirst(c, match); found != nil {
+			return found
+		}
+	}
+	return nil
+}
+
+// findAll returns every element under n that matches, without descending
+// into matches.
+func findAll(n *html.Node, match func(*html.Node) bool) []*html.Node {
+	var out []*html.Node
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.ElementNode && match(n) {
+			out = append(out, n)
+			return
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(n)
+	return out
+}
+
+func attr(n *html.Node, key string) string {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return a.Val
+		}
+	}
+	return ""
+}
+
+func hasAttr(n *html.Node, key string) bool {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return true
+		}
+	}
+	return false
+}
+
+// textContent returns the text of n and its descendants, as written.
+func textContent(n *html.Node) string {
+	if n.Type == html.TextNode {
+		return n.Data
+	}
+	var b strings.Builder
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		b.WriteString(textContent(c))
+	}
+	return b.String()
+}
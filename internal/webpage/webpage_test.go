@@ -0,0 +1,135 @@
+package webpage
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+)
+
+const testPage = `<!DOCTYPE html>
+<html>
+<head>
+  <title>Release notes - Example</title>
+  <style>body { color: red }</style>
+  <script>var tracking = 1;</script>
+</head>
+<body>
+  <header><a href="/">Home</a> <a href="/blog">Blog</a></header>
+  <nav><ul><li><a href="/docs">Docs</a></li></ul></nav>
+  <main>
+    <article>
+      <header><h1>Version 2.0</h1></header>
+      <p>This release adds <strong>streaming</strong> and a
+         <a href="/docs/streaming">new guide</a>.<br>
+         It also fixes bugs.</p>
+      <h2>Install</h2>
+      <pre><code class="language-sh">go install example.com/tool@v2.0.0
+tool --version</code></pre>
+      <ul>
+        <li>Faster startup</li>
+        <li>New flags
+          <ol><li>--quiet</li><li>--json</li></ol>
+        </li>
+      </ul>
+      <table>
+        <thead><tr><th>Flag</th><th>Meaning</th></tr></thead>
+        <tbody><tr><td><code>-q</code></td><td>Less | output</td></tr></tbody>
+      </table>
+      <blockquote><p>Upgrade soon.</p></blockquote>
+      <img src="img/chart.png" alt="Benchmark chart">
+      <div class="share-buttons"><a href="https://twitter.com">Tweet</a></div>
+    </article>
+    <aside>Related posts</aside>
+  </main>
+  <footer>Copyright 2024</footer>
+  <div hidden>secret</div>
+</body>
+</html>`
+
+func TestExtractReadable(t *testing.T) {
+	base, _ := url.Parse("https://example.com/blog/v2")
+	page, err := Extract(strings.NewReader(testPage), Options{BaseURL: base})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if page.Title != "Release notes - Example" {
+		t.Errorf("Title = %q", page.Title)
+	}
+
+	want := "# Version 2.0\n" +
+		"\n" +
+		"This release adds **streaming** and a [new guide](https://example.com/docs/streaming).\n" +
+		"It also fixes bugs.\n" +
+		"\n" +
+		"## Install\n" +
+		"\n" +
+		"```sh\n" +
+		"go install example.com/tool@v2.0.0\n" +
+		"tool --version\n" +
+		"```\n" +
+		"\n" +
+		"- Faster startup\n" +
+		"- New flags\n" +
+		"  1. --quiet\n" +
+		"  2. --json\n" +
+		"\n" +
+		"| Flag | Meaning |\n" +
+		"| --- | --- |\n" +
+		"| `-q` | Less \\| output |\n" +
+		"\n" +
+		"> Upgrade soon.\n" +
+		"\n" +
+		"![Benchmark chart](https://example.com/blog/img/chart.png)"
+	if page.Markdown != want {
+		t.Errorf("Markdown =\n%s\n\nwant\n%s", page.Markdown, want)
+	}
+}
+
+func TestExtractFull(t *testing.T) {
+	page, err := Extract(strings.NewReader(testPage), Options{Full: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []string{"[Blog](/blog)", "Related posts", "Copyright 2024", "[Tweet](https://twitter.com)"} {
+		if !strings.Contains(page.Markdown, s) {
+			t.Errorf("full page is missing %q:\n%s", s, page.Markdown)
+		}
+	}
+	for _, s := range []string{"tracking", "color: red", "secret"} {
+		if strings.Contains(page.Markdown, s) {
+			t.Errorf("full page should not contain %q", s)
+		}
+	}
+}
+
+func TestExtractSelector(t *testing.T) {
+	page, err := Extract(strings.NewReader(testPage), Options{Selector: "article > h2, table td:not(x)"})
+	if err == nil {
+		t.Fatalf("pseudo-class selector should fail, got %+v", page)
+	}
+
+	page, err = Extract(strings.NewReader(testPage), Options{Selector: "article > h2, nav a[href^='/d']"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "[Docs](/docs)\n\n## Install"; page.Markdown != want {
+		t.Errorf("Markdown = %q, want %q", page.Markdown, want)
+	}
+
+	if _, err := Extract(strings.NewReader(testPage), Options{Selector: "#missing"}); err == nil || !strings.Contains(err.Error(), "matched nothing") {
+		t.Errorf("expected matched nothing error, got %v", err)
+	}
+}
+
+func TestParseSelector(t *testing.T) {
+	for _, s := range []string{"div", "*", "#main", "div.post.featured", "a[href]", `a[href$=".pdf"]`, "ul > li a", "h1, h2"} {
+		if _, err := ParseSelector(s); err != nil {
+			t.Errorf("ParseSelector(%q): %v", s, err)
+		}
+	}
+	for _, s := range []string{"", "div >", "> div", "a[href", "a:hover", "div..x", "a[href|=en]"} {
+		if _, err := ParseSelector(s); err == nil {
+			t.Errorf("ParseSelector(%q) should fail", s)
+		}
+	}
+}
@@ -6,7 +6,10 @@ import {
   updateSession,
   listSessions,
   listProviders,
+  uploadAttachments,
+  exportUrl,
 } from '../lib/api';
+import type { ExportFormat } from '../lib/api';
 import { ForgeWebSocket } from '../lib/ws';
 import type { WSEvent, FallbackOption } from '../lib/ws';
 import MessageBubble from './MessageBubble';
@@ -28,6 +31,8 @@ export default function ChatView() {
   const store = useStore;
 
   const [input, setInput] = useState('');
+  const [files, setFiles] = useState<File[]>([]);
+  const fileInputRef = useRef<HTMLInputElement>(null);
   const chatRef = useRef<HTMLDivElement>(null);
   const wsRef = useRef<ForgeWebSocket | null>(null);
 
@@ -64,6 +69,9 @@ export default function ChatView() {
           args: event.args || {},
         });
         break;
+      case 'tool_output':
+        s.appendToolCallOutput(event.name || '', event.content || '');
+        break;
       case 'tool_result':
         s.updateToolCallResult(event.name || '', event.content || '');
         break;
@@ -76,6 +84,16 @@ export default function ChatView() {
         s.resetStreaming();
         break;
       }
+      case 'resync': {
+        // Events were missed while reconnecting; the current turn is replayed
+        // after this, on top of the saved messages.
+        const sid = store.getState().activeSessionId;
+        if (sid) {
+          getMessages(sid).then((msgs) => s.setMessages(msgs));
+        }
+        s.resetStreaming();
+        break;
+      }
       case 'error': {
         s.resetStreaming();
         const raw = event.content || 'Unknown error';
@@ -227,10 +245,21 @@ export default function ChatView() {
       }
     }
 
+    let attachments: string[] | undefined;
+    if (files.length > 0) {
+      try {
+        attachments = (await uploadAttachments(sid, files)).map((a) => a.name);
+        setFiles([]);
+      } catch (err: unknown) {
+        s.setError(`Failed to upload: ${err instanceof Error ? err.message : String(err)}`);
+        return;
+      }
+    }
+
     s.setIsStreaming(true);
     s.addStreamDelta(''); // reset
     store.setState({ streamingText: '', streamingToolCalls: [] });
-    s.addUserMessage(text);
+    s.addUserMessage(attachments ? `${text}\n\n(attached: ${attachments.join(', ')})` : text);
 
     // Wait for WS if needed
     await new Promise<void>((resolve) => {
@@ -243,7 +272,7 @@ export default function ChatView() {
       };
       check();
     });
-    wsRef.current!.send(text);
+    wsRef.current!.send(text, attachments);
   }
 
   function handleKeydown(e: React.KeyboardEvent) {
@@ -323,6 +352,14 @@ export default function ChatView() {
           <>
             <span className="chat-title">{activeSession.title || 'New Chat'}</span>
             <span className="chat-meta">{activeSession.provider}/{activeSession.model}</span>
+            <span className="chat-export">
+              Export
+              {(['md', 'html', 'json'] as ExportFormat[]).map((format) => (
+                <a key={format} href={exportUrl(activeSession.id, format)} download>
+                  {format.toUpperCase()}
+                </a>
+              ))}
+            </span>
           </>
         )}
       </div>
@@ -368,7 +405,7 @@ export default function ChatView() {
           <div className="bubble assistant streaming">
             <div className="role">Forge</div>
             {streamingToolCalls.map((tc, i) => (
-              <ToolCallCard key={i} name={tc.name} args={tc.args} result={tc.result} />
+              <ToolCallCard key={i} name={tc.name} args={tc.args} result={tc.result} output={tc.output} />
             ))}
             {streamingText && <Markdown content={streamingText} />}
             <span className="cursor">|</span>
@@ -376,7 +413,33 @@ export default function ChatView() {
         )}
       </div>
 
+      {files.length > 0 && (
+        <div className="chat-attachments">
+          {files.map((f, i) => (
+            <span key={i} className="attachment">
+              {f.name}
+              <button onClick={() => setFiles(files.filter((_, j) => j !== i))} title="Remove">
+                ×
+              </button>
+            </span>
+          ))}
+        </div>
+      )}
+
       <div className="chat-input">
+        <input
+          ref={fileInputRef}
+          type="file"
+          multiple
+          hidden
+          onChange={(e) => {
+            setFiles([...files, ...Array.from(e.target.files ?? [])]);
+            e.target.value = '';
+          }}
+        />
+        <button onClick={() => fileInputRef.current?.click()} disabled={isStreaming} title="Attach files">
+          Attach
+        </button>
         <textarea
           value={input}
           onChange={(e) => setInput(e.target.value)}
@@ -4,9 +4,10 @@ interface Props {
   name: string;
   args: Record<string, unknown>;
   result?: string;
+  output?: string;
 }
 
-export default function ToolCallCard({ name, args, result }: Props) {
+export default function ToolCallCard({ name, args, result, output }: Props) {
   const [expanded, setExpanded] = useState(false);
 
   return (
@@ -23,6 +24,12 @@ export default function ToolCallCard({ name, args, result }: Props) {
             <div className="tool-label">Arguments</div>
             <pre className="tool-pre">{JSON.stringify(args, null, 2)}</pre>
           </div>
+          {result === undefined && output && (
+            <div className="tool-section">
+              <div className="tool-label">Output so far</div>
+              <pre className="tool-pre">{output}</pre>
+            </div>
+          )}
           {result !== undefined && (
             <div className="tool-section">
               <div className="tool-label">Result</div>
@@ -16,6 +16,24 @@ export interface Message {
   content?: string;
   tool_calls?: ToolCall[];
   tool_call_id?: string;
+  meta?: MessageMeta;
+}
+
+export interface MessageMeta {
+  created_at: string;
+  model?: string;
+  latency_ms?: number;
+  prompt_tokens?: number;
+  completion_tokens?: number;
+}
+
+export interface Artifact {
+  session_id: string;
+  name: string;
+  mime_type: string;
+  size: number;
+  tool: string;
+  created_at: string;
 }
 
 export interface ToolCall {
@@ -24,10 +42,45 @@ export interface ToolCall {
   arguments: Record<string, any>;
 }
 
+export interface ToolCallRecord {
+  tool: string;
+  server: string;
+  session_id?: string;
+  call_id?: string;
+  args?: Record<string, any>;
+  result?: string;
+  duration: number; // nanoseconds
+  failed: boolean;
+  at: string;
+}
+
+export interface Profile {
+  name: string;
+  provider?: string;
+  model?: string;
+  system_prompt?: string;
+  tools?: string[];
+  max_iterations?: number;
+}
+
+export interface ToolDef {
+  name: string;
+  description: string;
+  parameters: Record<string, any>;
+}
+
+export interface ToolGroup {
+  server: string;
+  tools: ToolDef[];
+}
+
 export interface Provider {
   name: string;
   models: Record<string, string>;
   is_ollama: boolean;
+  available: boolean;
+  error?: string;
+  context_lengths?: Record<string, number>;
 }
 
 export interface ModelInfo {
@@ -71,6 +124,33 @@ export function getMessages(sessionId: string): Promise<Message[]> {
   return request(`/sessions/${sessionId}/messages`);
 }
 
+export type ExportFormat = 'md' | 'html' | 'json';
+
+// exportUrl is where a session's transcript downloads from.
+export function exportUrl(sessionId: string, format: ExportFormat): string {
+  return `${BASE}/sessions/${sessionId}/export?format=${format}`;
+}
+
+export function getArtifacts(sessionId: string): Promise<Artifact[]> {
+  return request(`/sessions/${sessionId}/artifacts`);
+}
+
+// uploadAttachments saves files to a session; messages refer to them by the
+// returned artifacts' names.
+export function uploadAttachments(sessionId: string, files: File[]): Promise<Artifact[]> {
+  const form = new FormData();
+  for (const f of files) form.append('file', f);
+  return request(`/sessions/${sessionId}/attachments`, { method: 'POST', body: form });
+}
+
+export function getToolCalls(sessionId: string): Promise<ToolCallRecord[]> {
+  return request(`/sessions/${sessionId}/tool-calls`);
+}
+
+export function artifactURL(sessionId: string, name: string): string {
+  return `${BASE}/sessions/${sessionId}/artifacts/${encodeURIComponent(name)}`;
+}
+
 export function sendMessage(sessionId: string, content: string): Promise<{ content: string }> {
   return request(`/sessions/${sessionId}/messages`, {
     method: 'POST',
@@ -79,7 +159,14 @@ export function sendMessage(sessionId: string, content: string): Promise<{ conte
   });
 }
 
-export function updateSession(id: string, updates: { provider?: string; model?: string }): Promise<Session> {
+export function cancelSession(sessionId: string): Promise<{ cancelled: boolean; messages: Message[] }> {
+  return request(`/sessions/${sessionId}/cancel`, { method: 'POST' });
+}
+
+export function updateSession(
+  id: string,
+  updates: { title?: string; provider?: string; model?: string; profile?: string; status?: string; tags?: string[] },
+): Promise<Session> {
   return request(`/sessions/${id}`, {
     method: 'PATCH',
     headers: { 'Content-Type': 'application/json' },
@@ -87,6 +174,54 @@ export function updateSession(id: string, updates: { provider?: string; model?:
   });
 }
 
+export function listProfiles(): Promise<Profile[]> {
+  return request('/profiles');
+}
+
+export function createProfile(profile: Profile): Promise<Profile> {
+  return request('/profiles', {
+    method: 'POST',
+    headers: { 'Content-Type': 'application/json' },
+    body: JSON.stringify(profile),
+  });
+}
+
+export function updateProfile(profile: Profile): Promise<Profile> {
+  return request(`/profiles/${encodeURIComponent(profile.name)}`, {
+    method: 'PUT',
+    headers: { 'Content-Type': 'application/json' },
+    body: JSON.stringify(profile),
+  });
+}
+
+export function deleteProfile(name: string): Promise<void> {
+  return request(`/profiles/${encodeURIComponent(name)}`, { method: 'DELETE' });
+}
+
+export function switchModel(sessionId: string, model: string, provider?: string): Promise<Session> {
+  return request(`/sessions/${sessionId}/model`, {
+    method: 'PATCH',
+    headers: { 'Content-Type': 'application/json' },
+    body: JSON.stringify({ provider, model }),
+  });
+}
+
+export function listTools(): Promise<ToolGroup[]> {
+  return request('/tools');
+}
+
+export function callTool(
+  name: string,
+  args: Record<string, any>,
+  sessionId?: string,
+): Promise<{ result: string; failed: boolean }> {
+  return request(`/tools/${encodeURIComponent(name)}/call`, {
+    method: 'POST',
+    headers: { 'Content-Type': 'application/json' },
+    body: JSON.stringify({ args, session_id: sessionId }),
+  });
+}
+
 export function listProviders(): Promise<Provider[]> {
   return request('/providers');
 }
@@ -6,6 +6,7 @@ export interface StreamingToolCall {
   name: string;
   args: Record<string, unknown>;
   result?: string;
+  output?: string; // what the tool has printed so far, before its result
 }
 
 interface ForgeState {
@@ -27,6 +28,7 @@ interface ForgeState {
   setIsStreaming: (v: boolean) => void;
   addStreamDelta: (delta: string) => void;
   addStreamToolCall: (tc: StreamingToolCall) => void;
+  appendToolCallOutput: (name: string, output: string) => void;
   updateToolCallResult: (name: string, result: string) => void;
   resetStreaming: () => void;
   setError: (msg: string, fallback?: FallbackOption[]) => void;
@@ -59,6 +61,15 @@ export const useStore = create<ForgeState>((set) => ({
     set((s) => ({ streamingText: s.streamingText + delta })),
   addStreamToolCall: (tc) =>
     set((s) => ({ streamingToolCalls: [...s.streamingToolCalls, tc] })),
+  appendToolCallOutput: (name, output) =>
+    set((s) => {
+      const calls = [...s.streamingToolCalls];
+      const idx = calls.findLastIndex((tc) => tc.name === name && !tc.result);
+      if (idx >= 0) {
+        calls[idx] = { ...calls[idx], output: (calls[idx].output || '') + output };
+      }
+      return { streamingToolCalls: calls };
+    }),
   updateToolCallResult: (name, result) =>
     set((s) => {
       const calls = [...s.streamingToolCalls];
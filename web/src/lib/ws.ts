@@ -1,4 +1,4 @@
-export type WSEventType = 'text_delta' | 'tool_call' | 'tool_result' | 'done' | 'error';
+export type WSEventType = 'queued' | 'started' | 'text_delta' | 'tool_call' | 'tool_output' | 'tool_result' | 'done' | 'error' | 'resync';
 
 export interface FallbackOption {
   provider: string;
@@ -11,6 +11,8 @@ export interface WSEvent {
   name?: string;
   args?: Record<string, any>;
   fallback_options?: FallbackOption[];
+  position?: number;
+  index?: number;
 }
 
 export type WSEventHandler = (event: WSEvent) => void;
@@ -20,6 +22,8 @@ export class ForgeWebSocket {
   private sessionId: string;
   private handler: WSEventHandler;
   private reconnectTimer: number | null = null;
+  // Index of the last event received, so a reconnect can resume after it.
+  private lastEvent = 0;
 
   constructor(sessionId: string, handler: WSEventHandler) {
     this.sessionId = sessionId;
@@ -30,11 +34,19 @@ export class ForgeWebSocket {
     const protocol = location.protocol === 'https:' ? 'wss:' : 'ws:';
     const url = `${protocol}//${location.host}/api/sessions/${this.sessionId}/ws`;
 
-    this.ws = new WebSocket(url);
+    const ws = new WebSocket(url);
+    this.ws = ws;
+
+    if (this.lastEvent > 0) {
+      ws.onopen = () => ws.send(JSON.stringify({ type: 'resume', last_event: this.lastEvent }));
+    }
 
     this.ws.onmessage = (event) => {
       try {
         const data: WSEvent = JSON.parse(event.data);
+        if (data.index) {
+          this.lastEvent = data.index;
+        }
         this.handler(data);
       } catch (err) {
         console.error('Failed to parse WebSocket message:', err);
@@ -52,9 +64,9 @@ export class ForgeWebSocket {
     };
   }
 
-  send(content: string): void {
+  send(content: string, attachments?: string[]): void {
     if (this.ws?.readyState === WebSocket.OPEN) {
-      this.ws.send(JSON.stringify({ type: 'message', content }));
+      this.ws.send(JSON.stringify({ type: 'message', content, attachments }));
     }
   }
 